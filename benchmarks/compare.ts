@@ -17,6 +17,7 @@ interface BenchmarkResult {
   avgTimeNs: number;
   opsPerSecond: number;
   bytesPerOp: number;
+  allocsPerOp?: number;
 }
 
 interface BenchmarkSuite {
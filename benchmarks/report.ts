@@ -0,0 +1,306 @@
+#!/usr/bin/env bun
+/**
+ * Benchmark Report
+ *
+ * Merges the TypeScript and Go schema benchmark results with the DNS comparison
+ * (BinSchema vs Kaitai vs C) and renders them as Markdown and HTML, grouped by
+ * schema and operation.
+ *
+ * Inputs (any may be missing):
+ *   results-ts.json                     from `just bench-ts`
+ *   results-go.json                     from `just bench-go`
+ *   go-compare/benchmark_results.txt    from `just bench-go-compare`
+ *
+ * Outputs: report.md and report.html next to this script.
+ */
+
+import { readFileSync, writeFileSync, existsSync } from "fs";
+import { join, relative } from "path";
+
+interface BenchmarkResult {
+  name: string;
+  type: string;
+  operation: "encode" | "decode";
+  iterations: number;
+  totalTimeNs: number;
+  avgTimeNs: number;
+  opsPerSecond: number;
+  bytesPerOp: number;
+  allocsPerOp?: number;
+}
+
+interface BenchmarkSuite {
+  language: string;
+  timestamp: string;
+  revision?: string;
+  results: BenchmarkResult[];
+}
+
+/**
+ * One implementation's result for one benchmark and operation
+ */
+interface Entry {
+  schema: string;
+  benchmark: string;
+  operation: string;
+  implementation: string;
+  avgTimeNs: number;
+  allocsPerOp?: number;
+}
+
+/**
+ * Where the entries came from, listed at the top of the report
+ */
+interface Source {
+  file: string;
+  description: string;
+}
+
+/**
+ * Format nanoseconds for display
+ */
+function formatNs(ns: number): string {
+  if (ns < 1000) {
+    return `${ns.toFixed(1)}ns`;
+  } else if (ns < 1_000_000) {
+    return `${(ns / 1000).toFixed(2)}µs`;
+  } else {
+    return `${(ns / 1_000_000).toFixed(2)}ms`;
+  }
+}
+
+/**
+ * Split "schema/benchmark" result names into their parts
+ */
+function splitName(name: string): [string, string] {
+  const slash = name.indexOf("/");
+  if (slash < 0) return [name, name];
+  return [name.substring(0, slash), name.substring(slash + 1)];
+}
+
+/**
+ * Entries of a run-ts.ts / run-go.go results file
+ */
+function loadSuite(path: string, implementation: string, sources: Source[]): Entry[] {
+  const suite: BenchmarkSuite = JSON.parse(readFileSync(path, "utf-8"));
+  const revision = suite.revision ? ` at ${suite.revision}` : "";
+  sources.push({ file: relative(__dirname, path), description: `${implementation}, ${suite.timestamp}${revision}` });
+
+  return suite.results.map((r) => {
+    const [schema, benchmark] = splitName(r.name);
+    return {
+      schema,
+      benchmark,
+      operation: r.operation,
+      implementation,
+      avgTimeNs: r.avgTimeNs,
+      allocsPerOp: r.allocsPerOp,
+    };
+  });
+}
+
+// Implementations in the DNS comparison, by benchmark function prefix
+const COMPARE_IMPLEMENTATIONS: Record<string, string> = {
+  BinSchema: "Go (BinSchema)",
+  Kaitai: "Go (Kaitai)",
+  C: "C",
+  Ldns: "C (ldns)",
+};
+
+/**
+ * Entries of the DNS comparison's `go test -bench` output. Benchmarks are named
+ * Benchmark<Implementation><Query|Response><Decode|Encode><Variant>; repeated runs
+ * (-count) are averaged.
+ */
+function loadCompare(path: string, sources: Source[]): Entry[] {
+  const output = readFileSync(path, "utf-8");
+  sources.push({ file: relative(__dirname, path), description: "DNS comparison (go test -bench)" });
+
+  // BenchmarkCQueryDecode-24    12345678    95.1 ns/op    0 B/op    0 allocs/op
+  const re = /^Benchmark(BinSchema|Kaitai|Ldns|C)(Query|Response)(Decode|Encode)(\w*)-\d+\s+\d+\s+([\d.]+) ns\/op(?:\s+\d+ B\/op\s+(\d+) allocs\/op)?/gm;
+
+  const runs = new Map<string, { entry: Entry; totalNs: number; count: number }>();
+  for (const match of output.matchAll(re)) {
+    const [, impl, message, operation, variant, ns, allocs] = match;
+    const implementation = variant
+      ? `${COMPARE_IMPLEMENTATIONS[impl]}, ${variant.toLowerCase()}`
+      : COMPARE_IMPLEMENTATIONS[impl];
+    const key = `${implementation}/${message}/${operation}`;
+
+    let run = runs.get(key);
+    if (!run) {
+      run = {
+        entry: {
+          schema: "dns",
+          benchmark: message.toLowerCase(),
+          operation: operation.toLowerCase(),
+          implementation,
+          avgTimeNs: 0,
+          allocsPerOp: allocs !== undefined ? parseInt(allocs) : undefined,
+        },
+        totalNs: 0,
+        count: 0,
+      };
+      runs.set(key, run);
+    }
+    run.totalNs += parseFloat(ns);
+    run.count++;
+  }
+
+  return [...runs.values()].map((run) => ({ ...run.entry, avgTimeNs: run.totalNs / run.count }));
+}
+
+/**
+ * Entries grouped by schema, then by "benchmark/operation", each group fastest first
+ */
+function groupEntries(entries: Entry[]): Map<string, Map<string, Entry[]>> {
+  const schemas = new Map<string, Map<string, Entry[]>>();
+  const sorted = [...entries].sort((a, b) =>
+    a.schema.localeCompare(b.schema) ||
+    a.benchmark.localeCompare(b.benchmark) ||
+    a.operation.localeCompare(b.operation) ||
+    a.avgTimeNs - b.avgTimeNs
+  );
+
+  for (const entry of sorted) {
+    if (!schemas.has(entry.schema)) {
+      schemas.set(entry.schema, new Map());
+    }
+    const groups = schemas.get(entry.schema)!;
+    const key = `${entry.benchmark}/${entry.operation}`;
+    if (!groups.has(key)) {
+      groups.set(key, []);
+    }
+    groups.get(key)!.push(entry);
+  }
+  return schemas;
+}
+
+/**
+ * How much slower than the group's fastest entry
+ */
+function vsFastest(entry: Entry, fastest: Entry): string {
+  if (entry === fastest || fastest.avgTimeNs === 0) return "fastest";
+  return `${(entry.avgTimeNs / fastest.avgTimeNs).toFixed(2)}x`;
+}
+
+function formatAllocs(entry: Entry): string {
+  return entry.allocsPerOp !== undefined ? String(entry.allocsPerOp) : "N/A";
+}
+
+function renderMarkdown(schemas: Map<string, Map<string, Entry[]>>, sources: Source[], generated: string): string {
+  const lines: string[] = [];
+  lines.push("# BinSchema Benchmark Report", "");
+  lines.push(`Generated ${generated} from:`, "");
+  for (const source of sources) {
+    lines.push(`- \`${source.file}\` — ${source.description}`);
+  }
+
+  for (const [schema, groups] of schemas) {
+    lines.push("", `## ${schema}`, "");
+    lines.push("| Benchmark | Operation | Implementation | Time/op | Allocs/op | vs fastest |");
+    lines.push("|-----------|-----------|----------------|--------:|----------:|-----------:|");
+    for (const entries of groups.values()) {
+      const fastest = entries[0];
+      for (const entry of entries) {
+        lines.push(`| ${entry.benchmark} | ${entry.operation} | ${entry.implementation} | ${formatNs(entry.avgTimeNs)} | ${formatAllocs(entry)} | ${vsFastest(entry, fastest)} |`);
+      }
+    }
+  }
+
+  return lines.join("\n") + "\n";
+}
+
+function escapeHtml(s: string): string {
+  return s.replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;").replace(/"/g, "&quot;");
+}
+
+function renderHtml(schemas: Map<string, Map<string, Entry[]>>, sources: Source[], generated: string): string {
+  const parts: string[] = [];
+  parts.push(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>BinSchema Benchmark Report</title>
+<style>
+  body { font-family: system-ui, sans-serif; margin: 2rem; color: #222; }
+  table { border-collapse: collapse; margin-bottom: 1.5rem; min-width: 50rem; }
+  th, td { padding: 0.3rem 0.6rem; border-bottom: 1px solid #ddd; text-align: left; }
+  td.num { text-align: right; font-variant-numeric: tabular-nums; }
+  td.chart { width: 20rem; }
+  .bar { height: 0.8rem; background: #4a7bd0; }
+  .fastest .bar { background: #3a9a5b; }
+  caption { text-align: left; font-weight: bold; padding: 0.3rem 0; }
+</style>
+</head>
+<body>
+<h1>BinSchema Benchmark Report</h1>
+<p>Generated ${escapeHtml(generated)} from:</p>
+<ul>`);
+  for (const source of sources) {
+    parts.push(`  <li><code>${escapeHtml(source.file)}</code> — ${escapeHtml(source.description)}</li>`);
+  }
+  parts.push("</ul>");
+
+  for (const [schema, groups] of schemas) {
+    parts.push(`<h2>${escapeHtml(schema)}</h2>`);
+    for (const entries of groups.values()) {
+      const fastest = entries[0];
+      const slowest = entries[entries.length - 1];
+      parts.push(`<table>
+<caption>${escapeHtml(fastest.benchmark)} — ${escapeHtml(fastest.operation)}</caption>
+<tr><th>Implementation</th><th>Time/op</th><th>Allocs/op</th><th>vs fastest</th><th></th></tr>`);
+      for (const entry of entries) {
+        const width = slowest.avgTimeNs > 0 ? (entry.avgTimeNs / slowest.avgTimeNs) * 100 : 0;
+        parts.push(`<tr${entry === fastest ? ' class="fastest"' : ""}><td>${escapeHtml(entry.implementation)}</td>` +
+          `<td class="num">${formatNs(entry.avgTimeNs)}</td>` +
+          `<td class="num">${formatAllocs(entry)}</td>` +
+          `<td class="num">${vsFastest(entry, fastest)}</td>` +
+          `<td class="chart"><div class="bar" style="width: ${width.toFixed(1)}%"></div></td></tr>`);
+      }
+      parts.push("</table>");
+    }
+  }
+
+  parts.push("</body>", "</html>");
+  return parts.join("\n") + "\n";
+}
+
+function main() {
+  const benchDir = join(__dirname);
+  const sources: Source[] = [];
+  const entries: Entry[] = [];
+
+  const tsResultsPath = join(benchDir, "results-ts.json");
+  const goResultsPath = join(benchDir, "results-go.json");
+  const compareResultsPath = join(benchDir, "go-compare", "benchmark_results.txt");
+
+  if (existsSync(tsResultsPath)) {
+    entries.push(...loadSuite(tsResultsPath, "TypeScript", sources));
+  }
+  if (existsSync(goResultsPath)) {
+    entries.push(...loadSuite(goResultsPath, "Go", sources));
+  }
+  if (existsSync(compareResultsPath)) {
+    entries.push(...loadCompare(compareResultsPath, sources));
+  }
+
+  if (entries.length === 0) {
+    console.log("No benchmark results found. Run 'just bench-ts', 'just bench-go' and/or 'just bench-go-compare' first.");
+    process.exit(1);
+  }
+
+  const schemas = groupEntries(entries);
+  const generated = new Date().toISOString();
+
+  const markdownPath = join(benchDir, "report.md");
+  const htmlPath = join(benchDir, "report.html");
+  writeFileSync(markdownPath, renderMarkdown(schemas, sources, generated));
+  writeFileSync(htmlPath, renderHtml(schemas, sources, generated));
+
+  console.log(`📊 ${entries.length} results from ${sources.length} source(s)`);
+  console.log(`📁 Markdown report: ${markdownPath}`);
+  console.log(`📁 HTML report: ${htmlPath}`);
+}
+
+main();
@@ -1,8 +1,12 @@
 // Package main implements a benchmark runner for BinSchema Go implementation.
 // It generates Go benchmark code from schema files and runs them.
+// With -compare it checks the results against a baseline run and exits non-zero
+// when any benchmark got slower or allocates more beyond its threshold, so CI can
+// gate on it.
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -40,17 +44,23 @@ type BenchmarkResult struct {
 	AvgTimeNs    float64 `json:"avgTimeNs"`
 	OpsPerSecond float64 `json:"opsPerSecond"`
 	BytesPerOp   int     `json:"bytesPerOp"`
+	AllocsPerOp  *int    `json:"allocsPerOp,omitempty"` // nil in results recorded without allocations
 }
 
 // BenchmarkSuite represents the full benchmark output
 type BenchmarkSuite struct {
 	Language  string            `json:"language"`
 	Timestamp string            `json:"timestamp"`
+	Revision  string            `json:"revision"`
 	Results   []BenchmarkResult `json:"results"`
 }
 
 func main() {
 	outputJSON := flag.String("json", "", "Output JSON file for results")
+	historyDir := flag.String("history", "", "Directory to also save results in, one file per git revision")
+	baselineJSON := flag.String("compare", "", "Baseline JSON file to compare results against; exits non-zero on regressions")
+	threshold := flag.Float64("threshold", 10, "Allowed slowdown against the baseline, in percent")
+	allocThreshold := flag.Float64("alloc-threshold", 10, "Allowed increase in allocs/op against the baseline, in percent")
 	flag.Parse()
 
 	fmt.Println("🚀 BinSchema Go Performance Benchmarks")
@@ -99,8 +109,8 @@ func main() {
 		grouped[result.Name][result.Operation] = result
 	}
 
-	fmt.Printf("\n%-40s %12s %12s %10s\n", "Benchmark", "Encode/op", "Decode/op", "Bytes")
-	fmt.Println(strings.Repeat("-", 76))
+	fmt.Printf("\n%-40s %12s %12s %10s %12s\n", "Benchmark", "Encode/op", "Decode/op", "Bytes", "Allocs e/d")
+	fmt.Println(strings.Repeat("-", 89))
 
 	// Sort benchmark names
 	names := make([]string, 0, len(grouped))
@@ -113,34 +123,169 @@ func main() {
 		ops := grouped[name]
 		encodeNs := "N/A"
 		decodeNs := "N/A"
+		encodeAllocs := "-"
+		decodeAllocs := "-"
 		bytes := 0
 		if e, ok := ops["encode"]; ok {
 			encodeNs = formatNs(e.AvgTimeNs)
+			encodeAllocs = formatAllocs(e.AllocsPerOp)
 			bytes = e.BytesPerOp
 		}
 		if d, ok := ops["decode"]; ok {
 			decodeNs = formatNs(d.AvgTimeNs)
+			decodeAllocs = formatAllocs(d.AllocsPerOp)
 			if bytes == 0 {
 				bytes = d.BytesPerOp
 			}
 		}
-		fmt.Printf("%-40s %12s %12s %10d\n", name, encodeNs, decodeNs, bytes)
+		fmt.Printf("%-40s %12s %12s %10d %12s\n", name, encodeNs, decodeNs, bytes, encodeAllocs+"/"+decodeAllocs)
 	}
 
+	output := BenchmarkSuite{
+		Language:  "go",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Revision:  gitRevision(),
+		Results:   allResults,
+	}
+	outputBytes, _ := json.MarshalIndent(output, "", "  ")
+
 	// Write JSON results if requested
 	if *outputJSON != "" {
-		output := BenchmarkSuite{
-			Language:  "go",
-			Timestamp: time.Now().Format(time.RFC3339),
-			Results:   allResults,
-		}
-		outputBytes, _ := json.MarshalIndent(output, "", "  ")
 		if err := os.WriteFile(*outputJSON, outputBytes, 0644); err != nil {
 			fmt.Fprintf(os.Stderr, "Error writing JSON output: %v\n", err)
 		} else {
 			fmt.Printf("\n📁 Results saved to: %s\n", *outputJSON)
 		}
 	}
+
+	// Keep a copy per revision, so any two revisions can be compared later
+	if *historyDir != "" {
+		historyFile := filepath.Join(*historyDir, "go-"+output.Revision+".json")
+		if err := os.MkdirAll(*historyDir, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating history directory: %v\n", err)
+		} else if err := os.WriteFile(historyFile, outputBytes, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing history file: %v\n", err)
+		} else {
+			fmt.Printf("📁 Results saved to: %s\n", historyFile)
+		}
+	}
+
+	if *baselineJSON != "" {
+		regressions, err := compareWithBaseline(*baselineJSON, allResults, *threshold, *allocThreshold)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error comparing with baseline: %v\n", err)
+			os.Exit(1)
+		}
+		if regressions > 0 {
+			fmt.Printf("\n❌ %d benchmark(s) got more than %.0f%% slower or allocate more than %.0f%% more\n", regressions, *threshold, *allocThreshold)
+			os.Exit(1)
+		}
+		fmt.Printf("\n✅ No regressions beyond %.0f%% (time) and %.0f%% (allocs)\n", *threshold, *allocThreshold)
+	}
+}
+
+// gitRevision returns the short hash of HEAD, marked dirty if the tree has changes,
+// or "unknown" outside a git checkout
+func gitRevision() string {
+	out, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		return "unknown"
+	}
+	revision := strings.TrimSpace(string(out))
+	if status, err := exec.Command("git", "status", "--porcelain").Output(); err == nil && len(bytes.TrimSpace(status)) > 0 {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// compareWithBaseline prints each result against the same benchmark in the baseline
+// file and returns how many got slower by more than threshold percent, or allocate
+// more per op by more than allocThreshold percent. Allocations are only compared
+// when the baseline recorded them. Benchmarks missing from either side are reported
+// but don't count as regressions.
+func compareWithBaseline(baselinePath string, results []BenchmarkResult, threshold, allocThreshold float64) (int, error) {
+	baselineBytes, err := os.ReadFile(baselinePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read baseline: %w", err)
+	}
+	var baseline BenchmarkSuite
+	if err := json.Unmarshal(baselineBytes, &baseline); err != nil {
+		return 0, fmt.Errorf("failed to parse baseline: %w", err)
+	}
+
+	baselineResults := make(map[string]BenchmarkResult)
+	for _, result := range baseline.Results {
+		baselineResults[result.Name+"/"+result.Operation] = result
+	}
+
+	revision := baseline.Revision
+	if revision == "" {
+		revision = "unknown revision"
+	}
+	fmt.Println("\n" + strings.Repeat("=", 60))
+	fmt.Printf("📉 Comparison with %s (%s)\n", baselinePath, revision)
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Printf("\n%-40s %-7s %12s %12s %9s %9s\n", "Benchmark", "Op", "Baseline", "Current", "Change", "Allocs")
+	fmt.Println(strings.Repeat("-", 94))
+
+	sorted := append([]BenchmarkResult(nil), results...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].Operation < sorted[j].Operation
+	})
+
+	regressions := 0
+	for _, result := range sorted {
+		key := result.Name + "/" + result.Operation
+		base, ok := baselineResults[key]
+		delete(baselineResults, key)
+		if !ok || base.AvgTimeNs == 0 {
+			fmt.Printf("%-40s %-7s %12s %12s %9s %9s\n", result.Name, result.Operation, "N/A", formatNs(result.AvgTimeNs), "new", formatAllocs(result.AllocsPerOp))
+			continue
+		}
+		change := (result.AvgTimeNs - base.AvgTimeNs) / base.AvgTimeNs * 100
+		allocs, allocsRegressed := compareAllocs(base.AllocsPerOp, result.AllocsPerOp, allocThreshold)
+		marker := ""
+		if change > threshold || allocsRegressed {
+			marker = " ❌"
+			regressions++
+		}
+		fmt.Printf("%-40s %-7s %12s %12s %+8.1f%% %9s%s\n", result.Name, result.Operation, formatNs(base.AvgTimeNs), formatNs(result.AvgTimeNs), change, allocs, marker)
+	}
+
+	missing := make([]string, 0, len(baselineResults))
+	for key := range baselineResults {
+		missing = append(missing, key)
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		fmt.Printf("⚠️  %s is in the baseline but was not run\n", key)
+	}
+
+	return regressions, nil
+}
+
+// compareAllocs formats a result's allocs/op against the baseline's, and reports
+// whether they grew by more than threshold percent. Any allocation where the
+// baseline had none counts; a baseline without allocation data never does.
+func compareAllocs(base, current *int, threshold float64) (string, bool) {
+	if base == nil || current == nil {
+		return formatAllocs(current), false
+	}
+	if *base == *current {
+		return strconv.Itoa(*current), false
+	}
+	return fmt.Sprintf("%d->%d", *base, *current), float64(*current) > float64(*base)*(1+threshold/100)
+}
+
+// formatAllocs formats an allocation count, "-" if it wasn't recorded
+func formatAllocs(allocs *int) string {
+	if allocs == nil {
+		return "-"
+	}
+	return strconv.Itoa(*allocs)
 }
 
 func runSchemaFile(schemaPath string) ([]BenchmarkResult, error) {
@@ -227,8 +372,8 @@ func runSchemaFile(schemaPath string) ([]BenchmarkResult, error) {
 
 	// Print inline results
 	for _, r := range results {
-		fmt.Printf("  ⏱️  %s/%s: %s/op (%s ops/s)\n",
-			r.Name, r.Operation, formatNs(r.AvgTimeNs), formatOps(r.OpsPerSecond))
+		fmt.Printf("  ⏱️  %s/%s: %s/op (%s ops/s, %s allocs/op)\n",
+			r.Name, r.Operation, formatNs(r.AvgTimeNs), formatOps(r.OpsPerSecond), formatAllocs(r.AllocsPerOp))
 	}
 
 	return results, nil
@@ -458,7 +603,7 @@ func parseBenchmarkOutput(output string, schemaName string) []BenchmarkResult {
 	// Parse Go benchmark output format:
 	// BenchmarkPoint_Encode-16    26052536    46.93 ns/op    170.47 MB/s    8 B/op    1 allocs/op
 	// Note: MB/s field is optional and there may be different spacing
-	re := regexp.MustCompile(`Benchmark(\w+)_(Encode|Decode)-\d+\s+(\d+)\s+([\d.]+)\s+ns/op(?:\s+[\d.]+\s+MB/s)?\s+(\d+)\s+B/op`)
+	re := regexp.MustCompile(`Benchmark(\w+)_(Encode|Decode)-\d+\s+(\d+)\s+([\d.]+)\s+ns/op(?:\s+[\d.]+\s+MB/s)?\s+(\d+)\s+B/op\s+(\d+)\s+allocs/op`)
 
 	for _, match := range re.FindAllStringSubmatch(output, -1) {
 		benchName := match[1]
@@ -466,6 +611,7 @@ func parseBenchmarkOutput(output string, schemaName string) []BenchmarkResult {
 		iterations, _ := strconv.Atoi(match[3])
 		avgNs, _ := strconv.ParseFloat(match[4], 64)
 		bytesPerOp, _ := strconv.Atoi(match[5])
+		allocsPerOp, _ := strconv.Atoi(match[6])
 
 		fullName := schemaName + "/" + toSnakeCase(benchName)
 
@@ -478,6 +624,7 @@ func parseBenchmarkOutput(output string, schemaName string) []BenchmarkResult {
 			AvgTimeNs:    avgNs,
 			OpsPerSecond: 1e9 / avgNs,
 			BytesPerOp:   bytesPerOp,
+			AllocsPerOp:  &allocsPerOp,
 		})
 	}
 
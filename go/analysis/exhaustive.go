@@ -0,0 +1,155 @@
+// ABOUTME: Exhaustiveness checks of type switches on generated discriminated unions
+// ABOUTME: A switch missing a variant is reported, so a variant added to a schema shows up where unions are used
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+)
+
+// Diagnostic is a type switch on a union without a case for some of its variants
+type Diagnostic struct {
+	Pos     token.Position
+	Union   string   // The union, as the switch's package names it ("dns.Rdata")
+	Missing []string // The variants without a case, named the same way ("*dns.RdataA")
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: type switch on %s is missing %s", d.Pos, d.Union, strings.Join(d.Missing, ", "))
+}
+
+// Variants returns the variants of a generated union, or nil if named isn't one. A
+// union is an interface with an unexported marker method, isRdata for Rdata, so only
+// types of its own package implement it: its variants are the types of that package
+// whose pointers do, in name order.
+func Variants(named *types.Named) []types.Type {
+	iface, ok := named.Underlying().(*types.Interface)
+	pkg := named.Obj().Pkg()
+	if !ok || pkg == nil {
+		return nil
+	}
+	marker := false
+	for i := 0; i < iface.NumMethods(); i++ {
+		method := iface.Method(i)
+		sig := method.Type().(*types.Signature)
+		if method.Name() == "is"+named.Obj().Name() && sig.Params().Len() == 0 && sig.Results().Len() == 0 {
+			marker = true
+		}
+	}
+	if !marker {
+		return nil
+	}
+	var variants []types.Type
+	for _, name := range pkg.Scope().Names() {
+		typeName, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok || typeName.IsAlias() || types.IsInterface(typeName.Type()) {
+			continue
+		}
+		if ptr := types.NewPointer(typeName.Type()); types.Implements(ptr, iface) {
+			variants = append(variants, ptr)
+		}
+	}
+	return variants
+}
+
+// Check returns the type switches in files, type-checked as pkg into info, on a union
+// without a case for each of its variants. A default case doesn't stand in for the
+// variants it catches: the point is that a variant added to the schema is reported
+// until the switch handles it. info needs Types.
+func Check(fset *token.FileSet, files []*ast.File, pkg *types.Package, info *types.Info) []Diagnostic {
+	qualifier := func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		return other.Name()
+	}
+	var diagnostics []Diagnostic
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			sw, ok := n.(*ast.TypeSwitchStmt)
+			if !ok {
+				return true
+			}
+			var assert ast.Expr
+			switch s := sw.Assign.(type) {
+			case *ast.ExprStmt:
+				assert = s.X
+			case *ast.AssignStmt:
+				assert = s.Rhs[0]
+			}
+			named, ok := types.Unalias(info.TypeOf(assert.(*ast.TypeAssertExpr).X)).(*types.Named)
+			if !ok {
+				return true
+			}
+			var cases []types.Type
+			for _, clause := range sw.Body.List {
+				for _, expr := range clause.(*ast.CaseClause).List {
+					if t := info.TypeOf(expr); t != nil {
+						cases = append(cases, t)
+					}
+				}
+			}
+			var missing []string
+			for _, variant := range Variants(named) {
+				if !containsType(cases, variant) {
+					missing = append(missing, types.TypeString(variant, qualifier))
+				}
+			}
+			if len(missing) > 0 {
+				diagnostics = append(diagnostics, Diagnostic{
+					Pos:     fset.Position(sw.Pos()),
+					Union:   types.TypeString(named, qualifier),
+					Missing: missing,
+				})
+			}
+			return true
+		})
+	}
+	return diagnostics
+}
+
+func containsType(list []types.Type, t types.Type) bool {
+	for _, u := range list {
+		if types.Identical(u, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckDir parses and type-checks the package in dir, with its in-package tests, and
+// returns Check's diagnostics. Imports, generated packages included, are type-checked
+// from source, so dir must build: type errors are returned as an error.
+func CheckDir(dir string) ([]Diagnostic, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	buildPkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range append(buildPkg.GoFiles, buildPkg.TestGoFiles...) {
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	config := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := config.Check(buildPkg.ImportPath, fset, files, info)
+	if err != nil {
+		return nil, fmt.Errorf("type-checking %s: %w", dir, err)
+	}
+	return Check(fset, files, pkg, info), nil
+}
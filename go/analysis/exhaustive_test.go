@@ -0,0 +1,111 @@
+// ABOUTME: Tests for the exhaustiveness checks of type switches on unions
+// ABOUTME: Uses a small hand-written union standing in for a generated one, in a package of its own
+package analysis
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// dnsSource is shaped like a generated union: an interface sealed by isRdata
+const dnsSource = `package dns
+
+type Rdata interface {
+	String() string
+	isRdata()
+}
+
+type RdataA struct{ Addr [4]byte }
+type RdataMX struct{ Host string }
+type RdataTXT struct{ Text string }
+
+func (*RdataA) String() string   { return "A" }
+func (*RdataMX) String() string  { return "MX" }
+func (*RdataTXT) String() string { return "TXT" }
+
+func (*RdataA) isRdata()   {}
+func (*RdataMX) isRdata()  {}
+func (*RdataTXT) isRdata() {}
+
+// Sealed but not a union: the marker isn't named after it
+type Shape interface{ sealed() }
+
+type Square struct{}
+
+func (*Square) sealed() {}
+`
+
+const useSource = `package use
+
+import (
+	"fmt"
+
+	"example.com/dns"
+)
+
+func Describe(r dns.Rdata) string {
+	switch r.(type) {
+	case *dns.RdataA, *dns.RdataMX, *dns.RdataTXT:
+		return r.String()
+	}
+	switch v := r.(type) {
+	case *dns.RdataA:
+		return fmt.Sprint(v.Addr)
+	default:
+		return "other"
+	}
+}
+
+func Shape(s dns.Shape, v interface{}) {
+	switch s.(type) {
+	case *dns.Square:
+	}
+	switch v.(type) {
+	case int:
+	}
+}
+`
+
+type mapImporter map[string]*types.Package
+
+func (m mapImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := m[path]; ok {
+		return pkg, nil
+	}
+	return importer.Default().Import(path)
+}
+
+func checkSource(t *testing.T, fset *token.FileSet, path, src string, imports mapImporter) ([]*ast.File, *types.Package, *types.Info) {
+	t.Helper()
+	file, err := parser.ParseFile(fset, path+".go", src, 0)
+	require.NoError(t, err)
+	info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+	config := types.Config{Importer: imports}
+	pkg, err := config.Check(path, fset, []*ast.File{file}, info)
+	require.NoError(t, err)
+	return []*ast.File{file}, pkg, info
+}
+
+func TestCheck(t *testing.T) {
+	fset := token.NewFileSet()
+	_, dns, _ := checkSource(t, fset, "example.com/dns", dnsSource, mapImporter{})
+
+	rdata := dns.Scope().Lookup("Rdata").Type().(*types.Named)
+	var names []string
+	for _, variant := range Variants(rdata) {
+		names = append(names, variant.String())
+	}
+	require.Equal(t, []string{"*example.com/dns.RdataA", "*example.com/dns.RdataMX", "*example.com/dns.RdataTXT"}, names)
+	require.Nil(t, Variants(dns.Scope().Lookup("Shape").Type().(*types.Named)))
+
+	files, use, info := checkSource(t, fset, "example.com/use", useSource, mapImporter{"example.com/dns": dns})
+	diagnostics := Check(fset, files, use, info)
+	require.Len(t, diagnostics, 1)
+	require.Equal(t, "example.com/use.go:14:2: type switch on dns.Rdata is missing *dns.RdataMX, *dns.RdataTXT", diagnostics[0].String())
+}
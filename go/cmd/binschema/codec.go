@@ -0,0 +1,184 @@
+// ABOUTME: The decode and encode commands: convert between binary messages and JSON values
+// ABOUTME: using binschema.Dynamic, so no code has to be generated first
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/serialexp/binschema"
+	"github.com/serialexp/binschema/runtime"
+)
+
+func runDecode(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlags("decode", "[message.bin]", stderr)
+	schemaPath := fs.String("schema", "", "schema file (required)")
+	typeName := fs.String("type", "", "type to decode (default: protocol header or the only type)")
+	hexInput := fs.Bool("hex", false, "input is hex text instead of binary")
+	out := fs.String("o", "", "output file (default: stdout)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *schemaPath == "" || fs.NArg() > 1 {
+		return usageError("expected -schema and at most one input file")
+	}
+
+	dyn, root, err := compileSchema(*schemaPath, *typeName)
+	if err != nil {
+		return err
+	}
+	data, err := openInput(fs.Arg(0), stdin)
+	if err != nil {
+		return err
+	}
+	if *hexInput {
+		data, err = parseHex(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	value, err := dyn.Decode(root, data)
+	if err != nil {
+		return fmt.Errorf("decode %s: %w", root, err)
+	}
+	encoded, err := json.MarshalIndent(jsonValue(value), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, append(encoded, '\n'), stdout)
+}
+
+func runEncode(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlags("encode", "[value.json]", stderr)
+	schemaPath := fs.String("schema", "", "schema file (required)")
+	typeName := fs.String("type", "", "type to encode (default: protocol header or the only type)")
+	hexOutput := fs.Bool("hex", false, "write hex text instead of binary")
+	out := fs.String("o", "", "output file (default: stdout)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *schemaPath == "" || fs.NArg() > 1 {
+		return usageError("expected -schema and at most one input file")
+	}
+
+	dyn, root, err := compileSchema(*schemaPath, *typeName)
+	if err != nil {
+		return err
+	}
+	input, err := openInput(fs.Arg(0), stdin)
+	if err != nil {
+		return err
+	}
+
+	// Numbers are kept exact so 64-bit values survive
+	decoder := json.NewDecoder(bytes.NewReader(input))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON value: %w", err)
+	}
+	value, err = numbersToGo(value)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := dyn.Encode(root, value)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", root, err)
+	}
+	if *hexOutput {
+		encoded = []byte(hex.EncodeToString(encoded) + "\n")
+	}
+	return writeOutput(*out, encoded, stdout)
+}
+
+// compileSchema loads a schema for the dynamic encoder and picks the root type
+func compileSchema(path, typeName string) (*binschema.Dynamic, string, error) {
+	schema, err := loadSchema(path)
+	if err != nil {
+		return nil, "", err
+	}
+	root, err := rootType(schema, typeName)
+	if err != nil {
+		return nil, "", err
+	}
+	dyn, err := binschema.CompileSchemaMap(schema)
+	if err != nil {
+		return nil, "", err
+	}
+	return dyn, root, nil
+}
+
+// parseHex decodes hex text, ignoring whitespace, colons and a 0x prefix
+func parseHex(text []byte) ([]byte, error) {
+	clean := strings.NewReplacer(" ", "", "\n", "", "\r", "", "\t", "", ":", "").Replace(string(text))
+	clean = strings.TrimPrefix(strings.TrimPrefix(clean, "0x"), "0X")
+	data, err := hex.DecodeString(clean)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex input: %w", err)
+	}
+	return data, nil
+}
+
+// jsonValue converts a decoded dynamic value for encoding/json: byte arrays become
+// arrays of numbers, like the JSON test vectors, instead of base64 strings
+func jsonValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case []byte:
+		return runtime.JSONBytes(x)
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, item := range x {
+			out[i] = jsonValue(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(x))
+		for key, item := range x {
+			out[key] = jsonValue(item)
+		}
+		return out
+	}
+	return v
+}
+
+// numbersToGo replaces json.Number with int64, uint64 or float64, whichever holds it exactly
+func numbersToGo(v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(x), 10, 64); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(string(x), 10, 64); err == nil {
+			return u, nil
+		}
+		f, err := strconv.ParseFloat(string(x), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s: %w", x, err)
+		}
+		return f, nil
+	case []interface{}:
+		for i, item := range x {
+			converted, err := numbersToGo(item)
+			if err != nil {
+				return nil, err
+			}
+			x[i] = converted
+		}
+	case map[string]interface{}:
+		for key, item := range x {
+			converted, err := numbersToGo(item)
+			if err != nil {
+				return nil, err
+			}
+			x[key] = converted
+		}
+	}
+	return v, nil
+}
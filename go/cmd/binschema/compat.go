@@ -0,0 +1,40 @@
+// ABOUTME: The compat command: compares two versions of a schema with codegen.CheckCompatibility,
+// ABOUTME: printing one change per line and failing on breaking ones
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/serialexp/binschema/codegen"
+)
+
+func runCompat(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlags("compat", "old.schema.json new.schema.json", stderr)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return usageError("expected an old and a new schema file")
+	}
+	oldSchema, err := loadSchema(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	newSchema, err := loadSchema(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	breaking := 0
+	for _, change := range codegen.CheckCompatibility(oldSchema, newSchema) {
+		fmt.Fprintln(stdout, change)
+		if change.Breaking {
+			breaking++
+		}
+	}
+	if breaking > 0 {
+		return fmt.Errorf("%d breaking changes", breaking)
+	}
+	return nil
+}
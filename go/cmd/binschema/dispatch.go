@@ -0,0 +1,63 @@
+// ABOUTME: The dispatch command: several versions of a schema -> DecodeAnyX, which decodes a
+// ABOUTME: message with the version its magic and version fields name (codegen.GenerateVersionDispatch)
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/serialexp/binschema/codegen"
+)
+
+func runDispatch(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlags("dispatch", "v1.schema.json v2.schema.json...", stderr)
+	typeName := fs.String("type", "", "message type every version has (default: protocol header or the only type)")
+	out := fs.String("o", "", "output file (default: stdout)")
+	prefixes := fs.String("type-prefixes", "", `comma-separated -type-prefix each version was generated with (default "V<config version>")`)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageError("expected at least one schema file")
+	}
+	var prefixList []string
+	if *prefixes != "" {
+		prefixList = strings.Split(*prefixes, ",")
+		if len(prefixList) != fs.NArg() {
+			return usageError(fmt.Sprintf("-type-prefixes has %d prefixes for %d schemas", len(prefixList), fs.NArg()))
+		}
+	}
+
+	var versions []codegen.SchemaVersion
+	root := *typeName
+	for i, path := range fs.Args() {
+		schema, err := loadSchema(path)
+		if err != nil {
+			return err
+		}
+		if root, err = rootType(schema, root); err != nil {
+			return err
+		}
+		prefix := ""
+		if prefixList != nil {
+			prefix = prefixList[i]
+		} else if version, ok := configVersion(schema); ok {
+			prefix = fmt.Sprintf("V%d", version)
+		}
+		versions = append(versions, codegen.SchemaVersion{Schema: schema, Options: codegen.GenerateOptions{TypePrefix: prefix, ExtractFields: true}})
+	}
+	code, err := codegen.GenerateVersionDispatch(versions, root)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, []byte(code), stdout)
+}
+
+// configVersion returns the value of a schema's config version, if it has one
+func configVersion(schema map[string]interface{}) (uint64, bool) {
+	config, _ := schema["config"].(map[string]interface{})
+	version, _ := config["version"].(map[string]interface{})
+	value, ok := version["value"].(float64)
+	return uint64(value), ok && value >= 0
+}
@@ -0,0 +1,37 @@
+// ABOUTME: The exhaustive command: checks the type switches of Go packages on generated unions
+// ABOUTME: with analysis.CheckDir, printing one switch missing variants per line
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/serialexp/binschema/analysis"
+)
+
+func runExhaustive(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlags("exhaustive", "[package-dir...]", stderr)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	dirs := fs.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	incomplete := 0
+	for _, dir := range dirs {
+		diagnostics, err := analysis.CheckDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, d := range diagnostics {
+			fmt.Fprintln(stdout, d)
+		}
+		incomplete += len(diagnostics)
+	}
+	if incomplete > 0 {
+		return fmt.Errorf("%d type switches miss union variants", incomplete)
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+// ABOUTME: binschema command line tool: generate Go code, validate and compare schemas, decode
+// ABOUTME: and encode messages and run JSON test vectors, without going through the TypeScript CLI
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aeolun/json5"
+	"github.com/serialexp/binschema/codegen"
+)
+
+const usage = `usage: binschema <command> [flags] [args]
+
+commands:
+  generate    schema -> Go code
+  validate    lint schemas
+  decode      schema + binary message -> JSON
+  encode      schema + JSON value -> binary message
+  test        run JSON test vectors
+  compat      old schema + new schema -> breaking changes
+  docs        schema -> Markdown or HTML protocol documentation
+  diagram     schema -> SVG packet layout diagram of a type
+  exhaustive  Go packages -> type switches on generated unions missing variants
+  dispatch    schema versions -> DecodeAnyX, decoding with the version a message names
+
+Run "binschema <command> -h" for the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes one command and returns the process exit code:
+// 0 on success, 1 if the command failed, 2 for usage errors
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	commands := map[string]func([]string, io.Reader, io.Writer, io.Writer) error{
+		"generate":   runGenerate,
+		"validate":   runValidate,
+		"decode":     runDecode,
+		"encode":     runEncode,
+		"test":       runTest,
+		"compat":     runCompat,
+		"docs":       runDocs,
+		"diagram":    runDiagram,
+		"exhaustive": runExhaustive,
+		"dispatch":   runDispatch,
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			fmt.Fprint(stdout, usage)
+			return 0
+		}
+		fmt.Fprintf(stderr, "binschema: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	if err := command(args[1:], stdin, stdout, stderr); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		fmt.Fprintf(stderr, "binschema %s: %v\n", args[0], err)
+		if _, isUsage := err.(usageError); isUsage {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// usageError reports bad command line arguments
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// newFlags creates the flag set of a command; parse errors are returned, not fatal
+func newFlags(name, args string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: binschema %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses a command's flags, turning parse failures into usage errors
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return usageError(err.Error())
+	}
+	return nil
+}
+
+// loadSchema reads a JSON or JSON5 schema file
+func loadSchema(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var schema map[string]interface{}
+	if err := json5.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("%s: invalid schema: %w", path, err)
+	}
+	return schema, nil
+}
+
+// rootType returns the explicitly requested type, else the protocol header type,
+// else the schema's only non-generic type
+func rootType(schema map[string]interface{}, explicit string) (string, error) {
+	types, _ := schema["types"].(map[string]interface{})
+	if explicit != "" {
+		if _, ok := types[explicit]; !ok && !strings.Contains(explicit, "<") {
+			return "", fmt.Errorf("type %s not found in schema", explicit)
+		}
+		return explicit, nil
+	}
+	if protocol, ok := schema["protocol"].(map[string]interface{}); ok {
+		if header, _ := protocol["header"].(string); header != "" {
+			return header, nil
+		}
+	}
+	var names []string
+	for name := range types {
+		if !strings.Contains(name, "<") {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 1 {
+		return names[0], nil
+	}
+	sort.Strings(names)
+	return "", usageError(fmt.Sprintf("schema has several types, choose one with -type: %s", strings.Join(names, ", ")))
+}
+
+// openInput opens a named file, or stdin for "" and "-"
+func openInput(path string, stdin io.Reader) ([]byte, error) {
+	if path == "" || path == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// writeOutput writes to a named file, or stdout for "" and "-"
+func writeOutput(path string, data []byte, stdout io.Writer) error {
+	if path == "" || path == "-" {
+		_, err := stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func runGenerate(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlags("generate", "schema.json", stderr)
+	typeName := fs.String("type", "", "root type for DumpAnnotated (default: protocol header or the only type)")
+	out := fs.String("o", "", "output file (default: stdout)")
+	nestedPointers := fs.Bool("nested-pointers", false, "generate nested struct fields as pointers")
+	pointerFields := fs.String("pointer-fields", "", "comma-separated Type.field list to generate as pointers")
+	emptySlices := fs.String("empty-slices", "", `decoded empty arrays: "nil" or "non-nil" (default: as decoded)`)
+	strict := fs.Bool("strict", false, "fail on unknown schema attributes instead of warning")
+	zeroCopy := fs.Bool("zero-copy", false, "decode strings as runtime.ByteString views of the input")
+	binaryCodecs := fs.Bool("binary-codecs", false, "generate MarshalBinary/UnmarshalBinary, for runtime.Codec and gRPC")
+	sqlMethods := fs.Bool("sql", false, "generate database/sql Value/Scan and MarshalText/UnmarshalText")
+	builders := fs.Bool("builders", false, "generate NewX constructors and XBuilder types checking required fields")
+	selfDescribing := fs.Bool("self-describing", false, "generate MarshalCBOR/MarshalMessagePack")
+	decodeInto := fs.Bool("decode-into", false, "generate DecodeXInto, decoding into a value and reusing its slices")
+	decodeWithArena := fs.Bool("decode-with-arena", false, "generate DecodeXWithArena, allocating from a runtime.DecodeArena")
+	decodeStream := fs.Bool("decode-stream", false, "generate DecodeXStream, decoding messages fed in chunks")
+	decodeContext := fs.Bool("decode-context", false, "generate DecodeXContext, stopping when a context is canceled")
+	extractFields := fs.Bool("extract-fields", false, "generate ExtractXField, decoding one field by its path")
+	canonical := fs.Bool("canonical", false, "generate encoders giving every value one encoding, for signing encoded bytes")
+	replaceNonASCII := fs.Bool("replace-non-ascii", false, "generate encoders writing '?' for characters of ascii strings that aren't ASCII, instead of failing")
+	compressNames := fs.Bool("compress-names", false, "generate encoders writing DNS compression pointers for names given as plain labels")
+	resolveNames := fs.Bool("resolve-names", false, "generate decoders following DNS compression pointers, so names hold only labels")
+	typePrefix := fs.String("type-prefix", "", "prefix for the Go name of every type, starting with an upper-case letter")
+	typeSuffix := fs.String("type-suffix", "", "suffix for the Go name of every type")
+	pascalCase := fs.Bool("pascal-case", false, "name struct fields by joining the words of snake_case names (data_offset is DataOffset)")
+	acronyms := fs.String("acronyms", "", "comma-separated words -pascal-case writes in capitals, id,url by default (user_id is UserID)")
+	exportDecoders := fs.Bool("export-decoders", false, "generate DecodeXWithDecoder, decoding from a runtime.BitStreamDecoder")
+	receiver := fs.String("receiver", "", `receiver name of generated methods (default "m")`)
+	only := fs.String("only", "", `generate only "encode" or "decode" methods (default: both)`)
+	castTypes := fs.String("cast-types", "", "comma-separated fixed-size little-endian types to generate unsafe casts for")
+	tests := fs.Bool("tests", false, "also write round-trip tests of every type next to the -o file, as name_test.go")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("expected one schema file")
+	}
+	if *tests && (*out == "" || *out == "-" || !strings.HasSuffix(*out, ".go")) {
+		return usageError("-tests needs -o with a .go file to write the tests next to")
+	}
+
+	schema, err := loadSchema(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	root, err := rootType(schema, *typeName)
+	if err != nil {
+		return err
+	}
+
+	opts := codegen.GenerateOptions{
+		NestedPointers:    *nestedPointers,
+		ZeroCopy:          *zeroCopy,
+		BinaryCodecs:      *binaryCodecs,
+		SQL:               *sqlMethods,
+		Builders:          *builders,
+		SelfDescribing:    *selfDescribing,
+		DecodeInto:        *decodeInto,
+		DecodeWithArena:   *decodeWithArena,
+		DecodeStream:      *decodeStream,
+		DecodeContext:     *decodeContext,
+		ExtractFields:     *extractFields,
+		Canonical:         *canonical,
+		ReplaceNonASCII:   *replaceNonASCII,
+		CompressNames:     *compressNames,
+		ResolveNames:      *resolveNames,
+		TypePrefix:        *typePrefix,
+		TypeSuffix:        *typeSuffix,
+		PascalCase:        *pascalCase,
+		ExportDecoders:    *exportDecoders,
+		Receiver:          *receiver,
+		UnknownAttributes: codegen.AttributesWarn,
+		Warn:              func(msg string) { fmt.Fprintf(stderr, "warning: %s\n", msg) },
+	}
+	if *strict {
+		opts.UnknownAttributes = codegen.AttributesStrict
+	}
+	if *pointerFields != "" {
+		opts.PointerFields = strings.Split(*pointerFields, ",")
+	}
+	if *castTypes != "" {
+		opts.CastTypes = strings.Split(*castTypes, ",")
+	}
+	if *acronyms != "" {
+		opts.Acronyms = strings.Split(*acronyms, ",")
+	}
+	switch *emptySlices {
+	case "":
+	case "nil":
+		opts.EmptySlices = codegen.EmptySlicesNil
+	case "non-nil":
+		opts.EmptySlices = codegen.EmptySlicesNonNil
+	default:
+		return usageError(fmt.Sprintf("-empty-slices must be nil or non-nil, got %q", *emptySlices))
+	}
+	switch *only {
+	case "":
+	case "encode":
+		opts.Direction = codegen.EncodeOnly
+	case "decode":
+		opts.Direction = codegen.DecodeOnly
+	default:
+		return usageError(fmt.Sprintf("-only must be encode or decode, got %q", *only))
+	}
+
+	code, err := codegen.GenerateGoWithOptions(schema, root, opts)
+	if err != nil {
+		return err
+	}
+	if *tests {
+		stubs, err := codegen.GenerateGoTests(schema, root, opts)
+		if err != nil {
+			return err
+		}
+		if err := writeOutput(strings.TrimSuffix(*out, ".go")+"_test.go", []byte(stubs), stdout); err != nil {
+			return err
+		}
+	}
+	return writeOutput(*out, []byte(code), stdout)
+}
+
+func runDocs(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlags("docs", "schema.json", stderr)
+	format := fs.String("format", "markdown", "output format: markdown or html")
+	title := fs.String("title", "", "document title (default: the schema's meta title)")
+	out := fs.String("o", "", "output file (default: stdout)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("expected one schema file")
+	}
+	if *format != "markdown" && *format != "html" {
+		return usageError(fmt.Sprintf("-format must be markdown or html, got %q", *format))
+	}
+
+	schema, err := loadSchema(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	docs, err := codegen.GenerateDocs(schema, codegen.DocsOptions{Format: *format, Title: *title})
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, []byte(docs), stdout)
+}
+
+func runDiagram(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlags("diagram", "schema.json", stderr)
+	typeName := fs.String("type", "", "struct to draw (default: protocol header or the only type)")
+	out := fs.String("o", "", "output file (default: stdout)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("expected one schema file")
+	}
+
+	schema, err := loadSchema(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	root, err := rootType(schema, *typeName)
+	if err != nil {
+		return err
+	}
+	svg, err := codegen.GenerateDiagram(schema, root)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*out, []byte(svg), stdout)
+}
@@ -0,0 +1,332 @@
+// ABOUTME: Tests for the binschema command line tool
+// ABOUTME: Runs each command in-process and checks output and exit codes
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const cliSchema = `{
+	config: { endianness: "big_endian" },
+	types: {
+		"Packet": { sequence: [
+			{ name: "id", type: "uint16" },
+			{ name: "big", type: "uint64" },
+			{ name: "name", type: "string", kind: "length_prefixed", length_type: "uint8", encoding: "utf8" },
+			{ name: "raw", type: "array", kind: "fixed", length: 2, items: { type: "uint8" } },
+		] },
+	},
+}`
+
+// packetBytes encodes {id: 258, big: 2^64-1, name: "hi", raw: [7, 8]}
+var packetBytes = []byte{1, 2, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 2, 'h', 'i', 7, 8}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func runCLI(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestUsage(t *testing.T) {
+	code, _, stderr := runCLI("")
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "usage: binschema")
+
+	code, _, stderr = runCLI("", "frobnicate")
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, `unknown command "frobnicate"`)
+
+	code, stdout, _ := runCLI("", "help")
+	require.Equal(t, 0, code)
+	require.Contains(t, stdout, "commands:")
+
+	code, _, stderr = runCLI("", "decode", "-bogus")
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "flag provided but not defined")
+
+	code, _, stderr = runCLI("", "generate", "-h")
+	require.Equal(t, 0, code)
+	require.Contains(t, stderr, "-nested-pointers")
+}
+
+func TestGenerate(t *testing.T) {
+	schema := writeFile(t, "packet.schema.json", cliSchema)
+	code, stdout, stderr := runCLI("", "generate", schema)
+	require.Equal(t, 0, code, stderr)
+	require.Contains(t, stdout, "type Packet struct")
+	require.Contains(t, stdout, "func DecodePacket(")
+
+	out := filepath.Join(t.TempDir(), "packet.go")
+	code, stdout, stderr = runCLI("", "generate", "-o", out, schema)
+	require.Equal(t, 0, code, stderr)
+	require.Empty(t, stdout)
+	written, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.Contains(t, string(written), "type Packet struct")
+
+	code, _, stderr = runCLI("", "generate", "-empty-slices", "sometimes", schema)
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "-empty-slices must be nil or non-nil")
+
+	code, stdout, stderr = runCLI("", "generate", "-only", "encode", schema)
+	require.Equal(t, 0, code, stderr)
+	require.Contains(t, stdout, "func (m *Packet) Encode() ([]byte, error)")
+	require.NotContains(t, stdout, "func DecodePacket(")
+
+	code, _, stderr = runCLI("", "generate", "-only", "both", schema)
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "-only must be encode or decode")
+
+	code, stdout, stderr = runCLI("", "generate", "-tests", "-o", out, schema)
+	require.Equal(t, 0, code, stderr)
+	require.Empty(t, stdout)
+	written, err = os.ReadFile(filepath.Join(filepath.Dir(out), "packet_test.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(written), "func TestPacketRoundTrip(t *testing.T)")
+
+	code, _, stderr = runCLI("", "generate", "-tests", schema)
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "-tests needs -o")
+
+	code, _, stderr = runCLI("", "generate", "-tests", "-only", "decode", "-o", out, schema)
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "test stubs need both encoders and decoders")
+}
+
+func TestGenerateUnknownAttribute(t *testing.T) {
+	schema := writeFile(t, "typo.schema.json", `{ types: { "Packet": { sequence: [ { name: "id", type: "uint8", endianess: "little_endian" } ] } } }`)
+
+	code, _, stderr := runCLI("", "generate", schema)
+	require.Equal(t, 0, code, stderr)
+	require.Contains(t, stderr, "warning: ")
+	require.Contains(t, stderr, "endianess")
+
+	code, _, stderr = runCLI("", "generate", "-strict", schema)
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "endianess")
+}
+
+func TestGenerateRootType(t *testing.T) {
+	schema := writeFile(t, "two.schema.json", `{ types: {
+		"A": { sequence: [ { name: "x", type: "uint8" } ] },
+		"B": { sequence: [ { name: "a", type: "A" } ] },
+	} }`)
+
+	code, _, stderr := runCLI("", "generate", schema)
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "choose one with -type: A, B")
+
+	code, stdout, stderr := runCLI("", "generate", "-type", "B", schema)
+	require.Equal(t, 0, code, stderr)
+	require.Contains(t, stdout, "func DumpAnnotated(")
+}
+
+func TestValidate(t *testing.T) {
+	good := writeFile(t, "good.schema.json", cliSchema)
+	code, stdout, stderr := runCLI("", "validate", good)
+	require.Equal(t, 0, code, stderr)
+	require.Empty(t, stdout)
+
+	bad := writeFile(t, "bad.schema.json", `{ types: {
+		"Frame<T>": { sequence: [ { name: "body", type: "T" } ] },
+		"Packet": { sequence: [
+			{ name: "id", type: "uint8" },
+			{ name: "next", type: "Pakcet" },
+			{ name: "frame", type: "Frame<uint32>" },
+			{ name: "other", type: "Frame<Missing>" },
+		] },
+	} }`)
+	code, stdout, stderr = runCLI("", "validate", good, bad)
+	require.Equal(t, 1, code)
+	require.Contains(t, stdout, bad+`: error: types.Packet.sequence[1]: unknown type "Pakcet"`)
+	require.Contains(t, stdout, bad+`: error: types.Packet.sequence[3]: unknown type "Frame<Missing>"`)
+	require.NotContains(t, stdout, "Frame<uint32>")
+	require.NotContains(t, stdout, `"T"`)
+	require.NotContains(t, stdout, good)
+	require.Contains(t, stderr, "1 of 2 schemas have problems")
+
+	// Warnings are reported but only fail with -strict
+	typo := writeFile(t, "typo.schema.json", `{ types: { "Packet": { sequence: [ { name: "id", type: "uint8", endianess: "little_endian" } ] } } }`)
+	code, stdout, stderr = runCLI("", "validate", typo)
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t, typo+`: warning: types.Packet.sequence[0]: unknown attribute "endianess"`+"\n", stdout)
+
+	code, _, stderr = runCLI("", "validate", "-strict", typo)
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "1 of 1 schemas have problems")
+}
+
+func TestCompat(t *testing.T) {
+	old := writeFile(t, "old.schema.json", `{ types: { "Reading": { sequence: [ { name: "id", type: "uint8" } ] } } }`)
+	appended := writeFile(t, "appended.schema.json", `{ types: { "Reading": { sequence: [
+		{ name: "id", type: "uint8" },
+		{ name: "extra", type: "uint16", optional: true },
+	] } } }`)
+	code, stdout, stderr := runCLI("", "compat", old, appended)
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t, `compatible: types.Reading.sequence[1]: optional field "extra" was appended`+"\n", stdout)
+
+	changed := writeFile(t, "changed.schema.json", `{ types: { "Reading": { sequence: [ { name: "id", type: "uint16" } ] } } }`)
+	code, stdout, stderr = runCLI("", "compat", old, changed)
+	require.Equal(t, 1, code)
+	require.Equal(t, `breaking: types.Reading.sequence[0]: type changed from "uint8" to "uint16"`+"\n", stdout)
+	require.Contains(t, stderr, "1 breaking changes")
+
+	code, _, _ = runCLI("", "compat", old)
+	require.Equal(t, 2, code)
+}
+
+func TestDocs(t *testing.T) {
+	schema := writeFile(t, "reading.schema.json", `{ types: { "Reading": { sequence: [ { name: "id", type: "uint16" } ] } } }`)
+	code, stdout, stderr := runCLI("", "docs", "-title", "Readings", schema)
+	require.Equal(t, 0, code, stderr)
+	require.Contains(t, stdout, "# Readings\n")
+	require.Contains(t, stdout, "| 0 | 16 | `id` | uint16 |  |\n")
+
+	code, stdout, stderr = runCLI("", "docs", "-format", "html", schema)
+	require.Equal(t, 0, code, stderr)
+	require.Contains(t, stdout, "<h1>Protocol</h1>")
+
+	code, _, _ = runCLI("", "docs", "-format", "pdf", schema)
+	require.Equal(t, 2, code)
+
+	code, stdout, stderr = runCLI("", "diagram", schema)
+	require.Equal(t, 0, code, stderr)
+	require.Contains(t, stdout, "<title>id</title>id</text>")
+}
+
+func TestDecodeEncode(t *testing.T) {
+	schema := writeFile(t, "packet.schema.json", cliSchema)
+
+	code, stdout, stderr := runCLI(string(packetBytes), "decode", "-schema", schema)
+	require.Equal(t, 0, code, stderr)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(stdout), &decoded))
+	require.Equal(t, "hi", decoded["name"])
+	require.Contains(t, stdout, `"big": 18446744073709551615`)
+	require.Contains(t, stdout, `"raw": [`)
+
+	code, encoded, stderr := runCLI(stdout, "encode", "-schema", schema)
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t, packetBytes, []byte(encoded))
+
+	code, hexOut, stderr := runCLI(stdout, "encode", "-schema", schema, "-hex")
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t, "0102ffffffffffffffff0268690708\n", hexOut)
+
+	code, again, stderr := runCLI("01 02 ff:ff ff ff ff ff ff ff 02 68 69 07 08\n", "decode", "-schema", schema, "-hex")
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t, stdout, again)
+
+	code, _, stderr = runCLI(string(packetBytes[:4]), "decode", "-schema", schema)
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "decode Packet")
+
+	code, _, stderr = runCLI(string(packetBytes), "decode")
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "expected -schema")
+}
+
+func TestVectors(t *testing.T) {
+	suite := `{
+		name: "cli_packet",
+		description: "packet vectors",
+		schema: ` + cliSchema + `,
+		test_type: "Packet",
+		test_cases: [
+			{ description: "basic", value: { id: 1, big: 2, name: "a", raw: [3, 4] }, bytes: [0, 1, 0, 0, 0, 0, 0, 0, 0, 2, 1, 97, 3, 4] },
+		],
+	}`
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cli_packet.test.json"), []byte(suite), 0o644))
+
+	code, stdout, stderr := runCLI("", "test", "-v", dir)
+	require.Equal(t, 0, code, stderr)
+	require.Contains(t, stdout, "ok   cli_packet (1 cases)")
+	require.Contains(t, stdout, "1 passed, 0 failed")
+
+	// The same vectors against a schema whose id is one byte wide no longer match
+	narrow := writeFile(t, "narrow.schema.json", strings.Replace(cliSchema, `type: "uint16"`, `type: "uint8"`, 1))
+	code, stdout, stderr = runCLI("", "test", "-schema", narrow, filepath.Join(dir, "cli_packet.test.json"))
+	require.Equal(t, 1, code)
+	require.Contains(t, stdout, "FAIL cli_packet: basic: ")
+	require.Contains(t, stderr, "1 test cases failed")
+}
+
+func TestExhaustive(t *testing.T) {
+	schema := writeFile(t, "message.schema.json", `{ types: {
+		"Ping": { sequence: [ { name: "kind", type: "uint8" } ] },
+		"Text": { sequence: [ { name: "kind", type: "uint8" }, { name: "size", type: "uint8" } ] },
+		"Message": { type: "discriminated_union", discriminator: { peek: "uint8" }, variants: [
+			{ when: "value == 1", type: "Ping" },
+			{ when: "value == 2", type: "Text" },
+		] },
+	} }`)
+	// A module using the generated code, with the runtime of this checkout
+	dir := t.TempDir()
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	require.NoError(t, err)
+	goMod := "module msgs\n\ngo 1.21\n\nrequire github.com/serialexp/binschema v0.0.0\n\nreplace github.com/serialexp/binschema => " + root + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0o644))
+	goSum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.sum"), goSum, 0o644))
+	t.Setenv("GOFLAGS", "-mod=mod")
+	code, _, stderr := runCLI("", "generate", "-type", "Message", "-o", filepath.Join(dir, "message.go"), schema)
+	require.Equal(t, 0, code, stderr)
+	use := "package main\n\nfunc describe(m Message) string {\n\tswitch m.(type) {\n\tcase *Ping:\n\t\treturn \"ping\"\n\t}\n\treturn \"\"\n}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "use.go"), []byte(use), 0o644))
+
+	code, stdout, stderr := runCLI("", "exhaustive", dir)
+	require.Equal(t, 1, code)
+	require.Equal(t, filepath.Join(dir, "use.go")+":4:2: type switch on Message is missing *Text\n", stdout)
+	require.Contains(t, stderr, "1 type switches miss union variants")
+
+	handled := strings.Replace(use, "case *Ping:", "case *Ping, *Text:", 1)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "use.go"), []byte(handled), 0o644))
+	code, stdout, stderr = runCLI("", "exhaustive", dir)
+	require.Equal(t, 0, code, stderr)
+	require.Empty(t, stdout)
+}
+
+func TestDispatch(t *testing.T) {
+	version := func(n int) string {
+		return fmt.Sprintf(`{ config: { version: { field: "version", value: %d } }, types: {
+			"Reading": { sequence: [ { name: "version", type: "uint8" }, { name: "value", type: "uint%d" } ] },
+		} }`, n, 8*n)
+	}
+	v1 := writeFile(t, "v1.schema.json", version(1))
+	v2 := writeFile(t, "v2.schema.json", version(2))
+
+	code, stdout, stderr := runCLI("", "dispatch", v1, v2)
+	require.Equal(t, 0, code, stderr)
+	require.Contains(t, stdout, "func DecodeAnyReading(bytes []byte) (ReadingMessage, uint64, error) {")
+	require.Contains(t, stdout, `is(ExtractV2ReadingField, "version", uint8(2))`)
+
+	code, stdout, stderr = runCLI("", "dispatch", "-type-prefixes", "Old,New", v1, v2)
+	require.Equal(t, 0, code, stderr)
+	require.Contains(t, stdout, "m, err := DecodeNewReading(bytes)")
+
+	code, _, stderr = runCLI("", "dispatch", "-type-prefixes", "Old", v1, v2)
+	require.Equal(t, 2, code)
+	require.Contains(t, stderr, "-type-prefixes has 1 prefixes for 2 schemas")
+
+	code, _, stderr = runCLI("", "dispatch", v1, v1)
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "versions 1 and 2 of Reading have the same magic and version")
+}
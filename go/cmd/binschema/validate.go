@@ -0,0 +1,54 @@
+// ABOUTME: The validate command: lints schemas with codegen.ValidateSchema and the dynamic
+// ABOUTME: API's compiler, printing one diagnostic per line
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/serialexp/binschema"
+	"github.com/serialexp/binschema/codegen"
+)
+
+func runValidate(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlags("validate", "schema.json...", stderr)
+	strict := fs.Bool("strict", false, "fail on warnings (unknown attributes, defaulted length types) too")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageError("expected at least one schema file")
+	}
+
+	failed := 0
+	for _, path := range fs.Args() {
+		schema, err := loadSchema(path)
+		if err != nil {
+			fmt.Fprintln(stdout, err)
+			failed++
+			continue
+		}
+		bad := false
+		for _, d := range validateSchema(schema) {
+			fmt.Fprintf(stdout, "%s: %s\n", path, d)
+			bad = bad || d.Severity == codegen.SeverityError || *strict
+		}
+		if bad {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d schemas have problems", failed, fs.NArg())
+	}
+	return nil
+}
+
+// validateSchema returns the generator's diagnostics for a parsed schema, plus an
+// error if the dynamic API can't compile it (invalid expressions, unsupported types)
+func validateSchema(schema map[string]interface{}) []codegen.Diagnostic {
+	diagnostics := codegen.ValidateSchema(schema)
+	if _, err := binschema.CompileSchemaMap(schema); err != nil {
+		diagnostics = append(diagnostics, codegen.Diagnostic{Severity: codegen.SeverityError, Message: err.Error()})
+	}
+	return diagnostics
+}
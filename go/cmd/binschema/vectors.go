@@ -0,0 +1,91 @@
+// ABOUTME: The test command: runs JSON test vectors (*.test.json) through binschema.Dynamic
+// ABOUTME: and reports failing cases, optionally against a schema other than the embedded one
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/serialexp/binschema/test"
+)
+
+func runTest(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlags("test", "vectors.test.json|dir...", stderr)
+	schemaPath := fs.String("schema", "", "schema file to test instead of the schema embedded in each suite")
+	verbose := fs.Bool("v", false, "list passing suites too")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageError("expected test vector files or directories")
+	}
+
+	var suites []*test.TestSuite
+	for _, path := range fs.Args() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			found, err := test.LoadAllTestSuites(path)
+			if err != nil {
+				return err
+			}
+			suites = append(suites, found...)
+			continue
+		}
+		suite, err := test.LoadTestSuite(path)
+		if err != nil {
+			return err
+		}
+		suites = append(suites, suite)
+	}
+	if len(suites) == 0 {
+		return fmt.Errorf("no *.test.json files found")
+	}
+
+	if *schemaPath != "" {
+		schema, err := loadSchema(*schemaPath)
+		if err != nil {
+			return err
+		}
+		for _, suite := range suites {
+			suite.Schema = schema
+		}
+	}
+
+	results, err := test.InterpretBatch(suites)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	passed, failed := 0, 0
+	for _, name := range names {
+		suiteFailed := 0
+		for _, result := range results[name] {
+			if result.Pass {
+				passed++
+				continue
+			}
+			failed++
+			suiteFailed++
+			fmt.Fprintf(stdout, "FAIL %s: %s: %s\n", name, result.Description, result.Error)
+		}
+		if suiteFailed == 0 && *verbose {
+			fmt.Fprintf(stdout, "ok   %s (%d cases)\n", name, len(results[name]))
+		}
+	}
+	fmt.Fprintf(stdout, "%d passed, %d failed\n", passed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d test cases failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+// ABOUTME: Regenerates the website's Go example code from the example schemas
+// ABOUTME: and verifies that the byte examples embedded in each schema still decode to their documented values
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aeolun/json5"
+	"github.com/serialexp/binschema"
+	"github.com/serialexp/binschema/codegen"
+)
+
+func main() {
+	schemaDirs := flag.String("schemas", "../examples,../website/src/examples", "comma-separated directories of example *.schema.json files")
+	outDir := flag.String("out", "../website/src/examples", "directory the generated .go files are written to")
+	check := flag.Bool("check", false, "report out-of-date .go files instead of writing them")
+	flag.Parse()
+
+	problems, err := run(strings.Split(*schemaDirs, ","), *outDir, *check)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, problem := range problems {
+		fmt.Fprintln(os.Stderr, problem)
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+// run processes every schema in schemaDirs and returns one line per problem found
+func run(schemaDirs []string, outDir string, check bool) ([]string, error) {
+	var files []string
+	for _, dir := range schemaDirs {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.schema.json"))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no *.schema.json files in %s", dir)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+
+	var problems []string
+	for _, file := range files {
+		name := filepath.Base(file)
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var schema map[string]interface{}
+		if err := json5.Unmarshal(data, &schema); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid schema: %v", name, err))
+			continue
+		}
+
+		for _, failure := range verifyExamples(schema) {
+			problems = append(problems, fmt.Sprintf("%s: %s", name, failure))
+		}
+
+		goFile := filepath.Join(outDir, strings.TrimSuffix(name, ".schema.json")+".generated.go")
+		code, genErr := generateExample(schema, name)
+		if genErr != nil {
+			// The Go generator doesn't cover every schema feature yet; such examples get no Go file
+			fmt.Printf("%s: no Go example (%v)\n", name, genErr)
+			if _, err := os.Stat(goFile); err == nil {
+				if check {
+					problems = append(problems, fmt.Sprintf("%s: stale, schema no longer generates Go", goFile))
+				} else if err := os.Remove(goFile); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		existing, _ := os.ReadFile(goFile)
+		if bytes.Equal(existing, code) {
+			continue
+		}
+		if check {
+			problems = append(problems, fmt.Sprintf("%s: out of date, regenerate with `just regen-website-examples`", goFile))
+			continue
+		}
+		if err := os.WriteFile(goFile, code, 0o644); err != nil {
+			return nil, err
+		}
+		fmt.Printf("wrote %s\n", goFile)
+	}
+	return problems, nil
+}
+
+// generateExample generates Go code for a schema; GenerateGo output is already gofmt-formatted
+func generateExample(schema map[string]interface{}, source string) ([]byte, error) {
+	root, err := rootType(schema)
+	if err != nil {
+		return nil, err
+	}
+	code, err := codegen.GenerateGo(schema, root)
+	if err != nil {
+		return nil, err
+	}
+	header := fmt.Sprintf("// Code generated by website-examples from %s. DO NOT EDIT.\n\n", source)
+	return []byte(header + code), nil
+}
+
+// rootType picks the protocol header type, else the first non-generic type by name
+func rootType(schema map[string]interface{}) (string, error) {
+	if protocol, ok := schema["protocol"].(map[string]interface{}); ok {
+		if header, _ := protocol["header"].(string); header != "" {
+			return header, nil
+		}
+	}
+	types, _ := schema["types"].(map[string]interface{})
+	names := make([]string, 0, len(types))
+	for name := range types {
+		if !strings.Contains(name, "<") {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("schema has no types")
+	}
+	sort.Strings(names)
+	return names[0], nil
+}
+
+// verifyExamples decodes each protocol message example's bytes as its payload type
+// and compares the result with the documented decoded value
+func verifyExamples(schema map[string]interface{}) []string {
+	protocol, _ := schema["protocol"].(map[string]interface{})
+	messages, _ := protocol["messages"].([]interface{})
+	if len(messages) == 0 {
+		return nil
+	}
+	dyn, err := binschema.CompileSchemaMap(schema)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var failures []string
+	for i, raw := range messages {
+		message, _ := raw.(map[string]interface{})
+		example, ok := message["example"].(map[string]interface{})
+		if !ok || example["bytes"] == nil {
+			continue
+		}
+		label, _ := message["name"].(string)
+		if label == "" {
+			label = fmt.Sprintf("messages[%d]", i)
+		}
+		payloadType, _ := message["payload_type"].(string)
+		if payloadType == "" {
+			failures = append(failures, fmt.Sprintf("%s: example has no payload_type", label))
+			continue
+		}
+		data, err := exampleBytes(example["bytes"])
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", label, err))
+			continue
+		}
+		decoded, err := dyn.Decode(payloadType, data)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: example bytes do not decode as %s: %v", label, payloadType, err))
+			continue
+		}
+		if expected, ok := example["decoded"]; ok {
+			if err := matchExample(expected, decoded, ""); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", label, err))
+			}
+		}
+	}
+	return failures
+}
+
+func exampleBytes(raw interface{}) ([]byte, error) {
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("example bytes must be an array")
+	}
+	data := make([]byte, len(list))
+	for i, v := range list {
+		n, ok := v.(float64)
+		if !ok || n < 0 || n > 255 || n != float64(int(n)) {
+			return nil, fmt.Errorf("example byte %d is not a byte: %v", i, v)
+		}
+		data[i] = byte(n)
+	}
+	return data, nil
+}
+
+// matchExample checks a decoded value against a documented one. Documented objects
+// may leave out fields (such as computed lengths); everything they list must match.
+func matchExample(expected, actual interface{}, path string) error {
+	at := path
+	if at == "" {
+		at = "value"
+	}
+	switch want := expected.(type) {
+	case map[string]interface{}:
+		got, ok := actual.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: documented as an object, decoded %v", at, actual)
+		}
+		keys := make([]string, 0, len(want))
+		for key := range want {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			value, present := got[key]
+			if !present {
+				return fmt.Errorf("%s: documented but not decoded", child)
+			}
+			if err := matchExample(want[key], value, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		var got []interface{}
+		switch a := actual.(type) {
+		case []interface{}:
+			got = a
+		case []byte:
+			for _, b := range a {
+				got = append(got, b)
+			}
+		default:
+			return fmt.Errorf("%s: documented as an array, decoded %v", at, actual)
+		}
+		if len(got) != len(want) {
+			return fmt.Errorf("%s: documented %d items, decoded %d", at, len(want), len(got))
+		}
+		for i := range want {
+			if err := matchExample(want[i], got[i], fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case float64:
+		if !binschema.NumericEqual(want, actual) {
+			return fmt.Errorf("%s: documented %v, decoded %v", at, want, actual)
+		}
+		return nil
+	}
+	if expected != actual {
+		return fmt.Errorf("%s: documented %v, decoded %v", at, expected, actual)
+	}
+	return nil
+}
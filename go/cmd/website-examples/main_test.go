@@ -0,0 +1,94 @@
+// ABOUTME: Tests for the website example generator
+// ABOUTME: Checks byte example verification, deterministic output and check mode
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aeolun/json5"
+	"github.com/stretchr/testify/require"
+)
+
+const exampleSchema = `{
+	config: { endianness: "big_endian" },
+	types: {
+		"Header": { sequence: [
+			{ name: "kind", type: "uint8" },
+			{ name: "name", type: "string", kind: "length_prefixed", length_type: "uint8", encoding: "utf8" },
+		] },
+	},
+	protocol: {
+		header: "Header",
+		messages: [
+			{ name: "GOOD", payload_type: "Header", example: { bytes: [1, 2, 104, 105], decoded: { kind: 1, name: "hi" } } },
+			{ name: "WRONG_VALUE", payload_type: "Header", example: { bytes: [2, 0], decoded: { kind: 3 } } },
+			{ name: "SHORT", payload_type: "Header", example: { bytes: [1, 5, 104], decoded: { kind: 1 } } },
+		],
+	},
+}`
+
+func TestVerifyExamples(t *testing.T) {
+	var schema map[string]interface{}
+	require.NoError(t, json5.Unmarshal([]byte(exampleSchema), &schema))
+
+	failures := verifyExamples(schema)
+	require.Len(t, failures, 2)
+	require.Equal(t, "WRONG_VALUE: kind: documented 3, decoded 2", failures[0])
+	require.Contains(t, failures[1], "SHORT: example bytes do not decode as Header")
+}
+
+func TestMatchExample(t *testing.T) {
+	decoded := map[string]interface{}{
+		"count": uint8(2),
+		"items": []interface{}{map[string]interface{}{"v": int16(-1)}},
+		"raw":   []byte{1, 2},
+		"value": float32(1.5),
+	}
+	require.NoError(t, matchExample(map[string]interface{}{"items": []interface{}{map[string]interface{}{"v": float64(-1)}}, "raw": []interface{}{float64(1), float64(2)}, "value": 1.5}, decoded, ""))
+	require.EqualError(t, matchExample(map[string]interface{}{"missing": float64(1)}, decoded, ""), "missing: documented but not decoded")
+	require.EqualError(t, matchExample(map[string]interface{}{"items": []interface{}{}}, decoded, ""), "items: documented 0 items, decoded 1")
+}
+
+// The byte examples published on the website must decode to their documented values
+func TestPublishedExamples(t *testing.T) {
+	files, err := filepath.Glob("../../../examples/*.schema.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		var schema map[string]interface{}
+		require.NoError(t, json5.Unmarshal(data, &schema))
+		require.Empty(t, verifyExamples(schema), filepath.Base(file))
+	}
+}
+
+func TestRunCheckMode(t *testing.T) {
+	schemas := t.TempDir()
+	out := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(schemas, "demo.schema.json"), []byte(`{
+		types: { "Point": { sequence: [ { name: "x", type: "uint16" }, { name: "y", type: "uint16" } ] } },
+	}`), 0o644))
+
+	problems, err := run([]string{schemas}, out, true)
+	require.NoError(t, err)
+	require.Len(t, problems, 1)
+	require.Contains(t, problems[0], "demo.generated.go: out of date")
+
+	problems, err = run([]string{schemas}, out, false)
+	require.NoError(t, err)
+	require.Empty(t, problems)
+	first, err := os.ReadFile(filepath.Join(out, "demo.generated.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(first), "// Code generated by website-examples from demo.schema.json. DO NOT EDIT.")
+
+	// Regenerating is a no-op, so check mode passes
+	problems, err = run([]string{schemas}, out, true)
+	require.NoError(t, err)
+	require.Empty(t, problems)
+	second, err := os.ReadFile(filepath.Join(out, "demo.generated.go"))
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+}
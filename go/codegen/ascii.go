@@ -0,0 +1,35 @@
+// ABOUTME: ASCII string encoding: characters that aren't ASCII fail the encode with INVALID_VALUE
+// ABOUTME: ReplaceNonASCII option: they are written as '?' instead
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// markReplaceNonASCII sets ReplaceNonASCII on every ascii string field and string array item
+func markReplaceNonASCII(schema *Schema) {
+	var mark func(field *Field)
+	mark = func(field *Field) {
+		if field.Type == "string" && field.Encoding == "ascii" {
+			field.ReplaceNonASCII = true
+		}
+		if field.Items != nil {
+			mark(field.Items)
+		}
+	}
+	for _, typeDef := range schema.Types {
+		for _, field := range typeDef.fieldPointers() {
+			mark(field)
+		}
+	}
+}
+
+// generateASCIIBytes emits the conversion of an ascii string to the bytes it writes,
+// which fails on a character that isn't ASCII unless it is replaced
+func generateASCIIBytes(buf *bytes.Buffer, field Field, bytesVar, fieldName, indent string) {
+	buf.WriteString(fmt.Sprintf("%s%s, err := runtime.ASCIIBytes(%q, %s, %t)\n", indent, bytesVar, fieldWhat(field), fieldName, field.ReplaceNonASCII))
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
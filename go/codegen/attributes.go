@@ -0,0 +1,134 @@
+// ABOUTME: Detects schema attributes the generator doesn't recognize (typos like "lenght_type")
+// ABOUTME: Unknown attributes are ignored, reported as warnings, or rejected depending on GenerateOptions
+package codegen
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// AttributeMode selects how unknown schema attributes are handled
+type AttributeMode int
+
+const (
+	// AttributesIgnore drops unknown attributes silently
+	AttributesIgnore AttributeMode = iota
+	// AttributesWarn reports each unknown attribute with its path and continues
+	AttributesWarn
+	// AttributesStrict fails generation if any attribute is unknown
+	AttributesStrict
+)
+
+var knownSchemaAttributes = attributeSet("$schema", "meta", "config", "types", "protocol")
+
+var knownConfigAttributes = attributeSet("endianness", "bit_order", "version", "magic", "invalid_utf8")
+
+// Attributes of a field or element type (array items, type aliases)
+var knownFieldAttributes = attributeSet(
+	"name", "type", "description", "metadata", "notes", "since", "deprecated", "example",
+	"kind", "length", "length_type", "length_field", "length_encoding", "item_length_type",
+	"count_expr", "items", "fields", "encoding", "endianness", "size", "signed",
+	"optional", "lazy", "conditional", "const", "computed", "value_type", "presence_type",
+	"discriminator", "variants", "choices", "byte_budget", "repr",
+	"terminator_value", "terminator_type", "terminator_endianness", "terminal_variants",
+	"storage", "offset", "offset_mask", "offset_from", "target_type", "bit_order",
+	"align_to", "alignment", "max_bytes", "element_type", "position", "selects_endianness",
+	"unit", "epoch", "digits", "decode_as", "field_number", "proto_type", "splittable",
+	"transform", "codec", "go_name", "padding", "max_length",
+)
+
+var knownTypeAttributes = attributeSet("sequence", "instances", "description", "notes", "bit_order", "protobuf")
+
+func attributeSet(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// checkAttributes finds unknown attributes in a raw schema and handles them per mode
+func checkAttributes(data map[string]interface{}, mode AttributeMode, warn func(string)) error {
+	if mode == AttributesIgnore {
+		return nil
+	}
+
+	unknown := unknownAttributes(data)
+	if len(unknown) == 0 {
+		return nil
+	}
+	if mode == AttributesStrict {
+		return fmt.Errorf("unknown schema attributes: %s", strings.Join(unknown, ", "))
+	}
+	if warn == nil {
+		warn = func(msg string) { log.Print(msg) }
+	}
+	for _, path := range unknown {
+		warn(fmt.Sprintf("unknown schema attribute %s", path))
+	}
+	return nil
+}
+
+// unknownAttributes returns the sorted paths of attributes outside the schema vocabulary
+func unknownAttributes(data map[string]interface{}) []string {
+	var unknown []string
+	collect := func(path string, obj map[string]interface{}, known ...map[string]bool) {
+		for key := range obj {
+			found := false
+			for _, set := range known {
+				found = found || set[key]
+			}
+			if !found && path == "" {
+				unknown = append(unknown, key)
+			} else if !found {
+				unknown = append(unknown, path+"."+key)
+			}
+		}
+	}
+
+	var checkField func(path string, field map[string]interface{})
+	checkField = func(path string, field map[string]interface{}) {
+		collect(path, field, knownFieldAttributes)
+		if items, ok := field["items"].(map[string]interface{}); ok {
+			checkField(path+".items", items)
+		}
+		if fields, ok := field["fields"].([]interface{}); ok {
+			for i, raw := range fields {
+				if inner, ok := raw.(map[string]interface{}); ok {
+					checkField(fmt.Sprintf("%s.fields[%d]", path, i), inner)
+				}
+			}
+		}
+	}
+
+	collect("", data, knownSchemaAttributes)
+	if config, ok := data["config"].(map[string]interface{}); ok {
+		collect("config", config, knownConfigAttributes)
+	}
+	if types, ok := data["types"].(map[string]interface{}); ok {
+		for name, raw := range types {
+			typeData, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			path := "types." + name
+			sequence, isStruct := typeData["sequence"].([]interface{})
+			if !isStruct {
+				// Type alias: an element type definition
+				checkField(path, typeData)
+				continue
+			}
+			collect(path, typeData, knownTypeAttributes)
+			for i, rawField := range sequence {
+				if field, ok := rawField.(map[string]interface{}); ok {
+					checkField(fmt.Sprintf("%s.sequence[%d]", path, i), field)
+				}
+			}
+		}
+	}
+
+	sort.Strings(unknown)
+	return unknown
+}
@@ -0,0 +1,479 @@
+// ABOUTME: back_reference fields: pointers to a value written earlier in the message, as DNS name compression
+// ABOUTME: Decoding follows the pointer; encoding writes one when the compression dictionary has the value
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BackReference describes a back_reference field. The field's Type is the target type:
+// the field holds the value pointed at, decoded from where the pointer leads.
+type BackReference struct {
+	Storage     string // Unsigned type the pointer is stored as
+	OffsetMask  uint64 // Bits of the pointer holding the offset; encoding sets the others
+	FromCurrent bool   // The offset counts from the pointer (offset_from current_position), not from the start of the message
+	NameKey     string // The runtime.NameKey the compression dictionary is searched with; set to DNSNameKey by markNameCompression
+}
+
+// NameCompression marks a null-terminated array of union items that ends in a pointer
+// variant, such as a DNS name of labels. The labels written before a pointer are
+// recorded, so later pointers can lead to them.
+type NameCompression struct {
+	Union   *TypeDef // The union of the array's items
+	Variant string   // The union variant that is a back_reference
+	Pointer Field    // That variant's back_reference field
+	Auto    bool     // Set by GenerateOptions.CompressNames: the encoder replaces labels written before with a pointer
+	Resolve bool     // Set by GenerateOptions.ResolveNames: the decoder follows pointers, leaving only labels
+}
+
+// parseBackReference turns a back_reference field into a field of its target type
+func parseBackReference(field *Field, fieldData map[string]interface{}) {
+	ref := &BackReference{Storage: "uint16", OffsetMask: 0x3FFF, NameKey: "runtime.WireNameKey"}
+	if storage, ok := fieldData["storage"].(string); ok {
+		ref.Storage = storage
+	}
+	switch mask := fieldData["offset_mask"].(type) {
+	case string:
+		if value, err := strconv.ParseUint(mask, 0, 64); err == nil {
+			ref.OffsetMask = value
+		}
+	case float64:
+		ref.OffsetMask = uint64(mask)
+	}
+	ref.FromCurrent = fieldData["offset_from"] == "current_position"
+	field.Type, _ = fieldData["target_type"].(string)
+	field.BackReference = ref
+}
+
+// usesBackReferences reports whether any field is a back_reference
+func usesBackReferences(schema *Schema) bool {
+	for _, typeDef := range schema.Types {
+		for _, field := range typeDef.allFields() {
+			if field.BackReference != nil || (field.Items != nil && field.Items.BackReference != nil) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// markNameCompression sets Names on null-terminated arrays of unions whose terminal
+// variants include a back_reference, the shape of a DNS name. Their pointers key the
+// dictionary with DNSNameKey, so names differing only in ASCII case share a pointer
+// as DNS servers' do.
+func markNameCompression(schema *Schema, auto, resolve bool) {
+	for _, typeDef := range schema.Types {
+		for _, field := range typeDef.fieldPointers() {
+			if field.Type != "array" || field.Kind != "null_terminated" || field.Items == nil || !field.Items.Union {
+				continue
+			}
+			for _, variant := range field.TerminalVariants {
+				variantDef, ok := schema.Types[variant]
+				if !ok || len(variantDef.Sequence) != 1 || variantDef.Sequence[0].BackReference == nil {
+					continue
+				}
+				variantDef.Sequence[0].BackReference.NameKey = "runtime.DNSNameKey"
+				field.Names = &NameCompression{Union: schema.Types[field.Items.Type], Variant: variant, Pointer: variantDef.Sequence[0], Auto: auto, Resolve: resolve}
+				break
+			}
+		}
+	}
+}
+
+// compressionContext returns the context Encode starts a message with when the schema
+// has back references, so that one compression dictionary serves the whole message.
+// Names compressed by the encoder are pointed at only where they are the rest of an
+// earlier name, as RFC 1035 does, and never further in than a pointer can reach.
+func compressionContext(schema *Schema, auto bool) string {
+	if !auto {
+		return "runtime.NewEncodingContext()"
+	}
+	var mask uint64
+	for _, typeDef := range schema.Types {
+		for _, field := range typeDef.allFields() {
+			if ref := field.BackReference; ref != nil && (mask == 0 || ref.OffsetMask < mask) {
+				mask = ref.OffsetMask
+			}
+		}
+	}
+	return fmt.Sprintf("runtime.NewEncodingContext(runtime.Compression(runtime.CompressionPolicy{MaxOffset: %#x, Match: runtime.MatchSuffixes}))", mask)
+}
+
+// generateDecodeBackReference reads a pointer and decodes the value it leads to, then
+// carries on after the pointer. Following a pointer counts as nesting, so pointer loops
+// end at the decoder's depth limit.
+func generateDecodeBackReference(buf *bytes.Buffer, field Field, fieldName, varName, endianness, indent string) error {
+	ref := field.BackReference
+	read, err := readPointer(ref.Storage, mapEndianness(endianness))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fieldWhat(field), err)
+	}
+	pointerVar := varName + "_pointer"
+	resumeVar := varName + "_resume"
+	buf.WriteString(fmt.Sprintf("%s%s, err := decoder.%s\n", indent, pointerVar, read))
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	buf.WriteString(fmt.Sprintf("%sif err := decoder.EnterNested(); err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	buf.WriteString(fmt.Sprintf("%s%s := decoder.Position()\n", indent, resumeVar))
+	if ref.FromCurrent {
+		buf.WriteString(fmt.Sprintf("%sdecoder.Seek(%s + int(%s&%#x))\n", indent, resumeVar, pointerVar, ref.OffsetMask))
+	} else {
+		buf.WriteString(fmt.Sprintf("%sdecoder.Seek(int(%s & %#x))\n", indent, pointerVar, ref.OffsetMask))
+	}
+	target := field
+	target.BackReference = nil
+	var targetBuf bytes.Buffer
+	if err := generateDecodeNestedStruct(&targetBuf, target, fieldName, varName, indent); err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimRight(targetBuf.Bytes(), "\n"))
+	buf.WriteString("\n")
+	buf.WriteString(fmt.Sprintf("%sdecoder.Seek(%s)\n", indent, resumeVar))
+	buf.WriteString(fmt.Sprintf("%sdecoder.ExitNested()\n", indent))
+	return nil
+}
+
+// generateDecodeNames decodes an array marked by markNameCompression with Resolve set.
+// A pointer isn't kept: the items it leads to are decoded in its place, up to the
+// name's terminator, and decoding carries on after the first pointer. Every pointer
+// followed counts as nesting, so pointer loops end at the decoder's depth limit.
+func generateDecodeNames(buf *bytes.Buffer, field Field, fieldName, varName, endianness, runtimeEndianness, indent string) error {
+	names := field.Names
+	ref := names.Pointer.BackReference
+	read, err := readPointer(ref.Storage, runtimeEndianness)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fieldWhat(field), err)
+	}
+	itemVar := varName + "_item"
+	resumeVar, hopsVar := varName+"_resume", varName+"_hops"
+	var others []string
+	for _, variant := range field.TerminalVariants {
+		if variant != names.Variant {
+			others = append(others, variant)
+		}
+	}
+
+	buf.WriteString(fmt.Sprintf("%sresult.%s = runtime.Reuse(decoder.Arena, result.%s, 0)\n", indent, fieldName, fieldName))
+	buf.WriteString(fmt.Sprintf("%s%s, %s := -1, 0\n", indent, resumeVar, hopsVar))
+	if len(others) > 0 {
+		buf.WriteString(fmt.Sprintf("%s%sLoop:\n", indent, varName))
+	}
+	buf.WriteString(fmt.Sprintf("%sfor {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\tterminator, err := decoder.PeekUint8()\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\tif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\tif terminator == 0 {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t\tdecoder.SkipBytes(1)\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t\tbreak\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+	generateCancelCheck(buf, indent+"\t")
+
+	// A pointer is recognised by the union's discriminator, before the union would decode it
+	union := names.Union
+	isPointer, err := pointerCondition(union, names.Variant)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fieldWhat(field), err)
+	}
+	peek := union.Discriminator.Peek
+	if peekMethods[peek] {
+		unionEndianness := union.Discriminator.Endianness
+		if unionEndianness == "" {
+			unionEndianness = endianness
+		}
+		buf.WriteString(fmt.Sprintf("%s\tdiscriminator, err := decoder.Peek%s(runtime.%s)\n", indent, capitalizeFirst(peek), mapEndianness(unionEndianness)))
+	} else {
+		buf.WriteString(fmt.Sprintf("%s\tdiscriminator, err := decoder.Peek%s()\n", indent, capitalizeFirst(peek)))
+	}
+	buf.WriteString(fmt.Sprintf("%s\tif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\tif %s {\n", indent, isPointer))
+	buf.WriteString(fmt.Sprintf("%s\t\tpointer, err := decoder.%s\n", indent, read))
+	buf.WriteString(fmt.Sprintf("%s\t\tif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t\t\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t\t}\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t\tif %s < 0 {\n", indent, resumeVar))
+	buf.WriteString(fmt.Sprintf("%s\t\t\t%s = decoder.Position()\n", indent, resumeVar))
+	buf.WriteString(fmt.Sprintf("%s\t\t}\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t\tif err := decoder.EnterNested(); err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t\t\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t\t}\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t\t%s++\n", indent, hopsVar))
+	if ref.FromCurrent {
+		buf.WriteString(fmt.Sprintf("%s\t\tdecoder.Seek(decoder.Position() + int(pointer&%#x))\n", indent, ref.OffsetMask))
+	} else {
+		buf.WriteString(fmt.Sprintf("%s\t\tdecoder.Seek(int(pointer & %#x))\n", indent, ref.OffsetMask))
+	}
+	buf.WriteString(fmt.Sprintf("%s\t\tcontinue\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+	if err := generateDecodeFieldImpl(buf, *field.Items, "", itemVar, endianness, runtimeEndianness, indent+"\t"); err != nil {
+		return err
+	}
+	buf.WriteString(fmt.Sprintf("%s\tresult.%s = append(result.%s, %s)\n", indent, fieldName, fieldName, itemVar))
+	if len(others) > 0 {
+		terminalSwitch(buf, itemVar, others, fmt.Sprintf("break %sLoop", varName), indent+"\t")
+	}
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	buf.WriteString(fmt.Sprintf("%sif %s >= 0 {\n", indent, resumeVar))
+	buf.WriteString(fmt.Sprintf("%s\tdecoder.Seek(%s)\n", indent, resumeVar))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	buf.WriteString(fmt.Sprintf("%sfor ; %s > 0; %s-- {\n", indent, hopsVar, hopsVar))
+	buf.WriteString(fmt.Sprintf("%s\tdecoder.ExitNested()\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n\n", indent))
+	return nil
+}
+
+// pointerCondition returns the Go condition on the peeked discriminator under which
+// a union decodes variant: no earlier case matches, and its own does. A default
+// variant is decoded when no case matches at all.
+func pointerCondition(union *TypeDef, variant string) (string, error) {
+	var conditions []string
+	for i, v := range union.Variants {
+		if v.Type != variant {
+			continue
+		}
+		for j, other := range union.Variants {
+			if other.When == "" || j == i || (j > i && v.When != "") {
+				continue
+			}
+			condition, err := unionCondition(other.When)
+			if err != nil {
+				return "", err
+			}
+			conditions = append(conditions, "!("+condition+")")
+		}
+		if v.When != "" {
+			condition, err := unionCondition(v.When)
+			if err != nil {
+				return "", err
+			}
+			conditions = append(conditions, condition)
+		}
+		return strings.Join(conditions, " && "), nil
+	}
+	return "", fmt.Errorf("union has no variant %s", variant)
+}
+
+// generateNameHelpers adds Labels and Domain to an alias of a name whose pointers
+// the decoder resolves, when every label variant holds a string
+func generateNameHelpers(buf *bytes.Buffer, schema *Schema, name string, typeDef *TypeDef) {
+	if !typeDef.Alias {
+		return
+	}
+	field := typeDef.Sequence[0]
+	if field.Names == nil || !field.Names.Resolve {
+		return
+	}
+	union, ok := schema.Types[field.Items.Type]
+	if !ok {
+		return
+	}
+	var cases []string
+	for _, variant := range union.Variants {
+		value := "v.Value"
+		labelType := variant.Type
+		if variant.Type == field.Names.Variant {
+			// A pointer built by hand rather than decoded holds the label it leads to
+			value += ".Value"
+			labelType = field.Names.Pointer.Type
+		}
+		labelDef, ok := schema.Types[labelType]
+		if !ok || !labelDef.Alias || labelDef.Sequence[0].Type != "string" {
+			return
+		}
+		cases = append(cases, fmt.Sprintf("\t\tcase *%s:\n\t\t\tlabels = append(labels, %s)\n", capitalizeFirst(variant.Type), value))
+	}
+
+	buf.WriteString("// Labels returns the name's labels; a pointer contributes the label it holds\n")
+	recv := typeDef.receiver()
+	buf.WriteString(fmt.Sprintf("func (%s *%s) Labels() []string {\n", recv, name))
+	buf.WriteString(fmt.Sprintf("\tlabels := make([]string, 0, len(%s.Value))\n", recv))
+	buf.WriteString(fmt.Sprintf("\tfor _, item := range %s.Value {\n", recv))
+	buf.WriteString("\t\tswitch v := item.(type) {\n")
+	for _, c := range cases {
+		buf.WriteString(c)
+	}
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn labels\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// Domain returns the name as dotted labels, or \".\" for the root\n")
+	buf.WriteString(fmt.Sprintf("func (%s *%s) Domain() string {\n", recv, name))
+	buf.WriteString("\tdomain := \"\"\n")
+	buf.WriteString(fmt.Sprintf("\tfor i, label := range %s.Labels() {\n", recv))
+	buf.WriteString("\t\tif i > 0 {\n")
+	buf.WriteString("\t\t\tdomain += \".\"\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t\tdomain += label\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tif domain == \"\" {\n")
+	buf.WriteString("\t\treturn \".\"\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn domain\n")
+	buf.WriteString("}\n\n")
+}
+
+// generateEncodeBackReference writes a pointer to the value if the compression
+// dictionary has it as a name of its own, and the value itself otherwise. onPointer,
+// if any, is a statement run once the pointer is written.
+func generateEncodeBackReference(buf *bytes.Buffer, field Field, fieldName, endianness, onPointer, indent string) error {
+	ref := field.BackReference
+	bytesVar := strings.ReplaceAll(strings.ReplaceAll(fieldName, ".", "_"), "m_", "") + "_target"
+	if field.Pointer {
+		buf.WriteString(fmt.Sprintf("%sif %s == nil {\n", indent, fieldName))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: nested %s is nil\")\n", indent, fieldWhat(field), field.Type))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	}
+	buf.WriteString(fmt.Sprintf("%s%s, err := %s.EncodeWithContext(nil)\n", indent, bytesVar, fieldName))
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	if ref.FromCurrent {
+		// Offsets count forwards from the pointer, so no pointer leads to a value written before
+		writeBytes(buf, bytesVar, indent)
+		return nil
+	}
+	buf.WriteString(fmt.Sprintf("%sif _, offset, ok := ctx.FindName([]string{string(%s)}, %s); ok && offset <= %#x {\n", indent, bytesVar, ref.NameKey, ref.OffsetMask))
+	if err := generateWritePointer(buf, ref, "offset", endianness, indent+"\t"); err != nil {
+		return fmt.Errorf("%s: %w", fieldWhat(field), err)
+	}
+	if onPointer != "" {
+		buf.WriteString(fmt.Sprintf("%s\t%s\n", indent, onPointer))
+	}
+	buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+	writeBytes(buf, bytesVar, indent+"\t")
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	return nil
+}
+
+// generateEncodeNames encodes an array marked by markNameCompression. Each label is
+// serialized first; the labels before the name's pointer (or its end) are recorded
+// with the offsets they are written at. With Auto, the longest run of final labels
+// the dictionary has is replaced with a pointer to it.
+func generateEncodeNames(buf *bytes.Buffer, field Field, fieldName, endianness, runtimeEndianness, indent string) error {
+	names := field.Names
+	base := strings.ReplaceAll(strings.ReplaceAll(fieldName, ".", "_"), "m_", "")
+	labelsVar, offsetsVar := base+"_labels", base+"_offsets"
+	itemVar := base + "_item"
+
+	buf.WriteString(fmt.Sprintf("%s%s := make([]string, 0, len(%s))\n", indent, labelsVar, fieldName))
+	buf.WriteString(fmt.Sprintf("%sfor _, %s := range %s {\n", indent, itemVar, fieldName))
+	buf.WriteString(fmt.Sprintf("%s\tif _, ok := %s.(*%s); ok || %s == nil {\n", indent, itemVar, capitalizeFirst(names.Variant), itemVar))
+	buf.WriteString(fmt.Sprintf("%s\t\tbreak\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\tlabel, err := %s.EncodeWithContext(nil)\n", indent, itemVar))
+	buf.WriteString(fmt.Sprintf("%s\tif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t%s = append(%s, string(label))\n", indent, labelsVar, labelsVar))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+
+	// Only a name given no pointer of its own can have one chosen for it
+	pointed := "false"
+	if names.Auto && !names.Pointer.BackReference.FromCurrent {
+		pointed = base + "_pointed"
+		buf.WriteString(fmt.Sprintf("%s%s, %s_offset, %s := len(%s), 0, false\n", indent, base+"_written", base, pointed, labelsVar))
+		buf.WriteString(fmt.Sprintf("%sif len(%s) == len(%s) {\n", indent, labelsVar, fieldName))
+		buf.WriteString(fmt.Sprintf("%s\tif n, offset, ok := ctx.FindName(%s, %s); ok && offset <= %#x {\n", indent, labelsVar, names.Pointer.BackReference.NameKey, names.Pointer.BackReference.OffsetMask))
+		buf.WriteString(fmt.Sprintf("%s\t\t%s, %s_offset, %s = n, offset, true\n", indent, base+"_written", base, pointed))
+		buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	}
+
+	written := labelsVar
+	if pointed != "false" {
+		written += "[:" + base + "_written]"
+	}
+	buf.WriteString(fmt.Sprintf("%s%s := make([]int, len(%s))\n", indent, offsetsVar, written))
+	buf.WriteString(fmt.Sprintf("%sfor i, label := range %s {\n", indent, written))
+	buf.WriteString(fmt.Sprintf("%s\t%s[i] = ctx.Offset() + encoder.Position()\n", indent, offsetsVar))
+	writeBytes(buf, "[]byte(label)", indent+"\t")
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+
+	if pointed != "false" {
+		buf.WriteString(fmt.Sprintf("%sif %s {\n", indent, pointed))
+		if err := generateWritePointer(buf, names.Pointer.BackReference, base+"_offset", endianness, indent+"\t"); err != nil {
+			return fmt.Errorf("%s: %w", fieldWhat(field), err)
+		}
+		buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+		indent += "\t"
+	}
+	// What follows the labels is the pointer the name was given. A pointer to a label
+	// not written before can't be written, so the label is, and the name ends as usual.
+	terminatedVar := base + "_terminated"
+	pointerVar := base + "_pointer"
+	buf.WriteString(fmt.Sprintf("%s%s := false\n", indent, terminatedVar))
+	buf.WriteString(fmt.Sprintf("%sfor _, %s := range %s[len(%s):] {\n", indent, itemVar, fieldName, labelsVar))
+	buf.WriteString(fmt.Sprintf("%s\tif %s, ok := %s.(*%s); ok {\n", indent, pointerVar, itemVar, capitalizeFirst(names.Variant)))
+	pointer := names.Pointer
+	if err := generateEncodeBackReference(buf, pointer, pointerVar+"."+pointer.goName(), endianness, terminatedVar+" = true", indent+"\t\t"); err != nil {
+		return err
+	}
+	buf.WriteString(fmt.Sprintf("%s\t\tcontinue\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+	if err := generateEncodeFieldImpl(buf, *field.Items, itemVar, endianness, runtimeEndianness, indent+"\t"); err != nil {
+		return err
+	}
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	var others []string
+	for _, variant := range field.TerminalVariants {
+		if variant != names.Variant {
+			others = append(others, variant)
+		}
+	}
+	if len(others) > 0 {
+		buf.WriteString(fmt.Sprintf("%sif n := len(%s); n > 0 {\n", indent, fieldName))
+		terminalSwitch(buf, fieldName+"[n-1]", others, terminatedVar+" = true", indent+"\t")
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	}
+	buf.WriteString(fmt.Sprintf("%sif !%s {\n", indent, terminatedVar))
+	buf.WriteString(fmt.Sprintf("%s\tencoder.WriteUint8(0)\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	if pointed != "false" {
+		indent = indent[:len(indent)-1]
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	}
+	buf.WriteString(fmt.Sprintf("%sctx.RecordName(%s, %s, %s)\n", indent, labelsVar, offsetsVar, names.Pointer.BackReference.NameKey))
+	return nil
+}
+
+// generateWritePointer writes a pointer to offset: the offset with every bit outside
+// the mask set, as the top two bits of a DNS compression pointer are
+func generateWritePointer(buf *bytes.Buffer, ref *BackReference, offset, endianness, indent string) error {
+	size := uintBytes[ref.Storage]
+	if size == 0 || size > 4 {
+		return fmt.Errorf("unsupported back_reference storage %q", ref.Storage)
+	}
+	tag := (uint64(1)<<(size*8) - 1) &^ ref.OffsetMask
+	if ref.Storage == "uint8" {
+		buf.WriteString(fmt.Sprintf("%sencoder.WriteUint8(%#x | uint8(%s))\n", indent, tag, offset))
+		return nil
+	}
+	buf.WriteString(fmt.Sprintf("%sencoder.Write%s(%#x|%s(%s), runtime.%s)\n", indent, capitalizeFirst(ref.Storage), tag, ref.Storage, offset, mapEndianness(endianness)))
+	return nil
+}
+
+// readPointer returns the decoder call reading a pointer stored as storage
+func readPointer(storage, runtimeEndianness string) (string, error) {
+	switch storage {
+	case "uint8":
+		return "ReadUint8()", nil
+	case "uint16", "uint32":
+		return fmt.Sprintf("Read%s(runtime.%s)", capitalizeFirst(storage), runtimeEndianness), nil
+	}
+	return "", fmt.Errorf("unsupported back_reference storage %q", storage)
+}
+
+// writeBytes emits the write of a byte slice to the encoder, appended whole when the
+// encoder is byte-aligned
+func writeBytes(buf *bytes.Buffer, bytesVar, indent string) {
+	buf.WriteString(fmt.Sprintf("%sencoder.WriteAlignedBytes(%s)\n", indent, bytesVar))
+}
@@ -0,0 +1,111 @@
+// ABOUTME: Groups of consecutive bit fields read with one ReadBits call
+// ABOUTME: Each field is then shifted and masked out of the word, as DNS header flags are
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Widest group read at once: ReadBits gathers up to 56 bits into a word in one go
+const maxBitGroup = 56
+
+// bitGroup returns the number of bit fields from fields[start] on that are read as one
+// group, or 0 if fewer than two in a row can be
+func bitGroup(fields []Field, start int) int {
+	n, bits := 0, 0
+	for start+n < len(fields) {
+		field := fields[start+n]
+		if (field.Type != "bit" && field.Type != "int") || field.Conditional != "" || field.Codec != "" || bits+field.Size > maxBitGroup {
+			break
+		}
+		bits += field.Size
+		n++
+	}
+	if n < 2 {
+		return 0
+	}
+	return n
+}
+
+// generateDecodeBitGroup decodes a group of bit fields of the struct being decoded with
+// one read. Traced decoders read them one by one, so each gets its own trace event.
+func generateDecodeBitGroup(buf *bytes.Buffer, fields []Field, bitOrder, defaultEndianness string) error {
+	buf.WriteString("\tif !traceEnabled {\n")
+	if err := generateReadBitGroup(buf, fields, "result", "bits", bitOrder, "\t\t"); err != nil {
+		return err
+	}
+	buf.WriteString("\t} else {\n")
+	for _, field := range fields {
+		endianness := fieldEndianness(field, defaultEndianness)
+		if err := generateTracedDecodeField(buf, field, field.goName(), field.localName(), endianness, mapEndianness(endianness), "\t\t"); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("\t}\n\n")
+	return nil
+}
+
+// generateReadBitGroups reads the subfields of a bitfield into target, in groups of up to
+// maxBitGroup bits held in variables named after it
+func generateReadBitGroups(buf *bytes.Buffer, fields []Field, target, bitOrder, indent string) error {
+	for start, group := 0, 1; start < len(fields); group++ {
+		n, bits := 0, 0
+		for start+n < len(fields) && (n == 0 || bits+fields[start+n].Size <= maxBitGroup) {
+			bits += fields[start+n].Size
+			n++
+		}
+		word := target + "_bits"
+		if group > 1 {
+			word = fmt.Sprintf("%s%d", word, group)
+		}
+		if err := generateReadBitGroup(buf, fields[start:start+n], target, word, bitOrder, indent); err != nil {
+			return err
+		}
+		start += n
+	}
+	return nil
+}
+
+// generateReadBitGroup reads the bits of all fields at once into word and sets target's
+// fields from them. In MSB-first order the first field is the top bits of the word; in
+// LSB-first order the bottom ones.
+func generateReadBitGroup(buf *bytes.Buffer, fields []Field, target, word, bitOrder, indent string) error {
+	total := 0
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		total += field.Size
+		names[i] = field.Name
+	}
+
+	buf.WriteString(fmt.Sprintf("%s// %s: %d bits\n", indent, strings.Join(names, ", "), total))
+	buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadBits(%d)\n", indent, word, total))
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+
+	offset := 0
+	for _, field := range fields {
+		goType, err := mapTypeToGo(field)
+		if err != nil {
+			return err
+		}
+		shift := offset
+		if bitOrder != "lsb_first" {
+			shift = total - offset - field.Size
+		}
+		var value string
+		if field.Type == "int" {
+			// Sign-extend: move the field's top bit to bit 63, then shift back arithmetically
+			value = fmt.Sprintf("%s(int64(%s<<%d) >> %d)", goType, word, 64-shift-field.Size, 64-field.Size)
+		} else if shift > 0 {
+			value = fmt.Sprintf("%s(%s >> %d & %#x)", goType, word, shift, uint64(1)<<field.Size-1)
+		} else {
+			value = fmt.Sprintf("%s(%s & %#x)", goType, word, uint64(1)<<field.Size-1)
+		}
+		buf.WriteString(fmt.Sprintf("%s%s.%s = %s\n", indent, target, field.goName(), value))
+		offset += field.Size
+	}
+	return nil
+}
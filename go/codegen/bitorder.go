@@ -0,0 +1,61 @@
+// ABOUTME: Bit order (msb_first/lsb_first) from config, with per-type overrides
+// ABOUTME: Types that differ from their parent switch the shared decoder's bit order while they decode
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// resolveBitOrders gives every type a bit order: its own bit_order, or the config's,
+// or MSB first, and tells bitfields the order of the type they are in. If any type
+// differs from the rest, every struct sets its bit order on the decoder it shares with
+// its parent and restores the parent's when done.
+func resolveBitOrders(schema *Schema) {
+	defaultOrder := "msb_first"
+	if schema.Config != nil && schema.Config.BitOrder != "" {
+		defaultOrder = schema.Config.BitOrder
+	}
+	overridden := false
+	for _, typeDef := range schema.Types {
+		if typeDef.BitOrder == "" {
+			typeDef.BitOrder = defaultOrder
+		} else if typeDef.BitOrder != defaultOrder {
+			overridden = true
+		}
+	}
+	for _, typeDef := range schema.Types {
+		for _, field := range typeDef.fieldPointers() {
+			if field.Items != nil {
+				field = field.Items
+			}
+			if field.Bitfield {
+				field.BitOrder = typeDef.BitOrder
+			}
+		}
+	}
+	if !overridden {
+		return
+	}
+	for _, typeDef := range schema.Types {
+		typeDef.SwitchesBitOrder = typeDef.Discriminator == nil && !typeDef.Bitfield
+	}
+}
+
+// runtimeBitOrder returns the runtime constant for a bit_order value
+func runtimeBitOrder(order string) string {
+	if order == "lsb_first" {
+		return "runtime.LSBFirst"
+	}
+	return "runtime.MSBFirst"
+}
+
+// generateDecodeBitOrder switches a shared decoder to the type's bit order until it returns
+func generateDecodeBitOrder(buf *bytes.Buffer, typeDef *TypeDef) {
+	if !typeDef.SwitchesBitOrder {
+		return
+	}
+	buf.WriteString("\tparentBitOrder := decoder.BitOrder()\n")
+	buf.WriteString(fmt.Sprintf("\tdecoder.SetBitOrder(%s)\n", runtimeBitOrder(typeDef.BitOrder)))
+	buf.WriteString("\tdefer decoder.SetBitOrder(parentBitOrder)\n\n")
+}
@@ -0,0 +1,275 @@
+// ABOUTME: Builders option: NewX constructors and XBuilder types that check required fields
+// ABOUTME: Build fills in what other fields determine: counts and lengths, computed length_of/count_of, consts
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// derivation is a field Build sets from the length of another
+type derivation struct {
+	target string // Go path below the struct: "Header.Qdcount"
+	what   string // Its schema path, for errors: "header.qdcount"
+	goType string
+	source Field // The sibling measured
+	offset int
+}
+
+// Length and count sources Build can follow: a field, or a field of a nested struct
+var fieldPathPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// Go types a derived length can be stored in
+var lengthGoTypes = map[string]bool{
+	"uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"int8": true, "int16": true, "int32": true, "int64": true,
+}
+
+// generateBuilder emits XBuilder, its setters and Build, and NewX taking the required
+// fields. A field is required unless it is conditional or optional, a protobuf field,
+// or derived from others; derived fields have no setter.
+func generateBuilder(buf *bytes.Buffer, schema *Schema, name string, typeDef *TypeDef) error {
+	derivations, checks, consts, derived := builderDerivations(schema, typeDef)
+
+	var setters, required []Field
+	for i, field := range typeDef.Sequence {
+		if derived[i] || field.PositionOf != "" || field.ByteRange != nil {
+			continue
+		}
+		setters = append(setters, field)
+		if field.Conditional == "" && !field.Optional && !typeDef.Protobuf {
+			required = append(required, field)
+		}
+	}
+	requiredIndex := make(map[string]int, len(required))
+	for i, field := range required {
+		requiredIndex[field.Name] = i
+	}
+
+	builder := name + "Builder"
+	buf.WriteString(fmt.Sprintf("// %s sets the fields of a %s one by one; Build checks the required ones\n", builder, name))
+	buf.WriteString("// were set and fills in those derived from others\n")
+	buf.WriteString(fmt.Sprintf("type %s struct {\n", builder))
+	buf.WriteString(fmt.Sprintf("\tm %s\n", name))
+	if len(required) > 0 {
+		buf.WriteString(fmt.Sprintf("\tset [%d]bool\n", len(required)))
+	}
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("// New%s returns an empty %s\n", builder, builder))
+	buf.WriteString(fmt.Sprintf("func New%s() *%s {\n", builder, builder))
+	buf.WriteString(fmt.Sprintf("\treturn &%s{}\n", builder))
+	buf.WriteString("}\n\n")
+
+	for _, field := range setters {
+		fieldName := field.goName()
+		inner := field
+		inner.Lazy = false
+		goType, err := mapTypeToGo(inner)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("// %s sets %s\n", fieldName, field.Name))
+		buf.WriteString(fmt.Sprintf("func (b *%s) %s(v %s) *%s {\n", builder, fieldName, goType, builder))
+		if field.Lazy {
+			buf.WriteString(fmt.Sprintf("\tb.m.%s.Set(v)\n", fieldName))
+		} else {
+			buf.WriteString(fmt.Sprintf("\tb.m.%s = v\n", fieldName))
+		}
+		if i, ok := requiredIndex[field.Name]; ok {
+			buf.WriteString(fmt.Sprintf("\tb.set[%d] = true\n", i))
+		}
+		buf.WriteString("\treturn b\n")
+		buf.WriteString("}\n\n")
+	}
+
+	buf.WriteString(fmt.Sprintf("// Build returns the %s built, or an error naming a required field that wasn't\n", name))
+	buf.WriteString("// set or a length too large for the field holding it\n")
+	buf.WriteString(fmt.Sprintf("func (b *%s) Build() (*%s, error) {\n", builder, name))
+	for i, field := range required {
+		buf.WriteString(fmt.Sprintf("\tif !b.set[%d] {\n", i))
+		buf.WriteString(fmt.Sprintf("\t\treturn nil, fmt.Errorf(\"%s: %s is required\")\n", name, field.Name))
+		buf.WriteString("\t}\n")
+	}
+	buf.WriteString("\tm := b.m\n")
+	for _, check := range checks {
+		buf.WriteString(fmt.Sprintf("\tif len(m.%s) != len(m.%s) {\n", check[1].goName(), check[0].goName()))
+		buf.WriteString(fmt.Sprintf("\t\treturn nil, fmt.Errorf(\"%s: %s and %s share a length, but have %%d and %%d\", len(m.%s), len(m.%s))\n",
+			name, check[0].Name, check[1].Name, check[0].goName(), check[1].goName()))
+		buf.WriteString("\t}\n")
+	}
+	for _, d := range derivations {
+		n := fmt.Sprintf("len(m.%s)", d.source.goName())
+		if d.offset != 0 {
+			n = fmt.Sprintf("%s + %d", n, d.offset)
+		}
+		buf.WriteString(fmt.Sprintf("\tif n := %s; int(%s(n)) != n {\n", n, d.goType))
+		buf.WriteString(fmt.Sprintf("\t\treturn nil, fmt.Errorf(\"%s: %s length %%d doesn't fit in %s\", n)\n", name, d.source.Name, d.what))
+		buf.WriteString("\t}\n")
+		buf.WriteString(fmt.Sprintf("\tm.%s = %s(%s)\n", d.target, d.goType, n))
+	}
+	for _, field := range consts {
+		value, _ := builderConst(field)
+		buf.WriteString(fmt.Sprintf("\tm.%s = %s\n", field.goName(), value))
+	}
+	buf.WriteString("\treturn &m, nil\n")
+	buf.WriteString("}\n\n")
+
+	var params, calls []string
+	for _, field := range required {
+		inner := field
+		inner.Lazy = false
+		goType, err := mapTypeToGo(inner)
+		if err != nil {
+			return err
+		}
+		param := strings.ToLower(field.goName()[:1]) + field.goName()[1:]
+		if token.Lookup(param).IsKeyword() {
+			param += "_"
+		}
+		params = append(params, fmt.Sprintf("%s %s", param, goType))
+		calls = append(calls, fmt.Sprintf(".%s(%s)", field.goName(), param))
+	}
+	buf.WriteString(fmt.Sprintf("// New%s returns a %s with the given required fields, filling in those derived\n", name, name))
+	buf.WriteString(fmt.Sprintf("// from them as %s does\n", builder))
+	buf.WriteString(fmt.Sprintf("func New%s(%s) (*%s, error) {\n", name, strings.Join(params, ", "), name))
+	buf.WriteString(fmt.Sprintf("\treturn New%s()%s.Build()\n", builder, strings.Join(calls, "")))
+	buf.WriteString("}\n\n")
+	return nil
+}
+
+// builderDerivations finds the fields Build sets: those other fields are read with
+// (length_field, count_expr or a fixed length naming them), computed length_of and
+// count_of fields, and consts. It returns the derivations, pairs of fields sharing a
+// length, which must agree, the consts, and the sequence indexes of all of them.
+func builderDerivations(schema *Schema, typeDef *TypeDef) ([]derivation, [][2]Field, []Field, map[int]bool) {
+	var derivations []derivation
+	var checks [][2]Field
+	var consts []Field
+	derived := make(map[int]bool)
+	byTarget := make(map[string]int)
+
+	add := func(d derivation, top int) {
+		if i, ok := byTarget[d.target]; ok {
+			if first := derivations[i]; first.offset == 0 && d.offset == 0 && first.source.Name != d.source.Name {
+				checks = append(checks, [2]Field{first.source, d.source})
+			}
+			return
+		}
+		byTarget[d.target] = len(derivations)
+		derivations = append(derivations, d)
+		if top >= 0 {
+			derived[top] = true
+		}
+	}
+
+	for _, field := range typeDef.Sequence {
+		src, ok := lengthSource(field)
+		if !ok || !measurable(field) || !fieldPathPattern.MatchString(src) {
+			continue
+		}
+		if d, top, ok := resolveLengthTarget(schema, typeDef, strings.Split(src, ".")); ok {
+			d.source = field
+			add(d, top)
+		}
+	}
+	for i, field := range typeDef.Sequence {
+		target, offset := field.CountOf, 0
+		if field.LengthOf != "" {
+			target, offset = field.LengthOf, field.ComputedOffset
+		}
+		if target == "" {
+			continue
+		}
+		source, ok := sequenceField(typeDef, target)
+		if !ok || !measurable(source) {
+			continue
+		}
+		// The byte length of anything but a string or byte array needs encoding it
+		if field.LengthOf != "" && source.Type != "string" && !isByteArray(source) {
+			continue
+		}
+		if d, top, ok := resolveLengthTarget(schema, typeDef, []string{field.Name}); ok && top == i {
+			d.source = source
+			d.offset = offset
+			add(d, top)
+		}
+	}
+	for i, field := range typeDef.Sequence {
+		if _, ok := builderConst(field); ok && !derived[i] {
+			consts = append(consts, field)
+			derived[i] = true
+		}
+	}
+	return derivations, checks, consts, derived
+}
+
+// measurable reports whether Build can take a field's length with len: a string or
+// array always present, held directly
+func measurable(field Field) bool {
+	return (field.Type == "string" || field.Type == "array") && field.Conditional == "" && !field.Optional && !field.Lazy
+}
+
+// resolveLengthTarget finds the integer field a path names, through nested structs held
+// by value, and the sequence index of the top-level field it starts at (-1 if it goes
+// into a nested struct, which keeps its setter)
+func resolveLengthTarget(schema *Schema, typeDef *TypeDef, path []string) (derivation, int, bool) {
+	current := typeDef
+	var goPath []string
+	top := -1
+	for depth, name := range path {
+		index := -1
+		for i, field := range current.Sequence {
+			if field.Name == name {
+				index = i
+			}
+		}
+		if index < 0 {
+			return derivation{}, 0, false
+		}
+		field := current.Sequence[index]
+		goPath = append(goPath, field.goName())
+		if depth == len(path)-1 {
+			goType, err := mapTypeToGo(field)
+			if err != nil || !lengthGoTypes[goType] || field.Type == "bit" || field.Type == "int" || field.FlagsRepr != "" || field.PositionOf != "" {
+				return derivation{}, 0, false
+			}
+			if len(path) == 1 {
+				top = index
+			}
+			return derivation{target: strings.Join(goPath, "."), what: strings.Join(path, "."), goType: goType}, top, true
+		}
+		nested, ok := schema.Types[field.Type]
+		if !ok || field.Pointer || field.Union || field.Bitfield || nested.Discriminator != nil {
+			return derivation{}, 0, false
+		}
+		current = nested
+	}
+	return derivation{}, 0, false
+}
+
+// sequenceField finds a sequence field by schema name
+func sequenceField(typeDef *TypeDef, name string) (Field, bool) {
+	for _, field := range typeDef.Sequence {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// builderConst returns the Go value Build gives a const integer field
+func builderConst(field Field) (string, bool) {
+	value, ok := field.Const.(float64)
+	if !ok || field.Type == "bit" || field.Type == "int" {
+		return "", false
+	}
+	goType, err := mapTypeToGo(field)
+	if err != nil || !lengthGoTypes[goType] {
+		return "", false
+	}
+	return fmt.Sprintf("%d", int64(value)), true
+}
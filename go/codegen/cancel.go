@@ -0,0 +1,29 @@
+// ABOUTME: DecodeXContext: decoding that stops when a context.Context is canceled
+// ABOUTME: Array and protobuf field loops check the decoder's context as they go
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// generateDecodeContext emits the public DecodeXContext
+func generateDecodeContext(buf *bytes.Buffer, typeName string, typeDef *TypeDef) {
+	buf.WriteString(fmt.Sprintf("// Decode%sContext decodes bytes like Decode%s, returning ctx.Err() if ctx is done\n", typeName, typeName))
+	buf.WriteString("// before it starts or while it decodes arrays\n")
+	buf.WriteString(fmt.Sprintf("func Decode%sContext(ctx context.Context, bytes []byte) (*%s, error) {\n", typeName, typeName))
+	buf.WriteString("\tif err := ctx.Err(); err != nil {\n")
+	buf.WriteString("\t\treturn nil, err\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString(fmt.Sprintf("\tdecoder := runtime.NewBitStreamDecoder(bytes, %s)\n", runtimeBitOrder(typeDef.BitOrder)))
+	buf.WriteString("\tdecoder.Context = ctx\n")
+	buf.WriteString(fmt.Sprintf("\treturn decode%sWithDecoder(decoder, nil)\n", typeName))
+	buf.WriteString("}\n\n")
+}
+
+// generateCancelCheck emits the check at the top of each iteration of a decoding loop
+func generateCancelCheck(buf *bytes.Buffer, indent string) {
+	buf.WriteString(fmt.Sprintf("%sif err := decoder.CheckCanceled(); err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
@@ -0,0 +1,96 @@
+// ABOUTME: Canonical option: encoders give every value one encoding, for signing and hashing encoded bytes
+// ABOUTME: NaNs are written as one NaN, and values that would be truncated or decode differently are errors
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// markCanonical sets Canonical on every field, array item and bitfield subfield
+func markCanonical(schema *Schema) {
+	var mark func(field *Field)
+	mark = func(field *Field) {
+		field.Canonical = true
+		if field.Items != nil {
+			mark(field.Items)
+		}
+		for i := range field.Fields {
+			mark(&field.Fields[i])
+		}
+	}
+	for _, typeDef := range schema.Types {
+		for _, field := range typeDef.fieldPointers() {
+			mark(field)
+		}
+	}
+}
+
+// canonicalFloat returns the expression a float field (or array of floats) writes:
+// the value itself, or with Canonical, the value with any NaN replaced by the quiet NaN
+func canonicalFloat(field Field, value string) string {
+	if !field.Canonical {
+		return value
+	}
+	typeName := field.Type
+	if field.Items != nil {
+		typeName = field.Items.Type
+	}
+	if typeName == "float32" {
+		return fmt.Sprintf("runtime.CanonicalFloat32(%s)", value)
+	}
+	return fmt.Sprintf("runtime.CanonicalFloat64(%s)", value)
+}
+
+// generateCanonicalBitsCheck rejects a bit field value wider than its bits, which
+// encoding would otherwise mask
+func generateCanonicalBitsCheck(buf *bytes.Buffer, field Field, fieldName, indent string) {
+	switch field.Size {
+	case 8, 16, 32, 64:
+		// The Go type holds no more than the bits
+		return
+	}
+	buf.WriteString(fmt.Sprintf("%sif %s > %d {\n", indent, fieldName, uint64(1)<<field.Size-1))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%d doesn't fit in %d bits\", %s)\n", indent, fieldWhat(field), field.Size, fieldName))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
+// generateCanonicalStringCheck rejects NUL bytes in null-terminated strings, which would
+// end at them
+func generateCanonicalStringCheck(buf *bytes.Buffer, field Field, bytesVar, indent string) {
+	if field.Kind != "null_terminated" {
+		return
+	}
+	buf.WriteString(fmt.Sprintf("%sfor _, b := range %s {\n", indent, bytesVar))
+	buf.WriteString(fmt.Sprintf("%s\tif b == 0 {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t\treturn nil, fmt.Errorf(\"%s: string contains a NUL byte\")\n", indent, fieldWhat(field)))
+	buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
+// generateCanonicalLengthCheck rejects a fixed-length string that wouldn't decode as
+// itself: one longer than its field, or ending in its padding, which decoding trims.
+// Without padding, every byte of the field is decoded, so the string must fill it.
+func generateCanonicalLengthCheck(buf *bytes.Buffer, field Field, bytesVar, length, indent string) {
+	buf.WriteString(fmt.Sprintf("%sif len(%s) > %s {\n", indent, bytesVar, length))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%d bytes don't fit in %%d\", len(%s), %s)\n", indent, fieldWhat(field), bytesVar, length))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	pad, trims := padByte(field)
+	if !trims {
+		buf.WriteString(fmt.Sprintf("%sif len(%s) < %s {\n", indent, bytesVar, length))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%d bytes don't fill %%d\", len(%s), %s)\n", indent, fieldWhat(field), bytesVar, length))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		return
+	}
+	buf.WriteString(fmt.Sprintf("%sif len(%s) > 0 && %s[len(%s)-1] == %s {\n", indent, bytesVar, bytesVar, bytesVar, pad))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: string ends in padding\")\n", indent, fieldWhat(field)))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
+// fieldWhat names a field in encode errors; array items have no name of their own
+func fieldWhat(field Field) string {
+	if field.Name == "" {
+		return "array item"
+	}
+	return field.Name
+}
@@ -0,0 +1,99 @@
+// ABOUTME: Unsafe casts of fixed-size little-endian records: bytes viewed as structs, and back, without copying
+// ABOUTME: Only types whose Go layout is their encoding qualify, and generated assertions check it at compile time
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// markCastTypes marks the types GenerateOptions.CastTypes names (schema names) for
+// casts, failing for one whose Go struct isn't laid out like its encoding
+func markCastTypes(schema *Schema, opts GenerateOptions, defaultEndianness string) error {
+	for _, name := range opts.CastTypes {
+		typeDef, ok := schema.Types[goTypeName(opts, name)]
+		if !ok {
+			return fmt.Errorf("cast type %s not found in schema", name)
+		}
+		if _, err := castAlign(schema, typeDef, defaultEndianness); err != nil {
+			return fmt.Errorf("cast type %s: %w", name, err)
+		}
+		typeDef.Cast = true
+	}
+	return nil
+}
+
+// castAlign returns the alignment of a type whose Go struct has the layout of its
+// encoding: fixed-width little-endian numbers and nested such structs, each at an
+// offset that is a multiple of its alignment, with no padding at the end. Widths are
+// alignments, which on 32-bit hosts only loosens what 64-bit ones need.
+func castAlign(schema *Schema, typeDef *TypeDef, defaultEndianness string) (int, error) {
+	if typeDef.FixedSize == 0 {
+		return 0, fmt.Errorf("not a struct of fixed-width fields only")
+	}
+	offset, maxAlign := 0, 1
+	for _, field := range typeDef.Sequence {
+		align := field.InlineWidth
+		if nested, ok := schema.Types[field.Type]; ok && field.FlagsRepr == "" {
+			nestedAlign, err := castAlign(schema, nested, defaultEndianness)
+			if err != nil {
+				return 0, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			align = nestedAlign
+		} else if align > 1 && fieldEndianness(field, defaultEndianness) != "little_endian" {
+			return 0, fmt.Errorf("field %s is not little-endian", field.Name)
+		}
+		if offset%align != 0 {
+			return 0, fmt.Errorf("field %s at byte %d is not aligned to its %d bytes", field.Name, offset, align)
+		}
+		offset += field.InlineWidth
+		if align > maxAlign {
+			maxAlign = align
+		}
+	}
+	if offset%maxAlign != 0 {
+		return 0, fmt.Errorf("its %d bytes are not a multiple of its %d-byte alignment", offset, maxAlign)
+	}
+	return maxAlign, nil
+}
+
+// generateCasts emits the compile-time layout assertions of a cast type, and the
+// casts of the directions generated: CastX and CastXSlice decode, XSliceBytes encodes
+func generateCasts(buf *bytes.Buffer, typeName string, typeDef *TypeDef, direction Direction) {
+	buf.WriteString(fmt.Sprintf("// %s's Go layout is its encoding, which its casts rely on: these fail to compile\n", typeName))
+	buf.WriteString("// if it isn't\n")
+	buf.WriteString("var (\n")
+	buf.WriteString(fmt.Sprintf("\t_ = [1]struct{}{}[unsafe.Sizeof(%s{})-%d]\n", typeName, typeDef.FixedSize))
+	offset := 0
+	for _, field := range typeDef.Sequence {
+		if offset > 0 {
+			buf.WriteString(fmt.Sprintf("\t_ = [1]struct{}{}[unsafe.Offsetof(%s{}.%s)-%d]\n", typeName, field.goName(), offset))
+		}
+		offset += field.InlineWidth
+	}
+	buf.WriteString(")\n\n")
+
+	if direction.decodes() {
+		buf.WriteString(fmt.Sprintf("// Cast%s returns the %s at the start of data without decoding or copying it. It\n", typeName, typeName))
+		buf.WriteString("// shares data's memory, so it is only valid while data is, and writing to it writes to\n")
+		buf.WriteString("// data. data must be aligned for it, as the start of an mmap'd file is. Casts fail\n")
+		buf.WriteString("// with runtime.ErrHostByteOrder on big-endian hosts.\n")
+		buf.WriteString(fmt.Sprintf("func Cast%s(data []byte) (*%s, error) {\n", typeName, typeName))
+		buf.WriteString(fmt.Sprintf("\treturn runtime.Cast[%s](data)\n", typeName))
+		buf.WriteString("}\n\n")
+
+		buf.WriteString(fmt.Sprintf("// Cast%sSlice returns the %d-byte records of data as a []%s sharing its memory,\n", typeName, typeDef.FixedSize, typeName))
+		buf.WriteString(fmt.Sprintf("// like Cast%s. data must be a whole number of records.\n", typeName))
+		buf.WriteString(fmt.Sprintf("func Cast%sSlice(data []byte) ([]%s, error) {\n", typeName, typeName))
+		buf.WriteString(fmt.Sprintf("\treturn runtime.CastSlice[%s](data)\n", typeName))
+		buf.WriteString("}\n\n")
+	}
+	if direction.encodes() {
+		buf.WriteString(fmt.Sprintf("// %sSliceBytes returns the encoding of records without encoding or copying them:\n", typeName))
+		buf.WriteString("// the bytes share the records' memory. It fails with runtime.ErrHostByteOrder on\n")
+		buf.WriteString("// big-endian hosts.\n")
+		buf.WriteString(fmt.Sprintf("func %sSliceBytes(records []%s) ([]byte, error) {\n", typeName, typeName))
+		buf.WriteString("\treturn runtime.SliceBytes(records)\n")
+		buf.WriteString("}\n\n")
+	}
+}
@@ -0,0 +1,99 @@
+// ABOUTME: Generates Clone(): deep copies of structs, their slices, nested structs and union variants
+// ABOUTME: Lets callers mutate a decoded value safely and keep ZeroCopy or lazy values past their input
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// generateClone emits Clone, a deep copy of a struct that shares no memory with it,
+// and so none with the input its ByteStrings view
+func generateClone(buf *bytes.Buffer, schema *Schema, name string, typeDef *TypeDef) error {
+	recv := typeDef.receiver()
+	buf.WriteString(fmt.Sprintf("// Clone returns a deep copy of %s that shares no memory with %s or the input it was decoded from\n", name, recv))
+	buf.WriteString(fmt.Sprintf("func (%s *%s) Clone() *%s {\n", recv, name, name))
+	buf.WriteString(fmt.Sprintf("\tif %s == nil {\n", recv))
+	buf.WriteString("\t\treturn nil\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString(fmt.Sprintf("\tout := *%s\n", recv))
+	for _, field := range typeDef.allFields() {
+		if !needsClone(schema, field) {
+			continue
+		}
+		fieldName := field.goName()
+		if err := generateCloneValue(buf, schema, field, "out."+fieldName, recv+"."+fieldName, "\t", 0); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("\treturn &out\n")
+	buf.WriteString("}\n\n")
+	return nil
+}
+
+// generateUnionClone emits clone<Union>, which clones whichever variant a union holds
+func generateUnionClone(buf *bytes.Buffer, name string, typeDef *TypeDef) {
+	buf.WriteString(fmt.Sprintf("func clone%s(v %s) %s {\n", name, name, name))
+	buf.WriteString("\tswitch v := v.(type) {\n")
+	for _, variant := range typeDef.Variants {
+		variantName := capitalizeFirst(variant.Type)
+		buf.WriteString(fmt.Sprintf("\tcase *%s:\n", variantName))
+		buf.WriteString("\t\tif v != nil {\n")
+		buf.WriteString("\t\t\treturn v.Clone()\n")
+		buf.WriteString("\t\t}\n")
+	}
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn v\n")
+	buf.WriteString("}\n\n")
+}
+
+// needsClone reports whether copying a field's value leaves it sharing memory:
+// ByteStrings, slices, pointers, unions and structs that may hold them
+func needsClone(schema *Schema, field Field) bool {
+	if field.Lazy || field.ZeroCopy || field.Type == "array" || field.Pointer || field.Union {
+		return true
+	}
+	typeDef, isType := schema.Types[field.Type]
+	return isType && typeDef.Flags == nil && !typeDef.Bitfield
+}
+
+// generateCloneValue emits the assignment of a deep copy of src to dst, for a field
+// needsClone reports true for
+func generateCloneValue(buf *bytes.Buffer, schema *Schema, field Field, dst, src, indent string, depth int) error {
+	switch {
+	case field.Lazy, field.ZeroCopy:
+		buf.WriteString(fmt.Sprintf("%s%s = %s.Clone()\n", indent, dst, src))
+	case field.Union:
+		buf.WriteString(fmt.Sprintf("%s%s = clone%s(%s)\n", indent, dst, capitalizeFirst(field.Type), src))
+	case field.Pointer:
+		buf.WriteString(fmt.Sprintf("%sif %s != nil {\n", indent, src))
+		buf.WriteString(fmt.Sprintf("%s\t%s = %s.Clone()\n", indent, dst, src))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	case field.Type == "array":
+		if field.Items == nil {
+			return fmt.Errorf("array field missing items definition")
+		}
+		goType, err := mapTypeToGo(field)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("%sif %s != nil {\n", indent, src))
+		buf.WriteString(fmt.Sprintf("%s\t%s = make(%s, len(%s))\n", indent, dst, goType, src))
+		if !needsClone(schema, *field.Items) {
+			buf.WriteString(fmt.Sprintf("%s\tcopy(%s, %s)\n", indent, dst, src))
+		} else {
+			index := fmt.Sprintf("i%d", depth)
+			buf.WriteString(fmt.Sprintf("%s\tfor %s := range %s {\n", indent, index, src))
+			item := fmt.Sprintf("[%s]", index)
+			if err := generateCloneValue(buf, schema, *field.Items, dst+item, src+item, indent+"\t\t", depth+1); err != nil {
+				return err
+			}
+			buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+		}
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	default:
+		// A struct held by value
+		buf.WriteString(fmt.Sprintf("%s%s = *%s.Clone()\n", indent, dst, src))
+	}
+	return nil
+}
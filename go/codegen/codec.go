@@ -0,0 +1,23 @@
+// ABOUTME: BinaryCodecs option: encoding.BinaryMarshaler and BinaryUnmarshaler on generated types
+// ABOUTME: With them runtime.Codec carries generated messages through gRPC and binary-aware libraries
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// generateBinaryMethods emits MarshalBinary and UnmarshalBinary, which wrap Encode and
+// decoding into the receiver as DecodeXInto does
+func generateBinaryMethods(buf *bytes.Buffer, name string, typeDef *TypeDef) {
+	recv := typeDef.receiver()
+	buf.WriteString(fmt.Sprintf("// MarshalBinary encodes %s, implementing encoding.BinaryMarshaler\n", recv))
+	buf.WriteString(fmt.Sprintf("func (%s *%s) MarshalBinary() ([]byte, error) {\n", recv, name))
+	buf.WriteString(fmt.Sprintf("\treturn %s.Encode()\n", recv))
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("// UnmarshalBinary decodes data into %s, implementing encoding.BinaryUnmarshaler\n", recv))
+	buf.WriteString(fmt.Sprintf("func (%s *%s) UnmarshalBinary(data []byte) error {\n", recv, name))
+	writeDecodeInto(buf, name, typeDef, "data", recv)
+	buf.WriteString("}\n\n")
+}
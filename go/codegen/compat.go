@@ -0,0 +1,409 @@
+// ABOUTME: CheckCompatibility: compares two versions of a schema for schema-evolution review
+// ABOUTME: Reports each change as breaking (old and new data can't be read both ways) or wire-compatible
+package codegen
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// CompatibilityChange is one difference between two versions of a schema
+type CompatibilityChange struct {
+	Breaking bool   // Data written with one version can't be read with the other
+	Path     string // Location in the new schema, or in the old one for what was removed
+	Message  string
+}
+
+func (c CompatibilityChange) String() string {
+	kind := "compatible"
+	if c.Breaking {
+		kind = "breaking"
+	}
+	if c.Path == "" {
+		return fmt.Sprintf("%s: %s", kind, c.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", kind, c.Path, c.Message)
+}
+
+// Attributes that document a schema without changing its wire format
+var documentationAttributes = attributeSet("description", "notes", "metadata", "example")
+
+// CheckCompatibility compares an old and a new version of a raw schema and reports
+// what changed on the wire. Removed or renamed types and fields, changed types,
+// lengths, byte or bit orders, reordered or inserted sequence fields, and removed
+// union variants are breaking. Added types and variants, documentation changes, and
+// optional or conditional fields appended to a type nothing follows on the wire are
+// compatible. Protobuf messages follow protobuf's rules instead: fields are matched
+// by field number, and adding or removing fields is compatible.
+//
+// Changes are sorted by path. Both schemas are assumed to be valid.
+func CheckCompatibility(oldSchema, newSchema map[string]interface{}) []CompatibilityChange {
+	c := &compatChecker{}
+	oldConfig, _ := oldSchema["config"].(map[string]interface{})
+	newConfig, _ := newSchema["config"].(map[string]interface{})
+	c.compareAttributes("config", oldConfig, newConfig)
+
+	oldTypes, _ := oldSchema["types"].(map[string]interface{})
+	newTypes, _ := newSchema["types"].(map[string]interface{})
+	c.embedded = embeddedTypes(newTypes)
+	for _, name := range sortedKeys(oldTypes) {
+		path := "types." + name
+		newType, ok := newTypes[name].(map[string]interface{})
+		if !ok {
+			c.breaking(path, "type %s was removed", name)
+			continue
+		}
+		oldType, _ := oldTypes[name].(map[string]interface{})
+		c.compareType(name, path, oldType, newType)
+	}
+	for _, name := range sortedKeys(newTypes) {
+		if _, ok := oldTypes[name]; !ok {
+			c.compatible("types."+name, "type %s was added", name)
+		}
+	}
+
+	sort.SliceStable(c.changes, func(i, j int) bool {
+		return pathLess(c.changes[i].Path, c.changes[j].Path)
+	})
+	return c.changes
+}
+
+type compatChecker struct {
+	embedded map[string]string // Type -> a field after which more data follows it on the wire
+	changes  []CompatibilityChange
+}
+
+func (c *compatChecker) breaking(path, format string, args ...interface{}) {
+	c.changes = append(c.changes, CompatibilityChange{true, path, fmt.Sprintf(format, args...)})
+}
+
+func (c *compatChecker) compatible(path, format string, args ...interface{}) {
+	c.changes = append(c.changes, CompatibilityChange{false, path, fmt.Sprintf(format, args...)})
+}
+
+// compareType compares the two versions of a type definition
+func (c *compatChecker) compareType(name, path string, oldType, newType map[string]interface{}) {
+	oldSequence, oldIsStruct := oldType["sequence"].([]interface{})
+	newSequence, newIsStruct := newType["sequence"].([]interface{})
+	oldProtobuf, _ := oldType["protobuf"].(bool)
+	newProtobuf, _ := newType["protobuf"].(bool)
+
+	switch {
+	case oldIsStruct != newIsStruct || oldProtobuf != newProtobuf:
+		c.breaking(path, "type %s changed from %s to %s", name, typeKind(oldType), typeKind(newType))
+	case newProtobuf:
+		c.compareProtobuf(path+".sequence", oldSequence, newSequence)
+	case newIsStruct:
+		c.compareAttributes(path, withoutKeys(oldType, "sequence", "instances"), withoutKeys(newType, "sequence", "instances"))
+		c.compareSequence(path+".sequence", oldSequence, newSequence, c.embedded[name])
+		oldInstances, _ := oldType["instances"].([]interface{})
+		newInstances, _ := newType["instances"].([]interface{})
+		c.compareInstances(path+".instances", oldInstances, newInstances)
+	default:
+		c.compareElement(path, oldType, newType, c.embedded[name])
+	}
+}
+
+// compareSequence matches the fields of two versions of a struct by name. Fields must
+// keep their order; new fields may only be appended, only optional ones, and only if no
+// data follows the struct on the wire: followedBy names where some does, if anywhere.
+func (c *compatChecker) compareSequence(path string, oldSequence, newSequence []interface{}, followedBy string) {
+	newIndex := make(map[string]int)
+	for i, raw := range newSequence {
+		if field, ok := raw.(map[string]interface{}); ok {
+			name, _ := field["name"].(string)
+			newIndex[name] = i
+		}
+	}
+
+	last := -1 // Index in the new sequence of the last field kept so far
+	for i, raw := range oldSequence {
+		oldField, _ := raw.(map[string]interface{})
+		name, _ := oldField["name"].(string)
+		j, ok := newIndex[name]
+		if !ok {
+			c.breaking(fmt.Sprintf("%s[%d]", path, i), "field %q was removed", name)
+			continue
+		}
+		fieldPath := fmt.Sprintf("%s[%d]", path, j)
+		if j < last {
+			c.breaking(fieldPath, "field %q was moved before fields it used to follow", name)
+		}
+		if j > last {
+			last = j
+		}
+		newField, _ := newSequence[j].(map[string]interface{})
+		fieldFollowedBy := followedBy
+		if j < len(newSequence)-1 {
+			fieldFollowedBy = fieldPath
+		}
+		c.compareElement(fieldPath, oldField, newField, fieldFollowedBy)
+	}
+
+	oldNames := make(map[string]bool)
+	for _, raw := range oldSequence {
+		if field, ok := raw.(map[string]interface{}); ok {
+			name, _ := field["name"].(string)
+			oldNames[name] = true
+		}
+	}
+	for j, raw := range newSequence {
+		field, _ := raw.(map[string]interface{})
+		name, _ := field["name"].(string)
+		if oldNames[name] {
+			continue
+		}
+		fieldPath := fmt.Sprintf("%s[%d]", path, j)
+		switch {
+		case j < last:
+			c.breaking(fieldPath, "field %q was inserted before existing fields", name)
+		case !isOptionalField(field):
+			c.breaking(fieldPath, "field %q was appended but isn't optional: data from the old schema lacks it", name)
+		case followedBy != "":
+			c.breaking(fieldPath, "field %q was appended, but more data follows in %s", name, followedBy)
+		default:
+			c.compatible(fieldPath, "optional field %q was appended", name)
+		}
+	}
+}
+
+// compareElement compares two versions of a field, array item or type alias, followed
+// on the wire by data from followedBy if not ""
+func (c *compatChecker) compareElement(path string, oldField, newField map[string]interface{}, followedBy string) {
+	if oldField["type"] == "discriminated_union" && newField["type"] == "discriminated_union" {
+		c.compareUnion(path, oldField, newField)
+		return
+	}
+	c.compareAttributes(path, withoutKeys(oldField, "name", "items", "fields"), withoutKeys(newField, "name", "items", "fields"))
+
+	oldItems, _ := oldField["items"].(map[string]interface{})
+	newItems, _ := newField["items"].(map[string]interface{})
+	if oldItems != nil && newItems != nil {
+		c.compareElement(path+".items", oldItems, newItems, path)
+	}
+
+	// Inline bitfields and structs
+	oldFields, _ := oldField["fields"].([]interface{})
+	newFields, _ := newField["fields"].([]interface{})
+	if oldFields != nil && newFields != nil && oldField["type"] == newField["type"] {
+		c.compareSequence(path+".fields", oldFields, newFields, followedBy)
+	}
+}
+
+// compareAttributes reports each wire-relevant attribute that differs
+func (c *compatChecker) compareAttributes(path string, oldAttrs, newAttrs map[string]interface{}) {
+	keys := make(map[string]bool)
+	for key := range oldAttrs {
+		keys[key] = true
+	}
+	for key := range newAttrs {
+		keys[key] = true
+	}
+	for _, key := range sortedKeys(keys) {
+		if documentationAttributes[key] {
+			continue
+		}
+		oldValue, hadOld := oldAttrs[key]
+		newValue, hasNew := newAttrs[key]
+		switch {
+		case reflect.DeepEqual(oldValue, newValue):
+		case !hadOld:
+			c.breaking(path, "%s %s was added", key, describeValue(newValue))
+		case !hasNew:
+			c.breaking(path, "%s %s was removed", key, describeValue(oldValue))
+		default:
+			c.breaking(path, "%s changed from %s to %s", key, describeValue(oldValue), describeValue(newValue))
+		}
+	}
+}
+
+// compareUnion compares two versions of a discriminated union: variants may be added
+func (c *compatChecker) compareUnion(path string, oldType, newType map[string]interface{}) {
+	c.compareAttributes(path, withoutKeys(oldType, "name", "variants"), withoutKeys(newType, "name", "variants"))
+	oldVariants := variantsByType(oldType)
+	newVariants := variantsByType(newType)
+	for _, name := range sortedKeys(oldVariants) {
+		newVariant, ok := newVariants[name]
+		if !ok {
+			c.breaking(path+".variants", "variant %s was removed", name)
+			continue
+		}
+		c.compareAttributes(path+".variants."+name, oldVariants[name], newVariant)
+	}
+	for _, name := range sortedKeys(newVariants) {
+		if _, ok := oldVariants[name]; !ok {
+			c.compatible(path+".variants", "variant %s was added", name)
+		}
+	}
+}
+
+// compareInstances matches instance fields by name; they are read at positions, so order doesn't matter
+func (c *compatChecker) compareInstances(path string, oldInstances, newInstances []interface{}) {
+	for i, raw := range oldInstances {
+		oldField, _ := raw.(map[string]interface{})
+		j, newField := fieldByName(newInstances, oldField["name"])
+		if newField == nil {
+			c.breaking(fmt.Sprintf("%s[%d]", path, i), "instance %q was removed", oldField["name"])
+			continue
+		}
+		c.compareElement(fmt.Sprintf("%s[%d]", path, j), oldField, newField, fmt.Sprintf("%s[%d]", path, j))
+	}
+	for j, raw := range newInstances {
+		newField, _ := raw.(map[string]interface{})
+		if _, oldField := fieldByName(oldInstances, newField["name"]); oldField == nil {
+			c.compatible(fmt.Sprintf("%s[%d]", path, j), "instance %q was added", newField["name"])
+		}
+	}
+}
+
+// compareProtobuf matches the fields of two versions of a protobuf message by field
+// number. Unknown fields are skipped and absent ones zero, so fields may come and go.
+func (c *compatChecker) compareProtobuf(path string, oldSequence, newSequence []interface{}) {
+	for i, raw := range oldSequence {
+		oldField, _ := raw.(map[string]interface{})
+		j, newField := fieldByNumber(newSequence, oldField["field_number"])
+		if newField == nil {
+			c.compatible(fmt.Sprintf("%s[%d]", path, i), "field %q (%v) was removed; don't reuse its field number", oldField["name"], oldField["field_number"])
+			continue
+		}
+		fieldPath := fmt.Sprintf("%s[%d]", path, j)
+		if oldField["name"] != newField["name"] {
+			c.compatible(fieldPath, "field %v was renamed from %q to %q", oldField["field_number"], oldField["name"], newField["name"])
+		}
+		c.compareElement(fieldPath, oldField, newField, "")
+	}
+	for j, raw := range newSequence {
+		newField, _ := raw.(map[string]interface{})
+		if _, oldField := fieldByNumber(oldSequence, newField["field_number"]); oldField == nil {
+			c.compatible(fmt.Sprintf("%s[%d]", path, j), "field %q (%v) was added", newField["name"], newField["field_number"])
+		}
+	}
+}
+
+// embeddedTypes returns the types after which more data follows wherever they're used,
+// so fields appended to them shift what comes next: types of fields that aren't last in
+// their struct, array items, and, transitively, the last fields of such types
+func embeddedTypes(types map[string]interface{}) map[string]string {
+	embedded := make(map[string]string)
+	var mark func(fieldType, where string)
+	mark = func(fieldType, where string) {
+		typeData, ok := types[fieldType].(map[string]interface{})
+		if !ok || embedded[fieldType] != "" {
+			return
+		}
+		if protobuf, _ := typeData["protobuf"].(bool); protobuf {
+			return
+		}
+		embedded[fieldType] = where
+		if sequence, ok := typeData["sequence"].([]interface{}); ok && len(sequence) > 0 {
+			if last, ok := sequence[len(sequence)-1].(map[string]interface{}); ok {
+				lastType, _ := last["type"].(string)
+				mark(lastType, where)
+			}
+		}
+		for _, variant := range variantsByType(typeData) {
+			variantType, _ := variant["type"].(string)
+			mark(variantType, where)
+		}
+	}
+
+	for _, name := range sortedKeys(types) {
+		typeData, _ := types[name].(map[string]interface{})
+		sequence, _ := typeData["sequence"].([]interface{})
+		for i, raw := range sequence {
+			field, _ := raw.(map[string]interface{})
+			fieldName, _ := field["name"].(string)
+			where := name + "." + fieldName
+			if fieldType, _ := field["type"].(string); i < len(sequence)-1 {
+				mark(fieldType, where)
+			}
+			for items, _ := field["items"].(map[string]interface{}); items != nil; items, _ = items["items"].(map[string]interface{}) {
+				itemType, _ := items["type"].(string)
+				mark(itemType, where)
+			}
+		}
+	}
+	return embedded
+}
+
+// isOptionalField reports whether a field may be absent from data: conditional,
+// marked optional, or an optional type with a presence flag
+func isOptionalField(field map[string]interface{}) bool {
+	optional, _ := field["optional"].(bool)
+	return optional || field["conditional"] != nil || field["type"] == "optional"
+}
+
+// typeKind names what a type definition is, for messages about it changing
+func typeKind(typeData map[string]interface{}) string {
+	if protobuf, _ := typeData["protobuf"].(bool); protobuf {
+		return "a protobuf message"
+	}
+	if _, ok := typeData["sequence"]; ok {
+		return "a struct"
+	}
+	if typeData["type"] == "discriminated_union" {
+		return "a union"
+	}
+	if fieldType, ok := typeData["type"].(string); ok {
+		return fmt.Sprintf("an alias of %s", fieldType)
+	}
+	return "an unknown kind of type"
+}
+
+// variantsByType returns the variants of a union, keyed by their type
+func variantsByType(typeData map[string]interface{}) map[string]map[string]interface{} {
+	variants := make(map[string]map[string]interface{})
+	list, _ := typeData["variants"].([]interface{})
+	for _, raw := range list {
+		if variant, ok := raw.(map[string]interface{}); ok {
+			name, _ := variant["type"].(string)
+			variants[name] = variant
+		}
+	}
+	return variants
+}
+
+// fieldByName returns the index and raw field with a name, or nil
+func fieldByName(fields []interface{}, name interface{}) (int, map[string]interface{}) {
+	for i, raw := range fields {
+		if field, ok := raw.(map[string]interface{}); ok && field["name"] == name {
+			return i, field
+		}
+	}
+	return -1, nil
+}
+
+// fieldByNumber returns the index and raw field with a protobuf field number, or nil
+func fieldByNumber(fields []interface{}, number interface{}) (int, map[string]interface{}) {
+	for i, raw := range fields {
+		if field, ok := raw.(map[string]interface{}); ok && number != nil && field["field_number"] == number {
+			return i, field
+		}
+	}
+	return -1, nil
+}
+
+// withoutKeys returns a copy of a raw object without some of its attributes
+func withoutKeys(obj map[string]interface{}, keys ...string) map[string]interface{} {
+	out := make(map[string]interface{}, len(obj))
+	for key, value := range obj {
+		out[key] = value
+	}
+	for _, key := range keys {
+		delete(out, key)
+	}
+	return out
+}
+
+// describeValue renders a raw attribute value for a message
+func describeValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case map[string]interface{}, []interface{}:
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+	return fmt.Sprint(value)
+}
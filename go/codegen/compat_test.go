@@ -0,0 +1,109 @@
+// ABOUTME: Tests for CheckCompatibility between two versions of a schema
+// ABOUTME: Each change is checked by path, message and whether it breaks the wire format
+package codegen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func compatStrings(changes []CompatibilityChange) []string {
+	var out []string
+	for _, c := range changes {
+		out = append(out, c.String())
+	}
+	return out
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	oldSchema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Header": { sequence: [
+				{ name: "version", type: "uint8" },
+				{ name: "length", type: "uint16" },
+			] },
+			"Packet": { sequence: [
+				{ name: "header", type: "Header" },
+				{ name: "flags", type: "uint8" },
+				{ name: "body", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "uint8" } },
+				{ name: "crc", type: "uint16" },
+			] },
+			"Reading": { sequence: [
+				{ name: "id", type: "uint8", description: "Sensor id" },
+			] },
+			"Trailer": { sequence: [ { name: "a", type: "uint8" } ] },
+			"Shape": { type: "discriminated_union", discriminator: { peek: "uint8" }, variants: [
+				{ type: "Reading", when: "value == 1" },
+				{ type: "Trailer", when: "value == 2" },
+			] },
+		},
+	}`)
+	newSchema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Header": { sequence: [
+				{ name: "version", type: "uint8" },
+				{ name: "length", type: "uint32" },
+				{ name: "ext", type: "uint8", conditional: "version > 1" },
+			] },
+			"Packet": { sequence: [
+				{ name: "flags", type: "uint8" },
+				{ name: "header", type: "Header" },
+				{ name: "body", type: "array", kind: "length_prefixed", length_type: "uint16", items: { type: "uint8" } },
+				{ name: "crc", type: "uint16" },
+				{ name: "trailer", type: "uint8" },
+			] },
+			"Reading": { sequence: [
+				{ name: "id", type: "uint8", description: "Sensor identifier" },
+				{ name: "extra", type: "uint16", optional: true },
+			] },
+			"Status": { sequence: [ { name: "code", type: "uint8" } ] },
+			"Shape": { type: "discriminated_union", discriminator: { peek: "uint8" }, variants: [
+				{ type: "Reading", when: "value == 3" },
+				{ type: "Status", when: "value == 2" },
+			] },
+		},
+	}`)
+
+	require.Equal(t, []string{
+		`breaking: types.Header.sequence[1]: type changed from "uint16" to "uint32"`,
+		`breaking: types.Header.sequence[2]: field "ext" was appended, but more data follows in Packet.header`,
+		`breaking: types.Packet.sequence[0]: field "flags" was moved before fields it used to follow`,
+		`breaking: types.Packet.sequence[2]: length_type changed from "uint8" to "uint16"`,
+		`breaking: types.Packet.sequence[4]: field "trailer" was appended but isn't optional: data from the old schema lacks it`,
+		`compatible: types.Reading.sequence[1]: optional field "extra" was appended`,
+		`breaking: types.Shape.variants: variant Trailer was removed`,
+		`compatible: types.Shape.variants: variant Status was added`,
+		`breaking: types.Shape.variants.Reading: when changed from "value == 1" to "value == 3"`,
+		`compatible: types.Status: type Status was added`,
+		`breaking: types.Trailer: type Trailer was removed`,
+	}, compatStrings(CheckCompatibility(oldSchema, newSchema)))
+
+	require.Empty(t, CheckCompatibility(oldSchema, oldSchema))
+}
+
+func TestCheckCompatibilityProtobuf(t *testing.T) {
+	oldSchema := parseTestSchema(t, `{ types: {
+		"Message": { protobuf: true, sequence: [
+			{ name: "a", type: "int32", field_number: 1 },
+			{ name: "b", type: "string", field_number: 2 },
+			{ name: "c", type: "uint64", field_number: 3 },
+		] },
+	} }`)
+	newSchema := parseTestSchema(t, `{ types: {
+		"Message": { protobuf: true, sequence: [
+			{ name: "c", type: "uint64", field_number: 3, proto_type: "fixed64" },
+			{ name: "alpha", type: "int32", field_number: 1 },
+			{ name: "d", type: "float32", field_number: 4 },
+		] },
+	} }`)
+
+	require.Equal(t, []string{
+		`breaking: types.Message.sequence[0]: proto_type "fixed64" was added`,
+		`compatible: types.Message.sequence[1]: field 1 was renamed from "a" to "alpha"`,
+		`compatible: types.Message.sequence[1]: field "b" (2) was removed; don't reuse its field number`,
+		`compatible: types.Message.sequence[2]: field "d" (4) was added`,
+	}, compatStrings(CheckCompatibility(oldSchema, newSchema)))
+}
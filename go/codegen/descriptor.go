@@ -0,0 +1,245 @@
+// ABOUTME: Generates the runtime.SchemaInfo descriptor returned by the generated Schema() function
+// ABOUTME: Lets tools introspect generated types (fields, widths, kinds, metadata) without the schema file
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// descriptorFuncName returns the name of the generated descriptor accessor.
+// It is Schema() unless a schema type already uses that name. Like the types, it
+// takes the type prefix and suffix (V1Schema), so schemas can share a package.
+func descriptorFuncName(schema *Schema, opts GenerateOptions) string {
+	if _, taken := schema.Types[goTypeName(opts, "Schema")]; taken {
+		return goTypeName(opts, "SchemaDescriptor")
+	}
+	return goTypeName(opts, "Schema")
+}
+
+// generateSchemaDescriptor emits a package-level runtime.SchemaInfo and its accessor,
+// which hands out copies so no caller can change what the others see
+func generateSchemaDescriptor(buf *bytes.Buffer, schema *Schema, defaultEndianness string, opts GenerateOptions) error {
+	funcName := descriptorFuncName(schema, opts)
+	infoVar := "schemaInfo" + opts.TypePrefix + opts.TypeSuffix
+	buf.WriteString(fmt.Sprintf("// %s describes the schema this package was generated from. Each call returns\n", funcName))
+	buf.WriteString("// a copy, which the caller may change\n")
+	buf.WriteString(fmt.Sprintf("func %s() *runtime.SchemaInfo {\n", funcName))
+	buf.WriteString(fmt.Sprintf("\treturn %s.Clone()\n", infoVar))
+	buf.WriteString("}\n\n")
+
+	bitOrder := "msb_first"
+	if schema.Config != nil && schema.Config.BitOrder != "" {
+		bitOrder = schema.Config.BitOrder
+	}
+
+	buf.WriteString(fmt.Sprintf("var %s = runtime.SchemaInfo{\n", infoVar))
+	if schema.Meta != nil {
+		writeStringAttr(buf, "\t", "Title", schema.Meta.Title)
+		writeStringAttr(buf, "\t", "Description", schema.Meta.Description)
+		writeStringAttr(buf, "\t", "Version", schema.Meta.Version)
+	}
+	writeStringAttr(buf, "\t", "Endianness", defaultEndianness)
+	writeStringAttr(buf, "\t", "BitOrder", bitOrder)
+
+	names := make([]string, 0, len(schema.Types))
+	for name := range schema.Types {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return schema.schemaName(names[i]) < schema.schemaName(names[j]) })
+
+	buf.WriteString("\tTypes: []runtime.TypeInfo{\n")
+	for _, name := range names {
+		typeDef := schema.Types[name]
+		buf.WriteString("\t\t{\n")
+		writeStringAttr(buf, "\t\t\t", "Name", schema.schemaName(name))
+		writeStringAttr(buf, "\t\t\t", "GoName", capitalizeFirst(name))
+		writeStringAttr(buf, "\t\t\t", "Description", typeDef.Description)
+		if width := typeWidth(schema, name, map[string]bool{}); width > 0 {
+			buf.WriteString(fmt.Sprintf("\t\t\tWidth: %d,\n", width))
+		}
+		if len(typeDef.Sequence) > 0 {
+			buf.WriteString("\t\t\tFields: []runtime.FieldInfo{\n")
+			for _, field := range typeDef.Sequence {
+				buf.WriteString("\t\t\t\t{\n")
+				if err := writeFieldInfo(buf, schema, field, defaultEndianness, "\t\t\t\t\t"); err != nil {
+					return fmt.Errorf("%s.%s: %w", name, field.Name, err)
+				}
+				buf.WriteString("\t\t\t\t},\n")
+			}
+			buf.WriteString("\t\t\t},\n")
+		}
+		buf.WriteString("\t\t},\n")
+	}
+	buf.WriteString("\t},\n")
+	buf.WriteString("}\n")
+	return nil
+}
+
+// writeFieldInfo writes the attributes of a runtime.FieldInfo literal
+func writeFieldInfo(buf *bytes.Buffer, schema *Schema, field Field, defaultEndianness, indent string) error {
+	writeStringAttr(buf, indent, "Name", field.Name)
+	writeStringAttr(buf, indent, "GoName", field.goName())
+	writeStringAttr(buf, indent, "Type", schema.schemaName(field.Type))
+	writeStringAttr(buf, indent, "Kind", field.Kind)
+	if width := fieldWidth(schema, field, map[string]bool{}); width > 0 {
+		buf.WriteString(fmt.Sprintf("%sWidth: %d,\n", indent, width))
+	}
+	endianness := field.Endianness
+	if endianness == "" {
+		endianness = defaultEndianness
+	}
+	writeStringAttr(buf, indent, "Endianness", endianness)
+	writeStringAttr(buf, indent, "LengthType", field.LengthType)
+	if length, ok := field.Length.(float64); ok {
+		buf.WriteString(fmt.Sprintf("%sLength: %d,\n", indent, int(length)))
+	}
+	writeStringAttr(buf, indent, "Conditional", field.Conditional)
+	if field.Optional {
+		buf.WriteString(fmt.Sprintf("%sOptional: true,\n", indent))
+	}
+	writeStringAttr(buf, indent, "Description", field.Description)
+	if len(field.Metadata) > 0 {
+		literal, err := goLiteral(field.Metadata)
+		if err != nil {
+			return fmt.Errorf("metadata: %w", err)
+		}
+		buf.WriteString(fmt.Sprintf("%sMetadata: %s,\n", indent, literal))
+	}
+	if field.Items != nil {
+		buf.WriteString(fmt.Sprintf("%sItems: &runtime.FieldInfo{\n", indent))
+		if err := writeFieldInfo(buf, schema, *field.Items, endianness, indent+"\t"); err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("%s},\n", indent))
+	}
+	return nil
+}
+
+// writeStringAttr writes a string struct attribute, omitting empty values
+func writeStringAttr(buf *bytes.Buffer, indent, name, value string) {
+	if value != "" {
+		buf.WriteString(fmt.Sprintf("%s%s: %q,\n", indent, name, value))
+	}
+}
+
+// fieldWidth returns a field's encoded size in bits, or 0 if it varies
+func fieldWidth(schema *Schema, field Field, visiting map[string]bool) int {
+	if field.Conditional != "" || field.Optional || field.Codec != "" {
+		return 0
+	}
+	switch field.Type {
+	case "bit", "int":
+		return field.Size
+	case "bitfield":
+		if field.Size > 0 {
+			return field.Size
+		}
+		total := 0
+		for _, sub := range field.Fields {
+			total += max(sub.Size, 1)
+		}
+		return total
+	case "enum", "flags":
+		return fieldWidth(schema, Field{Type: field.Repr}, visiting)
+	case "uint8", "int8", "bool":
+		return 8
+	case "uint16", "int16":
+		return 16
+	case "uint32", "int32", "float32":
+		return 32
+	case "uint64", "int64", "float64":
+		return 64
+	case "uint128", "int128", "uuid", "ipv6":
+		return 128
+	case "ipv4", "unix32":
+		return 32
+	case "unix64", "unix_milli", "ntp":
+		return 64
+	case "bcd", "packed_bcd":
+		return 8 * bcdBytes(field.Digits, field.Type == "packed_bcd")
+	case "mac":
+		return 48
+	case "string", "bytes", "array":
+		length, ok := field.Length.(float64)
+		if field.Kind != "fixed" || !ok {
+			return 0
+		}
+		if field.Type != "array" {
+			return int(length) * 8
+		}
+		if field.Items == nil {
+			return 0
+		}
+		return int(length) * fieldWidth(schema, *field.Items, visiting)
+	}
+	if _, isType := schema.Types[field.Type]; isType {
+		return typeWidth(schema, field.Type, visiting)
+	}
+	return 0
+}
+
+// typeWidth returns a type's encoded size in bits if every field is fixed-size, else 0
+func typeWidth(schema *Schema, name string, visiting map[string]bool) int {
+	typeDef := schema.Types[name]
+	if typeDef.Flags != nil {
+		return fieldWidth(schema, Field{Type: typeDef.Repr}, visiting)
+	}
+	if visiting[name] || len(typeDef.Sequence) == 0 || typeDef.Protobuf {
+		return 0
+	}
+	visiting[name] = true
+	defer delete(visiting, name)
+
+	total := 0
+	for _, field := range typeDef.Sequence {
+		width := fieldWidth(schema, field, visiting)
+		if width == 0 {
+			return 0
+		}
+		total += width
+	}
+	return total
+}
+
+// goLiteral renders a JSON value (as decoded into interface{}) as a Go expression
+func goLiteral(v interface{}) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "nil", nil
+	case bool:
+		return fmt.Sprintf("%t", x), nil
+	case string:
+		return fmt.Sprintf("%q", x), nil
+	case float64:
+		return fmt.Sprintf("float64(%v)", x), nil
+	case []interface{}:
+		items := make([]string, len(x))
+		for i, item := range x {
+			literal, err := goLiteral(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = literal
+		}
+		return "[]interface{}{" + strings.Join(items, ", ") + "}", nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		entries := make([]string, len(keys))
+		for i, k := range keys {
+			literal, err := goLiteral(x[k])
+			if err != nil {
+				return "", err
+			}
+			entries[i] = fmt.Sprintf("%q: %s", k, literal)
+		}
+		return "map[string]interface{}{" + strings.Join(entries, ", ") + "}", nil
+	}
+	return "", fmt.Errorf("unsupported value %v (%T)", v, v)
+}
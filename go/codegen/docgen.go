@@ -0,0 +1,816 @@
+// ABOUTME: Generates protocol documentation from a schema as Markdown or HTML, a sibling backend of GenerateGo
+// ABOUTME: Field tables with offsets and bit widths, enum, flag and union tables, and RFC-style ASCII packet diagrams
+package codegen
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DocsOptions configures the generated documentation
+type DocsOptions struct {
+	// Format is "markdown" (default) or "html"
+	Format string
+	// Title heads the document. Defaults to the schema's meta title, then protocol
+	// name, then "Protocol".
+	Title string
+}
+
+// GenerateDocs renders a schema as protocol documentation, one section per type.
+//
+// Structs get a table of their fields with offsets (in bytes, "byte.bit" inside a
+// byte) and widths in bits, known until the first field of variable size or
+// presence, and an RFC-style packet diagram of the fields up to there, as text in
+// Markdown and as an SVG figure in HTML. Bitfield subfields get rows of their own.
+// Enums and flag sets list their values, and unions the variant chosen for each
+// discriminator value.
+func GenerateDocs(schemaData map[string]interface{}, opts DocsOptions) (string, error) {
+	if err := schemaError(ValidateSchema(schemaData)); err != nil {
+		return "", err
+	}
+	format := opts.Format
+	if format == "" {
+		format = "markdown"
+	}
+	if format != "markdown" && format != "html" {
+		return "", fmt.Errorf("unsupported format %q (want markdown or html)", format)
+	}
+
+	g, err := newDocGen(schemaData)
+	if err != nil {
+		return "", err
+	}
+	page := docPage{title: opts.Title}
+	if page.title == "" {
+		page.title = schemaTitle(schemaData, "Protocol")
+	}
+	if meta, ok := schemaData["meta"].(map[string]interface{}); ok {
+		page.version, _ = meta["version"].(string)
+		page.description, _ = meta["description"].(string)
+	}
+	if g.endianness != "big_endian" || g.bitOrder != "msb_first" {
+		page.layout = fmt.Sprintf("Multi-byte values are %s and bits are packed %s.", strings.ReplaceAll(g.endianness, "_", " "), strings.ReplaceAll(g.bitOrder, "_", " "))
+	}
+	for _, name := range sortedKeys(g.types) {
+		if def, ok := g.types[name].(map[string]interface{}); ok {
+			page.sections = append(page.sections, g.section(name, def))
+		}
+	}
+
+	if format == "html" {
+		return renderDocsHTML(page), nil
+	}
+	return renderDocsMarkdown(page), nil
+}
+
+// docPage is the documentation of a schema, before rendering
+type docPage struct {
+	title, version, description string
+	layout                      string // Byte and bit order, when not the defaults
+	sections                    []docSection
+}
+
+// docSection documents one type
+type docSection struct {
+	name        string
+	description string
+	summary     string        // One line: what kind of type it is and its size
+	summaryLink string        // Type the summary names, linked to its section
+	spans       []diagramSpan // Fields of a struct's packet diagram, if it has one
+	columns     []string      // Table header
+	rows        [][]docCell
+}
+
+// docCell is one table cell: text with `code` spans, linked to a type's section when
+// link is set
+type docCell struct {
+	text string
+	link string
+}
+
+// docGen walks the schema's types, in the typed model for their layout and in the
+// schema's definitions for the rest
+type docGen struct {
+	types      map[string]interface{}
+	schema     *Schema
+	endianness string
+	bitOrder   string
+}
+
+// diagramSpan is a field in a packet diagram: bits [start, end) of the struct.
+// Variable-size fields end the diagram at the end of their row. A field whose bits
+// lsb_first packing scatters is drawn as several spans, each but the first continuing
+// the span before it.
+type diagramSpan struct {
+	label      string
+	start, end int
+	variable   bool
+	continues  bool
+}
+
+func newDocGen(schemaData map[string]interface{}) (*docGen, error) {
+	schema, err := parseSchema(schemaData)
+	if err != nil {
+		return nil, err
+	}
+	resolveBitOrders(schema)
+	types, _ := schemaData["types"].(map[string]interface{})
+	g := &docGen{types: types, schema: schema, endianness: "big_endian", bitOrder: "msb_first"}
+	if config, ok := schemaData["config"].(map[string]interface{}); ok {
+		if e, _ := config["endianness"].(string); e != "" {
+			g.endianness = e
+		}
+		if o, _ := config["bit_order"].(string); o != "" {
+			g.bitOrder = o
+		}
+	}
+	return g, nil
+}
+
+func (g *docGen) section(name string, def map[string]interface{}) docSection {
+	s := docSection{name: name}
+	s.description, _ = def["description"].(string)
+	if _, ok := def["sequence"].([]interface{}); ok {
+		g.structSection(&s, g.schema.Types[name], def)
+		return s
+	}
+
+	elemType, _ := def["type"].(string)
+	switch elemType {
+	case "enum", "flags":
+		repr, _ := def["repr"].(string)
+		variants, _ := def["variants"].(map[string]interface{})
+		if elemType == "enum" {
+			s.summary = fmt.Sprintf("Enum stored as `%s`.", repr)
+			s.columns = []string{"Value", "Name"}
+		} else {
+			s.summary = fmt.Sprintf("Flag set stored as `%s`.", repr)
+			s.columns = []string{"Mask", "Flag"}
+		}
+		for _, variant := range sortedVariants(variants) {
+			value, _ := variants[variant].(float64)
+			text := strconv.FormatInt(int64(value), 10)
+			if elemType == "flags" {
+				text = fmt.Sprintf("%#x", uint64(value))
+			}
+			s.rows = append(s.rows, []docCell{{text: text}, {text: "`" + variant + "`"}})
+		}
+	case "discriminated_union":
+		s.summary = "Union " + unionDiscriminator(def) + "."
+		s.columns = []string{"When", "Type", "Description"}
+		variants, _ := def["variants"].([]interface{})
+		for _, raw := range variants {
+			variant, _ := raw.(map[string]interface{})
+			variantType, _ := variant["type"].(string)
+			when := "otherwise"
+			if w, ok := variant["when"].(string); ok {
+				when = "`" + w + "`"
+			}
+			description, _ := variant["description"].(string)
+			s.rows = append(s.rows, []docCell{{text: when}, g.typeCell(variantType), {text: description}})
+		}
+	case "choice":
+		s.summary = "Choice of the variant whose leading constant field matches the next bytes."
+		s.columns = []string{"Starts with", "Type"}
+		choices, _ := def["choices"].([]interface{})
+		for _, raw := range choices {
+			choice, _ := raw.(map[string]interface{})
+			choiceType, _ := choice["type"].(string)
+			s.rows = append(s.rows, []docCell{{text: g.leadingConst(choiceType)}, g.typeCell(choiceType)})
+		}
+	default:
+		cell := g.describe(def)
+		s.summary, s.summaryLink = "Alias of "+cell.text+".", cell.link
+	}
+	return s
+}
+
+// sortedVariants returns the names of enum or flag variants by value, then name
+func sortedVariants(variants map[string]interface{}) []string {
+	names := make([]string, 0, len(variants))
+	for name := range variants {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, _ := variants[names[i]].(float64)
+		b, _ := variants[names[j]].(float64)
+		return a < b || (a == b && names[i] < names[j])
+	})
+	return names
+}
+
+// structSection lays out a struct's fields: the field table and the packet diagram.
+// The layout comes from the typed model of the struct, the text of the table from the
+// schema's own definitions, whose fields are in the same order.
+func (g *docGen) structSection(s *docSection, typeDef *TypeDef, def map[string]interface{}) {
+	s.columns = []string{"Offset", "Bits", "Field", "Type", "Description"}
+	sequence := docFields(def["sequence"])
+	var spans []diagramSpan
+	diagramOpen := true
+	pos, known := 0, true
+	for i, field := range typeDef.Sequence {
+		bits, fixed := g.bits(field)
+		if field.Type == "padding" && known {
+			align := max(field.AlignTo, 1) * 8
+			bits, fixed = (align-pos%align)%align, true
+		}
+		conditional := field.Conditional != ""
+		s.rows = append(s.rows, []docCell{
+			{text: docOffset(pos, known)}, {text: docBits(bits, fixed)}, {text: "`" + field.Name + "`"},
+			g.describe(sequence[i]), {text: g.fieldDescription(sequence[i])},
+		})
+
+		if field.Type == "bitfield" {
+			at := pos
+			for _, sub := range field.Fields {
+				size := max(sub.Size, 1)
+				s.rows = append(s.rows, []docCell{
+					{text: docOffset(at, known)}, {text: strconv.Itoa(size)}, {text: "`" + field.Name + "." + sub.Name + "`"},
+					{text: "bits"}, {text: sub.Description},
+				})
+				if diagramOpen && known && !conditional {
+					spans = append(spans, diagramSpan{label: sub.Name, start: at, end: at + size})
+				}
+				at += size
+			}
+		}
+
+		if diagramOpen {
+			switch {
+			case conditional || !fixed:
+				end := (pos/diagramWidth + 1) * diagramWidth
+				spans = append(spans, diagramSpan{label: field.Name, start: pos, end: end, variable: true})
+				diagramOpen = false
+			case field.Type == "bitfield" && len(field.Fields) > 0:
+			case bits > 0:
+				spans = append(spans, diagramSpan{label: field.Name, start: pos, end: pos + bits})
+			}
+		}
+		if conditional || !fixed {
+			known = false
+		}
+		pos += bits
+	}
+
+	if known {
+		s.summary = "Struct of " + docSize(pos) + "."
+	} else {
+		s.summary = "Struct of variable size."
+	}
+	instances := docFields(def["instances"])
+	for i, field := range typeDef.Instances {
+		bits, fixed := g.bits(field)
+		s.rows = append(s.rows, []docCell{
+			{text: "at `" + fmt.Sprint(field.Position) + "`"}, {text: docBits(bits, fixed)}, {text: "`" + field.Name + "`"},
+			g.describe(instances[i]), {text: g.fieldDescription(instances[i])},
+		})
+	}
+	if typeDef.BitOrder == "lsb_first" {
+		spans = lsbFirstSpans(spans)
+	}
+	if len(spans) > 0 && !spans[0].variable {
+		s.spans = spans
+	}
+}
+
+// docFields returns the field definitions of a sequence or instance list, skipping
+// entries that aren't objects as parseSchema does
+func docFields(raw interface{}) []map[string]interface{} {
+	list, _ := raw.([]interface{})
+	var fields []map[string]interface{}
+	for _, entry := range list {
+		if field, ok := entry.(map[string]interface{}); ok {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// bits returns a field's width in bits, and false when it depends on the data. A
+// conditional field has its width when present.
+func (g *docGen) bits(field Field) (int, bool) {
+	field.Conditional = ""
+	width := fieldWidth(g.schema, field, map[string]bool{})
+	return width, width > 0
+}
+
+// describe returns the type column of an element: its type and how its size is found
+func (g *docGen) describe(def map[string]interface{}) docCell {
+	elemType, _ := def["type"].(string)
+	var text string
+	switch elemType {
+	case "string", "bytes":
+		text = elemType
+		if encoding, _ := def["encoding"].(string); encoding != "" && encoding != "utf8" {
+			text = encoding + " " + text
+		}
+		if kind := docKind(def, "length"); kind != "" {
+			text += ", " + kind
+		}
+	case "array":
+		items, _ := def["items"].(map[string]interface{})
+		item := g.describe(items)
+		text = "array of " + item.text
+		if kind := docKind(def, "count"); kind != "" {
+			text += ", " + kind
+		}
+		return docCell{text: text, link: item.link}
+	case "optional":
+		valueType, _ := def["value_type"].(string)
+		cell := g.typeCell(valueType)
+		presence := "presence byte"
+		if def["presence_type"] == "bit" {
+			presence = "presence bit"
+		}
+		return docCell{text: "optional " + cell.text + ", after a " + presence, link: cell.link}
+	case "enum", "flags", "back_reference":
+		text = elemType
+		if repr, _ := def["repr"].(string); repr != "" {
+			text += " " + repr
+		}
+		if storage, _ := def["storage"].(string); storage != "" {
+			text += " " + storage
+		}
+	case "varlength":
+		encoding, _ := def["encoding"].(string)
+		if encoding == "" {
+			encoding = "der"
+		}
+		text = "varlength, " + encoding
+	case "padding":
+		text = fmt.Sprintf("padding to %d bytes", intAttr(def, "align_to", 1))
+	case "discriminated_union":
+		text = "union " + unionDiscriminator(def)
+	case "choice":
+		text = "choice"
+	default:
+		return g.typeCell(elemType)
+	}
+	if e, _ := def["endianness"].(string); e != "" && e != g.endianness && integerBytes(elemType) != 1 {
+		text += ", " + strings.ReplaceAll(e, "_", " ")
+	}
+	return docCell{text: text}
+}
+
+// typeCell names a type, linked to its section when the schema defines it
+func (g *docGen) typeCell(name string) docCell {
+	if _, ok := g.types[name]; ok {
+		return docCell{text: name, link: name}
+	}
+	return docCell{text: name}
+}
+
+// docKind describes how a string, byte array or array finds its length, in units of unit
+func docKind(def map[string]interface{}, unit string) string {
+	kind, _ := def["kind"].(string)
+	lengthType, _ := def["length_type"].(string)
+	switch kind {
+	case "fixed":
+		if field, ok := def["length_field"].(string); ok && def["length"] == nil {
+			return fmt.Sprintf("%s in `%s`", unit, field)
+		}
+		if unit == "count" {
+			return fmt.Sprintf("%d items", intAttr(def, "length", 0))
+		}
+		return fmt.Sprintf("%d bytes", intAttr(def, "length", 0))
+	case "length_prefixed":
+		return fmt.Sprintf("`%s` %s prefix", lengthType, unit)
+	case "length_prefixed_items":
+		itemLengthType, _ := def["item_length_type"].(string)
+		return fmt.Sprintf("`%s` count prefix, `%s` length before each item", lengthType, itemLengthType)
+	case "byte_length_prefixed":
+		return fmt.Sprintf("`%s` byte length prefix", lengthType)
+	case "null_terminated":
+		return "null-terminated"
+	case "signature_terminated":
+		return fmt.Sprintf("ends at `%s` %v", def["terminator_type"], def["terminator_value"])
+	case "eof_terminated":
+		return "to the end of the input"
+	case "field_referenced":
+		return fmt.Sprintf("%s in `%s`", unit, def["length_field"])
+	case "variant_terminated":
+		var variants []string
+		terminal, _ := def["terminal_variants"].([]interface{})
+		for _, v := range terminal {
+			variants = append(variants, fmt.Sprint(v))
+		}
+		return "ends after a " + strings.Join(variants, " or ")
+	case "computed_count":
+		return fmt.Sprintf("%s `%s`", unit, def["count_expr"])
+	}
+	return ""
+}
+
+// fieldDescription returns the description column of a field: its schema description,
+// when it is present, its constant or computed value, its enum values and its metadata
+func (g *docGen) fieldDescription(field map[string]interface{}) string {
+	var parts []string
+	if condition, ok := field["conditional"].(string); ok {
+		parts = append(parts, fmt.Sprintf("Present if `%s`.", condition))
+	}
+	if description, _ := field["description"].(string); description != "" {
+		parts = append(parts, description)
+	}
+	if value, ok := field["const"]; ok {
+		parts = append(parts, fmt.Sprintf("Always `%s`.", metadataValue(value)))
+	}
+	if computed, ok := field["computed"].(map[string]interface{}); ok {
+		what, _ := computed["type"].(string)
+		if target, ok := computed["target"].(string); ok {
+			what += " of `" + target + "`"
+		} else if byteRange := parseByteRange(computed); byteRange != nil {
+			from, to := "the start", "the end"
+			if byteRange.From != "" {
+				from = "`" + byteRange.From + "`"
+			}
+			if byteRange.To != "" {
+				to = "`" + byteRange.To + "`"
+			}
+			what += " the bytes from " + from + " through " + to
+		}
+		parts = append(parts, "Computed: "+what+".")
+	}
+	if field["type"] == "enum" {
+		variants, _ := field["variants"].(map[string]interface{})
+		var values []string
+		for _, name := range sortedVariants(variants) {
+			values = append(values, fmt.Sprintf("%s = `%s`", metadataValue(variants[name]), name))
+		}
+		parts = append(parts, "Values: "+strings.Join(values, ", ")+".")
+	}
+	metadata, _ := field["metadata"].(map[string]interface{})
+	parts = append(parts, metadataDocs(metadata)...)
+	return strings.Join(parts, " ")
+}
+
+// leadingConst returns the constant a choice variant starts with
+func (g *docGen) leadingConst(name string) string {
+	typeDef, _ := g.types[name].(map[string]interface{})
+	sequence, _ := typeDef["sequence"].([]interface{})
+	if len(sequence) == 0 {
+		return ""
+	}
+	first, _ := sequence[0].(map[string]interface{})
+	value, ok := first["const"]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("`%s` %s", first["type"], metadataValue(value))
+}
+
+// unionDiscriminator describes what chooses a union's variant
+func unionDiscriminator(def map[string]interface{}) string {
+	disc, _ := def["discriminator"].(map[string]interface{})
+	if peek, ok := disc["peek"].(string); ok {
+		return fmt.Sprintf("chosen by the next `%s`", peek)
+	}
+	if field, ok := disc["field"].(string); ok {
+		return fmt.Sprintf("chosen by `%s`", field)
+	}
+	return "without a discriminator"
+}
+
+// docOffset formats a bit position as bytes, with ".bit" inside a byte
+func docOffset(pos int, known bool) string {
+	if !known {
+		return "—"
+	}
+	if pos%8 == 0 {
+		return strconv.Itoa(pos / 8)
+	}
+	return fmt.Sprintf("%d.%d", pos/8, pos%8)
+}
+
+func docBits(bits int, fixed bool) string {
+	if !fixed {
+		return "var"
+	}
+	return strconv.Itoa(bits)
+}
+
+// docSize formats a struct's size in bytes, or in bits when it isn't whole bytes
+func docSize(bits int) string {
+	if bits%8 != 0 {
+		return fmt.Sprintf("%d bits", bits)
+	}
+	if bits == 8 {
+		return "1 byte"
+	}
+	return fmt.Sprintf("%d bytes", bits/8)
+}
+
+// diagramWidth is the bits in a row of a packet diagram, as in RFCs
+const diagramWidth = 32
+
+// packetDiagram draws fields as an RFC-style diagram: 32-bit rows, two columns a bit,
+// with the bit numbers above. Fields running into the next row leave the line between
+// the rows open.
+func packetDiagram(spans []diagramSpan) []string {
+	total := diagramBits(spans)
+	width := total
+	if width > diagramWidth {
+		width = diagramWidth
+	}
+	tens := []byte(strings.Repeat(" ", 2*width+1))
+	units := []byte(strings.Repeat(" ", 2*width+1))
+	for b := 0; b < width; b++ {
+		if b%10 == 0 {
+			tens[2*b+1] = byte('0' + b/10)
+		}
+		units[2*b+1] = byte('0' + b%10)
+	}
+	lines := []string{strings.TrimRight(string(tens), " "), strings.TrimRight(string(units), " ")}
+	lines = append(lines, "+"+strings.Repeat("-+", width))
+
+	for row := 0; row*diagramWidth < total; row++ {
+		first := row * diagramWidth
+		rowWidth := total - first
+		if rowWidth > diagramWidth {
+			rowWidth = diagramWidth
+		}
+		content := []byte(strings.Repeat(" ", 2*rowWidth+1))
+		content[0], content[2*rowWidth] = '|', '|'
+		for _, span := range spans {
+			start, end := span.start-first, span.end-first
+			if end <= 0 || start >= rowWidth {
+				continue
+			}
+			if start >= 0 {
+				content[2*start] = '|'
+			} else {
+				start = 0
+			}
+			if end > rowWidth {
+				end = rowWidth
+			}
+			// The label goes in the first row of the field
+			if span.start >= first {
+				cell := 2*(end-start) - 1
+				label := span.label
+				if span.variable {
+					label += " ..."
+				}
+				if len(label) > cell {
+					label = label[:cell]
+				}
+				copy(content[2*start+1+(cell-len(label))/2:], label)
+			}
+		}
+		lines = append(lines, string(content))
+
+		separator := []byte(strings.Repeat(" ", 2*rowWidth+1))
+		separator[0], separator[2*rowWidth] = '+', '+'
+		// The line is open below a bit whose field has the bit under it too
+		open := func(b int) bool {
+			i := fieldAt(spans, first+b)
+			return i >= 0 && first+diagramWidth+b < total && fieldAt(spans, first+diagramWidth+b) == i
+		}
+		for b := 0; b < rowWidth; b++ {
+			if !open(b) {
+				separator[2*b+1] = '-'
+			}
+			if b > 0 && (!open(b) || !open(b-1) || fieldAt(spans, first+b) != fieldAt(spans, first+b-1)) {
+				separator[2*b] = '+'
+			}
+		}
+		lines = append(lines, string(separator))
+	}
+	return lines
+}
+
+// spanAt returns the index of the span holding bit, or -1
+func spanAt(spans []diagramSpan, bit int) int {
+	for i, span := range spans {
+		if bit >= span.start && bit < span.end {
+			return i
+		}
+	}
+	return -1
+}
+
+// fieldAt returns the index of the first span of the field holding bit, or -1, so the
+// spans of a field lsb_first packing scatters compare equal
+func fieldAt(spans []diagramSpan, bit int) int {
+	i := spanAt(spans, bit)
+	for i > 0 && spans[i].continues {
+		i--
+	}
+	return i
+}
+
+// diagramBits returns the bits a diagram draws, up to the end of its last span
+func diagramBits(spans []diagramSpan) int {
+	total := 0
+	for _, span := range spans {
+		total = max(total, span.end)
+	}
+	return total
+}
+
+// lsbFirstSpans moves spans laid out in the order bits are packed to the columns
+// lsb_first packing puts them in: the diagram numbers the bits of a byte from the most
+// significant, and bit k of a byte is packed kth. A field that starts or ends inside a
+// byte and crosses into another becomes one span, labelled alike, for each run of
+// adjacent columns.
+func lsbFirstSpans(spans []diagramSpan) []diagramSpan {
+	var moved []diagramSpan
+	for _, span := range spans {
+		columns := make([]int, 0, span.end-span.start)
+		for bit := span.start; bit < span.end; bit++ {
+			columns = append(columns, bit/8*8+7-bit%8)
+		}
+		sort.Ints(columns)
+		for i := 0; i < len(columns); {
+			j := i + 1
+			for j < len(columns) && columns[j] == columns[j-1]+1 {
+				j++
+			}
+			moved = append(moved, diagramSpan{label: span.label, start: columns[i], end: columns[j-1] + 1, variable: span.variable, continues: i > 0})
+			i = j
+		}
+	}
+	return moved
+}
+
+// docAnchor returns the fragment a type's section is linked with, as GitHub derives
+// it from the heading
+func docAnchor(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
+
+func renderDocsMarkdown(page docPage) string {
+	var out strings.Builder
+	out.WriteString("# " + page.title + "\n\n")
+	if page.version != "" {
+		out.WriteString("Version " + page.version + "\n\n")
+	}
+	if page.description != "" {
+		out.WriteString(page.description + "\n\n")
+	}
+	if page.layout != "" {
+		out.WriteString(page.layout + "\n\n")
+	}
+	for _, s := range page.sections {
+		out.WriteString(fmt.Sprintf("- [%s](#%s)\n", s.name, docAnchor(s.name)))
+	}
+	for _, s := range page.sections {
+		out.WriteString("\n## " + s.name + "\n\n")
+		if s.description != "" {
+			out.WriteString(s.description + "\n\n")
+		}
+		summary := s.summary
+		if s.summaryLink != "" {
+			summary = linkType(summary, s.summaryLink, fmt.Sprintf("[%s](#%s)", s.summaryLink, docAnchor(s.summaryLink)))
+		}
+		out.WriteString(summary + "\n")
+		if len(s.spans) > 0 {
+			out.WriteString("\n```text\n" + strings.Join(packetDiagram(s.spans), "\n") + "\n```\n")
+		}
+		if len(s.rows) == 0 {
+			continue
+		}
+		out.WriteString("\n| " + strings.Join(s.columns, " | ") + " |\n|")
+		for _, column := range s.columns {
+			if column == "Offset" || column == "Bits" {
+				out.WriteString(" ---: |")
+			} else {
+				out.WriteString(" --- |")
+			}
+		}
+		out.WriteString("\n")
+		for _, row := range s.rows {
+			out.WriteString("|")
+			for _, cell := range row {
+				text := strings.NewReplacer("|", `\|`, "\n", " ").Replace(cell.text)
+				if cell.link != "" {
+					text = linkType(text, cell.link, fmt.Sprintf("[%s](#%s)", cell.link, docAnchor(cell.link)))
+				}
+				out.WriteString(" " + text + " |")
+			}
+			out.WriteString("\n")
+		}
+	}
+	return out.String()
+}
+
+// linkType replaces the type name in a type column's text with its link: the first
+// occurrence that is a whole word, as in "array of Label, null-terminated"
+func linkType(text, name, link string) string {
+	for from := 0; ; {
+		i := strings.Index(text[from:], name)
+		if i < 0 {
+			return text
+		}
+		i += from
+		end := i + len(name)
+		if (i == 0 || text[i-1] == ' ') && (end == len(text) || text[end] == ',' || text[end] == ' ') {
+			return text[:i] + link + text[end:]
+		}
+		from = end
+	}
+}
+
+// docHTMLStyle is the stylesheet of HTML documentation
+const docHTMLStyle = `body { font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.25em 0.5em; text-align: left; vertical-align: top; }
+td.num { text-align: right; white-space: nowrap; }
+pre { background: #f6f8fa; padding: 0.5em; overflow-x: auto; }
+`
+
+// docHTMLText escapes text for HTML, turning `code` spans into <code>
+func docHTMLText(text string) string {
+	parts := strings.Split(text, "`")
+	var b strings.Builder
+	for i, part := range parts {
+		if i%2 == 1 && i < len(parts)-1 {
+			b.WriteString("<code>" + html.EscapeString(part) + "</code>")
+			continue
+		}
+		if i%2 == 1 {
+			b.WriteString("`")
+		}
+		b.WriteString(html.EscapeString(part))
+	}
+	return b.String()
+}
+
+func renderDocsHTML(page docPage) string {
+	var out strings.Builder
+	out.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n")
+	out.WriteString("<title>" + html.EscapeString(page.title) + "</title>\n")
+	out.WriteString("<style>\n" + docHTMLStyle + "</style>\n</head>\n<body>\n")
+	out.WriteString("<h1>" + html.EscapeString(page.title) + "</h1>\n")
+	for _, p := range []string{versionText(page.version), page.description, page.layout} {
+		if p != "" {
+			out.WriteString("<p>" + docHTMLText(p) + "</p>\n")
+		}
+	}
+	out.WriteString("<ul>\n")
+	for _, s := range page.sections {
+		out.WriteString(fmt.Sprintf("<li><a href=\"#%s\">%s</a></li>\n", docAnchor(s.name), html.EscapeString(s.name)))
+	}
+	out.WriteString("</ul>\n")
+	for _, s := range page.sections {
+		out.WriteString(fmt.Sprintf("<section id=\"%s\">\n<h2>%s</h2>\n", docAnchor(s.name), html.EscapeString(s.name)))
+		if s.description != "" {
+			out.WriteString("<p>" + docHTMLText(s.description) + "</p>\n")
+		}
+		summary := docHTMLText(s.summary)
+		if s.summaryLink != "" {
+			link := html.EscapeString(s.summaryLink)
+			summary = linkType(summary, link, fmt.Sprintf("<a href=\"#%s\">%s</a>", docAnchor(s.summaryLink), link))
+		}
+		out.WriteString("<p>" + summary + "</p>\n")
+		if len(s.spans) > 0 {
+			out.WriteString(svgDiagram(s.spans))
+		}
+		if len(s.rows) > 0 {
+			out.WriteString("<table>\n<tr>")
+			for _, column := range s.columns {
+				out.WriteString("<th>" + column + "</th>")
+			}
+			out.WriteString("</tr>\n")
+			for _, row := range s.rows {
+				out.WriteString("<tr>")
+				for i, cell := range row {
+					text := docHTMLText(cell.text)
+					if cell.link != "" {
+						link := html.EscapeString(cell.link)
+						text = linkType(text, link, fmt.Sprintf("<a href=\"#%s\">%s</a>", docAnchor(cell.link), link))
+					}
+					if s.columns[i] == "Offset" || s.columns[i] == "Bits" {
+						out.WriteString("<td class=\"num\">" + text + "</td>")
+					} else {
+						out.WriteString("<td>" + text + "</td>")
+					}
+				}
+				out.WriteString("</tr>\n")
+			}
+			out.WriteString("</table>\n")
+		}
+		out.WriteString("</section>\n")
+	}
+	out.WriteString("</body>\n</html>\n")
+	return out.String()
+}
+
+func versionText(version string) string {
+	if version == "" {
+		return ""
+	}
+	return "Version " + version
+}
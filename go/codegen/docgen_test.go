@@ -0,0 +1,157 @@
+// ABOUTME: Tests for the Markdown/HTML documentation generator
+// ABOUTME: Checks field offsets and widths, enum and union tables, packet diagrams and HTML escaping
+package codegen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// docsTestSchema has a struct of each layout the docs show: fixed fields, a bitfield,
+// a conditional field and the variable fields after it
+func docsTestSchema(t *testing.T) map[string]interface{} {
+	return parseTestSchema(t, `{
+		meta: { title: "Sensor Net", version: "1.2", description: "Readings from field sensors" },
+		config: { endianness: "big_endian" },
+		types: {
+			"Kind": { type: "enum", repr: "uint8", variants: { Ping: 1, Pong: 2 } },
+			"Ping": { description: "Keepalive", sequence: [ { name: "id", type: "uint16" } ] },
+			"Pong": { sequence: [ { name: "id", type: "uint16" }, { name: "rtt", type: "uint32" } ] },
+			"Body": { type: "discriminated_union", discriminator: { peek: "uint8" }, variants: [
+				{ when: "value == 1", type: "Ping", description: "Sent every minute" },
+				{ type: "Pong" },
+			] },
+			"Packet": {
+				description: "One message",
+				sequence: [
+					{ name: "flags", type: "bitfield", size: 8, fields: [
+						{ name: "version", size: 3 },
+						{ name: "urgent", size: 1, description: "Deliver first" },
+						{ name: "reserved", size: 4 },
+					] },
+					{ name: "kind", type: "Kind" },
+					{ name: "seq", type: "uint16", metadata: { min: 1, max: 1000 } },
+					{ name: "extra", type: "uint32", conditional: "flags.urgent == 1" },
+					{ name: "body", type: "Body" },
+					{ name: "name", type: "string", kind: "length_prefixed", length_type: "uint8", description: "Sensor | name" },
+				],
+			},
+		},
+	}`)
+}
+
+func TestGenerateDocs(t *testing.T) {
+	docs, err := GenerateDocs(docsTestSchema(t), DocsOptions{})
+	require.NoError(t, err)
+
+	require.True(t, strings.HasPrefix(docs, "# Sensor Net\n\nVersion 1.2\n\nReadings from field sensors\n\n- [Body](#body)\n"), docs)
+	require.Contains(t, docs, "\n## Packet\n\nOne message\n\nStruct of variable size.\n")
+
+	// The diagram draws the fields up to the first one of variable presence
+	require.Contains(t, docs, "```text\n"+
+		" 0                   1                   2                   3\n"+
+		" 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1\n"+
+		"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+\n"+
+		"|versi|u|reserve|     kind      |              seq              |\n"+
+		"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+\n"+
+		"|                           extra ...                           |\n"+
+		"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+\n"+
+		"```\n")
+
+	// Offsets are known until a conditional field, subfields at their bit
+	require.Contains(t, docs, "| 0 | 8 | `flags` | bitfield |  |\n")
+	require.Contains(t, docs, "| 0.3 | 1 | `flags.urgent` | bits | Deliver first |\n")
+	require.Contains(t, docs, "| 1 | 8 | `kind` | [Kind](#kind) |  |\n")
+	require.Contains(t, docs, "| 2 | 16 | `seq` | uint16 | Valid range: 1 to 1000. |\n")
+	require.Contains(t, docs, "| 4 | 32 | `extra` | uint32 | Present if `flags.urgent == 1`. |\n")
+	require.Contains(t, docs, "| — | var | `body` | [Body](#body) |  |\n")
+	require.Contains(t, docs, "| — | var | `name` | string, `uint8` length prefix | Sensor \\| name |\n")
+
+	require.Contains(t, docs, "## Kind\n\nEnum stored as `uint8`.\n\n| Value | Name |\n| --- | --- |\n| 1 | `Ping` |\n| 2 | `Pong` |\n")
+	require.Contains(t, docs, "## Body\n\nUnion chosen by the next `uint8`.\n\n| When | Type | Description |\n| --- | --- | --- |\n"+
+		"| `value == 1` | [Ping](#ping) | Sent every minute |\n| otherwise | [Pong](#pong) |  |\n")
+	require.Contains(t, docs, "## Pong\n\nStruct of 6 bytes.\n")
+}
+
+func TestGenerateDocsHTML(t *testing.T) {
+	docs, err := GenerateDocs(docsTestSchema(t), DocsOptions{Format: "html", Title: "Sensors <v1>"})
+	require.NoError(t, err)
+
+	require.Contains(t, docs, "<title>Sensors &lt;v1&gt;</title>")
+	require.Contains(t, docs, `<li><a href="#packet">Packet</a></li>`)
+	require.Contains(t, docs, "<section id=\"packet\">\n<h2>Packet</h2>\n")
+	require.Contains(t, docs, `<tr><td class="num">4</td><td class="num">32</td><td><code>extra</code></td><td>uint32</td><td>Present if <code>flags.urgent == 1</code>.</td></tr>`)
+	require.Contains(t, docs, `<td><a href="#body">Body</a></td>`)
+	// HTML draws the diagrams as SVG figures
+	require.Contains(t, docs, "</table>\n</section>\n<section id=\"pong\">\n<h2>Pong</h2>\n<p>Struct of 6 bytes.</p>\n<svg ")
+	require.NotContains(t, docs, "+-+-+")
+	require.True(t, strings.HasSuffix(docs, "</body>\n</html>\n"))
+
+	_, err = GenerateDocs(docsTestSchema(t), DocsOptions{Format: "pdf"})
+	require.ErrorContains(t, err, `unsupported format "pdf"`)
+}
+
+func TestPacketDiagram(t *testing.T) {
+	// Fields running into the next row leave the line between the rows open
+	lines := packetDiagram([]diagramSpan{
+		{label: "type", start: 0, end: 16},
+		{label: "address", start: 16, end: 80},
+	})
+	require.Equal(t, []string{
+		" 0                   1                   2                   3",
+		" 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1",
+		"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+",
+		"|             type              |            address            |",
+		"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+                               +",
+		"|                                                               |",
+		"+                               +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+",
+		"|                               |",
+		"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+",
+	}, lines)
+}
+
+func TestGenerateDocsLSBFirst(t *testing.T) {
+	docs, err := GenerateDocs(lsbFirstTestSchema(t), DocsOptions{})
+	require.NoError(t, err)
+
+	// Each byte fills from its least significant bit, drawn on the right, and length
+	// carries on from the top of byte 1 into byte 2
+	require.Contains(t, docs, "```text\n"+
+		" 0                   1                   2                   3\n"+
+		" 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1\n"+
+		"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+\n"+
+		"|reserve|u|versi|length |channel|    length     |      seq      |\n"+
+		"+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+\n"+
+		"|               |\n"+
+		"+-+-+-+-+-+-+-+-+\n"+
+		"```\n")
+	require.Contains(t, docs, "| 1.4 | 12 | `length` | bit |  |\n")
+	// A type's own bit_order overrides the config's
+	require.Contains(t, docs, "```text\n 0\n 0 1 2 3 4 5 6 7\n+-+-+-+-+-+-+-+-+\n|   a   |   b   |\n")
+}
+
+// lsbFirstTestSchema packs bits lsb_first, with a field crossing a byte boundary, and
+// has a type packing them msb_first
+func lsbFirstTestSchema(t *testing.T) map[string]interface{} {
+	return parseTestSchema(t, `{
+		config: { bit_order: "lsb_first" },
+		types: {
+			"Frame": { sequence: [
+				{ name: "flags", type: "bitfield", size: 8, fields: [
+					{ name: "version", size: 3 },
+					{ name: "urgent", size: 1 },
+					{ name: "reserved", size: 4 },
+				] },
+				{ name: "channel", type: "bit", size: 4 },
+				{ name: "length", type: "bit", size: 12 },
+				{ name: "seq", type: "uint16" },
+			] },
+			"Nibbles": { bit_order: "msb_first", sequence: [
+				{ name: "a", type: "bit", size: 4 },
+				{ name: "b", type: "bit", size: 4 },
+			] },
+		},
+	}`)
+}
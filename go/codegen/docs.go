@@ -0,0 +1,131 @@
+// ABOUTME: Doc comments in generated code: a file header naming the schema and generator, and type and field docs
+// ABOUTME: Field docs come from the description and the unit and valid range in the field's metadata
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GeneratorVersion is the binschema version generated files name in their header
+const GeneratorVersion = "0.6.0"
+
+// docWidth is the width doc comment text is wrapped at, not counting indent and "// "
+const docWidth = 80
+
+// generateFileHeader emits the "Code generated" line and the schema's title, version
+// and description
+func generateFileHeader(out *bytes.Buffer, meta *SchemaMeta) {
+	out.WriteString(fmt.Sprintf("// Code generated by binschema %s. DO NOT EDIT.\n", GeneratorVersion))
+	if meta != nil && (meta.Title != "" || meta.Version != "") {
+		schema := meta.Title
+		if schema == "" {
+			schema = "schema"
+		}
+		if meta.Version != "" {
+			schema += ", version " + meta.Version
+		}
+		out.WriteString("//\n")
+		writeDoc(out, "", "Schema: "+schema)
+	}
+	if meta != nil && meta.Description != "" {
+		out.WriteString("//\n")
+		writeDoc(out, "", meta.Description)
+	}
+	out.WriteString("\n")
+}
+
+// generateTypeDoc emits the doc comment of a struct from its schema description
+func generateTypeDoc(buf *bytes.Buffer, name string, typeDef *TypeDef) {
+	if typeDef.Description == "" {
+		return
+	}
+	writeDoc(buf, "", docSentence(name, typeDef.Description))
+}
+
+// generateFieldDoc emits the doc comment of a struct field: its description, then the
+// unit and valid range its metadata gives
+func generateFieldDoc(buf *bytes.Buffer, field Field) {
+	docs := metadataDocs(field.Metadata)
+	if field.Description != "" {
+		writeDoc(buf, "\t", field.Description)
+		if len(docs) > 0 {
+			buf.WriteString("\t//\n")
+		}
+	}
+	for _, doc := range docs {
+		writeDoc(buf, "\t", doc)
+	}
+}
+
+// metadataDocs returns the lines documenting the unit ("unit" or "units") and the
+// valid range ("min" and "max", or "range") of a field's metadata
+func metadataDocs(metadata map[string]interface{}) []string {
+	var docs []string
+	for _, key := range []string{"unit", "units"} {
+		if unit, ok := metadata[key]; ok {
+			docs = append(docs, "Unit: "+metadataValue(unit)+".")
+			break
+		}
+	}
+	min, hasMin := metadata["min"]
+	max, hasMax := metadata["max"]
+	switch {
+	case hasMin && hasMax:
+		docs = append(docs, fmt.Sprintf("Valid range: %s to %s.", metadataValue(min), metadataValue(max)))
+	case hasMin:
+		docs = append(docs, fmt.Sprintf("Minimum: %s.", metadataValue(min)))
+	case hasMax:
+		docs = append(docs, fmt.Sprintf("Maximum: %s.", metadataValue(max)))
+	}
+	if valid, ok := metadata["range"]; ok && !hasMin && !hasMax {
+		if bounds, ok := valid.([]interface{}); ok && len(bounds) == 2 {
+			docs = append(docs, fmt.Sprintf("Valid range: %s to %s.", metadataValue(bounds[0]), metadataValue(bounds[1])))
+		} else {
+			docs = append(docs, "Valid range: "+metadataValue(valid)+".")
+		}
+	}
+	return docs
+}
+
+// metadataValue formats a metadata value from JSON, numbers without exponents
+func metadataValue(value interface{}) string {
+	if n, ok := value.(float64); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// docSentence starts a doc comment with the name it documents: "Name is ..." for a
+// description that carries on from the name, "Name: ..." for one that stands alone
+func docSentence(name, description string) string {
+	first := []rune(description)[0]
+	if unicode.IsLower(first) {
+		return name + " " + description
+	}
+	return name + ": " + description
+}
+
+// writeDoc emits text as // comment lines wrapped at docWidth, keeping its line breaks
+func writeDoc(buf *bytes.Buffer, indent, text string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			buf.WriteString(indent + "//\n")
+			continue
+		}
+		current := words[0]
+		for _, word := range words[1:] {
+			if len(current)+1+len(word) > docWidth {
+				buf.WriteString(indent + "// " + current + "\n")
+				current = word
+				continue
+			}
+			current += " " + word
+		}
+		buf.WriteString(indent + "// " + current + "\n")
+	}
+}
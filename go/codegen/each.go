@@ -0,0 +1,183 @@
+// ABOUTME: DecodeXFieldEach: decodes a message passing the items of one array to a callback
+// ABOUTME: Huge repeated sections are never collected into a slice; the callback can abort decoding
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// eachArrays returns the array fields of a type that get a DecodeXFieldEach: those
+// decoded item by item in the type's own sequence
+func eachArrays(typeDef *TypeDef) []Field {
+	if !extractable(typeDef) || typeDef.FixedSize > 0 {
+		return nil
+	}
+	var arrays []Field
+	for _, field := range typeDef.Sequence {
+		if field.Type != "array" || field.Items == nil || field.Lazy || field.Kind == "length_prefixed_items" ||
+			(field.Names != nil && field.Names.Resolve) {
+			continue
+		}
+		arrays = append(arrays, field)
+	}
+	return arrays
+}
+
+// eachItemType returns the type a DecodeXFieldEach callback takes: a pointer for struct
+// items, so large items aren't copied, and the slice's element type otherwise
+func eachItemType(items Field) (string, error) {
+	if decodesInPlace(items) {
+		return "*" + capitalizeFirst(items.Type), nil
+	}
+	return mapTypeToGo(items)
+}
+
+// generateDecodeEach emits DecodeXFieldEach and the decodeXFieldEach it calls for each
+// array in a type's sequence
+func generateDecodeEach(buf *bytes.Buffer, typeName string, typeDef *TypeDef, defaultEndianness string) error {
+	for _, array := range eachArrays(typeDef) {
+		itemType, err := eachItemType(*array.Items)
+		if err != nil {
+			return err
+		}
+		fieldName := array.goName()
+		funcName := typeName + fieldName + "Each"
+
+		buf.WriteString(fmt.Sprintf("// Decode%s decodes bytes like Decode%s, but passes each item of %s to fn as\n", funcName, typeName, array.Name))
+		buf.WriteString(fmt.Sprintf("// it is decoded instead of collecting them: %s is left empty. The first error fn\n", fieldName))
+		buf.WriteString("// returns stops decoding and is returned as is\n")
+		buf.WriteString(fmt.Sprintf("func Decode%s(bytes []byte, fn func(item %s) error) (*%s, error) {\n", funcName, itemType, typeName))
+		buf.WriteString(fmt.Sprintf("\tdecoder := runtime.NewBitStreamDecoder(bytes, %s)\n", runtimeBitOrder(typeDef.BitOrder)))
+		buf.WriteString(fmt.Sprintf("\treturn decode%s(decoder, nil, fn)\n", funcName))
+		buf.WriteString("}\n\n")
+
+		buf.WriteString(fmt.Sprintf("func decode%s(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, fn func(item %s) error) (*%s, error) {\n", funcName, itemType, typeName))
+		buf.WriteString("\tconst traceEnabled = runtime.TraceEnabled\n")
+		buf.WriteString(fmt.Sprintf("\tresult := &%s{}\n", typeName))
+		if passesParents(typeDef.allFields()) {
+			buf.WriteString("\tchildCtx := ctx.ExtendWithParentStruct(result)\n")
+		}
+		buf.WriteString("\n")
+		generateDecodeBitOrder(buf, typeDef)
+		if typeDef.Recursive {
+			buf.WriteString("\tif err := decoder.EnterNested(); err != nil {\n")
+			buf.WriteString("\t\treturn nil, err\n")
+			buf.WriteString("\t}\n")
+			buf.WriteString("\tdefer decoder.ExitNested()\n\n")
+		}
+
+		for _, field := range typeDef.Sequence {
+			if field.Name == array.Name {
+				if err := generateDecodeArrayEach(buf, field, defaultEndianness); err != nil {
+					return err
+				}
+			} else {
+				if err := generateDecodeField(buf, field, defaultEndianness); err != nil {
+					return err
+				}
+				generateEndiannessSelect(buf, field, "decoder", "result."+field.goName(), "\t")
+			}
+		}
+		if err := generateDecodeInstances(buf, typeDef, defaultEndianness); err != nil {
+			return err
+		}
+
+		buf.WriteString("\n\treturn result, nil\n")
+		buf.WriteString("}\n\n")
+	}
+	return nil
+}
+
+// generateDecodeArrayEach reads an array as generateDecodeArray does, calling fn with
+// each item instead of storing it
+func generateDecodeArrayEach(buf *bytes.Buffer, field Field, defaultEndianness string) error {
+	endianness := field.Endianness
+	if endianness == "" {
+		endianness = defaultEndianness
+	}
+	runtimeEndianness := mapEndianness(endianness)
+	varName := field.localName()
+
+	indent := "\t"
+	if field.Conditional != "" {
+		goCondition := generateCondition(buf, field, "result", indent)
+		buf.WriteString(fmt.Sprintf("%sif %s {\n", indent, goCondition))
+		indent += "\t"
+	}
+
+	switch src, computed := lengthSource(field); {
+	case field.Kind == "length_prefixed":
+		lengthType := field.LengthType
+		if lengthType == "" {
+			lengthType = "uint8"
+		}
+		switch lengthType {
+		case "uint8":
+			buf.WriteString(fmt.Sprintf("%s%s_length, err := decoder.ReadUint8()\n", indent, varName))
+		case "uint16", "uint32", "uint64":
+			buf.WriteString(fmt.Sprintf("%s%s_length, err := decoder.Read%s(runtime.%s)\n", indent, varName, capitalizeFirst(lengthType), runtimeEndianness))
+		default:
+			return fmt.Errorf("%s: unsupported length_type %s", field.Name, lengthType)
+		}
+		buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		buf.WriteString(fmt.Sprintf("%sfor i := 0; i < int(%s_length); i++ {\n", indent, varName))
+	case field.Kind == "null_terminated" || field.Kind == "variant_terminated":
+		if len(field.TerminalVariants) > 0 {
+			buf.WriteString(fmt.Sprintf("%s%sLoop:\n", indent, varName))
+		}
+		buf.WriteString(fmt.Sprintf("%sfor {\n", indent))
+		if field.Kind == "null_terminated" {
+			buf.WriteString(fmt.Sprintf("%s\tterminator, err := decoder.PeekUint8()\n", indent))
+			buf.WriteString(fmt.Sprintf("%s\tif err != nil {\n", indent))
+			buf.WriteString(fmt.Sprintf("%s\t\treturn nil, err\n", indent))
+			buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+			buf.WriteString(fmt.Sprintf("%s\tif terminator == 0 {\n", indent))
+			buf.WriteString(fmt.Sprintf("%s\t\tdecoder.SkipBytes(1)\n", indent))
+			buf.WriteString(fmt.Sprintf("%s\t\tbreak\n", indent))
+			buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+		}
+	case computed:
+		lengthVar := varName + "_computed_length"
+		if err := generateLength(buf, field, src, "result", lengthVar, indent); err != nil {
+			return err
+		}
+		generateRemainingCheck(buf, field, lengthVar, indent)
+		buf.WriteString(fmt.Sprintf("%sfor i := int64(0); i < %s; i++ {\n", indent, lengthVar))
+	case field.Kind == "fixed":
+		length := 0
+		if intLen, ok := field.Length.(float64); ok {
+			length = int(intLen)
+		}
+		buf.WriteString(fmt.Sprintf("%sfor i := 0; i < %d; i++ {\n", indent, length))
+	default:
+		return fmt.Errorf("unknown array kind: %s", field.Kind)
+	}
+
+	generateCancelCheck(buf, indent+"\t")
+	itemVar := varName + "_item"
+	if decodesInPlace(*field.Items) {
+		buf.WriteString(fmt.Sprintf("%s\t%s, err := decode%sWithDecoder(decoder, %s)\n", indent, itemVar, capitalizeFirst(field.Items.Type), contextFor(*field.Items)))
+		buf.WriteString(fmt.Sprintf("%s\tif err != nil {\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\t\treturn nil, err\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+	} else if err := generateDecodeFieldImpl(buf, *field.Items, "", itemVar, endianness, runtimeEndianness, indent+"\t"); err != nil {
+		return err
+	}
+	buf.WriteString(fmt.Sprintf("%s\tif err := fn(%s); err != nil {\n", indent, itemVar))
+	buf.WriteString(fmt.Sprintf("%s\t\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+	// A terminal variant is the last item, with no zero byte after it
+	if len(field.TerminalVariants) > 0 {
+		terminalSwitch(buf, itemVar, field.TerminalVariants, fmt.Sprintf("break %sLoop", varName), indent+"\t")
+	}
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+
+	if field.Conditional != "" {
+		buf.WriteString("\t}\n")
+	}
+	buf.WriteString("\n")
+	return nil
+}
@@ -0,0 +1,114 @@
+// ABOUTME: Generates MarshalCBOR/MarshalMessagePack methods re-serializing decoded values
+// ABOUTME: Values are written through runtime.Emitter as maps keyed by schema field names
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// generateEmitMethods emits MarshalCBOR(), MarshalMessagePack() and the emit method they share
+func generateEmitMethods(buf *bytes.Buffer, name string, typeDef *TypeDef) error {
+	recv := typeDef.receiver()
+	for _, format := range []struct{ method, constant, doc string }{
+		{"MarshalCBOR", "CBOR", "CBOR"},
+		{"MarshalMessagePack", "MessagePack", "MessagePack"},
+	} {
+		buf.WriteString(fmt.Sprintf("// %s re-serializes %s as %s: a map keyed by schema field names\n", format.method, name, format.doc))
+		buf.WriteString(fmt.Sprintf("func (%s *%s) %s() ([]byte, error) {\n", recv, name, format.method))
+		buf.WriteString(fmt.Sprintf("\te := &runtime.Emitter{Format: runtime.%s}\n", format.constant))
+		buf.WriteString(fmt.Sprintf("\t%s.emit(e)\n", recv))
+		buf.WriteString("\treturn e.Bytes(), nil\n")
+		buf.WriteString("}\n\n")
+	}
+
+	fields := typeDef.allFields()
+	buf.WriteString(fmt.Sprintf("func (%s *%s) emit(e *runtime.Emitter) {\n", recv, name))
+	buf.WriteString(fmt.Sprintf("\te.BeginMap(%d)\n", len(fields)))
+	for _, field := range fields {
+		buf.WriteString(fmt.Sprintf("\te.Key(%q)\n", field.Name))
+		if err := generateEmitValue(buf, field, recv+"."+field.goName(), "\t", 0); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("}\n\n")
+	return nil
+}
+
+// generateEmitValue emits the emitter calls for one value of a field's type
+func generateEmitValue(buf *bytes.Buffer, field Field, expr, indent string, depth int) error {
+	switch {
+	case field.Lazy:
+		// A value that fails to decode is written as null
+		return generateLazyValue(buf, field, expr, indent, "e.Nil()", func(inner Field, value string) error {
+			return generateEmitValue(buf, inner, value, indent+"\t", depth)
+		})
+	case field.FlagsRepr != "":
+		buf.WriteString(fmt.Sprintf("%se.Uint(uint64(%s))\n", indent, expr))
+	case field.Type == "array":
+		if field.Items == nil {
+			return fmt.Errorf("array field missing items definition")
+		}
+		if isByteArray(field) {
+			buf.WriteString(fmt.Sprintf("%se.Binary(%s)\n", indent, expr))
+			return nil
+		}
+		index := fmt.Sprintf("i%d", depth)
+		buf.WriteString(fmt.Sprintf("%se.BeginArray(len(%s))\n", indent, expr))
+		buf.WriteString(fmt.Sprintf("%sfor %s := range %s {\n", indent, index, expr))
+		if err := generateEmitValue(buf, *field.Items, fmt.Sprintf("%s[%s]", expr, index), indent+"\t", depth+1); err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	case !isScalarType(field.Type):
+		generateEmitNested(buf, field, expr, indent)
+	default:
+		goType, err := mapTypeToGo(field)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("%s%s\n", indent, emitScalar(goType, expr)))
+	}
+	return nil
+}
+
+// generateEmitNested emits a nested struct or union, or a null for an absent one
+func generateEmitNested(buf *bytes.Buffer, field Field, expr, indent string) {
+	emit := fmt.Sprintf("%s.emit(e)", expr)
+	if field.Union {
+		emit = fmt.Sprintf("emit%s(e, %s)", capitalizeFirst(field.Type), expr)
+	}
+	if !field.Pointer && !field.Union {
+		buf.WriteString(fmt.Sprintf("%s%s\n", indent, emit))
+		return
+	}
+	buf.WriteString(fmt.Sprintf("%sif %s == nil {\n", indent, expr))
+	buf.WriteString(fmt.Sprintf("%s\te.Nil()\n", indent))
+	buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t%s\n", indent, emit))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
+// emitScalar returns the emitter call writing a scalar of the given Go type
+func emitScalar(goType, expr string) string {
+	switch goType {
+	case "uint8", "uint16", "uint32", "uint64":
+		return fmt.Sprintf("e.Uint(uint64(%s))", expr)
+	case "int8", "int16", "int32", "int64":
+		return fmt.Sprintf("e.Int(int64(%s))", expr)
+	case "float32":
+		return fmt.Sprintf("e.Float32(%s)", expr)
+	case "float64":
+		return fmt.Sprintf("e.Float64(%s)", expr)
+	case "runtime.Uint128", "runtime.Int128":
+		return fmt.Sprintf("e.BigInt(%s.Big())", expr)
+	case "time.Time":
+		return fmt.Sprintf("e.Time(%s)", expr)
+	case "string":
+		return fmt.Sprintf("e.String(%s)", expr)
+	case "runtime.ByteString":
+		return fmt.Sprintf("e.String(string(%s))", expr)
+	}
+	// Addresses and UUIDs in their text form
+	return fmt.Sprintf("e.String(%s.String())", expr)
+}
@@ -0,0 +1,68 @@
+// ABOUTME: Dynamic endianness: config "dynamic" makes fields use a byte order chosen at runtime
+// ABOUTME: A selects_endianness field picks it from its value; callers can also pass one in
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// markEndiannessContext flags the nested-type fields and array items that must be told
+// the byte order their parent's encoder has chosen. The decoder carries it by itself.
+func markEndiannessContext(schema *Schema) {
+	if schema.Config == nil || schema.Config.Endianness != "dynamic" {
+		return
+	}
+	for _, typeDef := range schema.Types {
+		for _, field := range typeDef.fieldPointers() {
+			field.EndiannessContext = isNestedType(schema, field)
+			if field.Items != nil {
+				field.Items.EndiannessContext = isNestedType(schema, field.Items)
+			}
+		}
+	}
+}
+
+// generateEndiannessSelect emits the switch that sets the byte order a selects_endianness
+// field's value stands for, on the decoder (value in result) or encoder (value in m)
+func generateEndiannessSelect(buf *bytes.Buffer, field Field, stream, value, indent string) {
+	if len(field.SelectsEndianness) == 0 {
+		return
+	}
+	orders := make([]string, 0, len(field.SelectsEndianness))
+	for order := range field.SelectsEndianness {
+		orders = append(orders, order)
+	}
+	sort.Strings(orders)
+
+	buf.WriteString(fmt.Sprintf("%sswitch %s {\n", indent, value))
+	for _, order := range orders {
+		buf.WriteString(fmt.Sprintf("%scase %#x:\n", indent, field.SelectsEndianness[order]))
+		buf.WriteString(fmt.Sprintf("%s\t%s.SetEndianness(runtime.%s)\n", indent, stream, mapEndianness(order)))
+	}
+	buf.WriteString(fmt.Sprintf("%sdefault:\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%#x selects no byte order\", %s)\n", indent, field.Name, value))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
+// generateDecodeWithEndianness emits Decode<T>WithEndianness, for byte orders settled
+// outside the data (a handshake, a container format)
+func generateDecodeWithEndianness(buf *bytes.Buffer, typeName, bitOrder string) {
+	buf.WriteString(fmt.Sprintf("// Decode%sWithEndianness decodes dynamic-endian fields in the given byte order,\n", typeName))
+	buf.WriteString("// until a field in the data selects another\n")
+	buf.WriteString(fmt.Sprintf("func Decode%sWithEndianness(bytes []byte, endianness runtime.Endianness) (*%s, error) {\n", typeName, typeName))
+	buf.WriteString(fmt.Sprintf("\tdecoder := runtime.NewBitStreamDecoder(bytes, %s)\n", runtimeBitOrder(bitOrder)))
+	buf.WriteString("\tdecoder.SetEndianness(endianness)\n")
+	buf.WriteString(fmt.Sprintf("\treturn decode%sWithDecoder(decoder, nil)\n", typeName))
+	buf.WriteString("}\n\n")
+}
+
+// generateEncodeWithEndianness emits EncodeWithEndianness, the encoding counterpart
+func generateEncodeWithEndianness(buf *bytes.Buffer, typeName, recv string) {
+	buf.WriteString("// EncodeWithEndianness encodes dynamic-endian fields in the given byte order,\n")
+	buf.WriteString("// until a field selects another\n")
+	buf.WriteString(fmt.Sprintf("func (%s *%s) EncodeWithEndianness(endianness runtime.Endianness) ([]byte, error) {\n", recv, typeName))
+	buf.WriteString(fmt.Sprintf("\treturn %s.EncodeWithContext(runtime.NewEncodingContext().WithEndianness(endianness))\n", recv))
+	buf.WriteString("}\n\n")
+}
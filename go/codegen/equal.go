@@ -0,0 +1,209 @@
+// ABOUTME: Generates Equal and Hash: field-by-field comparison and a stable FNV-1a hash
+// ABOUTME: Floats compare bit for bit (NaN equals itself) and nil slices equal empty ones, unlike reflect.DeepEqual
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// generateEqualMethods emits Equal, Hash and the hash method nested types call
+func generateEqualMethods(buf *bytes.Buffer, schema *Schema, name string, typeDef *TypeDef) error {
+	recv := typeDef.receiver()
+	buf.WriteString(fmt.Sprintf("// Equal reports whether %s and other hold the same values. Floats are compared bit for\n", recv))
+	buf.WriteString("// bit, so NaN equals itself, and a nil slice equals an empty one. A lazy field that\n")
+	buf.WriteString("// fails to decode equals nothing.\n")
+	buf.WriteString(fmt.Sprintf("func (%s *%s) Equal(other *%s) bool {\n", recv, name, name))
+	buf.WriteString(fmt.Sprintf("\tif %s == nil || other == nil {\n", recv))
+	buf.WriteString(fmt.Sprintf("\t\treturn %s == other\n", recv))
+	buf.WriteString("\t}\n")
+	for _, field := range typeDef.allFields() {
+		fieldName := field.goName()
+		if field.Lazy {
+			// Lazy fields are compared as their values, in a block of their own
+			inner := field
+			inner.Lazy = false
+			buf.WriteString("\t{\n")
+			buf.WriteString(fmt.Sprintf("\t\ta, errA := %s.%s.Get()\n", recv, fieldName))
+			buf.WriteString(fmt.Sprintf("\t\tb, errB := other.%s.Get()\n", fieldName))
+			buf.WriteString("\t\tif errA != nil || errB != nil {\n")
+			buf.WriteString("\t\t\treturn false\n")
+			buf.WriteString("\t\t}\n")
+			if err := generateEqualValue(buf, schema, inner, "a", "b", "\t\t", 0); err != nil {
+				return err
+			}
+			buf.WriteString("\t}\n")
+			continue
+		}
+		if err := generateEqualValue(buf, schema, field, recv+"."+fieldName, "other."+fieldName, "\t", 0); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("\treturn true\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("// Hash returns a hash of %s that is the same for values Equal reports equal, on every\n", recv))
+	buf.WriteString("// platform and in every process\n")
+	buf.WriteString(fmt.Sprintf("func (%s *%s) Hash() uint64 {\n", recv, name))
+	buf.WriteString("\th := runtime.NewHasher()\n")
+	buf.WriteString(fmt.Sprintf("\t%s.hash(h)\n", recv))
+	buf.WriteString("\treturn h.Sum64()\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("func (%s *%s) hash(h *runtime.Hasher) {\n", recv, name))
+	buf.WriteString(fmt.Sprintf("\tif %s == nil {\n", recv))
+	buf.WriteString("\t\th.Uint(0)\n")
+	buf.WriteString("\t\treturn\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\th.Uint(1)\n")
+	for _, field := range typeDef.allFields() {
+		expr := recv + "." + field.goName()
+		if field.Lazy {
+			err := generateLazyValue(buf, field, expr, "\t", fmt.Sprintf("h.Bytes(%s.Raw())", expr), func(inner Field, value string) error {
+				return generateHashValue(buf, schema, inner, value, "\t\t", 0)
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err := generateHashValue(buf, schema, field, expr, "\t", 0); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("}\n\n")
+	return nil
+}
+
+// generateUnionEqual emits equalX and hashX, which compare and hash whichever variant a
+// union holds
+func generateUnionEqual(buf *bytes.Buffer, name string, typeDef *TypeDef) {
+	buf.WriteString(fmt.Sprintf("func equal%s(a, b %s) bool {\n", name, name))
+	buf.WriteString("\tswitch a := a.(type) {\n")
+	for _, variant := range typeDef.Variants {
+		variantName := capitalizeFirst(variant.Type)
+		buf.WriteString(fmt.Sprintf("\tcase *%s:\n", variantName))
+		buf.WriteString(fmt.Sprintf("\t\tb, ok := b.(*%s)\n", variantName))
+		buf.WriteString("\t\treturn ok && a.Equal(b)\n")
+	}
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn a == nil && b == nil\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("func hash%s(h *runtime.Hasher, v %s) {\n", name, name))
+	buf.WriteString("\tswitch v := v.(type) {\n")
+	for i, variant := range typeDef.Variants {
+		buf.WriteString(fmt.Sprintf("\tcase *%s:\n", capitalizeFirst(variant.Type)))
+		buf.WriteString(fmt.Sprintf("\t\th.Uint(%d)\n", i+1))
+		buf.WriteString("\t\tv.hash(h)\n")
+		buf.WriteString("\t\treturn\n")
+	}
+	buf.WriteString("\t}\n")
+	buf.WriteString("\th.Uint(0)\n")
+	buf.WriteString("}\n\n")
+}
+
+// generateEqualValue emits a return of false when a and b, values of a field's type, differ
+func generateEqualValue(buf *bytes.Buffer, schema *Schema, field Field, a, b, indent string, depth int) error {
+	differ := ""
+	switch {
+	case field.ZeroCopy, isByteArray(field):
+		differ = fmt.Sprintf("string(%s) != string(%s)", a, b)
+	case field.Type == "float32":
+		differ = fmt.Sprintf("math.Float32bits(%s) != math.Float32bits(%s)", a, b)
+	case field.Type == "float64":
+		differ = fmt.Sprintf("math.Float64bits(%s) != math.Float64bits(%s)", a, b)
+	case field.Type == "unix32", field.Type == "unix64", field.Type == "unix_milli", field.Type == "ntp":
+		differ = fmt.Sprintf("!%s.Equal(%s)", a, b)
+	case isScalarType(field.Type), field.FlagsRepr != "":
+		differ = fmt.Sprintf("%s != %s", a, b)
+	case field.Type == "array":
+		if field.Items == nil {
+			return fmt.Errorf("array field missing items definition")
+		}
+		index := fmt.Sprintf("i%d", depth)
+		buf.WriteString(fmt.Sprintf("%sif len(%s) != len(%s) {\n", indent, a, b))
+		buf.WriteString(fmt.Sprintf("%s\treturn false\n", indent))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		buf.WriteString(fmt.Sprintf("%sfor %s := range %s {\n", indent, index, a))
+		item := fmt.Sprintf("[%s]", index)
+		if err := generateEqualValue(buf, schema, *field.Items, a+item, b+item, indent+"\t", depth+1); err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		return nil
+	case field.Union:
+		differ = fmt.Sprintf("!equal%s(%s, %s)", capitalizeFirst(field.Type), a, b)
+	case field.Pointer:
+		differ = fmt.Sprintf("!%s.Equal(%s)", a, b)
+	default:
+		// A struct held by value
+		differ = fmt.Sprintf("!%s.Equal(&%s)", a, b)
+	}
+	buf.WriteString(fmt.Sprintf("%sif %s {\n", indent, differ))
+	buf.WriteString(fmt.Sprintf("%s\treturn false\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	return nil
+}
+
+// generateHashValue emits the Hasher calls feeding one value of a field's type
+func generateHashValue(buf *bytes.Buffer, schema *Schema, field Field, expr, indent string, depth int) error {
+	if field.FlagsRepr != "" {
+		buf.WriteString(fmt.Sprintf("%sh.Uint(uint64(%s))\n", indent, expr))
+		return nil
+	}
+	switch field.Type {
+	case "uint8", "uint16", "uint32", "uint64", "bit":
+		buf.WriteString(fmt.Sprintf("%sh.Uint(uint64(%s))\n", indent, expr))
+	case "int8", "int16", "int32", "int64", "int":
+		buf.WriteString(fmt.Sprintf("%sh.Int(int64(%s))\n", indent, expr))
+	case "float32":
+		buf.WriteString(fmt.Sprintf("%sh.Float32(%s)\n", indent, expr))
+	case "float64":
+		buf.WriteString(fmt.Sprintf("%sh.Float64(%s)\n", indent, expr))
+	case "uint128", "int128":
+		buf.WriteString(fmt.Sprintf("%sh.Uint(%s.Hi)\n", indent, expr))
+		buf.WriteString(fmt.Sprintf("%sh.Uint(%s.Lo)\n", indent, expr))
+	case "uuid", "mac":
+		buf.WriteString(fmt.Sprintf("%sh.Bytes(%s[:])\n", indent, expr))
+	case "ipv4", "ipv6":
+		// The text form carries the zone, which == compares too
+		buf.WriteString(fmt.Sprintf("%sh.String(%s.String())\n", indent, expr))
+	case "unix32", "unix64", "unix_milli", "ntp":
+		buf.WriteString(fmt.Sprintf("%sh.Time(%s)\n", indent, expr))
+	case "bcd", "packed_bcd":
+		if field.DecodeAs == "string" {
+			buf.WriteString(fmt.Sprintf("%sh.String(%s)\n", indent, expr))
+		} else {
+			buf.WriteString(fmt.Sprintf("%sh.Int(%s)\n", indent, expr))
+		}
+	case "string":
+		if field.ZeroCopy {
+			buf.WriteString(fmt.Sprintf("%sh.Bytes(%s)\n", indent, expr))
+		} else {
+			buf.WriteString(fmt.Sprintf("%sh.String(%s)\n", indent, expr))
+		}
+	case "array":
+		if field.Items == nil {
+			return fmt.Errorf("array field missing items definition")
+		}
+		if isByteArray(field) {
+			buf.WriteString(fmt.Sprintf("%sh.Bytes(%s)\n", indent, expr))
+			return nil
+		}
+		index := fmt.Sprintf("i%d", depth)
+		buf.WriteString(fmt.Sprintf("%sh.Uint(uint64(len(%s)))\n", indent, expr))
+		buf.WriteString(fmt.Sprintf("%sfor %s := range %s {\n", indent, index, expr))
+		if err := generateHashValue(buf, schema, *field.Items, fmt.Sprintf("%s[%s]", expr, index), indent+"\t", depth+1); err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	default:
+		if field.Union {
+			buf.WriteString(fmt.Sprintf("%shash%s(h, %s)\n", indent, capitalizeFirst(field.Type), expr))
+		} else {
+			buf.WriteString(fmt.Sprintf("%s%s.hash(h)\n", indent, expr))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+// ABOUTME: ExtractXField: decodes only as much of a message as it takes to reach one field
+// ABOUTME: Fixed-width fields nothing later depends on are skipped by their size, unread
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// extractable reports whether a type gets an ExtractXField: structs decoded in sequence
+func extractable(typeDef *TypeDef) bool {
+	return typeDef != nil && typeDef.Discriminator == nil && typeDef.Flags == nil && !typeDef.Bitfield && !typeDef.Protobuf
+}
+
+// generateExtract emits the public ExtractXField and the extractX it calls, which
+// nested structs on a path call in turn
+func generateExtract(buf *bytes.Buffer, schema *Schema, typeName string, typeDef *TypeDef, defaultEndianness string) error {
+	buf.WriteString(fmt.Sprintf("// Extract%sField decodes only as much of bytes as it takes to reach the field at path\n", typeName))
+	buf.WriteString("// (schema names, \"header.id\"), skipping fixed-width fields it doesn't need\n")
+	buf.WriteString(fmt.Sprintf("func Extract%sField(bytes []byte, path string) (interface{}, error) {\n", typeName))
+	buf.WriteString(fmt.Sprintf("\tdecoder := runtime.NewBitStreamDecoder(bytes, %s)\n", runtimeBitOrder(typeDef.BitOrder)))
+	buf.WriteString(fmt.Sprintf("\tvalue, err := extract%s(decoder, nil, runtime.SplitFieldPath(path))\n", typeName))
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\treturn nil, fmt.Errorf(\"%s: %w\", path, err)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn value, nil\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("func extract%s(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, fieldPath []string) (interface{}, error) {\n", typeName))
+	buf.WriteString("\tconst traceEnabled = runtime.TraceEnabled\n")
+	var names []string
+	for _, field := range typeDef.Sequence {
+		names = append(names, fmt.Sprintf("%q", field.Name))
+	}
+	if len(names) > 0 {
+		buf.WriteString("\tswitch fieldPath[0] {\n")
+		buf.WriteString(fmt.Sprintf("\tcase %s:\n", strings.Join(names, ", ")))
+		buf.WriteString("\tdefault:\n")
+		buf.WriteString("\t\treturn nil, runtime.ErrUnknownField\n")
+		buf.WriteString("\t}\n")
+	}
+	buf.WriteString(fmt.Sprintf("\tresult := &%s{}\n", typeName))
+
+	parents := passesParents(typeDef.allFields())
+	if parents {
+		buf.WriteString("\tchildCtx := ctx.ExtendWithParentStruct(result)\n")
+	}
+	buf.WriteString("\n")
+	generateDecodeBitOrder(buf, typeDef)
+	if typeDef.Recursive {
+		buf.WriteString("\tif err := decoder.EnterNested(); err != nil {\n")
+		buf.WriteString("\t\treturn nil, err\n")
+		buf.WriteString("\t}\n")
+		buf.WriteString("\tdefer decoder.ExitNested()\n\n")
+	}
+
+	for i := 0; i < len(typeDef.Sequence); i++ {
+		// Fields passed to nested types are all needed, so nothing is skipped then
+		n := 0
+		for !parents && i+n < len(typeDef.Sequence) && skippable(typeDef.Sequence, i+n) {
+			n++
+		}
+		if n == 0 {
+			if err := generateExtractField(buf, schema, typeDef.Sequence[i], defaultEndianness); err != nil {
+				return err
+			}
+			continue
+		}
+
+		run := typeDef.Sequence[i : i+n]
+		size := 0
+		var runNames []string
+		for _, field := range run {
+			size += field.InlineWidth
+			runNames = append(runNames, fmt.Sprintf("%q", field.Name))
+		}
+		buf.WriteString("\tswitch fieldPath[0] {\n")
+		buf.WriteString(fmt.Sprintf("\tcase %s:\n", strings.Join(runNames, ", ")))
+		for _, field := range run {
+			if err := generateExtractField(buf, schema, field, defaultEndianness); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\tdefault:\n")
+		buf.WriteString(fmt.Sprintf("\t\tif err := decoder.Skip(%d); err != nil {\n", size))
+		buf.WriteString("\t\t\treturn nil, err\n")
+		buf.WriteString("\t\t}\n")
+		buf.WriteString("\t}\n\n")
+		i += n - 1
+	}
+
+	buf.WriteString("\treturn nil, runtime.ErrUnknownField\n")
+	buf.WriteString("}\n\n")
+	return nil
+}
+
+// generateExtractField decodes one field as decodeXInto does, returning it (or what
+// the rest of the path names in it) when it is the field the path starts with.
+// Paths into nested structs go on in their extractX instead of decoding them whole.
+func generateExtractField(buf *bytes.Buffer, schema *Schema, field Field, defaultEndianness string) error {
+	fieldName := field.goName()
+	nested := schema.Types[field.Type]
+	if field.Type != "array" && field.BackReference == nil && field.Codec == "" && extractable(nested) {
+		buf.WriteString(fmt.Sprintf("\tif fieldPath[0] == %q && len(fieldPath) > 1 {\n", field.Name))
+		generateExtractPresent(buf, field)
+		buf.WriteString(fmt.Sprintf("\t\treturn extract%s(decoder, %s, fieldPath[1:])\n", field.Type, contextFor(field)))
+		buf.WriteString("\t}\n")
+	}
+
+	if err := generateDecodeField(buf, field, defaultEndianness); err != nil {
+		return err
+	}
+	generateEndiannessSelect(buf, field, "decoder", "result."+fieldName, "\t")
+
+	buf.WriteString(fmt.Sprintf("\tif fieldPath[0] == %q {\n", field.Name))
+	generateExtractPresent(buf, field)
+	buf.WriteString("\t\tif len(fieldPath) == 1 {\n")
+	buf.WriteString(fmt.Sprintf("\t\t\treturn result.%s, nil\n", fieldName))
+	buf.WriteString("\t\t}\n")
+	// Bitfield subfields are decoded with their bitfield, so they are picked out of it
+	if nested != nil && nested.Bitfield {
+		buf.WriteString("\t\tif len(fieldPath) == 2 {\n")
+		buf.WriteString("\t\t\tswitch fieldPath[1] {\n")
+		for _, sub := range nested.Sequence {
+			buf.WriteString(fmt.Sprintf("\t\t\tcase %q:\n", sub.Name))
+			buf.WriteString(fmt.Sprintf("\t\t\t\treturn result.%s.%s, nil\n", fieldName, sub.goName()))
+		}
+		buf.WriteString("\t\t\t}\n")
+		buf.WriteString("\t\t}\n")
+	}
+	buf.WriteString("\t\treturn nil, runtime.ErrUnknownField\n")
+	buf.WriteString("\t}\n\n")
+	return nil
+}
+
+// generateExtractPresent emits the check that a conditional field is in the data
+func generateExtractPresent(buf *bytes.Buffer, field Field) {
+	if field.Conditional == "" {
+		return
+	}
+	condition := generateCondition(buf, field, "result", "\t\t")
+	buf.WriteString(fmt.Sprintf("\t\tif !(%s) {\n", condition))
+	buf.WriteString("\t\t\treturn nil, runtime.ErrFieldAbsent\n")
+	buf.WriteString("\t\t}\n")
+}
+
+// Names a conditional or length expression may refer to
+var identifierPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// skippable reports whether extraction can step over fields[i] by its width: it is
+// read by the fast path (a fixed width, unconditional, byte order known up front) and
+// no later field's conditional or length refers to it
+func skippable(fields []Field, i int) bool {
+	if fields[i].InlineWidth == 0 {
+		return false
+	}
+	for _, later := range fields[i+1:] {
+		sources := []string{later.Conditional}
+		if src, ok := lengthSource(later); ok {
+			sources = append(sources, src)
+		}
+		for _, src := range sources {
+			for _, name := range identifierPattern.FindAllString(src, -1) {
+				if name == fields[i].Name {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
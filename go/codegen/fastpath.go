@@ -0,0 +1,196 @@
+// ABOUTME: Fast path for fixed-width fields: one bounds check, then reads straight from the input
+// ABOUTME: Types of a known size decode from one slice; the bitstream stays as the fallback
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Byte widths of the types a fast path reads from the input directly
+var inlineWidths = map[string]int{
+	"uint8": 1, "int8": 1, "uint16": 2, "int16": 2,
+	"uint32": 4, "int32": 4, "float32": 4,
+	"uint64": 8, "int64": 8, "float64": 8,
+}
+
+// markFixedSizes sets the InlineWidth of every sequence field the fast path can read,
+// and the FixedSize of every struct made only of such fields. Nested structs of a fixed
+// size count as fixed-width fields of their parents, so the size propagates upwards.
+func markFixedSizes(schema *Schema, defaultEndianness string) {
+	for _, typeDef := range schema.Types {
+		for i := range typeDef.Sequence {
+			typeDef.Sequence[i].InlineWidth = primitiveInlineWidth(typeDef.Sequence[i], defaultEndianness)
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, typeDef := range schema.Types {
+			if typeDef.FixedSize > 0 || !canBeFixedSize(typeDef) {
+				continue
+			}
+			size := 0
+			for i := range typeDef.Sequence {
+				field := &typeDef.Sequence[i]
+				if nested, ok := schema.Types[field.Type]; ok && field.InlineWidth == 0 && inlinesNested(*field) {
+					field.InlineWidth = nested.FixedSize
+				}
+				if field.InlineWidth == 0 {
+					size = 0
+					break
+				}
+				size += field.InlineWidth
+			}
+			if size > 0 {
+				typeDef.FixedSize = size
+				changed = true
+			}
+		}
+	}
+}
+
+// canBeFixedSize reports whether a type is a plain struct, which has a fixed size if
+// its fields do
+func canBeFixedSize(typeDef *TypeDef) bool {
+	return typeDef.Discriminator == nil && typeDef.Flags == nil && !typeDef.Bitfield && !typeDef.Protobuf &&
+		len(typeDef.Instances) == 0 && len(typeDef.Sequence) > 0
+}
+
+// inlinesNested reports whether a nested struct field can be decoded from its parent's
+// slice: it must be held by value and always present
+func inlinesNested(field Field) bool {
+	return field.Conditional == "" && field.Codec == "" && decodesInPlace(field)
+}
+
+// primitiveInlineWidth returns the byte width of a number or flag set the fast path can
+// read, or 0 if it needs the bitstream: conditional fields, bit fields, byte orders
+// chosen at runtime, and fields a codec reads
+func primitiveInlineWidth(field Field, defaultEndianness string) int {
+	if field.Conditional != "" || field.Codec != "" || len(field.SelectsEndianness) > 0 || fieldEndianness(field, defaultEndianness) == "dynamic" {
+		return 0
+	}
+	if field.FlagsRepr != "" {
+		return inlineWidths[field.FlagsRepr]
+	}
+	return inlineWidths[field.Type]
+}
+
+// inlineRun returns the number of fields from fields[start] on that the fast path reads
+// together, or 0 if fewer than two in a row can be: one field gains nothing
+func inlineRun(fields []Field, start int) int {
+	n := 0
+	for start+n < len(fields) && fields[start+n].InlineWidth > 0 {
+		n++
+	}
+	if n < 2 {
+		return 0
+	}
+	return n
+}
+
+// generateDecodeFrom emits decodeXFrom for a type of a fixed size, filling result from
+// a slice the caller has checked holds all of it
+func generateDecodeFrom(buf *bytes.Buffer, typeName string, typeDef *TypeDef, defaultEndianness string) {
+	buf.WriteString(fmt.Sprintf("// decode%sFrom decodes a %s from the first %d bytes of span, which the caller has checked are there\n", typeName, typeName, typeDef.FixedSize))
+	buf.WriteString(fmt.Sprintf("func decode%sFrom(span []byte, result *%s) {\n", typeName, typeName))
+	generateInlineReads(buf, typeDef.Sequence, defaultEndianness, "\t")
+	buf.WriteString("}\n\n")
+}
+
+// generateDecodeFixedSize emits the start of decodeXInto for a type of a fixed size: one
+// bounds check, then the whole value from one slice. Unaligned or short input, and traced
+// decoders, go on to the bitstream reads after it.
+func generateDecodeFixedSize(buf *bytes.Buffer, typeName string, typeDef *TypeDef) {
+	buf.WriteString(fmt.Sprintf("\tif span, ok := decoder.PeekAligned(%d); ok && !traceEnabled {\n", typeDef.FixedSize))
+	buf.WriteString(fmt.Sprintf("\t\tdecode%sFrom(span, result)\n", typeName))
+	buf.WriteString(fmt.Sprintf("\t\tdecoder.SkipBytes(%d)\n", typeDef.FixedSize))
+	buf.WriteString("\t\treturn result, nil\n")
+	buf.WriteString("\t}\n\n")
+}
+
+// generateDecodeInlineRun decodes a run of fixed-width fields from a slice of the input
+// after one bounds check. Unaligned or short input, and traced builds, take the
+// bitstream reads instead, so errors and traces are the same as without the fast path.
+func generateDecodeInlineRun(buf *bytes.Buffer, fields []Field, defaultEndianness string) error {
+	size := 0
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		size += field.InlineWidth
+		names[i] = field.Name
+	}
+
+	buf.WriteString(fmt.Sprintf("\t// %s: %d bytes\n", strings.Join(names, ", "), size))
+	buf.WriteString(fmt.Sprintf("\tif span, ok := decoder.PeekAligned(%d); ok && !traceEnabled {\n", size))
+	generateInlineReads(buf, fields, defaultEndianness, "\t\t")
+	buf.WriteString(fmt.Sprintf("\t\tdecoder.SkipBytes(%d)\n", size))
+	buf.WriteString("\t} else {\n")
+	for _, field := range fields {
+		endianness := fieldEndianness(field, defaultEndianness)
+		if err := generateTracedDecodeField(buf, field, field.goName(), field.localName(), endianness, mapEndianness(endianness), "\t\t"); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("\t}\n\n")
+	return nil
+}
+
+// generateInlineReads emits the reads of fixed-width fields from span, one after another
+func generateInlineReads(buf *bytes.Buffer, fields []Field, defaultEndianness, indent string) {
+	size := 0
+	for _, field := range fields {
+		size += field.InlineWidth
+	}
+	buf.WriteString(fmt.Sprintf("%s_ = span[%d]\n", indent, size-1))
+	offset := 0
+	for _, field := range fields {
+		fieldName := field.goName()
+		if inlineWidths[field.Type] == 0 && field.FlagsRepr == "" {
+			// A nested struct of a fixed size
+			buf.WriteString(fmt.Sprintf("%sdecode%sFrom(span[%d:], &result.%s)\n", indent, capitalizeFirst(field.Type), offset, fieldName))
+		} else {
+			buf.WriteString(fmt.Sprintf("%sresult.%s = %s\n", indent, fieldName, inlineRead(field, "span", offset, fieldEndianness(field, defaultEndianness))))
+		}
+		offset += field.InlineWidth
+	}
+}
+
+// inlineRead returns the expression reading a number or flag set at offset in span
+func inlineRead(field Field, span string, offset int, endianness string) string {
+	typ := field.Type
+	if field.FlagsRepr != "" {
+		typ = field.FlagsRepr
+	}
+	order := "binary.BigEndian"
+	if endianness == "little_endian" {
+		order = "binary.LittleEndian"
+	}
+
+	var read string
+	switch inlineWidths[typ] {
+	case 1:
+		read = fmt.Sprintf("%s[%d]", span, offset)
+	default:
+		read = fmt.Sprintf("%s.Uint%d(%s[%d:])", order, inlineWidths[typ]*8, span, offset)
+	}
+	switch typ {
+	case "int8", "int16", "int32", "int64":
+		read = fmt.Sprintf("%s(%s)", typ, read)
+	case "float32":
+		read = fmt.Sprintf("math.Float32frombits(%s)", read)
+	case "float64":
+		read = fmt.Sprintf("math.Float64frombits(%s)", read)
+	}
+	if field.FlagsRepr != "" {
+		read = fmt.Sprintf("%s(%s)", capitalizeFirst(field.Type), read)
+	}
+	return read
+}
+
+// fieldEndianness returns a field's own endianness, or the schema's
+func fieldEndianness(field Field, defaultEndianness string) string {
+	if field.Endianness != "" {
+		return field.Endianness
+	}
+	return defaultEndianness
+}
@@ -0,0 +1,57 @@
+// ABOUTME: codec fields: hand-written reads and writes registered with runtime.RegisterCodec
+// ABOUTME: The field keeps the Go type of its schema type; the registered codec decides its bytes
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// checkCodec checks a codec attribute: a name, on a field nothing else says how to
+// encode
+func (v *validator) checkCodec(path string, field map[string]interface{}, codec interface{}) {
+	if name, ok := codec.(string); !ok || name == "" {
+		v.errorf(path, "codec must be the name of a registered codec, got %v", codec)
+	}
+	for _, attr := range []string{"lazy", "transform", "const", "computed"} {
+		if _, ok := field[attr]; ok {
+			v.errorf(path, "codec fields can't also have %s: the codec alone writes them", attr)
+		}
+	}
+}
+
+// generateEncodeCodec writes a codec field's value with its registered codec
+func generateEncodeCodec(buf *bytes.Buffer, field Field, fieldName, indent string) error {
+	goType, err := mapTypeToGo(field)
+	if err != nil {
+		return err
+	}
+	what := field.Name
+	if what == "" {
+		what = "array item"
+	}
+	buf.WriteString(fmt.Sprintf("%sif err := runtime.EncodeCustom[%s](%q, encoder, %s); err != nil {\n", indent, goType, field.Codec, fieldName))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%w\", err)\n", indent, what))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	return nil
+}
+
+// generateDecodeCodec reads a codec field's value with its registered codec
+func generateDecodeCodec(buf *bytes.Buffer, field Field, fieldName, varName, indent string) error {
+	goType, err := mapTypeToGo(field)
+	if err != nil {
+		return err
+	}
+	what := field.Name
+	if what == "" {
+		what = "array item"
+	}
+	buf.WriteString(fmt.Sprintf("%s%s, err := runtime.DecodeCustom[%s](%q, decoder)\n", indent, varName, goType, field.Codec))
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%w\", err)\n", indent, what))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	if fieldName != "" {
+		buf.WriteString(fmt.Sprintf("%sresult.%s = %s\n\n", indent, fieldName, varName))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// ABOUTME: Flag sets: an unsigned integer whose bits are named flags ("fin", "syn", "ack")
+// ABOUTME: Generated as a Go integer type with a constant per flag, Has/Set/Clear and a String listing the flags
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// markFlagFields sets FlagsRepr on the fields and array items whose type is a flag set,
+// so they are encoded and decoded as its integer rather than as a nested type
+func markFlagFields(schema *Schema) {
+	mark := func(field *Field) {
+		if typeDef, ok := schema.Types[field.Type]; ok && typeDef.Flags != nil {
+			field.FlagsRepr = typeDef.Repr
+		}
+	}
+	for _, typeDef := range schema.Types {
+		for _, field := range typeDef.fieldPointers() {
+			mark(field)
+			if field.Items != nil {
+				mark(field.Items)
+			}
+		}
+	}
+}
+
+// parseFlags reads a flag set's repr and its variants, flag name -> bits
+func parseFlags(data map[string]interface{}) (string, map[string]uint64) {
+	repr, _ := data["repr"].(string)
+	flags := make(map[string]uint64)
+	variants, _ := data["variants"].(map[string]interface{})
+	for name, value := range variants {
+		if n, ok := value.(float64); ok {
+			flags[name] = uint64(n)
+		}
+	}
+	return repr, flags
+}
+
+// sortedFlags returns a flag set's names by value, then name
+func sortedFlags(flags map[string]uint64) []string {
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := flags[names[i]], flags[names[j]]
+		return a < b || (a == b && names[i] < names[j])
+	})
+	return names
+}
+
+// generateFlags emits a flag set type, its flag constants and its methods
+func generateFlags(buf *bytes.Buffer, typeName string, typeDef *TypeDef) {
+	name := capitalizeFirst(typeName)
+	description := typeDef.Description
+	if description == "" {
+		description = "is a set of flags stored as a " + typeDef.Repr
+	}
+	buf.WriteString(fmt.Sprintf("// %s %s\n", name, description))
+	buf.WriteString(fmt.Sprintf("type %s %s\n\n", name, typeDef.Repr))
+
+	names := sortedFlags(typeDef.Flags)
+	buf.WriteString("const (\n")
+	for _, flag := range names {
+		buf.WriteString(fmt.Sprintf("\t%s%s %s = %#x\n", name, capitalizeFirst(flag), name, typeDef.Flags[flag]))
+	}
+	buf.WriteString(")\n\n")
+
+	buf.WriteString(fmt.Sprintf("var flagNames%s = []runtime.FlagName{\n", name))
+	for _, flag := range names {
+		buf.WriteString(fmt.Sprintf("\t{Value: %#x, Name: %q},\n", typeDef.Flags[flag], flag))
+	}
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// Has reports whether all of flags are set\n")
+	buf.WriteString(fmt.Sprintf("func (s %s) Has(flags %s) bool {\n", name, name))
+	buf.WriteString("\treturn s&flags == flags\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// Set sets flags\n")
+	buf.WriteString(fmt.Sprintf("func (s *%s) Set(flags %s) {\n", name, name))
+	buf.WriteString("\t*s |= flags\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// Clear clears flags\n")
+	buf.WriteString(fmt.Sprintf("func (s *%s) Clear(flags %s) {\n", name, name))
+	buf.WriteString("\t*s &^= flags\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// String lists the flags that are set, as \"syn|ack\"\n")
+	buf.WriteString(fmt.Sprintf("func (s %s) String() string {\n", name))
+	buf.WriteString(fmt.Sprintf("\treturn runtime.FormatFlags(uint64(s), flagNames%s)\n", name))
+	buf.WriteString("}\n\n")
+}
+
+// generateEncodeFlags emits the write of a flag set field as its integer
+func generateEncodeFlags(buf *bytes.Buffer, field Field, fieldName, endianness, runtimeEndianness, indent string) error {
+	stored := Field{Name: field.Name, Type: field.FlagsRepr}
+	return generateEncodeFieldImpl(buf, stored, fmt.Sprintf("%s(%s)", field.FlagsRepr, fieldName), endianness, runtimeEndianness, indent)
+}
+
+// generateDecodeFlags emits the read of a flag set field's integer, converted to the set's type
+func generateDecodeFlags(buf *bytes.Buffer, field Field, fieldName, varName, endianness, runtimeEndianness, indent string) error {
+	stored := Field{Name: field.Name, Type: field.FlagsRepr}
+	if err := generateDecodeFieldImpl(buf, stored, "", varName+"_flags", endianness, runtimeEndianness, indent); err != nil {
+		return err
+	}
+	buf.WriteString(fmt.Sprintf("%s%s := %s(%s_flags)\n", indent, varName, capitalizeFirst(field.Type), varName))
+	if fieldName != "" {
+		buf.WriteString(fmt.Sprintf("%sresult.%s = %s\n\n", indent, fieldName, varName))
+	}
+	return nil
+}
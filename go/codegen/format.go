@@ -0,0 +1,122 @@
+// ABOUTME: Generates String()/GoString() methods and the DumpAnnotated debug helper
+// ABOUTME: Formatting and decode tracing are delegated to runtime.Formatter and runtime.Trace
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// generateFormatMethods emits String(), GoString() and the format method they share
+func generateFormatMethods(buf *bytes.Buffer, name string, typeDef *TypeDef) error {
+	recv := typeDef.receiver()
+	buf.WriteString(fmt.Sprintf("// String returns a readable one-line rendering of %s using schema field names\n", name))
+	buf.WriteString(fmt.Sprintf("func (%s *%s) String() string {\n", recv, name))
+	buf.WriteString(fmt.Sprintf("\tif %s == nil {\n", recv))
+	buf.WriteString("\t\treturn \"nil\"\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tf := &runtime.Formatter{}\n")
+	buf.WriteString(fmt.Sprintf("\t%s.format(f)\n", recv))
+	buf.WriteString("\treturn f.String()\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("// GoString returns %s as a Go composite literal, for %%#v\n", name))
+	buf.WriteString(fmt.Sprintf("func (%s *%s) GoString() string {\n", recv, name))
+	buf.WriteString(fmt.Sprintf("\tif %s == nil {\n", recv))
+	buf.WriteString(fmt.Sprintf("\t\treturn \"(*%s)(nil)\"\n", name))
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tf := &runtime.Formatter{GoSyntax: true}\n")
+	buf.WriteString("\tf.Pointer()\n")
+	buf.WriteString(fmt.Sprintf("\t%s.format(f)\n", recv))
+	buf.WriteString("\treturn f.String()\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("func (%s *%s) format(f *runtime.Formatter) {\n", recv, name))
+	buf.WriteString(fmt.Sprintf("\tf.BeginStruct(%q)\n", name))
+	for _, field := range typeDef.allFields() {
+		fieldName := field.goName()
+		buf.WriteString(fmt.Sprintf("\tf.Field(%q, %q)\n", field.Name, fieldName))
+		if err := generateFormatValue(buf, field, recv+"."+fieldName, "\t", 0); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("\tf.EndStruct()\n")
+	buf.WriteString("}\n\n")
+	return nil
+}
+
+// generateFormatValue emits the formatter calls for one value of a field's type
+func generateFormatValue(buf *bytes.Buffer, field Field, expr, indent string, depth int) error {
+	switch {
+	case field.Lazy:
+		// Formatting decodes the value; a decode error is shown in its place
+		return generateLazyValue(buf, field, expr, indent, "f.Value(err)", func(inner Field, value string) error {
+			return generateFormatValue(buf, inner, value, indent+"\t", depth)
+		})
+	case field.ZeroCopy:
+		buf.WriteString(fmt.Sprintf("%sf.Value(string(%s))\n", indent, expr))
+	case isScalarType(field.Type), field.FlagsRepr != "":
+		buf.WriteString(fmt.Sprintf("%sf.Value(%s)\n", indent, expr))
+	case field.Type == "array":
+		if field.Items == nil {
+			return fmt.Errorf("array field missing items definition")
+		}
+		// Slices of scalars are formatted whole
+		if isScalarType(field.Items.Type) && !field.Items.ZeroCopy {
+			buf.WriteString(fmt.Sprintf("%sf.Value(%s)\n", indent, expr))
+			return nil
+		}
+		goType, err := mapTypeToGo(field)
+		if err != nil {
+			return err
+		}
+		index := fmt.Sprintf("i%d", depth)
+		buf.WriteString(fmt.Sprintf("%sf.BeginList(%q)\n", indent, goType))
+		buf.WriteString(fmt.Sprintf("%sfor %s := range %s {\n", indent, index, expr))
+		buf.WriteString(fmt.Sprintf("%s\tf.Item()\n", indent))
+		if err := generateFormatValue(buf, *field.Items, fmt.Sprintf("%s[%s]", expr, index), indent+"\t", depth+1); err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		buf.WriteString(fmt.Sprintf("%sf.EndList()\n", indent))
+	case field.Pointer || field.Union:
+		buf.WriteString(fmt.Sprintf("%sif %s == nil {\n", indent, expr))
+		buf.WriteString(fmt.Sprintf("%s\tf.Nil()\n", indent))
+		buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\tf.Pointer()\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\t%s.format(f)\n", indent, expr))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	default:
+		buf.WriteString(fmt.Sprintf("%s%s.format(f)\n", indent, expr))
+	}
+	return nil
+}
+
+// isScalarType reports whether a schema type maps to a Go scalar fmt can print directly
+func isScalarType(typeName string) bool {
+	switch typeName {
+	case "uint8", "uint16", "uint32", "uint64", "uint128", "int8", "int16", "int32", "int64", "int128", "float32", "float64", "string", "bit", "int",
+		"uuid", "mac", "ipv4", "ipv6", "unix32", "unix64", "unix_milli", "ntp", "bcd", "packed_bcd":
+		return true
+	}
+	return false
+}
+
+// generateDumpAnnotated emits DumpAnnotated, which decodes bytes as the root type with a
+// runtime.Trace attached, taking the traced decoders in every build. funcName is renamed
+// like the types, so schemas can share a package.
+func generateDumpAnnotated(buf *bytes.Buffer, funcName, typeName, bitOrder string) {
+	buf.WriteString(fmt.Sprintf("// %s decodes data as %s and returns a hex dump annotated with each\n", funcName, typeName))
+	buf.WriteString("// field's offset, bytes and decoded value. If decoding fails the dump shows the\n")
+	buf.WriteString("// fields read up to the failure and the decode error is returned with it.\n")
+	buf.WriteString(fmt.Sprintf("func %s(data []byte) (string, error) {\n", funcName))
+	buf.WriteString("\ttrace := &runtime.Trace{}\n")
+	buf.WriteString(fmt.Sprintf("\tdecoder := runtime.NewBitStreamDecoder(data, %s)\n", runtimeBitOrder(bitOrder)))
+	buf.WriteString("\tdecoder.Trace = trace\n")
+	buf.WriteString(fmt.Sprintf("\tif _, err := decode%sWithDecoder(decoder, nil); err != nil {\n", typeName))
+	buf.WriteString("\t\tdecoder.TraceAbort()\n")
+	buf.WriteString(fmt.Sprintf("\t\treturn trace.Dump(%q, data), err\n", typeName))
+	buf.WriteString("\t}\n")
+	buf.WriteString(fmt.Sprintf("\treturn trace.Dump(%q, data), nil\n", typeName))
+	buf.WriteString("}\n\n")
+}
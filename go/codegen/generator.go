@@ -5,108 +5,471 @@ package codegen
 import (
 	"bytes"
 	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
 	"strings"
 	"text/template"
 )
 
 // Schema represents a BinSchema definition
 type Schema struct {
-	Config *SchemaConfig          `json:"config"`
-	Types  map[string]*TypeDef    `json:"types"`
+	Meta   *SchemaMeta         `json:"meta,omitempty"`
+	Config *SchemaConfig       `json:"config"`
+	Types  map[string]*TypeDef `json:"types"`
+}
+
+// SchemaMeta holds documentation metadata for a schema
+type SchemaMeta struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version,omitempty"`
 }
 
 // SchemaConfig contains schema-level configuration
 type SchemaConfig struct {
-	Endianness string `json:"endianness"` // "big_endian" or "little_endian"
+	Endianness string `json:"endianness"` // "big_endian", "little_endian" or "dynamic" (chosen by a selects_endianness field)
 	BitOrder   string `json:"bit_order"`  // "msb_first" or "lsb_first"
+
+	// Version and Magic identify a version of a schema for GenerateVersionDispatch
+	Version *VersionField `json:"version,omitempty"`
+	Magic   *VersionField `json:"magic,omitempty"`
+
+	InvalidUTF8 string `json:"invalid_utf8,omitempty"` // What decoded strings that aren't valid UTF-8 become: "keep" (default), "reject" or "replace"
+}
+
+// VersionField is a field of a schema's root type, by path ("header.version"), and the
+// value it holds in messages of this version of the schema
+type VersionField struct {
+	Field string `json:"field"`
+	Value uint64 `json:"value"`
 }
 
 // TypeDef represents a type definition
 type TypeDef struct {
-	Sequence []Field `json:"sequence"`
+	Sequence    []Field `json:"sequence"`
+	Instances   []Field `json:"instances,omitempty"` // Fields decoded at a position in the data rather than in sequence
+	Description string  `json:"description,omitempty"`
+	Recursive   bool    `json:"-"`                   // Set by markRecursiveTypes: type can (indirectly) contain itself
+	Bitfield    bool    `json:"-"`                   // Hoisted from an inline bitfield: encoded inline by the parent, no Encode/Decode of its own
+	BitOrder    string  `json:"bit_order,omitempty"` // "msb_first" or "lsb_first", overriding the config; resolveBitOrders fills it in
+
+	SwitchesBitOrder bool `json:"-"` // Set by resolveBitOrders: decoding sets the shared decoder's bit order and restores it after
+	FixedSize        int  `json:"-"` // Set by markFixedSizes: byte size of a struct of fixed-width fields only, decoded from one slice
+	Cast             bool `json:"-"` // Set by markCastTypes: laid out in Go like its encoding, so bytes can be cast to it
+	DecodesUTF8      bool `json:"-"` // Set by markUTF8Strings: decoding reads a utf8 string, itself or in the types it references
+
+	Protobuf   bool   `json:"protobuf,omitempty"` // Encoded in the protobuf wire format: fields tagged with their field_number, in any order
+	Alias      bool   `json:"-"`                  // A type alias ("Label": {"type": "string"}): a struct of one field, Value
+	Receiver   string `json:"-"`                  // Set by markReceivers: the receiver of the struct's methods, "m" if empty
+	SchemaName string `json:"-"`                  // Set by renameTypes: the schema's name for the type, when its Go name differs
+
+	// Flag sets (generated as Go integer types) have these instead of a sequence
+	Flags map[string]uint64 `json:"-"`              // Flag name -> its bits, from "variants"
+	Repr  string            `json:"repr,omitempty"` // Unsigned integer type the set is stored as
+
+	// Discriminated unions (generated as Go interfaces) have these instead of a sequence
+	Discriminator *Discriminator `json:"discriminator,omitempty"`
+	Variants      []Variant      `json:"variants,omitempty"`
+}
+
+// allFields returns the sequence fields followed by the instance fields: all fields of the Go struct
+func (t *TypeDef) allFields() []Field {
+	return append(t.Sequence[:len(t.Sequence):len(t.Sequence)], t.Instances...)
+}
+
+// fieldPointers is allFields for passes that annotate the fields in place
+func (t *TypeDef) fieldPointers() []*Field {
+	fields := make([]*Field, 0, len(t.Sequence)+len(t.Instances))
+	for i := range t.Sequence {
+		fields = append(fields, &t.Sequence[i])
+	}
+	for i := range t.Instances {
+		fields = append(fields, &t.Instances[i])
+	}
+	return fields
+}
+
+// Discriminator selects a union variant from a value peeked ahead of it
+type Discriminator struct {
+	Peek       string `json:"peek,omitempty"`  // Peeked type: "uint8", "uint16", "uint32"
+	Field      string `json:"field,omitempty"` // Earlier field (not supported by this generator)
+	Endianness string `json:"endianness,omitempty"`
+}
+
+// Variant is one alternative of a discriminated union
+type Variant struct {
+	Type        string `json:"type"`
+	When        string `json:"when,omitempty"` // Condition on the discriminator ("value >= 0xC0"); empty for the fallback
+	Description string `json:"description,omitempty"`
+	SchemaName  string `json:"-"` // Set by renameTypes: the schema name of the variant type, which JSON keeps
 }
 
 // Field represents a field in a struct
 type Field struct {
-	Name           string                 `json:"name"`
-	Type           string                 `json:"type"`
-	Kind           string                 `json:"kind,omitempty"`            // For arrays/strings: "fixed", "length_prefixed", "null_terminated", "length_prefixed_items"
-	Length         interface{}            `json:"length,omitempty"`          // For fixed arrays: int or string (field reference)
-	LengthType     string                 `json:"length_type,omitempty"`     // For length_prefixed: "uint8", "uint16", etc.
-	ItemLengthType string                 `json:"item_length_type,omitempty"` // For length_prefixed_items: per-item length type
-	Items          *Field                 `json:"items,omitempty"`           // For arrays: item type
-	Encoding       string                 `json:"encoding,omitempty"`        // For strings: "utf8", "ascii"
-	Optional       bool                   `json:"optional,omitempty"`
-	Conditional    string                 `json:"conditional,omitempty"` // Conditional expression (e.g., "present == 1")
-	Endianness     string                 `json:"endianness,omitempty"`  // Per-field endianness override
-	Fields         []Field                `json:"fields,omitempty"`      // For inline structs
-	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	Name            string                 `json:"name"`
+	GoName          string                 `json:"go_name,omitempty"` // The Go struct field, instead of the capitalized name
+	Type            string                 `json:"type"`
+	Kind            string                 `json:"kind,omitempty"`             // For arrays/strings: "fixed", "length_prefixed", "null_terminated", "length_prefixed_items"
+	Length          interface{}            `json:"length,omitempty"`           // For fixed arrays/strings: int, or string length expression ("rdlength - 2")
+	LengthField     string                 `json:"length_field,omitempty"`     // For field_referenced: earlier field (or expression) holding the length
+	CountExpr       string                 `json:"count_expr,omitempty"`       // For computed_count arrays: item count expression ("width * height")
+	LengthType      string                 `json:"length_type,omitempty"`      // For length_prefixed: "uint8", "uint16", etc.
+	ItemLengthType  string                 `json:"item_length_type,omitempty"` // For length_prefixed_items: per-item length type
+	Items           *Field                 `json:"items,omitempty"`            // For arrays: item type
+	Encoding        string                 `json:"encoding,omitempty"`         // For strings: "utf8", "ascii"
+	Padding         string                 `json:"padding,omitempty"`          // For fixed strings: "null" (default), "space" or "none", the bytes after the value
+	MaxLength       int                    `json:"max_length,omitempty"`       // For null-terminated strings: the most bytes before the terminator, 0 for no limit
+	Optional        bool                   `json:"optional,omitempty"`
+	Lazy            bool                   `json:"lazy,omitempty"`        // Decoded as a runtime.Lazy holding its bytes, decoded on first access
+	Splittable      bool                   `json:"splittable,omitempty"`  // For arrays: the items can be split across messages fitting a byte budget (EncodeXFit)
+	Transform       *FieldTransform        `json:"transform,omitempty"`   // For length_prefixed strings and byte arrays: compression or encryption of the bytes on the wire
+	Codec           string                 `json:"codec,omitempty"`       // Name of a codec registered with runtime.RegisterCodec that reads and writes the field
+	Conditional     string                 `json:"conditional,omitempty"` // Conditional expression (e.g., "present == 1")
+	Endianness      string                 `json:"endianness,omitempty"`  // Per-field endianness override
+	Fields          []Field                `json:"fields,omitempty"`      // For inline structs and bitfields
+	Size            int                    `json:"size,omitempty"`        // For bit and int: width in bits
+	AlignTo         int                    `json:"align_to,omitempty"`    // For padding: the byte multiple the next field starts at
+	Signed          bool                   `json:"signed,omitempty"`      // Bit fields and bitfield subfields: two's complement
+	Unit            string                 `json:"unit,omitempty"`        // For unix timestamps: "s", "ms", "us" or "ns" since the epoch
+	Epoch           string                 `json:"epoch,omitempty"`       // For unix timestamps: RFC 3339 time counted from, instead of 1970
+	Digits          int                    `json:"digits,omitempty"`      // For bcd and packed_bcd: decimal digit count
+	DecodeAs        string                 `json:"decode_as,omitempty"`   // For bcd and packed_bcd: "int" (int64, the default) or "string" (keeps leading zeros)
+	Description     string                 `json:"description,omitempty"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+	Pointer         bool                   `json:"-"`                  // Nested type generated as *T: set by GenerateOptions or by markRecursiveTypes to break a by-value cycle
+	Bitfield        bool                   `json:"-"`                  // Inline bitfield hoisted to a named type: subfields are read and written in place
+	Union           bool                   `json:"-"`                  // References a discriminated union: an interface holding a pointer to the variant
+	ParentContext   bool                   `json:"-"`                  // Set by markParentContext: nested type is given this struct's fields for ../ references
+	OffsetContext   bool                   `json:"-"`                  // Set by markOffsetContext: nested type is told where its output lands, for position_of
+	PositionOf      string                 `json:"-"`                  // Computed position_of: encoded as the byte offset of this sibling field
+	LengthOf        string                 `json:"-"`                  // Computed length_of: the byte length of this sibling field, plus ComputedOffset
+	CountOf         string                 `json:"-"`                  // Computed count_of: the item count of this sibling array
+	ComputedOffset  int                    `json:"-"`                  // Added to a computed length_of
+	ByteRange       *ByteRange             `json:"-"`                  // Computed length_of or crc32_of the encoded bytes of a run of sibling fields
+	WideRefs        map[string]bool        `json:"-"`                  // Set by markWideRefs: paths its expressions read from uint128/int128 fields
+	GoPaths         map[string]string      `json:"-"`                  // Set by markGoPaths: Go selectors of the paths its expressions read through go_name fields
+	Const           interface{}            `json:"const,omitempty"`    // The value the field always has: a number, or bytes
+	ZeroCopy        bool                   `json:"-"`                  // Set by markZeroCopyStrings: a string decoded as a runtime.ByteString view of the input
+	Canonical       bool                   `json:"-"`                  // Set by markCanonical: encoding rejects values with another encoding and writes NaNs one way
+	ReplaceNonASCII bool                   `json:"-"`                  // Set by markReplaceNonASCII: an ascii string writes '?' for characters that aren't ASCII
+	UTF8Policy      string                 `json:"-"`                  // Set by markUTF8Strings: the runtime UTF8Policy a utf8 string decodes with by default
+	FlagsRepr       string                 `json:"-"`                  // Set by markFlagFields: the type is a flag set stored as this integer type
+	Repr            string                 `json:"repr,omitempty"`     // For inline flag sets and enums: the integer type they are stored as
+	FlagValues      map[string]uint64      `json:"variants,omitempty"` // For inline flag sets: flag name -> its bits
+	InlineWidth     int                    `json:"-"`                  // Set by markFixedSizes: bytes the fast path reads straight from the input, 0 if it needs the bitstream
+	BitOrder        string                 `json:"-"`                  // Set by resolveBitOrders on bitfields: the bit order of the type they are in
+	Receiver        string                 `json:"-"`                  // Set by markReceivers: the receiver its encoder reads the struct's other fields from, "m" if empty
+
+	FieldNumber     int    `json:"field_number,omitempty"` // Fields of protobuf messages: the number tagging the field
+	ProtoType       string `json:"proto_type,omitempty"`   // Integer fields of protobuf messages: "sint32", "fixed32", ... instead of a varint
+	ProtobufMessage bool   `json:"-"`                      // Set by markProtobufFields: references a protobuf message type
+
+	EndiannessContext bool              `json:"-"`                            // Set by markEndiannessContext: nested type is told the byte order chosen so far
+	SelectsEndianness map[string]uint64 `json:"selects_endianness,omitempty"` // Value that selects each byte order ("little_endian": 0x4949), for config endianness "dynamic"
+
+	TerminalVariants []string `json:"terminal_variants,omitempty"` // For null_terminated/variant_terminated arrays of unions: variants that end the array
+
+	BackReference *BackReference   `json:"-"` // For back_reference: Type is the target type, read from where the pointer leads
+	Names         *NameCompression `json:"-"` // Set by markNameCompression on arrays of unions ending in a back_reference variant
+
+	ParentAccess map[string]*parentAccess `json:"-"` // Set by resolveParentRefs: how each ../ path in the field reads the enclosing struct
+
+	// Instance fields only
+	Position     interface{} `json:"position,omitempty"`  // Byte offset: a number (negative counts back from the end) or an expression ("index.data_offset")
+	InstanceSize interface{} `json:"-"`                   // Optional byte size at the position: a number or an expression
+	Alignment    int         `json:"alignment,omitempty"` // Position must be a multiple of this
+	Offsets      string      `json:"offsets,omitempty"`   // Arrays only, instead of a position: the sequence array of unsigned integers holding each item's position
 }
 
-
 // GenerateGo generates Go code from a BinSchema definition
 // Always generates all types in the schema for simplicity
 func GenerateGo(schemaData map[string]interface{}, typeName string) (string, error) {
-	// Parse schema
-	schema, err := parseSchema(schemaData)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse schema: %w", err)
-	}
+	return GenerateGoWithOptions(schemaData, typeName, GenerateOptions{})
+}
 
-	// Verify the requested type exists
-	if _, ok := schema.Types[typeName]; !ok {
-		return "", fmt.Errorf("type %s not found in schema", typeName)
+// GenerateGoWithOptions generates Go code like GenerateGo, with options for the shape of generated types
+func GenerateGoWithOptions(schemaData map[string]interface{}, typeName string, opts GenerateOptions) (string, error) {
+	schema, typeName, endianness, err := prepareSchema(schemaData, typeName, opts)
+	if err != nil {
+		return "", err
 	}
 
-	// Determine default endianness
-	endianness := "big_endian"
-	if schema.Config != nil && schema.Config.Endianness != "" {
-		endianness = schema.Config.Endianness
+	// Back references point into the whole message, so Encode gives it one context
+	encodeCtx := "nil"
+	if usesBackReferences(schema) {
+		encodeCtx = compressionContext(schema, opts.CompressNames)
 	}
 
-	// Generate code
+	// Generate type code first so imports can be derived from what it uses
 	var buf bytes.Buffer
 
-	// Package and imports
-	buf.WriteString("package main\n\n")
-	buf.WriteString("import (\n")
-	buf.WriteString("\t\"github.com/serialexp/binschema/runtime\"\n")
-	buf.WriteString(")\n\n")
-
 	// Generate ALL types in the schema (simpler - always same logic)
-	// Types are generated in map iteration order which is fine since Go
-	// doesn't require forward declarations
-	for name, typeDef := range schema.Types {
+	// Types are generated dependencies-first in a stable order so output is reproducible
+	for _, name := range typeOrder(schema) {
+		typeDef := schema.Types[name]
+
+		// Discriminated unions are interfaces over their variants
+		if typeDef.Discriminator != nil {
+			if err := checkUnion(schema, name, typeDef); err != nil {
+				return "", err
+			}
+			if err := generateUnion(&buf, name, typeDef, endianness, opts.Direction); err != nil {
+				return "", err
+			}
+			if opts.ExportDecoders && opts.Direction.decodes() {
+				generateExportedDecoder(&buf, name, name)
+			}
+			generateUnionJSON(&buf, name, typeDef)
+			if opts.SelfDescribing {
+				generateUnionEmit(&buf, name, typeDef)
+			}
+			generateUnionMatch(&buf, name, typeDef)
+			generateUnionEqual(&buf, name, typeDef)
+			generateUnionClone(&buf, name, typeDef)
+			continue
+		}
+
+		// Flag sets are integer types with named bits
+		if typeDef.Flags != nil {
+			generateFlags(&buf, name, typeDef)
+			continue
+		}
+
 		// Generate struct type
 		if err := generateStruct(&buf, name, typeDef); err != nil {
 			return "", err
 		}
+		generateVariantAccessors(&buf, schema, name, typeDef)
+		generateNameHelpers(&buf, schema, name, typeDef)
+		if opts.Builders && !typeDef.Bitfield {
+			if err := generateBuilder(&buf, schema, name, typeDef); err != nil {
+				return "", err
+			}
+		}
+
+		// Bitfields aren't byte-aligned: their parents encode and decode them in place
+		if !typeDef.Bitfield {
+			// Generate Encode method
+			if opts.Direction.encodes() {
+				if err := generateEncodeMethod(&buf, name, typeDef, endianness, encodeCtx); err != nil {
+					return "", err
+				}
+				if err := generateEncodeFit(&buf, schema, name, typeDef); err != nil {
+					return "", err
+				}
+			}
+
+			// Generate Decode function
+			if opts.Direction.decodes() {
+				if err := generateDecodeFunction(&buf, name, typeDef, endianness, opts); err != nil {
+					return "", err
+				}
+				if opts.ExportDecoders {
+					generateExportedDecoder(&buf, name, "*"+name)
+				}
+
+				if opts.ExtractFields && extractable(typeDef) {
+					if err := generateExtract(&buf, schema, name, typeDef, endianness); err != nil {
+						return "", err
+					}
+				}
+				if err := generateDecodeEach(&buf, name, typeDef, endianness); err != nil {
+					return "", err
+				}
+			}
+
+			if typeDef.Cast {
+				generateCasts(&buf, name, typeDef, opts.Direction)
+			}
+
+			if opts.BinaryCodecs {
+				generateBinaryMethods(&buf, name, typeDef)
+			}
+			// An alias's Value field leaves no room for driver.Valuer's Value method
+			if opts.SQL && !typeDef.Alias {
+				generateSQLMethods(&buf, name, typeDef)
+			}
+		}
+
+		// Generate String and GoString methods
+		if err := generateFormatMethods(&buf, name, typeDef); err != nil {
+			return "", err
+		}
+
+		// Generate Equal and Hash methods
+		if err := generateEqualMethods(&buf, schema, name, typeDef); err != nil {
+			return "", err
+		}
 
-		// Generate Encode method
-		if err := generateEncodeMethod(&buf, name, typeDef, endianness); err != nil {
+		// Generate MarshalJSON and UnmarshalJSON methods
+		if err := generateJSONMethods(&buf, name, typeDef); err != nil {
 			return "", err
 		}
 
-		// Generate Decode function
-		if err := generateDecodeFunction(&buf, name, typeDef, endianness); err != nil {
+		// Generate MarshalCBOR and MarshalMessagePack methods
+		if opts.SelfDescribing {
+			if err := generateEmitMethods(&buf, name, typeDef); err != nil {
+				return "", err
+			}
+		}
+
+		// Generate Clone method
+		if err := generateClone(&buf, schema, name, typeDef); err != nil {
 			return "", err
 		}
 	}
 
-	return buf.String(), nil
+	// Annotated hex dump of the requested type, for debugging byte mismatches
+	if opts.Direction.decodes() {
+		generateDumpAnnotated(&buf, goTypeName(opts, "DumpAnnotated"), typeName, schema.Types[typeName].BitOrder)
+	}
+
+	// Runtime descriptor of the schema
+	if err := generateSchemaDescriptor(&buf, schema, endianness, opts); err != nil {
+		return "", err
+	}
+
+	// Package and imports
+	var out bytes.Buffer
+	generateFileHeader(&out, schema.Meta)
+	out.WriteString("package main\n\n")
+	out.WriteString("import (\n")
+	stdlib := false
+	used := usedPackages(buf.Bytes())
+	for _, pkg := range []string{"context", "database/sql/driver", "encoding/binary", "encoding/json", "fmt", "math", "net/netip", "time", "unsafe"} {
+		if used[pkg[strings.LastIndex(pkg, "/")+1:]] {
+			out.WriteString(fmt.Sprintf("\t%q\n", pkg))
+			stdlib = true
+		}
+	}
+	if stdlib {
+		out.WriteString("\n")
+	}
+	out.WriteString("\t\"github.com/serialexp/binschema/runtime\"\n")
+	out.WriteString(")\n\n")
+	out.Write(buf.Bytes())
+
+	// gofmt the result so regenerated files diff cleanly
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("generated code does not parse: %w", err)
+	}
+	if opts.Receiver != "" {
+		if err := checkReceiver(formatted, opts.Receiver); err != nil {
+			return "", err
+		}
+	}
+	return string(formatted), nil
+}
+
+// prepareSchema validates and parses a schema for generating Go code with opts, and
+// runs the passes annotating it. It returns the parsed schema, the Go name of typeName
+// and the default endianness.
+func prepareSchema(schemaData map[string]interface{}, typeName string, opts GenerateOptions) (*Schema, string, string, error) {
+	if err := checkAttributes(schemaData, opts.UnknownAttributes, opts.Warn); err != nil {
+		return nil, "", "", err
+	}
+	if err := checkNamingOptions(opts); err != nil {
+		return nil, "", "", err
+	}
+	if err := checkDirection(opts); err != nil {
+		return nil, "", "", err
+	}
+	if err := schemaError(ValidateSchema(schemaData)); err != nil {
+		return nil, "", "", err
+	}
+
+	// Parse schema
+	schema, err := parseSchema(schemaData)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to parse schema: %w", err)
+	}
+
+	// Verify the requested type exists
+	if _, ok := schema.Types[typeName]; !ok {
+		return nil, "", "", fmt.Errorf("type %s not found in schema", typeName)
+	}
+
+	if err := nameFields(schema, opts); err != nil {
+		return nil, "", "", err
+	}
+
+	// Give inline bitfields, structs and flag sets named types
+	if err := hoistInlineStructs(schema); err != nil {
+		return nil, "", "", err
+	}
+	renameTypes(schema, opts)
+	typeName = goTypeName(opts, typeName)
+	markReceivers(schema, opts.Receiver)
+	markFlagFields(schema)
+	markUnionFields(schema)
+	markNameCompression(schema, opts.CompressNames, opts.ResolveNames)
+	markProtobufFields(schema)
+	resolveBitOrders(schema)
+	markParentContext(schema)
+	markOffsetContext(schema)
+	markEndiannessContext(schema)
+
+	if err := applyPointerOptions(schema, opts); err != nil {
+		return nil, "", "", err
+	}
+	if opts.ZeroCopy {
+		markZeroCopyStrings(schema)
+	}
+	if opts.Canonical {
+		markCanonical(schema)
+	}
+	if opts.ReplaceNonASCII {
+		markReplaceNonASCII(schema)
+	}
+	markUTF8Strings(schema)
+
+	// Break by-value cycles between mutually recursive types
+	markRecursiveTypes(schema)
+	if err := resolveParentRefs(schema); err != nil {
+		return nil, "", "", err
+	}
+	markWideRefs(schema)
+	markGoPaths(schema)
+
+	// Determine default endianness
+	endianness := "big_endian"
+	if schema.Config != nil && schema.Config.Endianness != "" {
+		endianness = schema.Config.Endianness
+	}
+	markFixedSizes(schema, endianness)
+	if err := checkLazyFields(schema, endianness); err != nil {
+		return nil, "", "", err
+	}
+	if err := checkInstances(schema); err != nil {
+		return nil, "", "", err
+	}
+	if err := markCastTypes(schema, opts, endianness); err != nil {
+		return nil, "", "", err
+	}
+
+	return schema, typeName, endianness, nil
 }
 
 func generateStruct(buf *bytes.Buffer, name string, typeDef *TypeDef) error {
+	generateTypeDoc(buf, name, typeDef)
 	buf.WriteString(fmt.Sprintf("type %s struct {\n", name))
 
-	for _, field := range typeDef.Sequence {
+	for _, field := range typeDef.allFields() {
 		goType, err := mapTypeToGo(field)
 		if err != nil {
 			return err
 		}
 
-		// Capitalize field name for export
-		fieldName := capitalizeFirst(field.Name)
+		// Capitalize field name for export; other Go names keep the schema name in a tag
+		fieldName := field.goName()
+		generateFieldDoc(buf, field)
+		if fieldName != capitalizeFirst(field.Name) {
+			buf.WriteString(fmt.Sprintf("\t%s %s `binschema:%q`\n", fieldName, goType, field.Name))
+			continue
+		}
 		buf.WriteString(fmt.Sprintf("\t%s %s\n", fieldName, goType))
 	}
 
@@ -114,26 +477,85 @@ func generateStruct(buf *bytes.Buffer, name string, typeDef *TypeDef) error {
 	return nil
 }
 
-func generateEncodeMethod(buf *bytes.Buffer, typeName string, typeDef *TypeDef, defaultEndianness string) error {
-	buf.WriteString(fmt.Sprintf("func (m *%s) Encode() ([]byte, error) {\n", typeName))
+// generateEncodeMethod emits Encode, which encodes with the context encodeCtx, and
+// EncodeWithContext
+func generateEncodeMethod(buf *bytes.Buffer, typeName string, typeDef *TypeDef, defaultEndianness, encodeCtx string) error {
+	recv := typeDef.receiver()
+	buf.WriteString(fmt.Sprintf("func (%s *%s) Encode() ([]byte, error) {\n", recv, typeName))
+	buf.WriteString(fmt.Sprintf("\treturn %s.EncodeWithContext(%s)\n", recv, encodeCtx))
+	buf.WriteString("}\n\n")
+	generateEncodeWithLimit(buf, typeName, recv, encodeCtx)
+	if defaultEndianness == "dynamic" {
+		generateEncodeWithEndianness(buf, typeName, recv)
+	}
+	if typeDef.Protobuf {
+		return generateEncodeProtobuf(buf, typeName, typeDef)
+	}
+
+	// Nested types are encoded with their parents in ctx, for ../field references.
+	// With instances, EncodeWithContext places them and the sequence has a method of its own.
+	encodeMethod := "EncodeWithContext"
+	if len(typeDef.Instances) > 0 {
+		if err := generateEncodeInstances(buf, typeName, typeDef, defaultEndianness); err != nil {
+			return err
+		}
+		encodeMethod = "encodeSequence"
+	}
+	buf.WriteString(fmt.Sprintf("func (%s *%s) %s(ctx *runtime.EncodingContext) ([]byte, error) {\n", recv, typeName, encodeMethod))
 
-	// Determine bit order (for now always MSBFirst)
-	buf.WriteString("\tencoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)\n\n")
+	buf.WriteString(fmt.Sprintf("\tencoder := runtime.NewBitStreamEncoder(%s)\n", runtimeBitOrder(typeDef.BitOrder)))
+	buf.WriteString("\tencoder.SetLimit(ctx.OutputRemaining())\n")
+	if defaultEndianness == "dynamic" {
+		buf.WriteString("\tencoder.SetEndianness(ctx.ByteOrder())\n")
+	}
+	buf.WriteString("\n")
+	generateEncodeChildContext(buf, typeDef, typeDef.Sequence)
 
 	// Generate encoding logic for each field
-	for _, field := range typeDef.Sequence {
-		if err := generateEncodeField(buf, field, defaultEndianness); err != nil {
-			return err
+	fixups, err := newPositionFixups(typeDef)
+	if err != nil {
+		return err
+	}
+	ranges, err := newRangeFixups(typeDef)
+	if err != nil {
+		return err
+	}
+	for i, field := range typeDef.Sequence {
+		fixups.generateTargetPosition(buf, field, defaultEndianness)
+		ranges.generateStart(buf, i)
+		switch {
+		case field.PositionOf != "":
+			if err := fixups.generateEncode(buf, field, defaultEndianness); err != nil {
+				return err
+			}
+		case field.ByteRange != nil:
+			if err := ranges.generateEncode(buf, field, i, defaultEndianness); err != nil {
+				return err
+			}
+		default:
+			if err := generateEncodeField(buf, field, defaultEndianness); err != nil {
+				return err
+			}
+			generateEndiannessSelect(buf, field, "encoder", recv+"."+field.goName(), "\t")
 		}
+		ranges.generateEnd(buf, i, defaultEndianness)
 	}
 
-	buf.WriteString("\n\treturn encoder.Finish(), nil\n")
+	buf.WriteString("\n\treturn encoder.Result()\n")
 	buf.WriteString("}\n\n")
 	return nil
 }
 
+// generateEncodeChildContext emits childCtx, the context nested types among fields are encoded with
+func generateEncodeChildContext(buf *bytes.Buffer, typeDef *TypeDef, fields []Field) {
+	if !passesParents(fields) {
+		return
+	}
+	buf.WriteString(fmt.Sprintf("\tchildCtx := ctx.ExtendWithParentStruct(%s)\n\n", typeDef.receiver()))
+}
+
 func generateEncodeField(buf *bytes.Buffer, field Field, defaultEndianness string) error {
-	fieldName := "m." + capitalizeFirst(field.Name)
+	fieldName := field.receiver() + "." + field.goName()
 	endianness := field.Endianness
 	if endianness == "" {
 		endianness = defaultEndianness
@@ -142,7 +564,7 @@ func generateEncodeField(buf *bytes.Buffer, field Field, defaultEndianness strin
 
 	// Handle conditional fields
 	if field.Conditional != "" {
-		goCondition := convertConditionalToGo(field.Conditional, "m")
+		goCondition := generateCondition(buf, field, field.receiver(), "\t")
 		buf.WriteString(fmt.Sprintf("\tif %s {\n", goCondition))
 		defer buf.WriteString("\t}\n")
 		// Increase indentation for the conditional block
@@ -156,6 +578,15 @@ func generateEncodeField(buf *bytes.Buffer, field Field, defaultEndianness strin
 }
 
 func generateEncodeFieldImpl(buf *bytes.Buffer, field Field, fieldName, endianness, runtimeEndianness, indent string) error {
+	if field.Lazy {
+		return generateEncodeLazy(buf, field, fieldName, endianness, runtimeEndianness, indent)
+	}
+	if field.Codec != "" {
+		return generateEncodeCodec(buf, field, fieldName, indent)
+	}
+	if field.Transform != nil {
+		return generateEncodeTransform(buf, field, fieldName, runtimeEndianness, indent)
+	}
 	switch field.Type {
 	case "uint8":
 		buf.WriteString(fmt.Sprintf("%sencoder.WriteUint8(%s)\n", indent, fieldName))
@@ -173,27 +604,112 @@ func generateEncodeFieldImpl(buf *bytes.Buffer, field Field, fieldName, endianne
 		buf.WriteString(fmt.Sprintf("%sencoder.WriteInt32(%s, runtime.%s)\n", indent, fieldName, runtimeEndianness))
 	case "int64":
 		buf.WriteString(fmt.Sprintf("%sencoder.WriteInt64(%s, runtime.%s)\n", indent, fieldName, runtimeEndianness))
+	case "uint128":
+		buf.WriteString(fmt.Sprintf("%sencoder.WriteUint128(%s, runtime.%s)\n", indent, fieldName, runtimeEndianness))
+	case "int128":
+		buf.WriteString(fmt.Sprintf("%sencoder.WriteInt128(%s, runtime.%s)\n", indent, fieldName, runtimeEndianness))
+	case "uuid":
+		buf.WriteString(fmt.Sprintf("%sencoder.WriteUUID(%s)\n", indent, fieldName))
+	case "mac":
+		buf.WriteString(fmt.Sprintf("%sencoder.WriteMAC(%s)\n", indent, fieldName))
+	case "ipv4":
+		what := field.Name
+		if what == "" {
+			what = "array item"
+		}
+		buf.WriteString(fmt.Sprintf("%sif err := encoder.WriteIPv4(%s); err != nil {\n", indent, fieldName))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%w\", err)\n", indent, what))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	case "ipv6":
+		buf.WriteString(fmt.Sprintf("%sencoder.WriteIPv6(%s)\n", indent, fieldName))
+	case "unix32", "unix64", "unix_milli", "ntp":
+		return generateEncodeTimestamp(buf, field, fieldName, runtimeEndianness, indent)
+	case "bcd", "packed_bcd":
+		what := field.Name
+		if what == "" {
+			what = "array item"
+		}
+		method := "WriteBCDInt"
+		if field.DecodeAs == "string" {
+			method = "WriteBCD"
+		}
+		buf.WriteString(fmt.Sprintf("%sif err := encoder.%s(%s, %d, %t); err != nil {\n", indent, method, fieldName, field.Digits, field.Type == "packed_bcd"))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%w\", err)\n", indent, what))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
 	case "float32":
-		buf.WriteString(fmt.Sprintf("%sencoder.WriteFloat32(%s, runtime.%s)\n", indent, fieldName, runtimeEndianness))
+		buf.WriteString(fmt.Sprintf("%sencoder.WriteFloat32(%s, runtime.%s)\n", indent, canonicalFloat(field, fieldName), runtimeEndianness))
 	case "float64":
-		buf.WriteString(fmt.Sprintf("%sencoder.WriteFloat64(%s, runtime.%s)\n", indent, fieldName, runtimeEndianness))
+		buf.WriteString(fmt.Sprintf("%sencoder.WriteFloat64(%s, runtime.%s)\n", indent, canonicalFloat(field, fieldName), runtimeEndianness))
+	case "bit":
+		if field.Canonical {
+			generateCanonicalBitsCheck(buf, field, fieldName, indent)
+		}
+		buf.WriteString(fmt.Sprintf("%sencoder.WriteBits(uint64(%s), %d)\n", indent, fieldName, field.Size))
+	case "int":
+		goType, err := mapTypeToGo(field)
+		if err != nil {
+			return err
+		}
+		// Narrower than its Go type: reject values the bits can't hold rather than wrap them
+		if field.Size < goIntBits[goType] {
+			what := field.Name
+			if what == "" {
+				what = "array item"
+			}
+			buf.WriteString(fmt.Sprintf("%sif %s < -%d || %s > %d {\n", indent, fieldName, int64(1)<<(field.Size-1), fieldName, int64(1)<<(field.Size-1)-1))
+			buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%d doesn't fit in %d signed bits\", %s)\n", indent, what, field.Size, fieldName))
+			buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		}
+		buf.WriteString(fmt.Sprintf("%sencoder.WriteBits(uint64(%s), %d)\n", indent, fieldName, field.Size))
 	case "string":
 		return generateEncodeString(buf, field, fieldName, endianness, indent)
 	case "array":
+		if field.Names != nil {
+			return generateEncodeNames(buf, field, fieldName, endianness, runtimeEndianness, indent)
+		}
 		return generateEncodeArray(buf, field, fieldName, endianness, runtimeEndianness, indent)
 	default:
+		if field.BackReference != nil {
+			return generateEncodeBackReference(buf, field, fieldName, endianness, "", indent)
+		}
+		if field.FlagsRepr != "" {
+			return generateEncodeFlags(buf, field, fieldName, endianness, runtimeEndianness, indent)
+		}
+		// Bitfield - subfields written in place, not byte-aligned
+		if field.Bitfield {
+			for _, sub := range field.Fields {
+				if err := generateEncodeFieldImpl(buf, sub, fieldName+"."+sub.goName(), endianness, runtimeEndianness, indent); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if field.ProtobufMessage {
+			return generateEncodeEmbeddedProtobuf(buf, field, fieldName, endianness, indent)
+		}
+
 		// Type reference - nested struct
 		// Generate unique variable name for bytes
 		bytesVar := strings.ReplaceAll(strings.ReplaceAll(fieldName, ".", "_"), "m_", "") + "_bytes"
 
+		// Pointer and union fields must be set when written - there is no wire representation for nil
+		if field.Pointer || field.Union {
+			what := field.Name
+			if what == "" {
+				what = "array item"
+			}
+			buf.WriteString(fmt.Sprintf("%sif %s == nil {\n", indent, fieldName))
+			buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: nested %s is nil\")\n", indent, what, field.Type))
+			buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		}
+
 		// Call the nested struct's Encode method and write the bytes
-		buf.WriteString(fmt.Sprintf("%s%s, err := %s.Encode()\n", indent, bytesVar, fieldName))
+		buf.WriteString(fmt.Sprintf("%s%s, err := %s.EncodeWithContext(%s)\n", indent, bytesVar, fieldName, encodeContextFor(field, 0)))
 		buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
 		buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
 		buf.WriteString(fmt.Sprintf("%s}\n", indent))
-		buf.WriteString(fmt.Sprintf("%sfor _, b := range %s {\n", indent, bytesVar))
-		buf.WriteString(fmt.Sprintf("%s\tencoder.WriteUint8(b)\n", indent))
-		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		writeBytes(buf, bytesVar, indent)
 	}
 
 	return nil
@@ -212,10 +728,10 @@ func generateEncodeString(buf *bytes.Buffer, field Field, fieldName, endianness,
 	if encoding == "utf8" {
 		buf.WriteString(fmt.Sprintf("%s%s := []byte(%s)\n", indent, bytesVar, fieldName))
 	} else if encoding == "ascii" {
-		buf.WriteString(fmt.Sprintf("%s%s := make([]byte, len(%s))\n", indent, bytesVar, fieldName))
-		buf.WriteString(fmt.Sprintf("%sfor i := 0; i < len(%s); i++ {\n", indent, fieldName))
-		buf.WriteString(fmt.Sprintf("%s\t%s[i] = %s[i]\n", indent, bytesVar, fieldName))
-		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		generateASCIIBytes(buf, field, bytesVar, fieldName, indent)
+	}
+	if field.Canonical {
+		generateCanonicalStringCheck(buf, field, bytesVar, indent)
 	}
 
 	switch field.Kind {
@@ -236,31 +752,47 @@ func generateEncodeString(buf *bytes.Buffer, field Field, fieldName, endianness,
 			buf.WriteString(fmt.Sprintf("%sencoder.WriteUint64(uint64(len(%s)), runtime.%s)\n", indent, bytesVar, mapEndianness(endianness)))
 		}
 		// Write bytes
-		buf.WriteString(fmt.Sprintf("%sfor _, b := range %s {\n", indent, bytesVar))
-		buf.WriteString(fmt.Sprintf("%s\tencoder.WriteUint8(b)\n", indent))
-		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		writeBytes(buf, bytesVar, indent)
 
 	case "null_terminated":
+		if field.MaxLength > 0 {
+			// Longer strings wouldn't decode
+			buf.WriteString(fmt.Sprintf("%sif len(%s) > %d {\n", indent, bytesVar, field.MaxLength))
+			buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%d bytes exceed max_length %d\", len(%s))\n", indent, fieldWhat(field), field.MaxLength, bytesVar))
+			buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		}
 		// Write bytes
-		buf.WriteString(fmt.Sprintf("%sfor _, b := range %s {\n", indent, bytesVar))
-		buf.WriteString(fmt.Sprintf("%s\tencoder.WriteUint8(b)\n", indent))
-		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		writeBytes(buf, bytesVar, indent)
 		// Write null terminator
 		buf.WriteString(fmt.Sprintf("%sencoder.WriteUint8(0)\n", indent))
 
+	case "field_referenced":
+		// The length is already in another field
+		writeBytes(buf, bytesVar, indent)
+
 	case "fixed":
 		// Write bytes (padded or truncated)
-		length := 0
-		if field.Length != nil {
-			if intLen, ok := field.Length.(float64); ok {
-				length = int(intLen)
+		length := "0"
+		if intLen, ok := field.Length.(float64); ok {
+			length = fmt.Sprintf("%d", int(intLen))
+		} else if src, ok := lengthSource(field); ok {
+			lengthVar := strings.TrimSuffix(bytesVar, "_bytes") + "_computed_length"
+			if err := generateLength(buf, field, src, field.receiver(), lengthVar, indent); err != nil {
+				return err
 			}
+			length = fmt.Sprintf("int(%s)", lengthVar)
 		}
-		buf.WriteString(fmt.Sprintf("%sfor i := 0; i < %d; i++ {\n", indent, length))
-		buf.WriteString(fmt.Sprintf("%s\tif i < len(%s) {\n", indent, bytesVar))
-		buf.WriteString(fmt.Sprintf("%s\t\tencoder.WriteUint8(%s[i])\n", indent, bytesVar))
-		buf.WriteString(fmt.Sprintf("%s\t} else {\n", indent))
-		buf.WriteString(fmt.Sprintf("%s\t\tencoder.WriteUint8(0)\n", indent))
+		if field.Canonical {
+			// Truncating would encode two strings the same way
+			generateCanonicalLengthCheck(buf, field, bytesVar, length, indent)
+		}
+		buf.WriteString(fmt.Sprintf("%sif len(%s) >= %s {\n", indent, bytesVar, length))
+		writeBytes(buf, fmt.Sprintf("%s[:%s]", bytesVar, length), indent+"\t")
+		buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+		writeBytes(buf, bytesVar, indent+"\t")
+		pad, _ := padByte(field)
+		buf.WriteString(fmt.Sprintf("%s\tfor i := len(%s); i < %s; i++ {\n", indent, bytesVar, length))
+		buf.WriteString(fmt.Sprintf("%s\t\tencoder.WriteUint8(%s)\n", indent, pad))
 		buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
 		buf.WriteString(fmt.Sprintf("%s}\n", indent))
 	}
@@ -295,8 +827,18 @@ func generateEncodeArray(buf *bytes.Buffer, field Field, fieldName, endianness,
 		return generateEncodeLengthPrefixedItems(buf, field, fieldName, itemVar, endianness, runtimeEndianness, indent)
 	}
 
+	// Byte arrays are written whole
+	if isByteArray(field) && field.Items.Codec == "" && len(field.TerminalVariants) == 0 {
+		writeBytes(buf, fieldName, indent)
+		if field.Kind == "null_terminated" {
+			buf.WriteString(fmt.Sprintf("%sencoder.WriteUint8(0)\n", indent))
+		}
+		return nil
+	}
+
 	// Write array elements (regular length_prefixed, fixed, null_terminated)
 	buf.WriteString(fmt.Sprintf("%sfor _, %s := range %s {\n", indent, itemVar, fieldName))
+	generateLimitCheck(buf, indent+"\t")
 	if field.Items != nil {
 		if err := generateEncodeFieldImpl(buf, *field.Items, itemVar, endianness, runtimeEndianness, indent+"\t"); err != nil {
 			return err
@@ -304,8 +846,17 @@ func generateEncodeArray(buf *bytes.Buffer, field Field, fieldName, endianness,
 	}
 	buf.WriteString(fmt.Sprintf("%s}\n", indent))
 
-	// Write null terminator for null_terminated arrays
-	if field.Kind == "null_terminated" {
+	// Write null terminator for null_terminated arrays, unless a terminal variant ended the array
+	if field.Kind == "null_terminated" && len(field.TerminalVariants) > 0 {
+		terminatedVar := strings.TrimSuffix(itemVar, "_item") + "_terminated"
+		buf.WriteString(fmt.Sprintf("%s%s := false\n", indent, terminatedVar))
+		buf.WriteString(fmt.Sprintf("%sif n := len(%s); n > 0 {\n", indent, fieldName))
+		terminalSwitch(buf, fieldName+"[n-1]", field.TerminalVariants, terminatedVar+" = true", indent+"\t")
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		buf.WriteString(fmt.Sprintf("%sif !%s {\n", indent, terminatedVar))
+		buf.WriteString(fmt.Sprintf("%s\tencoder.WriteUint8(0)\n", indent))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	} else if field.Kind == "null_terminated" {
 		buf.WriteString(fmt.Sprintf("%sencoder.WriteUint8(0)\n", indent))
 	}
 
@@ -317,9 +868,13 @@ func generateEncodeLengthPrefixedItems(buf *bytes.Buffer, field Field, fieldName
 	if itemLengthType == "" {
 		itemLengthType = "uint32"
 	}
+	if field.Items != nil && fixedWidthValueTypes[field.Items.Type] {
+		return fmt.Errorf("%s: length_prefixed_items of %s are not supported", field.Name, field.Items.Type)
+	}
 
 	// For each item, encode it separately, measure length, write length then bytes
 	buf.WriteString(fmt.Sprintf("%sfor _, %s := range %s {\n", indent, itemVar, fieldName))
+	generateLimitCheck(buf, indent+"\t")
 
 	// Need to encode the item to get its byte length
 	// For struct types, call Encode() method
@@ -333,7 +888,7 @@ func generateEncodeLengthPrefixedItems(buf *bytes.Buffer, field Field, fieldName
 			itemType != "float32" && itemType != "float64" && itemType != "string" {
 			// Custom type - call Encode()
 			itemBytesVar := itemVar + "_bytes"
-			buf.WriteString(fmt.Sprintf("%s\t%s, err := %s.Encode()\n", indent, itemBytesVar, itemVar))
+			buf.WriteString(fmt.Sprintf("%s\t%s, err := %s.EncodeWithContext(%s)\n", indent, itemBytesVar, itemVar, encodeContextFor(*field.Items, uintBytes[itemLengthType])))
 			buf.WriteString(fmt.Sprintf("%s\tif err != nil {\n", indent))
 			buf.WriteString(fmt.Sprintf("%s\t\treturn nil, err\n", indent))
 			buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
@@ -351,9 +906,7 @@ func generateEncodeLengthPrefixedItems(buf *bytes.Buffer, field Field, fieldName
 			}
 
 			// Write item bytes
-			buf.WriteString(fmt.Sprintf("%s\tfor _, b := range %s {\n", indent, itemBytesVar))
-			buf.WriteString(fmt.Sprintf("%s\t\tencoder.WriteUint8(b)\n", indent))
-			buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+			writeBytes(buf, itemBytesVar, indent+"\t")
 		} else {
 			// Primitive type - write length then value
 			// For primitives, length is fixed and known at compile time
@@ -392,45 +945,143 @@ func generateEncodeLengthPrefixedItems(buf *bytes.Buffer, field Field, fieldName
 	return nil
 }
 
-func convertConditionalToGo(condition string, basePath string) string {
-	// Simple conversion: "present == 1" -> "m.Present == 1"
-	// For now, just do basic field name capitalization
-	parts := strings.Split(condition, " ")
-	if len(parts) >= 3 {
-		fieldName := parts[0]
-		operator := parts[1]
-		value := strings.Join(parts[2:], " ")
-		return fmt.Sprintf("%s.%s %s %s", basePath, capitalizeFirst(fieldName), operator, value)
-	}
-	return condition
-}
-
-func generateDecodeFunction(buf *bytes.Buffer, typeName string, typeDef *TypeDef, defaultEndianness string) error {
+func generateDecodeFunction(buf *bytes.Buffer, typeName string, typeDef *TypeDef, defaultEndianness string, opts GenerateOptions) error {
 	// Generate public Decode function that creates a decoder
 	buf.WriteString(fmt.Sprintf("func Decode%s(bytes []byte) (*%s, error) {\n", typeName, typeName))
-	buf.WriteString("\tdecoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)\n")
-	buf.WriteString(fmt.Sprintf("\treturn decode%sWithDecoder(decoder)\n", typeName))
+	buf.WriteString(fmt.Sprintf("\tdecoder := runtime.NewBitStreamDecoder(bytes, %s)\n", runtimeBitOrder(typeDef.BitOrder)))
+	buf.WriteString(fmt.Sprintf("\treturn decode%sWithDecoder(decoder, nil)\n", typeName))
 	buf.WriteString("}\n\n")
+	if defaultEndianness == "dynamic" {
+		generateDecodeWithEndianness(buf, typeName, typeDef.BitOrder)
+	}
+	if opts.DecodeInto {
+		generateDecodeInto(buf, typeName, typeDef)
+	}
+	if opts.DecodeWithArena {
+		generateDecodeWithArena(buf, typeName, typeDef)
+	}
+	if opts.DecodeStream {
+		generateDecodeStream(buf, typeName, typeDef)
+	}
+	if opts.DecodeContext {
+		generateDecodeContext(buf, typeName, typeDef)
+	}
+	if typeDef.DecodesUTF8 {
+		generateDecodeWithUTF8(buf, typeName, typeDef)
+	}
+
+	// Generate helper that accepts an existing decoder (for nested structs) and the
+	// enclosing structs being decoded (for ../field references)
+	buf.WriteString(fmt.Sprintf("func decode%sWithDecoder(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext) (*%s, error) {\n", typeName, typeName))
+	buf.WriteString(fmt.Sprintf("\treturn decode%sInto(decoder, ctx, runtime.ArenaNew[%s](decoder.Arena))\n", typeName, typeName))
+	buf.WriteString("}\n\n")
+
+	// The decoding itself fills in a caller's value, keeping its slices' capacity
+	signature := fmt.Sprintf("(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *%s) (*%s, error) {\n", typeName, typeName)
+
+	// Protobuf messages are read tag by tag instead of in sequence
+	if typeDef.Protobuf {
+		buf.WriteString(fmt.Sprintf("func decode%sInto%s", typeName, signature))
+		generateResetInto(buf, typeName, typeDef)
+		if err := generateDecodeProtobuf(buf, typeDef, opts); err != nil {
+			return err
+		}
+		buf.WriteString("\treturn result, nil\n")
+		buf.WriteString("}\n\n")
+		return nil
+	}
 
-	// Generate helper that accepts an existing decoder (for nested structs)
-	buf.WriteString(fmt.Sprintf("func decode%sWithDecoder(decoder *runtime.BitStreamDecoder) (*%s, error) {\n", typeName, typeName))
-	buf.WriteString(fmt.Sprintf("\tresult := &%s{}\n\n", typeName))
+	var body bytes.Buffer
+	if err := generateDecodeIntoBody(&body, typeName, typeDef, defaultEndianness, opts); err != nil {
+		return err
+	}
+
+	// Decoders with a Trace attached take a copy that always traces, so DumpAnnotated
+	// annotates in every build while the trace calls here compile away without -tags trace
+	buf.WriteString(fmt.Sprintf("func decode%sInto%s", typeName, signature))
+	buf.WriteString("\tif decoder.Trace != nil {\n")
+	buf.WriteString(fmt.Sprintf("\t\treturn decode%sIntoTraced(decoder, ctx, result)\n", typeName))
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tconst traceEnabled = runtime.TraceEnabled\n\n")
+	buf.Write(body.Bytes())
+
+	buf.WriteString(fmt.Sprintf("// decode%sIntoTraced is decode%sInto recording each read in decoder.Trace\n", typeName, typeName))
+	buf.WriteString(fmt.Sprintf("func decode%sIntoTraced%s", typeName, signature))
+	buf.WriteString("\tconst traceEnabled = true\n\n")
+	buf.Write(body.Bytes())
+
+	if typeDef.FixedSize > 0 {
+		generateDecodeFrom(buf, typeName, typeDef, defaultEndianness)
+	}
+	return nil
+}
+
+// generateDecodeIntoBody emits the statements of decodeXInto, whose trace calls are
+// guarded by its traceEnabled constant
+func generateDecodeIntoBody(buf *bytes.Buffer, typeName string, typeDef *TypeDef, defaultEndianness string, opts GenerateOptions) error {
+	generateResetInto(buf, typeName, typeDef)
+
+	// Nested types see the struct being decoded, with the fields decoded before them
+	if passesParents(typeDef.allFields()) {
+		buf.WriteString("\tchildCtx := ctx.ExtendWithParentStruct(result)\n\n")
+	}
+
+	generateDecodeBitOrder(buf, typeDef)
+
+	// A type of a fixed size is read from one slice after a single bounds check
+	if typeDef.FixedSize > 0 {
+		generateDecodeFixedSize(buf, typeName, typeDef)
+	}
+
+	// Recursive types bound their nesting depth so hostile input can't recurse forever
+	if typeDef.Recursive {
+		buf.WriteString("\tif err := decoder.EnterNested(); err != nil {\n")
+		buf.WriteString("\t\treturn nil, err\n")
+		buf.WriteString("\t}\n")
+		buf.WriteString("\tdefer decoder.ExitNested()\n\n")
+	}
 
 	// Generate decoding logic for each field
-	for _, field := range typeDef.Sequence {
+	for i := 0; i < len(typeDef.Sequence); i++ {
+		// Groups of bit fields are read at once
+		if n := bitGroup(typeDef.Sequence, i); n > 0 {
+			group := typeDef.Sequence[i : i+n]
+			if err := generateDecodeBitGroup(buf, group, typeDef.BitOrder, defaultEndianness); err != nil {
+				return err
+			}
+			i += n - 1
+			continue
+		}
+		// Runs of fixed-width fields are read with one bounds check when byte-aligned
+		if n := inlineRun(typeDef.Sequence, i); n > 0 && typeDef.FixedSize == 0 {
+			run := typeDef.Sequence[i : i+n]
+			if err := generateDecodeInlineRun(buf, run, defaultEndianness); err != nil {
+				return err
+			}
+			i += n - 1
+			continue
+		}
+		field := typeDef.Sequence[i]
 		if err := generateDecodeField(buf, field, defaultEndianness); err != nil {
 			return err
 		}
+		generateEndiannessSelect(buf, field, "decoder", "result."+field.goName(), "\t")
+		if err := generateNormalizeSlice(buf, field, opts.EmptySlices); err != nil {
+			return err
+		}
+	}
+	if err := generateDecodeInstances(buf, typeDef, defaultEndianness); err != nil {
+		return err
 	}
 
 	buf.WriteString("\n\treturn result, nil\n")
-	buf.WriteString("}\n")
+	buf.WriteString("}\n\n")
 	return nil
 }
 
 func generateDecodeField(buf *bytes.Buffer, field Field, defaultEndianness string) error {
-	fieldName := capitalizeFirst(field.Name)
-	varName := strings.ToLower(field.Name)
+	fieldName := field.goName()
+	varName := field.localName()
 	endianness := field.Endianness
 	if endianness == "" {
 		endianness = defaultEndianness
@@ -439,19 +1090,60 @@ func generateDecodeField(buf *bytes.Buffer, field Field, defaultEndianness strin
 
 	// Handle conditional fields
 	if field.Conditional != "" {
-		goCondition := convertConditionalToGo(field.Conditional, "result")
+		goCondition := generateCondition(buf, field, "result", "\t")
 		buf.WriteString(fmt.Sprintf("\tif %s {\n", goCondition))
-		if err := generateDecodeFieldImpl(buf, field, fieldName, varName, endianness, runtimeEndianness, "\t\t"); err != nil {
+		if err := generateTracedDecodeField(buf, field, fieldName, varName, endianness, runtimeEndianness, "\t\t"); err != nil {
 			return err
 		}
 		buf.WriteString("\t}\n\n")
 		return nil
 	}
 
-	return generateDecodeFieldImpl(buf, field, fieldName, varName, endianness, runtimeEndianness, "\t")
+	if err := generateTracedDecodeField(buf, field, fieldName, varName, endianness, runtimeEndianness, "\t"); err != nil {
+		return err
+	}
+	buf.WriteString("\n")
+	return nil
+}
+
+// generateTracedDecodeField decodes a field between calls recording its span in decoder.Trace
+func generateTracedDecodeField(buf *bytes.Buffer, field Field, fieldName, varName, endianness, runtimeEndianness, indent string) error {
+	writeTraceBegin(buf, fmt.Sprintf("TraceEnter(%q)", field.Name), indent)
+	var fieldBuf bytes.Buffer
+	if err := generateDecodeFieldImpl(&fieldBuf, field, fieldName, varName, endianness, runtimeEndianness, indent); err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimRight(fieldBuf.Bytes(), "\n"))
+	buf.WriteString("\n")
+	writeTraceEnd(buf, "result."+fieldName, indent)
+	return nil
+}
+
+// writeTraceBegin emits a call starting a traced read. In decodeXInto it compiles away
+// unless built with -tags trace; decodeXIntoTraced always makes it.
+func writeTraceBegin(buf *bytes.Buffer, call, indent string) {
+	buf.WriteString(fmt.Sprintf("%sif traceEnabled {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\tdecoder.%s\n", indent, call))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
+// writeTraceEnd emits a call completing the innermost traced read with its value
+func writeTraceEnd(buf *bytes.Buffer, value, indent string) {
+	buf.WriteString(fmt.Sprintf("%sif traceEnabled {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\tdecoder.TraceLeave(%s)\n", indent, value))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
 }
 
 func generateDecodeFieldImpl(buf *bytes.Buffer, field Field, fieldName, varName, endianness, runtimeEndianness, indent string) error {
+	if field.Lazy {
+		return generateDecodeLazy(buf, field, fieldName, varName, runtimeEndianness, indent)
+	}
+	if field.Codec != "" {
+		return generateDecodeCodec(buf, field, fieldName, varName, indent)
+	}
+	if field.Transform != nil {
+		return generateDecodeTransform(buf, field, fieldName, varName, runtimeEndianness, indent)
+	}
 	switch field.Type {
 	case "uint8":
 		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadUint8()\n", indent, varName))
@@ -469,15 +1161,80 @@ func generateDecodeFieldImpl(buf *bytes.Buffer, field Field, fieldName, varName,
 		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadInt32(runtime.%s)\n", indent, varName, runtimeEndianness))
 	case "int64":
 		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadInt64(runtime.%s)\n", indent, varName, runtimeEndianness))
+	case "uint128":
+		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadUint128(runtime.%s)\n", indent, varName, runtimeEndianness))
+	case "int128":
+		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadInt128(runtime.%s)\n", indent, varName, runtimeEndianness))
+	case "uuid":
+		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadUUID()\n", indent, varName))
+	case "mac":
+		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadMAC()\n", indent, varName))
+	case "ipv4":
+		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadIPv4()\n", indent, varName))
+	case "ipv6":
+		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadIPv6()\n", indent, varName))
+	case "unix32", "unix64", "unix_milli", "ntp":
+		return generateDecodeTimestamp(buf, field, fieldName, varName, runtimeEndianness, indent)
+	case "bcd", "packed_bcd":
+		method := "ReadBCDInt"
+		if field.DecodeAs == "string" {
+			method = "ReadBCD"
+		}
+		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.%s(%d, %t)\n", indent, varName, method, field.Digits, field.Type == "packed_bcd"))
 	case "float32":
 		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadFloat32(runtime.%s)\n", indent, varName, runtimeEndianness))
 	case "float64":
 		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadFloat64(runtime.%s)\n", indent, varName, runtimeEndianness))
+	case "bit":
+		goType, err := mapTypeToGo(field)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("%s%s_bits, err := decoder.ReadBits(%d)\n", indent, varName, field.Size))
+		buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		buf.WriteString(fmt.Sprintf("%s%s := %s(%s_bits)\n", indent, varName, goType, varName))
+		if fieldName != "" {
+			buf.WriteString(fmt.Sprintf("%sresult.%s = %s\n\n", indent, fieldName, varName))
+		}
+		return nil
+	case "int":
+		goType, err := mapTypeToGo(field)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("%s%s_bits, err := decoder.ReadBits(%d)\n", indent, varName, field.Size))
+		buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		// Sign-extend: move the top bit read to bit 63, then shift back arithmetically
+		if field.Size < 64 {
+			buf.WriteString(fmt.Sprintf("%s%s := %s(int64(%s_bits<<%d) >> %d)\n", indent, varName, goType, varName, 64-field.Size, 64-field.Size))
+		} else {
+			buf.WriteString(fmt.Sprintf("%s%s := int64(%s_bits)\n", indent, varName, varName))
+		}
+		if fieldName != "" {
+			buf.WriteString(fmt.Sprintf("%sresult.%s = %s\n\n", indent, fieldName, varName))
+		}
+		return nil
 	case "string":
 		return generateDecodeString(buf, field, fieldName, varName, endianness, indent)
 	case "array":
 		return generateDecodeArray(buf, field, fieldName, varName, endianness, runtimeEndianness, indent)
 	default:
+		if field.BackReference != nil {
+			return generateDecodeBackReference(buf, field, fieldName, varName, endianness, indent)
+		}
+		if field.FlagsRepr != "" {
+			return generateDecodeFlags(buf, field, fieldName, varName, endianness, runtimeEndianness, indent)
+		}
+		if field.Bitfield {
+			return generateDecodeBitfield(buf, field, fieldName, varName, endianness, runtimeEndianness, indent)
+		}
+		if field.ProtobufMessage {
+			return generateDecodeEmbeddedProtobuf(buf, field, fieldName, varName, endianness, indent)
+		}
 		// Type reference - nested struct
 		return generateDecodeNestedStruct(buf, field, fieldName, varName, indent)
 	}
@@ -494,16 +1251,82 @@ func generateDecodeFieldImpl(buf *bytes.Buffer, field Field, fieldName, varName,
 	return nil
 }
 
+// generateDecodeBitfield reads a bitfield's subfields in place into a value of its hoisted type
+func generateDecodeBitfield(buf *bytes.Buffer, field Field, fieldName, varName, endianness, runtimeEndianness, indent string) error {
+	buf.WriteString(fmt.Sprintf("%svar %s %s\n", indent, varName, capitalizeFirst(field.Type)))
+	if err := generateReadBitGroups(buf, field.Fields, varName, field.BitOrder, indent); err != nil {
+		return err
+	}
+	if fieldName != "" {
+		buf.WriteString(fmt.Sprintf("%sresult.%s = %s\n\n", indent, fieldName, varName))
+	}
+	return nil
+}
+
 func generateDecodeNestedStruct(buf *bytes.Buffer, field Field, fieldName, varName, indent string) error {
 	// For nested structs, call a helper decode function that accepts the decoder
 	// This allows the decoder to continue sequentially
 	typeName := capitalizeFirst(field.Type)
-	buf.WriteString(fmt.Sprintf("%s%s, err := decode%sWithDecoder(decoder)\n", indent, varName, typeName))
+
+	// Unions decode to an interface value holding the variant
+	if field.Union {
+		buf.WriteString(fmt.Sprintf("%s%s, err := decode%sWithDecoder(decoder, %s)\n", indent, varName, typeName, contextFor(field)))
+		buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		if fieldName != "" {
+			buf.WriteString(fmt.Sprintf("%sresult.%s = %s\n\n", indent, fieldName, varName))
+		}
+		return nil
+	}
+
+	// Structs held by value are decoded in place, reusing their slices
+	if fieldName != "" && !field.Pointer {
+		buf.WriteString(fmt.Sprintf("%sif _, err := decode%sInto(decoder, %s, &result.%s); err != nil {\n", indent, typeName, contextFor(field), fieldName))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+		buf.WriteString(fmt.Sprintf("%s}\n\n", indent))
+		return nil
+	}
+
+	// Array items (no field name) are stored by value in the slice
+	if fieldName == "" {
+		buf.WriteString(fmt.Sprintf("%s%s_ptr, err := decode%sWithDecoder(decoder, %s)\n", indent, varName, typeName, contextFor(field)))
+		buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		buf.WriteString(fmt.Sprintf("%s%s := *%s_ptr\n", indent, varName, varName))
+		return nil
+	}
+
+	buf.WriteString(fmt.Sprintf("%s%s, err := decode%sWithDecoder(decoder, %s)\n", indent, varName, typeName, contextFor(field)))
 	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
 	buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
 	buf.WriteString(fmt.Sprintf("%s}\n", indent))
-	buf.WriteString(fmt.Sprintf("%sresult.%s = *%s\n\n", indent, fieldName, varName))
+	buf.WriteString(fmt.Sprintf("%sresult.%s = %s\n\n", indent, fieldName, varName))
+	return nil
+}
 
+// generateNormalizeSlice makes a decoded array field follow the EmptySlices option
+func generateNormalizeSlice(buf *bytes.Buffer, field Field, mode EmptySliceMode) error {
+	if field.Type != "array" || field.Lazy {
+		return nil
+	}
+	fieldName := field.goName()
+
+	switch mode {
+	case EmptySlicesNil:
+		buf.WriteString(fmt.Sprintf("\tif len(result.%s) == 0 {\n", fieldName))
+		buf.WriteString(fmt.Sprintf("\t\tresult.%s = nil\n", fieldName))
+		buf.WriteString("\t}\n\n")
+	case EmptySlicesNonNil:
+		goType, err := mapTypeToGo(field)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("\tif result.%s == nil {\n", fieldName))
+		buf.WriteString(fmt.Sprintf("\t\tresult.%s = %s{}\n", fieldName, goType))
+		buf.WriteString("\t}\n\n")
+	}
 	return nil
 }
 
@@ -522,6 +1345,7 @@ func generateDecodeString(buf *bytes.Buffer, field Field, fieldName, varName, en
 			lengthType = "uint8"
 		}
 		// Read length prefix
+		writeTraceBegin(buf, `TraceEnter("length")`, indent)
 		switch lengthType {
 		case "uint8":
 			buf.WriteString(fmt.Sprintf("%slength, err := decoder.ReadUint8()\n", indent))
@@ -535,8 +1359,13 @@ func generateDecodeString(buf *bytes.Buffer, field Field, fieldName, varName, en
 		buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
 		buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
 		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		writeTraceEnd(buf, "length", indent)
 
 		// Read bytes
+		if field.ZeroCopy {
+			generateReadView(buf, bytesVar, "ReadBytesView(int(length))", indent)
+			break
+		}
 		buf.WriteString(fmt.Sprintf("%s%s := make([]byte, length)\n", indent, bytesVar))
 		buf.WriteString(fmt.Sprintf("%sfor i := range %s {\n", indent, bytesVar))
 		buf.WriteString(fmt.Sprintf("%s\tb, err := decoder.ReadUint8()\n", indent))
@@ -548,6 +1377,15 @@ func generateDecodeString(buf *bytes.Buffer, field Field, fieldName, varName, en
 
 	case "null_terminated":
 		// Read until null terminator
+		if field.MaxLength > 0 {
+			// The view is copied when converted, unless it is kept as one
+			generateReadView(buf, bytesVar, fmt.Sprintf("ReadNullTerminatedLimit(%q, %d)", fieldWhat(field), field.MaxLength), indent)
+			break
+		}
+		if field.ZeroCopy {
+			generateReadView(buf, bytesVar, "ReadNullTerminatedView()", indent)
+			break
+		}
 		buf.WriteString(fmt.Sprintf("%s%s := []byte{}\n", indent, bytesVar))
 		buf.WriteString(fmt.Sprintf("%sfor {\n", indent))
 		buf.WriteString(fmt.Sprintf("%s\tb, err := decoder.ReadUint8()\n", indent))
@@ -560,31 +1398,72 @@ func generateDecodeString(buf *bytes.Buffer, field Field, fieldName, varName, en
 		buf.WriteString(fmt.Sprintf("%s\t%s = append(%s, b)\n", indent, bytesVar, bytesVar))
 		buf.WriteString(fmt.Sprintf("%s}\n", indent))
 
+	case "field_referenced":
+		// Read as many bytes as an earlier field says
+		src, _ := lengthSource(field)
+		lengthVar := varName + "_computed_length"
+		if err := generateLength(buf, field, src, "result", lengthVar, indent); err != nil {
+			return err
+		}
+		generateRemainingCheck(buf, field, lengthVar, indent)
+		if field.ZeroCopy {
+			generateReadView(buf, bytesVar, fmt.Sprintf("ReadBytesView(int(%s))", lengthVar), indent)
+			break
+		}
+		buf.WriteString(fmt.Sprintf("%s%s := make([]byte, %s)\n", indent, bytesVar, lengthVar))
+		buf.WriteString(fmt.Sprintf("%sfor i := range %s {\n", indent, bytesVar))
+		buf.WriteString(fmt.Sprintf("%s\tb, err := decoder.ReadUint8()\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\tif err != nil {\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\t\treturn nil, err\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\t%s[i] = b\n", indent, bytesVar))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+
 	case "fixed":
 		// Read fixed number of bytes
-		length := 0
-		if field.Length != nil {
-			if intLen, ok := field.Length.(float64); ok {
-				length = int(intLen)
+		length := "0"
+		if intLen, ok := field.Length.(float64); ok {
+			length = fmt.Sprintf("%d", int(intLen))
+		} else if src, ok := lengthSource(field); ok {
+			lengthVar := varName + "_computed_length"
+			if err := generateLength(buf, field, src, "result", lengthVar, indent); err != nil {
+				return err
 			}
+			generateRemainingCheck(buf, field, lengthVar, indent)
+			length = fmt.Sprintf("int(%s)", lengthVar)
 		}
-		buf.WriteString(fmt.Sprintf("%s%s := make([]byte, 0)\n", indent, bytesVar))
-		buf.WriteString(fmt.Sprintf("%sfor i := 0; i < %d; i++ {\n", indent, length))
+		if field.ZeroCopy {
+			generateReadView(buf, bytesVar, fmt.Sprintf("ReadBytesView(%s)", length), indent)
+			generateTrimPadding(buf, field, bytesVar, indent)
+			break
+		}
+		buf.WriteString(fmt.Sprintf("%s%s := make([]byte, %s)\n", indent, bytesVar, length))
+		buf.WriteString(fmt.Sprintf("%sfor i := range %s {\n", indent, bytesVar))
 		buf.WriteString(fmt.Sprintf("%s\tb, err := decoder.ReadUint8()\n", indent))
 		buf.WriteString(fmt.Sprintf("%s\tif err != nil {\n", indent))
 		buf.WriteString(fmt.Sprintf("%s\t\treturn nil, err\n", indent))
 		buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
-		buf.WriteString(fmt.Sprintf("%s\tif b != 0 {\n", indent))
-		buf.WriteString(fmt.Sprintf("%s\t\t%s = append(%s, b)\n", indent, bytesVar, bytesVar))
-		buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\t%s[i] = b\n", indent, bytesVar))
 		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		generateTrimPadding(buf, field, bytesVar, indent)
 	}
 
 	// Convert bytes to string
-	if encoding == "utf8" {
-		buf.WriteString(fmt.Sprintf("%sresult.%s = string(%s)\n\n", indent, fieldName, bytesVar))
+	assign := fmt.Sprintf("result.%s =", fieldName)
+	if fieldName == "" {
+		// An array item: the caller stores varName
+		assign = varName + " :="
+	}
+	if field.UTF8Policy != "" {
+		stringVar := varName + "_string"
+		generateUTF8Conversion(buf, field, stringVar, bytesVar, indent)
+		buf.WriteString(fmt.Sprintf("%s%s %s\n\n", indent, assign, stringVar))
+	} else if field.ZeroCopy {
+		buf.WriteString(fmt.Sprintf("%s%s runtime.ByteString(%s)\n\n", indent, assign, bytesVar))
+	} else if encoding == "utf8" {
+		buf.WriteString(fmt.Sprintf("%s%s string(%s)\n\n", indent, assign, bytesVar))
 	} else if encoding == "ascii" {
-		buf.WriteString(fmt.Sprintf("%sresult.%s = string(%s)\n\n", indent, fieldName, bytesVar))
+		buf.WriteString(fmt.Sprintf("%s%s string(%s)\n\n", indent, assign, bytesVar))
 	}
 
 	return nil
@@ -595,10 +1474,12 @@ func generateDecodeArray(buf *bytes.Buffer, field Field, fieldName, varName, end
 		return fmt.Errorf("array field missing items definition")
 	}
 
-	itemType, err := mapTypeToGo(*field.Items)
-	if err != nil {
+	if _, err := mapTypeToGo(*field.Items); err != nil {
 		return err
 	}
+	if field.Names != nil && field.Names.Resolve {
+		return generateDecodeNames(buf, field, fieldName, varName, endianness, runtimeEndianness, indent)
+	}
 
 	// Read length prefix if length_prefixed or length_prefixed_items
 	if field.Kind == "length_prefixed" || field.Kind == "length_prefixed_items" {
@@ -606,6 +1487,7 @@ func generateDecodeArray(buf *bytes.Buffer, field Field, fieldName, varName, end
 		if lengthType == "" {
 			lengthType = "uint8"
 		}
+		writeTraceBegin(buf, `TraceEnter("length")`, indent)
 		switch lengthType {
 		case "uint8":
 			buf.WriteString(fmt.Sprintf("%slength, err := decoder.ReadUint8()\n", indent))
@@ -619,7 +1501,8 @@ func generateDecodeArray(buf *bytes.Buffer, field Field, fieldName, varName, end
 		buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
 		buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
 		buf.WriteString(fmt.Sprintf("%s}\n", indent))
-		buf.WriteString(fmt.Sprintf("%sresult.%s = make([]%s, length)\n", indent, fieldName, itemType))
+		writeTraceEnd(buf, "length", indent)
+		buf.WriteString(fmt.Sprintf("%sresult.%s = runtime.Reuse(decoder.Arena, result.%s, int(length))\n", indent, fieldName, fieldName))
 
 		// For length_prefixed_items, handle per-item lengths
 		if field.Kind == "length_prefixed_items" {
@@ -627,22 +1510,39 @@ func generateDecodeArray(buf *bytes.Buffer, field Field, fieldName, varName, end
 		}
 
 		buf.WriteString(fmt.Sprintf("%sfor i := range result.%s {\n", indent, fieldName))
-	} else if field.Kind == "null_terminated" {
-		// Read until null terminator
-		buf.WriteString(fmt.Sprintf("%sresult.%s = []%s{}\n", indent, fieldName, itemType))
+	} else if field.Kind == "null_terminated" || field.Kind == "variant_terminated" {
+		// Read until a zero byte (peeked before each item, then consumed) or a terminal variant
+		buf.WriteString(fmt.Sprintf("%sresult.%s = runtime.Reuse(decoder.Arena, result.%s, 0)\n", indent, fieldName, fieldName))
+		if len(field.TerminalVariants) > 0 {
+			buf.WriteString(fmt.Sprintf("%s%sLoop:\n", indent, varName))
+		}
 		buf.WriteString(fmt.Sprintf("%sfor {\n", indent))
+		if field.Kind == "null_terminated" {
+			buf.WriteString(fmt.Sprintf("%s\tterminator, err := decoder.PeekUint8()\n", indent))
+			buf.WriteString(fmt.Sprintf("%s\tif err != nil {\n", indent))
+			buf.WriteString(fmt.Sprintf("%s\t\treturn nil, err\n", indent))
+			buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+			buf.WriteString(fmt.Sprintf("%s\tif terminator == 0 {\n", indent))
+			buf.WriteString(fmt.Sprintf("%s\t\tdecoder.SkipBytes(1)\n", indent))
+			buf.WriteString(fmt.Sprintf("%s\t\tbreak\n", indent))
+			buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+		}
+	} else if src, ok := lengthSource(field); ok {
+		// Count computed from earlier fields - field_referenced, computed_count, or fixed with an expression
+		lengthVar := varName + "_computed_length"
+		if err := generateLength(buf, field, src, "result", lengthVar, indent); err != nil {
+			return err
+		}
+		generateRemainingCheck(buf, field, lengthVar, indent)
+		buf.WriteString(fmt.Sprintf("%sresult.%s = runtime.Reuse(decoder.Arena, result.%s, int(%s))\n", indent, fieldName, fieldName, lengthVar))
+		buf.WriteString(fmt.Sprintf("%sfor i := range result.%s {\n", indent, fieldName))
 	} else if field.Kind == "fixed" {
 		// Fixed array - read a compile-time known number of elements
 		length := 0
-		if field.Length != nil {
-			if intLen, ok := field.Length.(float64); ok {
-				length = int(intLen)
-			} else if strLen, ok := field.Length.(string); ok {
-				// Field reference - not yet implemented
-				return fmt.Errorf("field-referenced array lengths not yet implemented (length field: %s)", strLen)
-			}
+		if intLen, ok := field.Length.(float64); ok {
+			length = int(intLen)
 		}
-		buf.WriteString(fmt.Sprintf("%sresult.%s = make([]%s, %d)\n", indent, fieldName, itemType, length))
+		buf.WriteString(fmt.Sprintf("%sresult.%s = runtime.Reuse(decoder.Arena, result.%s, %d)\n", indent, fieldName, fieldName, length))
 		buf.WriteString(fmt.Sprintf("%sfor i := 0; i < %d; i++ {\n", indent, length))
 	} else {
 		return fmt.Errorf("unknown array kind: %s", field.Kind)
@@ -650,17 +1550,29 @@ func generateDecodeArray(buf *bytes.Buffer, field Field, fieldName, varName, end
 
 	// Read item
 	itemVar := varName + "_item"
+	itemIndex := "i"
+	if field.Kind == "null_terminated" || field.Kind == "variant_terminated" {
+		itemIndex = fmt.Sprintf("len(result.%s)", fieldName)
+	}
+	generateCancelCheck(buf, indent+"\t")
+	writeTraceBegin(buf, fmt.Sprintf("TraceEnterItem(%s)", itemIndex), indent+"\t")
+	if decodesInPlace(*field.Items) && field.Kind != "variant_terminated" {
+		return generateDecodeItemInPlace(buf, field, fieldName, indent)
+	}
 	if err := generateDecodeFieldImpl(buf, *field.Items, "", itemVar, endianness, runtimeEndianness, indent+"\t"); err != nil {
 		return err
 	}
+	writeTraceEnd(buf, itemVar, indent+"\t")
 
-	if field.Kind == "length_prefixed" || field.Kind == "fixed" {
+	if field.Kind == "length_prefixed" || field.Kind == "fixed" || field.Kind == "field_referenced" || field.Kind == "computed_count" {
 		buf.WriteString(fmt.Sprintf("%s\tresult.%s[i] = %s\n", indent, fieldName, itemVar))
 		buf.WriteString(fmt.Sprintf("%s}\n\n", indent))
-	} else if field.Kind == "null_terminated" {
-		// Check for null terminator
-		buf.WriteString(fmt.Sprintf("%s\t// TODO: Check for null terminator\n", indent))
+	} else if field.Kind == "null_terminated" || field.Kind == "variant_terminated" {
 		buf.WriteString(fmt.Sprintf("%s\tresult.%s = append(result.%s, %s)\n", indent, fieldName, fieldName, itemVar))
+		// A terminal variant is the last item, with no zero byte after it
+		if len(field.TerminalVariants) > 0 {
+			terminalSwitch(buf, itemVar, field.TerminalVariants, fmt.Sprintf("break %sLoop", varName), indent+"\t")
+		}
 		buf.WriteString(fmt.Sprintf("%s}\n\n", indent))
 	}
 
@@ -675,6 +1587,7 @@ func generateDecodeLengthPrefixedItems(buf *bytes.Buffer, field Field, fieldName
 
 	// For each item, read the item length, then read exactly that many bytes
 	buf.WriteString(fmt.Sprintf("%sfor i := range result.%s {\n", indent, fieldName))
+	generateCancelCheck(buf, indent+"\t")
 
 	// Read item length
 	itemLengthVar := varName + "_item_length"
@@ -724,6 +1637,9 @@ func generateDecodeLengthPrefixedItems(buf *bytes.Buffer, field Field, fieldName
 			itemVar := varName + "_item"
 			itemType := field.Items.Type
 
+			if endianness == "dynamic" && itemType != "uint8" {
+				return fmt.Errorf("%s: length_prefixed_items of %s don't support dynamic endianness", field.Name, itemType)
+			}
 			switch itemType {
 			case "uint8":
 				buf.WriteString(fmt.Sprintf("%s\t%s := %s[0]\n", indent, itemVar, itemBytesVar))
@@ -759,6 +1675,14 @@ func generateDecodeLengthPrefixedItems(buf *bytes.Buffer, field Field, fieldName
 }
 
 func mapTypeToGo(field Field) (string, error) {
+	if field.Lazy {
+		field.Lazy = false
+		goType, err := mapTypeToGo(field)
+		if err != nil {
+			return "", err
+		}
+		return "runtime.Lazy[" + goType + "]", nil
+	}
 	switch field.Type {
 	case "uint8":
 		return "uint8", nil
@@ -776,11 +1700,57 @@ func mapTypeToGo(field Field) (string, error) {
 		return "int32", nil
 	case "int64":
 		return "int64", nil
+	case "uint128":
+		return "runtime.Uint128", nil
+	case "int128":
+		return "runtime.Int128", nil
+	case "uuid":
+		return "runtime.UUID", nil
+	case "mac":
+		return "runtime.MAC", nil
+	case "ipv4", "ipv6":
+		return "netip.Addr", nil
+	case "unix32", "unix64", "unix_milli", "ntp":
+		return "time.Time", nil
+	case "bcd", "packed_bcd":
+		if field.DecodeAs == "string" {
+			return "string", nil
+		}
+		return "int64", nil
 	case "float32":
 		return "float32", nil
 	case "float64":
 		return "float64", nil
+	case "int":
+		// Smallest signed type holding the bits
+		switch {
+		case field.Size < 1 || field.Size > 64:
+			return "", fmt.Errorf("int field %s needs a size of 1 to 64 bits, got %d", field.Name, field.Size)
+		case field.Size <= 8:
+			return "int8", nil
+		case field.Size <= 16:
+			return "int16", nil
+		case field.Size <= 32:
+			return "int32", nil
+		default:
+			return "int64", nil
+		}
+	case "bit":
+		// Smallest unsigned type holding the bits
+		switch {
+		case field.Size <= 8:
+			return "uint8", nil
+		case field.Size <= 16:
+			return "uint16", nil
+		case field.Size <= 32:
+			return "uint32", nil
+		default:
+			return "uint64", nil
+		}
 	case "string":
+		if field.ZeroCopy {
+			return "runtime.ByteString", nil
+		}
 		return "string", nil
 	case "array":
 		if field.Items == nil {
@@ -792,23 +1762,41 @@ func mapTypeToGo(field Field) (string, error) {
 		}
 		return "[]" + itemType, nil
 	default:
+		// Builtins without a case above (choice, varlength, ...) have no Go mapping yet
+		if builtinTypes[field.Type] {
+			return "", fmt.Errorf("type %s is not supported by the Go generator", field.Type)
+		}
 		// Assume it's a type reference (nested struct)
+		if field.Pointer {
+			return "*" + capitalizeFirst(field.Type), nil
+		}
 		return capitalizeFirst(field.Type), nil
 	}
 }
 
-func mapEndianness(endianness string) string {
-	if endianness == "little_endian" {
-		return "LittleEndian"
+// Fixed-width builtins with a runtime value type rather than a Go integer
+var fixedWidthValueTypes = map[string]bool{"uint128": true, "int128": true, "uuid": true, "mac": true, "ipv4": true, "ipv6": true,
+	"unix32": true, "unix64": true, "unix_milli": true, "ntp": true, "bcd": true, "packed_bcd": true}
+
+// bcdBytes is the size of a BCD number: a byte per digit, or per two digits when packed
+func bcdBytes(digits int, packed bool) int {
+	if packed {
+		return (digits + 1) / 2
 	}
-	return "BigEndian"
+	return digits
 }
 
-func capitalizeFirst(s string) string {
-	if s == "" {
-		return ""
+// Width of the Go types an int field may map to
+var goIntBits = map[string]int{"int8": 8, "int16": 16, "int32": 32, "int64": 64}
+
+func mapEndianness(endianness string) string {
+	switch endianness {
+	case "little_endian":
+		return "LittleEndian"
+	case "dynamic":
+		return "DynamicEndian"
 	}
-	return strings.ToUpper(s[:1]) + s[1:]
+	return "BigEndian"
 }
 
 func parseField(fieldData map[string]interface{}) Field {
@@ -817,6 +1805,9 @@ func parseField(fieldData map[string]interface{}) Field {
 	if name, ok := fieldData["name"].(string); ok {
 		field.Name = name
 	}
+	if goName, ok := fieldData["go_name"].(string); ok {
+		field.GoName = goName
+	}
 	if fieldType, ok := fieldData["type"].(string); ok {
 		field.Type = fieldType
 	}
@@ -826,6 +1817,12 @@ func parseField(fieldData map[string]interface{}) Field {
 	if length, ok := fieldData["length"]; ok {
 		field.Length = length
 	}
+	if lengthField, ok := fieldData["length_field"].(string); ok {
+		field.LengthField = lengthField
+	}
+	if countExpr, ok := fieldData["count_expr"].(string); ok {
+		field.CountExpr = countExpr
+	}
 	if lengthType, ok := fieldData["length_type"].(string); ok {
 		field.LengthType = lengthType
 	}
@@ -835,12 +1832,118 @@ func parseField(fieldData map[string]interface{}) Field {
 	if encoding, ok := fieldData["encoding"].(string); ok {
 		field.Encoding = encoding
 	}
+	field.Padding, _ = fieldData["padding"].(string)
+	if maxLength, ok := fieldData["max_length"].(float64); ok {
+		field.MaxLength = int(maxLength)
+	}
 	if conditional, ok := fieldData["conditional"].(string); ok {
 		field.Conditional = conditional
 	}
+	if selects, ok := fieldData["selects_endianness"].(map[string]interface{}); ok {
+		field.SelectsEndianness = make(map[string]uint64, len(selects))
+		for order, value := range selects {
+			if n, ok := value.(float64); ok {
+				field.SelectsEndianness[order] = uint64(n)
+			}
+		}
+	}
+	if computed, ok := fieldData["computed"].(map[string]interface{}); ok {
+		target, _ := computed["target"].(string)
+		field.ByteRange = parseByteRange(computed)
+		if offset, ok := computed["offset"].(float64); ok && field.ByteRange != nil {
+			field.ComputedOffset = int(offset)
+		}
+		switch computed["type"] {
+		case "position_of":
+			field.PositionOf = target
+		case "length_of":
+			if field.ByteRange != nil {
+				break
+			}
+			field.LengthOf = target
+			if offset, ok := computed["offset"].(float64); ok {
+				field.ComputedOffset = int(offset)
+			}
+		case "count_of":
+			field.CountOf = target
+		}
+	}
+	if constValue, ok := fieldData["const"]; ok {
+		field.Const = constValue
+	}
 	if endianness, ok := fieldData["endianness"].(string); ok {
 		field.Endianness = endianness
 	}
+	if optional, ok := fieldData["optional"].(bool); ok {
+		field.Optional = optional
+	}
+	if lazy, ok := fieldData["lazy"].(bool); ok {
+		field.Lazy = lazy
+	}
+	if splittable, ok := fieldData["splittable"].(bool); ok {
+		field.Splittable = splittable
+	}
+	if transform, ok := fieldData["transform"]; ok {
+		field.Transform = parseTransform(transform)
+	}
+	if codec, ok := fieldData["codec"].(string); ok {
+		field.Codec = codec
+	}
+	if description, ok := fieldData["description"].(string); ok {
+		field.Description = description
+	}
+	if metadata, ok := fieldData["metadata"].(map[string]interface{}); ok {
+		field.Metadata = metadata
+	}
+
+	if size, ok := fieldData["size"].(float64); ok {
+		field.Size = int(size)
+	}
+	if signed, ok := fieldData["signed"].(bool); ok {
+		field.Signed = signed
+	}
+	if alignTo, ok := fieldData["align_to"].(float64); ok {
+		field.AlignTo = int(alignTo)
+	}
+	if fieldNumber, ok := fieldData["field_number"].(float64); ok {
+		field.FieldNumber = int(fieldNumber)
+	}
+	if protoType, ok := fieldData["proto_type"].(string); ok {
+		field.ProtoType = protoType
+	}
+	if unit, ok := fieldData["unit"].(string); ok {
+		field.Unit = unit
+	}
+	if epoch, ok := fieldData["epoch"].(string); ok {
+		field.Epoch = epoch
+	}
+	if digits, ok := fieldData["digits"].(float64); ok {
+		field.Digits = int(digits)
+	}
+	if decodeAs, ok := fieldData["decode_as"].(string); ok {
+		field.DecodeAs = decodeAs
+	}
+	if field.Type == "flags" {
+		field.Repr, field.FlagValues = parseFlags(fieldData)
+	} else if field.Type == "enum" {
+		field.Repr, _ = fieldData["repr"].(string)
+	}
+	// Signedness picks the type, as in the interpreter: a signed bit field is an int, an unsigned int a bit field
+	if field.Type == "bit" && field.Signed {
+		field.Type = "int"
+	} else if field.Type == "int" && fieldData["signed"] == false {
+		field.Type = "bit"
+	}
+	if field.Type == "back_reference" {
+		parseBackReference(&field, fieldData)
+	}
+	if terminal, ok := fieldData["terminal_variants"].([]interface{}); ok {
+		for _, variant := range terminal {
+			if name, ok := variant.(string); ok {
+				field.TerminalVariants = append(field.TerminalVariants, name)
+			}
+		}
+	}
 
 	// Parse items for arrays
 	if itemsData, ok := fieldData["items"].(map[string]interface{}); ok {
@@ -848,6 +1951,15 @@ func parseField(fieldData map[string]interface{}) Field {
 		field.Items = &items
 	}
 
+	// Parse subfields of inline structs and bitfields
+	if fieldsData, ok := fieldData["fields"].([]interface{}); ok {
+		for _, subRaw := range fieldsData {
+			if subData, ok := subRaw.(map[string]interface{}); ok {
+				field.Fields = append(field.Fields, parseField(subData))
+			}
+		}
+	}
+
 	return field
 }
 
@@ -856,6 +1968,14 @@ func parseSchema(data map[string]interface{}) (*Schema, error) {
 		Types: make(map[string]*TypeDef),
 	}
 
+	// Parse documentation metadata
+	if metaData, ok := data["meta"].(map[string]interface{}); ok {
+		schema.Meta = &SchemaMeta{}
+		schema.Meta.Title, _ = metaData["title"].(string)
+		schema.Meta.Description, _ = metaData["description"].(string)
+		schema.Meta.Version, _ = metaData["version"].(string)
+	}
+
 	// Parse config
 	if configData, ok := data["config"].(map[string]interface{}); ok {
 		schema.Config = &SchemaConfig{}
@@ -865,6 +1985,9 @@ func parseSchema(data map[string]interface{}) (*Schema, error) {
 		if bitOrder, ok := configData["bit_order"].(string); ok {
 			schema.Config.BitOrder = bitOrder
 		}
+		schema.Config.Version = parseVersionField(configData["version"])
+		schema.Config.Magic = parseVersionField(configData["magic"])
+		schema.Config.InvalidUTF8, _ = configData["invalid_utf8"].(string)
 	}
 
 	// Parse types
@@ -876,6 +1999,9 @@ func parseSchema(data map[string]interface{}) (*Schema, error) {
 			}
 
 			typeDef := &TypeDef{}
+			typeDef.Description, _ = typeData["description"].(string)
+			typeDef.BitOrder, _ = typeData["bit_order"].(string)
+			typeDef.Protobuf, _ = typeData["protobuf"].(bool)
 
 			// Parse sequence
 			if sequenceData, ok := typeData["sequence"].([]interface{}); ok {
@@ -890,6 +2016,62 @@ func parseSchema(data map[string]interface{}) (*Schema, error) {
 				}
 			}
 
+			// Parse instances (fields at a position)
+			if instancesData, ok := typeData["instances"].([]interface{}); ok {
+				for _, instanceRaw := range instancesData {
+					instanceData, ok := instanceRaw.(map[string]interface{})
+					if !ok {
+						continue
+					}
+
+					field := parseField(instanceData)
+					field.Size = 0
+					field.Position = instanceData["position"]
+					field.InstanceSize = instanceData["size"]
+					field.Offsets, _ = instanceData["offsets"].(string)
+					if alignment, ok := instanceData["alignment"].(float64); ok {
+						field.Alignment = int(alignment)
+					}
+					typeDef.Instances = append(typeDef.Instances, field)
+				}
+			}
+
+			// A type alias ("Label": {"type": "string", ...}) is a struct holding one field, Value
+			if isAliasType(typeData) {
+				field := parseField(typeData)
+				field.Name = "value"
+				field.Description = ""
+				typeDef.Sequence = []Field{field}
+				typeDef.Alias = true
+			}
+
+			// Parse flag set
+			if typeData["type"] == "flags" {
+				typeDef.Repr, typeDef.Flags = parseFlags(typeData)
+			}
+
+			// Parse discriminated union
+			if typeData["type"] == "discriminated_union" {
+				typeDef.Discriminator = &Discriminator{}
+				if discData, ok := typeData["discriminator"].(map[string]interface{}); ok {
+					typeDef.Discriminator.Peek, _ = discData["peek"].(string)
+					typeDef.Discriminator.Field, _ = discData["field"].(string)
+					typeDef.Discriminator.Endianness, _ = discData["endianness"].(string)
+				}
+				variantsData, _ := typeData["variants"].([]interface{})
+				for _, variantRaw := range variantsData {
+					variantData, ok := variantRaw.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					variant := Variant{}
+					variant.Type, _ = variantData["type"].(string)
+					variant.When, _ = variantData["when"].(string)
+					variant.Description, _ = variantData["description"].(string)
+					typeDef.Variants = append(typeDef.Variants, variant)
+				}
+			}
+
 			schema.Types[typeName] = typeDef
 		}
 	}
@@ -897,6 +2079,39 @@ func parseSchema(data map[string]interface{}) (*Schema, error) {
 	return schema, nil
 }
 
+// isAliasType reports whether a schema type names another type instead of defining a
+// struct, union or flag set
+func isAliasType(typeData map[string]interface{}) bool {
+	if _, ok := typeData["sequence"]; ok {
+		return false
+	}
+	switch typeData["type"] {
+	case nil, "", "struct", "flags", "discriminated_union":
+		return false
+	}
+	return true
+}
+
+// usedPackages returns the packages generated code refers to, as the "time" of
+// time.Second. Selectors on local variables and words in comments don't count.
+func usedPackages(code []byte) map[string]bool {
+	used := make(map[string]bool)
+	file, err := parser.ParseFile(token.NewFileSet(), "", append([]byte("package main\n\n"), code...), 0)
+	if err != nil {
+		// Reported with the rest of the output by format.Source
+		return used
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok && ident.Obj == nil {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+	return used
+}
+
 // Template helpers (for future expansion)
 var templateFuncs = template.FuncMap{
 	"capitalize": capitalizeFirst,
@@ -3,6 +3,12 @@
 package codegen
 
 import (
+	"go/format"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -46,6 +52,44 @@ func TestGenerateSimpleStruct(t *testing.T) {
 	require.Contains(t, code, "encoder.WriteUint16(m.Y, runtime.BigEndian)")
 }
 
+func TestGenerateTypeOrder(t *testing.T) {
+	schema := map[string]interface{}{
+		"types": map[string]interface{}{
+			"Alpha": map[string]interface{}{"sequence": []interface{}{
+				map[string]interface{}{"name": "mid", "type": "Mid"},
+				map[string]interface{}{"name": "leaves", "type": "array", "kind": "length_prefixed", "length_type": "uint8",
+					"items": map[string]interface{}{"type": "Leaf"}},
+			}},
+			"Mid":   map[string]interface{}{"sequence": []interface{}{map[string]interface{}{"name": "leaf", "type": "Leaf"}}},
+			"Leaf":  map[string]interface{}{"sequence": []interface{}{map[string]interface{}{"name": "v", "type": "uint8"}}},
+			"Beta":  map[string]interface{}{"sequence": []interface{}{map[string]interface{}{"name": "v", "type": "uint8"}}},
+			"Cycle": map[string]interface{}{"sequence": []interface{}{map[string]interface{}{"name": "next", "type": "Cycle"}}},
+		},
+	}
+
+	code, err := GenerateGo(schema, "Alpha")
+	require.NoError(t, err)
+
+	// Dependencies come first, otherwise name order
+	var order []string
+	for _, line := range strings.Split(code, "\n") {
+		if strings.HasPrefix(line, "type ") {
+			order = append(order, strings.Fields(line)[1])
+		}
+	}
+	require.Equal(t, []string{"Leaf", "Mid", "Alpha", "Beta", "Cycle"}, order)
+
+	// Output is gofmt-formatted and identical across runs
+	formatted, err := format.Source([]byte(code))
+	require.NoError(t, err)
+	require.Equal(t, string(formatted), code)
+	for i := 0; i < 5; i++ {
+		again, err := GenerateGo(schema, "Alpha")
+		require.NoError(t, err)
+		require.Equal(t, code, again)
+	}
+}
+
 func TestGeneratePrimitiveTypes(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -189,3 +233,3831 @@ func TestGenerateEndianness(t *testing.T) {
 		})
 	}
 }
+
+// runGenerated compiles generated code together with a main function body in a
+// temporary module that resolves the runtime to this checkout, runs it, and
+// returns its combined output.
+func runGenerated(t *testing.T, code string, mainBody string) string {
+	t.Helper()
+	return runGeneratedWithTags(t, "", code, mainBody)
+}
+
+// runGeneratedWithTags is runGenerated with build tags (e.g. "trace")
+func runGeneratedWithTags(t *testing.T, tags string, code string, mainBody string) string {
+	t.Helper()
+	return runGeneratedFiles(t, tags, map[string]string{"generated.go": code}, mainBody)
+}
+
+// runGeneratedFiles is runGeneratedWithTags with more files next to the generated code,
+// for helpers main needs: files maps names to sources and must include generated.go
+func runGeneratedFiles(t *testing.T, tags string, files map[string]string, mainBody string) string {
+	t.Helper()
+
+	dir := writeGeneratedModule(t, files)
+	mainSrc := "package main\n\nimport (\n\t\"encoding/json\"\n\t\"fmt\"\n\n\t\"github.com/serialexp/binschema/runtime\"\n)\n\nvar _ = json.Marshal\nvar _ = fmt.Sprint\nvar _ = runtime.MSBFirst\n\nfunc main() {\n" + mainBody + "\n}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(mainSrc), 0644))
+
+	cmd := exec.Command("go", "run", "-tags", tags, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, "generated code failed to build/run:\n%s\n--- code ---\n%s", output, files["generated.go"])
+	return string(output)
+}
+
+// writeGeneratedModule writes files to a temporary module depending on the binschema
+// runtime in this checkout and returns its directory
+func writeGeneratedModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	root, err := filepath.Abs("..")
+	require.NoError(t, err)
+
+	goMod := "module gentest\n\ngo 1.21\n\nrequire github.com/serialexp/binschema v0.0.0\n\nreplace github.com/serialexp/binschema => " + root + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0644))
+	goSum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.sum"), goSum, 0644))
+	for name, src := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0644))
+	}
+	return dir
+}
+
+func TestGenerateRecursiveTypes(t *testing.T) {
+	schema := map[string]interface{}{
+		"types": map[string]interface{}{
+			// Self-reference through an array: slices already break the size cycle
+			"TreeNode": map[string]interface{}{
+				"sequence": []interface{}{
+					map[string]interface{}{"name": "value", "type": "uint8"},
+					map[string]interface{}{
+						"name": "children", "type": "array", "kind": "length_prefixed", "length_type": "uint8",
+						"items": map[string]interface{}{"type": "TreeNode"},
+					},
+				},
+			},
+			// Mutual by-value recursion terminated by a conditional
+			"Outer": map[string]interface{}{
+				"sequence": []interface{}{
+					map[string]interface{}{"name": "present", "type": "uint8"},
+					map[string]interface{}{"name": "inner", "type": "Inner", "conditional": "present == 1"},
+				},
+			},
+			"Inner": map[string]interface{}{
+				"sequence": []interface{}{
+					map[string]interface{}{"name": "outer", "type": "Outer"},
+				},
+			},
+		},
+	}
+
+	code, err := GenerateGo(schema, "TreeNode")
+	require.NoError(t, err)
+
+	require.Contains(t, code, "Children []TreeNode")
+	require.Contains(t, code, "Inner   *Inner")
+	require.Contains(t, code, "Outer *Outer")
+	require.Contains(t, code, "decoder.EnterNested()")
+
+	output := runGenerated(t, code, `
+	tree := &TreeNode{Value: 1, Children: []TreeNode{{Value: 2}, {Value: 3, Children: []TreeNode{{Value: 4}}}}}
+	encoded, err := tree.Encode()
+	if err != nil {
+		panic(err)
+	}
+	decoded, err := DecodeTreeNode(encoded)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(encoded, decoded.Children[1].Children[0].Value)
+
+	outer := &Outer{Present: 1, Inner: &Inner{Outer: &Outer{Present: 0}}}
+	encoded, err = outer.Encode()
+	if err != nil {
+		panic(err)
+	}
+	decodedOuter, err := DecodeOuter(encoded)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(encoded, decodedOuter.Inner.Outer.Present)
+
+	// Each level is one byte (value=0, 1 child); nesting beyond the limit must fail, not overflow
+	deep := make([]byte, 0, 2000)
+	for i := 0; i < 1000; i++ {
+		deep = append(deep, 0, 1)
+	}
+	_, err = DecodeTreeNode(deep)
+	fmt.Println(err != nil)
+`)
+	require.Equal(t, "[1 2 2 0 3 1 4 0] 4\n[1 0] 0\ntrue\n", output)
+}
+
+func TestGenerateNestedPointers(t *testing.T) {
+	schema := map[string]interface{}{
+		"types": map[string]interface{}{
+			"Header": map[string]interface{}{
+				"sequence": []interface{}{
+					map[string]interface{}{"name": "version", "type": "uint8"},
+				},
+			},
+			"Packet": map[string]interface{}{
+				"sequence": []interface{}{
+					map[string]interface{}{"name": "flags", "type": "uint8"},
+					map[string]interface{}{"name": "header", "type": "Header"},
+					map[string]interface{}{"name": "extra", "type": "Header", "conditional": "flags == 1"},
+					map[string]interface{}{
+						"name": "items", "type": "array", "kind": "length_prefixed", "length_type": "uint8",
+						"items": map[string]interface{}{"type": "Header"},
+					},
+				},
+			},
+		},
+	}
+
+	// By default nested structs are embedded by value
+	code, err := GenerateGo(schema, "Packet")
+	require.NoError(t, err)
+	require.Contains(t, code, "Header Header")
+	require.Contains(t, code, "Extra  Header")
+
+	// Per-field selection
+	code, err = GenerateGoWithOptions(schema, "Packet", GenerateOptions{PointerFields: []string{"Packet.extra"}})
+	require.NoError(t, err)
+	require.Contains(t, code, "Header Header")
+	require.Contains(t, code, "Extra  *Header")
+
+	_, err = GenerateGoWithOptions(schema, "Packet", GenerateOptions{PointerFields: []string{"Packet.missing"}})
+	require.ErrorContains(t, err, "Packet.missing")
+	_, err = GenerateGoWithOptions(schema, "Packet", GenerateOptions{PointerFields: []string{"Packet.flags"}})
+	require.ErrorContains(t, err, "not a nested struct")
+
+	// Global option: array items stay values
+	code, err = GenerateGoWithOptions(schema, "Packet", GenerateOptions{NestedPointers: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "Header *Header")
+	require.Contains(t, code, "Extra  *Header")
+	require.Contains(t, code, "Items  []Header")
+
+	output := runGenerated(t, code, `
+	// An absent conditional field may stay nil
+	packet := &Packet{Flags: 0, Header: &Header{Version: 2}, Items: []Header{{Version: 3}}}
+	encoded, err := packet.Encode()
+	if err != nil {
+		panic(err)
+	}
+	decoded, err := DecodePacket(encoded)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(encoded, decoded.Header.Version, decoded.Extra == nil, decoded.Items[0].Version)
+
+	packet = &Packet{Flags: 1, Header: &Header{Version: 2}, Extra: &Header{Version: 5}}
+	encoded, err = packet.Encode()
+	if err != nil {
+		panic(err)
+	}
+	decoded, err = DecodePacket(encoded)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(encoded, decoded.Extra.Version)
+
+	// A nil field that must be written has no wire representation
+	_, err = (&Packet{Flags: 1, Header: &Header{}}).Encode()
+	fmt.Println(err)
+`)
+	require.Equal(t, "[0 2 1 3] 2 true 3\n[1 2 5 0] 5\nextra: nested Header is nil\n", output)
+}
+
+func TestGenerateEmptySlices(t *testing.T) {
+	schema := map[string]interface{}{
+		"types": map[string]interface{}{
+			"Message": map[string]interface{}{
+				"sequence": []interface{}{
+					map[string]interface{}{"name": "flags", "type": "uint8"},
+					map[string]interface{}{
+						"name": "items", "type": "array", "kind": "length_prefixed", "length_type": "uint8",
+						"items": map[string]interface{}{"type": "uint8"},
+					},
+					map[string]interface{}{
+						"name": "extra", "type": "array", "kind": "length_prefixed", "length_type": "uint8",
+						"items":       map[string]interface{}{"type": "uint16"},
+						"conditional": "flags == 1",
+					},
+				},
+			},
+		},
+	}
+
+	mainBody := `
+	decoded, err := DecodeMessage([]byte{0, 0})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(decoded.Items == nil, len(decoded.Items), decoded.Extra == nil, len(decoded.Extra))
+`
+	tests := []struct {
+		mode   EmptySliceMode
+		output string
+	}{
+		{EmptySlicesAsDecoded, "false 0 true 0\n"},
+		{EmptySlicesNil, "true 0 true 0\n"},
+		{EmptySlicesNonNil, "false 0 false 0\n"},
+	}
+	for _, tt := range tests {
+		code, err := GenerateGoWithOptions(schema, "Message", GenerateOptions{EmptySlices: tt.mode})
+		require.NoError(t, err)
+		require.Equal(t, tt.output, runGenerated(t, code, mainBody), "mode %d", tt.mode)
+	}
+}
+
+func TestGenerateSchemaDescriptor(t *testing.T) {
+	schema := map[string]interface{}{
+		"meta":   map[string]interface{}{"title": "Sensor Protocol", "version": "1.2"},
+		"config": map[string]interface{}{"endianness": "little_endian"},
+		"types": map[string]interface{}{
+			"Reading": map[string]interface{}{
+				"description": "One sample",
+				"sequence": []interface{}{
+					map[string]interface{}{"name": "sensor", "type": "uint8", "description": "Sensor id"},
+					map[string]interface{}{"name": "value", "type": "int32", "endianness": "big_endian",
+						"metadata": map[string]interface{}{"unit": "mK", "scale": float64(0.001), "tags": []interface{}{"raw", true}}},
+				},
+			},
+			"Report": map[string]interface{}{
+				"sequence": []interface{}{
+					map[string]interface{}{"name": "name", "type": "string", "kind": "fixed", "length": float64(4)},
+					map[string]interface{}{
+						"name": "readings", "type": "array", "kind": "length_prefixed", "length_type": "uint16",
+						"items": map[string]interface{}{"type": "Reading"},
+					},
+				},
+			},
+		},
+	}
+
+	code, err := GenerateGo(schema, "Report")
+	require.NoError(t, err)
+	require.Contains(t, code, "func Schema() *runtime.SchemaInfo")
+
+	output := runGenerated(t, code, `
+	info := Schema()
+	fmt.Println(info.Title, info.Version, info.Endianness, len(info.Types), info.Types[0].Name)
+
+	reading, _ := info.Type("Reading")
+	value, _ := reading.Field("value")
+	fmt.Println(reading.Description, reading.Width, value.GoName, value.Width, value.Endianness, value.Metadata["unit"], value.Metadata["scale"], value.Metadata["tags"])
+
+	report, _ := info.Type("Report")
+	readings, _ := report.Field("readings")
+	name, _ := report.Field("name")
+	fmt.Println(report.Width, name.Width, name.Length, readings.Kind, readings.LengthType, readings.Items.Type, readings.Items.Width)
+
+	// Each call returns a copy: changing one leaves the next untouched
+	info.Title = "changed"
+	value.Metadata["unit"] = "K"
+	value.Metadata["tags"].([]interface{})[0] = "cooked"
+	readings.Items.Type = "Other"
+	again := Schema()
+	reading, _ = again.Type("Reading")
+	value, _ = reading.Field("value")
+	report, _ = again.Type("Report")
+	readings, _ = report.Field("readings")
+	fmt.Println(again.Title, value.Metadata["unit"], value.Metadata["tags"], readings.Items.Type)
+`)
+	require.Equal(t, "Sensor Protocol 1.2 little_endian 2 Reading\n"+
+		"One sample 40 Value 32 big_endian mK 0.001 [raw true]\n"+
+		"0 32 4 length_prefixed uint16 Reading 40\n"+
+		"Sensor Protocol mK [raw true] Reading\n", output)
+
+	// A schema type named Schema keeps its name; the accessor moves aside
+	schema["types"].(map[string]interface{})["Schema"] = map[string]interface{}{
+		"sequence": []interface{}{map[string]interface{}{"name": "x", "type": "uint8"}},
+	}
+	code, err = GenerateGo(schema, "Report")
+	require.NoError(t, err)
+	require.Contains(t, code, "func SchemaDescriptor() *runtime.SchemaInfo")
+}
+
+// debugSchema exercises nested, pointer, conditional and array fields in String() and traces
+var debugSchema = map[string]interface{}{
+	"types": map[string]interface{}{
+		"Reading": map[string]interface{}{
+			"sequence": []interface{}{
+				map[string]interface{}{"name": "sensor", "type": "uint8"},
+				map[string]interface{}{"name": "value", "type": "int16"},
+			},
+		},
+		"Report": map[string]interface{}{
+			"sequence": []interface{}{
+				map[string]interface{}{"name": "flags", "type": "uint8"},
+				map[string]interface{}{"name": "name", "type": "string", "kind": "null_terminated"},
+				map[string]interface{}{"name": "extra", "type": "Reading", "conditional": "flags == 1"},
+				map[string]interface{}{
+					"name": "readings", "type": "array", "kind": "length_prefixed", "length_type": "uint8",
+					"items": map[string]interface{}{"type": "Reading"},
+				},
+				map[string]interface{}{
+					"name": "raw", "type": "array", "kind": "fixed", "length": float64(2),
+					"items": map[string]interface{}{"type": "uint8"},
+				},
+			},
+		},
+	},
+}
+
+func TestGenerateStringMethods(t *testing.T) {
+	code, err := GenerateGoWithOptions(debugSchema, "Report", GenerateOptions{PointerFields: []string{"Report.extra"}})
+	require.NoError(t, err)
+
+	output := runGenerated(t, code, `
+	report := &Report{Name: "hi", Readings: []Reading{{Sensor: 1, Value: -2}}, Raw: []uint8{0xca, 0xfe}}
+	fmt.Println(report)
+	fmt.Printf("%#v\n", report)
+	report.Flags = 1
+	report.Extra = &Reading{Sensor: 9}
+	fmt.Println(report.String())
+	var missing *Reading
+	fmt.Println(missing.String(), missing.GoString())
+`)
+	require.Equal(t, `Report{flags: 0, name: "hi", extra: nil, readings: [Reading{sensor: 1, value: -2}], raw: [202 254]}
+&Report{Flags: 0x0, Name: "hi", Extra: nil, Readings: []Reading{Reading{Sensor: 0x1, Value: -2}}, Raw: []byte{0xca, 0xfe}}
+Report{flags: 1, name: "hi", extra: Reading{sensor: 9, value: 0}, readings: [Reading{sensor: 1, value: -2}], raw: [202 254]}
+nil (*Reading)(nil)
+`, output)
+}
+
+func TestGenerateDecodeTrace(t *testing.T) {
+	code, err := GenerateGoWithOptions(debugSchema, "Report", GenerateOptions{PointerFields: []string{"Report.extra"}})
+	require.NoError(t, err)
+	require.Contains(t, code, "if traceEnabled {")
+	require.Contains(t, code, "func decodeReportIntoTraced(")
+
+	mainBody := `
+	report := &Report{Flags: 1, Name: "hi", Extra: &Reading{Sensor: 9}, Readings: []Reading{{Sensor: 1, Value: -2}}, Raw: []uint8{0xca, 0xfe}}
+	encoded, err := report.Encode()
+	if err != nil {
+		panic(err)
+	}
+	dump, err := DumpAnnotated(append(encoded, 0x42))
+	fmt.Print(dump, err, "\n")
+	dump, err = DumpAnnotated(encoded[:6])
+	fmt.Print(dump, err, "\n")
+
+	// Any TraceSink can collect the events
+	sink := &runtime.Trace{}
+	decoder := runtime.NewBitStreamDecoder(encoded, runtime.MSBFirst)
+	decoder.Trace = sink
+	if _, err := decodeReportWithDecoder(decoder, nil); err != nil {
+		panic(err)
+	}
+	for _, event := range sink.Events {
+		if !event.Container {
+			fmt.Printf("%s %d.%d-%d.%d %v\n", event.Path, event.ByteStart, event.BitStart, event.ByteEnd, event.BitEnd, event.Value)
+		}
+	}
+`
+
+	// Dump columns are padded; compare with runs of spaces collapsed
+	spaces := regexp.MustCompile(` +`)
+	want := `Report (14 bytes)
+0000 01 flags: 1
+0001 68 69 00 name: "hi"
+0004 extra (3 bytes)
+0004 09 sensor: 9
+0005 00 00 value: 0
+0007 readings (4 bytes)
+0007 01 length: 1
+0008 [0] (3 bytes)
+0008 01 sensor: 1
+0009 ff fe value: -2
+000b raw (2 bytes)
+000b ca [0]: 202
+000c fe [1]: 254
+000d 42 (1 byte not decoded)
+
+0000 01 68 69 00 09 00 00 01 01 ff fe ca fe 42 |.hi..........B|
+<nil>
+Report (6 bytes)
+0000 01 flags: 1
+0001 68 69 00 name: "hi"
+0004 extra (1 byte) (incomplete)
+0004 09 sensor: 9
+0005 value (incomplete)
+0005 00 (1 byte not decoded)
+
+0000 01 68 69 00 09 00 |.hi...|
+unexpected end of stream
+flags 0.0-1.0 1
+name 1.0-4.0 hi
+extra.sensor 4.0-5.0 9
+extra.value 5.0-7.0 0
+readings.length 7.0-8.0 1
+readings[0].sensor 8.0-9.0 1
+readings[0].value 9.0-11.0 -2
+raw[0] 11.0-12.0 202
+raw[1] 12.0-13.0 254
+`
+	// Decoders with a Trace attached trace with or without the tag
+	require.Equal(t, want, spaces.ReplaceAllString(runGenerated(t, code, mainBody), " "))
+	require.Equal(t, want, spaces.ReplaceAllString(runGeneratedWithTags(t, "trace", code, mainBody), " "))
+}
+
+func TestGenerateJSONMethods(t *testing.T) {
+	code, err := GenerateGoWithOptions(debugSchema, "Report", GenerateOptions{PointerFields: []string{"Report.extra"}})
+	require.NoError(t, err)
+	require.Contains(t, code, "\"encoding/json\"")
+
+	output := runGenerated(t, code, `
+	report := Report{Flags: 0, Name: "hi", Readings: []Reading{{Sensor: 1, Value: -2}}, Raw: []uint8{0xca, 0xfe}}
+	data, err := json.Marshal(report)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(data))
+
+	// Decoding the JSON and encoding the result reproduces the binary message
+	var decoded Report
+	if err := json.Unmarshal([]byte(`+"`"+`{"flags": 1, "name": "hi", "extra": {"sensor": 9, "value": 3}, "readings": [], "raw": [1, 2]}`+"`"+`), &decoded); err != nil {
+		panic(err)
+	}
+	encoded, err := decoded.Encode()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(encoded, decoded.Extra.Value)
+
+	again, err := DecodeReport(encoded)
+	if err != nil {
+		panic(err)
+	}
+	data, _ = json.Marshal(again)
+	fmt.Println(string(data))
+`)
+	require.Equal(t, `{"flags":0,"name":"hi","readings":[{"sensor":1,"value":-2}],"raw":[202,254]}
+[1 104 105 0 9 0 3 0 1 2] 3
+{"flags":1,"name":"hi","extra":{"sensor":9,"value":3},"readings":[],"raw":[1,2]}
+`, output)
+}
+
+func TestGenerateUnknownAttributes(t *testing.T) {
+	schema := map[string]interface{}{
+		"config": map[string]interface{}{"endianess": "little_endian"},
+		"types": map[string]interface{}{
+			"Message": map[string]interface{}{
+				"notes": []interface{}{"documentation is allowed"},
+				"sequence": []interface{}{
+					map[string]interface{}{
+						"name": "data", "type": "array", "kind": "length_prefixed", "lenght_type": "uint16",
+						"items": map[string]interface{}{"type": "uint8", "endian": "big_endian"},
+					},
+				},
+			},
+		},
+	}
+
+	// Ignored by default
+	_, err := GenerateGo(schema, "Message")
+	require.NoError(t, err)
+
+	var warnings []string
+	_, err = GenerateGoWithOptions(schema, "Message", GenerateOptions{
+		UnknownAttributes: AttributesWarn,
+		Warn:              func(msg string) { warnings = append(warnings, msg) },
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"unknown schema attribute config.endianess",
+		"unknown schema attribute types.Message.sequence[0].items.endian",
+		"unknown schema attribute types.Message.sequence[0].lenght_type",
+	}, warnings)
+
+	_, err = GenerateGoWithOptions(schema, "Message", GenerateOptions{UnknownAttributes: AttributesStrict})
+	require.EqualError(t, err, "unknown schema attributes: config.endianess, types.Message.sequence[0].items.endian, types.Message.sequence[0].lenght_type")
+}
+
+func TestGenerateInlineStructs(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Header": { sequence: [
+				{ name: "id", type: "uint16" },
+				{ name: "flags", type: "bitfield", size: 16, fields: [
+					{ name: "qr", offset: 0, size: 1 },
+					{ name: "opcode", offset: 1, size: 4 },
+					{ name: "aa", offset: 5, size: 1 },
+					{ name: "tc", offset: 6, size: 1 },
+					{ name: "rd", offset: 7, size: 1 },
+					{ name: "ra", offset: 8, size: 1 },
+					{ name: "z", offset: 9, size: 3 },
+					{ name: "rcode", offset: 12, size: 4 },
+				] },
+				{ name: "counts", type: "struct", fields: [
+					{ name: "kind", type: "bitfield", size: 8, fields: [
+						{ name: "major", offset: 0, size: 4 },
+						{ name: "minor", offset: 4, size: 4 },
+					] },
+					{ name: "total", type: "uint16" },
+				] },
+				{ name: "marks", type: "array", kind: "fixed", length: 2, items: {
+					type: "bitfield", size: 8, fields: [
+						{ name: "hi", offset: 0, size: 4 },
+						{ name: "lo", offset: 4, size: 4 },
+					],
+				} },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Header")
+	require.NoError(t, err)
+	require.Contains(t, code, "type Header_Flags struct")
+	require.Contains(t, code, "type Header_Counts struct")
+	require.Contains(t, code, "type Header_Counts_Kind struct")
+	require.Contains(t, code, "Marks  []Header_Marks")
+	require.Contains(t, code, "func DecodeHeader_Counts(")
+	// Bitfields are read in place by their parent
+	require.NotContains(t, code, "func DecodeHeader_Flags(")
+	require.NotContains(t, code, "func (m *Header_Flags) Encode(")
+
+	output := runGenerated(t, code, `
+	input := []byte{0x12, 0x34, 0x91, 0x83, 0x56, 0x00, 0x01, 0xab, 0xcd}
+	header, err := DecodeHeader(input)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(header.Flags.Opcode, header.Flags.Rcode, header.Counts.Kind.Minor, header.Marks[1].Lo)
+	data, err := json.Marshal(header)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(data))
+
+	encoded, err := header.Encode()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(encoded) == string(input))
+`)
+	require.Equal(t, `2 3 6 13
+{"id":4660,"flags":{"qr":1,"opcode":2,"aa":0,"tc":0,"rd":1,"ra":1,"z":0,"rcode":3},"counts":{"kind":{"major":5,"minor":6},"total":1},"marks":[{"hi":10,"lo":11},{"hi":12,"lo":13}]}
+true
+`, output)
+}
+
+func TestGenerateUnionArrays(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Literal": { sequence: [ { name: "value", type: "string", kind: "length_prefixed", length_type: "uint8", encoding: "ascii" } ] },
+			"Pointer": { sequence: [ { name: "offset", type: "uint16" } ] },
+			"Label": { type: "discriminated_union", discriminator: { peek: "uint8" }, variants: [
+				{ type: "Literal", when: "value < 0xC0" },
+				{ type: "Pointer", when: "value >= 0xC0" },
+			] },
+			"Name": { sequence: [
+				{ name: "labels", type: "array", kind: "null_terminated", items: { type: "Label" }, terminal_variants: ["Pointer"] },
+				{ name: "chain", type: "array", kind: "variant_terminated", items: { type: "Label" }, terminal_variants: ["Pointer"] },
+				{ name: "last", type: "Label" },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Name")
+	require.NoError(t, err)
+	require.Contains(t, code, "type Label interface {")
+	require.Contains(t, code, "break labelsLoop")
+
+	output := runGenerated(t, code, `
+	inputs := [][]byte{
+		// "www" then a pointer: the pointer ends labels, no zero byte follows
+		{3, 'w', 'w', 'w', 0xc0, 0x0c, 1, 'a', 0xc0, 0x10, 0xc0, 0x20},
+		// Labels ended by a zero byte
+		{3, 'c', 'o', 'm', 0, 0xc0, 0x01, 2, 'h', 'i'},
+	}
+	for _, input := range inputs {
+		name, err := DecodeName(input)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(name)
+		data, err := json.Marshal(name)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(data))
+
+		var decoded Name
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			panic(err)
+		}
+		encoded, err := decoded.Encode()
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(encoded) == string(input))
+	}
+
+	_, err := DecodeLabel([]byte{})
+	fmt.Println(err != nil)
+	_, err = (&Name{Labels: []Label{nil}}).Encode()
+	fmt.Println(err)
+`)
+	require.Equal(t, `Name{labels: [Literal{value: "www"}, Pointer{offset: 49164}], chain: [Literal{value: "a"}, Pointer{offset: 49168}], last: Pointer{offset: 49184}}
+{"labels":[{"type":"Literal","value":{"value":"www"}},{"type":"Pointer","value":{"offset":49164}}],"chain":[{"type":"Literal","value":{"value":"a"}},{"type":"Pointer","value":{"offset":49168}}],"last":{"type":"Pointer","value":{"offset":49184}}}
+true
+Name{labels: [Literal{value: "com"}], chain: [Pointer{offset: 49153}], last: Literal{value: "hi"}}
+{"labels":[{"type":"Literal","value":{"value":"com"}}],"chain":[{"type":"Pointer","value":{"offset":49153}}],"last":{"type":"Literal","value":{"value":"hi"}}}
+true
+true
+array item: nested Label is nil
+`, output)
+}
+
+func TestGenerateParentReferences(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Header": { sequence: [ { name: "version", type: "uint8" } ] },
+			"Body": { sequence: [
+				{ name: "flags", type: "uint8" },
+				{ name: "extra", type: "uint16", conditional: "../header.version == 2" },
+			] },
+			"Record": { sequence: [
+				{ name: "id", type: "uint8" },
+				{ name: "checksum", type: "uint8", conditional: "../header.version >= 2" },
+			] },
+			"Packet": { sequence: [
+				{ name: "header", type: "Header" },
+				{ name: "body", type: "Body" },
+				{ name: "records", type: "array", kind: "fixed", length: 2, items: { type: "Record" } },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Packet")
+	require.NoError(t, err)
+	require.Contains(t, code, "switch parent := ctx.ParentStruct(1).(type) {")
+	require.Contains(t, code, "extra_ref = int64(parent.Header.Version)")
+
+	output := runGenerated(t, code, `
+	inputs := [][]byte{
+		{1, 0xff, 7, 8},
+		{2, 0xff, 0x12, 0x34, 7, 0xa7, 8, 0xa8},
+	}
+	for _, input := range inputs {
+		packet, err := DecodePacket(input)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(packet)
+		encoded, err := packet.Encode()
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(encoded) == string(input))
+	}
+
+	// Without a parent there is nothing to resolve ../header against
+	_, err := DecodeBody([]byte{0xff, 0x12, 0x34})
+	fmt.Println(err)
+`)
+	require.Equal(t, `Packet{header: Header{version: 1}, body: Body{flags: 255, extra: 0}, records: [Record{id: 7, checksum: 0}, Record{id: 8, checksum: 0}]}
+true
+Packet{header: Header{version: 2}, body: Body{flags: 255, extra: 4660}, records: [Record{id: 7, checksum: 167}, Record{id: 8, checksum: 168}]}
+true
+extra: ../header.version: no parent 1 levels up
+`, output)
+}
+
+func TestGenerateParentContextOnlyWhereUsed(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Header": { sequence: [ { name: "version", type: "uint8" } ] },
+			"Section": { sequence: [
+				{ name: "extra", type: "uint8", conditional: "../../header.version == 2" },
+			] },
+			"Body": { sequence: [ { name: "section", type: "Section" } ] },
+			"Point": { sequence: [ { name: "x", type: "uint8" } ] },
+			"Shape": { sequence: [ { name: "points", type: "array", kind: "fixed", length: 2, items: { type: "Point" } } ] },
+			"Packet": { sequence: [
+				{ name: "header", type: "Header" },
+				{ name: "body", type: "Body" },
+				{ name: "shape", type: "Shape" },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Packet")
+	require.NoError(t, err)
+	// Packet and Body pass their fields down to Section; Shape's points look up nothing
+	for typeName, extends := range map[string]bool{"Packet": true, "Body": true, "Section": false, "Shape": false} {
+		start := strings.Index(code, "func (m *"+typeName+") EncodeWithContext")
+		require.GreaterOrEqual(t, start, 0, typeName)
+		body := code[start : start+strings.Index(code[start:], "\n}\n")]
+		require.Equal(t, extends, strings.Contains(body, "ExtendWithParent"), typeName)
+	}
+	require.Contains(t, code, "decodeShapeInto(decoder, ctx, &result.Shape)")
+
+	output := runGenerated(t, code, `
+	for _, input := range [][]byte{{1, 5, 6}, {2, 9, 5, 6}} {
+		packet, err := DecodePacket(input)
+		if err != nil {
+			panic(err)
+		}
+		encoded, err := packet.Encode()
+		fmt.Println(packet.Body.Section.Extra, string(encoded) == string(input), err)
+	}
+`)
+	require.Equal(t, "0 true <nil>\n9 true <nil>\n", output)
+}
+
+func TestGenerateParentReferencesThroughUnionsAndPointers(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Header": { sequence: [ { name: "version", type: "uint8" } ] },
+			"Ping": { sequence: [
+				{ name: "kind", type: "uint8" },
+				{ name: "extra", type: "uint8", conditional: "../header.version == 2" },
+			] },
+			"Pong": { sequence: [ { name: "kind", type: "uint8" } ] },
+			"Message": { type: "discriminated_union", discriminator: { peek: "uint8" }, variants: [
+				{ when: "value == 1", type: "Ping" },
+				{ when: "value == 2", type: "Pong" },
+			] },
+			"Packet": { sequence: [
+				{ name: "header", type: "Header" },
+				{ name: "tag", type: "uint8" },
+				{ name: "message", type: "Message" },
+			] },
+		},
+	}`)
+	code, err := GenerateGoWithOptions(schema, "Packet", GenerateOptions{NestedPointers: true})
+	require.NoError(t, err)
+	// The union adds no level: Ping's parent is the Packet holding the union
+	require.Contains(t, code, "case *Packet:\n\t\tif parent.Header == nil {")
+
+	output := runGenerated(t, code, `
+	packet, err := DecodePacket([]byte{2, 0, 1, 9})
+	fmt.Println(packet.Message.(*Ping).Extra, err)
+	ping := packet.Message.(*Ping)
+	_, err = ping.EncodeWithContext(runtime.NewEncodingContext().ExtendWithParentStruct(&Packet{}))
+	fmt.Println(err)
+	_, err = ping.EncodeWithContext(runtime.NewEncodingContext().ExtendWithParentStruct(&Header{}))
+	fmt.Println(err)
+`)
+	require.Equal(t, `9 <nil>
+extra: ../header.version: header is nil
+extra: ../header.version: no parent 1 levels up
+`, output)
+}
+
+func TestGenerateParentReferenceErrors(t *testing.T) {
+	for _, tc := range []struct{ ref, err string }{
+		{"../header.nope == 1", "Body.extra: ../header.nope: Header has no field nope"},
+		{"../name.x == 1", "Body.extra: ../name.x: Packet.name is not a struct"},
+		{"../name == 1", "Body.extra: ../name: Packet.name is not an integer"},
+		{"../trailer == 1", "Body.extra: ../trailer: Packet decodes trailer after body"},
+		{"../../header.version == 1", "Body.extra: ../../header.version: no type holds Body 2 levels up"},
+	} {
+		schema := parseTestSchema(t, `{
+			types: {
+				"Header": { sequence: [ { name: "version", type: "uint8" } ] },
+				"Body": { sequence: [ { name: "extra", type: "uint8", conditional: "`+tc.ref+`" } ] },
+				"Packet": { sequence: [
+					{ name: "header", type: "Header" },
+					{ name: "name", type: "string", kind: "length_prefixed", length_type: "uint8" },
+					{ name: "body", type: "Body" },
+					{ name: "trailer", type: "uint8" },
+				] },
+			},
+		}`)
+		_, err := GenerateGo(schema, "Packet")
+		require.EqualError(t, err, tc.err, tc.ref)
+	}
+}
+
+func TestGenerateLengthExpressions(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Image": { sequence: [
+				{ name: "width", type: "uint8" },
+				{ name: "height", type: "uint8" },
+				{ name: "pixels", type: "array", kind: "computed_count", count_expr: "width * height", items: { type: "uint8" } },
+				{ name: "name_len", type: "uint8" },
+				{ name: "name", type: "string", kind: "field_referenced", length_field: "name_len", encoding: "ascii" },
+				{ name: "rdlength", type: "uint8" },
+				{ name: "tag", type: "string", kind: "fixed", length: "rdlength - 2", encoding: "ascii" },
+				{ name: "stride", type: "uint8" },
+				{ name: "rows", type: "array", kind: "fixed", length: "(rdlength - 2) / stride", items: { type: "uint8" } },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Image")
+	require.NoError(t, err)
+	require.Contains(t, code, "pixels_computed_length := (int64(result.Width) * int64(result.Height))")
+
+	output := runGenerated(t, code, `
+	input := []byte{2, 3, 1, 2, 3, 4, 5, 6, 2, 'h', 'i', 6, 'a', 'b', 0, 0, 2, 7, 8}
+	image, err := DecodeImage(input)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(image)
+	encoded, err := image.Encode()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(encoded) == string(input))
+
+	_, err = DecodeImage([]byte{0, 0, 0, 1, 0})
+	fmt.Println(err)
+	_, err = DecodeImage([]byte{0, 0, 0, 4, 'a', 'b', 0, 0, 0})
+	fmt.Println(err)
+	_, err = DecodeImage([]byte{200, 200, 1})
+	fmt.Println(err)
+`)
+	require.Equal(t, `Image{width: 2, height: 3, pixels: [1 2 3 4 5 6], name_len: 2, name: "hi", rdlength: 6, tag: "ab", stride: 2, rows: [7 8]}
+true
+tag: negative length -1 from "rdlength - 2"
+rows: length divides by zero
+pixels: length 40000 exceeds remaining data
+`, output)
+
+	// Lengths are integer arithmetic on fields
+	_, err = GenerateGo(parseTestSchema(t, `{ types: { "Bad": { sequence: [
+		{ name: "n", type: "uint8" },
+		{ name: "data", type: "array", kind: "computed_count", count_expr: "n > 2", items: { type: "uint8" } },
+	] } } }`), "Bad")
+	require.EqualError(t, err, `data: length "n > 2": operator > doesn't give a number`)
+}
+
+func TestGenerateInstances(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Entry": { sequence: [
+				{ name: "a", type: "uint8" },
+				{ name: "b", type: "uint8" },
+			] },
+			"File": {
+				sequence: [
+					{ name: "version", type: "uint8" },
+					{ name: "entry_offset", type: "uint8" },
+				],
+				instances: [
+					{ name: "entry", type: "Entry", position: "entry_offset", size: 2, alignment: 4 },
+				],
+			},
+			"Tail": {
+				sequence: [{ name: "tag", type: "uint8" }],
+				instances: [{ name: "checksum", type: "uint8", position: -1 }],
+			},
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "File")
+	require.NoError(t, err)
+
+	output := runGenerated(t, code, `
+	file, err := DecodeFile([]byte{1, 4, 0xff, 0xff, 7, 8})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(file)
+	encoded, err := (&File{Version: 1, Entry: Entry{A: 7, B: 8}}).Encode()
+	fmt.Println(encoded, err)
+
+	_, err = DecodeFile([]byte{1, 9, 0})
+	fmt.Println(err)
+	_, err = DecodeFile([]byte{1, 2, 7, 8})
+	fmt.Println(err)
+	_, err = DecodeFile([]byte{1, 4, 0, 0, 7})
+	fmt.Println(err)
+
+	tail, err := DecodeTail([]byte{5, 0, 9})
+	fmt.Println(tail, err)
+	_, err = tail.Encode()
+	fmt.Println(err)
+`)
+	require.Equal(t, `File{version: 1, entry_offset: 4, entry: Entry{a: 7, b: 8}}
+[1 4 0 0 7 8] <nil>
+entry: position 9 is outside the data
+entry: position 2 is not a multiple of 4
+entry: 2 bytes at position 4 run past the end of the data
+Tail{tag: 5, checksum: 9} <nil>
+Tail: instance checksum counts back from the end of the data and can only be decoded
+`, output)
+}
+
+func TestGeneratePositionOf(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Index": { sequence: [
+				{ name: "entry_offset", type: "uint8", computed: { type: "position_of", target: "entry" } },
+				{ name: "entry", type: "uint8" },
+			] },
+			"File": { sequence: [
+				{ name: "version", type: "uint8" },
+				{ name: "index_offset", type: "uint16", computed: { type: "position_of", target: "index" } },
+				{ name: "name_length", type: "uint8" },
+				{ name: "name", type: "string", kind: "field_referenced", length_field: "name_length", encoding: "ascii" },
+				{ name: "name_offset", type: "uint8", computed: { type: "position_of", target: "name" } },
+				{ name: "index", type: "Index" },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "File")
+	require.NoError(t, err)
+	require.Contains(t, code, "index_offset_slot, err := encoder.ReservePlaceholder(2)")
+	require.Contains(t, code, "ctx.At(encoder.Position())")
+
+	output := runGenerated(t, code, `
+	encoded, err := (&File{Version: 1, Name_length: 2, Name: "hi", Index: Index{Entry: 9}}).Encode()
+	fmt.Println(encoded, err)
+	file, err := DecodeFile(encoded)
+	fmt.Println(file, err)
+
+	long := make([]byte, 250)
+	_, err = (&File{Name_length: 250, Name: string(long)}).Encode()
+	fmt.Println(err)
+`)
+	require.Equal(t, `[1 0 7 2 104 105 4 8 9] <nil>
+File{version: 1, index_offset: 7, name_length: 2, name: "hi", name_offset: 4, index: Index{entry_offset: 8, entry: 9}} <nil>
+entry_offset: offset 256 of entry doesn't fit in uint8
+`, output)
+
+	// Offsets are of fields of the same struct
+	_, err = GenerateGo(parseTestSchema(t, `{ types: { "Bad": { sequence: [
+		{ name: "data_offset", type: "uint32", computed: { type: "position_of", target: "../data" } },
+	] } } }`), "Bad")
+	require.EqualError(t, err, `data_offset: position_of target "../data" is not another field of this struct`)
+}
+
+func TestGenerateDynamicEndianness(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "dynamic" },
+		types: {
+			"Entry": { sequence: [
+				{ name: "tag", type: "uint16" },
+				{ name: "value", type: "uint32" },
+			] },
+			"Tiff": { sequence: [
+				{ name: "byte_order", type: "uint16", selects_endianness: { little_endian: 0x4949, big_endian: 0x4d4d } },
+				{ name: "magic", type: "uint16" },
+				{ name: "entry", type: "Entry" },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Tiff")
+	require.NoError(t, err)
+
+	output := runGenerated(t, code, `
+	for _, input := range [][]byte{
+		{'I', 'I', 42, 0, 1, 0, 2, 0, 0, 0},
+		{'M', 'M', 0, 42, 0, 1, 0, 0, 0, 2},
+	} {
+		tiff, err := DecodeTiff(input)
+		if err != nil {
+			panic(err)
+		}
+		encoded, err := tiff.Encode()
+		fmt.Println(tiff, string(encoded) == string(input), err)
+	}
+	_, err := DecodeTiff([]byte{0, 0, 42, 0})
+	fmt.Println(err)
+
+	// The byte order can also come from the caller
+	entry, err := DecodeEntryWithEndianness([]byte{1, 0, 2, 0, 0, 0}, runtime.LittleEndian)
+	fmt.Println(entry, err)
+	fmt.Println(entry.EncodeWithEndianness(runtime.LittleEndian))
+	fmt.Println(entry.Encode())
+`)
+	require.Equal(t, `Tiff{byte_order: 18761, magic: 42, entry: Entry{tag: 1, value: 2}} true <nil>
+Tiff{byte_order: 19789, magic: 42, entry: Entry{tag: 1, value: 2}} true <nil>
+byte_order: 0x0 selects no byte order
+Entry{tag: 1, value: 2} <nil>
+[1 0 2 0 0 0] <nil>
+[0 1 0 0 0 2] <nil>
+`, output)
+}
+
+func TestGenerateBitOrder(t *testing.T) {
+	// Vectors from tests-json spanning_bytes_lsb and bit_order_comparison; Register keeps
+	// MSB-first packing inside an LSB-first schema
+	schema := parseTestSchema(t, `{
+		config: { bit_order: "lsb_first" },
+		types: {
+			"Register": {
+				bit_order: "msb_first",
+				sequence: [
+					{ name: "a", type: "bit", size: 2 },
+					{ name: "b", type: "bit", size: 3 },
+					{ name: "c", type: "bit", size: 3 },
+				],
+			},
+			"Packet": { sequence: [
+				{ name: "flag", type: "bit", size: 1 },
+				{ name: "value", type: "bit", size: 8 },
+				{ name: "pad", type: "bit", size: 7 },
+				{ name: "register", type: "Register" },
+				{ name: "tail", type: "bit", size: 2 },
+				{ name: "rest", type: "bit", size: 6 },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Packet")
+	require.NoError(t, err)
+	require.Contains(t, code, "runtime.NewBitStreamDecoder(bytes, runtime.LSBFirst)")
+
+	output := runGenerated(t, code, `
+	input := []byte{133, 0, 213, 1}
+	packet, err := DecodePacket(input)
+	if err != nil {
+		panic(err)
+	}
+	encoded, err := packet.Encode()
+	fmt.Println(packet, encoded, err)
+	fmt.Println(DecodeRegister([]byte{213}))
+`)
+	require.Equal(t, `Packet{flag: 1, value: 66, pad: 0, register: Register{a: 3, b: 2, c: 5}, tail: 1, rest: 0} [133 0 213 1] <nil>
+Register{a: 3, b: 2, c: 5} <nil>
+`, output)
+}
+
+func TestGenerateSignedBits(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Sample": { sequence: [
+				{ name: "x", type: "int", size: 12, signed: true },
+				{ name: "y", type: "bit", size: 4, signed: true },
+				{ name: "flags", type: "bitfield", size: 8, fields: [
+					{ name: "delta", offset: 0, size: 3, signed: true },
+					{ name: "mode", offset: 3, size: 5 },
+				] },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Sample")
+	require.NoError(t, err)
+	require.Contains(t, code, "X     int16")
+	require.Contains(t, code, "Delta int8")
+
+	output := runGenerated(t, code, `
+	input := []byte{0x80, 0x07, 0xa3}
+	sample, err := DecodeSample(input)
+	if err != nil {
+		panic(err)
+	}
+	encoded, err := sample.Encode()
+	fmt.Println(sample, encoded, err)
+	_, err = (&Sample{X: 2048}).Encode()
+	fmt.Println(err)
+	_, err = (&Sample{Y: -9}).Encode()
+	fmt.Println(err)
+`)
+	require.Equal(t, `Sample{x: -2048, y: 7, flags: Sample_Flags{delta: -3, mode: 3}} [128 7 163] <nil>
+x: 2048 doesn't fit in 12 signed bits
+y: -9 doesn't fit in 4 signed bits
+`, output)
+}
+
+func TestGenerate128BitIntegers(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Counter": { sequence: [
+				{ name: "id", type: "uint128" },
+				{ name: "delta", type: "int128", endianness: "little_endian" },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Counter")
+	require.NoError(t, err)
+	require.Contains(t, code, "Id    runtime.Uint128")
+	require.Contains(t, code, "Delta runtime.Int128")
+
+	output := runGenerated(t, code, `
+	input := []byte{
+		0x01, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x02,
+		0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	}
+	counter, err := DecodeCounter(input)
+	if err != nil {
+		panic(err)
+	}
+	encoded, err := counter.Encode()
+	fmt.Println(counter, fmt.Sprint(encoded) == fmt.Sprint(input), err)
+	data, err := json.Marshal(counter)
+	fmt.Println(string(data), err)
+	var back Counter
+	err = json.Unmarshal([]byte(`+"`"+`{"id": "340282366920938463463374607431768211455", "delta": -170141183460469231731687303715884105728}`+"`"+`), &back)
+	fmt.Println(back.Id.Hi, back.Id.Lo, back.Delta.Hi, back.Delta.Lo, err)
+`)
+	require.Equal(t, `Counter{id: 1329227995784915872903807060280344578, delta: -2} true <nil>
+{"id":1329227995784915872903807060280344578,"delta":-2} <nil>
+18446744073709551615 18446744073709551615 9223372036854775808 0 <nil>
+`, output)
+}
+
+func TestGenerateAddressTypes(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Lease": { sequence: [
+				{ name: "client", type: "uuid" },
+				{ name: "hardware", type: "mac" },
+				{ name: "address", type: "ipv4" },
+				{ name: "gateway", type: "ipv6" },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Lease")
+	require.NoError(t, err)
+	require.Contains(t, code, `"net/netip"`)
+	require.Contains(t, code, "Address  netip.Addr")
+
+	output := runGenerated(t, code, `
+	input := []byte{
+		0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00,
+		0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e,
+		192, 168, 1, 20,
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+	}
+	lease, err := DecodeLease(input)
+	if err != nil {
+		panic(err)
+	}
+	encoded, err := lease.Encode()
+	fmt.Println(lease, fmt.Sprint(encoded) == fmt.Sprint(input), err)
+	data, err := json.Marshal(lease)
+	fmt.Println(string(data), err)
+	var back Lease
+	fmt.Println(json.Unmarshal(data, &back), back == *lease)
+	back.Address = back.Gateway
+	_, err = back.Encode()
+	fmt.Println(err)
+`)
+	require.Equal(t, `Lease{client: 123e4567-e89b-12d3-a456-426614174000, hardware: 00:1a:2b:3c:4d:5e, address: 192.168.1.20, gateway: 2001:db8::1} true <nil>
+{"client":"123e4567-e89b-12d3-a456-426614174000","hardware":"00:1a:2b:3c:4d:5e","address":"192.168.1.20","gateway":"2001:db8::1"} <nil>
+<nil> true
+address: 2001:db8::1 is not an IPv4 address
+`, output)
+}
+
+func TestGenerateTimestamps(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Reading": { sequence: [
+				{ name: "taken", type: "unix32" },
+				{ name: "logged", type: "unix_milli" },
+				{ name: "synced", type: "ntp" },
+				{ name: "uptime", type: "unix64", unit: "us", epoch: "2000-01-01T00:00:00Z" },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Reading")
+	require.NoError(t, err)
+	require.Contains(t, code, `"time"`)
+	require.Contains(t, code, "Taken  time.Time")
+	require.Contains(t, code, "time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)")
+
+	output := runGenerated(t, code, `
+	input := []byte{
+		0x65, 0x92, 0x00, 0x80,
+		0, 0, 0x01, 0x8c, 0xc2, 0x51, 0xf4, 0x7b,
+		0xe9, 0x3c, 0x7f, 0x00, 0x80, 0, 0, 0,
+		0, 0, 0, 0, 0, 0x0f, 0x42, 0x40,
+	}
+	reading, err := DecodeReading(input)
+	if err != nil {
+		panic(err)
+	}
+	encoded, err := reading.Encode()
+	fmt.Println(fmt.Sprint(encoded) == fmt.Sprint(input), err)
+	data, err := json.Marshal(reading)
+	fmt.Println(string(data), err)
+	reading.Taken = reading.Taken.AddDate(-100, 0, 0)
+	_, err = reading.Encode()
+	fmt.Println(err)
+`)
+	require.Equal(t, `true <nil>
+{"taken":"2024-01-01T00:00:00Z","logged":"2024-01-01T00:00:00.123Z","synced":"2024-01-01T00:00:00.5Z","uptime":"2000-01-01T00:00:01Z"} <nil>
+taken: 1924-01-01 00:00:00 +0000 UTC is outside the range of unix32
+`, output)
+}
+
+// Package names in descriptions, or used as local variables, don't import the package
+func TestGenerateImportsOnlyUsedPackages(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Sample": {
+				description: "One reading at a time. Values are fmt.Sprint-able.",
+				sequence: [{ name: "time", type: "uint32", description: "Uptime, not a time." }],
+			},
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Sample")
+	require.NoError(t, err)
+	require.NotContains(t, code, "\t\"time\"\n")
+	output := runGenerated(t, code, `
+	sample, err := DecodeSample([]byte{0, 0, 0, 7})
+	fmt.Println(sample, err)
+`)
+	require.Equal(t, "Sample{time: 7} <nil>\n", output)
+}
+
+func TestGenerateDecodeInto(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Entry": { sequence: [
+				{ name: "id", type: "uint8" },
+				{ name: "data", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "uint8" } },
+			] },
+			"Packet": { sequence: [
+				{ name: "flags", type: "uint8" },
+				{ name: "extra", type: "uint8", conditional: "flags == 1" },
+				{ name: "first", type: "Entry" },
+				{ name: "entries", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "Entry" } },
+				{ name: "trailer", type: "array", kind: "null_terminated", items: { type: "Entry" } },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGoWithOptions(schema, "Packet", GenerateOptions{DecodeInto: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "func DecodePacketInto(bytes []byte, out *Packet) error {")
+
+	output := runGenerated(t, code, `
+	var packet Packet
+	big := []byte{1, 9, 5, 2, 1, 2, 2, 3, 3, 7, 8, 9, 7, 1, 1, 8, 0, 0}
+	if err := DecodePacketInto(big, &packet); err != nil {
+		panic(err)
+	}
+	fmt.Println(packet.String())
+	entries, data := &packet.Entries[0], &packet.Entries[0].Data[0]
+
+	small := []byte{0, 5, 0, 1, 6, 1, 4, 8, 0, 0}
+	if err := DecodePacketInto(small, &packet); err != nil {
+		panic(err)
+	}
+	fresh, err := DecodePacket(small)
+	fmt.Println(packet.String(), fresh.String() == packet.String(), err)
+	fmt.Println(entries == &packet.Entries[0], data == &packet.Entries[0].Data[0], cap(packet.Entries))
+
+	if err := DecodePacketInto([]byte{0, 5}, &packet); err != nil {
+		fmt.Println(err)
+	}
+`)
+	require.Equal(t, `Packet{flags: 1, extra: 9, first: Entry{id: 5, data: [1 2]}, entries: [Entry{id: 3, data: [7 8 9]}, Entry{id: 7, data: [1]}], trailer: [Entry{id: 8, data: []}]}
+Packet{flags: 0, extra: 0, first: Entry{id: 5, data: []}, entries: [Entry{id: 6, data: [4]}], trailer: [Entry{id: 8, data: []}]} true <nil>
+true true 2
+unexpected end of stream
+`, output)
+}
+
+func TestGenerateDecodeWithArena(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Entry": { sequence: [
+				{ name: "id", type: "uint8" },
+				{ name: "data", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "uint8" } },
+			] },
+			"Packet": { sequence: [
+				{ name: "entries", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "Entry" } },
+				{ name: "trailer", type: "array", kind: "null_terminated", items: { type: "Entry" } },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGoWithOptions(schema, "Packet", GenerateOptions{DecodeWithArena: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "func DecodePacketWithArena(bytes []byte, arena *runtime.DecodeArena) (*Packet, error) {")
+
+	output := runGenerated(t, code, `
+	arena := runtime.AcquireDecodeArena()
+	input := []byte{2, 1, 2, 7, 8, 3, 0, 5, 1, 9, 6, 0, 0}
+	first, err := DecodePacketWithArena(input, arena)
+	if err != nil {
+		panic(err)
+	}
+	second, err := DecodePacketWithArena([]byte{0, 4, 1, 1, 0}, arena)
+	fmt.Println(first.String())
+	fmt.Println(second.String(), err)
+
+	arena.Reset()
+	again, err := DecodePacketWithArena(input, arena)
+	fresh, _ := DecodePacket(input)
+	fmt.Println(again == first, again.String() == fresh.String(), err)
+	runtime.ReleaseDecodeArena(arena)
+`)
+	require.Equal(t, `Packet{entries: [Entry{id: 1, data: [7 8]}, Entry{id: 3, data: []}], trailer: [Entry{id: 5, data: [9]}, Entry{id: 6, data: []}]}
+Packet{entries: [], trailer: [Entry{id: 4, data: [1]}]} <nil>
+true true <nil>
+`, output)
+}
+
+func TestGenerateZeroCopy(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Label": { sequence: [
+				{ name: "text", type: "string", kind: "length_prefixed", length_type: "uint8" },
+			] },
+			"Query": { sequence: [
+				{ name: "id", type: "uint8" },
+				{ name: "host", type: "string", kind: "null_terminated" },
+				{ name: "code", type: "string", kind: "fixed", length: 4, encoding: "ascii" },
+				{ name: "labels", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "Label" } },
+				{ name: "tags", type: "array", kind: "fixed", length: 2, items: { type: "string", kind: "null_terminated" } },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGoWithOptions(schema, "Query", GenerateOptions{ZeroCopy: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "Host   runtime.ByteString")
+	require.Contains(t, code, "Tags   []runtime.ByteString")
+	require.Contains(t, code, "func (m *Query) Clone() *Query {")
+	require.NotContains(t, code, "decoder.ReadUint8()\n\t\tif err != nil {\n\t\t\treturn nil, err\n\t\t}\n\t\tif b == 0")
+
+	output := runGenerated(t, code, `
+	input := []byte{7, 'a', '.', 'b', 0, 'U', 'S', 0, 0, 1, 2, 'h', 'i', 'x', 0, 'y', 0}
+	query, err := DecodeQuery(input)
+	if err != nil {
+		panic(err)
+	}
+	kept := query.Clone()
+	encoded, err := query.Encode()
+	fmt.Println(query, fmt.Sprint(encoded) == fmt.Sprint(input), err)
+	input[1], input[11], input[13] = 'A', 'H', 'X'
+	fmt.Println(query)
+	fmt.Println(kept)
+	data, err := json.Marshal(kept)
+	fmt.Println(string(data), err)
+	var back Query
+	err = json.Unmarshal(data, &back)
+	fmt.Println(back.Host.String(), back.Tags[1].String(), err)
+`)
+	require.Equal(t, `Query{id: 7, host: "a.b", code: "US", labels: [Label{text: "hi"}], tags: ["x", "y"]} true <nil>
+Query{id: 7, host: "A.b", code: "US", labels: [Label{text: "Hi"}], tags: ["X", "y"]}
+Query{id: 7, host: "a.b", code: "US", labels: [Label{text: "hi"}], tags: ["x", "y"]}
+{"id":7,"host":"a.b","code":"US","labels":[{"text":"hi"}],"tags":["x","y"]} <nil>
+a.b y <nil>
+`, output)
+}
+
+func TestGenerateFlags(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"TcpFlags": { type: "flags", repr: "uint8", variants: { fin: 0x01, syn: 0x02, rst: 0x04, ack: 0x10 } },
+			"Segment": { sequence: [
+				{ name: "flags", type: "TcpFlags" },
+				{ name: "options", type: "flags", repr: "uint16", variants: { mss: 0x0100, window_scale: 0x0200 } },
+				{ name: "history", type: "array", kind: "fixed", length: 2, items: { type: "TcpFlags" } },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Segment")
+	require.NoError(t, err)
+	require.Contains(t, code, "type TcpFlags uint8")
+	require.Contains(t, code, "TcpFlagsSyn TcpFlags = 0x2")
+	require.Contains(t, code, "type Segment_Options uint16")
+	require.Contains(t, code, "Segment_OptionsWindow_scale Segment_Options = 0x200")
+	require.Contains(t, code, "Flags   TcpFlags")
+
+	output := runGenerated(t, code, `
+	input := []byte{0x12, 0x03, 0x01, 0x02, 0x21}
+	segment, err := DecodeSegment(input)
+	if err != nil {
+		panic(err)
+	}
+	encoded, err := segment.Encode()
+	fmt.Println(segment.Flags, segment.Flags.Has(TcpFlagsSyn|TcpFlagsAck), segment.Flags.Has(TcpFlagsFin), fmt.Sprint(encoded) == fmt.Sprint(input), err)
+	fmt.Println(segment.Options, segment.History[0], segment.History[1], TcpFlags(0))
+	segment.Flags.Set(TcpFlagsFin)
+	segment.Flags.Clear(TcpFlagsSyn)
+	encoded, err = segment.Encode()
+	fmt.Println(segment.Flags, encoded[0], err)
+`)
+	require.Equal(t, `syn|ack true false true <nil>
+mss|window_scale|0x1 syn fin|0x20 0
+fin|ack 17 <nil>
+`, output)
+}
+
+func TestGenerateBCD(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Charge": { sequence: [
+				{ name: "msisdn", type: "packed_bcd", digits: 11, decode_as: "string" },
+				{ name: "amount", type: "packed_bcd", digits: 6 },
+				{ name: "code", type: "bcd", digits: 3 },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Charge")
+	require.NoError(t, err)
+	require.Contains(t, code, "Msisdn string")
+	require.Contains(t, code, "Amount int64")
+
+	output := runGenerated(t, code, `
+	input := []byte{0x04, 0x47, 0x91, 0x23, 0x45, 0x67, 0x00, 0x12, 0x50, 0x00, 0x04, 0x02}
+	charge, err := DecodeCharge(input)
+	if err != nil {
+		panic(err)
+	}
+	encoded, err := charge.Encode()
+	fmt.Println(charge, fmt.Sprint(encoded) == fmt.Sprint(input), err)
+	_, err = (&Charge{Msisdn: "447912345678", Amount: 1}).Encode()
+	fmt.Println(err)
+	_, err = (&Charge{Msisdn: "44791234567", Amount: 1000000}).Encode()
+	fmt.Println(err)
+	_, err = DecodeCharge([]byte{0x04, 0x47, 0x91, 0x23, 0x45, 0x67, 0x00, 0x1a, 0x50, 0x00, 0x04, 0x02})
+	fmt.Println(err)
+`)
+	require.Equal(t, `Charge{msisdn: "44791234567", amount: 1250, code: 42} true <nil>
+msisdn: "447912345678" is not 11 decimal digits
+amount: 1000000 doesn't fit in 6 decimal digits
+byte 0x1a is not two decimal digits
+`, output)
+}
+
+func TestGenerateInlineFastPath(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Reading": { sequence: [
+				{ name: "a", type: "uint16", endianness: "little_endian" },
+				{ name: "b", type: "int8" },
+				{ name: "c", type: "float32" },
+				{ name: "d", type: "flags", repr: "uint8", variants: { x: 1, y: 2 } },
+				{ name: "name", type: "string", kind: "length_prefixed", length_type: "uint8" },
+			] },
+			"Packet": { sequence: [
+				{ name: "lead", type: "bit", size: 4 },
+				{ name: "reading", type: "Reading" },
+				{ name: "tail", type: "bit", size: 4 },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Packet")
+	require.NoError(t, err)
+	require.Contains(t, code, "if span, ok := decoder.PeekAligned(8); ok && !traceEnabled {")
+	require.Contains(t, code, "result.A = binary.LittleEndian.Uint16(span[0:])")
+	require.Contains(t, code, "result.C = math.Float32frombits(binary.BigEndian.Uint32(span[3:]))")
+	require.Contains(t, code, "result.D = Reading_D(span[7])")
+	require.NotContains(t, code, "decodeReadingFrom")
+
+	// Packet reads Reading unaligned, through the bitstream fallback
+	output := runGenerated(t, code, `
+	input := []byte{1, 2, 0xfe, 0x3f, 0xc0, 0, 0, 3, 2, 'h', 'i'}
+	reading, err := DecodeReading(input)
+	fmt.Println(reading, err)
+	packet := &Packet{Lead: 10, Reading: *reading, Tail: 5}
+	encoded, _ := packet.Encode()
+	decoded, err := DecodePacket(encoded)
+	fmt.Println(decoded, err)
+	_, err = DecodeReading(input[:6])
+	fmt.Println(err)
+`)
+	require.Equal(t, `Reading{a: 513, b: -2, c: 1.5, d: x|y, name: "hi"} <nil>
+Packet{lead: 10, reading: Reading{a: 513, b: -2, c: 1.5, d: x|y, name: "hi"}, tail: 5} <nil>
+unexpected end of stream
+`, output)
+}
+
+func TestGenerateFixedSizeTypes(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Vec": { sequence: [
+				{ name: "x", type: "int16" },
+				{ name: "y", type: "int16" },
+			] },
+			"Sample": { sequence: [
+				{ name: "id", type: "uint8" },
+				{ name: "pos", type: "Vec" },
+				{ name: "vel", type: "Vec" },
+				{ name: "time", type: "uint32", endianness: "little_endian" },
+			] },
+			"Frame": { sequence: [
+				{ name: "lead", type: "bit", size: 4 },
+				{ name: "sample", type: "Sample" },
+				{ name: "tail", type: "bit", size: 4 },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Frame")
+	require.NoError(t, err)
+	require.Contains(t, code, "if span, ok := decoder.PeekAligned(13); ok && !traceEnabled {\n\t\tdecodeSampleFrom(span, result)")
+	require.Contains(t, code, "func decodeSampleFrom(span []byte, result *Sample) {\n\t_ = span[12]")
+	require.Contains(t, code, "decodeVecFrom(span[5:], &result.Vel)")
+	require.NotContains(t, code, "decodeFrameFrom")
+
+	// Frame reads Sample unaligned, through the bitstream fallback
+	output := runGenerated(t, code, `
+	input := []byte{7, 0, 1, 0xff, 0xfe, 0, 3, 0, 4, 0x10, 0x20, 0, 0}
+	sample, err := DecodeSample(input)
+	fmt.Println(sample, err)
+	frame := &Frame{Lead: 10, Sample: *sample, Tail: 5}
+	encoded, _ := frame.Encode()
+	decoded, err := DecodeFrame(encoded)
+	fmt.Println(decoded.Sample == *sample, err)
+	_, err = DecodeSample(input[:12])
+	fmt.Println(err)
+`)
+	require.Equal(t, `Sample{id: 7, pos: Vec{x: 1, y: -2}, vel: Vec{x: 3, y: 4}, time: 8208} <nil>
+true <nil>
+unexpected end of stream
+`, output)
+}
+
+func TestGenerateBitGroups(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Header": { sequence: [
+				{ name: "version", type: "bit", size: 3 },
+				{ name: "delta", type: "int", size: 5, signed: true },
+				{ name: "wide", type: "bitfield", size: 64, fields: [
+					{ name: "hi", type: "bit", size: 40 },
+					{ name: "mid", type: "bit", size: 20 },
+					{ name: "lo", type: "bit", size: 4 },
+				] },
+			] },
+			"Register": { bit_order: "lsb_first", sequence: [
+				{ name: "enable", type: "bit", size: 1 },
+				{ name: "mode", type: "bit", size: 3 },
+				{ name: "offset", type: "int", size: 12, signed: true },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Header")
+	require.NoError(t, err)
+	require.Contains(t, code, "bits, err := decoder.ReadBits(8)")
+	require.Contains(t, code, "result.Delta = int8(int64(bits<<59) >> 59)")
+	require.Contains(t, code, "wide_bits, err := decoder.ReadBits(40)")
+	require.Contains(t, code, "wide_bits2, err := decoder.ReadBits(24)")
+	require.Contains(t, code, "result.Offset = int16(int64(bits<<48) >> 52)")
+
+	output := runGenerated(t, code, `
+	header := &Header{Version: 5, Delta: -3, Wide: Header_Wide{Hi: 0x123456789a, Mid: 0xabcde, Lo: 7}}
+	encoded, _ := header.Encode()
+	decoded, err := DecodeHeader(encoded)
+	fmt.Println(encoded, *decoded == *header, err)
+	register := &Register{Enable: 1, Mode: 6, Offset: -1000}
+	encoded, _ = register.Encode()
+	decodedRegister, err := DecodeRegister(encoded)
+	fmt.Println(encoded, decodedRegister, err)
+	_, err = DecodeRegister(encoded[:1])
+	fmt.Println(err)
+`)
+	require.Equal(t, `[189 18 52 86 120 154 171 205 231] true <nil>
+[141 193] Register{enable: 1, mode: 6, offset: -1000} <nil>
+unexpected end of stream
+`, output)
+}
+
+func TestGenerateProtobuf(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Test": { protobuf: true, sequence: [
+				{ name: "a", type: "int32", field_number: 1 },
+				{ name: "b", type: "string", field_number: 2 },
+				{ name: "c", type: "Inner", field_number: 3 },
+				{ name: "d", type: "array", items: { type: "int32" }, field_number: 4 },
+				{ name: "e", type: "int32", proto_type: "sint32", field_number: 5 },
+				{ name: "data", type: "array", items: { type: "uint8" }, field_number: 6 },
+				{ name: "tags", type: "array", items: { type: "string" }, field_number: 7 },
+			] },
+			"Inner": { protobuf: true, sequence: [
+				{ name: "x", type: "uint64", field_number: 1 },
+				{ name: "ratio", type: "float64", field_number: 2 },
+			] },
+			"Frame": { sequence: [
+				{ name: "magic", type: "uint16" },
+				{ name: "payload", type: "Inner", kind: "length_prefixed", length_type: "uint16" },
+				{ name: "crc", type: "uint8" },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Test")
+	require.NoError(t, err)
+	require.Contains(t, code, "fieldNumber, wireType, err := decoder.ReadProtobufTag()")
+	require.Contains(t, code, "decoder.SkipProtobufField(wireType)")
+
+	output := runGenerated(t, code, `
+	// The examples of the protobuf encoding guide
+	encoded, err := (&Test{A: 150}).Encode()
+	fmt.Printf("% x %v\n", encoded, err)
+	encoded, err = (&Test{B: "testing"}).Encode()
+	fmt.Printf("% x %v\n", encoded, err)
+	encoded, err = (&Test{C: Inner{X: 150}}).Encode()
+	fmt.Printf("% x %v\n", encoded, err)
+	encoded, err = (&Test{D: []int32{3, 270, 86942}, E: -2}).Encode()
+	fmt.Printf("% x %v\n", encoded, err)
+
+	test := &Test{A: -1, B: "hi", C: Inner{X: 1 << 40, Ratio: 0.5}, D: []int32{-5, 7}, E: -300, Data: []byte{1, 2}, Tags: []string{"x", "", "z"}}
+	encoded, _ = test.Encode()
+	decoded, err := DecodeTest(encoded)
+	fmt.Println(decoded.A, decoded.B, decoded.C.X, decoded.C.Ratio, decoded.D, decoded.E, decoded.Data, decoded.Tags, err)
+
+	// Unpacked repeated values, fields out of order and an unknown field 31
+	decoded, err = DecodeTest([]byte{0x20, 0x03, 0x08, 0x07, 0xf8, 0x01, 0x05, 0x20, 0x04})
+	fmt.Println(decoded.A, decoded.D, err)
+	_, err = DecodeTest([]byte{0x0a, 0x01, 0x00})
+	fmt.Println(err)
+
+	frame := &Frame{Magic: 0xcafe, Payload: Inner{X: 3}, Crc: 9}
+	encoded, _ = frame.Encode()
+	decodedFrame, err := DecodeFrame(encoded)
+	fmt.Printf("% x %v %v %v\n", encoded, decodedFrame.Payload.X, decodedFrame.Crc, err)
+`)
+	require.Equal(t, `08 96 01 <nil>
+12 07 74 65 73 74 69 6e 67 <nil>
+1a 03 08 96 01 <nil>
+22 06 03 8e 02 9e a7 05 28 03 <nil>
+-1 hi 1099511627776 0.5 [-5 7] -300 [1 2] [x  z] <nil>
+7 [3 4] <nil>
+protobuf field 1 has wire type 2, expected 0
+ca fe 00 02 08 03 09 3 9 <nil>
+`, output)
+}
+
+func TestGenerateCBORAndMessagePack(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Reading": { sequence: [
+				{ name: "id", type: "uint8" },
+				{ name: "delta", type: "int16" },
+				{ name: "name", type: "string", kind: "fixed", length: 2 },
+				{ name: "raw", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "uint8" } },
+				{ name: "at", type: "unix32" },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGoWithOptions(schema, "Reading", GenerateOptions{SelfDescribing: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "func (m *Reading) MarshalCBOR() ([]byte, error)")
+	require.Contains(t, code, "func (m *Reading) MarshalMessagePack() ([]byte, error)")
+
+	output := runGenerated(t, code, `
+	reading, err := DecodeReading([]byte{1, 0xff, 0xfe, 'a', 'b', 1, 0xff, 0x65, 0x53, 0xf1, 0x00})
+	if err != nil {
+		panic(err)
+	}
+	encoded, err := reading.MarshalCBOR()
+	fmt.Printf("% x %v\n", encoded, err)
+	encoded, err = reading.MarshalMessagePack()
+	fmt.Printf("% x %v\n", encoded, err)
+`)
+	require.Equal(t, `a5 62 69 64 01 65 64 65 6c 74 61 21 64 6e 61 6d 65 62 61 62 63 72 61 77 41 ff 62 61 74 c1 1a 65 53 f1 00 <nil>
+85 a2 69 64 01 a5 64 65 6c 74 61 fe a4 6e 61 6d 65 a2 61 62 a3 72 61 77 c4 01 ff a2 61 74 d6 ff 65 53 f1 00 <nil>
+`, output)
+}
+
+// trickleConn is an io.ReadWriter for generated programs: writes are buffered, and reads
+// return at most chunk bytes, as a slow network connection would
+const trickleConn = `package main
+
+import "io"
+
+type trickleConn struct {
+	data  []byte
+	chunk int
+}
+
+func (c *trickleConn) Write(p []byte) (int, error) {
+	c.data = append(c.data, p...)
+	return len(p), nil
+}
+
+func (c *trickleConn) Read(p []byte) (int, error) {
+	if len(c.data) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p[:min(len(p), c.chunk)], c.data)
+	c.data = c.data[n:]
+	return n, nil
+}
+`
+
+func TestGenerateMessageStream(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Reading": { sequence: [
+				{ name: "id", type: "uint16" },
+				{ name: "samples", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "uint8" } },
+			] },
+		},
+	}`)
+	code, err := GenerateGo(schema, "Reading")
+	require.NoError(t, err)
+
+	output := runGeneratedFiles(t, "", map[string]string{"generated.go": code, "conn.go": trickleConn}, `
+	for _, prefix := range []runtime.LengthPrefix{runtime.PrefixUint16, runtime.PrefixVarint} {
+		conn := &trickleConn{chunk: 3}
+		stream := runtime.NewMessageStream(conn, prefix, runtime.LittleEndian)
+		for i := 0; i < 3; i++ {
+			samples := make([]uint8, 100*i)
+			if err := stream.WriteMessage(&Reading{Id: uint16(i), Samples: samples}); err != nil {
+				panic(err)
+			}
+		}
+		fmt.Printf("% x\n", conn.data[:5])
+		for {
+			reading, err := runtime.ReadMessage(stream, DecodeReading)
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			fmt.Println(reading.Id, len(reading.Samples))
+		}
+	}
+
+	// A frame cut short, and one longer than the prefix or the limit allows
+	conn := &trickleConn{chunk: 1}
+	stream := runtime.NewMessageStream(conn, runtime.PrefixUint8, runtime.BigEndian)
+	stream.WriteFrame([]byte{1, 2, 3})
+	conn.data = conn.data[:3]
+	_, err := stream.ReadFrame()
+	fmt.Println(err)
+	fmt.Println(stream.WriteFrame(make([]byte, 256)))
+	stream.MaxFrameSize = 2
+	stream.WriteFrame([]byte{1, 2, 3})
+	_, err = stream.ReadFrame()
+	fmt.Println(err)
+`)
+	require.Equal(t, `03 00 00 00 00
+0 0
+1 100
+2 200
+EOF
+03 00 00 00 67
+0 0
+1 100
+2 200
+EOF
+unexpected EOF
+message frame too large: 256 bytes
+message frame too large: 3 bytes
+`, output)
+}
+
+func TestGenerateDecodeStream(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Reading": { sequence: [
+				{ name: "id", type: "uint16" },
+				{ name: "samples", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "uint8" } },
+			] },
+		},
+	}`)
+	code, err := GenerateGoWithOptions(schema, "Reading", GenerateOptions{DecodeStream: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "func DecodeReadingStream() *runtime.StatefulDecoder[*Reading]")
+
+	output := runGenerated(t, code, `
+	// Two messages, the second split inside its id and inside its samples
+	stream := DecodeReadingStream()
+	for _, chunk := range [][]byte{{0, 1, 2, 10, 20, 0}, {2, 3}, {30, 40}, {50}} {
+		stream.Feed(chunk)
+		for {
+			reading, err := stream.Next()
+			if err != nil {
+				fmt.Println(err, stream.Buffered())
+				break
+			}
+			fmt.Println(reading.Id, reading.Samples)
+		}
+	}
+`)
+	require.Equal(t, `1 [10 20]
+need more data 1
+need more data 3
+need more data 5
+2 [30 40 50]
+need more data 0
+`, output)
+}
+
+// countdownContext is a context for generated programs that is done after n checks
+const countdownContext = `package main
+
+import "context"
+
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func newCountdownContext(n int) *countdownContext {
+	return &countdownContext{context.Background(), n}
+}
+
+func (c *countdownContext) Err() error {
+	if c.n == 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+`
+
+func TestGenerateDecodeContext(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Reading": { sequence: [
+				{ name: "count", type: "uint16" },
+				{ name: "samples", type: "array", kind: "field_referenced", length_field: "count", items: { type: "uint16" } },
+			] },
+		},
+	}`)
+	code, err := GenerateGoWithOptions(schema, "Reading", GenerateOptions{DecodeContext: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "func DecodeReadingContext(ctx context.Context, bytes []byte) (*Reading, error)")
+
+	output := runGeneratedFiles(t, "", map[string]string{"generated.go": code, "context.go": countdownContext}, `
+	data := make([]byte, 2+2*3000)
+	data[0], data[1] = 0x0b, 0xb8
+	for _, checks := range []int{0, 1, 2, 3, 100} {
+		reading, err := DecodeReadingContext(newCountdownContext(checks), data)
+		if err != nil {
+			fmt.Println(checks, err)
+			continue
+		}
+		fmt.Println(checks, len(reading.Samples))
+	}
+`)
+	// One check before decoding, then one every 1024 items
+	require.Equal(t, `0 context canceled
+1 context canceled
+2 context canceled
+3 3000
+100 3000
+`, output)
+}
+
+func TestGenerateEncodeSharedContext(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Item": { sequence: [
+				{ name: "value", type: "uint16" },
+				{ name: "extra", type: "uint8", conditional: "../flags == 1" },
+			] },
+			"Batch": { sequence: [
+				{ name: "flags", type: "uint8" },
+				{ name: "count", type: "uint8" },
+				{ name: "items", type: "array", kind: "field_referenced", length_field: "count", items: { type: "Item" } },
+			] },
+		},
+	}`)
+	code, err := GenerateGo(schema, "Batch")
+	require.NoError(t, err)
+
+	// Goroutines share one Synchronized context, recording into its dictionary as they go
+	output := runGenerated(t, code, `
+	shared := runtime.NewEncodingContext(runtime.Synchronized())
+	batch := &Batch{Flags: 1, Count: 2, Items: []Item{{Value: 1, Extra: 2}, {Value: 3, Extra: 4}}}
+	results := make(chan string)
+	for g := 0; g < 8; g++ {
+		go func(g int) {
+			var last []byte
+			for i := 0; i < 100; i++ {
+				encoded, err := batch.EncodeWithContext(shared)
+				if err != nil {
+					panic(err)
+				}
+				shared.SetCompressionOffset(fmt.Sprint(g, i), i)
+				last = encoded
+			}
+			results <- fmt.Sprint(last)
+		}(g)
+	}
+	for g := 0; g < 8; g++ {
+		fmt.Println(<-results)
+	}
+	fmt.Println(len(shared.CompressionDict))
+
+	// A fork starts with a copy of the dictionary and keeps its own
+	forked := shared.Fork()
+	forked.SetCompressionOffset("new", 1)
+	_, inShared := shared.GetCompressionOffset("new")
+	offset, inForked := forked.GetCompressionOffset("7 99")
+	fmt.Println(inShared, offset, inForked)
+`)
+	require.Equal(t, strings.Repeat("[1 2 0 1 2 0 3 4]\n", 8)+"800\nfalse 99 true\n", output)
+}
+
+func TestGenerateBinaryCodecs(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Reading": { sequence: [
+				{ name: "sensor", type: "uint8" },
+				{ name: "samples", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "uint16" } },
+			] },
+		},
+	}`)
+	code, err := GenerateGo(schema, "Reading")
+	require.NoError(t, err)
+	require.NotContains(t, code, "MarshalBinary")
+
+	code, err = GenerateGoWithOptions(schema, "Reading", GenerateOptions{BinaryCodecs: true})
+	require.NoError(t, err)
+
+	// codec stands in for gRPC's encoding.Codec
+	output := runGeneratedFiles(t, "", map[string]string{"generated.go": code, "codec.go": `package main
+
+import "encoding"
+
+type codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+	Name() string
+}
+
+var (
+	_ encoding.BinaryMarshaler   = (*Reading)(nil)
+	_ encoding.BinaryUnmarshaler = (*Reading)(nil)
+)
+`}, `
+	var c codec = runtime.Codec{}
+	data, err := c.Marshal(&Reading{Sensor: 3, Samples: []uint16{1, 2}})
+	fmt.Println(c.Name(), data, err)
+	var reading Reading
+	fmt.Println(c.Unmarshal(data, &reading), &reading)
+	fmt.Println(reading.UnmarshalBinary([]byte{3, 2, 0}))
+	_, err = c.Marshal("not a message")
+	fmt.Println(err)
+`)
+	require.Equal(t, `binschema [3 2 0 1 0 2] <nil>
+<nil> Reading{sensor: 3, samples: [1 2]}
+unexpected end of stream
+binschema codec: string is not a generated message
+`, output)
+}
+
+func TestGenerateSQLMethods(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Reading": { sequence: [
+				{ name: "sensor", type: "uint8" },
+				{ name: "name", type: "string", kind: "length_prefixed", length_type: "uint8", encoding: "utf8" },
+			] },
+		},
+	}`)
+	code, err := GenerateGoWithOptions(schema, "Reading", GenerateOptions{SQL: true, ZeroCopy: true})
+	require.NoError(t, err)
+	require.Contains(t, code, `"database/sql/driver"`)
+
+	output := runGeneratedFiles(t, "", map[string]string{"generated.go": code, "sql.go": `package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+)
+
+var (
+	_ driver.Valuer            = (*Reading)(nil)
+	_ sql.Scanner              = (*Reading)(nil)
+	_ encoding.TextMarshaler   = (*Reading)(nil)
+	_ encoding.TextUnmarshaler = (*Reading)(nil)
+)
+`}, `
+	value, err := (&Reading{Sensor: 7, Name: runtime.ByteString("hi")}).Value()
+	fmt.Println(value, err)
+
+	// Scanned values don't keep views of the driver's buffer
+	blob := value.([]byte)
+	var reading Reading
+	fmt.Println(reading.Scan(blob))
+	blob[2] = 'X'
+	fmt.Println(&reading)
+	fmt.Println(reading.Scan(string([]byte{1, 0})), &reading)
+	fmt.Println(reading.Scan(nil))
+	fmt.Println(reading.Scan(42))
+
+	text, err := reading.MarshalText()
+	fmt.Println(string(text), err)
+	fmt.Println(reading.UnmarshalText([]byte("BwJoaQ==")), &reading)
+	fmt.Println(reading.UnmarshalText([]byte("!")))
+`)
+	require.Equal(t, `[7 2 104 105] <nil>
+<nil>
+Reading{sensor: 7, name: "hi"}
+<nil> Reading{sensor: 1, name: ""}
+cannot scan NULL into Reading
+cannot scan int into Reading
+AQA= <nil>
+<nil> Reading{sensor: 7, name: "hi"}
+illegal base64 data at input byte 0
+`, output)
+}
+
+func TestGenerateExtractField(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Header": { sequence: [
+				{ name: "version", type: "uint8" },
+				{ name: "id", type: "uint32" },
+				{ name: "flags", type: "bitfield", size: 8, fields: [
+					{ name: "urgent", offset: 0, size: 1 },
+					{ name: "priority", offset: 1, size: 7 },
+				] },
+			] },
+			"Message": { sequence: [
+				{ name: "magic", type: "uint32" },
+				{ name: "padding", type: "uint64" },
+				{ name: "header", type: "Header" },
+				{ name: "has_extra", type: "uint8" },
+				{ name: "extra", type: "uint16", conditional: "has_extra == 1" },
+				{ name: "length", type: "uint16" },
+				{ name: "body", type: "array", kind: "field_referenced", length_field: "length", items: { type: "uint8" } },
+			] },
+		},
+	}`)
+	code, err := GenerateGoWithOptions(schema, "Message", GenerateOptions{ExtractFields: true})
+	require.NoError(t, err)
+	// magic and padding are stepped over together; nothing later depends on them
+	require.Contains(t, code, "decoder.Skip(12)")
+
+	output := runGenerated(t, code, `
+	data := []byte{
+		0xCA, 0xFE, 0xBA, 0xBE, 0, 0, 0, 0, 0, 0, 0, 0,
+		2, 0, 0, 1, 0, 0x85,
+		0,
+		0, 2, 7, 8,
+	}
+	for _, path := range []string{"magic", "header.id", "header.flags.priority", "header.flags", "extra", "body", "nope", "header.nope", "magic.x"} {
+		value, err := ExtractMessageField(data, path)
+		fmt.Printf("%s %v %v\n", path, value, err)
+	}
+	// Fields before the one asked for aren't read, only counted
+	value, err := ExtractMessageField(data[:17], "header.id")
+	fmt.Println(value, err)
+	_, err = ExtractMessageField(data[:11], "header.id")
+	fmt.Println(err)
+`)
+	require.Equal(t, `magic 3405691582 <nil>
+header.id 256 <nil>
+header.flags.priority 5 <nil>
+header.flags {1 5} <nil>
+extra <nil> extra: field absent
+body [7 8] <nil>
+nope <nil> nope: unknown field
+header.nope <nil> header.nope: unknown field
+magic.x <nil> magic.x: unknown field
+256 <nil>
+header.id: unexpected end of stream
+`, output)
+}
+
+func TestGenerateLazyFields(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Archive": { sequence: [
+				{ name: "name", type: "string", kind: "length_prefixed", length_type: "uint8", lazy: true },
+				{ name: "count", type: "uint8" },
+				{ name: "samples", type: "array", kind: "field_referenced", length_field: "count", items: { type: "uint16" }, lazy: true },
+				{ name: "blob", type: "array", kind: "length_prefixed", length_type: "uint16", items: { type: "uint8" }, lazy: true },
+				{ name: "label", type: "string", kind: "fixed", length: 4, lazy: true },
+				{ name: "trailer", type: "uint8" },
+			] },
+		},
+	}`)
+	code, err := GenerateGoWithOptions(schema, "Archive", GenerateOptions{ZeroCopy: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "Samples runtime.Lazy[[]uint16]")
+
+	output := runGenerated(t, code, `
+	data := []byte{2, 'h', 'i', 2, 0, 1, 0, 2, 0, 3, 9, 8, 7, 'a', 'b', 0, 0, 0xFF}
+	archive, err := DecodeArchive(data)
+	fmt.Println(err, archive.Trailer, archive.Samples.Decoded(), archive.Samples.Raw())
+
+	samples, err := archive.Samples.Get()
+	fmt.Println(samples, err, archive.Samples.Decoded())
+	blob, _ := archive.Blob.Get()
+	fmt.Println(blob)
+	fmt.Println(archive)
+
+	encoded, err := archive.Encode()
+	fmt.Println(encoded, err)
+	text, err := json.Marshal(archive)
+	fmt.Println(string(text), err)
+
+	// Clones no longer view the input
+	clone := archive.Clone()
+	data[1] = 'H'
+	name, _ := clone.Name.Get()
+	fmt.Println(name)
+
+	built := &Archive{Name: runtime.LazyOf("x"), Count: 1, Samples: runtime.LazyOf([]uint16{258}), Label: runtime.LazyOf("ok")}
+	encoded, err = built.Encode()
+	fmt.Println(encoded, err)
+	var parsed Archive
+	fmt.Println(json.Unmarshal(text, &parsed), &parsed)
+
+	_, err = DecodeArchive([]byte{2, 'h', 'i', 200, 0, 1})
+	fmt.Println(err)
+`)
+	require.Equal(t, `<nil> 255 false [0 1 0 2]
+[1 2] <nil> true
+[9 8 7]
+Archive{name: "hi", count: 2, samples: [1 2], blob: [9 8 7], label: "ab", trailer: 255}
+[2 104 105 2 0 1 0 2 0 3 9 8 7 97 98 0 0 255] <nil>
+{"name":"hi","count":2,"samples":[1,2],"blob":[9,8,7],"label":"ab","trailer":255} <nil>
+hi
+[1 120 1 1 2 0 0 111 107 0 0 0] <nil>
+<nil> Archive{name: "hi", count: 2, samples: [1 2], blob: [9 8 7], label: "ab", trailer: 255}
+samples: length 200 exceeds remaining data
+`, output)
+
+	_, err = GenerateGo(parseTestSchema(t, `{
+		types: {
+			"Log": { sequence: [
+				{ name: "lines", type: "array", kind: "null_terminated", items: { type: "uint8" }, lazy: true },
+			] },
+		},
+	}`), "Log")
+	require.EqualError(t, err, "Log.lines: lazy fields must be strings or arrays of fixed-width numbers, with a fixed, length_prefixed, field_referenced or computed_count length")
+}
+
+func TestGenerateUnionAccessors(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"ARdata": { sequence: [ { name: "tag", type: "uint8" }, { name: "address", type: "uint32" } ] },
+			"NSRdata": { sequence: [ { name: "tag", type: "uint8" }, { name: "host", type: "string", kind: "length_prefixed", length_type: "uint8" } ] },
+			"Rdata": { type: "discriminated_union", discriminator: { peek: "uint8" }, variants: [
+				{ type: "ARdata", when: "value == 1" },
+				{ type: "NSRdata", when: "value == 2" },
+			] },
+			"Record": { sequence: [ { name: "rdata", type: "Rdata" } ] },
+		},
+	}`)
+	code, err := GenerateGo(schema, "Record")
+	require.NoError(t, err)
+	require.Contains(t, code, "func MatchRdata(v Rdata, onA func(*ARdata), onNS func(*NSRdata)) {")
+
+	output := runGeneratedFiles(t, "", map[string]string{"generated.go": code, "visitor.go": `package main
+
+import "fmt"
+
+type describer struct{}
+
+func (describer) VisitA(v *ARdata) error   { fmt.Println("address", v.Address); return nil }
+func (describer) VisitNS(v *NSRdata) error { fmt.Println("host", v.Host); return nil }
+`}, `
+	for _, input := range [][]byte{{1, 10, 0, 0, 1}, {2, 2, 'n', 's'}} {
+		record, err := DecodeRecord(input)
+		if err != nil {
+			panic(err)
+		}
+		a, ok := record.RdataAsA()
+		fmt.Println(a, ok)
+		ns, ok := record.RdataAsNS()
+		fmt.Println(ns, ok)
+		record.MatchRdata(func(v *ARdata) { fmt.Println("A", v.Address) }, nil)
+		fmt.Println(VisitRdata(record.Rdata, describer{}))
+	}
+	fmt.Println(VisitRdata(nil, describer{}))
+`)
+	require.Equal(t, `ARdata{tag: 1, address: 167772161} true
+nil false
+A 167772161
+address 167772161
+<nil>
+nil false
+NSRdata{tag: 2, host: "ns"} true
+host ns
+<nil>
+Rdata: no variant set
+`, output)
+
+	for _, tc := range []struct {
+		variants []string
+		want     []string
+	}{
+		{[]string{"ARdata", "NSRdata"}, []string{"A", "NS"}},
+		{[]string{"A_Record", "NS_Record"}, []string{"A", "NS"}},
+		{[]string{"MsgLogin", "MsgChat"}, []string{"Login", "Chat"}},
+		{[]string{"AddRequest", "AckRequest"}, []string{"Add", "Ack"}},
+		{[]string{"Alpha", "Beta"}, []string{"Alpha", "Beta"}},
+		{[]string{"Ping", "PingReply"}, []string{"Ping", "PingReply"}},
+		{[]string{"Literal"}, []string{"Literal"}},
+	} {
+		typeDef := &TypeDef{}
+		for _, variant := range tc.variants {
+			typeDef.Variants = append(typeDef.Variants, Variant{Type: variant})
+		}
+		require.Equal(t, tc.want, variantNames(typeDef), tc.variants)
+	}
+}
+
+func TestGenerateBuilders(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Header": { sequence: [
+				{ name: "id", type: "uint16" },
+				{ name: "qdcount", type: "uint8" },
+			] },
+			"Message": { sequence: [
+				{ name: "magic", type: "uint8", const: 0x42 },
+				{ name: "header", type: "Header" },
+				{ name: "questions", type: "array", kind: "field_referenced", length_field: "header.qdcount", items: { type: "uint16" } },
+				{ name: "note_len", type: "uint8" },
+				{ name: "note", type: "string", kind: "field_referenced", length_field: "note_len" },
+				{ name: "data_len", type: "uint8", computed: { type: "length_of", target: "data", offset: 1 } },
+				{ name: "data", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "uint8" } },
+				{ name: "extra", type: "uint8", conditional: "header.id == 7" },
+			] },
+		},
+	}`)
+	code, err := GenerateGoWithOptions(schema, "Message", GenerateOptions{Builders: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "func NewMessage(header Header, questions []uint16, note string, data []uint8) (*Message, error) {")
+	require.NotContains(t, code, "func (b *MessageBuilder) NoteLen(")
+
+	output := runGenerated(t, code, `
+	message, err := NewMessage(Header{Id: 7}, []uint16{1, 2}, "hi", []uint8{9})
+	fmt.Println(message, err)
+	encoded, _ := message.Encode()
+	fmt.Println(encoded)
+
+	message, err = NewMessageBuilder().Header(Header{Id: 1}).Questions(nil).Note("").Data(nil).Extra(5).Build()
+	fmt.Println(message, err)
+
+	_, err = NewMessageBuilder().Header(Header{Id: 1}).Note("x").Build()
+	fmt.Println(err)
+	_, err = NewMessage(Header{}, make([]uint16, 256), "", nil)
+	fmt.Println(err)
+`)
+	require.Equal(t, `Message{magic: 66, header: Header{id: 7, qdcount: 2}, questions: [1 2], note_len: 2, note: "hi", data_len: 2, data: [9], extra: 0} <nil>
+[66 0 7 2 0 1 0 2 2 104 105 2 1 9 0]
+Message{magic: 66, header: Header{id: 1, qdcount: 0}, questions: [], note_len: 0, note: "", data_len: 1, data: [], extra: 5} <nil>
+Message: questions is required
+Message: questions length 256 doesn't fit in header.qdcount
+`, output)
+}
+
+func TestGenerateEqualHash(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Point": { sequence: [ { name: "x", type: "float64" }, { name: "y", type: "float32" } ] },
+			"ARdata": { sequence: [ { name: "tag", type: "uint8" }, { name: "address", type: "uint32" } ] },
+			"NSRdata": { sequence: [ { name: "tag", type: "uint8" }, { name: "host", type: "string", kind: "length_prefixed", length_type: "uint8" } ] },
+			"Rdata": { type: "discriminated_union", discriminator: { peek: "uint8" }, variants: [
+				{ type: "ARdata", when: "value == 1" },
+				{ type: "NSRdata", when: "value == 2" },
+			] },
+			"Shape": { sequence: [
+				{ name: "name", type: "string", kind: "length_prefixed", length_type: "uint8" },
+				{ name: "count", type: "uint8" },
+				{ name: "points", type: "array", kind: "field_referenced", length_field: "count", items: { type: "Point" } },
+				{ name: "size", type: "uint8" },
+				{ name: "data", type: "array", kind: "field_referenced", length_field: "size", items: { type: "uint8" } },
+				{ name: "rdata", type: "Rdata" },
+			] },
+		},
+	}`)
+	code, err := GenerateGo(schema, "Shape")
+	require.NoError(t, err)
+
+	output := runGenerated(t, code, `
+	zero := 0.0
+	nan := zero / zero
+	a := &Shape{Name: "tri", Points: []Point{{X: nan, Y: 1}}, Data: nil, Rdata: &ARdata{Tag: 1, Address: 7}}
+	b := &Shape{Name: "tri", Points: []Point{{X: nan, Y: 1}}, Data: []uint8{}, Rdata: &ARdata{Tag: 1, Address: 7}}
+	fmt.Println(a.Equal(b), a.Hash() == b.Hash())
+
+	b.Rdata = &NSRdata{Tag: 2, Host: "x"}
+	fmt.Println(a.Equal(b), a.Hash() == b.Hash())
+	b.Rdata = &ARdata{Tag: 1, Address: 8}
+	fmt.Println(a.Equal(b), a.Hash() == b.Hash())
+	b.Rdata = nil
+	fmt.Println(a.Equal(b), b.Equal(&Shape{Name: "tri", Points: []Point{{X: nan, Y: 1}}}))
+
+	c := &Shape{Points: []Point{{X: -zero}}}
+	fmt.Println(c.Equal(&Shape{Points: []Point{{}}}))
+	var none *Shape
+	fmt.Println(none.Equal(nil), none.Equal(a), a.Equal(none))
+`)
+	require.Equal(t, `true true
+false false
+false false
+false true
+false
+true false false
+`, output)
+}
+
+func TestGenerateClone(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Point": { sequence: [ { name: "x", type: "int16" }, { name: "tags", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "uint8" } } ] },
+			"ARdata": { sequence: [ { name: "tag", type: "uint8" }, { name: "address", type: "uint32" } ] },
+			"NSRdata": { sequence: [ { name: "tag", type: "uint8" }, { name: "host", type: "string", kind: "length_prefixed", length_type: "uint8" } ] },
+			"Rdata": { type: "discriminated_union", discriminator: { peek: "uint8" }, variants: [
+				{ type: "ARdata", when: "value == 1" },
+				{ type: "NSRdata", when: "value == 2" },
+			] },
+			"Shape": { sequence: [
+				{ name: "origin", type: "Point" },
+				{ name: "count", type: "uint8" },
+				{ name: "points", type: "array", kind: "field_referenced", length_field: "count", items: { type: "Point" } },
+				{ name: "rdata", type: "Rdata" },
+			] },
+		},
+	}`)
+	code, err := GenerateGo(schema, "Shape")
+	require.NoError(t, err)
+	require.Contains(t, code, "func (m *Shape) Clone() *Shape {")
+
+	output := runGenerated(t, code, `
+	shape := &Shape{Origin: Point{X: 1, Tags: []uint8{1}}, Count: 1, Points: []Point{{X: 2, Tags: []uint8{2}}}, Rdata: &ARdata{Tag: 1, Address: 7}}
+	clone := shape.Clone()
+	fmt.Println(clone.Equal(shape))
+
+	clone.Origin.Tags[0] = 9
+	clone.Points[0].X = 9
+	clone.Points[0].Tags[0] = 9
+	clone.Rdata.(*ARdata).Address = 9
+	fmt.Println(shape)
+	fmt.Println(clone)
+
+	var none *Shape
+	fmt.Println(none.Clone(), (&Shape{}).Clone())
+`)
+	require.Equal(t, `true
+Shape{origin: Point{x: 1, tags: [1]}, count: 1, points: [Point{x: 2, tags: [2]}], rdata: ARdata{tag: 1, address: 7}}
+Shape{origin: Point{x: 1, tags: [9]}, count: 1, points: [Point{x: 9, tags: [9]}], rdata: ARdata{tag: 1, address: 9}}
+nil Shape{origin: Point{x: 0, tags: []}, count: 0, points: [], rdata: nil}
+`, output)
+}
+
+func TestGenerateCanonical(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Reading": { sequence: [
+				{ name: "level", type: "bit", size: 3 },
+				{ name: "spare", type: "bit", size: 5 },
+				{ name: "value", type: "float32" },
+				{ name: "samples", type: "array", kind: "fixed", length: 1, items: { type: "float64" } },
+				{ name: "label", type: "string", kind: "fixed", length: 4 },
+				{ name: "note", type: "string", kind: "null_terminated" },
+			] },
+		},
+	}`)
+	plain, err := GenerateGo(schema, "Reading")
+	require.NoError(t, err)
+	require.NotContains(t, plain, "CanonicalFloat32")
+
+	code, err := GenerateGoWithOptions(schema, "Reading", GenerateOptions{Canonical: true})
+	require.NoError(t, err)
+
+	output := runGeneratedFiles(t, "", map[string]string{"generated.go": code, "nan.go": `package main
+
+import "math"
+
+// A NaN with bits other than the quiet NaN's
+var payload = math.Float64frombits(0x7FF0000000000001)
+`}, `
+	zero := 0.0
+	nan := zero / zero
+	a, err := (&Reading{Level: 1, Value: float32(nan), Samples: []float64{nan}, Label: "ab", Note: "x"}).Encode()
+	fmt.Println(a, err)
+	b, err := (&Reading{Level: 1, Value: float32(-payload), Samples: []float64{payload}, Label: "ab", Note: "x"}).Encode()
+	fmt.Println(string(a) == string(b), err)
+
+	_, err = (&Reading{Level: 8}).Encode()
+	fmt.Println(err)
+	_, err = (&Reading{Label: "abcde"}).Encode()
+	fmt.Println(err)
+	_, err = (&Reading{Label: "a\x00"}).Encode()
+	fmt.Println(err)
+	_, err = (&Reading{Note: "a\x00b"}).Encode()
+	fmt.Println(err)
+`)
+	require.Equal(t, `[32 127 192 0 0 127 248 0 0 0 0 0 0 97 98 0 0 120 0] <nil>
+true <nil>
+level: 8 doesn't fit in 3 bits
+label: 5 bytes don't fit in 4
+label: string ends in padding
+note: string contains a NUL byte
+`, output)
+}
+
+func TestGenerateASCIIStrings(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Record": { sequence: [
+				{ name: "name", type: "string", kind: "length_prefixed", length_type: "uint8", encoding: "ascii" },
+				{ name: "tags", type: "array", kind: "fixed", length: 2, items: { type: "string", kind: "null_terminated", encoding: "ascii" } },
+			] },
+		},
+	}`)
+	code, err := GenerateGo(schema, "Record")
+	require.NoError(t, err)
+	output := runGenerated(t, code, `
+	a, err := (&Record{Name: "abc", Tags: []string{"x", "y"}}).Encode()
+	fmt.Println(a, err)
+	_, err = (&Record{Name: "café", Tags: []string{"x", "y"}}).Encode()
+	invalid, ok := err.(*runtime.InvalidValueError)
+	fmt.Println(err, ok && invalid.Code() == runtime.ErrorInvalidValue && invalid.Index == 3)
+	_, err = (&Record{Name: "a", Tags: []string{"x", "\xff"}}).Encode()
+	fmt.Println(err)
+	b, err := (&Record{Name: "café ☕", Tags: []string{"x", "\xff"}}).Encode()
+	fmt.Println(b, err)
+`)
+	require.Equal(t, `[3 97 98 99 120 0 121 0] <nil>
+name: character 'é' is not ASCII at index 3 true
+array item: byte 0xff is not ASCII at index 0
+[] name: character 'é' is not ASCII at index 3
+`, output)
+
+	code, err = GenerateGoWithOptions(schema, "Record", GenerateOptions{ReplaceNonASCII: true, ZeroCopy: true})
+	require.NoError(t, err)
+	output = runGenerated(t, code, `
+	b, err := (&Record{Name: runtime.ByteString("café ☕"), Tags: []runtime.ByteString{runtime.ByteString("x"), runtime.ByteString("\xff")}}).Encode()
+	fmt.Println(b, err)
+`)
+	require.Equal(t, "[6 99 97 102 63 32 63 120 0 63 0] <nil>\n", output)
+}
+
+func TestGenerateUTF8Strings(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian", invalid_utf8: "reject" },
+		types: {
+			"Name": { sequence: [{ name: "text", type: "string", kind: "length_prefixed", length_type: "uint8" }] },
+			"Record": { sequence: [
+				{ name: "id", type: "uint8" },
+				{ name: "name", type: "Name" },
+				{ name: "tags", type: "array", kind: "fixed", length: 2, items: { type: "string", kind: "null_terminated" } },
+			] },
+			"Counts": { sequence: [{ name: "n", type: "uint16" }] },
+		},
+	}`)
+	code, err := GenerateGoWithOptions(schema, "Record", GenerateOptions{ExportDecoders: true})
+	require.NoError(t, err)
+	require.NotContains(t, code, "DecodeCountsWithUTF8")
+	output := runGenerated(t, code, `
+	valid := []byte{1, 3, 'a', 'b', 'c', 'x', 0, 'y', 0}
+	invalid := []byte{1, 3, 'a', 0xff, 'c', 'x', 0, 0xe2, 0x82, 0}
+	r, err := DecodeRecord(valid)
+	fmt.Println(r.Name.Text, r.Tags, err)
+	decoder := runtime.NewBitStreamDecoder(invalid, runtime.MSBFirst)
+	_, err = DecodeRecordWithDecoder(decoder)
+	fmt.Println(err, *decoder.LastErrorCode)
+	r, err = DecodeRecordWithUTF8(invalid, runtime.UTF8Replace)
+	fmt.Printf("%q %q %v\n", r.Name.Text, r.Tags, err)
+	r, err = DecodeRecordWithUTF8(invalid, runtime.UTF8Keep)
+	fmt.Printf("%q %q %v\n", r.Name.Text, r.Tags, err)
+	r, err = DecodeRecordWithUTF8([]byte{1, 1, 'a', 'x', 0, 0xe2, 0x82, 0}, runtime.UTF8Default)
+	fmt.Println(err)
+`)
+	require.Equal(t, `abc [x y] <nil>
+text: invalid UTF-8 at index 1 SCHEMA_MISMATCH
+"a�c" ["x" "�"] <nil>
+"a\xffc" ["x" "\xe2\x82"] <nil>
+array item: invalid UTF-8 at index 0
+`, output)
+
+	// Views are kept unless they are repaired, and lazy strings decode with the policy
+	// their decode had
+	schema = parseTestSchema(t, `{
+		config: { endianness: "big_endian", invalid_utf8: "replace" },
+		types: { "Record": { sequence: [
+			{ name: "name", type: "string", kind: "length_prefixed", length_type: "uint8" },
+			{ name: "note", type: "string", kind: "length_prefixed", length_type: "uint8", lazy: true },
+		] } },
+	}`)
+	code, err = GenerateGoWithOptions(schema, "Record", GenerateOptions{ZeroCopy: true})
+	require.NoError(t, err)
+	output = runGenerated(t, code, `
+	r, err := DecodeRecord([]byte{2, 'a', 0xff, 2, 'b', 0xff})
+	note, noteErr := r.Note.Get()
+	fmt.Printf("%q %q %v %v\n", r.Name, note, err, noteErr)
+	r, err = DecodeRecordWithUTF8([]byte{2, 'a', 'b', 2, 'b', 0xff}, runtime.UTF8Reject)
+	_, noteErr = r.Note.Get()
+	fmt.Println(r.Name, err, noteErr)
+`)
+	require.Equal(t, `"a�" "b�" <nil> <nil>
+ab <nil> note: invalid UTF-8 at index 1
+`, output)
+}
+
+func TestGenerateStringPadding(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: { "Record": { sequence: [
+			{ name: "null", type: "string", kind: "fixed", length: 5, encoding: "ascii" },
+			{ name: "space", type: "string", kind: "fixed", length: 5, encoding: "ascii", padding: "space" },
+			{ name: "raw", type: "string", kind: "fixed", length: 3, encoding: "ascii", padding: "none" },
+		] } },
+	}`)
+	const want = `[97 0 98 0 0 97 32 98 32 32 120 0 0] <nil>
+"a\x00b" "a b" "x\x00\x00" <nil>
+`
+	code, err := GenerateGo(schema, "Record")
+	require.NoError(t, err)
+	output := runGenerated(t, code, `
+	encoded, err := (&Record{Null: "a\x00b", Space: "a b", Raw: "x"}).Encode()
+	fmt.Println(encoded, err)
+	r, err := DecodeRecord(encoded)
+	fmt.Printf("%q %q %q %v\n", r.Null, r.Space, r.Raw, err)
+`)
+	require.Equal(t, want, output)
+
+	// Views trim the same padding
+	code, err = GenerateGoWithOptions(schema, "Record", GenerateOptions{ZeroCopy: true})
+	require.NoError(t, err)
+	output = runGenerated(t, code, `
+	encoded, err := (&Record{Null: runtime.ByteString("a\x00b"), Space: runtime.ByteString("a b"), Raw: runtime.ByteString("x")}).Encode()
+	fmt.Println(encoded, err)
+	r, err := DecodeRecord(encoded)
+	fmt.Printf("%q %q %q %v\n", r.Null, r.Space, r.Raw, err)
+`)
+	require.Equal(t, want, output)
+
+	// Canonical encoding rejects strings that would decode as another
+	code, err = GenerateGoWithOptions(schema, "Record", GenerateOptions{Canonical: true})
+	require.NoError(t, err)
+	output = runGenerated(t, code, `
+	_, err := (&Record{Null: "a\x00", Raw: "xyz"}).Encode()
+	fmt.Println(err)
+	_, err = (&Record{Space: "a ", Raw: "xyz"}).Encode()
+	fmt.Println(err)
+	_, err = (&Record{Raw: "x"}).Encode()
+	fmt.Println(err)
+`)
+	require.Equal(t, `null: string ends in padding
+space: string ends in padding
+raw: 1 bytes don't fill 3
+`, output)
+}
+
+func TestGenerateNullTerminatedMaxLength(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: { "Record": { sequence: [
+			{ name: "name", type: "string", kind: "null_terminated", max_length: 4 },
+			{ name: "tags", type: "array", kind: "fixed", length: 1, items: { type: "string", kind: "null_terminated", encoding: "ascii", max_length: 2 } },
+		] } },
+	}`)
+	const body = `
+	for _, input := range [][]byte{
+		{'a', 'b', 'c', 'd', 0, 'x', 0},
+		{'a', 'b', 'c', 'd', 'e', 0, 'x', 0},
+		{'a', 'b', 'c'},
+		{'a', 0, 'x', 'y', 'z', 0},
+	} {
+		decoder := runtime.NewBitStreamDecoder(input, runtime.MSBFirst)
+		r, err := DecodeRecordWithDecoder(decoder)
+		if err != nil {
+			fmt.Println(err, *decoder.LastErrorCode)
+			continue
+		}
+		fmt.Println(r.Name, r.Tags)
+	}
+	_, err := (&Record{VALUE}).Encode()
+	fmt.Println(err)
+`
+	const want = `abcd [x]
+name: no terminator within max_length of 4 bytes SCHEMA_MISMATCH
+unexpected end of stream INCOMPLETE_DATA
+array item: no terminator within max_length of 2 bytes SCHEMA_MISMATCH
+name: 5 bytes exceed max_length 4
+`
+	code, err := GenerateGoWithOptions(schema, "Record", GenerateOptions{ExportDecoders: true})
+	require.NoError(t, err)
+	require.Equal(t, want, runGenerated(t, code, strings.Replace(body, "VALUE", `Name: "abcde", Tags: []string{"x"}`, 1)))
+
+	code, err = GenerateGoWithOptions(schema, "Record", GenerateOptions{ExportDecoders: true, ZeroCopy: true})
+	require.NoError(t, err)
+	require.Equal(t, want, runGenerated(t, code, strings.Replace(body, "VALUE", `Name: runtime.ByteString("abcde")`, 1)))
+}
+
+func TestGenerateCompressNames(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Label": { type: "string", kind: "length_prefixed", length_type: "uint8", encoding: "ascii" },
+			"LabelPointer": { type: "back_reference", storage: "uint16", offset_mask: "0x3FFF", offset_from: "message_start", target_type: "Label" },
+			"CompressedLabel": { type: "discriminated_union", discriminator: { peek: "uint8" }, variants: [
+				{ type: "Label", when: "value < 0xC0" },
+				{ type: "LabelPointer", when: "value >= 0xC0" },
+			] },
+			"CompressedDomain": { type: "array", kind: "null_terminated", items: { type: "CompressedLabel" }, terminal_variants: ["LabelPointer"] },
+			"Message": { sequence: [
+				{ name: "id", type: "uint16" },
+				{ name: "names", type: "array", kind: "fixed", length: 3, items: { type: "CompressedDomain" } },
+			] },
+		},
+	}`)
+	const helpers = `package main
+
+func name(labels ...string) CompressedDomain {
+	var d CompressedDomain
+	for _, l := range labels {
+		d.Value = append(d.Value, &Label{Value: l})
+	}
+	return d
+}
+`
+
+	code, err := GenerateGo(schema, "Message")
+	require.NoError(t, err)
+	output := runGeneratedFiles(t, "", map[string]string{"generated.go": code, "names.go": helpers}, `
+	second := name("mail")
+	second.Value = append(second.Value, &LabelPointer{Value: Label{Value: "example"}})
+	unknown := CompressedDomain{Value: []CompressedLabel{&LabelPointer{Value: Label{Value: "nowhere"}}}}
+	b, err := (&Message{Id: 7, Names: []CompressedDomain{name("www", "example", "com"), second, unknown}}).Encode()
+	fmt.Println(b, err)
+	d, err := DecodeMessage(b)
+	fmt.Println(len(d.Names[1].Value), d.Names[1].Value[1].(*LabelPointer).Value.Value, err)
+	_, err = DecodeMessage([]byte{0, 1, 0xC0, 2, 0, 0})
+	fmt.Println(err != nil)
+`)
+	require.Equal(t, `[0 7 3 119 119 119 7 101 120 97 109 112 108 101 3 99 111 109 0 4 109 97 105 108 192 6 7 110 111 119 104 101 114 101 0] <nil>
+2 example <nil>
+true
+`, output)
+
+	code, err = GenerateGoWithOptions(schema, "Message", GenerateOptions{CompressNames: true})
+	require.NoError(t, err)
+	output = runGeneratedFiles(t, "", map[string]string{"generated.go": code, "names.go": helpers}, `
+	b, err := (&Message{Id: 7, Names: []CompressedDomain{name("www", "example", "com"), name("mail", "example", "com"), name("example", "com")}}).Encode()
+	fmt.Println(b, err)
+	// Names are keyed with DNSNameKey, so they point at names differing only in case
+	b, err = (&Message{Id: 7, Names: []CompressedDomain{name("www", "example", "com"), name("mail", "EXAMPLE", "Com"), name("Example", "COM")}}).Encode()
+	fmt.Println(b, err)
+`)
+	require.Equal(t, `[0 7 3 119 119 119 7 101 120 97 109 112 108 101 3 99 111 109 0 4 109 97 105 108 192 6 192 6] <nil>
+[0 7 3 119 119 119 7 101 120 97 109 112 108 101 3 99 111 109 0 4 109 97 105 108 192 6 192 6] <nil>
+`, output)
+	require.Contains(t, code, "ctx.RecordName(Value_labels, Value_offsets, runtime.DNSNameKey)")
+}
+
+func TestGenerateResolveNames(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Label": { type: "string", kind: "length_prefixed", length_type: "uint8", encoding: "ascii" },
+			"LabelPointer": { type: "back_reference", storage: "uint16", offset_mask: "0x3FFF", offset_from: "message_start", target_type: "Label" },
+			"CompressedLabel": { type: "discriminated_union", discriminator: { peek: "uint8" }, variants: [
+				{ type: "Label", when: "value < 0xC0" },
+				{ type: "LabelPointer", when: "value >= 0xC0" },
+			] },
+			"CompressedDomain": { type: "array", kind: "null_terminated", items: { type: "CompressedLabel" }, terminal_variants: ["LabelPointer"] },
+			"Message": { sequence: [
+				{ name: "names", type: "array", kind: "fixed", length: 4, items: { type: "CompressedDomain" } },
+				{ name: "id", type: "uint8" },
+			] },
+		},
+	}`)
+	plain, err := GenerateGo(schema, "Message")
+	require.NoError(t, err)
+	require.NotContains(t, plain, "Domain() string")
+
+	code, err := GenerateGoWithOptions(schema, "Message", GenerateOptions{ResolveNames: true, CompressNames: true})
+	require.NoError(t, err)
+	output := runGenerated(t, code, `
+	// www.example.com, mail -> example.com, -> com, root
+	data := []byte{3, 'w', 'w', 'w', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		4, 'm', 'a', 'i', 'l', 0xC0, 4, 0xC0, 12, 0, 9}
+	m, err := DecodeMessage(data)
+	fmt.Println(err)
+	for _, name := range m.Names {
+		fmt.Println(name.Labels(), name.Domain())
+	}
+	fmt.Println(m.Id)
+	b, err := m.Encode()
+	fmt.Println(string(b) == string(data), err)
+	_, err = DecodeMessage([]byte{0xC0, 0})
+	fmt.Println(err)
+`)
+	require.Equal(t, `<nil>
+[www example com] www.example.com
+[mail example com] mail.example.com
+[com] com
+[] .
+9
+true <nil>
+maximum nesting depth of 128 exceeded
+`, output)
+}
+
+func TestGenerateDecodeEach(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Record": { sequence: [
+				{ name: "id", type: "uint16" },
+				{ name: "name", type: "string", kind: "length_prefixed", length_type: "uint8" },
+			] },
+			"Log": { sequence: [
+				{ name: "count", type: "uint8" },
+				{ name: "records", type: "array", kind: "field_referenced", length_field: "count", items: { type: "Record" } },
+				{ name: "tags", type: "array", kind: "null_terminated", items: { type: "uint8" } },
+				{ name: "trailer", type: "uint8" },
+			] },
+		},
+	}`)
+	code, err := GenerateGo(schema, "Log")
+	require.NoError(t, err)
+	require.Contains(t, code, "func DecodeLogRecordsEach(bytes []byte, fn func(item *Record) error) (*Log, error)")
+	require.Contains(t, code, "func DecodeLogTagsEach(bytes []byte, fn func(item uint8) error) (*Log, error)")
+
+	output := runGenerated(t, code, `
+	data := []byte{3, 0, 1, 1, 'a', 0, 2, 2, 'b', 'c', 0, 3, 0, 7, 8, 0, 0xFF}
+	log, err := DecodeLogRecordsEach(data, func(item *Record) error {
+		fmt.Println(item.Id, item.Name)
+		return nil
+	})
+	fmt.Println(err, len(log.Records), log.Tags, log.Trailer)
+
+	log, err = DecodeLogTagsEach(data, func(item uint8) error {
+		fmt.Println("tag", item)
+		return nil
+	})
+	fmt.Println(err, len(log.Records), len(log.Tags), log.Trailer)
+
+	// Returning an error stops decoding at that item
+	stop := fmt.Errorf("enough")
+	seen := 0
+	log, err = DecodeLogRecordsEach(data, func(item *Record) error {
+		seen++
+		if item.Id == 2 {
+			return stop
+		}
+		return nil
+	})
+	fmt.Println(log == nil, err == stop, seen)
+`)
+	require.Equal(t, `1 a
+2 bc
+3 
+<nil> 0 [7 8] 255
+tag 7
+tag 8
+<nil> 3 0 255
+true true 2
+`, output)
+}
+
+func TestGenerateEncodeWithLimit(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Item": { sequence: [
+				{ name: "value", type: "uint32" },
+			] },
+			"Packet": { sequence: [
+				{ name: "id", type: "uint8" },
+				{ name: "items", type: "array", kind: "length_prefixed", length_type: "uint16", items: { type: "Item" } },
+				{ name: "payload", type: "string", kind: "null_terminated" },
+			] },
+		},
+	}`)
+	code, err := GenerateGo(schema, "Packet")
+	require.NoError(t, err)
+	require.Contains(t, code, "func (m *Packet) EncodeWithLimit(maxBytes int) ([]byte, error) {")
+	require.Contains(t, code, "\tencoder.SetLimit(ctx.OutputRemaining())\n")
+
+	output := runGenerated(t, code, `
+	packet := &Packet{Id: 1, Payload: "abc"}
+	for i := 0; i < 10; i++ {
+		packet.Items = append(packet.Items, Item{Value: uint32(i)})
+	}
+	// 1 + 2 + 10*4 + 3 + 1 bytes
+	data, err := packet.EncodeWithLimit(47)
+	fmt.Println(len(data), err)
+	data, err = packet.EncodeWithLimit(46)
+	fmt.Println(data == nil, err)
+
+	// Stopped in the items
+	_, err = packet.EncodeWithLimit(10)
+	fmt.Println(err)
+
+	// The option does the same for EncodeWithContext; Encode has no limit
+	_, err = packet.EncodeWithContext(runtime.NewEncodingContext(runtime.OutputLimit(3)))
+	fmt.Println(err)
+	data, err = packet.Encode()
+	fmt.Println(len(data), err)
+`)
+	require.Equal(t, `47 <nil>
+true output too large: more than 46 bytes
+output too large: more than 10 bytes
+output too large: more than 3 bytes
+47 <nil>
+`, output)
+}
+
+func TestGenerateEncodeFit(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Header": { sequence: [
+				{ name: "id", type: "uint16" },
+				{ name: "ancount", type: "uint16" },
+			] },
+			"Answer": { sequence: [
+				{ name: "name", type: "string", kind: "null_terminated" },
+				{ name: "ttl", type: "uint32" },
+			] },
+			"Response": { sequence: [
+				{ name: "header", type: "Header" },
+				{ name: "answers", type: "array", kind: "field_referenced", length_field: "header.ancount", items: { type: "Answer" }, splittable: true },
+			] },
+			"Batch": { sequence: [
+				{ name: "samples", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "uint16" }, splittable: true },
+			] },
+		},
+	}`)
+	code, err := GenerateGo(schema, "Response")
+	require.NoError(t, err)
+	require.Contains(t, code, "func (m *Response) EncodeAnswersFit(maxBytes int) ([]byte, []Answer, error) {")
+	require.Contains(t, code, "\t\tmessage.Header.Ancount = uint16(len(items))\n")
+
+	output := runGenerated(t, code, `
+	response := &Response{Header: Header{Id: 7, Ancount: 5}}
+	for _, name := range []string{"a", "bb", "ccc", "dd", "e"} {
+		response.Answers = append(response.Answers, Answer{Name: name, Ttl: 60})
+	}
+	// Header 4 bytes, answers 6 to 8
+	data, rest, err := response.EncodeAnswersFit(20)
+	fmt.Println(data, len(rest), err)
+	decoded, _ := DecodeResponse(data)
+	fmt.Println(decoded.Header.Ancount, len(decoded.Answers), response.Header.Ancount)
+
+	messages, err := response.EncodeAnswersSplit(20)
+	fmt.Println(len(messages), err)
+	for _, message := range messages {
+		decoded, _ := DecodeResponse(message)
+		fmt.Println(len(message), decoded.Header.Id, decoded.Header.Ancount)
+	}
+
+	// Everything fits in one message; nothing fits with no room for an answer
+	data, rest, err = response.EncodeAnswersFit(512)
+	fmt.Println(len(data), rest == nil, err)
+	_, _, err = response.EncodeAnswersFit(8)
+	fmt.Println(err)
+
+	// A count can't pass what its length prefix holds
+	batch := &Batch{Samples: make([]uint16, 300)}
+	messages, err = batch.EncodeSamplesSplit(1000)
+	fmt.Println(len(messages), len(messages[0]), len(messages[1]), err)
+`)
+	require.Equal(t, `[0 7 0 2 97 0 0 0 0 60 98 98 0 0 0 0 60] 3 <nil>
+2 2 5
+3 <nil>
+17 7 2
+19 7 2
+10 7 1
+38 true <nil>
+output too large: the first item doesn't fit in a message on its own
+2 511 91 <nil>
+`, output)
+
+	for _, bad := range []struct{ field, want string }{
+		{`{ name: "ids", type: "array", kind: "fixed", length: 4, items: { type: "uint8" }, splittable: true }`, "fixed arrays hold a set number of items"},
+		{`{ name: "ids", type: "array", kind: "field_referenced", length_field: "b", items: { type: "uint8" }, splittable: true }`, "must name an integer field"},
+		{`{ name: "ids", type: "uint8", splittable: true }`, "splittable needs an array"},
+	} {
+		schema := parseTestSchema(t, `{ types: { "T": { sequence: [ { name: "n", type: "uint8" }, { name: "b", type: "bit", size: 8 }, `+bad.field+` ] } } }`)
+		_, err := GenerateGo(schema, "T")
+		require.ErrorContains(t, err, bad.want)
+	}
+}
+
+func TestGenerateTransform(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Container": { sequence: [
+				{ name: "version", type: "uint8" },
+				{ name: "manifest", type: "string", kind: "length_prefixed", length_type: "uint16", transform: "gzip" },
+				{ name: "payload", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "uint8" }, transform: { type: "xor", key: [0x5A, 0xA5] } },
+				{ name: "secret", type: "array", kind: "length_prefixed", length_type: "uint16", items: { type: "uint8" }, transform: { type: "aes-gcm", key_id: "k1" } },
+			] },
+		},
+	}`)
+	code, err := GenerateGo(schema, "Container")
+	require.NoError(t, err)
+	require.Contains(t, code, `runtime.ApplyTransform("xor", runtime.TransformParams{Field: "payload", Key: []byte{0x5A, 0xA5}}, m.Payload)`)
+
+	output := runGenerated(t, code, `
+	container := &Container{Version: 1, Payload: []byte{1, 2, 3}, Secret: []byte("hunter2")}
+	for i := 0; i < 50; i++ {
+		container.Manifest += "entry;"
+	}
+	// aes-gcm needs its key hook registered
+	_, err := container.Encode()
+	fmt.Println(err)
+
+	key := []byte("0123456789abcdef")
+	runtime.RegisterTransform("aes-gcm", runtime.AESGCM(func(keyID string) ([]byte, error) {
+		if keyID != "k1" {
+			return nil, fmt.Errorf("no key")
+		}
+		return key, nil
+	}))
+	data, err := container.Encode()
+	fmt.Println(len(data) < 300, err)
+	// The XORed payload follows the compressed manifest
+	manifestLength := int(data[1])<<8 | int(data[2])
+	fmt.Println(data[3+manifestLength:][:4])
+
+	decoded, err := DecodeContainer(data)
+	fmt.Println(err, decoded.Manifest == container.Manifest, decoded.Payload, string(decoded.Secret))
+
+	// Tampered ciphertext fails to decode
+	data[len(data)-1] ^= 1
+	_, err = DecodeContainer(data)
+	fmt.Println(err)
+`)
+	require.Equal(t, `secret: unknown transform "aes-gcm": register it with runtime.RegisterTransform
+true <nil>
+[3 91 167 89]
+<nil> true [1 2 3] hunter2
+secret: cipher: message authentication failed
+`, output)
+
+	for _, bad := range []struct{ field, want string }{
+		{`{ name: "s", type: "string", kind: "null_terminated", transform: "gzip" }`, "must be length_prefixed"},
+		{`{ name: "s", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "uint16" }, transform: "zlib" }`, "only strings and byte arrays"},
+		{`{ name: "s", type: "string", kind: "length_prefixed", length_type: "uint8", transform: "brotli" }`, `unknown transform "brotli"`},
+		{`{ name: "s", type: "string", kind: "length_prefixed", length_type: "uint8", transform: { type: "xor" } }`, "transform xor needs a key"},
+	} {
+		schema := parseTestSchema(t, `{ types: { "T": { sequence: [ `+bad.field+` ] } } }`)
+		_, err := GenerateGo(schema, "T")
+		require.ErrorContains(t, err, bad.want)
+	}
+}
+
+func TestGenerateCodec(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Reading": { sequence: [
+				{ name: "sensor", type: "uint8" },
+				{ name: "value", type: "float32", codec: "q8_8" },
+				{ name: "label", type: "string", codec: "pascal" },
+				{ name: "history", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "float32", codec: "q8_8" } },
+				{ name: "flags", type: "uint8" },
+			] },
+		},
+	}`)
+	code, err := GenerateGo(schema, "Reading")
+	require.NoError(t, err)
+	require.Contains(t, code, `runtime.DecodeCustom[float32]("q8_8", decoder)`)
+
+	output := runGenerated(t, code, `
+	reading := &Reading{Sensor: 3, Value: 1.5, Label: "temp", History: []float32{-2, 0.25}, Flags: 9}
+	_, err := reading.Encode()
+	fmt.Println(err)
+
+	// A signed 8.8 fixed-point number
+	runtime.RegisterCodec("q8_8", func(encoder *runtime.BitStreamEncoder, value float32) error {
+		encoder.WriteUint16(uint16(int16(value*256)), runtime.BigEndian)
+		return nil
+	}, func(decoder *runtime.BitStreamDecoder) (float32, error) {
+		raw, err := decoder.ReadUint16(runtime.BigEndian)
+		return float32(int16(raw)) / 256, err
+	})
+	// A length byte, then the text
+	runtime.RegisterCodec("pascal", func(encoder *runtime.BitStreamEncoder, value string) error {
+		if len(value) > 255 {
+			return fmt.Errorf("too long")
+		}
+		encoder.WriteUint8(uint8(len(value)))
+		encoder.WriteBytes([]byte(value))
+		return nil
+	}, func(decoder *runtime.BitStreamDecoder) (string, error) {
+		n, err := decoder.ReadUint8()
+		if err != nil {
+			return "", err
+		}
+		text, err := decoder.ReadBytesSlice(int(n))
+		return string(text), err
+	})
+
+	data, err := reading.Encode()
+	fmt.Println(data, err)
+	decoded, err := DecodeReading(data)
+	fmt.Println(err, decoded.Sensor, decoded.Value, decoded.Label, decoded.History, decoded.Flags)
+
+	// A codec registered for another Go type is refused
+	runtime.RegisterCodec("pascal", func(encoder *runtime.BitStreamEncoder, value []byte) error {
+		return nil
+	}, func(decoder *runtime.BitStreamDecoder) ([]byte, error) {
+		return nil, nil
+	})
+	_, err = reading.Encode()
+	fmt.Println(err)
+`)
+	require.Equal(t, `value: unknown codec "q8_8": register it with runtime.RegisterCodec
+[3 1 128 4 116 101 109 112 2 254 0 0 64 9] <nil>
+<nil> 3 1.5 temp [-2 0.25] 9
+label: codec "pascal" is registered for another type than string
+`, output)
+
+	for _, bad := range []struct{ field, want string }{
+		{`{ name: "v", type: "uint16", codec: "" }`, "codec must be the name of a registered codec"},
+		{`{ name: "v", type: "string", kind: "length_prefixed", length_type: "uint8", codec: "x", transform: "gzip" }`, "codec fields can't also have transform"},
+		{`{ name: "v", type: "bitfield", size: 8, fields: [ { name: "a", size: 8, codec: "x" } ] }`, "can't have a codec"},
+	} {
+		schema := parseTestSchema(t, `{ types: { "T": { sequence: [ `+bad.field+` ] } } }`)
+		_, err := GenerateGo(schema, "T")
+		require.ErrorContains(t, err, bad.want)
+	}
+}
+
+func TestGenerateWideLengths(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Header": { sequence: [
+				{ name: "count", type: "uint128" },
+			] },
+			"Entry": { sequence: [
+				{ name: "id", type: "uint8" },
+			] },
+			"Archive": {
+				sequence: [
+					{ name: "header", type: "Header" },
+					{ name: "size", type: "uint128" },
+					{ name: "data", type: "array", kind: "field_referenced", length_field: "size", items: { type: "uint8" } },
+					{ name: "entries", type: "array", kind: "computed_count", count_expr: "header.count", items: { type: "Entry" } },
+					{ name: "where", type: "int128" },
+				],
+				instances: [
+					{ name: "index", type: "Entry", position: "where" },
+				],
+			},
+		},
+	}`)
+	code, err := GenerateGo(schema, "Archive")
+	require.NoError(t, err)
+
+	output := runGenerated(t, code, `
+	archive := &Archive{
+		Header:      Header{Count: runtime.Uint128{Lo: 2}},
+		Size:        runtime.Uint128{Lo: 3},
+		Data:        []uint8{1, 2, 3},
+		Entries:     []Entry{{Id: 7}, {Id: 8}},
+		Index:       Entry{Id: 9},
+	}
+	data, err := archive.Encode()
+	fmt.Println(len(data), err)
+	decoded, err := DecodeArchive(data)
+	fmt.Println(err, decoded.Data, decoded.Entries, decoded.Index.Id)
+
+	// Lengths and offsets past an int fail rather than wrap
+	data[16] = 1
+	_, err = DecodeArchive(data)
+	fmt.Println(err)
+	data[16] = 0
+	data[37] = 0x80
+	_, err = DecodeArchive(data)
+	fmt.Println(err)
+	data[37] = 0
+	data[52] = 60
+	_, err = DecodeArchive(data)
+	fmt.Println(err)
+`)
+	require.Equal(t, `54 <nil>
+<nil> [1 2 3] [{7} {8}] 9
+data: length size: 1329227995784915872903807060280344579 is larger than an int holds
+index: position where: -170141183460469231731687303715884105675 is outside what an int holds
+index: position 60 is outside the data
+`, output)
+}
+
+func TestGenerateNaming(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Ping": { sequence: [ { name: "kind", type: "uint8" }, { name: "seq", type: "uint16" } ] },
+			"Pong": { sequence: [ { name: "kind", type: "uint8" } ] },
+			"Header": { sequence: [ { name: "version", type: "uint8" } ] },
+			"Message": { type: "discriminated_union", discriminator: { peek: "uint8" }, variants: [
+				{ when: "value == 1", type: "Ping" },
+				{ when: "value == 2", type: "Pong" },
+			] },
+			"Packet": { sequence: [
+				{ name: "flags", type: "bitfield", size: 8, fields: [ { name: "urgent", size: 1 }, { name: "spare", size: 7 } ] },
+				{ name: "header", type: "Header" },
+				{ name: "message", type: "Message" },
+			] },
+		},
+	}`)
+	code, err := GenerateGoWithOptions(schema, "Packet", GenerateOptions{
+		TypePrefix: "Wire", TypeSuffix: "V1", ExportDecoders: true, Receiver: "p", PointerFields: []string{"Packet.header"},
+	})
+	require.NoError(t, err)
+	require.Contains(t, code, "type WirePacketV1 struct {")
+	require.Contains(t, code, "type WirePacket_FlagsV1 struct {")
+	require.Contains(t, code, "func (p *WirePacketV1) Encode() ([]byte, error) {")
+	require.Contains(t, code, "func (p *WirePacketV1) MessageAsPing() (*WirePingV1, bool) {")
+	require.Contains(t, code, "func WireDumpAnnotatedV1(data []byte) (string, error) {")
+	require.Contains(t, code, "func WireSchemaV1() *runtime.SchemaInfo {")
+	require.NotContains(t, code, "(m *")
+
+	output := runGenerated(t, code, `
+	packet := &WirePacketV1{Flags: WirePacket_FlagsV1{Urgent: 1}, Header: &WireHeaderV1{Version: 2}, Message: &WirePingV1{Kind: 1, Seq: 7}}
+	data, err := packet.Encode()
+	fmt.Println(data, err)
+
+	// Exported decoders read a type in the middle of other data
+	decoder := runtime.NewBitStreamDecoder(data, runtime.MSBFirst)
+	decoder.ReadUint8()
+	header, err := DecodeWireHeaderV1WithDecoder(decoder)
+	fmt.Println(header.Version, err)
+	message, err := DecodeWireMessageV1WithDecoder(decoder)
+	fmt.Println(message.(*WirePingV1).Seq, err)
+
+	// JSON keeps the schema's variant names
+	text, _ := json.Marshal(packet)
+	fmt.Println(string(text))
+`)
+	require.Equal(t, `[128 2 1 0 7] <nil>
+2 <nil>
+7 <nil>
+{"flags":{"urgent":1,"spare":0},"header":{"version":2},"message":{"type":"Ping","value":{"kind":1,"seq":7}}}
+`, output)
+
+	for _, bad := range []struct {
+		opts GenerateOptions
+		want string
+	}{
+		{GenerateOptions{TypePrefix: "wire"}, `type prefix "wire" must be an identifier starting with an upper-case letter`},
+		{GenerateOptions{TypeSuffix: "-v1"}, `type suffix "-v1" must be letters, digits and underscores`},
+		{GenerateOptions{Receiver: "Packet"}, `receiver "Packet" must be an identifier starting with a lower-case letter`},
+		{GenerateOptions{Receiver: "encoder"}, `receiver "encoder" clashes with a name used in EncodeWithContext`},
+		{GenerateOptions{Receiver: "runtime"}, `receiver "runtime" clashes with a name used in`},
+	} {
+		_, err := GenerateGoWithOptions(schema, "Packet", bad.opts)
+		require.ErrorContains(t, err, bad.want)
+	}
+}
+
+func TestGenerateDocComments(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		meta: { title: "Sensor Protocol", version: "2.1", description: "Readings sent by field sensors" },
+		config: { endianness: "big_endian" },
+		types: {
+			"Reading": {
+				description: "One sample from a sensor",
+				sequence: [
+					{ name: "temperature", type: "int16", description: "Air temperature", metadata: { unit: "centi-degrees Celsius", min: -4000, max: 12500 } },
+					{ name: "battery", type: "uint8", metadata: { units: "%", max: 100 } },
+					{ name: "interval", type: "uint32", metadata: { range: [1, 86400] } },
+					{ name: "raw", type: "uint8" },
+				],
+			},
+		},
+	}`)
+	code, err := GenerateGo(schema, "Reading")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(code, `// Code generated by binschema `+GeneratorVersion+`. DO NOT EDIT.
+//
+// Schema: Sensor Protocol, version 2.1
+//
+// Readings sent by field sensors
+
+package main
+`), code)
+	require.Contains(t, code, `// Reading: One sample from a sensor
+type Reading struct {
+	// Air temperature
+	//
+	// Unit: centi-degrees Celsius.
+	// Valid range: -4000 to 12500.
+	Temperature int16
+	// Unit: %.
+	// Maximum: 100.
+	Battery uint8
+	// Valid range: 1 to 86400.
+	Interval uint32
+	Raw      uint8
+}`)
+}
+
+func TestGenerateDirection(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Ping": { sequence: [ { name: "kind", type: "uint8" }, { name: "seq", type: "uint16" } ] },
+			"Pong": { sequence: [ { name: "kind", type: "uint8" } ] },
+			"Message": { type: "discriminated_union", discriminator: { peek: "uint8" }, variants: [
+				{ when: "value == 1", type: "Ping" },
+				{ when: "value == 2", type: "Pong" },
+			] },
+			"Packet": { sequence: [
+				{ name: "id", type: "uint8" },
+				{ name: "message", type: "Message" },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGoWithOptions(schema, "Packet", GenerateOptions{Direction: EncodeOnly})
+	require.NoError(t, err)
+	require.Contains(t, code, "func (m *Packet) Encode() ([]byte, error) {")
+	require.Contains(t, code, "\tEncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error)\n")
+	require.NotContains(t, code, "Decode")
+	require.NotContains(t, code, "DumpAnnotated")
+	output := runGenerated(t, code, `
+	data, err := (&Packet{Id: 3, Message: &Ping{Kind: 1, Seq: 7}}).Encode()
+	fmt.Println(data, err)
+`)
+	require.Equal(t, "[3 1 0 7] <nil>\n", output)
+
+	code, err = GenerateGoWithOptions(schema, "Packet", GenerateOptions{Direction: DecodeOnly, ExportDecoders: true})
+	require.NoError(t, err)
+	require.Contains(t, code, "func DecodePacket(bytes []byte) (*Packet, error) {")
+	require.Contains(t, code, "func DecodeMessageWithDecoder(")
+	require.NotContains(t, code, "Encode")
+	output = runGenerated(t, code, `
+	packet, err := DecodePacket([]byte{3, 2, 1})
+	fmt.Println(packet.Id, packet.Message.(*Pong).Kind, err)
+`)
+	require.Equal(t, "3 2 <nil>\n", output)
+
+	for _, bad := range []struct {
+		opts GenerateOptions
+		want string
+	}{
+		{GenerateOptions{Direction: EncodeOnly, BinaryCodecs: true}, "binary codecs need both encoders and decoders"},
+		{GenerateOptions{Direction: DecodeOnly, SQL: true}, "SQL methods need both encoders and decoders"},
+		{GenerateOptions{Direction: 7}, "unknown direction 7"},
+	} {
+		_, err := GenerateGoWithOptions(schema, "Packet", bad.opts)
+		require.ErrorContains(t, err, bad.want)
+	}
+}
+
+func TestGenerateCasts(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "little_endian" },
+		types: {
+			"Point": { sequence: [ { name: "x", type: "float32" }, { name: "y", type: "float32" } ] },
+			"Record": { sequence: [
+				{ name: "id", type: "uint32" },
+				{ name: "temp", type: "int16" },
+				{ name: "flags", type: "uint8" },
+				{ name: "kind", type: "uint8" },
+				{ name: "value", type: "float64" },
+				{ name: "pos", type: "Point" },
+			] },
+			"Unaligned": { sequence: [ { name: "tag", type: "uint8" }, { name: "id", type: "uint32" } ] },
+			"Padded": { sequence: [ { name: "id", type: "uint32" }, { name: "tag", type: "uint8" } ] },
+			"Network": { sequence: [ { name: "id", type: "uint32", endianness: "big_endian" } ] },
+			"Named": { sequence: [ { name: "name", type: "string", kind: "length_prefixed", length_type: "uint8" } ] },
+		},
+	}`)
+	code, err := GenerateGoWithOptions(schema, "Record", GenerateOptions{CastTypes: []string{"Record"}})
+	require.NoError(t, err)
+	require.Contains(t, code, "\t_ = [1]struct{}{}[unsafe.Sizeof(Record{})-24]\n")
+	require.Contains(t, code, "\t_ = [1]struct{}{}[unsafe.Offsetof(Record{}.Pos)-16]\n")
+	require.Contains(t, code, "func CastRecordSlice(data []byte) ([]Record, error) {")
+	require.NotContains(t, code, "CastPoint")
+
+	aligned := `package main
+
+import "unsafe"
+
+// aligned returns n bytes of memory aligned to 8 bytes
+func aligned(n int) []byte {
+	buffer := make([]uint64, (n+7)/8)
+	return unsafe.Slice((*byte)(unsafe.Pointer(&buffer[0])), n)
+}
+`
+	output := runGeneratedFiles(t, "", map[string]string{"generated.go": code, "aligned.go": aligned}, `
+	records := []Record{
+		{Id: 1, Temp: -5, Flags: 2, Kind: 3, Value: 1.5, Pos: Point{X: 1, Y: 2}},
+		{Id: 2, Temp: 7, Value: -2, Pos: Point{X: 3, Y: 4}},
+	}
+	data, err := RecordSliceBytes(records)
+	fmt.Println(len(data), err)
+
+	// Casting matches decoding, and shares the bytes it views
+	encoded, _ := records[1].Encode()
+	fmt.Println(fmt.Sprint(encoded) == fmt.Sprint(data[24:]))
+	file := aligned(48)
+	copy(file, data)
+	view, err := CastRecordSlice(file)
+	fmt.Println(len(view), view[1].Pos.Y, view[0].Temp, err)
+	first, _ := CastRecord(file)
+	first.Id = 9
+	decoded, _ := DecodeRecord(file[:24])
+	fmt.Println(decoded.Id)
+
+	_, err = CastRecordSlice(file[:30])
+	fmt.Println(err)
+	_, err = CastRecord(file[1:])
+	fmt.Println(err)
+`)
+	require.Equal(t, `48 <nil>
+true
+2 4 -5 <nil>
+9
+30 bytes is not a whole number of 24-byte records
+cast needs data aligned to 8 bytes
+`, output)
+
+	for _, bad := range []struct {
+		name string
+		want string
+	}{
+		{"Unaligned", "cast type Unaligned: field id at byte 1 is not aligned to its 4 bytes"},
+		{"Padded", "cast type Padded: its 5 bytes are not a multiple of its 4-byte alignment"},
+		{"Network", "cast type Network: field id is not little-endian"},
+		{"Named", "cast type Named: not a struct of fixed-width fields only"},
+		{"Missing", "cast type Missing not found in schema"},
+	} {
+		_, err := GenerateGoWithOptions(schema, "Record", GenerateOptions{CastTypes: []string{bad.name}})
+		require.ErrorContains(t, err, bad.want)
+	}
+}
+
+func TestGenerateMappedDecoder(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Payload": { sequence: [ { name: "value", type: "uint16" } ] },
+			"Record": {
+				sequence: [ { name: "tag", type: "uint8" }, { name: "payload_offset", type: "uint8" } ],
+				instances: [ { name: "payload", type: "Payload", position: "payload_offset", size: 2 } ],
+			},
+		},
+	}`)
+	code, err := GenerateGoWithOptions(schema, "Record", GenerateOptions{ExportDecoders: true})
+	require.NoError(t, err)
+
+	tempFile := `package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// tempFile writes data to a file that is removed when main returns
+func tempFile(data []byte) (string, func()) {
+	dir, err := os.MkdirTemp("", "mapped")
+	if err != nil {
+		panic(err)
+	}
+	path := filepath.Join(dir, "records.bin")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		panic(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+`
+	output := runGeneratedFiles(t, "", map[string]string{"generated.go": code, "tempfile.go": tempFile}, `
+	// Two records, each with its payload elsewhere in the file
+	path, remove := tempFile([]byte{1, 6, 2, 4, 0, 20, 0, 10})
+	defer remove()
+	mapped, err := runtime.OpenMapped(path, runtime.MSBFirst)
+	if err != nil {
+		panic(err)
+	}
+	defer mapped.Close()
+	fmt.Println(mapped.Len())
+
+	records := runtime.NewMappedRecords(mapped, []int64{0, 2}, DecodeRecordWithDecoder)
+	for i := records.Len() - 1; i >= 0; i-- {
+		record, err := records.At(i)
+		fmt.Println(record, err)
+	}
+	record, err := runtime.DecodeAt(mapped, 6, DecodePayloadWithDecoder)
+	fmt.Println(record, err)
+
+	_, err = runtime.NewMappedRecords(mapped, []int64{7, 9}, DecodeRecordWithDecoder).At(1)
+	fmt.Println(err)
+	_, err = runtime.NewMappedRecords(mapped, []int64{7, 9}, DecodeRecordWithDecoder).At(0)
+	fmt.Println(err)
+`)
+	require.Equal(t, `8
+Record{tag: 2, payload_offset: 4, payload: Payload{value: 20}} <nil>
+Record{tag: 1, payload_offset: 6, payload: Payload{value: 10}} <nil>
+Payload{value: 10} <nil>
+record 1 at offset 9: offset 9 is outside the 8 bytes mapped
+record 0 at offset 7: unexpected end of stream
+`, output)
+}
+
+func TestGenerateOffsetTable(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Record": { sequence: [
+				{ name: "id", type: "uint8" },
+				{ name: "name", type: "string", kind: "length_prefixed", length_type: "uint8", encoding: "ascii" },
+			] },
+			"Table": {
+				sequence: [
+					{ name: "record_offsets", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "uint16" } },
+					{ name: "mark_offsets", type: "array", kind: "fixed", length: 2, items: { type: "uint8" } },
+				],
+				instances: [
+					{ name: "records", type: "array", items: { type: "Record" }, offsets: "record_offsets", alignment: 2 },
+					{ name: "marks", type: "array", items: { type: "uint8" }, offsets: "mark_offsets" },
+				],
+			},
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Table")
+	require.NoError(t, err)
+
+	output := runGenerated(t, code, `
+	encoded, err := (&Table{
+		Records: []Record{{Id: 1, Name: "ab"}, {Id: 2, Name: "c"}},
+		Marks:   []uint8{7, 8},
+	}).Encode()
+	fmt.Println(encoded, err)
+	table, err := DecodeTable(encoded)
+	fmt.Println(table, err)
+
+	// Items are read at their offsets, in the table's order
+	table, err = DecodeTable([]byte{2, 0, 12, 0, 8, 11, 16, 0, 2, 1, 'x', 5, 1, 2, 'a', 'b', 6})
+	fmt.Println(table, err)
+
+	_, err = DecodeTable([]byte{1, 0, 20, 0, 0})
+	fmt.Println(err)
+	_, err = DecodeTable([]byte{1, 0, 5, 0, 0, 0})
+	fmt.Println(err)
+	_, err = (&Table{Records: []Record{{Name: string(make([]byte, 250))}}, Marks: []uint8{7, 8}}).Encode()
+	fmt.Println(err)
+`)
+	require.Equal(t, `[2 0 8 0 12 15 16 0 1 2 97 98 2 1 99 7 8] <nil>
+Table{record_offsets: [8 12], mark_offsets: [15 16], records: [Record{id: 1, name: "ab"}, Record{id: 2, name: "c"}], marks: [7 8]} <nil>
+Table{record_offsets: [12 8], mark_offsets: [11 16], records: [Record{id: 1, name: "ab"}, Record{id: 2, name: "x"}], marks: [5 6]} <nil>
+records[0]: position 20 is outside the data
+records[0]: position 5 is not a multiple of 2
+marks[0]: position 258 doesn't fit in mark_offsets
+`, output)
+}
+
+func TestGenerateByteRanges(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Frame": { sequence: [
+				{ name: "magic", type: "uint8" },
+				{ name: "length", type: "uint16", computed: { type: "length_of", from: "kind", offset: 1 } },
+				{ name: "kind", type: "uint8" },
+				{ name: "payload", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "uint8" } },
+				{ name: "checksum", type: "uint32", computed: { type: "crc32_of" } },
+				{ name: "payloadCrc", type: "uint32", computed: { type: "crc32_of", target: "payload" } },
+				{ name: "headerLength", type: "uint8", computed: { type: "length_of", from: "magic", to: "length" } },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Frame")
+	require.NoError(t, err)
+	require.NotContains(t, code, "func (b *FrameBuilder) Checksum(")
+
+	output := runGenerated(t, code, `
+	// Whatever the struct holds, the computed fields are encoded from the bytes
+	encoded, err := (&Frame{Magic: 0xAA, Kind: 2, Payload: []uint8{1, 2, 3}, Checksum: 99, Length: 99}).Encode()
+	fmt.Println(encoded, err)
+	frame, err := DecodeFrame(encoded)
+	fmt.Println(frame.Length, frame.HeaderLength, err)
+
+	// The checksum covers the whole frame with itself zeroed
+	zeroed := append([]byte{}, encoded...)
+	copy(zeroed[8:12], []byte{0, 0, 0, 0})
+	fmt.Println(frame.Checksum == runtime.CRC32(zeroed), frame.PayloadCrc == runtime.CRC32([]byte{3, 1, 2, 3}))
+`)
+	require.Equal(t, `[170 0 15 2 3 1 2 3 214 121 187 89 153 12 41 253 3] <nil>
+15 3 <nil>
+true true
+`, output)
+
+	bad := parseTestSchema(t, `{ types: { "Frame": { sequence: [
+		{ name: "data", type: "uint8" },
+		{ name: "crc", type: "uint16", computed: { type: "crc32_of", from: "data" } },
+		{ name: "length", type: "uint8", computed: { type: "length_of", from: "length", to: "data" } },
+		{ name: "size", type: "uint8", computed: { type: "length_of", to: "tail" } },
+	] } } }`)
+	require.Equal(t, []string{
+		`error: types.Frame.sequence[1]: crc32_of needs a uint32 field, got "uint16"`,
+		`error: types.Frame.sequence[2]: length_of: range from "length" starts after its end "data"`,
+		`error: types.Frame.sequence[3]: length_of: no field "tail" in this struct`,
+	}, diagnosticStrings(ValidateSchema(bad)))
+}
+
+func TestGenerateGoTests(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Point": { sequence: [
+				{ name: "x", type: "int16" },
+				{ name: "y", type: "int16" },
+			] },
+			"Ping": { sequence: [
+				{ name: "kind", type: "uint8" },
+				{ name: "seq", type: "uint32" },
+			] },
+			"Text": { sequence: [
+				{ name: "kind", type: "uint8" },
+				{ name: "body", type: "string", kind: "length_prefixed", length_type: "uint8" },
+			] },
+			"Message": {
+				type: "discriminated_union",
+				discriminator: { peek: "uint8" },
+				variants: [
+					{ when: "value == 1", type: "Ping" },
+					{ when: "value == 2", type: "Text" },
+				],
+			},
+			"Packet": { sequence: [
+				{ name: "magic", type: "array", kind: "fixed", length: 2, items: { type: "uint8" } },
+				{ name: "count", type: "uint8" },
+				{ name: "points", type: "array", kind: "field_referenced", length_field: "count", items: { type: "Point" } },
+				{ name: "message", type: "Message" },
+			] },
+			"Span": { sequence: [
+				{ name: "min", type: "uint8" },
+				{ name: "max", type: "uint8" },
+				{ name: "values", type: "array", kind: "computed_count", count_expr: "max - min + 1", items: { type: "uint8" } },
+			] },
+		},
+	}`)
+
+	_, err := GenerateGoTests(schema, "Packet", GenerateOptions{Direction: DecodeOnly})
+	require.EqualError(t, err, "test stubs need both encoders and decoders")
+
+	code, err := GenerateGo(schema, "Packet")
+	require.NoError(t, err)
+	stubs, err := GenerateGoTests(schema, "Packet", GenerateOptions{})
+	require.NoError(t, err)
+
+	// The example's count is derived from the points it holds, and the message is a Ping
+	require.Contains(t, stubs, "m.Count = uint8(len(m.Points))")
+	require.Contains(t, stubs, "v.Kind = 1")
+	require.Contains(t, stubs, "// No zero value: magic has 2 items")
+	require.Contains(t, stubs, `// No example: the length of values is "max - min + 1"`)
+
+	dir := writeGeneratedModule(t, map[string]string{
+		"generated.go":      code,
+		"generated_test.go": stubs,
+		"main.go":           "package main\n\nfunc main() {}\n",
+	})
+	cmd := exec.Command("go", "test", "-v", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, "generated tests failed:\n%s\n--- tests ---\n%s", output, stubs)
+	for _, passed := range []string{"TestPointRoundTrip/zero", "TestPointRoundTrip/example", "TestPingRoundTrip/example", "TestTextRoundTrip/example", "TestPacketRoundTrip/example"} {
+		require.Contains(t, string(output), "--- PASS: "+passed)
+	}
+	// A zero Span counts one value, which it doesn't hold
+	require.Contains(t, string(output), "--- SKIP: TestSpanRoundTrip/zero")
+	require.NotContains(t, string(output), "TestPacketRoundTrip/zero")
+}
+
+func TestGenerateVersionDispatch(t *testing.T) {
+	v1 := parseTestSchema(t, `{
+		config: { endianness: "big_endian", magic: { field: "magic", value: 0x4253 }, version: { field: "header.version", value: 1 } },
+		types: {
+			"Header": { sequence: [ { name: "magic", type: "uint16" }, { name: "version", type: "uint8" } ] },
+			"Reading": { sequence: [
+				{ name: "magic", type: "uint16" },
+				{ name: "header", type: "Header" },
+				{ name: "celsius", type: "int8" },
+			] },
+		},
+	}`)
+	v2 := parseTestSchema(t, `{
+		config: { endianness: "big_endian", magic: { field: "magic", value: 0x4253 }, version: { field: "header.version", value: 2 } },
+		types: {
+			"Header": { sequence: [ { name: "magic", type: "uint16" }, { name: "version", type: "uint8" } ] },
+			"Reading": { sequence: [
+				{ name: "magic", type: "uint16" },
+				{ name: "header", type: "Header" },
+				{ name: "millicelsius", type: "int32" },
+				{ name: "sensor", type: "string", kind: "length_prefixed", length_type: "uint8" },
+			] },
+		},
+	}`)
+	versions := []SchemaVersion{
+		{Schema: v1, Options: GenerateOptions{TypePrefix: "V1", ExtractFields: true}},
+		{Schema: v2, Options: GenerateOptions{TypePrefix: "V2", ExtractFields: true}},
+	}
+	dispatch, err := GenerateVersionDispatch(versions, "Reading")
+	require.NoError(t, err)
+	// Both versions' code shares the package with the dispatch
+	files := map[string]string{"dispatch.go": dispatch}
+	for i, name := range []string{"generated.go", "v2.go"} {
+		code, err := GenerateGoWithOptions(versions[i].Schema, "Reading", versions[i].Options)
+		require.NoError(t, err)
+		files[name] = code
+	}
+
+	output := runGeneratedFiles(t, "", files, `
+	for _, m := range []ReadingMessage{
+		&V1Reading{Magic: 0x4253, Header: V1Header{Version: 1}, Celsius: -4},
+		&V2Reading{Magic: 0x4253, Header: V2Header{Version: 2}, Millicelsius: 21500, Sensor: "attic"},
+		&V2Reading{Magic: 0x4253, Header: V2Header{Version: 3}},
+		&V2Reading{Magic: 0x0000, Header: V2Header{Version: 2}},
+	} {
+		encoded, _ := m.Encode()
+		decoded, version, err := DecodeAnyReading(encoded)
+		fmt.Printf("%T %d %v\n", decoded, version, err)
+		if err == nil {
+			fmt.Println(decoded)
+		}
+	}
+	// The version matches, but the message is cut short
+	_, version, err := DecodeAnyReading([]byte{0x42, 0x53, 0, 0, 2, 0})
+	fmt.Println(version, err != nil)
+`)
+	require.Equal(t, `*main.V1Reading 1 <nil>
+`+"V1Reading{magic: 16979, header: V1Header{magic: 0, version: 1}, celsius: -4}"+`
+*main.V2Reading 2 <nil>
+`+`V2Reading{magic: 16979, header: V2Header{magic: 0, version: 2}, millicelsius: 21500, sensor: "attic"}`+`
+<nil> 0 Reading: no version's magic and version fields match
+<nil> 0 Reading: no version's magic and version fields match
+2 true
+`, output)
+
+	_, err = GenerateVersionDispatch([]SchemaVersion{versions[0], versions[0]}, "Reading")
+	require.EqualError(t, err, "versions 1 and 2 of Reading have the same magic and version")
+	noVersion := parseTestSchema(t, `{ types: { "Reading": { sequence: [ { name: "x", type: "uint8" } ] } } }`)
+	_, err = GenerateVersionDispatch([]SchemaVersion{{Schema: v1, Options: GenerateOptions{TypePrefix: "V1"}}}, "Reading")
+	require.EqualError(t, err, "version 1 of Reading: dispatching needs ExtractFields")
+	_, err = GenerateVersionDispatch([]SchemaVersion{{Schema: noVersion, Options: GenerateOptions{ExtractFields: true}}}, "Reading")
+	require.EqualError(t, err, "version 1 of Reading: the schema config has no version")
+	badField := parseTestSchema(t, `{ config: { version: { field: "name", value: 1 } }, types: { "Reading": { sequence: [
+		{ name: "name", type: "string", kind: "length_prefixed", length_type: "uint8" },
+	] } } }`)
+	_, err = GenerateVersionDispatch([]SchemaVersion{{Schema: badField, Options: GenerateOptions{ExtractFields: true}}}, "Reading")
+	require.EqualError(t, err, `version 1 of Reading: "name" is not an integer field`)
+}
+
+func TestGenerateGoNames(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Header": { sequence: [
+				{ name: "len", type: "uint8", go_name: "Size" },
+				{ name: "string", type: "uint8", go_name: "Str" },
+			] },
+			"Message": { sequence: [
+				{ name: "header", type: "Header" },
+				{ name: "type", type: "uint8", go_name: "Kind" },
+				{ name: "Type", type: "uint8" },
+				{ name: "func", type: "uint8" },
+				{ name: "range", type: "array", kind: "field_referenced", length_field: "header.len", items: { type: "uint8" } },
+				{ name: "map", type: "string", kind: "length_prefixed", length_type: "uint8" },
+				{ name: "err", type: "uint8", conditional: "type > 1" },
+				{ name: "decoder", type: "array", kind: "fixed", length: "type", items: { type: "uint8" } },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGo(schema, "Message")
+	require.NoError(t, err)
+	require.Contains(t, code, "Kind    uint8 `binschema:\"type\"`")
+	require.Contains(t, code, "type_, err := decoder.ReadUint8()")
+
+	// Keywords and generated names become locals of their own; go_name fields are
+	// found by the expressions that name them
+	output := runGenerated(t, code, `
+	m := &Message{Header: Header{Size: 2, Str: 7}, Kind: 2, Type: 3, Func: 4, Range: []uint8{5, 6}, Map: "go", Err: 9, Decoder: []uint8{1, 2}}
+	encoded, err := m.Encode()
+	fmt.Println(encoded, err)
+	decoded, err := DecodeMessage(encoded)
+	fmt.Println(decoded.Equal(m), err)
+`)
+	require.Equal(t, `[2 7 2 3 4 5 6 2 103 111 9 1 2] <nil>
+true <nil>
+`, output)
+
+	bad := parseTestSchema(t, `{ types: { "Message": { sequence: [
+		{ name: "first", type: "uint8", go_name: "kind" },
+		{ name: "kind", type: "uint8", go_name: "Type" },
+		{ name: "Type", type: "uint8" },
+		{ name: "string", type: "uint8", go_name: "Encode" },
+	] } } }`)
+	require.Equal(t, []string{
+		`error: types.Message.sequence[0]: go_name must be an exported Go identifier, got kind`,
+		`error: types.Message.sequence[2]: fields "kind" and "Type" both become Go field Type`,
+		`error: types.Message.sequence[3]: field "string" becomes Go field Encode, which clashes with the generated Encode method`,
+	}, diagnosticStrings(ValidateSchema(bad)))
+}
+
+func TestPascalCase(t *testing.T) {
+	acronyms := []string{"id", "URL", "crc"}
+	for _, tc := range []struct {
+		name, want, withAcronyms string
+	}{
+		{"data_offset", "DataOffset", "DataOffset"},
+		{"user_id", "UserId", "UserID"},
+		{"urlPath", "UrlPath", "URLPath"},
+		{"HTTPServer", "HTTPServer", "HTTPServer"},
+		{"header-crc32", "HeaderCrc32", "HeaderCrc32"},
+		{"header_crc", "HeaderCrc", "HeaderCRC"},
+		{"pattern_5555", "Pattern5555", "Pattern5555"},
+		{"_reserved", "Reserved", "Reserved"},
+		{"__1st", "X1st", "X1st"},
+		{"ñame_größe", "ÑameGröße", "ÑameGröße"},
+		{"日付", "X日付", "X日付"},
+	} {
+		require.Equal(t, tc.want, PascalCase(tc.name, nil), tc.name)
+		require.Equal(t, tc.withAcronyms, PascalCase(tc.name, acronyms), tc.name)
+	}
+
+	// FieldName writes id and url in capitals, as the TypeScript generator does
+	require.Equal(t, "UserID", FieldName("user_id"))
+	require.Equal(t, "URLPath", FieldName("urlPath"))
+	require.Equal(t, "HeaderCrc", FieldName("header_crc"))
+	require.Equal(t, "Identity", FieldName("identity"))
+
+	// Capitalizing keeps the rest of the name, and makes any name exported
+	require.Equal(t, "Data_offset", capitalizeFirst("data_offset"))
+	require.Equal(t, "Ñame", capitalizeFirst("ñame"))
+	require.Equal(t, "X_reserved", capitalizeFirst("_reserved"))
+	require.Equal(t, "X日付", capitalizeFirst("日付"))
+}
+
+func TestGeneratePascalCaseFields(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "big_endian" },
+		types: {
+			"Header": { sequence: [
+				{ name: "payload_len", type: "uint8" },
+				{ name: "user_id", type: "uint16" },
+			] },
+			"Record": { sequence: [
+				{ name: "header", type: "Header" },
+				{ name: "payload", type: "array", kind: "field_referenced", length_field: "header.payload_len", items: { type: "uint8" } },
+				{ name: "größe", type: "uint8", conditional: "header.user_id > 1" },
+				{ name: "_reserved", type: "uint8" },
+				{ name: "crc_value", type: "uint8", go_name: "Check" },
+			] },
+		},
+	}`)
+
+	code, err := GenerateGoWithOptions(schema, "Record", GenerateOptions{PascalCase: true, Acronyms: []string{"id"}})
+	require.NoError(t, err)
+	require.Contains(t, code, "UserID     uint16 `binschema:\"user_id\"`")
+	require.Contains(t, code, "Größe    uint8\n")
+
+	output := runGenerated(t, code, `
+	r := &Record{Header: Header{PayloadLen: 2, UserID: 7}, Payload: []uint8{1, 2}, Größe: 3, Reserved: 4, Check: 5}
+	encoded, err := r.Encode()
+	fmt.Println(encoded, err)
+	decoded, err := DecodeRecord(encoded)
+	fmt.Println(decoded.Equal(r), err)
+`)
+	require.Equal(t, `[2 0 7 1 2 3 4 5] <nil>
+true <nil>
+`, output)
+
+	// Without PascalCase, names are capitalized, even those that would stay unexported
+	code, err = GenerateGo(schema, "Record")
+	require.NoError(t, err)
+	require.Contains(t, code, "User_id     uint16\n")
+	require.Contains(t, code, "X_reserved uint8\n")
+
+	clash := parseTestSchema(t, `{ types: { "Record": { sequence: [
+		{ name: "user_id", type: "uint8" },
+		{ name: "userId", type: "uint8" },
+	] } } }`)
+	_, err = GenerateGoWithOptions(clash, "Record", GenerateOptions{PascalCase: true})
+	require.ErrorContains(t, err, `Record: fields "user_id" and "userId" both become Go field UserID`)
+	_, err = GenerateGoWithOptions(clash, "Record", GenerateOptions{Acronyms: []string{"id"}})
+	require.ErrorContains(t, err, "acronyms only apply to PascalCase field names")
+	_, err = GenerateGoWithOptions(clash, "Record", GenerateOptions{PascalCase: true, Acronyms: []string{"i-d"}})
+	require.ErrorContains(t, err, `acronym "i-d" must be letters and digits starting with a letter`)
+}
@@ -0,0 +1,242 @@
+// ABOUTME: Go identifiers for schema names: capitalized or PascalCase fields, go_name, keyword-safe locals
+// ABOUTME: A field named type decodes into type_ and is stored in Type, so any identifier compiles
+package codegen
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Names generated code declares or uses in the functions that decode and encode fields,
+// besides Go's own predeclared identifiers, which a field's local can't shadow
+var generatedLocals = attributeSet(
+	"bytes", "ctx", "childCtx", "decoder", "encoder", "err", "fmt", "m", "math", "parent",
+	"result", "runtime", "strings", "time", "traceEnabled", "utf8",
+)
+
+// Go's predeclared identifiers, which generated code converts and calls with
+var predeclared = attributeSet(
+	"any", "append", "bool", "byte", "cap", "clear", "close", "comparable", "complex",
+	"complex64", "complex128", "copy", "delete", "error", "false", "float32", "float64",
+	"imag", "int", "int8", "int16", "int32", "int64", "iota", "len", "make", "max", "min",
+	"new", "nil", "panic", "print", "println", "real", "recover", "rune", "string", "true",
+	"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+)
+
+// capitalizeFirst upper-cases the first letter of a schema name, making it an exported Go
+// name. A name that stays unexported, one starting with an underscore or a letter
+// without case, is prefixed with X ("_reserved" is X_reserved).
+func capitalizeFirst(s string) string {
+	if s == "" {
+		return ""
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if upper := unicode.ToUpper(r); unicode.IsUpper(upper) {
+		return string(upper) + s[size:]
+	}
+	return "X" + s
+}
+
+// defaultAcronyms are the words PascalCase field names are written with in capitals when
+// GenerateOptions names no Acronyms
+var defaultAcronyms = []string{"id", "url"}
+
+// FieldName converts a schema field name to the Go name PascalCase fields get with the
+// default acronyms: "user_id" is UserID. The TypeScript generator names fields the same
+// way, and the test harness builds its values with it.
+func FieldName(name string) string {
+	return PascalCase(name, defaultAcronyms)
+}
+
+// PascalCase converts a snake_case, kebab-case or camelCase schema name to an exported Go
+// name, joining its words with the first letter of each upper-cased: "data_offset" is
+// DataOffset. Words that are acronyms, compared in lower case, are written in capitals:
+// with "id" and "url", "user_id" is UserID and "urlPath" URLPath.
+func PascalCase(name string, acronyms []string) string {
+	upper := make(map[string]bool, len(acronyms))
+	for _, acronym := range acronyms {
+		upper[strings.ToLower(acronym)] = true
+	}
+	var out strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' }) {
+		for _, word := range camelWords(part) {
+			if upper[strings.ToLower(word)] {
+				out.WriteString(strings.ToUpper(word))
+				continue
+			}
+			r, size := utf8.DecodeRuneInString(word)
+			out.WriteRune(unicode.ToUpper(r))
+			out.WriteString(word[size:])
+		}
+	}
+	return capitalizeFirst(out.String())
+}
+
+// camelWords splits a camelCase name into its words, keeping runs of capitals together:
+// "urlPath" is url, Path and "HTTPServer" HTTP, Server
+func camelWords(s string) []string {
+	runes := []rune(s)
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		if !unicode.IsUpper(runes[i]) {
+			continue
+		}
+		prev := runes[i-1]
+		if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(words, string(runes[start:]))
+}
+
+// nameFields gives every field without a go_name its PascalCase Go name when opts ask
+// for them, failing if two fields of a struct or a field and a generated method end up
+// with the same name
+func nameFields(schema *Schema, opts GenerateOptions) error {
+	if !opts.PascalCase {
+		return nil
+	}
+	acronyms := opts.Acronyms
+	if len(acronyms) == 0 {
+		acronyms = defaultAcronyms
+	}
+	names := make([]string, 0, len(schema.Types))
+	for name := range schema.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, typeName := range names {
+		if err := nameStructFields(typeName, schema.Types[typeName].fieldPointers(), acronyms); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nameStructFields names the fields of one struct, and those of its inline structs
+func nameStructFields(where string, fields []*Field, acronyms []string) error {
+	goNames := make(map[string]string)
+	for _, field := range fields {
+		if field.GoName == "" {
+			field.GoName = PascalCase(field.Name, acronyms)
+		}
+		if other, ok := goNames[field.GoName]; ok {
+			return fmt.Errorf("%s: fields %q and %q both become Go field %s", where, other, field.Name, field.GoName)
+		}
+		if generatedMethods[field.GoName] {
+			return fmt.Errorf("%s: field %q becomes Go field %s, which clashes with the generated %s method", where, field.Name, field.GoName, field.GoName)
+		}
+		goNames[field.GoName] = field.Name
+		if len(field.Fields) > 0 {
+			inline := make([]*Field, len(field.Fields))
+			for i := range field.Fields {
+				inline[i] = &field.Fields[i]
+			}
+			if err := nameStructFields(where+"."+field.Name, inline, acronyms); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// goName is the Go struct field holding the field: its go_name, or its capitalized name
+func (f Field) goName() string {
+	if f.GoName != "" {
+		return f.GoName
+	}
+	return capitalizeFirst(f.Name)
+}
+
+// localName is the local variable a field is decoded into: its Go name lowercased, with
+// an underscore after a keyword or a name generated code uses ("type" is decoded into
+// type_), so fields told apart by go_name get locals of their own
+func (f Field) localName() string {
+	return unreserved(strings.ToLower(f.goName()))
+}
+
+// unreserved appends an underscore to a keyword or a name generated code uses
+func unreserved(name string) string {
+	if token.IsKeyword(name) || predeclared[name] || generatedLocals[name] {
+		return name + "_"
+	}
+	return name
+}
+
+// goPath is the Go selector of a local dotted path one of the field's expressions reads
+// ("header.type" is Header.Type): markGoPaths resolves the paths through fields with a
+// go_name, the rest name their fields capitalized
+func (f Field) goPath(path string) string {
+	if goPath, ok := f.GoPaths[path]; ok {
+		return goPath
+	}
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		segments[i] = capitalizeFirst(segment)
+	}
+	return strings.Join(segments, ".")
+}
+
+// markGoPaths sets GoPaths on every field whose expressions, condition or offsets read
+// a local path through a field with a go_name
+func markGoPaths(schema *Schema) {
+	for _, typeDef := range schema.Types {
+		for _, field := range typeDef.fieldPointers() {
+			for _, f := range []*Field{field, field.Items} {
+				if f == nil {
+					continue
+				}
+				refs := expressionFields(*f)
+				if parts := strings.Fields(f.Conditional); len(parts) > 0 {
+					refs = append(refs, parts[0])
+				}
+				if f.Offsets != "" {
+					refs = append(refs, f.Offsets)
+				}
+				for _, ref := range refs {
+					if strings.HasPrefix(ref, "../") {
+						continue
+					}
+					goPath, ok := resolveGoPath(schema, typeDef, ref)
+					if !ok || goPath == f.goPath(ref) {
+						continue
+					}
+					if f.GoPaths == nil {
+						f.GoPaths = make(map[string]string)
+					}
+					f.GoPaths[ref] = goPath
+				}
+			}
+		}
+	}
+}
+
+// resolveGoPath returns the Go selector of the field a dotted path names, through nested
+// structs, or false if it names none
+func resolveGoPath(schema *Schema, typeDef *TypeDef, path string) (string, bool) {
+	var goPath []string
+	for _, segment := range strings.Split(path, ".") {
+		if typeDef == nil {
+			return "", false
+		}
+		var found *Field
+		for _, field := range typeDef.allFields() {
+			if field.Name == segment {
+				found = &field
+				break
+			}
+		}
+		if found == nil {
+			return "", false
+		}
+		goPath = append(goPath, found.goName())
+		typeDef = schema.Types[found.Type]
+	}
+	return strings.Join(goPath, "."), true
+}
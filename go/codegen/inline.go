@@ -0,0 +1,70 @@
+// ABOUTME: Hoists inline field groups (bitfields and anonymous structs) into named types
+// ABOUTME: A group "flags" in type Packet becomes type Packet_Flags, like hand-written DnsMessage_Flags
+package codegen
+
+import (
+	"fmt"
+	"sort"
+)
+
+// hoistInlineStructs gives every inline group a generated type named <Parent>_<Field>
+// and points the field at it. Groups inside hoisted types are hoisted in turn, so a
+// bitfield inside struct "header" of Packet becomes Packet_Header_Flags.
+//
+// Byte-aligned groups ("struct") become ordinary nested types. Bitfield groups
+// become types of "bit" fields that are flagged so parents read and write their
+// subfields in place rather than through a byte-aligned Encode/Decode.
+func hoistInlineStructs(schema *Schema) error {
+	names := make([]string, 0, len(schema.Types))
+	for name := range schema.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := hoistFields(schema, name, schema.Types[name].Sequence); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hoistFields(schema *Schema, parent string, fields []Field) error {
+	for i := range fields {
+		field := &fields[i]
+		// Inline groups as array items are named after the array field
+		if field.Type == "array" && field.Items != nil {
+			field = field.Items
+		}
+		if field.Type != "bitfield" && field.Type != "struct" && field.Type != "flags" {
+			continue
+		}
+
+		name := parent + "_" + capitalizeFirst(fields[i].Name)
+		if _, exists := schema.Types[name]; exists {
+			return fmt.Errorf("inline %s %s.%s becomes type %s, which is already defined", field.Type, parent, fields[i].Name, name)
+		}
+
+		hoisted := &TypeDef{Sequence: field.Fields, Description: field.Description, BitOrder: schema.Types[parent].BitOrder}
+		if field.Type == "flags" {
+			hoisted = &TypeDef{Flags: field.FlagValues, Repr: field.Repr, Description: field.Description}
+		}
+		if field.Type == "bitfield" {
+			hoisted.Bitfield = true
+			for j := range hoisted.Sequence {
+				hoisted.Sequence[j].Type = "bit"
+				if hoisted.Sequence[j].Signed {
+					hoisted.Sequence[j].Type = "int"
+				}
+			}
+			field.Bitfield = true
+		}
+		schema.Types[name] = hoisted
+		field.Type = name
+
+		if err := hoistFields(schema, name, hoisted.Sequence); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+// ABOUTME: Instance fields: values stored at a position in the data instead of in sequence
+// ABOUTME: Decoders seek to each position and back; encoders append instances and fill in their offset fields
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// checkInstances rejects instances of inline types, which have no Go type to be
+// decoded into or encoded from
+func checkInstances(schema *Schema) error {
+	for _, name := range typeOrder(schema) {
+		for _, field := range schema.Types[name].Instances {
+			if field.Type == "" {
+				return fmt.Errorf("instance %s: only named types are supported, not inline types", field.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// generateDecodeInstances emits the decoding of a type's instances, after its sequence.
+// Positions are absolute in the decoder's data, so they work the same in nested types.
+func generateDecodeInstances(buf *bytes.Buffer, typeDef *TypeDef, defaultEndianness string) error {
+	if len(typeDef.Instances) == 0 {
+		return nil
+	}
+	buf.WriteString("\t// Instances are read at their positions, then decoding carries on after the sequence\n")
+	buf.WriteString("\tsequenceEnd := decoder.Position()\n\n")
+
+	for _, field := range typeDef.Instances {
+		fieldName := field.goName()
+		varName := field.localName()
+		endianness := field.Endianness
+		if endianness == "" {
+			endianness = defaultEndianness
+		}
+		if field.Offsets != "" {
+			if err := generateDecodeOffsetTable(buf, field, endianness); err != nil {
+				return err
+			}
+			continue
+		}
+
+		positionVar := varName + "_instance_position"
+		switch position := field.Position.(type) {
+		case float64:
+			if position < 0 {
+				// Negative positions count back from the end of the data
+				buf.WriteString(fmt.Sprintf("\t%s := int64(decoder.Len()) - %d\n", positionVar, int64(-position)))
+			} else {
+				buf.WriteString(fmt.Sprintf("\t%s := int64(%d)\n", positionVar, int64(position)))
+			}
+		case string:
+			if err := generateIntExpression(buf, field, "position", position, "result", positionVar, "\t"); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("instance %s: position must be a number or an expression", field.Name)
+		}
+		buf.WriteString(fmt.Sprintf("\tif %s < 0 || %s > int64(decoder.Len()) {\n", positionVar, positionVar))
+		buf.WriteString(fmt.Sprintf("\t\treturn nil, fmt.Errorf(\"%s: position %%d is outside the data\", %s)\n", field.Name, positionVar))
+		buf.WriteString("\t}\n")
+
+		if field.InstanceSize != nil {
+			sizeVar := varName + "_instance_size"
+			switch size := field.InstanceSize.(type) {
+			case float64:
+				buf.WriteString(fmt.Sprintf("\t%s := int64(%d)\n", sizeVar, int64(size)))
+			case string:
+				if err := generateIntExpression(buf, field, "size", size, "result", sizeVar, "\t"); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("instance %s: size must be a number or an expression", field.Name)
+			}
+			buf.WriteString(fmt.Sprintf("\tif %s+%s > int64(decoder.Len()) {\n", positionVar, sizeVar))
+			buf.WriteString(fmt.Sprintf("\t\treturn nil, fmt.Errorf(\"%s: %%d bytes at position %%d run past the end of the data\", %s, %s)\n", field.Name, sizeVar, positionVar))
+			buf.WriteString("\t}\n")
+		}
+
+		if field.Alignment > 1 {
+			buf.WriteString(fmt.Sprintf("\tif %s%%%d != 0 {\n", positionVar, field.Alignment))
+			buf.WriteString(fmt.Sprintf("\t\treturn nil, fmt.Errorf(\"%s: position %%d is not a multiple of %d\", %s)\n", field.Name, field.Alignment, positionVar))
+			buf.WriteString("\t}\n")
+		}
+
+		buf.WriteString(fmt.Sprintf("\tdecoder.Seek(int(%s))\n", positionVar))
+		if err := generateTracedDecodeField(buf, field, fieldName, varName, endianness, mapEndianness(endianness), "\t"); err != nil {
+			return err
+		}
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString("\tdecoder.Seek(sequenceEnd)\n")
+	return nil
+}
+
+// generateDecodeOffsetTable emits the decoding of an array instance whose items are at
+// the positions of an offsets array decoded before it: one item at each
+func generateDecodeOffsetTable(buf *bytes.Buffer, field Field, endianness string) error {
+	if field.Type != "array" || field.Items == nil {
+		return fmt.Errorf("instance %s: offsets locate the items of an array, not a %s", field.Name, field.Type)
+	}
+	fieldName := field.goName()
+	varName := field.localName()
+	offsets := "result." + field.goPath(field.Offsets)
+
+	buf.WriteString(fmt.Sprintf("\t// %s: an item at each offset in %s\n", field.Name, field.Offsets))
+	writeTraceBegin(buf, fmt.Sprintf("TraceEnter(%q)", field.Name), "\t")
+	buf.WriteString(fmt.Sprintf("\tresult.%s = runtime.Reuse(decoder.Arena, result.%s, len(%s))\n", fieldName, fieldName, offsets))
+	buf.WriteString(fmt.Sprintf("\tfor i := range result.%s {\n", fieldName))
+	buf.WriteString(fmt.Sprintf("\t\tif uint64(%s[i]) > uint64(decoder.Len()) {\n", offsets))
+	buf.WriteString(fmt.Sprintf("\t\t\treturn nil, fmt.Errorf(\"%s[%%d]: position %%d is outside the data\", i, %s[i])\n", field.Name, offsets))
+	buf.WriteString("\t\t}\n")
+	if field.Alignment > 1 {
+		buf.WriteString(fmt.Sprintf("\t\tif %s[i]%%%d != 0 {\n", offsets, field.Alignment))
+		buf.WriteString(fmt.Sprintf("\t\t\treturn nil, fmt.Errorf(\"%s[%%d]: position %%d is not a multiple of %d\", i, %s[i])\n", field.Name, field.Alignment, offsets))
+		buf.WriteString("\t\t}\n")
+	}
+	buf.WriteString(fmt.Sprintf("\t\tdecoder.Seek(int(%s[i]))\n", offsets))
+	writeTraceBegin(buf, "TraceEnterItem(i)", "\t\t")
+	if decodesInPlace(*field.Items) {
+		if err := generateDecodeItemInPlace(buf, field, fieldName, "\t"); err != nil {
+			return err
+		}
+	} else {
+		itemVar := varName + "_item"
+		if err := generateDecodeFieldImpl(buf, *field.Items, "", itemVar, endianness, mapEndianness(endianness), "\t\t"); err != nil {
+			return err
+		}
+		writeTraceEnd(buf, itemVar, "\t\t")
+		buf.WriteString(fmt.Sprintf("\t\tresult.%s[i] = %s\n", fieldName, itemVar))
+		buf.WriteString("\t}\n")
+	}
+	writeTraceEnd(buf, "result."+fieldName, "\t")
+	buf.WriteString("\n")
+	return nil
+}
+
+// generateEncodeInstances emits EncodeWithContext for a type with instances; the
+// sequence itself is written by encodeSequence. Instances are appended after the
+// sequence, in order. An instance at a fixed position is padded to it; one whose
+// position is a sequence field gets that field set to where it lands, which takes a
+// second pass over the sequence; so does an array located by an offsets array, whose
+// items are appended one after another.
+func generateEncodeInstances(buf *bytes.Buffer, typeName string, typeDef *TypeDef, defaultEndianness string) error {
+	if defaultEndianness == "dynamic" {
+		return fmt.Errorf("%s: instances are not supported with dynamic endianness", typeName)
+	}
+	recv := typeDef.receiver()
+	buf.WriteString(fmt.Sprintf("func (%s *%s) EncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error) {\n", recv, typeName))
+
+	// Instances at positions the encoder can't choose (from the end, computed) are decode-only
+	for _, field := range typeDef.Instances {
+		if _, err := instancePlacement(typeDef, field); err != nil {
+			buf.WriteString(fmt.Sprintf("\treturn nil, fmt.Errorf(\"%s: %%s\", %q)\n", typeName, err.Error()))
+			buf.WriteString("}\n\n")
+			return nil
+		}
+	}
+
+	buf.WriteString("\t// Instances go after the sequence. Position fields don't change its size, so the\n")
+	buf.WriteString("\t// sequence is encoded once to place the instances and again with their positions.\n")
+	buf.WriteString(fmt.Sprintf("\tplaced := *%s\n", recv))
+	for _, field := range typeDef.Instances {
+		// Offsets arrays get an entry for each item before the sequence is sized
+		if table, _ := instancePlacement(typeDef, field); table.table {
+			buf.WriteString(fmt.Sprintf("\tplaced.%s = make([]%s, len(%s.%s))\n", table.field.goName(), table.goType, recv, field.goName()))
+		}
+	}
+	buf.WriteString("\tsequence, err := placed.encodeSequence(ctx)\n")
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\treturn nil, err\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString(fmt.Sprintf("\tencoder := runtime.NewBitStreamEncoder(%s)\n", runtimeBitOrder(typeDef.BitOrder)))
+	buf.WriteString("\tencoder.SetLimit(ctx.At(len(sequence)).OutputRemaining())\n\n")
+	generateEncodeChildContext(buf, typeDef, typeDef.Instances)
+
+	for _, field := range typeDef.Instances {
+		endianness := field.Endianness
+		if endianness == "" {
+			endianness = defaultEndianness
+		}
+		positionVar := strings.ToLower(field.Name) + "_instance_position"
+		placement, _ := instancePlacement(typeDef, field)
+		if placement.table {
+			if err := generateEncodeOffsetTable(buf, field, recv, placement, endianness); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if field.Alignment > 1 {
+			buf.WriteString(fmt.Sprintf("\tfor (len(sequence)+encoder.Position())%%%d != 0 {\n", field.Alignment))
+			buf.WriteString("\t\tencoder.WriteUint8(0)\n")
+			buf.WriteString("\t}\n")
+		}
+		if placement.fixed >= 0 {
+			buf.WriteString(fmt.Sprintf("\tif len(sequence)+encoder.Position() > %d {\n", placement.fixed))
+			buf.WriteString(fmt.Sprintf("\t\treturn nil, fmt.Errorf(\"%s: position %d is already taken by the data before it\")\n", field.Name, placement.fixed))
+			buf.WriteString("\t}\n")
+			buf.WriteString(fmt.Sprintf("\tfor len(sequence)+encoder.Position() < %d {\n", placement.fixed))
+			buf.WriteString("\t\tencoder.WriteUint8(0)\n")
+			buf.WriteString("\t}\n")
+		} else {
+			buf.WriteString(fmt.Sprintf("\t%s := len(sequence) + encoder.Position()\n", positionVar))
+			if wideIntegers[placement.goType] {
+				goType, _ := mapTypeToGo(placement.field)
+				buf.WriteString(fmt.Sprintf("\tplaced.%s = %s{Lo: uint64(%s)}\n", placement.field.goName(), goType, positionVar))
+			} else {
+				buf.WriteString(fmt.Sprintf("\tif uint64(%s) > %s {\n", positionVar, placement.max))
+				buf.WriteString(fmt.Sprintf("\t\treturn nil, fmt.Errorf(\"%s: position %%d doesn't fit in %s\", %s)\n", field.Name, placement.field.Name, positionVar))
+				buf.WriteString("\t}\n")
+				buf.WriteString(fmt.Sprintf("\tplaced.%s = %s(%s)\n", placement.field.goName(), placement.goType, positionVar))
+			}
+		}
+		if err := generateEncodeFieldImpl(buf, field, recv+"."+field.goName(), endianness, mapEndianness(endianness), "\t"); err != nil {
+			return err
+		}
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString("\tplacedSequence, err := placed.encodeSequence(ctx)\n")
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\treturn nil, err\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tif len(placedSequence) != len(sequence) {\n")
+	buf.WriteString(fmt.Sprintf("\t\treturn nil, fmt.Errorf(\"%s: sequence changed size when instance positions were filled in\")\n", typeName))
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tinstances, err := encoder.Result()\n")
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\treturn nil, err\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn append(placedSequence, instances...), nil\n")
+	buf.WriteString("}\n\n")
+	return nil
+}
+
+// placement is where the encoder puts an instance: at a fixed position, or wherever it
+// lands with a sequence field recording it
+type placement struct {
+	fixed  int    // Fixed position, or -1
+	field  Field  // Sequence field holding the position
+	goType string // Go type of that field, or of its items for a table
+	max    string // Largest position it holds
+	table  bool   // The field is an offsets array holding the position of each item of the instance
+}
+
+// Largest value of each unsigned type a position field may have. 128-bit fields hold
+// any position an int does.
+var positionFieldMax = map[string]string{"uint8": "0xff", "uint16": "0xffff", "uint32": "0xffffffff", "uint64": "0xffffffffffffffff",
+	"uint128": "", "int128": ""}
+
+func instancePlacement(typeDef *TypeDef, field Field) (placement, error) {
+	if field.Offsets != "" {
+		for _, seq := range typeDef.Sequence {
+			if seq.Name != field.Offsets || seq.Type != "array" || seq.Items == nil || seq.Conditional != "" {
+				continue
+			}
+			if max := positionFieldMax[seq.Items.Type]; max != "" {
+				return placement{fixed: -1, field: seq, goType: seq.Items.Type, max: max, table: true}, nil
+			}
+		}
+		return placement{}, fmt.Errorf("instance %s is at the offsets in %q, not an array of unsigned integers of the sequence, and can only be decoded", field.Name, field.Offsets)
+	}
+	switch position := field.Position.(type) {
+	case float64:
+		if position >= 0 {
+			return placement{fixed: int(position)}, nil
+		}
+		return placement{}, fmt.Errorf("instance %s counts back from the end of the data and can only be decoded", field.Name)
+	case string:
+		for _, seq := range typeDef.Sequence {
+			if seq.Name != position {
+				continue
+			}
+			max, ok := positionFieldMax[seq.Type]
+			if !ok || seq.Conditional != "" {
+				break
+			}
+			return placement{fixed: -1, field: seq, goType: seq.Type, max: max}, nil
+		}
+		return placement{}, fmt.Errorf("instance %s is at %q, not an unsigned integer field of the sequence, and can only be decoded", field.Name, position)
+	}
+	return placement{}, fmt.Errorf("instance %s has no position", field.Name)
+}
+
+// generateEncodeOffsetTable emits the encoding of an array instance located by an
+// offsets array: the items are appended one after another, each entry of the offsets
+// array set to where its item landed
+func generateEncodeOffsetTable(buf *bytes.Buffer, field Field, recv string, table placement, endianness string) error {
+	fieldName := field.goName()
+	offsets := "placed." + table.field.goName()
+	positionVar := strings.ToLower(field.Name) + "_instance_position"
+
+	buf.WriteString(fmt.Sprintf("\t// %s: each item is appended, and %s records where\n", field.Name, table.field.Name))
+	buf.WriteString(fmt.Sprintf("\tfor i, %s_item := range %s.%s {\n", strings.ToLower(field.Name), recv, fieldName))
+	if field.Alignment > 1 {
+		buf.WriteString(fmt.Sprintf("\t\tfor (len(sequence)+encoder.Position())%%%d != 0 {\n", field.Alignment))
+		buf.WriteString("\t\t\tencoder.WriteUint8(0)\n")
+		buf.WriteString("\t\t}\n")
+	}
+	buf.WriteString(fmt.Sprintf("\t\t%s := len(sequence) + encoder.Position()\n", positionVar))
+	buf.WriteString(fmt.Sprintf("\t\tif uint64(%s) > %s {\n", positionVar, table.max))
+	buf.WriteString(fmt.Sprintf("\t\t\treturn nil, fmt.Errorf(\"%s[%%d]: position %%d doesn't fit in %s\", i, %s)\n", field.Name, table.field.Name, positionVar))
+	buf.WriteString("\t\t}\n")
+	buf.WriteString(fmt.Sprintf("\t\t%s[i] = %s(%s)\n", offsets, table.goType, positionVar))
+	if err := generateEncodeFieldImpl(buf, *field.Items, strings.ToLower(field.Name)+"_item", endianness, mapEndianness(endianness), "\t\t"); err != nil {
+		return err
+	}
+	buf.WriteString("\t}\n\n")
+	return nil
+}
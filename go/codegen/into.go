@@ -0,0 +1,93 @@
+// ABOUTME: DecodeXInto: decoding into a caller's value, reusing the capacity of its slices
+// ABOUTME: Nested structs and struct array items are decoded in place instead of allocated
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// generateDecodeInto emits the public DecodeXInto
+func generateDecodeInto(buf *bytes.Buffer, typeName string, typeDef *TypeDef) {
+	buf.WriteString(fmt.Sprintf("// Decode%sInto decodes bytes into out, reusing the capacity of its slices, so a loop\n", typeName))
+	buf.WriteString("// decoding into the same value stops allocating once they have grown. Values taken\n")
+	buf.WriteString("// from out before the call may be overwritten; on error out is partly decoded.\n")
+	buf.WriteString(fmt.Sprintf("func Decode%sInto(bytes []byte, out *%s) error {\n", typeName, typeName))
+	writeDecodeInto(buf, typeName, typeDef, "bytes", "out")
+	buf.WriteString("}\n\n")
+}
+
+// writeDecodeInto emits the body of DecodeXInto, decoding bytesVar into out, for the
+// methods that decode into their receiver whether DecodeXInto is generated or not
+func writeDecodeInto(buf *bytes.Buffer, typeName string, typeDef *TypeDef, bytesVar, out string) {
+	buf.WriteString(fmt.Sprintf("\tdecoder := runtime.NewBitStreamDecoder(%s, %s)\n", bytesVar, runtimeBitOrder(typeDef.BitOrder)))
+	buf.WriteString(fmt.Sprintf("\tif _, err := decode%sInto(decoder, nil, %s); err != nil {\n", typeName, out))
+	buf.WriteString("\t\treturn err\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn nil\n")
+}
+
+// generateDecodeWithArena emits the public DecodeXWithArena
+func generateDecodeWithArena(buf *bytes.Buffer, typeName string, typeDef *TypeDef) {
+	buf.WriteString(fmt.Sprintf("// Decode%sWithArena decodes bytes taking the structs and slices it needs from arena,\n", typeName))
+	buf.WriteString("// so the result is only valid until the arena is reset\n")
+	buf.WriteString(fmt.Sprintf("func Decode%sWithArena(bytes []byte, arena *runtime.DecodeArena) (*%s, error) {\n", typeName, typeName))
+	buf.WriteString(fmt.Sprintf("\tdecoder := runtime.AcquireDecoder(bytes, %s)\n", runtimeBitOrder(typeDef.BitOrder)))
+	buf.WriteString("\tdefer runtime.ReleaseDecoder(decoder)\n")
+	buf.WriteString("\tdecoder.Arena = arena\n")
+	buf.WriteString(fmt.Sprintf("\treturn decode%sWithDecoder(decoder, nil)\n", typeName))
+	buf.WriteString("}\n\n")
+}
+
+// generateResetInto emits the reset of result to its zero value, except for what is
+// decoded again whatever the data: the slices of arrays, emptied but keeping their
+// capacity, and structs held by value, which reset themselves. Protobuf messages may
+// leave out any field, so their structs are reset too.
+func generateResetInto(buf *bytes.Buffer, typeName string, typeDef *TypeDef) {
+	var kept []string
+	for _, field := range typeDef.Sequence {
+		if field.Conditional != "" || field.Optional {
+			continue
+		}
+		fieldName := field.goName()
+		if field.Lazy {
+			continue
+		}
+		if field.Type == "array" {
+			kept = append(kept, fmt.Sprintf("%s: result.%s[:0]", fieldName, fieldName))
+		} else if decodesInPlace(field) && !typeDef.Protobuf {
+			kept = append(kept, fmt.Sprintf("%s: result.%s", fieldName, fieldName))
+		}
+	}
+	buf.WriteString(fmt.Sprintf("\t*result = %s{", typeName))
+	for i, field := range kept {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(field)
+	}
+	buf.WriteString("}\n\n")
+}
+
+// decodesInPlace reports whether a field is a struct held by value, which is decoded
+// into the parent's memory rather than allocated
+func decodesInPlace(field Field) bool {
+	return !builtinTypes[field.Type] && !field.Union && !field.Pointer && !field.Bitfield && field.FlagsRepr == "" &&
+		field.BackReference == nil && field.Codec == ""
+}
+
+// generateDecodeItemInPlace emits the rest of an array loop whose struct items are
+// decoded into the slice: by index for counted arrays, growing it for terminated ones
+func generateDecodeItemInPlace(buf *bytes.Buffer, field Field, fieldName, indent string) error {
+	item := fmt.Sprintf("result.%s[i]", fieldName)
+	if field.Kind == "null_terminated" {
+		buf.WriteString(fmt.Sprintf("%s\tresult.%s = runtime.Extend(decoder.Arena, result.%s)\n", indent, fieldName, fieldName))
+		item = fmt.Sprintf("result.%s[len(result.%s)-1]", fieldName, fieldName)
+	}
+	buf.WriteString(fmt.Sprintf("%s\tif _, err := decode%sInto(decoder, %s, &%s); err != nil {\n", indent, capitalizeFirst(field.Items.Type), contextFor(*field.Items), item))
+	buf.WriteString(fmt.Sprintf("%s\t\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+	writeTraceEnd(buf, item, indent+"\t")
+	buf.WriteString(fmt.Sprintf("%s}\n\n", indent))
+	return nil
+}
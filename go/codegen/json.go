@@ -0,0 +1,98 @@
+// ABOUTME: Generates MarshalJSON/UnmarshalJSON methods for generated types
+// ABOUTME: JSON uses schema field names and number arrays for bytes, matching the test-vector shape
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// generateJSONMethods emits MarshalJSON and UnmarshalJSON, both going through a mirror
+// struct whose tags carry the schema field names
+func generateJSONMethods(buf *bytes.Buffer, name string, typeDef *TypeDef) error {
+	recv := typeDef.receiver()
+	buf.WriteString(fmt.Sprintf("// MarshalJSON encodes %s with schema field names; byte arrays become arrays of numbers\n", name))
+	buf.WriteString(fmt.Sprintf("func (%s %s) MarshalJSON() ([]byte, error) {\n", recv, name))
+	buf.WriteString("\treturn json.Marshal(&")
+	if err := writeJSONMirror(buf, typeDef, "\t"); err != nil {
+		return err
+	}
+	buf.WriteString("{\n")
+	for _, field := range typeDef.allFields() {
+		fieldName := field.goName()
+		if isByteArray(field) {
+			buf.WriteString(fmt.Sprintf("\t\t%s: runtime.JSONBytes(%s.%s),\n", fieldName, recv, fieldName))
+		} else if field.Union {
+			buf.WriteString(fmt.Sprintf("\t\t%s: %s{%s.%s},\n", fieldName, unionJSONType(field.Type), recv, fieldName))
+		} else if isUnionArray(field) {
+			buf.WriteString(fmt.Sprintf("\t\t%s: %sSlice(%s.%s),\n", fieldName, unionJSONType(field.Items.Type), recv, fieldName))
+		} else {
+			buf.WriteString(fmt.Sprintf("\t\t%s: %s.%s,\n", fieldName, recv, fieldName))
+		}
+	}
+	buf.WriteString("\t})\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("// UnmarshalJSON decodes %s from the JSON MarshalJSON produces\n", name))
+	buf.WriteString(fmt.Sprintf("func (%s *%s) UnmarshalJSON(data []byte) error {\n", recv, name))
+	buf.WriteString("\tvar v ")
+	if err := writeJSONMirror(buf, typeDef, "\t"); err != nil {
+		return err
+	}
+	buf.WriteString("\n")
+	buf.WriteString("\tif err := json.Unmarshal(data, &v); err != nil {\n")
+	buf.WriteString("\t\treturn err\n")
+	buf.WriteString("\t}\n")
+	for _, field := range typeDef.allFields() {
+		fieldName := field.goName()
+		if isByteArray(field) {
+			buf.WriteString(fmt.Sprintf("\t%s.%s = []uint8(v.%s)\n", recv, fieldName, fieldName))
+		} else if field.Union {
+			buf.WriteString(fmt.Sprintf("\t%s.%s = v.%s.v\n", recv, fieldName, fieldName))
+		} else if isUnionArray(field) {
+			buf.WriteString(fmt.Sprintf("\t%s.%s = %s(v.%s)\n", recv, fieldName, unionSliceFunc(field.Items.Type), fieldName))
+		} else {
+			buf.WriteString(fmt.Sprintf("\t%s.%s = v.%s\n", recv, fieldName, fieldName))
+		}
+	}
+	buf.WriteString("\treturn nil\n")
+	buf.WriteString("}\n\n")
+	return nil
+}
+
+// writeJSONMirror writes an anonymous struct type with the type's fields tagged by schema name.
+// Nil pointers (absent conditional fields) are left out.
+func writeJSONMirror(buf *bytes.Buffer, typeDef *TypeDef, indent string) error {
+	buf.WriteString("struct {\n")
+	for _, field := range typeDef.allFields() {
+		goType, err := mapTypeToGo(field)
+		if err != nil {
+			return err
+		}
+		if isByteArray(field) {
+			goType = "runtime.JSONBytes"
+		} else if field.Union {
+			goType = unionJSONType(field.Type)
+		} else if isUnionArray(field) {
+			goType = "[]" + unionJSONType(field.Items.Type)
+		}
+		tag := field.Name
+		if field.Pointer {
+			tag += ",omitempty"
+		}
+		buf.WriteString(fmt.Sprintf("%s\t%s %s `json:%q`\n", indent, field.goName(), goType, tag))
+	}
+	buf.WriteString(indent + "}")
+	return nil
+}
+
+// isUnionArray reports whether a field is an array of discriminated unions
+func isUnionArray(field Field) bool {
+	return field.Type == "array" && field.Items != nil && field.Items.Union
+}
+
+// isByteArray reports whether a field is generated as []uint8. A lazy byte array is a
+// runtime.Lazy, which marshals its bytes as numbers itself.
+func isByteArray(field Field) bool {
+	return !field.Lazy && field.Type == "array" && field.Items != nil && field.Items.Type == "uint8"
+}
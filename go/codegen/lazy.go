@@ -0,0 +1,173 @@
+// ABOUTME: Lazy fields: decoding records a field's bytes in a runtime.Lazy instead of decoding them
+// ABOUTME: The value is decoded from those bytes on first access, so unread payloads cost one bounds check
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// checkLazyFields rejects lazy on fields whose bytes can't be found without decoding
+// them: only strings and arrays of fixed-width numbers whose length is known before
+// them can be stepped over
+func checkLazyFields(schema *Schema, defaultEndianness string) error {
+	for _, name := range typeOrder(schema) {
+		typeDef := schema.Types[name]
+		for i, field := range typeDef.allFields() {
+			if field.Items != nil && field.Items.Lazy {
+				return fmt.Errorf("%s.%s: array items can't be lazy, only the array", name, field.Name)
+			}
+			if !field.Lazy {
+				continue
+			}
+			if i >= len(typeDef.Sequence) || typeDef.Bitfield || typeDef.Protobuf {
+				return fmt.Errorf("%s.%s: only sequence fields of structs can be lazy", name, field.Name)
+			}
+			if _, ok := lazyWidth(field, defaultEndianness); !ok {
+				return fmt.Errorf("%s.%s: lazy fields must be strings or arrays of fixed-width numbers, with a fixed, length_prefixed, field_referenced or computed_count length", name, field.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// lazyWidth returns the bytes each unit of a lazy field's length takes: 1 for strings,
+// the item width for arrays
+func lazyWidth(field Field, defaultEndianness string) (int, bool) {
+	if fieldEndianness(field, defaultEndianness) == "dynamic" {
+		return 0, false
+	}
+	switch field.Type {
+	case "string":
+		switch field.Kind {
+		case "fixed", "length_prefixed", "field_referenced":
+			return 1, true
+		}
+	case "array":
+		if field.Items == nil {
+			return 0, false
+		}
+		width, ok := inlineWidths[field.Items.Type]
+		switch field.Kind {
+		case "fixed", "length_prefixed", "field_referenced", "computed_count":
+			return width, ok
+		}
+	}
+	return 0, false
+}
+
+// generateDecodeLazy reads a lazy field's bytes as a view of the input and stores them
+// in a runtime.Lazy with a function decoding them as generateDecodeFieldImpl would
+func generateDecodeLazy(buf *bytes.Buffer, field Field, fieldName, varName, runtimeEndianness, indent string) error {
+	width, _ := lazyWidth(field, "")
+	countVar := varName + "_count"
+	count := countVar
+	switch src, ok := lengthSource(field); {
+	case field.Kind == "length_prefixed":
+		lengthType := field.LengthType
+		if lengthType == "" {
+			lengthType = "uint8"
+		}
+		read, known := lengthPrefixReads[lengthType]
+		if !known {
+			return fmt.Errorf("%s: unsupported length_type %q", field.Name, lengthType)
+		}
+		if strings.Contains(read, "%s") {
+			read = fmt.Sprintf(read, runtimeEndianness)
+		}
+		buf.WriteString(fmt.Sprintf("%s%s_length, err := %s\n", indent, varName, read))
+		buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		buf.WriteString(fmt.Sprintf("%s%s := int64(%s_length)\n", indent, countVar, varName))
+	case ok:
+		if err := generateLength(buf, field, src, "result", countVar, indent); err != nil {
+			return err
+		}
+	default:
+		length, _ := field.Length.(float64)
+		count = fmt.Sprintf("%d", int(length))
+	}
+
+	// A count read from the data is checked before it is multiplied into a size
+	if count == countVar {
+		buf.WriteString(fmt.Sprintf("%sif %s < 0 || %s > int64(decoder.Len()-decoder.Position())/%d {\n", indent, countVar, countVar, width))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, decoder.Incomplete(fmt.Errorf(\"%s: length %%d exceeds remaining data\", %s))\n", indent, field.Name, countVar))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		count = fmt.Sprintf("int(%s)", countVar)
+	}
+	size := count
+	if width > 1 {
+		size = fmt.Sprintf("%s*%d", count, width)
+	}
+	spanVar := varName + "_span"
+	generateReadView(buf, spanVar, fmt.Sprintf("ReadBytesView(%s)", size), indent)
+
+	inner := field
+	inner.Lazy = false
+	goType, err := mapTypeToGo(inner)
+	if err != nil {
+		return err
+	}
+	// Strings are decoded after the decode returns, with the policy it had
+	policyVar := varName + "_utf8"
+	if field.UTF8Policy != "" {
+		buf.WriteString(fmt.Sprintf("%s%s := decoder.UTF8Policy(runtime.%s)\n", indent, policyVar, field.UTF8Policy))
+	}
+	buf.WriteString(fmt.Sprintf("%sresult.%s = runtime.NewLazy(%s, func(span []byte) (%s, error) {\n", indent, fieldName, spanVar, goType))
+	if field.Type == "string" && field.Kind == "fixed" {
+		// As when decoded eagerly, the padding is trimmed from the end
+		generateTrimPadding(buf, field, "span", indent+"\t")
+	}
+	switch {
+	case field.UTF8Policy != "":
+		buf.WriteString(fmt.Sprintf("%s\treturn runtime.DecodeUTF8(%q, span, %s)\n", indent, field.Name, policyVar))
+	case field.Type == "string":
+		buf.WriteString(fmt.Sprintf("%s\treturn string(span), nil\n", indent))
+	case width == 1 && field.Items.Type == "uint8":
+		// Byte arrays are the bytes themselves
+		buf.WriteString(fmt.Sprintf("%s\treturn span, nil\n", indent))
+	default:
+		buf.WriteString(fmt.Sprintf("%s\tdecoder := runtime.NewBitStreamDecoder(span, runtime.MSBFirst)\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\titems := make(%s, len(span)/%d)\n", indent, goType, width))
+		buf.WriteString(fmt.Sprintf("%s\tfor i := range items {\n", indent))
+		if err := generateDecodeFieldImpl(buf, *field.Items, "", "item", "", runtimeEndianness, indent+"\t\t"); err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("%s\t\titems[i] = item\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\treturn items, nil\n", indent))
+	}
+	buf.WriteString(fmt.Sprintf("%s})\n\n", indent))
+	return nil
+}
+
+// generateEncodeLazy encodes a lazy field's value, decoding it first if it hasn't been
+func generateEncodeLazy(buf *bytes.Buffer, field Field, fieldName, endianness, runtimeEndianness, indent string) error {
+	valueVar := strings.ReplaceAll(strings.ReplaceAll(fieldName, ".", "_"), "m_", "") + "_value"
+	buf.WriteString(fmt.Sprintf("%s%s, err := %s.Get()\n", indent, valueVar, fieldName))
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%w\", err)\n", indent, field.Name))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	inner := field
+	inner.Lazy = false
+	return generateEncodeFieldImpl(buf, inner, valueVar, endianness, runtimeEndianness, indent)
+}
+
+// generateLazyValue emits the use of a lazy field's value by a method that can't return
+// its decode error: onValue emits the use of the variable holding the value, and onError
+// is emitted instead when decoding fails, with the error in err
+func generateLazyValue(buf *bytes.Buffer, field Field, expr, indent, onError string, onValue func(inner Field, value string) error) error {
+	valueVar := strings.ToLower(field.Name) + "_value"
+	buf.WriteString(fmt.Sprintf("%sif %s, err := %s.Get(); err != nil {\n", indent, valueVar, expr))
+	buf.WriteString(fmt.Sprintf("%s\t%s\n", indent, onError))
+	buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+	inner := field
+	inner.Lazy = false
+	if err := onValue(inner, valueVar); err != nil {
+		return err
+	}
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	return nil
+}
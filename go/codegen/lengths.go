@@ -0,0 +1,158 @@
+// ABOUTME: Compiles length and count expressions ("rdlength - 2", "width * height") into Go
+// ABOUTME: Used by fixed lengths given as strings, field_referenced length_field and computed_count
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/serialexp/binschema/expression"
+)
+
+// lengthSource returns the expression giving a string's or array's length, if it has one.
+// A numeric fixed length is a constant and has none.
+func lengthSource(field Field) (string, bool) {
+	switch field.Kind {
+	case "fixed":
+		src, ok := field.Length.(string)
+		return src, ok
+	case "field_referenced":
+		return field.LengthField, field.LengthField != ""
+	case "computed_count":
+		return field.CountExpr, field.CountExpr != ""
+	}
+	return "", false
+}
+
+// generateLength emits lengthVar := <expression>, an int64 checked to be non-negative.
+// Fields are read from basePath ("m" when encoding, "result" when decoding) or, for
+// ../ paths, from the parents in ctx.
+func generateLength(buf *bytes.Buffer, field Field, src, basePath, lengthVar, indent string) error {
+	if err := generateIntExpression(buf, field, "length", src, basePath, lengthVar, indent); err != nil {
+		return err
+	}
+	buf.WriteString(fmt.Sprintf("%sif %s < 0 {\n", indent, lengthVar))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: negative length %%d from %%q\", %s, %q)\n", indent, field.Name, lengthVar, src))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	return nil
+}
+
+// generateIntExpression emits resultVar := <expression> as an int64. what names the
+// attribute holding the expression in errors ("length", "position").
+func generateIntExpression(buf *bytes.Buffer, field Field, what, src, basePath, resultVar, indent string) error {
+	node, err := expression.Parse(src)
+	if err != nil {
+		return fmt.Errorf("%s: %s %q: %w", field.Name, what, src, err)
+	}
+	c := &lengthCompiler{buf: buf, basePath: basePath, prefix: resultVar, indent: indent, name: field.Name, what: what, owner: field}
+	goExpr, err := c.compile(node)
+	if err != nil {
+		return fmt.Errorf("%s: %s %q: %w", field.Name, what, src, err)
+	}
+	buf.WriteString(fmt.Sprintf("%s%s := %s\n", indent, resultVar, goExpr))
+	return nil
+}
+
+// lengthCompiler turns an expression tree into int64 Go arithmetic. Parent lookups and
+// divisors that aren't constants are evaluated into variables first, so errors (missing
+// parents, division by zero) are returned rather than panicking.
+type lengthCompiler struct {
+	buf      *bytes.Buffer
+	basePath string
+	prefix   string
+	indent   string
+	name     string
+	what     string
+	temps    int
+	owner    Field // The field the expression belongs to, with its resolved ../ paths
+}
+
+func (c *lengthCompiler) compile(node expression.Node) (string, error) {
+	switch n := node.(type) {
+	case *expression.Number:
+		return fmt.Sprintf("int64(%d)", n.Value), nil
+	case *expression.Field:
+		return c.field(n.Path)
+	case *expression.Unary:
+		operand, err := c.compile(n.Operand)
+		if err != nil {
+			return "", err
+		}
+		switch n.Op {
+		case "-":
+			return "(-" + operand + ")", nil
+		case "~":
+			return "(^" + operand + ")", nil
+		}
+		return "", fmt.Errorf("operator %s doesn't give a number", n.Op)
+	case *expression.Binary:
+		left, err := c.compile(n.Left)
+		if err != nil {
+			return "", err
+		}
+		right, err := c.compile(n.Right)
+		if err != nil {
+			return "", err
+		}
+		switch n.Op {
+		case "+", "-", "*", "&", "|", "^":
+			return fmt.Sprintf("(%s %s %s)", left, n.Op, right), nil
+		case "<<", ">>":
+			return fmt.Sprintf("(%s %s uint64(%s))", left, n.Op, right), nil
+		case "/", "%":
+			if divisor, ok := n.Right.(*expression.Number); !ok || divisor.Value == 0 {
+				right = c.divisor(right)
+			}
+			return fmt.Sprintf("(%s %s %s)", left, n.Op, right), nil
+		}
+		return "", fmt.Errorf("operator %s doesn't give a number", n.Op)
+	}
+	return "", fmt.Errorf("only integer arithmetic on fields is supported")
+}
+
+// field reads a local path (header.length) or a parent path (../count) as an int64
+func (c *lengthCompiler) field(path string) (string, error) {
+	if strings.HasPrefix(path, "_root.") {
+		return "", fmt.Errorf("_root references are not supported")
+	}
+	if !strings.HasPrefix(path, "../") {
+		goPath := c.basePath + "." + c.owner.goPath(path)
+		if c.owner.WideRefs[path] {
+			return c.wide(goPath, path), nil
+		}
+		return fmt.Sprintf("int64(%s)", goPath), nil
+	}
+
+	refVar := c.temp("ref")
+	generateParentRef(c.buf, c.owner, path, refVar, c.indent)
+	return refVar, nil
+}
+
+// divisor evaluates a divisor into a variable checked to be non-zero
+func (c *lengthCompiler) divisor(expr string) string {
+	divisorVar := c.temp("divisor")
+	c.buf.WriteString(fmt.Sprintf("%s%s := %s\n", c.indent, divisorVar, expr))
+	c.buf.WriteString(fmt.Sprintf("%sif %s == 0 {\n", c.indent, divisorVar))
+	c.buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %s divides by zero\")\n", c.indent, c.name, c.what))
+	c.buf.WriteString(fmt.Sprintf("%s}\n", c.indent))
+	return divisorVar
+}
+
+func (c *lengthCompiler) temp(kind string) string {
+	c.temps++
+	return fmt.Sprintf("%s_%s%d", c.prefix, kind, c.temps)
+}
+
+// generateRemainingCheck rejects a decoded length larger than the data left, before
+// anything is allocated for it, as incomplete data. Array items take at least a bit,
+// string bytes a byte.
+func generateRemainingCheck(buf *bytes.Buffer, field Field, lengthVar, indent string) {
+	remaining := "int64(decoder.Len() - decoder.Position())"
+	if field.Type == "array" {
+		remaining += " * 8"
+	}
+	buf.WriteString(fmt.Sprintf("%sif %s > %s {\n", indent, lengthVar, remaining))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, decoder.Incomplete(fmt.Errorf(\"%s: length %%d exceeds remaining data\", %s))\n", indent, field.Name, lengthVar))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
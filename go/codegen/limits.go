@@ -0,0 +1,41 @@
+// ABOUTME: EncodeWithLimit: encoding that fails with ErrOutputTooLarge once the output passes a limit
+// ABOUTME: Encoders take the limit from their context, and array loops stop as soon as it is passed
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// generateEncodeWithLimit emits EncodeWithLimit, which encodes like Encode with an
+// OutputLimit added to its context
+func generateEncodeWithLimit(buf *bytes.Buffer, typeName, recv, encodeCtx string) {
+	buf.WriteString("// EncodeWithLimit encodes like Encode, but fails with runtime.ErrOutputTooLarge as\n")
+	buf.WriteString("// soon as the output passes maxBytes, so a message too large to send isn't encoded\n")
+	buf.WriteString("// to the end first\n")
+	buf.WriteString(fmt.Sprintf("func (%s *%s) EncodeWithLimit(maxBytes int) ([]byte, error) {\n", recv, typeName))
+	buf.WriteString(fmt.Sprintf("\treturn %s.EncodeWithContext(%s)\n", recv, limitContext(encodeCtx)))
+	buf.WriteString("}\n\n")
+}
+
+// limitContext adds an OutputLimit of maxBytes to encodeCtx, the context Encode uses
+// ("nil" or a runtime.NewEncodingContext call)
+func limitContext(encodeCtx string) string {
+	const option = "runtime.OutputLimit(maxBytes)"
+	switch {
+	case encodeCtx == "nil":
+		return "runtime.NewEncodingContext(" + option + ")"
+	case strings.HasSuffix(encodeCtx, "()"):
+		return strings.TrimSuffix(encodeCtx, ")") + option + ")"
+	default:
+		return strings.TrimSuffix(encodeCtx, ")") + ", " + option + ")"
+	}
+}
+
+// generateLimitCheck emits the check at the top of each iteration of an encoding loop
+func generateLimitCheck(buf *bytes.Buffer, indent string) {
+	buf.WriteString(fmt.Sprintf("%sif err := encoder.CheckLimit(); err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
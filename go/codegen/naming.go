@@ -0,0 +1,195 @@
+// ABOUTME: GenerateOptions naming: type name prefixes and suffixes, PascalCase fields, exported decoders and the receiver
+// ABOUTME: Types are renamed in the parsed schema before generation; union variants keep their schema names for JSON
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+)
+
+var (
+	typePrefixPattern = regexp.MustCompile(`^([A-Z][A-Za-z0-9_]*)?$`)
+	typeSuffixPattern = regexp.MustCompile(`^[A-Za-z0-9_]*$`)
+	receiverPattern   = regexp.MustCompile(`^[a-z][A-Za-z0-9]*$`)
+	acronymPattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+)
+
+// checkNamingOptions checks the names GenerateOptions adds to generated code
+func checkNamingOptions(opts GenerateOptions) error {
+	if !typePrefixPattern.MatchString(opts.TypePrefix) {
+		return fmt.Errorf("type prefix %q must be an identifier starting with an upper-case letter", opts.TypePrefix)
+	}
+	if !typeSuffixPattern.MatchString(opts.TypeSuffix) {
+		return fmt.Errorf("type suffix %q must be letters, digits and underscores", opts.TypeSuffix)
+	}
+	if opts.Receiver != "" && (!receiverPattern.MatchString(opts.Receiver) || token.IsKeyword(opts.Receiver)) {
+		return fmt.Errorf("receiver %q must be an identifier starting with a lower-case letter", opts.Receiver)
+	}
+	if len(opts.Acronyms) > 0 && !opts.PascalCase {
+		return fmt.Errorf("acronyms only apply to PascalCase field names")
+	}
+	for _, acronym := range opts.Acronyms {
+		if !acronymPattern.MatchString(acronym) {
+			return fmt.Errorf("acronym %q must be letters and digits starting with a letter", acronym)
+		}
+	}
+	return nil
+}
+
+// goTypeName returns the Go name GenerateOptions give the schema type name
+func goTypeName(opts GenerateOptions, name string) string {
+	return opts.TypePrefix + name + opts.TypeSuffix
+}
+
+// renameTypes gives every type of the schema its Go name, rewriting the fields and
+// variants that refer to it
+func renameTypes(schema *Schema, opts GenerateOptions) {
+	if opts.TypePrefix == "" && opts.TypeSuffix == "" {
+		return
+	}
+	renamed := make(map[string]*TypeDef, len(schema.Types))
+	for name, typeDef := range schema.Types {
+		renamed[goTypeName(opts, name)] = typeDef
+		typeDef.SchemaName = name
+		for i := range typeDef.Variants {
+			variant := &typeDef.Variants[i]
+			variant.SchemaName = variant.Type
+			variant.Type = goTypeName(opts, variant.Type)
+		}
+		for _, field := range typeDef.fieldPointers() {
+			renameFieldTypes(schema, field, opts)
+		}
+	}
+	schema.Types = renamed
+}
+
+// renameFieldTypes renames the types a field and its items refer to
+func renameFieldTypes(schema *Schema, field *Field, opts GenerateOptions) {
+	if _, ok := schema.Types[field.Type]; ok {
+		field.Type = goTypeName(opts, field.Type)
+	}
+	for i, variant := range field.TerminalVariants {
+		field.TerminalVariants[i] = goTypeName(opts, variant)
+	}
+	if field.Items != nil {
+		renameFieldTypes(schema, field.Items, opts)
+	}
+	for i := range field.Fields {
+		renameFieldTypes(schema, &field.Fields[i], opts)
+	}
+}
+
+// markReceivers sets the receiver of every struct's methods, and of the fields whose
+// encoders read the struct's other fields
+func markReceivers(schema *Schema, receiver string) {
+	if receiver == "" {
+		return
+	}
+	for _, typeDef := range schema.Types {
+		typeDef.Receiver = receiver
+		for _, field := range typeDef.fieldPointers() {
+			for f := field; f != nil; f = f.Items {
+				f.Receiver = receiver
+			}
+		}
+	}
+}
+
+// receiver returns the name of the receiver of the struct's methods
+func (t *TypeDef) receiver() string {
+	if t.Receiver == "" {
+		return "m"
+	}
+	return t.Receiver
+}
+
+// receiver returns the name of the receiver of the methods encoding the field
+func (f Field) receiver() string {
+	if f.Receiver == "" {
+		return "m"
+	}
+	return f.Receiver
+}
+
+// schemaName returns the schema's name for a variant's type, which JSON tags it with
+func (v Variant) schemaName() string {
+	if v.SchemaName == "" {
+		return v.Type
+	}
+	return v.SchemaName
+}
+
+// schemaName returns the schema's name for the type with the Go name name
+func (s *Schema) schemaName(name string) string {
+	if typeDef, ok := s.Types[name]; ok && typeDef.SchemaName != "" {
+		return typeDef.SchemaName
+	}
+	return name
+}
+
+// generateExportedDecoder emits DecodeXWithDecoder, the exported form of the
+// decoder nested types are read with
+func generateExportedDecoder(buf *bytes.Buffer, typeName, goType string) {
+	buf.WriteString(fmt.Sprintf("// Decode%sWithDecoder decodes a %s from decoder at its position, leaving it after\n", typeName, typeName))
+	buf.WriteString(fmt.Sprintf("// the %s\n", typeName))
+	buf.WriteString(fmt.Sprintf("func Decode%sWithDecoder(decoder *runtime.BitStreamDecoder) (%s, error) {\n", typeName, goType))
+	buf.WriteString(fmt.Sprintf("\treturn decode%sWithDecoder(decoder, nil)\n", typeName))
+	buf.WriteString("}\n\n")
+}
+
+// checkReceiver reports a method of code whose receiver is hidden by, or hides, another
+// use of its name: a local variable or parameter, or a package the method refers to
+func checkReceiver(code []byte, receiver string) error {
+	file, err := parser.ParseFile(token.NewFileSet(), "", code, 0)
+	if err != nil {
+		return err
+	}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || fn.Body == nil || len(fn.Recv.List[0].Names) == 0 {
+			continue
+		}
+		recv := fn.Recv.List[0].Names[0]
+		if recv.Name != receiver {
+			continue
+		}
+		// Field and method names can't clash with a variable
+		selected := make(map[*ast.Ident]bool)
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				selected[sel.Sel] = true
+			}
+			return true
+		})
+		// A := at the top of the body assigns the receiver rather than declaring a variable
+		defined := make(map[*ast.Ident]bool)
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			if assign, ok := n.(*ast.AssignStmt); ok && assign.Tok == token.DEFINE {
+				for _, lhs := range assign.Lhs {
+					if ident, ok := lhs.(*ast.Ident); ok {
+						defined[ident] = true
+					}
+				}
+			}
+			return true
+		})
+		var clash error
+		for _, node := range []ast.Node{fn.Type, fn.Body} {
+			ast.Inspect(node, func(n ast.Node) bool {
+				ident, ok := n.(*ast.Ident)
+				if ok && clash == nil && !selected[ident] && ident.Name == receiver && (ident.Obj != recv.Obj || defined[ident]) {
+					clash = fmt.Errorf("receiver %q clashes with a name used in %s", receiver, fn.Name.Name)
+				}
+				return true
+			})
+		}
+		if clash != nil {
+			return clash
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// ABOUTME: position_of computed fields: byte offsets of other fields, filled in when encoding
+// ABOUTME: Offsets of later fields are reserved as placeholders and patched once the target is written
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Byte size of each unsigned type an offset or length prefix may have
+var uintBytes = map[string]int{"uint8": 1, "uint16": 2, "uint32": 4, "uint64": 8}
+
+// markOffsetContext flags the nested-type fields and array items that must be told where
+// their output lands, so that position_of fields and the offsets of names recorded for
+// back references inside them count from the start of the message. Schemas with neither
+// pass the context through untouched.
+func markOffsetContext(schema *Schema) {
+	if !usesPositionOf(schema) && !usesBackReferences(schema) {
+		return
+	}
+	for _, typeDef := range schema.Types {
+		for _, field := range typeDef.fieldPointers() {
+			field.OffsetContext = isNestedType(schema, field)
+			if field.Items != nil {
+				field.Items.OffsetContext = isNestedType(schema, field.Items)
+			}
+		}
+	}
+}
+
+// usesPositionOf reports whether any field is a position_of computed field
+func usesPositionOf(schema *Schema) bool {
+	for _, typeDef := range schema.Types {
+		for _, field := range typeDef.allFields() {
+			if field.PositionOf != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// encodeContextFor names the context a nested field is encoded with. skip is the number
+// of bytes written ahead of the nested output, such as a length prefix. Nested types in
+// dynamic-endian schemas also get the byte order chosen so far.
+func encodeContextFor(field Field, skip int) string {
+	ctx := contextFor(field)
+	if field.OffsetContext && skip > 0 {
+		ctx += fmt.Sprintf(".At(encoder.Position() + %d)", skip)
+	} else if field.OffsetContext {
+		ctx += ".At(encoder.Position())"
+	}
+	if field.EndiannessContext {
+		ctx += ".WithEndianness(encoder.Endianness())"
+	}
+	return ctx
+}
+
+// positionFixups tracks a struct's position_of fields while its encoder is generated.
+// An offset of an earlier field is written directly; an offset of a later one is a
+// placeholder, patched when that field is reached.
+type positionFixups struct {
+	targets map[string]bool    // Fields whose offset is taken
+	written map[string]bool    // Targets whose offset is known
+	pending map[string][]Field // position_of fields waiting for their target
+}
+
+func newPositionFixups(typeDef *TypeDef) (*positionFixups, error) {
+	p := &positionFixups{targets: make(map[string]bool), written: make(map[string]bool), pending: make(map[string][]Field)}
+	for _, field := range typeDef.Sequence {
+		if field.PositionOf == "" {
+			continue
+		}
+		if _, ok := uintBytes[field.Type]; !ok {
+			return nil, fmt.Errorf("%s: position_of needs an unsigned integer type, not %s", field.Name, field.Type)
+		}
+		if field.Conditional != "" {
+			return nil, fmt.Errorf("%s: conditional position_of fields are not supported", field.Name)
+		}
+		target, ok := findSequenceField(typeDef, field.PositionOf)
+		if !ok || target.Name == field.Name {
+			return nil, fmt.Errorf("%s: position_of target %q is not another field of this struct", field.Name, field.PositionOf)
+		}
+		if target.Conditional != "" {
+			return nil, fmt.Errorf("%s: position_of target %q is conditional, which is not supported", field.Name, field.PositionOf)
+		}
+		p.targets[target.Name] = true
+	}
+	return p, nil
+}
+
+func findSequenceField(typeDef *TypeDef, name string) (Field, bool) {
+	for _, field := range typeDef.Sequence {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// generateTargetPosition emits the absolute offset of a field whose offset is taken,
+// before it is written, and patches the placeholders waiting for it
+func (p *positionFixups) generateTargetPosition(buf *bytes.Buffer, field Field, defaultEndianness string) {
+	if !p.targets[field.Name] {
+		return
+	}
+	positionVar := strings.ToLower(field.Name) + "_position"
+	buf.WriteString(fmt.Sprintf("\t%s := ctx.Offset() + encoder.Position()\n", positionVar))
+	for _, offset := range p.pending[field.Name] {
+		generatePositionCheck(buf, offset, positionVar)
+		slotVar := strings.ToLower(offset.Name) + "_slot"
+		if offset.Type == "uint8" {
+			buf.WriteString(fmt.Sprintf("\tencoder.PatchUint8(%s, uint8(%s))\n", slotVar, positionVar))
+			continue
+		}
+		endianness := offset.Endianness
+		if endianness == "" {
+			endianness = defaultEndianness
+		}
+		buf.WriteString(fmt.Sprintf("\tencoder.Patch%s(%s, %s(%s), runtime.%s)\n", capitalizeFirst(offset.Type), slotVar, offset.Type, positionVar, mapEndianness(endianness)))
+	}
+	delete(p.pending, field.Name)
+	p.written[field.Name] = true
+}
+
+// generateEncode emits a position_of field: the offset itself if its target has been
+// written, otherwise a placeholder for it
+func (p *positionFixups) generateEncode(buf *bytes.Buffer, field Field, defaultEndianness string) error {
+	if p.written[field.PositionOf] {
+		positionVar := strings.ToLower(field.PositionOf) + "_position"
+		generatePositionCheck(buf, field, positionVar)
+		endianness := field.Endianness
+		if endianness == "" {
+			endianness = defaultEndianness
+		}
+		return generateEncodeFieldImpl(buf, field, fmt.Sprintf("%s(%s)", field.Type, positionVar), endianness, mapEndianness(endianness), "\t")
+	}
+
+	slotVar := strings.ToLower(field.Name) + "_slot"
+	buf.WriteString(fmt.Sprintf("\t%s, err := encoder.ReservePlaceholder(%d)\n", slotVar, uintBytes[field.Type]))
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString(fmt.Sprintf("\t\treturn nil, fmt.Errorf(\"%s: %%w\", err)\n", field.Name))
+	buf.WriteString("\t}\n")
+	p.pending[field.PositionOf] = append(p.pending[field.PositionOf], field)
+	return nil
+}
+
+// generatePositionCheck rejects an offset too large for the field holding it
+func generatePositionCheck(buf *bytes.Buffer, field Field, positionVar string) {
+	if field.Type == "uint64" {
+		return
+	}
+	buf.WriteString(fmt.Sprintf("\tif uint64(%s) > %s {\n", positionVar, positionFieldMax[field.Type]))
+	buf.WriteString(fmt.Sprintf("\t\treturn nil, fmt.Errorf(\"%s: offset %%d of %s doesn't fit in %s\", %s)\n", field.Name, field.PositionOf, field.Type, positionVar))
+	buf.WriteString("\t}\n")
+}
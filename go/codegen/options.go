@@ -0,0 +1,280 @@
+// ABOUTME: Options that control the shape of generated Go code
+// ABOUTME: Wire format never depends on these; they only change the Go API of generated types
+package codegen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GenerateOptions controls how schema types map to Go types.
+// The zero value matches GenerateGo.
+type GenerateOptions struct {
+	// NestedPointers generates every nested struct field as *T instead of T.
+	//
+	// Trade-offs: by-value fields (the default) keep a struct in one allocation,
+	// are never nil and copy cleanly, but copying a message copies every nested
+	// subtree and "not set" can't be distinguished from a zero value. Pointer
+	// fields share subtrees on copy, avoid large copies and can be left nil when
+	// a conditional field is absent, at the cost of an allocation per nested
+	// struct and a nil check on encode: encoding a nil pointer that must be
+	// written is an error, since nil has no wire representation.
+	//
+	// Array items stay values. Fields of recursive types are always pointers.
+	NestedPointers bool
+
+	// PointerFields selects individual nested fields to generate as *T, as
+	// "Type.field" (schema names). Unknown entries are an error.
+	PointerFields []string
+
+	// EmptySlices controls whether decoded array fields with no elements are
+	// nil or empty. reflect.DeepEqual (and testify's Equal) treat nil and empty
+	// slices as different, so a value built with nil slices won't compare equal
+	// to its decoded copy unless both sides agree. The wire format is the same
+	// either way: encoding doesn't distinguish nil from empty.
+	EmptySlices EmptySliceMode
+
+	// UnknownAttributes controls schema attributes outside the BinSchema
+	// vocabulary, which the generator would otherwise drop silently (a typo
+	// like "lenght_type" falls back to the default length type and changes the
+	// wire format). AttributesWarn reports each one with its path through Warn;
+	// AttributesStrict fails generation.
+	UnknownAttributes AttributeMode
+
+	// Warn receives AttributesWarn messages. Defaults to the standard logger.
+	Warn func(msg string)
+
+	// ZeroCopy generates string fields as runtime.ByteString, decoded as views of
+	// the input instead of copies.
+	//
+	// Decoding skips an allocation and a copy per string, which dominates the
+	// cost of string-heavy messages such as DNS. The decoded value is only valid
+	// while the input buffer is unchanged: a caller that reuses the buffer (a
+	// network read loop, say) must Clone what it keeps.
+	ZeroCopy bool
+
+	// BinaryCodecs generates MarshalBinary and UnmarshalBinary on every struct,
+	// implementing encoding.BinaryMarshaler and encoding.BinaryUnmarshaler, so
+	// generated types work as net/http bodies, in libraries that accept those
+	// interfaces, and through runtime.Codec as gRPC messages.
+	BinaryCodecs bool
+
+	// SQL generates Value and Scan on every struct, implementing driver.Valuer
+	// and sql.Scanner, so messages can be stored in BLOB columns as their encoded
+	// bytes and read back through database/sql. It also generates MarshalText
+	// and UnmarshalText, which carry the same bytes as base64, for text columns
+	// and formats that use encoding.TextMarshaler. Type aliases, whose field is
+	// Value, don't get them.
+	SQL bool
+
+	// Builders generates NewX constructors taking a struct's required fields and
+	// XBuilder types setting them one by one. Build fails on a required field that
+	// was never set, and fills in the fields derived from others: counts and
+	// lengths other fields are read with (qdcount from the questions), computed
+	// length_of and count_of fields, and const values.
+	Builders bool
+
+	// SelfDescribing generates MarshalCBOR and MarshalMessagePack on every type,
+	// re-serializing a value in those formats with the shape of its JSON, so
+	// gateways can translate a binary protocol without mapping code.
+	SelfDescribing bool
+
+	// DecodeInto generates DecodeXInto, decoding into a caller's value and
+	// reusing the capacity of its slices, so a decode loop stops allocating.
+	DecodeInto bool
+
+	// DecodeWithArena generates DecodeXWithArena, taking the structs and slices
+	// of the decoded value from a runtime.DecodeArena that is reset between
+	// messages.
+	DecodeWithArena bool
+
+	// DecodeStream generates DecodeXStream, a runtime.StatefulDecoder of
+	// messages arriving in chunks, such as reads from a socket.
+	DecodeStream bool
+
+	// DecodeContext generates DecodeXContext, which stops decoding arrays when
+	// a context.Context is canceled. Its import of context comes with it.
+	DecodeContext bool
+
+	// ExtractFields generates ExtractXField, decoding only the field at a path
+	// and skipping the fixed-width fields it doesn't need.
+	ExtractFields bool
+
+	// Canonical generates encoders that give every value exactly one encoding, so
+	// equal values encode to identical bytes that can be signed or hashed. NaNs
+	// are written as the quiet NaN whatever their payload, and values encoding
+	// would otherwise change are errors: bit fields wider than their bits,
+	// fixed-length strings too long for their field or ending in their padding
+	// (too short for it without padding), and null-terminated strings containing
+	// a NUL byte. Decoding is unchanged. Contexts passed to EncodeWithContext
+	// should be made with runtime.Canonical.
+	Canonical bool
+
+	// ReplaceNonASCII generates encoders that write each character of an ascii
+	// string that isn't ASCII as '?', as lossy text encoders do. Without it,
+	// such a character fails the encode with a runtime.InvalidValueError (code
+	// INVALID_VALUE) giving its byte offset, rather than writing its UTF-8 bytes.
+	ReplaceNonASCII bool
+
+	// CompressNames generates encoders that compress DNS-style names themselves:
+	// null-terminated arrays of union items ending in a back_reference variant.
+	// The name is given as plain labels, and the encoder replaces its longest
+	// run of final labels that ended a name written earlier in the message with
+	// a pointer to it, as DNS servers do. Without it, a pointer is only written
+	// for a back_reference variant the name was given. Encode compresses with
+	// RFC 1035 rules; contexts passed to EncodeWithContext should be made with a
+	// runtime.Compression policy matching suffixes.
+	CompressNames bool
+
+	// ResolveNames generates decoders that follow the pointers of DNS-style
+	// names, so a decoded name holds every label and no pointer. Loops end at
+	// the decoder's nesting limit. A type that is such a name gets Labels()
+	// and Domain() ("www.example.com") when its labels are strings.
+	ResolveNames bool
+
+	// TypePrefix and TypeSuffix are added to the Go name of every schema type, so
+	// types from several schemas can share a package: TypePrefix "DNS" makes
+	// Header DNSHeader, decoded with DecodeDNSHeader. TypePrefix must start with
+	// an upper-case letter. JSON keeps the schema names of union variants.
+	TypePrefix string
+	TypeSuffix string
+
+	// PascalCase names the Go struct fields of snake_case and camelCase schema
+	// fields by joining their words ("data_offset" is DataOffset) rather than
+	// capitalizing their first letter (Data_offset), and gives them a binschema
+	// tag with the schema name. Acronyms are words written in capitals, "id" and
+	// "url" if none are given: "user_id" is UserID, as FieldName and the
+	// TypeScript generator name it. A field's go_name overrides both.
+	PascalCase bool
+	Acronyms   []string
+
+	// ExportDecoders generates DecodeXWithDecoder for every type, decoding one
+	// from a runtime.BitStreamDecoder at its position, so generated types can be
+	// read in the middle of hand-written parsers.
+	ExportDecoders bool
+
+	// Receiver names the receiver of the methods of generated structs, "m" if
+	// empty. Generation fails if the name clashes with one the generated code
+	// uses.
+	Receiver string
+
+	// Direction generates only the encoders (EncodeOnly) or only the decoders
+	// (DecodeOnly) of every type, for programs that only write or only read a
+	// format, such as TinyGo and firmware builds where each unused method adds
+	// to the binary. DumpAnnotated, ExtractXField and DecodeXFieldEach go with
+	// the decoders, and imports only the dropped half used are left out. Options
+	// for the dropped half are ignored, except BinaryCodecs and SQL, whose
+	// interfaces need both halves: combining them with either is an error.
+	Direction Direction
+
+	// CastTypes names structs (schema names) to generate unsafe casts for:
+	// CastX views bytes as an X and CastXSlice as a []X without decoding or
+	// copying, and XSliceBytes views records as their encoding, for files of
+	// hundreds of millions of records read through mmap. Only fixed-size
+	// little-endian records qualify: numbers and nested such structs whose Go
+	// layout is their encoding, each aligned to its width, and the generated
+	// code asserts that layout at compile time. Casts share memory with their
+	// input, and fail on big-endian hosts and on misaligned data. Other types
+	// named are an error.
+	CastTypes []string
+}
+
+// EmptySliceMode selects how decoders represent array fields with no elements
+type EmptySliceMode int
+
+const (
+	// EmptySlicesAsDecoded leaves slices as decoding produced them: an array
+	// read from the wire is non-nil even when empty, while a conditional array
+	// that is absent stays nil
+	EmptySlicesAsDecoded EmptySliceMode = iota
+	// EmptySlicesNil decodes every array field with no elements as nil,
+	// matching Go structs built without setting the field
+	EmptySlicesNil
+	// EmptySlicesNonNil decodes every array field as a non-nil slice,
+	// including conditional arrays that are absent
+	EmptySlicesNonNil
+)
+
+// Direction selects whether encoders, decoders or both are generated
+type Direction int
+
+const (
+	// EncodeAndDecode generates both encoders and decoders
+	EncodeAndDecode Direction = iota
+	// EncodeOnly generates Encode and its variants, and no decoders
+	EncodeOnly
+	// DecodeOnly generates DecodeX and its variants, and no encoders
+	DecodeOnly
+)
+
+// encodes reports whether encoders are generated
+func (d Direction) encodes() bool {
+	return d != DecodeOnly
+}
+
+// decodes reports whether decoders are generated
+func (d Direction) decodes() bool {
+	return d != EncodeOnly
+}
+
+// checkDirection rejects a Direction other options can't work with
+func checkDirection(opts GenerateOptions) error {
+	if opts.Direction < EncodeAndDecode || opts.Direction > DecodeOnly {
+		return fmt.Errorf("unknown direction %d", opts.Direction)
+	}
+	if opts.Direction == EncodeAndDecode {
+		return nil
+	}
+	if opts.BinaryCodecs {
+		return fmt.Errorf("binary codecs need both encoders and decoders")
+	}
+	if opts.SQL {
+		return fmt.Errorf("SQL methods need both encoders and decoders")
+	}
+	return nil
+}
+
+// applyPointerOptions marks nested struct fields chosen by opts as pointers
+func applyPointerOptions(schema *Schema, opts GenerateOptions) error {
+	// Paths name schema types, which have their Go names by now
+	selected := make(map[string]string)
+	for _, path := range opts.PointerFields {
+		goPath := path
+		if dot := strings.Index(path, "."); dot > 0 {
+			goPath = goTypeName(opts, path[:dot]) + path[dot:]
+		}
+		selected[goPath] = path
+	}
+
+	for typeName, typeDef := range schema.Types {
+		for _, field := range typeDef.fieldPointers() {
+			// Bitfields are read in place and unions are interfaces: neither becomes a pointer
+			_, isType := schema.Types[field.Type]
+			isType = isType && !field.Bitfield && !field.Union && field.FlagsRepr == ""
+			path := typeName + "." + field.Name
+			if _, ok := selected[path]; ok {
+				delete(selected, path)
+				if field.Bitfield {
+					return fmt.Errorf("pointer field %s is a bitfield, not a nested struct", path)
+				}
+				if !isType {
+					return fmt.Errorf("pointer field %s has type %s, not a nested struct", path, field.Type)
+				}
+				field.Pointer = true
+			} else if opts.NestedPointers && isType {
+				field.Pointer = true
+			}
+		}
+	}
+
+	if len(selected) > 0 {
+		var unknown []string
+		for _, path := range selected {
+			unknown = append(unknown, path)
+		}
+		sort.Strings(unknown)
+		return fmt.Errorf("pointer fields not found in schema: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
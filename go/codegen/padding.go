@@ -0,0 +1,32 @@
+// ABOUTME: Padding of fixed-length strings: NULs (default) or spaces after the value, trimmed from its end
+// ABOUTME: padding: "none" keeps every byte of the field, so bytes that look like padding survive decoding
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// padByte returns the byte a fixed-length string is padded with, and whether decoding
+// trims it from the end
+func padByte(field Field) (string, bool) {
+	switch field.Padding {
+	case "space":
+		return "' '", true
+	case "none":
+		return "0", false
+	}
+	return "0", true
+}
+
+// generateTrimPadding emits the trimming of a fixed-length string's padding from the end
+// of its decoded bytes; bytes inside the value are kept, whatever they are
+func generateTrimPadding(buf *bytes.Buffer, field Field, bytesVar, indent string) {
+	pad, trims := padByte(field)
+	if !trims {
+		return
+	}
+	buf.WriteString(fmt.Sprintf("%sfor len(%s) > 0 && %s[len(%s)-1] == %s {\n", indent, bytesVar, bytesVar, bytesVar, pad))
+	buf.WriteString(fmt.Sprintf("%s\t%s = %s[:len(%s)-1]\n", indent, bytesVar, bytesVar, bytesVar))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
@@ -0,0 +1,382 @@
+// ABOUTME: Parent field references ("../header.version == 2") in conditionals
+// ABOUTME: Structs with nested types hand them their fields through runtime Encoding/DecodingContext
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/serialexp/binschema/expression"
+)
+
+// markParentContext flags the nested-type fields and array items that must receive their
+// parent's fields: those whose type, or a type nested in it, refers past it with ../.
+// Other fields pass the context through untouched, so types with nothing below them
+// looking up don't build parent maps.
+func markParentContext(schema *Schema) {
+	reach := parentReach(schema)
+	for _, typeDef := range schema.Types {
+		for _, field := range typeDef.fieldPointers() {
+			field.ParentContext = isNestedType(schema, field) && reach[field.Type] > 0
+			if field.Items != nil {
+				field.Items.ParentContext = isNestedType(schema, field.Items) && reach[field.Items.Type] > 0
+			}
+		}
+	}
+}
+
+// parentReach returns how many levels above each type its ../ references reach, its own
+// or those of the types nested in it: a child reaching two levels up reaches one above
+// its parent. Unions don't add a level, so they reach as far as their variants.
+func parentReach(schema *Schema) map[string]int {
+	reach := make(map[string]int)
+	for name, typeDef := range schema.Types {
+		for _, field := range typeDef.allFields() {
+			reach[name] = max(reach[name], parentLevels(field.Conditional))
+			if src, ok := lengthSource(field); ok {
+				reach[name] = max(reach[name], parentLevels(src))
+			}
+		}
+	}
+
+	// Carry reaches up to the types nesting them until nothing changes
+	for changed := true; changed; {
+		changed = false
+		for name, typeDef := range schema.Types {
+			r := reach[name]
+			for _, variant := range typeDef.Variants {
+				r = max(r, reach[variant.Type])
+			}
+			for _, field := range typeDef.allFields() {
+				r = max(r, reach[field.Type]-1)
+				if field.Items != nil {
+					r = max(r, reach[field.Items.Type]-1)
+				}
+			}
+			if r > reach[name] {
+				reach[name] = r
+				changed = true
+			}
+		}
+	}
+	return reach
+}
+
+// parentLevels returns the most levels any ../ path in an expression goes up
+func parentLevels(src string) int {
+	most := 0
+	for rest := src; ; {
+		i := strings.Index(rest, "../")
+		if i < 0 {
+			return most
+		}
+		levels := 0
+		for rest = rest[i:]; strings.HasPrefix(rest, "../"); rest = rest[len("../"):] {
+			levels++
+		}
+		most = max(most, levels)
+	}
+}
+
+// isNestedType reports whether a field is encoded by another type's Encode/Decode
+func isNestedType(schema *Schema, field *Field) bool {
+	typeDef, ok := schema.Types[field.Type]
+	return ok && !typeDef.Bitfield && typeDef.Flags == nil
+}
+
+// passesParents reports whether any of a type's fields hands it to a nested type
+func passesParents(fields []Field) bool {
+	for _, field := range fields {
+		if field.ParentContext || (field.Items != nil && field.Items.ParentContext) {
+			return true
+		}
+	}
+	return false
+}
+
+// contextFor names the context a nested field is encoded or decoded with
+func contextFor(field Field) string {
+	if field.ParentContext {
+		return "childCtx"
+	}
+	return "ctx"
+}
+
+// generateCondition returns the Go form of a field's conditional, "field op value".
+// Local paths read the struct being built (basePath). Parent paths are read from the
+// enclosing struct in ctx first, into a variable emitted before the condition.
+func generateCondition(buf *bytes.Buffer, field Field, basePath, indent string) string {
+	parts := strings.Split(field.Conditional, " ")
+	if len(parts) < 3 {
+		return field.Conditional
+	}
+	path, operator, value := parts[0], parts[1], strings.Join(parts[2:], " ")
+
+	if strings.HasPrefix(path, "../") {
+		refVar := strings.ToLower(field.Name) + "_ref"
+		generateParentRef(buf, field, path, refVar, indent)
+		return fmt.Sprintf("%s %s %s", refVar, operator, value)
+	}
+
+	return fmt.Sprintf("%s.%s %s %s", basePath, field.goPath(path), operator, value)
+}
+
+// parentAccess is how a ../ path reads the enclosing struct it names: one case for each
+// type that can enclose the field's type that many levels up
+type parentAccess struct {
+	levels int
+	cases  []parentCase
+}
+
+// parentCase reads a ../ path from a value of one enclosing type, bound to parent
+type parentCase struct {
+	typeName  string
+	nilChecks []nilCheck // Pointer fields on the way, which must be set
+	value     string     // The field as an int64: "int64(parent.Header.Version)"
+}
+
+// nilCheck is a pointer field a ../ path goes through: its Go expression and schema path
+type nilCheck struct {
+	expr string // "parent.Header"
+	path string // "header"
+}
+
+// resolveParentRefs type-checks every ../ path against the types that can enclose the
+// one it is in, and records in the field how to read it from each of them. A path
+// must name an integer field, through nested structs, that the enclosing type has
+// decoded by the time it decodes the field leading down to the reference.
+func resolveParentRefs(schema *Schema) error {
+	enclosers := make(map[string][]encloser)
+	for name, typeDef := range schema.Types {
+		for i, field := range typeDef.allFields() {
+			for _, target := range []*Field{&field, field.Items} {
+				if target != nil && isNestedType(schema, target) {
+					for _, enclosed := range unionMembers(schema, target.Type) {
+						enclosers[enclosed] = append(enclosers[enclosed], encloser{name, i})
+					}
+				}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(schema.Types))
+	for name := range schema.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, field := range schema.Types[name].fieldPointers() {
+			for _, f := range []*Field{field, field.Items} {
+				if f == nil {
+					continue
+				}
+				for _, ref := range parentPaths(*f) {
+					access, err := resolveParentRef(schema, enclosers, name, ref)
+					if err != nil {
+						return fmt.Errorf("%s.%s: %s: %w", name, f.Name, ref, err)
+					}
+					if f.ParentAccess == nil {
+						f.ParentAccess = make(map[string]*parentAccess)
+					}
+					f.ParentAccess[ref] = access
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// encloser is a type holding another in its field (or the field's items) at index,
+// counting instances after the sequence
+type encloser struct {
+	typeName string
+	index    int
+}
+
+// unionMembers returns the struct types a nested field of type name may hold: the type
+// itself, or a union's variants, since unions add no level
+func unionMembers(schema *Schema, name string) []string {
+	typeDef := schema.Types[name]
+	if typeDef == nil || typeDef.Discriminator == nil {
+		return []string{name}
+	}
+	members := []string{name}
+	for _, variant := range typeDef.Variants {
+		if variant.Type != name {
+			members = append(members, unionMembers(schema, variant.Type)...)
+		}
+	}
+	return members
+}
+
+// parentPaths returns the ../ paths a field's conditional and expressions read
+func parentPaths(field Field) []string {
+	var srcs []string
+	if parts := strings.Split(field.Conditional, " "); len(parts) >= 3 && strings.HasPrefix(parts[0], "../") {
+		srcs = append(srcs, parts[0])
+	}
+	for _, ref := range expressionFields(field) {
+		if strings.HasPrefix(ref, "../") {
+			srcs = append(srcs, ref)
+		}
+	}
+	return srcs
+}
+
+// expressionFields returns the field paths a field's length, count, position and size
+// expressions read
+func expressionFields(field Field) []string {
+	var refs []string
+	exprs := []interface{}{field.Position, field.InstanceSize}
+	if src, ok := lengthSource(field); ok {
+		exprs = append(exprs, src)
+	}
+	for _, expr := range exprs {
+		src, ok := expr.(string)
+		if !ok {
+			continue
+		}
+		node, err := expression.Parse(src)
+		if err != nil {
+			continue // Reported with the expression's other errors when it is generated
+		}
+		refs = append(refs, expression.Fields(node)...)
+	}
+	return refs
+}
+
+// resolveParentRef finds the types enclosing typeName as many levels up as ref goes and
+// how ref reads each of them
+func resolveParentRef(schema *Schema, enclosers map[string][]encloser, typeName, ref string) (*parentAccess, error) {
+	path := ref
+	levels := 0
+	for strings.HasPrefix(path, "../") {
+		levels++
+		path = path[len("../"):]
+	}
+	if strings.HasPrefix(path, "_root.") || path == "" {
+		return nil, fmt.Errorf("not a parent field path")
+	}
+
+	// The enclosers a level up from the types a level down, by the fields holding them
+	level := []encloser{{typeName: typeName}}
+	for i := 0; i < levels; i++ {
+		var up []encloser
+		seen := make(map[encloser]bool)
+		for _, inner := range level {
+			for _, outer := range enclosers[inner.typeName] {
+				if !seen[outer] {
+					seen[outer] = true
+					up = append(up, outer)
+				}
+			}
+		}
+		level = up
+	}
+	if len(level) == 0 {
+		return nil, fmt.Errorf("no type holds %s %d levels up", typeName, levels)
+	}
+
+	access := &parentAccess{levels: levels}
+	byType := make(map[string]int)
+	for _, outer := range level {
+		if _, done := byType[outer.typeName]; done {
+			// Held in several fields: the path must be decoded before each of them
+			if err := checkDecodedBefore(schema, outer, path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		byType[outer.typeName] = len(access.cases)
+		if err := checkDecodedBefore(schema, outer, path); err != nil {
+			return nil, err
+		}
+		c, err := parentFieldAccess(schema, outer.typeName, path)
+		if err != nil {
+			return nil, err
+		}
+		access.cases = append(access.cases, c)
+	}
+	sort.Slice(access.cases, func(i, j int) bool { return access.cases[i].typeName < access.cases[j].typeName })
+	return access, nil
+}
+
+// checkDecodedBefore rejects a path into a field its enclosing type decodes only after
+// the field leading down to the reference, which would read a zero value
+func checkDecodedBefore(schema *Schema, outer encloser, path string) error {
+	fields := schema.Types[outer.typeName].allFields()
+	name := strings.SplitN(path, ".", 2)[0]
+	for i, field := range fields {
+		if field.Name == name && i >= outer.index {
+			return fmt.Errorf("%s decodes %s after %s", outer.typeName, name, fields[outer.index].Name)
+		}
+	}
+	return nil
+}
+
+// parentFieldAccess resolves a dotted path through the fields of typeName and its nested
+// structs to an integer field
+func parentFieldAccess(schema *Schema, typeName, path string) (parentCase, error) {
+	c := parentCase{typeName: typeName}
+	expr := "parent"
+	current := typeName
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		var found *Field
+		for _, field := range schema.Types[current].allFields() {
+			if field.Name == segment {
+				found = &field
+				break
+			}
+		}
+		if found == nil {
+			return c, fmt.Errorf("%s has no field %s", current, segment)
+		}
+		expr += "." + found.goName()
+
+		if i < len(segments)-1 {
+			nested := schema.Types[found.Type]
+			if nested == nil || nested.Discriminator != nil || nested.Flags != nil {
+				return c, fmt.Errorf("%s.%s is not a struct", current, segment)
+			}
+			if found.Pointer {
+				c.nilChecks = append(c.nilChecks, nilCheck{expr, strings.Join(segments[:i+1], ".")})
+			}
+			current = found.Type
+			continue
+		}
+
+		goType, err := mapTypeToGo(*found)
+		if found.FlagsRepr == "" && (err != nil || !integerGoTypes[goType]) {
+			return c, fmt.Errorf("%s.%s is not an integer", current, segment)
+		}
+		c.value = fmt.Sprintf("int64(%s)", expr)
+	}
+	return c, nil
+}
+
+// Go types a ../ path may end in: they convert to the int64 expressions compare
+var integerGoTypes = map[string]bool{"uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"int8": true, "int16": true, "int32": true, "int64": true}
+
+// generateParentRef emits refVar, the int64 the ../ path ref reads from the enclosing
+// struct in ctx, switching on its type
+func generateParentRef(buf *bytes.Buffer, field Field, ref, refVar, indent string) {
+	access := field.ParentAccess[ref]
+	buf.WriteString(fmt.Sprintf("%svar %s int64\n", indent, refVar))
+	buf.WriteString(fmt.Sprintf("%sswitch parent := ctx.ParentStruct(%d).(type) {\n", indent, access.levels))
+	for _, c := range access.cases {
+		buf.WriteString(fmt.Sprintf("%scase *%s:\n", indent, c.typeName))
+		for _, check := range c.nilChecks {
+			buf.WriteString(fmt.Sprintf("%s\tif %s == nil {\n", indent, check.expr))
+			buf.WriteString(fmt.Sprintf("%s\t\treturn nil, fmt.Errorf(\"%s: %s: %s is nil\")\n", indent, field.Name, ref, check.path))
+			buf.WriteString(fmt.Sprintf("%s\t}\n", indent))
+		}
+		buf.WriteString(fmt.Sprintf("%s\t%s = %s\n", indent, refVar, c.value))
+	}
+	buf.WriteString(fmt.Sprintf("%sdefault:\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %s: no parent %d levels up\")\n", indent, field.Name, ref, access.levels))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
@@ -0,0 +1,505 @@
+// ABOUTME: Protobuf messages: types marked "protobuf" encode as tagged fields in the protobuf wire format
+// ABOUTME: They interoperate with other protobuf implementations and embed length-delimited in other types
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// How a protobuf field's values are encoded after its tag
+type protoEncoding struct {
+	wire   string // runtime constant of the wire type
+	scalar string // "varint", "zigzag", "fixed32", "fixed64", "float", "double", "bytes", "message"
+}
+
+// Wire encodings of the integer fields' proto_type values; plain varints have none
+var protoIntTypes = map[string]protoEncoding{
+	"sint32":   {"runtime.WireVarint", "zigzag"},
+	"sint64":   {"runtime.WireVarint", "zigzag"},
+	"fixed32":  {"runtime.WireFixed32", "fixed32"},
+	"sfixed32": {"runtime.WireFixed32", "fixed32"},
+	"fixed64":  {"runtime.WireFixed64", "fixed64"},
+	"sfixed64": {"runtime.WireFixed64", "fixed64"},
+}
+
+// markProtobufFields sets ProtobufMessage on fields and array items referencing a
+// protobuf message, and gives array items their array's proto_type
+func markProtobufFields(schema *Schema) {
+	var mark func(field *Field)
+	mark = func(field *Field) {
+		if typeDef, ok := schema.Types[field.Type]; ok && typeDef.Protobuf {
+			field.ProtobufMessage = true
+		}
+		if field.Items != nil {
+			if field.Items.ProtoType == "" {
+				field.Items.ProtoType = field.ProtoType
+			}
+			mark(field.Items)
+		}
+	}
+	for _, typeDef := range schema.Types {
+		for _, field := range typeDef.fieldPointers() {
+			mark(field)
+		}
+	}
+}
+
+// protoEncodingOf returns how a field or array item of a protobuf message is encoded.
+// Nested types are length-delimited: protobuf messages as such, other types as their
+// own encoding.
+func protoEncodingOf(field Field) (protoEncoding, error) {
+	switch field.Type {
+	case "uint8", "uint16", "uint32", "uint64", "int8", "int16", "int32", "int64":
+		if field.ProtoType == "" {
+			return protoEncoding{"runtime.WireVarint", "varint"}, nil
+		}
+		if enc, ok := protoIntTypes[field.ProtoType]; ok {
+			return enc, nil
+		}
+		return protoEncoding{}, fmt.Errorf("%s: unknown proto_type %q", field.Name, field.ProtoType)
+	case "float32":
+		return protoEncoding{"runtime.WireFixed32", "float"}, nil
+	case "float64":
+		return protoEncoding{"runtime.WireFixed64", "double"}, nil
+	case "string":
+		return protoEncoding{"runtime.WireBytes", "bytes"}, nil
+	}
+	if builtinTypes[field.Type] || field.Union || field.Bitfield || field.FlagsRepr != "" {
+		return protoEncoding{}, fmt.Errorf("%s: type %s can't be a protobuf message field", field.Name, field.Type)
+	}
+	return protoEncoding{"runtime.WireBytes", "message"}, nil
+}
+
+// isProtoBytes reports whether an array field is a protobuf bytes field: one value, not repeated
+func isProtoBytes(field Field) bool {
+	return field.Type == "array" && field.Items != nil && field.Items.Type == "uint8"
+}
+
+// generateEncodeProtobuf emits EncodeWithContext of a protobuf message: each field that
+// isn't empty as a tag and value, in field order, as proto3 encoders write them
+func generateEncodeProtobuf(buf *bytes.Buffer, typeName string, typeDef *TypeDef) error {
+	recv := typeDef.receiver()
+	buf.WriteString(fmt.Sprintf("func (%s *%s) EncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error) {\n", recv, typeName))
+	buf.WriteString("\tencoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)\n")
+	buf.WriteString("\tencoder.SetLimit(ctx.OutputRemaining())\n\n")
+
+	for _, field := range typeDef.Sequence {
+		fieldName := recv + "." + field.goName()
+		buf.WriteString(fmt.Sprintf("\t// %s = %d\n", field.Name, field.FieldNumber))
+
+		if isProtoBytes(field) {
+			buf.WriteString(fmt.Sprintf("\tif len(%s) > 0 {\n", fieldName))
+			buf.WriteString(fmt.Sprintf("\t\tencoder.WriteProtobufTag(%d, runtime.WireBytes)\n", field.FieldNumber))
+			buf.WriteString(fmt.Sprintf("\t\tencoder.WriteProtobufBytes(%s)\n", fieldName))
+			buf.WriteString("\t}\n\n")
+			continue
+		}
+
+		if field.Type == "array" {
+			enc, err := protoEncodingOf(*field.Items)
+			if err != nil {
+				return err
+			}
+			if enc.wire == "runtime.WireBytes" {
+				// Repeated strings and messages: a tagged value per item
+				buf.WriteString(fmt.Sprintf("\tfor i := range %s {\n", fieldName))
+				generateLimitCheck(buf, "\t\t")
+				if err := generateEncodeProtoValue(buf, *field.Items, field.FieldNumber, field.Name, fmt.Sprintf("%s[i]", fieldName), "\t\t"); err != nil {
+					return err
+				}
+				buf.WriteString("\t}\n\n")
+				continue
+			}
+			// Repeated numbers are packed into one length-delimited value
+			packed := strings.ToLower(field.Name) + "_packed"
+			buf.WriteString(fmt.Sprintf("\tif len(%s) > 0 {\n", fieldName))
+			buf.WriteString(fmt.Sprintf("\t\t%s := runtime.NewBitStreamEncoder(runtime.MSBFirst)\n", packed))
+			buf.WriteString(fmt.Sprintf("\t\tfor _, v := range %s {\n", fieldName))
+			generateWriteProtoScalar(buf, *field.Items, enc, "v", packed, "\t\t\t")
+			buf.WriteString("\t\t}\n")
+			buf.WriteString(fmt.Sprintf("\t\tencoder.WriteProtobufTag(%d, runtime.WireBytes)\n", field.FieldNumber))
+			buf.WriteString(fmt.Sprintf("\t\tencoder.WriteProtobufBytes(%s.Finish())\n", packed))
+			buf.WriteString("\t}\n\n")
+			continue
+		}
+
+		enc, err := protoEncodingOf(field)
+		if err != nil {
+			return err
+		}
+		switch {
+		case enc.scalar == "message" && !field.Pointer:
+			// Held by value, so left out when empty, as an unset message is
+			bytesVar := strings.ToLower(field.Name) + "_bytes"
+			generateEncodeProtoMessage(buf, field.Name, fieldName, bytesVar, "\t")
+			buf.WriteString(fmt.Sprintf("\tif len(%s) > 0 {\n", bytesVar))
+			buf.WriteString(fmt.Sprintf("\t\tencoder.WriteProtobufTag(%d, runtime.WireBytes)\n", field.FieldNumber))
+			buf.WriteString(fmt.Sprintf("\t\tencoder.WriteProtobufBytes(%s)\n", bytesVar))
+			buf.WriteString("\t}\n\n")
+			continue
+		case enc.scalar == "message":
+			buf.WriteString(fmt.Sprintf("\tif %s != nil {\n", fieldName))
+		case enc.scalar == "bytes":
+			buf.WriteString(fmt.Sprintf("\tif len(%s) > 0 {\n", fieldName))
+		case enc.scalar == "float":
+			// Bits, so -0 is written as protobuf does
+			buf.WriteString(fmt.Sprintf("\tif math.Float32bits(%s) != 0 {\n", fieldName))
+		case enc.scalar == "double":
+			buf.WriteString(fmt.Sprintf("\tif math.Float64bits(%s) != 0 {\n", fieldName))
+		default:
+			buf.WriteString(fmt.Sprintf("\tif %s != 0 {\n", fieldName))
+		}
+		if err := generateEncodeProtoValue(buf, field, field.FieldNumber, field.Name, fieldName, "\t\t"); err != nil {
+			return err
+		}
+		buf.WriteString("\t}\n\n")
+	}
+
+	buf.WriteString("\treturn encoder.Result()\n")
+	buf.WriteString("}\n\n")
+	return nil
+}
+
+// generateEncodeProtoValue writes the tag and value of one field or repeated item
+func generateEncodeProtoValue(buf *bytes.Buffer, field Field, fieldNumber int, name, value, indent string) error {
+	enc, err := protoEncodingOf(field)
+	if err != nil {
+		return err
+	}
+	if enc.scalar == "message" {
+		bytesVar := strings.ToLower(name) + "_bytes"
+		generateEncodeProtoMessage(buf, name, value, bytesVar, indent)
+		buf.WriteString(fmt.Sprintf("%sencoder.WriteProtobufTag(%d, runtime.WireBytes)\n", indent, fieldNumber))
+		buf.WriteString(fmt.Sprintf("%sencoder.WriteProtobufBytes(%s)\n", indent, bytesVar))
+		return nil
+	}
+	buf.WriteString(fmt.Sprintf("%sencoder.WriteProtobufTag(%d, %s)\n", indent, fieldNumber, enc.wire))
+	generateWriteProtoScalar(buf, field, enc, value, "encoder", indent)
+	return nil
+}
+
+// generateEncodeProtoMessage emits bytesVar := the encoding of a nested type
+func generateEncodeProtoMessage(buf *bytes.Buffer, name, value, bytesVar, indent string) {
+	buf.WriteString(fmt.Sprintf("%s%s, err := %s.EncodeWithContext(nil)\n", indent, bytesVar, value))
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%w\", err)\n", indent, name))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
+// generateWriteProtoScalar writes a number or string without its tag
+func generateWriteProtoScalar(buf *bytes.Buffer, field Field, enc protoEncoding, value, encoder, indent string) {
+	signed := strings.HasPrefix(field.Type, "int")
+	switch enc.scalar {
+	case "varint":
+		if signed {
+			// Negative numbers are sign-extended to 64 bits, as protobuf's int32 is
+			value = fmt.Sprintf("int64(%s)", value)
+		}
+		buf.WriteString(fmt.Sprintf("%s%s.WriteVarlengthLEB128(uint64(%s))\n", indent, encoder, value))
+	case "zigzag":
+		if field.ProtoType == "sint32" {
+			buf.WriteString(fmt.Sprintf("%s%s.WriteVarlengthLEB128(runtime.ZigZag32(int32(%s)))\n", indent, encoder, value))
+		} else {
+			buf.WriteString(fmt.Sprintf("%s%s.WriteVarlengthLEB128(runtime.ZigZag64(int64(%s)))\n", indent, encoder, value))
+		}
+	case "fixed32":
+		buf.WriteString(fmt.Sprintf("%s%s.WriteUint32(uint32(%s), runtime.LittleEndian)\n", indent, encoder, value))
+	case "fixed64":
+		buf.WriteString(fmt.Sprintf("%s%s.WriteUint64(uint64(%s), runtime.LittleEndian)\n", indent, encoder, value))
+	case "float":
+		buf.WriteString(fmt.Sprintf("%s%s.WriteFloat32(%s, runtime.LittleEndian)\n", indent, encoder, canonicalFloat(field, value)))
+	case "double":
+		buf.WriteString(fmt.Sprintf("%s%s.WriteFloat64(%s, runtime.LittleEndian)\n", indent, encoder, canonicalFloat(field, value)))
+	case "bytes":
+		buf.WriteString(fmt.Sprintf("%s%s.WriteProtobufBytes([]byte(%s))\n", indent, encoder, value))
+	}
+}
+
+// generateDecodeProtobuf emits the body of decodeXInto for a protobuf message: fields
+// are read by tag until the end of the input, in any order. Fields the schema doesn't
+// know are skipped, and repeated numbers are read packed or not.
+func generateDecodeProtobuf(buf *bytes.Buffer, typeDef *TypeDef, opts GenerateOptions) error {
+	buf.WriteString("\tfor !decoder.AtEnd() {\n")
+	generateCancelCheck(buf, "\t\t")
+	buf.WriteString("\t\tfieldNumber, wireType, err := decoder.ReadProtobufTag()\n")
+	buf.WriteString("\t\tif err != nil {\n")
+	buf.WriteString("\t\t\treturn nil, err\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t\tswitch fieldNumber {\n")
+
+	for _, field := range typeDef.Sequence {
+		fieldName := field.goName()
+		varName := field.localName()
+		buf.WriteString(fmt.Sprintf("\t\tcase %d: // %s\n", field.FieldNumber, field.Name))
+
+		if isProtoBytes(field) {
+			generateCheckWireType(buf, "runtime.WireBytes", "\t\t\t")
+			generateReadView(buf, varName, "ReadProtobufBytes()", "\t\t\t")
+			// Copied out of the input, reusing the slice's capacity
+			buf.WriteString(fmt.Sprintf("\t\t\tresult.%s = append(result.%s[:0], %s...)\n", fieldName, fieldName, varName))
+			continue
+		}
+
+		if field.Type == "array" {
+			if err := generateDecodeProtoRepeated(buf, field, fieldName, varName); err != nil {
+				return err
+			}
+			continue
+		}
+
+		enc, err := protoEncodingOf(field)
+		if err != nil {
+			return err
+		}
+		generateCheckWireType(buf, enc.wire, "\t\t\t")
+		if enc.scalar == "message" {
+			if err := generateDecodeProtoMessage(buf, field, "result."+fieldName, varName, "\t\t\t"); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := generateReadProtoScalar(buf, field, enc, varName, "\t\t\t"); err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("\t\t\tresult.%s = %s\n", fieldName, varName))
+	}
+
+	buf.WriteString("\t\tdefault:\n")
+	buf.WriteString("\t\t\tif err := decoder.SkipProtobufField(wireType); err != nil {\n")
+	buf.WriteString("\t\t\t\treturn nil, err\n")
+	buf.WriteString("\t\t\t}\n")
+	buf.WriteString("\t\t}\n")
+	buf.WriteString("\t}\n\n")
+
+	for _, field := range typeDef.Sequence {
+		if err := generateNormalizeSlice(buf, field, opts.EmptySlices); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateDecodeProtoRepeated emits the case of a repeated field: strings and messages
+// come a value per tag; numbers may also come packed in one length-delimited value
+func generateDecodeProtoRepeated(buf *bytes.Buffer, field Field, fieldName, varName string) error {
+	item := *field.Items
+	enc, err := protoEncodingOf(item)
+	if err != nil {
+		return err
+	}
+
+	if enc.scalar == "message" {
+		generateCheckWireType(buf, "runtime.WireBytes", "\t\t\t")
+		buf.WriteString(fmt.Sprintf("\t\t\tresult.%s = runtime.Extend(decoder.Arena, result.%s)\n", fieldName, fieldName))
+		return generateDecodeProtoMessage(buf, item, fmt.Sprintf("result.%s[len(result.%s)-1]", fieldName, fieldName), varName, "\t\t\t")
+	}
+	if enc.scalar == "bytes" {
+		generateCheckWireType(buf, "runtime.WireBytes", "\t\t\t")
+		if err := generateReadProtoScalar(buf, item, enc, varName, "\t\t\t"); err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("\t\t\tresult.%s = append(result.%s, %s)\n", fieldName, fieldName, varName))
+		return nil
+	}
+
+	buf.WriteString("\t\t\tif wireType == runtime.WireBytes {\n")
+	buf.WriteString(fmt.Sprintf("\t\t\t\t%s_length, err := decoder.ReadProtobufLength()\n", varName))
+	buf.WriteString("\t\t\t\tif err != nil {\n")
+	buf.WriteString("\t\t\t\t\treturn nil, err\n")
+	buf.WriteString("\t\t\t\t}\n")
+	buf.WriteString(fmt.Sprintf("\t\t\t\t%s_limit, err := decoder.LimitBytes(%s_length)\n", varName, varName))
+	buf.WriteString("\t\t\t\tif err != nil {\n")
+	buf.WriteString("\t\t\t\t\treturn nil, err\n")
+	buf.WriteString("\t\t\t\t}\n")
+	buf.WriteString("\t\t\t\tfor !decoder.AtEnd() {\n")
+	generateCancelCheck(buf, "\t\t\t\t\t")
+	if err := generateReadProtoScalar(buf, item, enc, varName, "\t\t\t\t\t"); err != nil {
+		return err
+	}
+	buf.WriteString(fmt.Sprintf("\t\t\t\t\tresult.%s = append(result.%s, %s)\n", fieldName, fieldName, varName))
+	buf.WriteString("\t\t\t\t}\n")
+	buf.WriteString(fmt.Sprintf("\t\t\t\tdecoder.RestoreLimit(%s_limit)\n", varName))
+	buf.WriteString("\t\t\t\tcontinue\n")
+	buf.WriteString("\t\t\t}\n")
+	generateCheckWireType(buf, enc.wire, "\t\t\t")
+	if err := generateReadProtoScalar(buf, item, enc, varName, "\t\t\t"); err != nil {
+		return err
+	}
+	buf.WriteString(fmt.Sprintf("\t\t\tresult.%s = append(result.%s, %s)\n", fieldName, fieldName, varName))
+	return nil
+}
+
+// generateCheckWireType emits the check that a known field came with its wire type
+func generateCheckWireType(buf *bytes.Buffer, wire, indent string) {
+	buf.WriteString(fmt.Sprintf("%sif err := decoder.CheckWireType(fieldNumber, wireType, %s); err != nil {\n", indent, wire))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
+// generateReadProtoScalar emits varName := the next number or string, of the field's Go type
+func generateReadProtoScalar(buf *bytes.Buffer, field Field, enc protoEncoding, varName, indent string) error {
+	goType, err := mapTypeToGo(field)
+	if err != nil {
+		return err
+	}
+	raw := varName + "_raw"
+	var value string
+	switch enc.scalar {
+	case "varint":
+		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadVarlengthLEB128()\n", indent, raw))
+		value = fmt.Sprintf("%s(%s)", goType, raw)
+	case "zigzag":
+		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadVarlengthLEB128()\n", indent, raw))
+		if field.ProtoType == "sint32" {
+			value = fmt.Sprintf("%s(runtime.UnZigZag32(%s))", goType, raw)
+		} else {
+			value = fmt.Sprintf("%s(runtime.UnZigZag64(%s))", goType, raw)
+		}
+	case "fixed32":
+		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadUint32(runtime.LittleEndian)\n", indent, raw))
+		value = fmt.Sprintf("%s(%s)", goType, raw)
+	case "fixed64":
+		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadUint64(runtime.LittleEndian)\n", indent, raw))
+		value = fmt.Sprintf("%s(%s)", goType, raw)
+	case "float":
+		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadFloat32(runtime.LittleEndian)\n", indent, raw))
+		value = raw
+	case "double":
+		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadFloat64(runtime.LittleEndian)\n", indent, raw))
+		value = raw
+	case "bytes":
+		buf.WriteString(fmt.Sprintf("%s%s, err := decoder.ReadProtobufBytes()\n", indent, raw))
+		value = fmt.Sprintf("%s(%s)", goType, raw)
+	}
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	if field.UTF8Policy != "" {
+		generateUTF8Conversion(buf, field, varName, raw, indent)
+		return nil
+	}
+	buf.WriteString(fmt.Sprintf("%s%s := %s\n", indent, varName, value))
+	return nil
+}
+
+// generateDecodeProtoMessage emits the decoding of a length-delimited nested type into
+// target, with the input ending where it does
+func generateDecodeProtoMessage(buf *bytes.Buffer, field Field, target, varName, indent string) error {
+	buf.WriteString(fmt.Sprintf("%s%s_length, err := decoder.ReadProtobufLength()\n", indent, varName))
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	return generateDecodeLimited(buf, field, target, varName, varName+"_length", indent)
+}
+
+// generateDecodeLimited decodes a nested type from the next length bytes into target.
+// Pointers are set to a new value; values held in place are decoded into.
+func generateDecodeLimited(buf *bytes.Buffer, field Field, target, varName, length, indent string) error {
+	typeName := capitalizeFirst(field.Type)
+	buf.WriteString(fmt.Sprintf("%s%s_limit, err := decoder.LimitBytes(%s)\n", indent, varName, length))
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	if field.Pointer {
+		buf.WriteString(fmt.Sprintf("%s%s, err := decode%sWithDecoder(decoder, nil)\n", indent, varName, typeName))
+		buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%w\", err)\n", indent, field.Name))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		buf.WriteString(fmt.Sprintf("%s%s = %s\n", indent, target, varName))
+	} else {
+		buf.WriteString(fmt.Sprintf("%sif _, err := decode%sInto(decoder, nil, &%s); err != nil {\n", indent, typeName, target))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%w\", err)\n", indent, field.Name))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	}
+	buf.WriteString(fmt.Sprintf("%sdecoder.RestoreLimit(%s_limit)\n", indent, varName))
+	return nil
+}
+
+// Reads of the length before a protobuf message embedded in another type, or a lazy field
+var lengthPrefixReads = map[string]string{
+	"uint8":  "decoder.ReadUint8()",
+	"uint16": "decoder.ReadUint16(runtime.%s)",
+	"uint32": "decoder.ReadUint32(runtime.%s)",
+	"uint64": "decoder.ReadUint64(runtime.%s)",
+}
+
+// Writes of the length before a protobuf message embedded in another type
+var lengthPrefixWrites = map[string]string{
+	"uint8":  "encoder.WriteUint8(uint8(len(%[1]s)))",
+	"uint16": "encoder.WriteUint16(uint16(len(%[1]s)), runtime.%[2]s)",
+	"uint32": "encoder.WriteUint32(uint32(len(%[1]s)), runtime.%[2]s)",
+	"uint64": "encoder.WriteUint64(uint64(len(%[1]s)), runtime.%[2]s)",
+}
+
+// generateDecodeEmbeddedProtobuf decodes a protobuf message field of a type that isn't
+// one. Protobuf messages don't mark their end, so the field's kind says where it is:
+// after a length prefix, at a length given by other fields, or at the end of the input.
+func generateDecodeEmbeddedProtobuf(buf *bytes.Buffer, field Field, fieldName, varName, endianness, indent string) error {
+	target := "result." + fieldName
+	if fieldName == "" {
+		return fmt.Errorf("%s: arrays of protobuf messages are only supported in protobuf messages", field.Name)
+	}
+	lengthVar := varName + "_length"
+	switch field.Kind {
+	case "length_prefixed":
+		lengthType := field.LengthType
+		if lengthType == "" {
+			lengthType = "uint8"
+		}
+		read, ok := lengthPrefixReads[lengthType]
+		if !ok {
+			return fmt.Errorf("%s: unsupported length_type %q", field.Name, lengthType)
+		}
+		if strings.Contains(read, "%s") {
+			read = fmt.Sprintf(read, mapEndianness(endianness))
+		}
+		buf.WriteString(fmt.Sprintf("%s%s, err := %s\n", indent, lengthVar, read))
+		buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	case "field_referenced":
+		if err := generateLength(buf, field, field.LengthField, "result", lengthVar, indent); err != nil {
+			return err
+		}
+	case "":
+		// The rest of the input
+		return generateDecodeNestedStruct(buf, field, fieldName, varName, indent)
+	default:
+		return fmt.Errorf("%s: protobuf message fields need kind length_prefixed or field_referenced, got %q", field.Name, field.Kind)
+	}
+	if err := generateDecodeLimited(buf, field, target, varName, fmt.Sprintf("int(%s)", lengthVar), indent); err != nil {
+		return err
+	}
+	buf.WriteString("\n")
+	return nil
+}
+
+// generateEncodeEmbeddedProtobuf encodes a protobuf message field of a type that isn't
+// one, after its length prefix if it has one
+func generateEncodeEmbeddedProtobuf(buf *bytes.Buffer, field Field, fieldName, endianness, indent string) error {
+	bytesVar := strings.ReplaceAll(strings.ReplaceAll(fieldName, ".", "_"), "m_", "") + "_bytes"
+	if field.Pointer {
+		buf.WriteString(fmt.Sprintf("%sif %s == nil {\n", indent, fieldName))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: nested %s is nil\")\n", indent, field.Name, field.Type))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	}
+	buf.WriteString(fmt.Sprintf("%s%s, err := %s.EncodeWithContext(nil)\n", indent, bytesVar, fieldName))
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%w\", err)\n", indent, field.Name))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	if field.Kind == "length_prefixed" {
+		lengthType := field.LengthType
+		if lengthType == "" {
+			lengthType = "uint8"
+		}
+		write, ok := lengthPrefixWrites[lengthType]
+		if !ok {
+			return fmt.Errorf("%s: unsupported length_type %q", field.Name, lengthType)
+		}
+		buf.WriteString(fmt.Sprintf("%s%s\n", indent, fmt.Sprintf(write, bytesVar, mapEndianness(endianness))))
+	}
+	buf.WriteString(fmt.Sprintf("%sencoder.WriteBytes(%s)\n", indent, bytesVar))
+	return nil
+}
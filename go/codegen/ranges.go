@@ -0,0 +1,171 @@
+// ABOUTME: Byte-range computed fields: lengths and CRC32s of the encoded bytes from one field through another
+// ABOUTME: Range ends are tracked while encoding; a field written before its range ends is patched in after it
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// ByteRange is a computed field over the encoded bytes of a run of sibling fields, from
+// the start of From through the end of To
+type ByteRange struct {
+	Of   string // "length_of" or "crc32_of"
+	From string // First field of the range, or "" for the struct's first
+	To   string // Last field of the range, or "" for the struct's last
+}
+
+// parseByteRange returns the range a computed field covers: a length_of with from or to,
+// or a crc32_of, whose target is a range of one sibling field. Other computed fields,
+// and crc32_of targets elsewhere ("../body"), have none.
+func parseByteRange(computed map[string]interface{}) *ByteRange {
+	of, _ := computed["type"].(string)
+	from, hasFrom := computed["from"].(string)
+	to, hasTo := computed["to"].(string)
+	target, hasTarget := computed["target"].(string)
+	switch {
+	case of == "crc32_of" && hasTarget:
+		if !isIdentifier(target) {
+			return nil
+		}
+		return &ByteRange{Of: of, From: target, To: target}
+	case of == "crc32_of" || (of == "length_of" && (hasFrom || hasTo)):
+		return &ByteRange{Of: of, From: from, To: to}
+	}
+	return nil
+}
+
+// rangeField is a byte-range field of a struct with the sequence indexes of its range
+type rangeField struct {
+	field    Field
+	index    int
+	from, to int
+}
+
+// rangeFixups tracks a struct's byte-range fields while its encoder is generated. A
+// range is measured from bit positions recorded around its fields. A field after its
+// range is written directly; one before its end, inside the range included, is a zero
+// placeholder while the range is encoded, patched once it is.
+type rangeFixups struct {
+	ranges []rangeField
+}
+
+func newRangeFixups(typeDef *TypeDef) (*rangeFixups, error) {
+	r := &rangeFixups{}
+	for i, field := range typeDef.Sequence {
+		if field.ByteRange == nil {
+			continue
+		}
+		switch {
+		case field.ByteRange.Of == "crc32_of" && field.Type != "uint32":
+			return nil, fmt.Errorf("%s: crc32_of needs a uint32 field, not %s", field.Name, field.Type)
+		case uintBytes[field.Type] == 0:
+			return nil, fmt.Errorf("%s: length_of a byte range needs an unsigned integer type, not %s", field.Name, field.Type)
+		case field.Conditional != "":
+			return nil, fmt.Errorf("%s: conditional byte-range fields are not supported", field.Name)
+		}
+		from, to := 0, len(typeDef.Sequence)-1
+		for j, seq := range typeDef.Sequence {
+			if seq.Name == field.ByteRange.From {
+				from = j
+			}
+			if seq.Name == field.ByteRange.To {
+				to = j
+			}
+		}
+		for _, end := range []string{field.ByteRange.From, field.ByteRange.To} {
+			if _, ok := findSequenceField(typeDef, end); end != "" && !ok {
+				return nil, fmt.Errorf("%s: %s range field %q is not a field of this struct", field.Name, field.ByteRange.Of, end)
+			}
+		}
+		if from > to {
+			return nil, fmt.Errorf("%s: %s range starts at %s, after its end %s", field.Name, field.ByteRange.Of, typeDef.Sequence[from].Name, typeDef.Sequence[to].Name)
+		}
+		r.ranges = append(r.ranges, rangeField{field: field, index: i, from: from, to: to})
+	}
+	return r, nil
+}
+
+// generateStart emits the start position of the ranges starting at the field with
+// sequence index i, before it is written
+func (r *rangeFixups) generateStart(buf *bytes.Buffer, i int) {
+	for _, rf := range r.ranges {
+		if rf.from == i {
+			buf.WriteString(fmt.Sprintf("\t%s_range_start := encoder.BitPosition()\n", strings.ToLower(rf.field.Name)))
+		}
+	}
+}
+
+// generateEnd emits the end position of the ranges ending at the field with sequence
+// index i, after it is written, and patches the placeholders of fields before it
+func (r *rangeFixups) generateEnd(buf *bytes.Buffer, i int, defaultEndianness string) {
+	for _, rf := range r.ranges {
+		if rf.to != i {
+			continue
+		}
+		name := strings.ToLower(rf.field.Name)
+		buf.WriteString(fmt.Sprintf("\t%s_range_end := encoder.BitPosition()\n", name))
+		if rf.index > i {
+			continue
+		}
+		value := generateRangeValue(buf, rf.field)
+		slotVar := name + "_slot"
+		if rf.field.Type == "uint8" {
+			buf.WriteString(fmt.Sprintf("\tencoder.PatchUint8(%s, uint8(%s))\n", slotVar, value))
+			continue
+		}
+		endianness := rf.field.Endianness
+		if endianness == "" {
+			endianness = defaultEndianness
+		}
+		buf.WriteString(fmt.Sprintf("\tencoder.Patch%s(%s, %s(%s), runtime.%s)\n", capitalizeFirst(rf.field.Type), slotVar, rf.field.Type, value, mapEndianness(endianness)))
+	}
+}
+
+// generateEncode emits a byte-range field: its value if the range has been written,
+// otherwise a placeholder for it
+func (r *rangeFixups) generateEncode(buf *bytes.Buffer, field Field, i int, defaultEndianness string) error {
+	for _, rf := range r.ranges {
+		if rf.index != i {
+			continue
+		}
+		if rf.to < i {
+			value := generateRangeValue(buf, field)
+			endianness := field.Endianness
+			if endianness == "" {
+				endianness = defaultEndianness
+			}
+			return generateEncodeFieldImpl(buf, field, fmt.Sprintf("%s(%s)", field.Type, value), endianness, mapEndianness(endianness), "\t")
+		}
+		slotVar := strings.ToLower(field.Name) + "_slot"
+		buf.WriteString(fmt.Sprintf("\t%s, err := encoder.ReservePlaceholder(%d)\n", slotVar, uintBytes[field.Type]))
+		buf.WriteString("\tif err != nil {\n")
+		buf.WriteString(fmt.Sprintf("\t\treturn nil, fmt.Errorf(\"%s: %%w\", err)\n", field.Name))
+		buf.WriteString("\t}\n")
+	}
+	return nil
+}
+
+// generateRangeValue emits the bytes of a field's range and returns the expression of
+// its value: their CRC32, or their length plus the field's offset, checked to fit it
+func generateRangeValue(buf *bytes.Buffer, field Field) string {
+	name := strings.ToLower(field.Name)
+	buf.WriteString(fmt.Sprintf("\t%s_range, err := encoder.Span(%s_range_start, %s_range_end)\n", name, name, name))
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString(fmt.Sprintf("\t\treturn nil, fmt.Errorf(\"%s: %%w\", err)\n", field.Name))
+	buf.WriteString("\t}\n")
+	if field.ByteRange.Of == "crc32_of" {
+		return fmt.Sprintf("runtime.CRC32(%s_range)", name)
+	}
+	lengthVar := name + "_length"
+	buf.WriteString(fmt.Sprintf("\t%s := len(%s_range) + %d\n", lengthVar, name, field.ComputedOffset))
+	check := fmt.Sprintf("%s < 0", lengthVar)
+	if field.Type != "uint64" {
+		check += fmt.Sprintf(" || uint64(%s) > %s", lengthVar, positionFieldMax[field.Type])
+	}
+	buf.WriteString(fmt.Sprintf("\tif %s {\n", check))
+	buf.WriteString(fmt.Sprintf("\t\treturn nil, fmt.Errorf(\"%s: length %%d doesn't fit in %s\", %s)\n", field.Name, field.Type, lengthVar))
+	buf.WriteString("\t}\n")
+	return lengthVar
+}
@@ -0,0 +1,118 @@
+// ABOUTME: Walks the type reference graph of a schema: orders types dependencies-first and
+// ABOUTME: marks recursive types and the fields that must become pointers to break by-value cycles
+package codegen
+
+import "sort"
+
+// typeRef is an edge in the type reference graph
+type typeRef struct {
+	target  string
+	byValue bool // Embedded directly in the struct (not through a slice)
+}
+
+// markRecursiveTypes annotates the schema so generated code compiles and terminates:
+//   - TypeDef.Recursive is set for every type that can reach itself through any
+//     chain of references (decoders for these get nesting-depth checks)
+//   - Field.Pointer is set for fields whose by-value embedding would give a struct
+//     infinite size (a cycle consisting only of by-value references)
+//
+// References through arrays are slices in Go and already break size cycles.
+func markRecursiveTypes(schema *Schema) {
+	edges := make(map[string][]typeRef)
+	for name, typeDef := range schema.Types {
+		for _, field := range typeDef.allFields() {
+			edges[name] = append(edges[name], fieldRefs(schema, field, true)...)
+		}
+		// A union holds a pointer to its variant, so it never embeds it by value
+		for _, variant := range typeDef.Variants {
+			edges[name] = append(edges[name], typeRef{target: variant.Type})
+		}
+	}
+
+	for name, typeDef := range schema.Types {
+		typeDef.Recursive = reaches(edges, name, name, false)
+
+		for _, field := range typeDef.fieldPointers() {
+			if _, isType := schema.Types[field.Type]; !isType {
+				continue
+			}
+			if field.Type == name || reaches(edges, field.Type, name, true) {
+				field.Pointer = true
+			}
+		}
+	}
+}
+
+// fieldRefs lists the schema types referenced by a field (or array item)
+func fieldRefs(schema *Schema, field Field, byValue bool) []typeRef {
+	if field.Type == "array" {
+		if field.Items == nil {
+			return nil
+		}
+		return fieldRefs(schema, *field.Items, false)
+	}
+	if _, isType := schema.Types[field.Type]; isType {
+		return []typeRef{{target: field.Type, byValue: byValue}}
+	}
+	return nil
+}
+
+// reaches reports whether target is reachable from start (following at least one edge).
+// If valueOnly is set, only by-value edges are followed.
+func reaches(edges map[string][]typeRef, start, target string, valueOnly bool) bool {
+	visited := make(map[string]bool)
+	stack := []string{start}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, edge := range edges[current] {
+			if valueOnly && !edge.byValue {
+				continue
+			}
+			if edge.target == target {
+				return true
+			}
+			if !visited[edge.target] {
+				visited[edge.target] = true
+				stack = append(stack, edge.target)
+			}
+		}
+	}
+	return false
+}
+
+// typeOrder returns the schema's type names with each type after the types it
+// references, so generated code reads bottom-up. Ties, and types in a reference
+// cycle, keep name order, so regenerating an unchanged schema gives the same file.
+func typeOrder(schema *Schema) []string {
+	names := make([]string, 0, len(schema.Types))
+	for name := range schema.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	order := make([]string, 0, len(names))
+	visited := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, field := range schema.Types[name].allFields() {
+			for _, ref := range fieldRefs(schema, field, true) {
+				visit(ref.target)
+			}
+		}
+		for _, variant := range schema.Types[name].Variants {
+			if _, isType := schema.Types[variant.Type]; isType {
+				visit(variant.Type)
+			}
+		}
+		order = append(order, name)
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return order
+}
@@ -0,0 +1,85 @@
+// ABOUTME: Snapshot tests comparing generated Go source with golden files in testdata/snapshots
+// ABOUTME: Run with -update to rewrite the golden files after an intended change to generated code
+package codegen
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/snapshots")
+
+// snapshotCase is one testdata/snapshots/<name>.json: a schema, the type to generate
+// it for, and the options to generate with
+type snapshotCase struct {
+	Type    string                 `json:"type"`
+	Options GenerateOptions        `json:"options"`
+	Schema  map[string]interface{} `json:"schema"`
+}
+
+// TestGenerateSnapshots generates every schema in testdata/snapshots and compares the
+// code with <name>.go.golden next to it, so refactors can't change the output
+// unnoticed. After a change to generated code that is intended:
+//
+//	go test ./codegen -run TestGenerateSnapshots -update
+func TestGenerateSnapshots(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "snapshots", "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths, "no snapshot schemas in testdata/snapshots")
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			var snapshot snapshotCase
+			require.NoError(t, json.Unmarshal(data, &snapshot))
+
+			code, err := GenerateGoWithOptions(snapshot.Schema, snapshot.Type, snapshot.Options)
+			require.NoError(t, err)
+
+			golden := strings.TrimSuffix(path, ".json") + ".go.golden"
+			if *update {
+				require.NoError(t, os.WriteFile(golden, []byte(code), 0o644))
+				return
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err, "no golden file; run with -update to create it")
+			if diff := firstDifference(string(want), code); diff != "" {
+				t.Errorf("generated code differs from %s (run with -update if intended):\n%s", golden, diff)
+			}
+		})
+	}
+}
+
+// firstDifference describes the first line where got differs from want, with the lines
+// around it, or returns "" when they are equal
+func firstDifference(want, got string) string {
+	if want == got {
+		return ""
+	}
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	line := 0
+	for line < len(wantLines) && line < len(gotLines) && wantLines[line] == gotLines[line] {
+		line++
+	}
+	var diff strings.Builder
+	for i := max(0, line-3); i < line; i++ {
+		fmt.Fprintf(&diff, "  %4d   %s\n", i+1, wantLines[i])
+	}
+	for i := line; i < min(len(wantLines), line+3); i++ {
+		fmt.Fprintf(&diff, "- %4d   %s\n", i+1, wantLines[i])
+	}
+	for i := line; i < min(len(gotLines), line+3); i++ {
+		fmt.Fprintf(&diff, "+ %4d   %s\n", i+1, gotLines[i])
+	}
+	fmt.Fprintf(&diff, "(%d lines, was %d)", len(gotLines), len(wantLines))
+	return diff.String()
+}
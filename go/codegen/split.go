@@ -0,0 +1,124 @@
+// ABOUTME: EncodeXFit/EncodeXSplit for "splittable" arrays: the items spread over messages of a byte budget
+// ABOUTME: Fields holding the item count are set for each message; runtime.FitItems finds how many fit
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// splitCount is a field set to the item count of each message
+type splitCount struct {
+	target string // Go path below the struct: "Header.Ancount"
+	what   string // Its schema path, for errors
+	goType string
+}
+
+// Largest item count each integer type holds
+var countLimits = map[string]uint64{
+	"uint8": 1<<8 - 1, "uint16": 1<<16 - 1, "uint32": 1<<32 - 1,
+	"int8": 1<<7 - 1, "int16": 1<<15 - 1, "int32": 1<<31 - 1,
+}
+
+// splitCounts returns the fields that hold the item count of array and so change from
+// one message to the next: its length_field or length prefix, and count_of fields
+func splitCounts(schema *Schema, typeDef *TypeDef, array Field) ([]splitCount, error) {
+	if array.Type != "array" {
+		return nil, fmt.Errorf("splittable needs an array, not %s", array.Type)
+	}
+	if array.Conditional != "" || array.Optional || array.Lazy {
+		return nil, fmt.Errorf("splittable arrays can't be conditional, optional or lazy")
+	}
+
+	var counts []splitCount
+	switch array.Kind {
+	case "fixed", "computed_count":
+		return nil, fmt.Errorf("%s arrays hold a set number of items and can't be split", array.Kind)
+	case "length_prefixed", "length_prefixed_items":
+		lengthType := array.LengthType
+		if lengthType == "" {
+			lengthType = "uint8"
+		}
+		counts = append(counts, splitCount{what: "its " + lengthType + " length prefix", goType: lengthType})
+	case "field_referenced":
+		d, _, ok := resolveLengthTarget(schema, typeDef, strings.Split(array.LengthField, "."))
+		if !ok || !fieldPathPattern.MatchString(array.LengthField) {
+			return nil, fmt.Errorf("length_field %q must name an integer field, through nested structs, to set for each message", array.LengthField)
+		}
+		counts = append(counts, splitCount{target: d.target, what: d.what, goType: d.goType})
+	}
+
+	for _, field := range typeDef.Sequence {
+		if field.LengthOf == array.Name {
+			return nil, fmt.Errorf("its byte length is held in %s, which can't be set for each message", field.Name)
+		}
+		if field.CountOf != array.Name {
+			continue
+		}
+		goType, err := mapTypeToGo(field)
+		if err != nil || !lengthGoTypes[goType] {
+			return nil, fmt.Errorf("its count is held in %s, which isn't an integer", field.Name)
+		}
+		if len(counts) == 0 || counts[0].target != field.goName() {
+			counts = append(counts, splitCount{target: field.goName(), what: field.Name, goType: goType})
+		}
+	}
+	return counts, nil
+}
+
+// generateEncodeFit emits EncodeXFit and EncodeXSplit for each splittable array of a type
+func generateEncodeFit(buf *bytes.Buffer, schema *Schema, typeName string, typeDef *TypeDef) error {
+	recv := typeDef.receiver()
+	for _, array := range typeDef.Sequence {
+		if !array.Splittable {
+			continue
+		}
+		if typeDef.Protobuf {
+			return fmt.Errorf("%s.%s: splittable arrays aren't supported in protobuf messages", typeName, array.Name)
+		}
+		counts, err := splitCounts(schema, typeDef, array)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", typeName, array.Name, err)
+		}
+		sliceType, err := mapTypeToGo(array)
+		if err != nil {
+			return err
+		}
+		fieldName := array.goName()
+
+		buf.WriteString(fmt.Sprintf("// Encode%sFit encodes %s with as many of its %s as fit in maxBytes, from the first,\n", fieldName, recv, fieldName))
+		buf.WriteString("// and returns the items left for the next message\n")
+		buf.WriteString(fmt.Sprintf("func (%s *%s) Encode%sFit(maxBytes int) ([]byte, %s, error) {\n", recv, typeName, fieldName, sliceType))
+		buf.WriteString(fmt.Sprintf("\treturn runtime.FitItems(%s.%s, %s.encode%sWith(maxBytes))\n", recv, fieldName, recv, fieldName))
+		buf.WriteString("}\n\n")
+
+		buf.WriteString(fmt.Sprintf("// Encode%sSplit encodes %s into as many messages of at most maxBytes as it takes to\n", fieldName, recv))
+		buf.WriteString(fmt.Sprintf("// hold all its %s, the other fields the same in each\n", fieldName))
+		buf.WriteString(fmt.Sprintf("func (%s *%s) Encode%sSplit(maxBytes int) ([][]byte, error) {\n", recv, typeName, fieldName))
+		buf.WriteString(fmt.Sprintf("\treturn runtime.SplitItems(%s.%s, %s.encode%sWith(maxBytes))\n", recv, fieldName, recv, fieldName))
+		buf.WriteString("}\n\n")
+
+		buf.WriteString(fmt.Sprintf("// encode%sWith returns the encoding of %s holding other %s, within maxBytes\n", fieldName, recv, fieldName))
+		buf.WriteString(fmt.Sprintf("func (%s *%s) encode%sWith(maxBytes int) func(items %s) ([]byte, error) {\n", recv, typeName, fieldName, sliceType))
+		buf.WriteString(fmt.Sprintf("\treturn func(items %s) ([]byte, error) {\n", sliceType))
+		for _, count := range counts {
+			if limit, ok := countLimits[count.goType]; ok {
+				buf.WriteString(fmt.Sprintf("\t\tif uint64(len(items)) > %d {\n", limit))
+				buf.WriteString(fmt.Sprintf("\t\t\treturn nil, fmt.Errorf(\"%%w: %%d items don't fit in %s\", runtime.ErrOutputTooLarge, len(items))\n", count.what))
+				buf.WriteString("\t\t}\n")
+			}
+		}
+		buf.WriteString(fmt.Sprintf("\t\tmessage := *%s\n", recv))
+		buf.WriteString(fmt.Sprintf("\t\tmessage.%s = items\n", fieldName))
+		for _, count := range counts {
+			if count.target != "" {
+				buf.WriteString(fmt.Sprintf("\t\tmessage.%s = %s(len(items))\n", count.target, count.goType))
+			}
+		}
+		buf.WriteString("\t\treturn message.EncodeWithLimit(maxBytes)\n")
+		buf.WriteString("\t}\n")
+		buf.WriteString("}\n\n")
+	}
+	return nil
+}
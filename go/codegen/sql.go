@@ -0,0 +1,45 @@
+// ABOUTME: SQL option: database/sql Value/Scan and encoding.TextMarshaler on generated types
+// ABOUTME: Messages are stored as their encoded bytes in BLOB columns, or base64 as text
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// generateSQLMethods emits Value and Scan, storing a message as its encoded bytes, and
+// MarshalText and UnmarshalText, which carry the same bytes as base64
+func generateSQLMethods(buf *bytes.Buffer, name string, typeDef *TypeDef) {
+	recv := typeDef.receiver()
+	buf.WriteString(fmt.Sprintf("// Value stores %s as its encoded bytes, implementing driver.Valuer\n", recv))
+	buf.WriteString(fmt.Sprintf("func (%s *%s) Value() (driver.Value, error) {\n", recv, name))
+	buf.WriteString(fmt.Sprintf("\treturn %s.Encode()\n", recv))
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("// Scan decodes the bytes of a BLOB or text column into %s, implementing sql.Scanner\n", recv))
+	buf.WriteString(fmt.Sprintf("func (%s *%s) Scan(src interface{}) error {\n", recv, name))
+	buf.WriteString(fmt.Sprintf("\tdata, err := runtime.ScanBytes(src, %q)\n", name))
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\treturn err\n")
+	buf.WriteString("\t}\n")
+	writeDecodeInto(buf, name, typeDef, "data", recv)
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("// MarshalText encodes %s as base64 of its encoded bytes, implementing encoding.TextMarshaler\n", recv))
+	buf.WriteString(fmt.Sprintf("func (%s *%s) MarshalText() ([]byte, error) {\n", recv, name))
+	buf.WriteString(fmt.Sprintf("\tdata, err := %s.Encode()\n", recv))
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\treturn nil, err\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn runtime.AppendBase64(nil, data), nil\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("// UnmarshalText decodes the base64 MarshalText writes into %s, implementing encoding.TextUnmarshaler\n", recv))
+	buf.WriteString(fmt.Sprintf("func (%s *%s) UnmarshalText(text []byte) error {\n", recv, name))
+	buf.WriteString("\tdata, err := runtime.DecodeBase64(text)\n")
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\treturn err\n")
+	buf.WriteString("\t}\n")
+	writeDecodeInto(buf, name, typeDef, "data", recv)
+	buf.WriteString("}\n\n")
+}
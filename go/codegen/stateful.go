@@ -0,0 +1,19 @@
+// ABOUTME: DecodeXStream: a runtime.StatefulDecoder for messages arriving in chunks
+// ABOUTME: Wraps the decoder-taking helper so partial input is buffered until it is whole
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// generateDecodeStream emits the public DecodeXStream
+func generateDecodeStream(buf *bytes.Buffer, typeName string, typeDef *TypeDef) {
+	buf.WriteString(fmt.Sprintf("// Decode%sStream returns a decoder of successive %ss from input fed to it in chunks:\n", typeName, typeName))
+	buf.WriteString("// Next returns runtime.ErrNeedMoreData until a whole message has been fed\n")
+	buf.WriteString(fmt.Sprintf("func Decode%sStream() *runtime.StatefulDecoder[*%s] {\n", typeName, typeName))
+	buf.WriteString(fmt.Sprintf("\treturn runtime.NewStatefulDecoder(%s, func(decoder *runtime.BitStreamDecoder) (*%s, error) {\n", runtimeBitOrder(typeDef.BitOrder), typeName))
+	buf.WriteString(fmt.Sprintf("\t\treturn decode%sWithDecoder(decoder, nil)\n", typeName))
+	buf.WriteString("\t})\n")
+	buf.WriteString("}\n\n")
+}
@@ -0,0 +1,139 @@
+// ABOUTME: SVG packet layout diagrams: RFC 791-style figures of bit-numbered boxes, 32 bits a row
+// ABOUTME: Shares the field layout of the docgen backend; used for HTML docs and as standalone figures
+package codegen
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Geometry of SVG diagrams, in pixels
+const (
+	svgBitWidth  = 20 // Width of one bit's column
+	svgRowHeight = 36 // Height of a row of 32 bits
+	svgLeft      = 36 // Margin holding the byte offset of each row
+	svgTop       = 20 // Margin holding the bit numbers
+	svgCharWidth = 7  // Width of a character of a 12px monospace label, rounded down
+)
+
+// GenerateDiagram draws the packet layout of the struct typeName as an SVG figure, for
+// the website or for embedding in specs. Like the diagrams of GenerateDocs it draws
+// the fields up to the first one of variable size or presence, which is drawn to the
+// end of its row with a dashed edge.
+func GenerateDiagram(schemaData map[string]interface{}, typeName string) (string, error) {
+	if err := schemaError(ValidateSchema(schemaData)); err != nil {
+		return "", err
+	}
+	g, err := newDocGen(schemaData)
+	if err != nil {
+		return "", err
+	}
+	def, ok := g.types[typeName].(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("type %s not found in schema", typeName)
+	}
+	if _, isStruct := def["sequence"].([]interface{}); !isStruct {
+		return "", fmt.Errorf("type %s is not a struct", typeName)
+	}
+	s := g.section(typeName, def)
+	if len(s.spans) == 0 {
+		return "", fmt.Errorf("type %s has no fields at fixed offsets to draw", typeName)
+	}
+	return svgDiagram(s.spans), nil
+}
+
+// svgDiagram draws spans as bit-numbered boxes. Lines between rows are left out where a
+// field carries on into the next row.
+func svgDiagram(spans []diagramSpan) string {
+	total := diagramBits(spans)
+	width := total
+	if width > diagramWidth {
+		width = diagramWidth
+	}
+	rows := (total + diagramWidth - 1) / diagramWidth
+	w, h := svgLeft+width*svgBitWidth+1, svgTop+rows*svgRowHeight+1
+	x := func(bit int) int { return svgLeft + bit*svgBitWidth }
+	y := func(row int) int { return svgTop + row*svgRowHeight }
+
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" font-family=\"monospace\" font-size=\"12\">\n", w, h, w, h))
+	out.WriteString("<g font-size=\"10\" text-anchor=\"middle\" fill=\"#666\">\n")
+	for b := 0; b < width; b++ {
+		out.WriteString(fmt.Sprintf("<text x=\"%d\" y=\"%d\">%d</text>\n", x(b)+svgBitWidth/2, svgTop-6, b))
+	}
+	out.WriteString("</g>\n<g font-size=\"10\" text-anchor=\"end\" fill=\"#666\">\n")
+	for row := 0; row < rows; row++ {
+		out.WriteString(fmt.Sprintf("<text x=\"%d\" y=\"%d\">%d</text>\n", svgLeft-6, y(row)+svgRowHeight/2+4, row*diagramWidth/8))
+	}
+	out.WriteString("</g>\n")
+
+	// Edges: a line above and below each bit whose neighbour there is another field,
+	// and between bits of different fields
+	var solid, dashed strings.Builder
+	for row := 0; row < rows; row++ {
+		first := row * diagramWidth
+		rowWidth := total - first
+		if rowWidth > diagramWidth {
+			rowWidth = diagramWidth
+		}
+		above := func(b int) bool {
+			return row == 0 || fieldAt(spans, first+b-diagramWidth) != fieldAt(spans, first+b)
+		}
+		below := func(b int) bool {
+			return first+b+diagramWidth >= total || fieldAt(spans, first+b+diagramWidth) != fieldAt(spans, first+b)
+		}
+		for _, edge := range []struct {
+			y    int
+			draw func(int) bool
+		}{{y(row), above}, {y(row + 1), below}} {
+			for b := 0; b < rowWidth; b++ {
+				if !edge.draw(b) {
+					continue
+				}
+				end := b
+				for end+1 < rowWidth && edge.draw(end+1) {
+					end++
+				}
+				solid.WriteString(fmt.Sprintf("M%d %dH%d", x(b), edge.y, x(end+1)))
+				b = end
+			}
+		}
+		for b := 0; b < rowWidth; b++ {
+			if b == 0 || fieldAt(spans, first+b-1) != fieldAt(spans, first+b) {
+				solid.WriteString(fmt.Sprintf("M%d %dV%d", x(b), y(row), y(row+1)))
+			}
+		}
+		edge := &solid
+		if i := spanAt(spans, first+rowWidth-1); i >= 0 && spans[i].variable {
+			edge = &dashed
+		}
+		edge.WriteString(fmt.Sprintf("M%d %dV%d", x(rowWidth), y(row), y(row+1)))
+	}
+	out.WriteString(fmt.Sprintf("<path d=\"%s\" fill=\"none\" stroke=\"black\"/>\n", solid.String()))
+	if dashed.Len() > 0 {
+		out.WriteString(fmt.Sprintf("<path d=\"%s\" fill=\"none\" stroke=\"black\" stroke-dasharray=\"4 3\"/>\n", dashed.String()))
+	}
+
+	// Labels go in the first row of their field, cut to fit with the full name as a tooltip
+	out.WriteString("<g text-anchor=\"middle\">\n")
+	for _, span := range spans {
+		row := span.start / diagramWidth
+		start, end := span.start%diagramWidth, span.end-row*diagramWidth
+		if end > diagramWidth {
+			end = diagramWidth
+		}
+		label := []rune(span.label)
+		if fit := ((end-start)*svgBitWidth - 4) / svgCharWidth; len(label) > fit {
+			if fit < 2 {
+				label = label[:1]
+			} else {
+				label = append(label[:fit-1], '…')
+			}
+		}
+		out.WriteString(fmt.Sprintf("<text x=\"%d\" y=\"%d\"><title>%s</title>%s</text>\n",
+			(x(start)+x(end))/2, y(row)+svgRowHeight/2+4, html.EscapeString(span.label), html.EscapeString(string(label))))
+	}
+	out.WriteString("</g>\n</svg>\n")
+	return out.String()
+}
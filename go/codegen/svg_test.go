@@ -0,0 +1,57 @@
+// ABOUTME: Tests for SVG packet layout diagrams
+// ABOUTME: Checks box edges, labels, bit and byte numbering, lsb_first packing and the types that can't be drawn
+package codegen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateDiagram(t *testing.T) {
+	svg, err := GenerateDiagram(docsTestSchema(t), "Packet")
+	require.NoError(t, err)
+
+	require.Contains(t, svg, `<svg xmlns="http://www.w3.org/2000/svg" width="677" height="93" viewBox="0 0 677 93"`)
+	// Bit numbers above the columns and byte offsets beside the rows
+	require.Contains(t, svg, `<text x="46" y="14">0</text>`)
+	require.Contains(t, svg, `<text x="666" y="14">31</text>`)
+	require.Contains(t, svg, `<text x="30" y="78">4</text>`)
+	// Subfields are boxes of their own, and labels too long for a box are cut
+	require.Contains(t, svg, `<text x="66" y="42"><title>version</title>version</text>`)
+	require.Contains(t, svg, `<text x="106" y="42"><title>urgent</title>u…</text>`)
+	require.Contains(t, svg, `<text x="516" y="42"><title>seq</title>seq</text>`)
+	// The field of variable presence ends the figure with a dashed edge
+	require.Contains(t, svg, `<text x="356" y="78"><title>extra</title>extra</text>`)
+	require.Contains(t, svg, `<path d="M676 56V92" fill="none" stroke="black" stroke-dasharray="4 3"/>`)
+	require.Contains(t, svg, `M36 20H676M36 56H676M36 20V56M96 20V56M116 20V56M196 20V56M356 20V56M676 20V56`)
+
+	_, err = GenerateDiagram(docsTestSchema(t), "Kind")
+	require.ErrorContains(t, err, "type Kind is not a struct")
+	_, err = GenerateDiagram(docsTestSchema(t), "Missing")
+	require.ErrorContains(t, err, "type Missing not found")
+}
+
+func TestGenerateDiagramLSBFirst(t *testing.T) {
+	svg, err := GenerateDiagram(lsbFirstTestSchema(t), "Frame")
+	require.NoError(t, err)
+
+	// Bits fill each byte from the right, and both boxes of length are labelled
+	require.Contains(t, svg, `<text x="166" y="42"><title>version</title>version</text>`)
+	require.Contains(t, svg, `<text x="76" y="42"><title>reserved</title>reserved</text>`)
+	require.Contains(t, svg, `<text x="236" y="42"><title>length</title>length</text>`)
+	require.Contains(t, svg, `<text x="436" y="42"><title>length</title>length</text>`)
+	require.Contains(t, svg, `M36 20V56M116 20V56M136 20V56M196 20V56M276 20V56M356 20V56M516 20V56M676 20V56`)
+}
+
+func TestSVGDiagramRows(t *testing.T) {
+	// A field running into the next row has no line between its rows
+	svg := svgDiagram([]diagramSpan{
+		{label: "type", start: 0, end: 16},
+		{label: "address", start: 16, end: 80},
+	})
+	require.Contains(t, svg, `height="129"`)
+	require.Contains(t, svg, `<path d="M36 20H676M36 56H356M36 20V56M356 20V56M676 20V56`+
+		`M36 56H356M356 92H676M36 56V92M676 56V92M36 128H356M36 92V128M356 92V128" fill="none" stroke="black"/>`)
+	require.NotContains(t, svg, "stroke-dasharray")
+}
@@ -0,0 +1,460 @@
+// ABOUTME: GenerateGoTests: a _test.go file of round-trip tests for the code GenerateGo generates
+// ABOUTME: Each struct type is encoded as its zero value and as an example, decoded and encoded again
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxExampleDepth bounds nested examples, so recursive types end
+const maxExampleDepth = 3
+
+// GenerateGoTests generates a test file for the code GenerateGoWithOptions generates with
+// the same arguments, to be saved next to it as a _test.go file. Each struct type gets a
+// round-trip test encoding its zero value and an example, decoding the bytes and checking
+// they encode the same again. The example sets the fields it can to a value of their
+// type, the lengths and counts other fields are read with to match, and one variant of
+// each union, picked by a "value == N" discriminator on its first field. Types whose
+// zero value doesn't round-trip skip that case; types that only decode inside their parents
+// (../ references), need a registered codec, or whose instances or back references
+// depend on the rest of a message get no test.
+func GenerateGoTests(schemaData map[string]interface{}, typeName string, opts GenerateOptions) (string, error) {
+	if opts.Direction != EncodeAndDecode {
+		return "", fmt.Errorf("test stubs need both encoders and decoders")
+	}
+	schema, _, _, err := prepareSchema(schemaData, typeName, opts)
+	if err != nil {
+		return "", err
+	}
+
+	s := &testStubs{schema: schema, reasons: make(map[string]string)}
+	var buf bytes.Buffer
+	buf.WriteString("// roundTrip encodes value, decodes the bytes with decode and checks they encode the same\n")
+	buf.WriteString("// again. A zero value that doesn't encode and decode is skipped: it may lack what every\n")
+	buf.WriteString("// message needs, such as items a count says are there.\n")
+	buf.WriteString("func roundTrip[T interface{ Encode() ([]byte, error) }](t *testing.T, value T, decode func([]byte) (T, error), zero bool) {\n")
+	buf.WriteString("\tt.Helper()\n")
+	buf.WriteString("\tencoded, err := value.Encode()\n")
+	buf.WriteString("\tif err != nil && zero {\n")
+	buf.WriteString("\t\tt.Skipf(\"the zero value doesn't encode: %v\", err)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\tt.Fatalf(\"encode: %v\", err)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tdecoded, err := decode(encoded)\n")
+	buf.WriteString("\tif err != nil && zero {\n")
+	buf.WriteString("\t\tt.Skipf(\"the zero value's encoding doesn't decode: %v\", err)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\tt.Fatalf(\"decode % x: %v\", encoded, err)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treencoded, err := decoded.Encode()\n")
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\tt.Fatalf(\"encode decoded value: %v\", err)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tif !bytes.Equal(reencoded, encoded) {\n")
+	buf.WriteString("\t\tt.Fatalf(\"encoded % x, decoded and encoded again % x\", encoded, reencoded)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("}\n\n")
+
+	reach := parentReach(schema)
+	for _, name := range typeOrder(schema) {
+		typeDef := schema.Types[name]
+		if typeDef.Discriminator != nil || typeDef.Flags != nil {
+			continue
+		}
+		if s.reason(name) == "" {
+			if err := s.generateExample(&buf, name, typeDef); err != nil {
+				return "", err
+			}
+		}
+		if typeDef.Bitfield {
+			continue
+		}
+		if noTest := s.noTest(name, reach); noTest != "" {
+			buf.WriteString(fmt.Sprintf("// %s has no test: %s\n\n", name, noTest))
+			continue
+		}
+		if noZero(typeDef) != "" && s.reason(name) != "" {
+			buf.WriteString(fmt.Sprintf("// %s has no test: %s, and it has no example (%s)\n\n", name, noZero(typeDef), s.reason(name)))
+			continue
+		}
+		generateRoundTripTest(&buf, name, noZero(typeDef), s.reason(name))
+	}
+
+	var out bytes.Buffer
+	out.WriteString(fmt.Sprintf("// Code generated by binschema %s. DO NOT EDIT.\n\n", GeneratorVersion))
+	out.WriteString("package main\n\n")
+	out.WriteString("import (\n")
+	used := usedPackages(buf.Bytes())
+	for _, pkg := range []string{"bytes", "net/netip", "testing", "time"} {
+		if used[pkg[strings.LastIndex(pkg, "/")+1:]] {
+			out.WriteString(fmt.Sprintf("\t%q\n", pkg))
+		}
+	}
+	if used["runtime"] {
+		out.WriteString("\n\t\"github.com/serialexp/binschema/runtime\"\n")
+	}
+	out.WriteString(")\n\n")
+	out.Write(buf.Bytes())
+
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("generated tests do not parse: %w", err)
+	}
+	return string(formatted), nil
+}
+
+// noZero returns why a type's zero value isn't a message, or "" if it may be one: a
+// fixed-length array left empty encodes fewer items than are decoded
+func noZero(typeDef *TypeDef) string {
+	for _, field := range typeDef.Sequence {
+		if n, ok := field.Length.(float64); ok && field.Type == "array" && field.Kind == "fixed" && n > 0 && field.Conditional == "" {
+			return fmt.Sprintf("%s has %d items", field.Name, int(n))
+		}
+	}
+	return ""
+}
+
+// generateRoundTripTest emits TestXRoundTrip, over the zero value and the example of a
+// type, with a comment saying why in place of either it leaves out
+func generateRoundTripTest(buf *bytes.Buffer, name, noZero, noExample string) {
+	buf.WriteString(fmt.Sprintf("func Test%sRoundTrip(t *testing.T) {\n", name))
+	buf.WriteString(fmt.Sprintf("\tfor _, tc := range []struct {\n\t\tname  string\n\t\tvalue *%s\n\t}{\n", name))
+	if noZero == "" {
+		buf.WriteString(fmt.Sprintf("\t\t{\"zero\", &%s{}},\n", name))
+	} else {
+		buf.WriteString(fmt.Sprintf("\t\t// No zero value: %s\n", noZero))
+	}
+	if noExample == "" {
+		buf.WriteString(fmt.Sprintf("\t\t{\"example\", example%s(0)},\n", name))
+	} else {
+		buf.WriteString(fmt.Sprintf("\t\t// No example: %s\n", noExample))
+	}
+	buf.WriteString("\t} {\n")
+	buf.WriteString("\t\tt.Run(tc.name, func(t *testing.T) {\n")
+	buf.WriteString(fmt.Sprintf("\t\t\troundTrip(t, tc.value, Decode%s, tc.name == \"zero\")\n", name))
+	buf.WriteString("\t\t})\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("}\n\n")
+}
+
+// noTest returns why a struct type gets no test, or "" if it gets one: the test of a
+// type encodes and decodes it on its own, as a whole message
+func (s *testStubs) noTest(name string, reach map[string]int) string {
+	typeDef := s.schema.Types[name]
+	for _, instance := range typeDef.Instances {
+		if _, err := instancePlacement(typeDef, instance); err != nil {
+			return "its instances can only be decoded"
+		}
+	}
+	switch {
+	case reach[name] > 0:
+		return "it reads fields of the types it is nested in"
+	case s.contains(name, func(f *Field) bool { return f.Codec != "" }, make(map[string]bool)):
+		return "it has fields read by a registered codec"
+	case s.contains(name, func(f *Field) bool { return f.BackReference != nil }, make(map[string]bool)):
+		return "it has back references, which point into the message they are decoded from"
+	case s.contains(name, func(f *Field) bool {
+		nested, ok := s.schema.Types[f.Type]
+		return ok && f.Type != name && len(nested.Instances) > 0
+	}, make(map[string]bool)):
+		return "it nests types with instances, which are placed relative to the message they are encoded in"
+	}
+	return ""
+}
+
+// contains reports whether a type, or a type in it, has a field matching match
+func (s *testStubs) contains(name string, match func(*Field) bool, seen map[string]bool) bool {
+	typeDef, ok := s.schema.Types[name]
+	if !ok || seen[name] {
+		return false
+	}
+	seen[name] = true
+	for _, variant := range typeDef.Variants {
+		if s.contains(variant.Type, match, seen) {
+			return true
+		}
+	}
+	for _, field := range typeDef.allFields() {
+		for f := &field; f != nil; f = f.Items {
+			if match(f) || s.contains(f.Type, match, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// testStubs generates the examples of a schema's struct types
+type testStubs struct {
+	schema  *Schema
+	reasons map[string]string // Why a type has no example, "" if it has one
+}
+
+// reason returns why a struct type can't have an example, or "" if it can. A type
+// containing one that can't has none either.
+func (s *testStubs) reason(name string) string {
+	if reason, ok := s.reasons[name]; ok {
+		return reason
+	}
+	s.reasons[name] = "" // Recursive types end at maxExampleDepth
+	typeDef := s.schema.Types[name]
+	var reason string
+	if typeDef.Protobuf {
+		reason = "protobuf messages aren't given examples"
+	}
+	// Arrays of items without examples are left empty
+	for _, field := range typeDef.allFields() {
+		if reason != "" {
+			break
+		}
+		if src, ok := lengthSource(field); ok && !fieldPath.MatchString(src) {
+			reason = fmt.Sprintf("the length of %s is %q, which needn't be zero when the fields it reads are", field.Name, src)
+		} else if field.Union {
+			if _, _, ok := s.unionVariant(field.Type); !ok {
+				reason = fmt.Sprintf("no variant of %s is picked by \"value == N\" on its first field", field.Type)
+			}
+		} else if nested, ok := s.schema.Types[field.Type]; ok && nested.Discriminator == nil && nested.Flags == nil {
+			if inner := s.reason(field.Type); inner != "" {
+				reason = fmt.Sprintf("%s has none (%s)", field.Type, inner)
+			}
+		}
+	}
+	s.reasons[name] = reason
+	return reason
+}
+
+// unionVariant picks the variant of a union an example holds: the first selected by a
+// "value == N" discriminator when its first field is an unsigned integer as wide as the
+// peeked value, so the example's first field can be set to N. Variants that can't
+// contain the union come first, so examples of recursive unions end.
+func (s *testStubs) unionVariant(name string) (Variant, uint64, bool) {
+	union := s.schema.Types[name]
+	if union == nil || union.Discriminator == nil || union.Discriminator.Peek == "" {
+		return Variant{}, 0, false
+	}
+	variants := append([]Variant{}, union.Variants...)
+	sort.SliceStable(variants, func(i, j int) bool {
+		return !s.schema.Types[variants[i].Type].Recursive && s.schema.Types[variants[j].Type].Recursive
+	})
+	for _, variant := range variants {
+		n, ok := whenEquals(variant.When)
+		variantDef := s.schema.Types[variant.Type]
+		if !ok || variantDef == nil || len(variantDef.Sequence) == 0 || variantDef.Discriminator != nil {
+			continue
+		}
+		first := variantDef.Sequence[0]
+		if first.Type != union.Discriminator.Peek || first.Conditional != "" || first.PositionOf != "" || first.ByteRange != nil {
+			continue
+		}
+		if s.reason(variant.Type) == "" {
+			return variant, n, true
+		}
+	}
+	return Variant{}, 0, false
+}
+
+var whenEqualsPattern = regexp.MustCompile(`^\s*value\s*==\s*(0[xX][0-9a-fA-F]+|\d+)\s*$`)
+
+// whenEquals returns N of a "value == N" variant condition
+func whenEquals(when string) (uint64, bool) {
+	match := whenEqualsPattern.FindStringSubmatch(when)
+	if match == nil {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(match[1], 0, 64)
+	return n, err == nil
+}
+
+// expressionNames matches the names and field paths ("header.count") expressions read
+var expressionNames = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*`)
+
+// fieldPath matches an expression that is a field path alone
+var fieldPath = regexp.MustCompile(`^\s*[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*\s*$`)
+
+// generateExample emits exampleX, returning a *X with the fields it can set. Integer
+// fields other fields' lengths, counts, conditions or positions read are left zero,
+// unless the example derives them from the lengths it sets, and so are the fields
+// paths into them start at.
+func (s *testStubs) generateExample(buf *bytes.Buffer, name string, typeDef *TypeDef) error {
+	read := make(map[string]bool)
+	for _, field := range typeDef.allFields() {
+		for f := &field; f != nil; f = f.Items {
+			src, _ := lengthSource(*f)
+			position, _ := field.Position.(string)
+			size, _ := field.InstanceSize.(string)
+			for _, expr := range []string{src, f.Conditional, position, size} {
+				for _, n := range expressionNames.FindAllString(expr, -1) {
+					read[n] = true
+				}
+			}
+		}
+	}
+	derivations, _, _, _ := builderDerivations(s.schema, typeDef)
+	derived := make(map[string]bool)
+	for _, d := range derivations {
+		derived[d.source.Name] = true
+	}
+
+	buf.WriteString(fmt.Sprintf("// example%s returns a %s with the fields an example can have set; depth counts\n", name, name))
+	buf.WriteString("// the examples it is nested in, so those of recursive types end\n")
+	buf.WriteString(fmt.Sprintf("func example%s(depth int) *%s {\n", name, name))
+	buf.WriteString(fmt.Sprintf("\tm := &%s{}\n", name))
+	for _, field := range typeDef.allFields() {
+		if read[field.Name] && s.isInteger(field) || s.pathStart(read, field.Name) {
+			continue
+		}
+		value, err := s.fieldExample(field, derived[field.Name])
+		if err != nil {
+			return err
+		}
+		if value != "" {
+			buf.WriteString(fmt.Sprintf("\tm.%s = %s\n", field.goName(), value))
+		}
+	}
+	for _, d := range derivations {
+		n := fmt.Sprintf("len(m.%s)", d.source.goName())
+		if d.offset != 0 {
+			n = fmt.Sprintf("%s + %d", n, d.offset)
+		}
+		buf.WriteString(fmt.Sprintf("\tm.%s = %s(%s)\n", d.target, d.goType, n))
+	}
+	buf.WriteString("\treturn m\n")
+	buf.WriteString("}\n\n")
+	return nil
+}
+
+// pathStart reports whether a field path read starts at the named field
+func (s *testStubs) pathStart(read map[string]bool, name string) bool {
+	for n := range read {
+		if strings.HasPrefix(n, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// isInteger reports whether a field holds an integer an expression can read
+func (s *testStubs) isInteger(field Field) bool {
+	goType, err := mapTypeToGo(field)
+	return err == nil && lengthGoTypes[goType] && field.FlagsRepr == ""
+}
+
+// fieldExample returns the Go expression of a sequence field's example value, or "" to
+// leave it zero. sized says the example derives the field's length.
+func (s *testStubs) fieldExample(field Field, sized bool) (string, error) {
+	if field.Optional || field.Lazy || field.Transform != nil || field.Codec != "" || field.Const != nil ||
+		field.PositionOf != "" || field.ByteRange != nil || field.LengthOf != "" || field.CountOf != "" {
+		return "", nil
+	}
+	if _, ok := lengthSource(field); ok && !sized && field.Kind != "fixed" {
+		return "", nil
+	}
+	if len(field.SelectsEndianness) > 0 {
+		values := make([]uint64, 0, len(field.SelectsEndianness))
+		for _, value := range field.SelectsEndianness {
+			values = append(values, value)
+		}
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		return strconv.FormatUint(values[0], 10), nil
+	}
+
+	switch field.Type {
+	case "string":
+		if _, ok := field.Length.(string); ok && !sized {
+			return "", nil
+		}
+		if field.ZeroCopy {
+			return `runtime.ByteString("example")`, nil
+		}
+		return `"example"`, nil
+	case "array":
+		count := 1
+		switch field.Kind {
+		case "fixed":
+			n, ok := field.Length.(float64)
+			if !ok {
+				return "", nil
+			}
+			count = int(n)
+		case "length_prefixed", "length_prefixed_items", "byte_length_prefixed", "null_terminated":
+		case "field_referenced", "computed_count":
+			if !sized {
+				return "", nil
+			}
+		default:
+			return "", nil
+		}
+		if field.Kind == "null_terminated" && field.Items.Union {
+			return "", nil
+		}
+		item, err := s.valueExample(*field.Items)
+		if err != nil || item == "" {
+			return "", err
+		}
+		goType, err := mapTypeToGo(field)
+		if err != nil {
+			return "", err
+		}
+		if field.Kind == "fixed" {
+			return fmt.Sprintf("func() %s { items := make(%s, %d); for i := range items { items[i] = %s }; return items }()", goType, goType, count, item), nil
+		}
+		return fmt.Sprintf("%s{%s}", goType, item), nil
+	}
+	return s.valueExample(field)
+}
+
+// valueExample returns the example of a value of a field's type, a field or an array
+// item, or "" to leave it zero
+func (s *testStubs) valueExample(field Field) (string, error) {
+	if field.Union {
+		variant, n, ok := s.unionVariant(field.Type)
+		if !ok {
+			return "", nil
+		}
+		first := s.schema.Types[variant.Type].Sequence[0].goName()
+		return fmt.Sprintf("func() %s { v := example%s(depth + 1); v.%s = %d; return v }()", capitalizeFirst(field.Type), variant.Type, first, n), nil
+	}
+	if nested, ok := s.schema.Types[field.Type]; ok {
+		if nested.Flags != nil || nested.Discriminator != nil || s.reason(field.Type) != "" {
+			return "", nil
+		}
+		if field.Pointer {
+			return fmt.Sprintf("func() *%s { if depth < %d { return example%s(depth + 1) }; return &%s{} }()", field.Type, maxExampleDepth, field.Type, field.Type), nil
+		}
+		return fmt.Sprintf("func() %s { if depth < %d { return *example%s(depth + 1) }; return %s{} }()", field.Type, maxExampleDepth, field.Type, field.Type), nil
+	}
+
+	goType, err := mapTypeToGo(field)
+	if err != nil {
+		return "", err
+	}
+	switch goType {
+	case "uint8", "uint16", "uint32", "uint64", "int16", "int32", "int64":
+		return "1", nil
+	case "int8":
+		if field.Type == "int" && field.Size < 2 {
+			return "", nil // A 1-bit signed field holds only 0 and -1
+		}
+		return "1", nil
+	case "float32", "float64":
+		return "1.5", nil
+	case "string":
+		return `"12"`, nil // Decimal digits, for bcd decoded as strings
+	case "netip.Addr":
+		if field.Type == "ipv4" {
+			return "netip.AddrFrom4([4]byte{192, 0, 2, 1})", nil
+		}
+		return `netip.MustParseAddr("2001:db8::1")`, nil
+	case "time.Time":
+		return "time.Unix(1700000000, 0).UTC()", nil
+	}
+	return "", nil
+}
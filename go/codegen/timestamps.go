@@ -0,0 +1,103 @@
+// ABOUTME: Timestamp fields (unix32, unix64, unix_milli, ntp) decoded into time.Time
+// ABOUTME: Unix timestamps count units since an epoch; NTP ones are seconds since 1900 with a 32-bit fraction
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timestampWire is how a timestamp type is stored
+type timestampWire struct {
+	goType string // Integer read and written
+	method string // Uint32, Int64, ... after Read/Write
+	unit   string // Default unit
+}
+
+var timestampWires = map[string]timestampWire{
+	"unix32":     {"uint32", "Uint32", "s"},
+	"unix64":     {"int64", "Int64", "s"},
+	"unix_milli": {"int64", "Int64", "ms"},
+	"ntp":        {"uint64", "Uint64", ""},
+}
+
+// Go expression for each unit a unix timestamp can count
+var timestampUnits = map[string]string{"s": "time.Second", "ms": "time.Millisecond", "us": "time.Microsecond", "ns": "time.Nanosecond"}
+
+// timestampScale returns the Go unit and epoch a unix timestamp field counts with
+func timestampScale(field Field) (unit, epoch string, err error) {
+	name := field.Unit
+	if name == "" {
+		name = timestampWires[field.Type].unit
+	}
+	unit, ok := timestampUnits[name]
+	if !ok {
+		return "", "", fmt.Errorf("%s: unit must be s, ms, us or ns, got %q", field.Name, name)
+	}
+	if field.Epoch == "" {
+		return unit, "runtime.UnixEpoch", nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, field.Epoch)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: epoch %q is not an RFC 3339 time", field.Name, field.Epoch)
+	}
+	t = t.UTC()
+	return unit, fmt.Sprintf("time.Date(%d, time.%s, %d, %d, %d, %d, %d, time.UTC)",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond()), nil
+}
+
+// generateEncodeTimestamp emits the conversion of a time.Time to its wire integer and
+// the write, failing for times the integer can't hold
+func generateEncodeTimestamp(buf *bytes.Buffer, field Field, fieldName, runtimeEndianness, indent string) error {
+	wire := timestampWires[field.Type]
+	what := field.Name
+	if what == "" {
+		what = "array item"
+	}
+	rawVar := strings.ReplaceAll(strings.ReplaceAll(fieldName, ".", "_"), "m_", "") + "_timestamp"
+
+	if field.Type == "ntp" {
+		buf.WriteString(fmt.Sprintf("%s%s, ok := runtime.NTPTimestamp(%s)\n", indent, rawVar, fieldName))
+		buf.WriteString(fmt.Sprintf("%sif !ok {\n", indent))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%s is outside NTP era 0 (1900 to 2036)\", %s)\n", indent, what, fieldName))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	} else {
+		unit, epoch, err := timestampScale(field)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("%s%s := runtime.UnixUnits(%s, %s, %s)\n", indent, rawVar, fieldName, unit, epoch))
+		if wire.goType == "uint32" {
+			buf.WriteString(fmt.Sprintf("%sif %s < 0 || %s > 0xffffffff {\n", indent, rawVar, rawVar))
+			buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%s is outside the range of %s\", %s)\n", indent, what, field.Type, fieldName))
+			buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		}
+	}
+	buf.WriteString(fmt.Sprintf("%sencoder.Write%s(%s(%s), runtime.%s)\n", indent, wire.method, wire.goType, rawVar, runtimeEndianness))
+	return nil
+}
+
+// generateDecodeTimestamp emits the read of a timestamp's wire integer and its
+// conversion to time.Time
+func generateDecodeTimestamp(buf *bytes.Buffer, field Field, fieldName, varName, runtimeEndianness, indent string) error {
+	wire := timestampWires[field.Type]
+	buf.WriteString(fmt.Sprintf("%s%s_timestamp, err := decoder.Read%s(runtime.%s)\n", indent, varName, wire.method, runtimeEndianness))
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	if field.Type == "ntp" {
+		buf.WriteString(fmt.Sprintf("%s%s := runtime.NTPTime(%s_timestamp)\n", indent, varName, varName))
+	} else {
+		unit, epoch, err := timestampScale(field)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("%s%s := runtime.UnixTime(int64(%s_timestamp), %s, %s)\n", indent, varName, varName, unit, epoch))
+	}
+	if fieldName != "" {
+		buf.WriteString(fmt.Sprintf("%sresult.%s = %s\n\n", indent, fieldName, varName))
+	}
+	return nil
+}
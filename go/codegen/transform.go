@@ -0,0 +1,190 @@
+// ABOUTME: Field transforms: a field's bytes gzip/zlib/zstd-compressed, XORed or AES-GCM-encrypted on the wire
+// ABOUTME: Encoders transform the bytes after encoding them and decoders reverse it first, through runtime.ApplyTransform
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// FieldTransform is the transform a field's bytes go through on the wire
+type FieldTransform struct {
+	Type  string `json:"type"`             // "gzip", "zlib", "zstd", "xor" or "aes-gcm"
+	Key   []byte `json:"key,omitempty"`    // For xor: the key bytes, repeated over the data
+	KeyID string `json:"key_id,omitempty"` // For aes-gcm: the name of the key its hook is asked for
+}
+
+// Transforms the runtime has or expects, with the attributes each needs
+var knownTransforms = map[string][]string{
+	"gzip":    nil,
+	"zlib":    nil,
+	"zstd":    nil,
+	"xor":     {"key"},
+	"aes-gcm": {"key_id"},
+}
+
+// parseTransform reads a transform attribute: a name, or an object with a type and its
+// parameters. checkTransform has reported anything malformed.
+func parseTransform(raw interface{}) *FieldTransform {
+	switch t := raw.(type) {
+	case string:
+		return &FieldTransform{Type: t}
+	case map[string]interface{}:
+		transform := &FieldTransform{}
+		transform.Type, _ = t["type"].(string)
+		transform.KeyID, _ = t["key_id"].(string)
+		if key, ok := t["key"].([]interface{}); ok {
+			for _, b := range key {
+				n, _ := b.(float64)
+				transform.Key = append(transform.Key, byte(n))
+			}
+		}
+		return transform
+	}
+	return nil
+}
+
+// checkTransform checks a transform attribute and that the field it is on holds bytes
+// whose wire length a length prefix gives: a length_prefixed string or byte array.
+// Other kinds find their end by their length or content, which a transform changes.
+func (v *validator) checkTransform(path string, field map[string]interface{}, raw interface{}) {
+	var name string
+	attrs := map[string]interface{}{}
+	switch t := raw.(type) {
+	case string:
+		name = t
+	case map[string]interface{}:
+		name, _ = t["type"].(string)
+		attrs = t
+	default:
+		v.errorf(path, "transform must be a name or an object with a type, got %v", raw)
+		return
+	}
+	needs, known := knownTransforms[name]
+	if !known {
+		v.errorf(path, "unknown transform %q (want %s)", name, strings.Join(sortedKeys(knownTransforms), ", "))
+		return
+	}
+	for _, attr := range needs {
+		if _, ok := attrs[attr]; !ok {
+			v.errorf(path, "transform %s needs a %s", name, attr)
+		}
+	}
+	if key, ok := attrs["key"]; ok {
+		bytes, ok := key.([]interface{})
+		valid := ok && len(bytes) > 0
+		for _, b := range bytes {
+			n, isNumber := b.(float64)
+			valid = valid && isNumber && n >= 0 && n <= 255 && n == float64(int(n))
+		}
+		if !valid {
+			v.errorf(path, "transform key must be a non-empty array of bytes, got %v", key)
+		}
+	}
+	if keyID, ok := attrs["key_id"]; ok {
+		if s, isString := keyID.(string); !isString || s == "" {
+			v.errorf(path, "transform key_id must be a non-empty string, got %v", keyID)
+		}
+	}
+
+	fieldType, _ := field["type"].(string)
+	items, _ := field["items"].(map[string]interface{})
+	if fieldType != "string" && (fieldType != "array" || items["type"] != "uint8") {
+		v.errorf(path, "only strings and byte arrays can be transformed, not %s", fieldType)
+	} else if field["kind"] != "length_prefixed" {
+		v.errorf(path, "transformed fields must be length_prefixed, so the prefix can hold their length on the wire, not %v", field["kind"])
+	}
+	if lazy, _ := field["lazy"].(bool); lazy {
+		v.errorf(path, "transformed fields can't be lazy")
+	}
+}
+
+// transformParams returns the runtime.TransformParams literal for a field's transform
+func transformParams(field Field) string {
+	params := fmt.Sprintf("Field: %q", field.Name)
+	if len(field.Transform.Key) > 0 {
+		key := make([]string, len(field.Transform.Key))
+		for i, b := range field.Transform.Key {
+			key[i] = fmt.Sprintf("0x%02X", b)
+		}
+		params += fmt.Sprintf(", Key: []byte{%s}", strings.Join(key, ", "))
+	}
+	if field.Transform.KeyID != "" {
+		params += fmt.Sprintf(", KeyID: %q", field.Transform.KeyID)
+	}
+	return "runtime.TransformParams{" + params + "}"
+}
+
+// transformLengthType returns the type of a transformed field's length prefix
+func transformLengthType(field Field) string {
+	if field.LengthType == "" {
+		return "uint8"
+	}
+	return field.LengthType
+}
+
+// generateEncodeTransform applies a field's transform to its bytes and writes the
+// result with its length prefix, failing if the prefix can't hold its length
+func generateEncodeTransform(buf *bytes.Buffer, field Field, fieldName, runtimeEndianness, indent string) error {
+	lengthType := transformLengthType(field)
+	write, ok := lengthPrefixWrites[lengthType]
+	if !ok {
+		return fmt.Errorf("%s: unsupported length_type %q", field.Name, lengthType)
+	}
+	transformedVar := strings.ReplaceAll(strings.ReplaceAll(fieldName, ".", "_"), "m_", "") + "_transformed"
+	data := fieldName
+	if field.Type == "string" {
+		data = "[]byte(" + fieldName + ")"
+	}
+	buf.WriteString(fmt.Sprintf("%s%s, err := runtime.ApplyTransform(%q, %s, %s)\n", indent, transformedVar, field.Transform.Type, transformParams(field), data))
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%w\", err)\n", indent, field.Name))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	if limit, ok := countLimits[lengthType]; ok {
+		buf.WriteString(fmt.Sprintf("%sif uint64(len(%s)) > %d {\n", indent, transformedVar, limit))
+		buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%d bytes after %s don't fit in a %s length\", len(%s))\n", indent, field.Name, field.Transform.Type, lengthType, transformedVar))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	}
+	buf.WriteString(fmt.Sprintf("%s%s\n", indent, fmt.Sprintf(write, transformedVar, runtimeEndianness)))
+	buf.WriteString(fmt.Sprintf("%sencoder.WriteBytes(%s)\n", indent, transformedVar))
+	return nil
+}
+
+// generateDecodeTransform reads a transformed field's bytes and reverses the transform
+// on them, giving the field's value in varName
+func generateDecodeTransform(buf *bytes.Buffer, field Field, fieldName, varName, runtimeEndianness, indent string) error {
+	lengthType := transformLengthType(field)
+	read, ok := lengthPrefixReads[lengthType]
+	if !ok {
+		return fmt.Errorf("%s: unsupported length_type %q", field.Name, lengthType)
+	}
+	if strings.Contains(read, "%s") {
+		read = fmt.Sprintf(read, runtimeEndianness)
+	}
+	buf.WriteString(fmt.Sprintf("%s%s_length, err := %s\n", indent, varName, read))
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	buf.WriteString(fmt.Sprintf("%sif uint64(%s_length) > uint64(decoder.Len()-decoder.Position()) {\n", indent, varName))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, decoder.Incomplete(fmt.Errorf(\"%s: length %%d exceeds remaining data\", %s_length))\n", indent, field.Name, varName))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	wireVar := varName + "_wire"
+	generateReadView(buf, wireVar, fmt.Sprintf("ReadBytesView(int(%s_length))", varName), indent)
+
+	reversedVar := varName
+	if field.Type == "string" {
+		reversedVar = varName + "_bytes"
+	}
+	buf.WriteString(fmt.Sprintf("%s%s, err := runtime.ReverseTransform(%q, %s, %s)\n", indent, reversedVar, field.Transform.Type, transformParams(field), wireVar))
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %%w\", err)\n", indent, field.Name))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	if field.Type == "string" {
+		buf.WriteString(fmt.Sprintf("%s%s := string(%s)\n", indent, varName, reversedVar))
+	}
+	if fieldName != "" {
+		buf.WriteString(fmt.Sprintf("%sresult.%s = %s\n\n", indent, fieldName, varName))
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+// ABOUTME: Generates discriminated unions as Go interfaces implemented by pointers to the variant types
+// ABOUTME: Decoders peek the discriminator to pick a variant; JSON uses the {"type", "value"} test-vector shape
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"regexp"
+	"strings"
+)
+
+// isUnion reports whether a schema type is a discriminated union
+func isUnion(schema *Schema, typeName string) bool {
+	typeDef, ok := schema.Types[typeName]
+	return ok && typeDef.Discriminator != nil
+}
+
+// markUnionFields flags fields and array items whose type is a discriminated union
+func markUnionFields(schema *Schema) {
+	for _, typeDef := range schema.Types {
+		for _, field := range typeDef.fieldPointers() {
+			field.Union = isUnion(schema, field.Type)
+			if field.Items != nil {
+				field.Items.Union = isUnion(schema, field.Items.Type)
+			}
+		}
+	}
+}
+
+// checkUnion reports unions this generator can't produce code for
+func checkUnion(schema *Schema, name string, typeDef *TypeDef) error {
+	if typeDef.Discriminator.Peek == "" {
+		return fmt.Errorf("union %s: only peek discriminators are supported", name)
+	}
+	if _, ok := peekMethods[typeDef.Discriminator.Peek]; !ok {
+		return fmt.Errorf("union %s: unsupported peek type %q", name, typeDef.Discriminator.Peek)
+	}
+	for _, variant := range typeDef.Variants {
+		variantDef, ok := schema.Types[variant.Type]
+		if !ok || variantDef.Discriminator != nil || variantDef.Bitfield || len(variantDef.Sequence) == 0 {
+			return fmt.Errorf("union %s: variant %s is not a struct type", name, variant.Type)
+		}
+	}
+	return nil
+}
+
+// Decoder methods peeking each discriminator type, and whether they take an endianness
+var peekMethods = map[string]bool{"uint8": false, "uint16": true, "uint32": true, "uint64": true}
+
+// generateUnion emits the union interface, its marker methods and, when direction
+// generates decoders, its Decode functions
+func generateUnion(buf *bytes.Buffer, name string, typeDef *TypeDef, defaultEndianness string, direction Direction) error {
+	var variants []string
+	for _, variant := range typeDef.Variants {
+		variants = append(variants, "*"+capitalizeFirst(variant.Type))
+	}
+	marker := "is" + name
+
+	buf.WriteString(fmt.Sprintf("// %s is a discriminated union of %s\n", name, strings.Join(variants, ", ")))
+	if typeDef.Description != "" {
+		buf.WriteString("//\n")
+		writeDoc(buf, "", typeDef.Description)
+	}
+	buf.WriteString(fmt.Sprintf("type %s interface {\n", name))
+	if direction.encodes() {
+		buf.WriteString("\tEncode() ([]byte, error)\n")
+		buf.WriteString("\tEncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error)\n")
+	}
+	buf.WriteString("\tString() string\n")
+	buf.WriteString("\tformat(f *runtime.Formatter)\n")
+	buf.WriteString(fmt.Sprintf("\t%s()\n", marker))
+	buf.WriteString("}\n\n")
+	for _, variant := range variants {
+		buf.WriteString(fmt.Sprintf("func (%s) %s() {}\n", variant, marker))
+	}
+	buf.WriteString("\n")
+	if !direction.decodes() {
+		return nil
+	}
+
+	buf.WriteString(fmt.Sprintf("func Decode%s(bytes []byte) (%s, error) {\n", name, name))
+	buf.WriteString(fmt.Sprintf("\tdecoder := runtime.NewBitStreamDecoder(bytes, %s)\n", runtimeBitOrder(typeDef.BitOrder)))
+	buf.WriteString(fmt.Sprintf("\treturn decode%sWithDecoder(decoder, nil)\n", name))
+	buf.WriteString("}\n\n")
+
+	// The discriminator is peeked, so the chosen variant decodes it again as its own field.
+	// A union adds no level of its own: the variant gets the union's parents.
+	buf.WriteString(fmt.Sprintf("func decode%sWithDecoder(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext) (%s, error) {\n", name, name))
+	peek := typeDef.Discriminator.Peek
+	if peekMethods[peek] {
+		endianness := typeDef.Discriminator.Endianness
+		if endianness == "" {
+			endianness = defaultEndianness
+		}
+		buf.WriteString(fmt.Sprintf("\tdiscriminator, err := decoder.Peek%s(runtime.%s)\n", capitalizeFirst(peek), mapEndianness(endianness)))
+	} else {
+		buf.WriteString(fmt.Sprintf("\tdiscriminator, err := decoder.Peek%s()\n", capitalizeFirst(peek)))
+	}
+	buf.WriteString("\tif err != nil {\n")
+	buf.WriteString("\t\treturn nil, err\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tswitch {\n")
+	for _, variant := range typeDef.Variants {
+		if variant.When == "" {
+			buf.WriteString("\tdefault:\n")
+		} else {
+			condition, err := unionCondition(variant.When)
+			if err != nil {
+				return fmt.Errorf("union %s: variant %s: %w", name, variant.Type, err)
+			}
+			buf.WriteString(fmt.Sprintf("\tcase %s:\n", condition))
+		}
+		buf.WriteString(fmt.Sprintf("\t\tv, err := decode%sWithDecoder(decoder, ctx)\n", capitalizeFirst(variant.Type)))
+		buf.WriteString("\t\tif err != nil {\n")
+		buf.WriteString("\t\t\treturn nil, err\n")
+		buf.WriteString("\t\t}\n")
+		buf.WriteString("\t\treturn v, nil\n")
+	}
+	buf.WriteString("\t}\n")
+	buf.WriteString(fmt.Sprintf("\treturn nil, fmt.Errorf(\"%s: no variant matches discriminator %%d\", discriminator)\n", name))
+	buf.WriteString("}\n\n")
+	return nil
+}
+
+var discriminatorValue = regexp.MustCompile(`\bvalue\b`)
+
+// unionCondition turns a variant's "when" expression on the peeked value into Go
+func unionCondition(when string) (string, error) {
+	condition := discriminatorValue.ReplaceAllString(when, "discriminator")
+	if _, err := parser.ParseExpr(condition); err != nil {
+		return "", fmt.Errorf("invalid when expression %q", when)
+	}
+	return condition, nil
+}
+
+// generateUnionJSON emits the wrapper that carries a union through JSON as
+// {"type": "<Variant>", "value": {...}}, and helpers converting slices of it
+func generateUnionJSON(buf *bytes.Buffer, name string, typeDef *TypeDef) {
+	wrapper := unionJSONType(name)
+
+	buf.WriteString(fmt.Sprintf("// %s carries a %s through JSON as {\"type\": ..., \"value\": ...}\n", wrapper, name))
+	buf.WriteString(fmt.Sprintf("type %s struct{ v %s }\n\n", wrapper, name))
+
+	buf.WriteString(fmt.Sprintf("func (j %s) MarshalJSON() ([]byte, error) {\n", wrapper))
+	buf.WriteString("\tswitch v := j.v.(type) {\n")
+	for _, variant := range typeDef.Variants {
+		buf.WriteString(fmt.Sprintf("\tcase *%s:\n", capitalizeFirst(variant.Type)))
+		buf.WriteString("\t\treturn json.Marshal(&struct {\n")
+		buf.WriteString("\t\t\tType  string `json:\"type\"`\n")
+		buf.WriteString(fmt.Sprintf("\t\t\tValue *%s `json:\"value\"`\n", capitalizeFirst(variant.Type)))
+		buf.WriteString(fmt.Sprintf("\t\t}{%q, v})\n", variant.schemaName()))
+	}
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn []byte(\"null\"), nil\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("func (j *%s) UnmarshalJSON(data []byte) error {\n", wrapper))
+	buf.WriteString("\tvar raw struct {\n")
+	buf.WriteString("\t\tType  string          `json:\"type\"`\n")
+	buf.WriteString("\t\tValue json.RawMessage `json:\"value\"`\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tif err := json.Unmarshal(data, &raw); err != nil {\n")
+	buf.WriteString("\t\treturn err\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tswitch raw.Type {\n")
+	buf.WriteString("\tcase \"\":\n")
+	buf.WriteString("\t\tj.v = nil\n")
+	buf.WriteString("\t\treturn nil\n")
+	for _, variant := range typeDef.Variants {
+		buf.WriteString(fmt.Sprintf("\tcase %q:\n", variant.schemaName()))
+		buf.WriteString(fmt.Sprintf("\t\tv := &%s{}\n", capitalizeFirst(variant.Type)))
+		buf.WriteString("\t\tj.v = v\n")
+		buf.WriteString("\t\treturn json.Unmarshal(raw.Value, v)\n")
+	}
+	buf.WriteString("\t}\n")
+	buf.WriteString(fmt.Sprintf("\treturn fmt.Errorf(\"%s: unknown variant %%q\", raw.Type)\n", name))
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("func %sSlice(items []%s) []%s {\n", wrapper, name, wrapper))
+	buf.WriteString("\tif items == nil {\n")
+	buf.WriteString("\t\treturn nil\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString(fmt.Sprintf("\tout := make([]%s, len(items))\n", wrapper))
+	buf.WriteString("\tfor i, item := range items {\n")
+	buf.WriteString(fmt.Sprintf("\t\tout[i] = %s{item}\n", wrapper))
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn out\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("func %s(items []%s) []%s {\n", unionSliceFunc(name), wrapper, name))
+	buf.WriteString("\tif items == nil {\n")
+	buf.WriteString("\t\treturn nil\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString(fmt.Sprintf("\tout := make([]%s, len(items))\n", name))
+	buf.WriteString("\tfor i, item := range items {\n")
+	buf.WriteString("\t\tout[i] = item.v\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn out\n")
+	buf.WriteString("}\n\n")
+}
+
+// generateUnionEmit emits emitX, which writes a union for MarshalCBOR and
+// MarshalMessagePack in the shape JSON uses: {"type": "<Variant>", "value": {...}}
+func generateUnionEmit(buf *bytes.Buffer, name string, typeDef *TypeDef) {
+	buf.WriteString(fmt.Sprintf("func emit%s(e *runtime.Emitter, v %s) {\n", name, name))
+	buf.WriteString("\tswitch v := v.(type) {\n")
+	for _, variant := range typeDef.Variants {
+		buf.WriteString(fmt.Sprintf("\tcase *%s:\n", capitalizeFirst(variant.Type)))
+		buf.WriteString("\t\te.BeginMap(2)\n")
+		buf.WriteString("\t\te.Key(\"type\")\n")
+		buf.WriteString(fmt.Sprintf("\t\te.String(%q)\n", variant.schemaName()))
+		buf.WriteString("\t\te.Key(\"value\")\n")
+		buf.WriteString("\t\tv.emit(e)\n")
+		buf.WriteString("\t\treturn\n")
+	}
+	buf.WriteString("\t}\n")
+	buf.WriteString("\te.Nil()\n")
+	buf.WriteString("}\n\n")
+}
+
+// unionJSONType names the JSON wrapper of a union
+func unionJSONType(name string) string {
+	return "json" + capitalizeFirst(name)
+}
+
+// unionSliceFunc names the helper turning a slice of JSON wrappers back into union values
+func unionSliceFunc(name string) string {
+	return strings.ToLower(name[:1]) + name[1:] + "Slice"
+}
+
+// terminalSwitch emits a type switch running action when value is one of the terminal variants
+func terminalSwitch(buf *bytes.Buffer, value string, terminal []string, action, indent string) {
+	var cases []string
+	for _, variant := range terminal {
+		cases = append(cases, "*"+capitalizeFirst(variant))
+	}
+	buf.WriteString(fmt.Sprintf("%sswitch %s.(type) {\n", indent, value))
+	buf.WriteString(fmt.Sprintf("%scase %s:\n", indent, strings.Join(cases, ", ")))
+	buf.WriteString(fmt.Sprintf("%s\t%s\n", indent, action))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
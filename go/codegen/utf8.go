@@ -0,0 +1,84 @@
+// ABOUTME: invalid_utf8 config: decoded utf8 strings kept as read, rejected or repaired when invalid
+// ABOUTME: DecodeXWithUTF8 and the decoder's UTF8 field choose another policy for one decode
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// The runtime policy of each invalid_utf8 value
+var utf8Policies = map[string]string{
+	"":        "UTF8Default",
+	"keep":    "UTF8Keep",
+	"reject":  "UTF8Reject",
+	"replace": "UTF8Replace",
+}
+
+// decodesUTF8 reports whether a field (or array item) is a string decoded as UTF-8 by
+// generated code, rather than by a transform or codec
+func decodesUTF8(field Field) bool {
+	return field.Type == "string" && (field.Encoding == "" || field.Encoding == "utf8") && field.Transform == nil && field.Codec == ""
+}
+
+// markUTF8Strings sets UTF8Policy on every string decoded as UTF-8 to the runtime policy
+// of the config's invalid_utf8, and DecodesUTF8 on every struct decoding one, itself or
+// through the types it references
+func markUTF8Strings(schema *Schema) {
+	policy := utf8Policies[""]
+	if schema.Config != nil {
+		policy = utf8Policies[schema.Config.InvalidUTF8]
+	}
+	edges := make(map[string][]typeRef)
+	direct := make(map[string]bool)
+	for name, typeDef := range schema.Types {
+		for _, field := range typeDef.fieldPointers() {
+			for _, f := range []*Field{field, field.Items} {
+				if f != nil && decodesUTF8(*f) {
+					f.UTF8Policy = policy
+					direct[name] = true
+				}
+			}
+			edges[name] = append(edges[name], fieldRefs(schema, *field, true)...)
+		}
+		for _, variant := range typeDef.Variants {
+			edges[name] = append(edges[name], typeRef{target: variant.Type})
+		}
+	}
+	for name, typeDef := range schema.Types {
+		typeDef.DecodesUTF8 = direct[name]
+		for target := range direct {
+			typeDef.DecodesUTF8 = typeDef.DecodesUTF8 || reaches(edges, name, target, false)
+		}
+	}
+}
+
+// utf8Conversion returns the expression converting a string's decoded bytes to its
+// value under the decoder's policy, a call returning the value and an error
+func utf8Conversion(field Field, bytesVar string) string {
+	method := "UTF8String"
+	if field.ZeroCopy {
+		method = "UTF8View"
+	}
+	return fmt.Sprintf("decoder.%s(%q, %s, runtime.%s)", method, fieldWhat(field), bytesVar, field.UTF8Policy)
+}
+
+// generateUTF8Conversion emits varName, the value of a string's decoded bytes under the
+// decoder's policy
+func generateUTF8Conversion(buf *bytes.Buffer, field Field, varName, bytesVar, indent string) {
+	buf.WriteString(fmt.Sprintf("%s%s, err := %s\n", indent, varName, utf8Conversion(field, bytesVar)))
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
+// generateDecodeWithUTF8 emits the public DecodeXWithUTF8
+func generateDecodeWithUTF8(buf *bytes.Buffer, typeName string, typeDef *TypeDef) {
+	buf.WriteString(fmt.Sprintf("// Decode%sWithUTF8 decodes bytes like Decode%s, with policy deciding what strings\n", typeName, typeName))
+	buf.WriteString("// that aren't valid UTF-8 decode to in place of the schema's\n")
+	buf.WriteString(fmt.Sprintf("func Decode%sWithUTF8(bytes []byte, policy runtime.UTF8Policy) (*%s, error) {\n", typeName, typeName))
+	buf.WriteString(fmt.Sprintf("\tdecoder := runtime.NewBitStreamDecoder(bytes, %s)\n", runtimeBitOrder(typeDef.BitOrder)))
+	buf.WriteString("\tdecoder.UTF8 = policy\n")
+	buf.WriteString(fmt.Sprintf("\treturn decode%sWithDecoder(decoder, nil)\n", typeName))
+	buf.WriteString("}\n\n")
+}
@@ -0,0 +1,1104 @@
+// ABOUTME: Validates a raw schema before code generation and reports problems with their paths
+// ABOUTME: Catches mistakes that would otherwise be skipped silently or produce Go code that doesn't compile
+package codegen
+
+import (
+	"fmt"
+	"go/token"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/serialexp/binschema/expression"
+)
+
+// Severity says whether a Diagnostic blocks code generation
+type Severity int
+
+const (
+	// SeverityError marks a schema the generator refuses
+	SeverityError Severity = iota
+	// SeverityWarning marks a likely mistake that still generates
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Diagnostic is one problem found by ValidateSchema
+type Diagnostic struct {
+	Severity Severity
+	Path     string // Location in the schema, e.g. "types.Message.sequence[2].items"
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	if d.Path == "" {
+		return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", d.Severity, d.Path, d.Message)
+}
+
+// builtinTypes are the type names a field may use without defining them
+var builtinTypes = attributeSet(
+	"uint8", "uint16", "uint32", "uint64", "uint128", "int8", "int16", "int32", "int64", "int128",
+	"float32", "float64", "bool", "bit", "int", "varlength", "bitfield", "struct",
+	"uuid", "mac", "ipv4", "ipv6", "unix32", "unix64", "unix_milli", "ntp", "bcd", "packed_bcd",
+	"string", "bytes", "array", "optional", "padding", "enum", "flags",
+	"discriminated_union", "choice", "back_reference",
+)
+
+// Required attributes of each array kind
+var arrayKinds = map[string][]string{
+	"fixed":                 {"length"},
+	"length_prefixed":       {"length_type"},
+	"length_prefixed_items": {"length_type", "item_length_type"},
+	"byte_length_prefixed":  {"length_type"},
+	"null_terminated":       nil,
+	"signature_terminated":  {"terminator_value", "terminator_type"},
+	"eof_terminated":        nil,
+	"field_referenced":      {"length_field"},
+	"variant_terminated":    {"terminal_variants"},
+	"computed_count":        {"count_expr"},
+}
+
+// Required attributes of each string kind
+var stringKinds = map[string][]string{
+	"fixed":            {"length"},
+	"length_prefixed":  {"length_type"},
+	"field_referenced": {"length_field"},
+	"null_terminated":  nil,
+}
+
+// BuiltinTypes returns the sorted type names a field may use without defining them
+func BuiltinTypes() []string { return sortedKeys(builtinTypes) }
+
+// ArrayKinds returns the sorted kinds an array field may have
+func ArrayKinds() []string { return sortedKeys(arrayKinds) }
+
+// StringKinds returns the sorted kinds a string field may have
+func StringKinds() []string { return sortedKeys(stringKinds) }
+
+// Attributes the generator defaults when a kind requires them but they're missing
+var kindDefaults = map[string]string{"length_type": "uint8", "item_length_type": "uint32"}
+
+// Methods generated on every struct; a field with the same Go name doesn't compile
+var generatedMethods = attributeSet("Encode", "String", "GoString", "MarshalJSON", "UnmarshalJSON", "Equal", "Hash", "Clone")
+
+// ValidateSchema checks a raw schema for mistakes: malformed types and fields,
+// references to undefined types, arrays and strings missing the attributes their
+// kind needs, duplicate field names, and length_field references to fields that
+// don't exist or come later. Unknown attributes are reported as warnings.
+//
+// GenerateGo and GenerateWireshark refuse schemas with error diagnostics.
+// Diagnostics are sorted by path.
+func ValidateSchema(data map[string]interface{}) []Diagnostic {
+	v := &validator{templates: make(map[string][]string)}
+
+	for _, path := range unknownAttributes(data) {
+		parent, attr := "", path
+		if dot := strings.LastIndex(path, "."); dot >= 0 {
+			parent, attr = path[:dot], path[dot+1:]
+		}
+		v.warnf(parent, "unknown attribute %q", attr)
+	}
+
+	if config, ok := data["config"].(map[string]interface{}); ok {
+		switch endianness := config["endianness"]; endianness {
+		case nil, "big_endian", "little_endian":
+		case "dynamic":
+			v.dynamic = true
+		default:
+			v.errorf("config", "endianness must be \"big_endian\", \"little_endian\" or \"dynamic\", got %v", endianness)
+		}
+		v.checkBitOrder("config", config)
+		for _, attr := range []string{"version", "magic"} {
+			if raw, ok := config[attr]; ok && parseVersionField(raw) == nil {
+				v.errorf("config", "%s must be { field: \"<field path>\", value: <unsigned integer> }", attr)
+			}
+		}
+		switch invalid := config["invalid_utf8"]; invalid {
+		case nil, "keep", "reject", "replace":
+		default:
+			v.errorf("config", "invalid_utf8 must be \"keep\", \"reject\" or \"replace\", got %v", invalid)
+		}
+	}
+
+	types, ok := data["types"].(map[string]interface{})
+	if !ok {
+		v.errorf("types", "schema has no types")
+		return v.sorted()
+	}
+	v.types = types
+	v.headerFields, v.payloadTypes = protocolContext(data, types)
+
+	// Generic templates like "Frame<T>" are referenced as "Frame<uint32>"
+	for name := range types {
+		if open := strings.Index(name, "<"); open > 0 && strings.HasSuffix(name, ">") {
+			var params []string
+			for _, param := range strings.Split(name[open+1:len(name)-1], ",") {
+				params = append(params, strings.TrimSpace(param))
+			}
+			v.templates[name[:open]] = params
+		}
+	}
+
+	goNames := make(map[string]string)
+	for _, name := range sortedKeys(types) {
+		path := "types." + name
+		if !strings.Contains(name, "<") {
+			goName := capitalizeFirst(name)
+			if other, ok := goNames[goName]; ok {
+				v.errorf(path, "types %q and %q both become Go type %s", other, name, goName)
+			}
+			goNames[goName] = name
+		}
+
+		typeData, ok := types[name].(map[string]interface{})
+		if !ok {
+			v.errorf(path, "type definition must be an object")
+			continue
+		}
+		var params []string
+		if open := strings.Index(name, "<"); open > 0 {
+			params = v.templates[name[:open]]
+		}
+		v.checkTypeRefs(path, typeData, params)
+		v.checkBitOrder(path, typeData)
+
+		if raw, isStruct := typeData["sequence"]; isStruct {
+			sequence, ok := raw.([]interface{})
+			if !ok {
+				v.errorf(path+".sequence", "sequence must be an array")
+				continue
+			}
+			v.checkSequence(name, path+".sequence", sequence)
+			if instances, ok := typeData["instances"]; ok {
+				if v.isProtobufType(name) {
+					v.errorf(path+".instances", "protobuf messages can't have instances")
+				} else {
+					v.checkInstances(path+".instances", instances, sequence)
+				}
+			}
+		} else {
+			// Type alias: an element type definition
+			v.checkElement(path, typeData)
+		}
+	}
+	return v.sorted()
+}
+
+// schemaError combines the error diagnostics of a schema into one error, or returns
+// nil if there are none
+func schemaError(diagnostics []Diagnostic) error {
+	var problems []string
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			problems = append(problems, fmt.Sprintf("%s: %s", d.Path, d.Message))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid schema: %s", strings.Join(problems, "; "))
+}
+
+type validator struct {
+	types        map[string]interface{}
+	templates    map[string][]string // Generic template name -> type parameters
+	headerFields []interface{}       // Protocol header fields, visible to message payload types
+	payloadTypes map[string]bool     // Types used as a protocol message payload
+	dynamic      bool                // Config endianness is "dynamic"
+	diagnostics  []Diagnostic
+}
+
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{SeverityError, path, fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(path, format string, args ...interface{}) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{SeverityWarning, path, fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) sorted() []Diagnostic {
+	sort.SliceStable(v.diagnostics, func(i, j int) bool {
+		return pathLess(v.diagnostics[i].Path, v.diagnostics[j].Path)
+	})
+	return v.diagnostics
+}
+
+// pathLess orders schema paths with array indexes compared as numbers,
+// so "sequence[2]" sorts before "sequence[10]"
+func pathLess(a, b string) bool {
+	for a != "" && b != "" {
+		na, restA := leadingNumber(a)
+		nb, restB := leadingNumber(b)
+		if na >= 0 && nb >= 0 {
+			if na != nb {
+				return na < nb
+			}
+			a, b = restA, restB
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+// leadingNumber splits off the decimal number s starts with, or returns -1
+func leadingNumber(s string) (int, string) {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	if end == 0 {
+		return -1, s
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n, s[end:]
+}
+
+// checkSequence validates the fields of a struct type
+func (v *validator) checkSequence(typeName, path string, sequence []interface{}) {
+	seen := make(map[string]string)    // Field name -> path
+	goNames := make(map[string]string) // Go field name -> field name
+	var earlier []interface{}
+	if v.payloadTypes[typeName] {
+		earlier = append(earlier, v.headerFields...)
+	}
+	protobuf := v.isProtobufType(typeName)
+	fieldNumbers := make(map[int]string) // Protobuf field number -> path
+
+	for i, raw := range sequence {
+		fieldPath := fmt.Sprintf("%s[%d]", path, i)
+		field, ok := raw.(map[string]interface{})
+		if !ok {
+			v.errorf(fieldPath, "field must be an object")
+			continue
+		}
+
+		name, _ := field["name"].(string)
+		switch {
+		case name == "":
+			v.errorf(fieldPath, "field has no name")
+		case !isIdentifier(name):
+			v.errorf(fieldPath, "field name %q is not a valid identifier", name)
+		default:
+			goName := v.goName(fieldPath, field, name)
+			if other, ok := seen[name]; ok {
+				v.errorf(fieldPath, "duplicate field name %q (also %s)", name, other)
+			} else if other, ok := goNames[goName]; ok {
+				v.errorf(fieldPath, "fields %q and %q both become Go field %s", other, name, goName)
+			}
+			if generatedMethods[goName] {
+				v.errorf(fieldPath, "field %q becomes Go field %s, which clashes with the generated %s method", name, goName, goName)
+			}
+			seen[name] = fieldPath
+			goNames[goName] = name
+		}
+
+		if protobuf {
+			v.checkProtobufField(fieldPath, field, fieldNumbers)
+			if _, ok := field["codec"]; ok {
+				v.errorf(fieldPath, "codec fields aren't supported in protobuf messages")
+			}
+		} else {
+			v.checkElement(fieldPath, field)
+		}
+		if name != "" {
+			v.checkInlineGroup(typeName, name, fieldPath, field)
+		}
+		if ref, ok := field["length_field"].(string); ok && field["kind"] == "field_referenced" {
+			v.checkLengthField(fieldPath, "length_field", ref, ref, earlier, sequence[i+1:])
+		}
+		v.checkLengthExpression(fieldPath, field, earlier, sequence[i+1:])
+		if selects, ok := field["selects_endianness"]; ok {
+			v.checkEndiannessSelector(fieldPath, field, selects)
+		}
+		if computed, ok := field["computed"].(map[string]interface{}); ok && computed["type"] == "position_of" {
+			if fieldType, _ := field["type"].(string); !unsignedTypes[fieldType] {
+				v.errorf(fieldPath, "position_of needs an unsigned integer type (uint8, uint16, uint32, uint64), got %q", fieldType)
+			}
+		}
+		if computed, ok := field["computed"].(map[string]interface{}); ok {
+			v.checkByteRange(fieldPath, field, computed, sequence)
+		}
+		earlier = append(earlier, field)
+	}
+}
+
+// checkByteRange checks a computed field over a range of the struct's encoded bytes:
+// the fields its from and to name, and a type its value fits
+func (v *validator) checkByteRange(path string, field, computed map[string]interface{}, sequence []interface{}) {
+	byteRange := parseByteRange(computed)
+	if byteRange == nil {
+		return
+	}
+	fieldType, _ := field["type"].(string)
+	if byteRange.Of == "crc32_of" && fieldType != "uint32" {
+		v.errorf(path, "crc32_of needs a uint32 field, got %q", fieldType)
+	} else if !unsignedTypes[fieldType] {
+		v.errorf(path, "length_of a byte range needs an unsigned integer type (uint8, uint16, uint32, uint64), got %q", fieldType)
+	}
+	from, to := 0, len(sequence)-1
+	for i, raw := range sequence {
+		if other, ok := raw.(map[string]interface{}); ok {
+			if byteRange.From != "" && other["name"] == byteRange.From {
+				from = i
+			}
+			if byteRange.To != "" && other["name"] == byteRange.To {
+				to = i
+			}
+		}
+	}
+	for _, end := range []string{byteRange.From, byteRange.To} {
+		if end != "" && findField(sequence, end) == nil {
+			v.errorf(path, "%s: no field %q in this struct", byteRange.Of, end)
+			return
+		}
+	}
+	if from > to {
+		v.errorf(path, "%s: range from %q starts after its end %q", byteRange.Of, byteRange.From, byteRange.To)
+	}
+}
+
+// checkBitOrder checks the bit_order of the config or a type
+func (v *validator) checkBitOrder(path string, data map[string]interface{}) {
+	if order, ok := data["bit_order"]; ok && order != "msb_first" && order != "lsb_first" {
+		v.errorf(path, "bit_order must be \"msb_first\" or \"lsb_first\", got %v", order)
+	}
+}
+
+// checkEndiannessSelector validates a selects_endianness field, whose value picks the
+// byte order of the fields after it
+func (v *validator) checkEndiannessSelector(path string, field map[string]interface{}, raw interface{}) {
+	if !v.dynamic {
+		v.errorf(path, "selects_endianness needs config endianness \"dynamic\"")
+	}
+	if fieldType, _ := field["type"].(string); !unsignedTypes[fieldType] {
+		v.errorf(path, "selects_endianness needs an unsigned integer type (uint8, uint16, uint32, uint64), got %q", fieldType)
+	}
+	if _, ok := field["conditional"]; ok {
+		v.errorf(path, "selects_endianness fields can't be conditional")
+	}
+	selects, ok := raw.(map[string]interface{})
+	if !ok || len(selects) == 0 {
+		v.errorf(path, "selects_endianness must map \"big_endian\" and/or \"little_endian\" to values")
+		return
+	}
+	for _, order := range sortedKeys(selects) {
+		if order != "big_endian" && order != "little_endian" {
+			v.errorf(path, "selects_endianness: unknown byte order %q", order)
+		}
+		if n, ok := selects[order].(float64); !ok || n < 0 || n != float64(uint64(n)) {
+			v.errorf(path, "selects_endianness: %s must be a non-negative integer, got %v", order, selects[order])
+		}
+	}
+	if selects["big_endian"] != nil && selects["big_endian"] == selects["little_endian"] {
+		v.errorf(path, "selects_endianness: big_endian and little_endian have the same value")
+	}
+}
+
+// checkElement validates a field or element type (array items, type aliases) on its own
+func (v *validator) checkElement(path string, field map[string]interface{}) {
+	fieldType, ok := field["type"].(string)
+	if !ok || fieldType == "" {
+		v.errorf(path, "field has no type")
+		return
+	}
+
+	if codec, ok := field["codec"]; ok {
+		v.checkCodec(path, field, codec)
+		// The codec decides the bytes, so strings and arrays need no kind
+		if fieldType == "string" {
+			return
+		}
+		if items, ok := field["items"].(map[string]interface{}); ok && fieldType == "array" {
+			v.checkElement(path+".items", items)
+			return
+		}
+	}
+
+	switch fieldType {
+	case "array":
+		items, ok := field["items"].(map[string]interface{})
+		if !ok {
+			v.errorf(path, "array has no items")
+		} else {
+			v.checkElement(path+".items", items)
+		}
+		if itemType, _ := items["type"].(string); v.isProtobufType(itemType) {
+			v.errorf(path, "arrays of protobuf messages are only supported in protobuf messages")
+		}
+		v.checkKind(path, field, "array", arrayKinds)
+		if terminal, ok := field["terminal_variants"]; ok {
+			v.checkTerminalVariants(path, field, terminal)
+		}
+	case "string":
+		v.checkKind(path, field, "string", stringKinds)
+		v.checkPadding(path, field)
+		v.checkMaxLength(path, field)
+	case "bit", "int":
+		if size, ok := field["size"].(float64); !ok || size < 1 || size > 64 || size != float64(int(size)) {
+			v.errorf(path, "%s needs a size of 1 to 64 bits, got %v", fieldType, field["size"])
+		}
+	case "bitfield":
+		subfields, ok := field["fields"].([]interface{})
+		if !ok {
+			v.errorf(path, "bitfield has no fields")
+		}
+		v.checkBitfield(path+".fields", subfields)
+	case "struct":
+		if _, ok := field["fields"].([]interface{}); !ok {
+			v.errorf(path, "struct has no fields")
+		}
+	case "unix32", "unix64", "unix_milli", "ntp":
+		v.checkTimestamp(path, fieldType, field)
+	case "flags":
+		v.checkFlags(path, field)
+	default:
+		if v.isProtobufType(fieldType) {
+			v.checkEmbeddedProtobuf(path, field)
+		}
+	case "bcd", "packed_bcd":
+		asString := field["decode_as"] == "string"
+		if decodeAs, ok := field["decode_as"]; ok && decodeAs != "int" && !asString {
+			v.errorf(path, "decode_as must be \"int\" or \"string\", got %v", decodeAs)
+		}
+		digits, ok := field["digits"].(float64)
+		if !ok || digits < 1 || digits != float64(int(digits)) {
+			v.errorf(path, "%s needs a positive digits count, got %v", fieldType, field["digits"])
+		} else if digits > 18 && !asString {
+			v.errorf(path, "%g digits don't fit in an int64; use decode_as \"string\"", digits)
+		}
+	}
+	if transform, ok := field["transform"]; ok {
+		v.checkTransform(path, field, transform)
+	}
+}
+
+// Integer types of protobuf message fields, by width in bits; negative for signed ones
+var protobufIntegers = map[string]int{
+	"uint8": 8, "uint16": 16, "uint32": 32, "uint64": 64,
+	"int8": -8, "int16": -16, "int32": -32, "int64": -64,
+}
+
+// Values of proto_type, by the widest integer they hold; negative for signed ones
+var protoTypeWidths = map[string]int{
+	"sint32": -32, "sint64": -64, "sfixed32": -32, "sfixed64": -64, "fixed32": 32, "fixed64": 64,
+}
+
+// checkProtobufField checks a field of a protobuf message: a unique field number
+// protobuf allows, and a type with a protobuf encoding. Fields are tagged rather
+// than in sequence, so they have no kind and can't be conditional or computed.
+func (v *validator) checkProtobufField(path string, field map[string]interface{}, fieldNumbers map[int]string) {
+	n, ok := field["field_number"].(float64)
+	switch {
+	case !ok || n != math.Trunc(n) || n < 1 || n > 1<<29-1:
+		v.errorf(path, "protobuf fields need a field_number from 1 to 536870911, got %v", field["field_number"])
+	case n >= 19000 && n <= 19999:
+		v.errorf(path, "field_number %g is reserved by protobuf", n)
+	default:
+		if other, ok := fieldNumbers[int(n)]; ok {
+			v.errorf(path, "duplicate field_number %g (also %s)", n, other)
+		}
+		fieldNumbers[int(n)] = path
+	}
+	for _, attr := range []string{"conditional", "computed", "kind"} {
+		if _, ok := field[attr]; ok {
+			v.errorf(path, "protobuf fields can't have %q", attr)
+		}
+	}
+
+	fieldType, _ := field["type"].(string)
+	element := field
+	if fieldType == "array" {
+		items, ok := field["items"].(map[string]interface{})
+		if !ok {
+			v.errorf(path, "array has no items")
+			return
+		}
+		element = items
+		fieldType, _ = items["type"].(string)
+		if fieldType == "array" {
+			v.errorf(path, "repeated protobuf fields can't be arrays of arrays")
+			return
+		}
+	}
+	protoType, hasProtoType := field["proto_type"].(string)
+	if !hasProtoType {
+		protoType, hasProtoType = element["proto_type"].(string)
+	}
+
+	switch {
+	case protobufIntegers[fieldType] != 0:
+		if !hasProtoType {
+			return
+		}
+		width, known := protoTypeWidths[protoType]
+		bits := protobufIntegers[fieldType]
+		switch {
+		case !known:
+			v.errorf(path, "unknown proto_type %q (want %s)", protoType, strings.Join(sortedKeys(protoTypeWidths), ", "))
+		case (width < 0) != (bits < 0):
+			v.errorf(path, "proto_type %s doesn't match the signedness of %s", protoType, fieldType)
+		case bits*bits > width*width:
+			v.errorf(path, "proto_type %s can't hold a %s", protoType, fieldType)
+		}
+	case fieldType == "float32" || fieldType == "float64" || fieldType == "string" || v.isStructType(fieldType):
+		if hasProtoType {
+			v.errorf(path, "proto_type is only for integer fields")
+		}
+	default:
+		v.errorf(path, "type %q can't be a protobuf field", fieldType)
+	}
+}
+
+// checkEmbeddedProtobuf checks a protobuf message field of another type. Protobuf
+// messages don't mark their end, so it needs a length or must end the input.
+func (v *validator) checkEmbeddedProtobuf(path string, field map[string]interface{}) {
+	switch kind := field["kind"]; kind {
+	case nil, "field_referenced":
+	case "length_prefixed":
+		if lengthType, ok := field["length_type"]; ok && !unsignedTypes[fmt.Sprint(lengthType)] {
+			v.errorf(path, "length_type must be uint8, uint16, uint32 or uint64, got %v", lengthType)
+		}
+	default:
+		v.errorf(path, "protobuf message fields need kind length_prefixed or field_referenced, got %v", kind)
+	}
+}
+
+// checkTimestamp checks the unit and epoch of a timestamp; NTP has its own
+func (v *validator) checkTimestamp(path, fieldType string, field map[string]interface{}) {
+	unit, hasUnit := field["unit"]
+	epoch, hasEpoch := field["epoch"]
+	if fieldType == "ntp" {
+		if hasUnit || hasEpoch {
+			v.errorf(path, "ntp timestamps have a fixed unit and epoch")
+		}
+		return
+	}
+	if name, ok := unit.(string); hasUnit && (!ok || timestampUnits[name] == "") {
+		v.errorf(path, "unit must be s, ms, us or ns, got %v", unit)
+	}
+	if s, ok := epoch.(string); hasEpoch {
+		if _, err := time.Parse(time.RFC3339Nano, s); !ok || err != nil {
+			v.errorf(path, "epoch must be an RFC 3339 time, got %v", epoch)
+		}
+	}
+}
+
+// checkFlags checks that a flag set is stored as an unsigned integer and that each
+// flag is a Go identifier with nonzero bits the integer can hold
+func (v *validator) checkFlags(path string, field map[string]interface{}) {
+	repr, _ := field["repr"].(string)
+	if !unsignedTypes[repr] {
+		v.errorf(path, "flags need a repr of uint8, uint16, uint32 or uint64, got %v", field["repr"])
+		repr = "uint64"
+	}
+	variants, ok := field["variants"].(map[string]interface{})
+	if !ok || len(variants) == 0 {
+		v.errorf(path, "flags need a non-empty variants map of flag name to bits")
+		return
+	}
+	max := math.Ldexp(1, reprBits[repr])
+	for _, name := range sortedKeys(variants) {
+		if !isIdentifier(name) {
+			v.errorf(path+".variants", "flag name %q is not an identifier", name)
+		}
+		bits, ok := variants[name].(float64)
+		if !ok || bits < 1 || bits >= max || bits != math.Trunc(bits) {
+			v.errorf(path+".variants", "flag %q must be nonzero bits that fit in a %s, got %v", name, repr, variants[name])
+		}
+	}
+}
+
+// checkTerminalVariants checks that an array's terminal_variants name variants of its
+// union items, on an array kind that stops at them
+func (v *validator) checkTerminalVariants(path string, field map[string]interface{}, terminal interface{}) {
+	if kind := field["kind"]; kind != "null_terminated" && kind != "variant_terminated" {
+		v.errorf(path, "terminal_variants needs a null_terminated or variant_terminated array, not %v", kind)
+		return
+	}
+	names, ok := terminal.([]interface{})
+	if !ok || len(names) == 0 {
+		v.errorf(path, "terminal_variants must be a non-empty list of variant types")
+		return
+	}
+
+	items, _ := field["items"].(map[string]interface{})
+	union := items
+	if itemType, _ := items["type"].(string); itemType != "discriminated_union" {
+		union, _ = v.types[itemType].(map[string]interface{})
+	}
+	if union["type"] != "discriminated_union" {
+		v.errorf(path, "terminal_variants needs items that are a discriminated_union")
+		return
+	}
+
+	variants := make(map[string]bool)
+	rawVariants, _ := union["variants"].([]interface{})
+	for _, raw := range rawVariants {
+		if variant, ok := raw.(map[string]interface{}); ok {
+			if name, ok := variant["type"].(string); ok {
+				variants[name] = true
+			}
+		}
+	}
+	for _, raw := range names {
+		if name, _ := raw.(string); !variants[name] {
+			v.errorf(path, "terminal variant %q is not a variant of the items (want %s)", name, strings.Join(sortedKeys(variants), ", "))
+		}
+	}
+}
+
+// checkInlineGroup checks an inline bitfield or struct (directly or as array items),
+// which the generator turns into a type named <Parent>_<Field>
+func (v *validator) checkInlineGroup(typeName, name, path string, field map[string]interface{}) {
+	if items, ok := field["items"].(map[string]interface{}); ok && field["type"] == "array" {
+		field, path = items, path+".items"
+	}
+	fieldType, _ := field["type"].(string)
+	if fieldType != "bitfield" && fieldType != "struct" && fieldType != "flags" {
+		return
+	}
+	hoisted := typeName + "_" + capitalizeFirst(name)
+	if _, exists := v.types[hoisted]; exists {
+		v.errorf(path, "inline %s %q becomes Go type %s, which is already defined", fieldType, name, hoisted)
+	}
+	if fields, ok := field["fields"].([]interface{}); ok && fieldType == "struct" {
+		v.checkSequence(hoisted, path+".fields", fields)
+	}
+}
+
+// checkBitfield checks that each bitfield subfield has a unique name and a width
+func (v *validator) checkBitfield(path string, subfields []interface{}) {
+	seen := make(map[string]string)
+	for i, raw := range subfields {
+		subPath := fmt.Sprintf("%s[%d]", path, i)
+		sub, ok := raw.(map[string]interface{})
+		if !ok {
+			v.errorf(subPath, "bitfield field must be an object")
+			continue
+		}
+		name, _ := sub["name"].(string)
+		switch {
+		case name == "":
+			v.errorf(subPath, "bitfield field has no name")
+		case !isIdentifier(name):
+			v.errorf(subPath, "field name %q is not a valid identifier", name)
+		default:
+			goName := v.goName(subPath, sub, name)
+			if other, ok := seen[goName]; ok {
+				v.errorf(subPath, "duplicate bitfield field name %q (also %s)", name, other)
+			}
+			seen[goName] = subPath
+		}
+		if size, ok := sub["size"].(float64); !ok || size < 1 || size > 64 || size != float64(int(size)) {
+			v.errorf(subPath, "bitfield field size must be an integer from 1 to 64, got %v", sub["size"])
+		}
+		if _, ok := sub["codec"]; ok {
+			v.errorf(subPath, "bitfield fields are read together and can't have a codec")
+		}
+	}
+}
+
+// checkKind checks that an array or string has a known kind and the attributes it requires
+func (v *validator) checkKind(path string, field map[string]interface{}, what string, kinds map[string][]string) {
+	kind, ok := field["kind"].(string)
+	if !ok {
+		v.errorf(path, "%s has no kind", what)
+		return
+	}
+	required, ok := kinds[kind]
+	if !ok {
+		v.errorf(path, "unknown %s kind %q (want %s)", what, kind, strings.Join(sortedKeys(kinds), ", "))
+		return
+	}
+	for _, attr := range required {
+		if _, ok := field[attr]; ok {
+			continue
+		}
+		if def, ok := kindDefaults[attr]; ok {
+			v.warnf(path, "%s %s has no %q, defaulting to %s", kind, what, attr, def)
+		} else {
+			v.errorf(path, "%s %s requires %q", kind, what, attr)
+		}
+	}
+	if kind == "fixed" {
+		if length, ok := field["length"]; ok {
+			if _, isExpression := length.(string); isExpression {
+				return
+			}
+			if n, isNumber := length.(float64); !isNumber || n < 0 || n != float64(int(n)) {
+				v.errorf(path, "fixed length must be a non-negative integer, got %v", length)
+			}
+		}
+	}
+}
+
+// checkPadding checks the padding of a fixed-length string
+func (v *validator) checkPadding(path string, field map[string]interface{}) {
+	padding, ok := field["padding"]
+	if !ok {
+		return
+	}
+	switch padding {
+	case "null", "space", "none":
+	default:
+		v.errorf(path, "padding must be \"null\", \"space\" or \"none\", got %v", padding)
+		return
+	}
+	if field["kind"] != "fixed" {
+		v.errorf(path, "padding only applies to fixed-length strings")
+	}
+}
+
+// checkMaxLength checks the max_length of a null-terminated string
+func (v *validator) checkMaxLength(path string, field map[string]interface{}) {
+	maxLength, ok := field["max_length"]
+	if !ok {
+		return
+	}
+	if n, isNumber := maxLength.(float64); !isNumber || n < 1 || n != float64(int(n)) {
+		v.errorf(path, "max_length must be a positive integer, got %v", maxLength)
+	}
+	if field["kind"] != "null_terminated" {
+		v.errorf(path, "max_length only applies to null-terminated strings")
+	}
+}
+
+// checkLengthExpression parses a fixed length given as an expression ("rdlength - 2") or
+// a computed_count's count_expr, and resolves the fields it reads against the fields
+// before it. Parent (../) fields are not checked.
+func (v *validator) checkLengthExpression(path string, field map[string]interface{}, earlier, later []interface{}) {
+	attr := "length"
+	src, ok := field["length"].(string)
+	if !ok || field["kind"] != "fixed" {
+		attr = "count_expr"
+		src, ok = field["count_expr"].(string)
+		if !ok || field["kind"] != "computed_count" {
+			return
+		}
+	}
+	v.checkExpressionFields(path, attr, src, earlier, later)
+}
+
+// checkExpressionFields parses an expression and resolves the fields it reads
+func (v *validator) checkExpressionFields(path, attr, src string, earlier, later []interface{}) {
+	node, err := expression.Parse(src)
+	if err != nil {
+		v.errorf(path, "%s %q: %v", attr, src, err)
+		return
+	}
+	for _, ref := range expression.Fields(node) {
+		if !strings.HasPrefix(ref, "../") {
+			v.checkLengthField(path, attr, src, ref, earlier, later)
+		}
+	}
+}
+
+// checkInstances validates the instance fields of a struct type, fields decoded at a
+// position. Positions and sizes may read the sequence and earlier instances.
+func (v *validator) checkInstances(path string, raw interface{}, sequence []interface{}) {
+	instances, ok := raw.([]interface{})
+	if !ok {
+		v.errorf(path, "instances must be an array")
+		return
+	}
+	goNames := make(map[string]string) // Go field name -> field name
+	for _, raw := range sequence {
+		if field, ok := raw.(map[string]interface{}); ok {
+			if name, ok := field["name"].(string); ok {
+				goNames[fieldGoName(field, name)] = name
+			}
+		}
+	}
+	earlier := append([]interface{}{}, sequence...)
+
+	for i, raw := range instances {
+		instancePath := fmt.Sprintf("%s[%d]", path, i)
+		instance, ok := raw.(map[string]interface{})
+		if !ok {
+			v.errorf(instancePath, "instance must be an object")
+			continue
+		}
+
+		name, _ := instance["name"].(string)
+		switch {
+		case name == "":
+			v.errorf(instancePath, "instance has no name")
+		case !isIdentifier(name):
+			v.errorf(instancePath, "instance name %q is not a valid identifier", name)
+		default:
+			goName := v.goName(instancePath, instance, name)
+			if other, ok := goNames[goName]; ok {
+				v.errorf(instancePath, "instance %q and field %q both become Go field %s", name, other, goName)
+			}
+			goNames[goName] = name
+		}
+		if _, ok := instance["type"]; !ok {
+			v.errorf(instancePath, "instance has no type")
+		}
+
+		offsets, hasOffsets := instance["offsets"]
+		if hasOffsets {
+			v.checkOffsetTable(instancePath, instance, offsets, earlier)
+		}
+		for _, attr := range []string{"position", "size"} {
+			switch value := instance[attr].(type) {
+			case nil:
+				if attr == "position" && !hasOffsets {
+					v.errorf(instancePath, "instance has no position")
+				}
+			case float64:
+				if value != float64(int(value)) || (attr == "size" && value < 0) {
+					v.errorf(instancePath, "%s must be an integer, got %v", attr, value)
+				}
+			case string:
+				v.checkExpressionFields(instancePath, attr, value, earlier, instances[i+1:])
+			default:
+				v.errorf(instancePath, "%s must be a number or an expression, got %v", attr, value)
+			}
+		}
+		if alignment, ok := instance["alignment"]; ok {
+			if n, isNumber := alignment.(float64); !isNumber || n < 1 || n != float64(int(n)) || int(n)&(int(n)-1) != 0 {
+				v.errorf(instancePath, "alignment must be a power of 2, got %v", alignment)
+			}
+		}
+		earlier = append(earlier, instance)
+	}
+}
+
+// checkOffsetTable validates an array instance located by an offsets array: the name of
+// an earlier array of unsigned integers, one position for each item
+func (v *validator) checkOffsetTable(path string, instance map[string]interface{}, offsets interface{}, earlier []interface{}) {
+	for _, attr := range []string{"position", "size"} {
+		if _, ok := instance[attr]; ok {
+			v.errorf(path, "instance has both offsets and a %s", attr)
+		}
+	}
+	if _, ok := instance["items"].(map[string]interface{}); instance["type"] != "array" || !ok {
+		v.errorf(path, "offsets locate the items of an array, but the instance is of type %v", instance["type"])
+	}
+	name, ok := offsets.(string)
+	if !ok {
+		v.errorf(path, "offsets must be the name of a field, got %v", offsets)
+		return
+	}
+	field := findField(earlier, name)
+	if field == nil {
+		v.errorf(path, "offsets %q: no field %q before this one", name, name)
+		return
+	}
+	items, _ := field["items"].(map[string]interface{})
+	if itemType, _ := items["type"].(string); field["type"] != "array" || !unsignedTypes[itemType] {
+		v.errorf(path, "offsets %q: %s is not an array of unsigned integers", name, name)
+	}
+}
+
+// checkLengthField resolves a field reference of a length (attr, with source src) against
+// the fields before it. References into the root type ("_root.x") depend on the message
+// being decoded and are not checked.
+func (v *validator) checkLengthField(path, attr, src, ref string, earlier, later []interface{}) {
+	if strings.HasPrefix(ref, "_root.") {
+		return
+	}
+	parts := strings.Split(ref, ".")
+	fields := earlier
+	for i, part := range parts {
+		field := findField(fields, part)
+		if field == nil {
+			if i == 0 && findField(later, part) != nil {
+				v.errorf(path, "%s %q: %s comes after this field (forward reference)", attr, src, part)
+			} else if i == 0 {
+				v.errorf(path, "%s %q: no field %q before this one", attr, src, part)
+			} else {
+				v.errorf(path, "%s %q: %s has no field %q", attr, src, strings.Join(parts[:i], "."), part)
+			}
+			return
+		}
+		fieldType, _ := field["type"].(string)
+		if i == len(parts)-1 {
+			if notInteger[fieldType] || v.isStructType(fieldType) || field["decode_as"] == "string" {
+				v.errorf(path, "%s %q: %s is a %s, not an integer", attr, src, ref, fieldType)
+			}
+			return
+		}
+
+		// Descend into a bitfield, an inline struct or a struct type
+		if fieldType == "bitfield" || fieldType == "struct" {
+			fields, _ = field["fields"].([]interface{})
+			continue
+		}
+		typeData, _ := v.types[fieldType].(map[string]interface{})
+		sequence, ok := typeData["sequence"].([]interface{})
+		if !ok {
+			v.errorf(path, "%s %q: %s is a %s, not a struct or bitfield", attr, src, strings.Join(parts[:i+1], "."), fieldType)
+			return
+		}
+		fields = sequence
+	}
+}
+
+// Types a byte offset can be stored in
+var unsignedTypes = map[string]bool{"uint8": true, "uint16": true, "uint32": true, "uint64": true}
+
+// Bits in each of the unsignedTypes
+var reprBits = map[string]int{"uint8": 8, "uint16": 16, "uint32": 32, "uint64": 64}
+
+// Builtin types a length or position can't be read from
+var notInteger = map[string]bool{"string": true, "array": true, "float32": true, "float64": true, "bitfield": true, "struct": true,
+	"uuid": true, "mac": true, "ipv4": true, "ipv6": true,
+	"unix32": true, "unix64": true, "unix_milli": true, "ntp": true}
+
+// isStructType reports whether name is a schema type with a sequence
+func (v *validator) isStructType(name string) bool {
+	typeData, _ := v.types[name].(map[string]interface{})
+	_, ok := typeData["sequence"]
+	return ok
+}
+
+// isProtobufType reports whether name is a schema type marked as a protobuf message
+func (v *validator) isProtobufType(name string) bool {
+	typeData, _ := v.types[name].(map[string]interface{})
+	protobuf, _ := typeData["protobuf"].(bool)
+	return protobuf
+}
+
+// checkTypeRefs reports "type" and "target_type" attributes anywhere in a type
+// definition that name neither a builtin, a schema type nor a type parameter
+func (v *validator) checkTypeRefs(path string, value interface{}, params []string) {
+	switch x := value.(type) {
+	case map[string]interface{}:
+		for _, key := range []string{"type", "target_type"} {
+			if name, ok := x[key].(string); ok && name != "" && !v.knownType(name, params) {
+				v.errorf(path, "unknown type %q", name)
+			}
+		}
+		for _, key := range sortedKeys(x) {
+			// Free-form values, computed field specs ({"type": "length_of"}) and transforms
+			// ({"type": "xor"}) hold no type references
+			if key != "metadata" && key != "example" && key != "computed" && key != "transform" {
+				v.checkTypeRefs(path+"."+key, x[key], params)
+			}
+		}
+	case []interface{}:
+		for i, item := range x {
+			v.checkTypeRefs(fmt.Sprintf("%s[%d]", path, i), item, params)
+		}
+	}
+}
+
+func (v *validator) knownType(name string, params []string) bool {
+	if builtinTypes[name] || v.types[name] != nil {
+		return true
+	}
+	for _, param := range params {
+		if name == param {
+			return true
+		}
+	}
+	open := strings.Index(name, "<")
+	if open <= 0 || !strings.HasSuffix(name, ">") {
+		return false
+	}
+	want, ok := v.templates[name[:open]]
+	if !ok {
+		return false
+	}
+	args := strings.Split(name[open+1:len(name)-1], ",")
+	if len(args) != len(want) {
+		return false
+	}
+	for _, arg := range args {
+		if !v.knownType(strings.TrimSpace(arg), params) {
+			return false
+		}
+	}
+	return true
+}
+
+// protocolContext returns the header fields and message payload types of a protocol
+// schema; payload types may reference header fields in length_field
+func protocolContext(data map[string]interface{}, types map[string]interface{}) ([]interface{}, map[string]bool) {
+	payloads := make(map[string]bool)
+	protocol, ok := data["protocol"].(map[string]interface{})
+	if !ok {
+		return nil, payloads
+	}
+	messages, _ := protocol["messages"].([]interface{})
+	for _, raw := range messages {
+		if message, ok := raw.(map[string]interface{}); ok {
+			if payload, ok := message["payload_type"].(string); ok {
+				payloads[payload] = true
+			}
+		}
+	}
+	header, _ := protocol["header"].(string)
+	headerData, _ := types[header].(map[string]interface{})
+	fields, _ := headerData["sequence"].([]interface{})
+	return fields, payloads
+}
+
+// findField returns the field with the given name from a list of raw fields
+func findField(fields []interface{}, name string) map[string]interface{} {
+	for _, raw := range fields {
+		if field, ok := raw.(map[string]interface{}); ok && field["name"] == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// goName returns the Go struct field a field named name becomes, checking its go_name
+func (v *validator) goName(path string, field map[string]interface{}, name string) string {
+	if raw, ok := field["go_name"]; ok {
+		goName, _ := raw.(string)
+		if !isIdentifier(goName) || !token.IsExported(goName) {
+			v.errorf(path, "go_name must be an exported Go identifier, got %v", raw)
+			return capitalizeFirst(name)
+		}
+	}
+	return fieldGoName(field, name)
+}
+
+// fieldGoName returns the Go struct field a field named name becomes: its go_name, or
+// its capitalized name
+func fieldGoName(field map[string]interface{}, name string) string {
+	if goName, ok := field["go_name"].(string); ok && goName != "" {
+		return goName
+	}
+	return capitalizeFirst(name)
+}
+
+// isIdentifier reports whether name is a Go identifier: letters, in any script, digits
+// and underscores, not starting with a digit
+func isIdentifier(name string) bool {
+	for i, r := range name {
+		letter := r == '_' || unicode.IsLetter(r)
+		if !letter && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
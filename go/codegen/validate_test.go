@@ -0,0 +1,448 @@
+// ABOUTME: Tests for schema validation before code generation
+// ABOUTME: Checks each diagnostic's path and message, and that generators refuse invalid schemas
+package codegen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aeolun/json5"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTestSchema(t *testing.T, src string) map[string]interface{} {
+	t.Helper()
+	var schema map[string]interface{}
+	require.NoError(t, json5.Unmarshal([]byte(src), &schema))
+	return schema
+}
+
+func diagnosticStrings(diagnostics []Diagnostic) []string {
+	var out []string
+	for _, d := range diagnostics {
+		out = append(out, d.String())
+	}
+	return out
+}
+
+func TestValidateSchema(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Flags": { type: "bitfield", size: 8, fields: [ { name: "count", offset: 0, size: 4 } ] },
+			"Header": { sequence: [ { name: "len", type: "uint8" } ] },
+			"header": { sequence: [] },
+			"Broken": "uint8",
+			"Packet": { sequence: [
+				{ name: "id", type: "uint8", lenght: 3 },
+				{ name: "id", type: "uint16" },
+				{ name: "Id", type: "uint16" },
+				{ name: "string", type: "uint8" },
+				{ name: "2nd", type: "uint8" },
+				{ type: "uint8" },
+				{ name: "next", type: "Pakcet" },
+				{ name: "list", type: "array", kind: "length_prefixed" },
+				{ name: "blob", type: "array", items: { type: "uint8" } },
+				{ name: "chunks", type: "array", kind: "sized", items: { type: "uint8" } },
+				{ name: "grid", type: "array", kind: "fixed", length: 1.5, items: { type: "array", kind: "fixed", items: { type: "uint8" } } },
+				{ name: "data", type: "array", kind: "field_referenced", length_field: "size", items: { type: "uint8" } },
+				{ name: "more", type: "array", kind: "field_referenced", length_field: "later", items: { type: "uint8" } },
+				{ name: "later", type: "uint8" },
+				"oops",
+			] },
+			"Nested": { sequence: [
+				{ name: "flags", type: "Flags" },
+				{ name: "bits", type: "bitfield", size: 8, fields: [ { name: "n", offset: 0, size: 8 } ] },
+				{ name: "header", type: "Header" },
+				{ name: "a", type: "string", kind: "field_referenced", length_field: "bits.n" },
+				{ name: "b", type: "string", kind: "field_referenced", length_field: "bits.m" },
+				{ name: "c", type: "string", kind: "field_referenced", length_field: "header.len" },
+				{ name: "d", type: "string", kind: "field_referenced", length_field: "header.size" },
+				{ name: "e", type: "string", kind: "field_referenced", length_field: "a.x" },
+				{ name: "f", type: "string", kind: "field_referenced", length_field: "_root.anything" },
+				{ name: "g", type: "string" },
+			] },
+		},
+	}`)
+
+	require.Equal(t, []string{
+		`error: types.Broken: type definition must be an object`,
+		`error: types.Nested.sequence[4]: length_field "bits.m": bits has no field "m"`,
+		`error: types.Nested.sequence[6]: length_field "header.size": header has no field "size"`,
+		`error: types.Nested.sequence[7]: length_field "a.x": a is a string, not a struct or bitfield`,
+		`error: types.Nested.sequence[9]: string has no kind`,
+		`warning: types.Packet.sequence[0]: unknown attribute "lenght"`,
+		`error: types.Packet.sequence[1]: duplicate field name "id" (also types.Packet.sequence[0])`,
+		`error: types.Packet.sequence[2]: fields "id" and "Id" both become Go field Id`,
+		`error: types.Packet.sequence[3]: field "string" becomes Go field String, which clashes with the generated String method`,
+		`error: types.Packet.sequence[4]: field name "2nd" is not a valid identifier`,
+		`error: types.Packet.sequence[5]: field has no name`,
+		`error: types.Packet.sequence[6]: unknown type "Pakcet"`,
+		`error: types.Packet.sequence[7]: array has no items`,
+		`warning: types.Packet.sequence[7]: length_prefixed array has no "length_type", defaulting to uint8`,
+		`error: types.Packet.sequence[8]: array has no kind`,
+		`error: types.Packet.sequence[9]: unknown array kind "sized" (want byte_length_prefixed, computed_count, eof_terminated, field_referenced, fixed, length_prefixed, length_prefixed_items, null_terminated, signature_terminated, variant_terminated)`,
+		`error: types.Packet.sequence[10]: fixed length must be a non-negative integer, got 1.5`,
+		`error: types.Packet.sequence[10].items: fixed array requires "length"`,
+		`error: types.Packet.sequence[11]: length_field "size": no field "size" before this one`,
+		`error: types.Packet.sequence[12]: length_field "later": later comes after this field (forward reference)`,
+		`error: types.Packet.sequence[14]: field must be an object`,
+		`error: types.header: types "Header" and "header" both become Go type Header`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+
+	require.Equal(t, []string{"error: types: schema has no types"}, diagnosticStrings(ValidateSchema(map[string]interface{}{})))
+}
+
+func TestValidateSchemaProtocolHeader(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Header": { sequence: [ { name: "body_len", type: "uint16" } ] },
+			"Body": { sequence: [ { name: "data", type: "array", kind: "field_referenced", length_field: "body_len", items: { type: "uint8" } } ] },
+			"Other": { sequence: [ { name: "data", type: "array", kind: "field_referenced", length_field: "body_len", items: { type: "uint8" } } ] },
+		},
+		protocol: { header: "Header", messages: [ { code: 1, name: "BODY", payload_type: "Body" } ] },
+	}`)
+
+	// Payload types see the header's fields; other types don't
+	require.Equal(t, []string{
+		`error: types.Other.sequence[0]: length_field "body_len": no field "body_len" before this one`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchemaInlineGroups(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Packet_Flags": { sequence: [] },
+			"Packet": { sequence: [
+				{ name: "flags", type: "bitfield", size: 8, fields: [ { name: "a", size: 4 }, { name: "b", size: 4 } ] },
+				{ name: "bits", type: "bitfield", size: 8, fields: [ { name: "a", size: 0 }, { size: 4 }, { name: "A", size: 4 } ] },
+				{ name: "body", type: "struct", fields: [ { name: "len", type: "uint8" }, { name: "x" } ] },
+				{ name: "empty", type: "struct" },
+				{ name: "data", type: "string", kind: "field_referenced", length_field: "body.len" },
+				{ name: "more", type: "string", kind: "field_referenced", length_field: "body.size" },
+			] },
+		},
+	}`)
+
+	require.Equal(t, []string{
+		`error: types.Packet.sequence[0]: inline bitfield "flags" becomes Go type Packet_Flags, which is already defined`,
+		`error: types.Packet.sequence[1].fields[0]: bitfield field size must be an integer from 1 to 64, got 0`,
+		`error: types.Packet.sequence[1].fields[1]: bitfield field has no name`,
+		`error: types.Packet.sequence[1].fields[2]: duplicate bitfield field name "A" (also types.Packet.sequence[1].fields[0])`,
+		`error: types.Packet.sequence[2].fields[1]: field has no type`,
+		`error: types.Packet.sequence[3]: struct has no fields`,
+		`error: types.Packet.sequence[5]: length_field "body.size": body has no field "size"`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchemaTerminalVariants(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"A": { sequence: [ { name: "x", type: "uint8" } ] },
+			"B": { sequence: [ { name: "y", type: "uint8" } ] },
+			"AB": { type: "discriminated_union", discriminator: { peek: "uint8" }, variants: [ { type: "A", when: "value == 1" }, { type: "B", when: "value == 2" } ] },
+			"List": { sequence: [
+				{ name: "ok", type: "array", kind: "null_terminated", items: { type: "AB" }, terminal_variants: ["B"] },
+				{ name: "kind", type: "array", kind: "fixed", length: 2, items: { type: "AB" }, terminal_variants: ["B"] },
+				{ name: "items", type: "array", kind: "variant_terminated", items: { type: "A" }, terminal_variants: ["A"] },
+				{ name: "typo", type: "array", kind: "variant_terminated", items: { type: "AB" }, terminal_variants: ["C"] },
+			] },
+		},
+	}`)
+
+	require.Equal(t, []string{
+		`error: types.List.sequence[1]: terminal_variants needs a null_terminated or variant_terminated array, not fixed`,
+		`error: types.List.sequence[2]: terminal_variants needs items that are a discriminated_union`,
+		`error: types.List.sequence[3]: terminal variant "C" is not a variant of the items (want A, B)`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchemaLengthExpressions(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Packet": { sequence: [
+				{ name: "rdlength", type: "uint16" },
+				{ name: "ok", type: "string", kind: "fixed", length: "rdlength - 2" },
+				{ name: "parent", type: "string", kind: "fixed", length: "../size * 2" },
+				{ name: "typo", type: "array", kind: "computed_count", count_expr: "rdlenght / 8", items: { type: "uint8" } },
+				{ name: "early", type: "array", kind: "fixed", length: "later + 1", items: { type: "uint8" } },
+				{ name: "broken", type: "array", kind: "computed_count", count_expr: "rdlength -", items: { type: "uint8" } },
+				{ name: "later", type: "uint8" },
+			] },
+		},
+	}`)
+
+	require.Equal(t, []string{
+		`error: types.Packet.sequence[3]: count_expr "rdlenght / 8": no field "rdlenght" before this one`,
+		`error: types.Packet.sequence[4]: length "later + 1": later comes after this field (forward reference)`,
+		`error: types.Packet.sequence[5]: count_expr "rdlength -": parse_error: unexpected end of expression`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchemaInstances(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: {
+			"Entry": { sequence: [{ name: "a", type: "uint8" }] },
+			"File": {
+				sequence: [
+					{ name: "name", type: "string", kind: "fixed", length: 4 },
+					{ name: "index_offset", type: "uint32" },
+				],
+				instances: [
+					{ name: "index", type: "Entry", position: "index_offset", size: "index.a" },
+					{ name: "trailer", type: "uint8", position: -1, alignment: 3 },
+					{ name: "label", type: "Entry", position: "name" },
+					{ name: "Name", type: "uint8", position: 0 },
+					{ name: "spare", type: "uint8" },
+					{ name: "rows", type: "array", items: { type: "Entry" }, offsets: "index_offset" },
+					{ name: "cells", type: "Entry", offsets: "cell_offsets", position: 0 },
+				],
+			},
+		},
+	}`)
+
+	require.Equal(t, []string{
+		`error: types.File.instances[0]: size "index.a": no field "index" before this one`,
+		`error: types.File.instances[1]: alignment must be a power of 2, got 3`,
+		`error: types.File.instances[2]: position "name": name is a string, not an integer`,
+		`error: types.File.instances[3]: instance "Name" and field "name" both become Go field Name`,
+		`error: types.File.instances[4]: instance has no position`,
+		`error: types.File.instances[5]: offsets "index_offset": index_offset is not an array of unsigned integers`,
+		`error: types.File.instances[6]: instance has both offsets and a position`,
+		`error: types.File.instances[6]: offsets locate the items of an array, but the instance is of type Entry`,
+		`error: types.File.instances[6]: offsets "cell_offsets": no field "cell_offsets" before this one`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchemaPositionOf(t *testing.T) {
+	schema := parseTestSchema(t, `{ types: { "File": { sequence: [
+		{ name: "data_offset", type: "uint32", computed: { type: "position_of", target: "data" } },
+		{ name: "bad_offset", type: "int16", computed: { type: "position_of", target: "data" } },
+		{ name: "data", type: "uint8" },
+	] } } }`)
+
+	require.Equal(t, []string{
+		`error: types.File.sequence[1]: position_of needs an unsigned integer type (uint8, uint16, uint32, uint64), got "int16"`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchemaDynamicEndianness(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { endianness: "dynamic" },
+		types: { "Header": { sequence: [
+			{ name: "order", type: "uint16", selects_endianness: { little_endian: 0x4949, big_endian: 0x4d4d } },
+			{ name: "signed", type: "int8", selects_endianness: { little_endian: 1, middle_endian: 2 } },
+			{ name: "same", type: "uint8", selects_endianness: { little_endian: 1, big_endian: 1 } },
+		] } },
+	}`)
+	require.Equal(t, []string{
+		`error: types.Header.sequence[1]: selects_endianness needs an unsigned integer type (uint8, uint16, uint32, uint64), got "int8"`,
+		`error: types.Header.sequence[1]: selects_endianness: unknown byte order "middle_endian"`,
+		`error: types.Header.sequence[2]: selects_endianness: big_endian and little_endian have the same value`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+
+	schema = parseTestSchema(t, `{
+		config: { endianness: "little" },
+		types: { "Header": { sequence: [
+			{ name: "order", type: "uint8", selects_endianness: { big_endian: 0 } },
+		] } },
+	}`)
+	require.Equal(t, []string{
+		`error: config: endianness must be "big_endian", "little_endian" or "dynamic", got little`,
+		`error: types.Header.sequence[0]: selects_endianness needs config endianness "dynamic"`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchemaBitOrder(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { bit_order: "lsb" },
+		types: {
+			"Ok": { bit_order: "msb_first", sequence: [{ name: "a", type: "bit", size: 1 }] },
+			"Bad": { bit_order: "little", sequence: [{ name: "a", type: "bit", size: 1 }] },
+		},
+	}`)
+	require.Equal(t, []string{
+		`error: config: bit_order must be "msb_first" or "lsb_first", got lsb`,
+		`error: types.Bad: bit_order must be "msb_first" or "lsb_first", got little`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchemaStringPadding(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: { "Msg": { sequence: [
+			{ name: "a", type: "string", kind: "fixed", length: 4, padding: "space" },
+			{ name: "b", type: "string", kind: "fixed", length: 4, padding: "zero" },
+			{ name: "c", type: "string", kind: "null_terminated", padding: "none" },
+		] } },
+	}`)
+	require.Equal(t, []string{
+		`error: types.Msg.sequence[1]: padding must be "null", "space" or "none", got zero`,
+		`error: types.Msg.sequence[2]: padding only applies to fixed-length strings`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchemaMaxLength(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		types: { "Msg": { sequence: [
+			{ name: "a", type: "string", kind: "null_terminated", max_length: 255 },
+			{ name: "b", type: "string", kind: "null_terminated", max_length: 0 },
+			{ name: "c", type: "string", kind: "length_prefixed", length_type: "uint8", max_length: 8 },
+		] } },
+	}`)
+	require.Equal(t, []string{
+		`error: types.Msg.sequence[1]: max_length must be a positive integer, got 0`,
+		`error: types.Msg.sequence[2]: max_length only applies to null-terminated strings`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchemaInvalidUTF8(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { invalid_utf8: "drop" },
+		types: { "Msg": { sequence: [{ name: "text", type: "string", kind: "null_terminated" }] } },
+	}`)
+	require.Equal(t, []string{
+		`error: config: invalid_utf8 must be "keep", "reject" or "replace", got drop`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchemaVersionConfig(t *testing.T) {
+	schema := parseTestSchema(t, `{
+		config: { version: { field: "version", value: -1 }, magic: { value: 7 } },
+		types: { "Msg": { sequence: [{ name: "version", type: "uint8" }] } },
+	}`)
+	require.Equal(t, []string{
+		`error: config: version must be { field: "<field path>", value: <unsigned integer> }`,
+		`error: config: magic must be { field: "<field path>", value: <unsigned integer> }`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+
+	schema = parseTestSchema(t, `{
+		config: { version: { field: "header.version", value: 2 }, magic: { field: "magic", value: 0x4253 } },
+		types: { "Msg": { sequence: [{ name: "version", type: "uint8" }] } },
+	}`)
+	require.Empty(t, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchemaIntSize(t *testing.T) {
+	schema := parseTestSchema(t, `{ types: { "Sample": { sequence: [
+		{ name: "x", type: "int", size: 12, signed: true },
+		{ name: "y", type: "int", size: 65 },
+		{ name: "z", type: "bit" },
+	] } } }`)
+	require.Equal(t, []string{
+		"error: types.Sample.sequence[1]: int needs a size of 1 to 64 bits, got 65",
+		"error: types.Sample.sequence[2]: bit needs a size of 1 to 64 bits, got <nil>",
+	}, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchema128BitLength(t *testing.T) {
+	schema := parseTestSchema(t, `{ types: { "Packet": { sequence: [
+		{ name: "size", type: "uint128" },
+		{ name: "data", type: "array", kind: "field_referenced", length_field: "size", items: { type: "uint8" } },
+	] } } }`)
+	require.Empty(t, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchemaTimestamps(t *testing.T) {
+	schema := parseTestSchema(t, `{ types: { "Reading": { sequence: [
+		{ name: "a", type: "unix64", unit: "ms", epoch: "2000-01-01T00:00:00Z" },
+		{ name: "b", type: "unix32", unit: "minutes" },
+		{ name: "c", type: "unix_milli", epoch: "2000-01-01" },
+		{ name: "d", type: "ntp", unit: "s" },
+	] } } }`)
+	require.Equal(t, []string{
+		"error: types.Reading.sequence[1]: unit must be s, ms, us or ns, got minutes",
+		"error: types.Reading.sequence[2]: epoch must be an RFC 3339 time, got 2000-01-01",
+		"error: types.Reading.sequence[3]: ntp timestamps have a fixed unit and epoch",
+	}, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchemaFlags(t *testing.T) {
+	schema := parseTestSchema(t, `{ types: {
+		"Bad": { type: "flags", repr: "int8", variants: { a: 1 } },
+		"Empty": { type: "flags", repr: "uint8", variants: {} },
+		"Packet": { sequence: [
+			{ name: "a", type: "flags", repr: "uint8", variants: { "two words": 1, zero: 0, wide: 256, half: 0.5 } },
+			{ name: "b", type: "Bad" },
+		] },
+	} }`)
+	require.Equal(t, []string{
+		"error: types.Bad: flags need a repr of uint8, uint16, uint32 or uint64, got int8",
+		"error: types.Empty: flags need a non-empty variants map of flag name to bits",
+		`error: types.Packet.sequence[0].variants: flag "half" must be nonzero bits that fit in a uint8, got 0.5`,
+		`error: types.Packet.sequence[0].variants: flag name "two words" is not an identifier`,
+		`error: types.Packet.sequence[0].variants: flag "wide" must be nonzero bits that fit in a uint8, got 256`,
+		`error: types.Packet.sequence[0].variants: flag "zero" must be nonzero bits that fit in a uint8, got 0`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchemaBCD(t *testing.T) {
+	schema := parseTestSchema(t, `{ types: { "Charge": { sequence: [
+		{ name: "a", type: "packed_bcd", digits: 20, decode_as: "string" },
+		{ name: "b", type: "bcd" },
+		{ name: "c", type: "packed_bcd", digits: 19 },
+		{ name: "d", type: "bcd", digits: 2, decode_as: "float" },
+		{ name: "items", type: "array", kind: "field_referenced", length_field: "a", items: { type: "uint8" } },
+	] } } }`)
+	require.Equal(t, []string{
+		"error: types.Charge.sequence[1]: bcd needs a positive digits count, got <nil>",
+		`error: types.Charge.sequence[2]: 19 digits don't fit in an int64; use decode_as "string"`,
+		`error: types.Charge.sequence[3]: decode_as must be "int" or "string", got float`,
+		`error: types.Charge.sequence[4]: length_field "a": a is a packed_bcd, not an integer`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestValidateSchemaProtobuf(t *testing.T) {
+	schema := parseTestSchema(t, `{ types: {
+		"Message": { protobuf: true, sequence: [
+			{ name: "a", type: "int32", field_number: 1, proto_type: "sint32" },
+			{ name: "b", type: "string", field_number: 1 },
+			{ name: "c", type: "uint64", field_number: 19001, proto_type: "fixed32" },
+			{ name: "d", type: "array", items: { type: "int8" }, proto_type: "fixed32" },
+			{ name: "e", type: "ipv4", field_number: 5, conditional: "a == 1" },
+			{ name: "f", type: "array", items: { type: "Message" }, field_number: 6 },
+		] },
+		"Frame": { sequence: [
+			{ name: "a", type: "Message", kind: "length_prefixed", length_type: "uint16" },
+			{ name: "b", type: "Message", kind: "fixed" },
+			{ name: "c", type: "array", kind: "fixed", length: 2, items: { type: "Message" } },
+		] },
+	} }`)
+	require.Equal(t, []string{
+		"error: types.Frame.sequence[1]: protobuf message fields need kind length_prefixed or field_referenced, got fixed",
+		"error: types.Frame.sequence[2]: arrays of protobuf messages are only supported in protobuf messages",
+		"error: types.Message.sequence[1]: duplicate field_number 1 (also types.Message.sequence[0])",
+		"error: types.Message.sequence[2]: field_number 19001 is reserved by protobuf",
+		"error: types.Message.sequence[2]: proto_type fixed32 can't hold a uint64",
+		"error: types.Message.sequence[3]: protobuf fields need a field_number from 1 to 536870911, got <nil>",
+		"error: types.Message.sequence[3]: proto_type fixed32 doesn't match the signedness of int8",
+		`error: types.Message.sequence[4]: protobuf fields can't have "conditional"`,
+		`error: types.Message.sequence[4]: type "ipv4" can't be a protobuf field`,
+	}, diagnosticStrings(ValidateSchema(schema)))
+}
+
+func TestGenerateRefusesInvalidSchema(t *testing.T) {
+	schema := parseTestSchema(t, `{ types: { "Packet": { sequence: [
+		{ name: "items", type: "array", kind: "fixed", length: 2 },
+		{ name: "next", type: "Missing" },
+	] } } }`)
+
+	_, err := GenerateGo(schema, "Packet")
+	require.EqualError(t, err, `invalid schema: types.Packet.sequence[0]: array has no items; types.Packet.sequence[1]: unknown type "Missing"`)
+
+	_, err = GenerateWireshark(schema, "Packet", WiresharkOptions{})
+	require.EqualError(t, err, `invalid schema: types.Packet.sequence[0]: array has no items; types.Packet.sequence[1]: unknown type "Missing"`)
+}
+
+// The published example schemas must validate without errors
+func TestValidateExampleSchemas(t *testing.T) {
+	files, err := filepath.Glob("../../examples/*.schema.json")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		var schema map[string]interface{}
+		require.NoError(t, json5.Unmarshal(data, &schema), file)
+		require.Empty(t, diagnosticStrings(ValidateSchema(schema)), file)
+	}
+}
@@ -0,0 +1,137 @@
+// ABOUTME: Typed helpers for discriminated unions: FieldAsVariant accessors, Match and Visit
+// ABOUTME: Match and visitors take every variant, so a variant added to the schema breaks callers until handled
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// variantNames returns the names helpers use for a union's variants: their type names
+// without the words all of them share at the start or end (ARdata, NSRdata -> A, NS)
+func variantNames(typeDef *TypeDef) []string {
+	names := make([]string, len(typeDef.Variants))
+	for i, variant := range typeDef.Variants {
+		names[i] = capitalizeFirst(variant.schemaName())
+	}
+	if len(names) < 2 {
+		return names
+	}
+
+	prefix, suffix := names[0], names[0]
+	for _, name := range names[1:] {
+		for !strings.HasPrefix(name, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+		for !strings.HasSuffix(name, suffix) {
+			suffix = suffix[1:]
+		}
+	}
+	// Only whole words are dropped: the suffix must start one, and what follows the
+	// prefix must start one in every name
+	for suffix != "" && !unicode.IsUpper(rune(suffix[0])) && suffix[0] != '_' {
+		suffix = suffix[1:]
+	}
+	for prefix != "" && !wordsFollow(names, prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+
+	short := make([]string, len(names))
+	for i, name := range names {
+		if len(prefix)+len(suffix) >= len(name) {
+			return names
+		}
+		short[i] = strings.Trim(name[len(prefix):len(name)-len(suffix)], "_")
+		if short[i] == "" || !unicode.IsUpper(rune(short[i][0])) {
+			return names
+		}
+	}
+	return short
+}
+
+// wordsFollow reports whether a word starts right after prefix in every name
+func wordsFollow(names []string, prefix string) bool {
+	for _, name := range names {
+		if len(name) == len(prefix) {
+			return false
+		}
+		if next := name[len(prefix)]; !unicode.IsUpper(rune(next)) && next != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// generateUnionMatch emits MatchX, calling a function per variant, and XVisitor with
+// VisitX, the same with methods that can fail
+func generateUnionMatch(buf *bytes.Buffer, name string, typeDef *TypeDef) {
+	names := variantNames(typeDef)
+	var params []string
+	for i, variant := range typeDef.Variants {
+		params = append(params, fmt.Sprintf("on%s func(*%s)", names[i], capitalizeFirst(variant.Type)))
+	}
+
+	buf.WriteString(fmt.Sprintf("// Match%s calls the function for the variant v holds; nil functions, and a nil v,\n", name))
+	buf.WriteString("// are skipped. Every variant has a parameter, so adding one breaks callers until they handle it.\n")
+	buf.WriteString(fmt.Sprintf("func Match%s(v %s, %s) {\n", name, name, strings.Join(params, ", ")))
+	buf.WriteString("\tswitch v := v.(type) {\n")
+	for i, variant := range typeDef.Variants {
+		buf.WriteString(fmt.Sprintf("\tcase *%s:\n", capitalizeFirst(variant.Type)))
+		buf.WriteString(fmt.Sprintf("\t\tif on%s != nil {\n", names[i]))
+		buf.WriteString(fmt.Sprintf("\t\t\ton%s(v)\n", names[i]))
+		buf.WriteString("\t\t}\n")
+	}
+	buf.WriteString("\t}\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("// %sVisitor has a method for each variant of %s, called by Visit%s\n", name, name, name))
+	buf.WriteString(fmt.Sprintf("type %sVisitor interface {\n", name))
+	for i, variant := range typeDef.Variants {
+		buf.WriteString(fmt.Sprintf("\tVisit%s(v *%s) error\n", names[i], capitalizeFirst(variant.Type)))
+	}
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("// Visit%s calls the visitor's method for the variant v holds; a nil v is an error\n", name))
+	buf.WriteString(fmt.Sprintf("func Visit%s(v %s, visitor %sVisitor) error {\n", name, name, name))
+	buf.WriteString("\tswitch v := v.(type) {\n")
+	for i, variant := range typeDef.Variants {
+		buf.WriteString(fmt.Sprintf("\tcase *%s:\n", capitalizeFirst(variant.Type)))
+		buf.WriteString(fmt.Sprintf("\t\treturn visitor.Visit%s(v)\n", names[i]))
+	}
+	buf.WriteString("\t}\n")
+	buf.WriteString(fmt.Sprintf("\treturn fmt.Errorf(\"%s: no variant set\")\n", name))
+	buf.WriteString("}\n\n")
+}
+
+// generateVariantAccessors emits, for each union field of a struct, an accessor per
+// variant (RdataAsA) and MatchRdata, which passes the field to MatchX
+func generateVariantAccessors(buf *bytes.Buffer, schema *Schema, name string, typeDef *TypeDef) {
+	for _, field := range typeDef.allFields() {
+		if !field.Union {
+			continue
+		}
+		union := schema.Types[field.Type]
+		names := variantNames(union)
+		fieldName := field.goName()
+		recv := typeDef.receiver()
+
+		var params, args []string
+		for i, variant := range union.Variants {
+			variantType := capitalizeFirst(variant.Type)
+			buf.WriteString(fmt.Sprintf("// %sAs%s returns %s as a *%s, and whether it holds one\n", fieldName, names[i], fieldName, variantType))
+			buf.WriteString(fmt.Sprintf("func (%s *%s) %sAs%s() (*%s, bool) {\n", recv, name, fieldName, names[i], variantType))
+			buf.WriteString(fmt.Sprintf("\tv, ok := %s.%s.(*%s)\n", recv, fieldName, variantType))
+			buf.WriteString("\treturn v, ok\n")
+			buf.WriteString("}\n\n")
+			params = append(params, fmt.Sprintf("on%s func(*%s)", names[i], variantType))
+			args = append(args, "on"+names[i])
+		}
+
+		buf.WriteString(fmt.Sprintf("// Match%s calls the function for the variant %s holds, as Match%s does\n", fieldName, fieldName, capitalizeFirst(field.Type)))
+		buf.WriteString(fmt.Sprintf("func (%s *%s) Match%s(%s) {\n", recv, name, fieldName, strings.Join(params, ", ")))
+		buf.WriteString(fmt.Sprintf("\tMatch%s(%s.%s, %s)\n", capitalizeFirst(field.Type), recv, fieldName, strings.Join(args, ", ")))
+		buf.WriteString("}\n\n")
+	}
+}
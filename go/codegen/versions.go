@@ -0,0 +1,182 @@
+// ABOUTME: GenerateVersionDispatch: DecodeAnyX, decoding a message with the version of its schema it was encoded with
+// ABOUTME: Versions are told apart by the magic and version fields their configs declare, read with ExtractXField
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+// SchemaVersion is one version of a schema given to GenerateVersionDispatch. Its code is
+// generated into the same package with Options, whose TypePrefix or TypeSuffix keeps the
+// types of the versions apart.
+type SchemaVersion struct {
+	Schema  map[string]interface{}
+	Options GenerateOptions
+}
+
+// parseVersionField parses a config version or magic, { field: "version", value: 2 },
+// returning nil if raw isn't one
+func parseVersionField(raw interface{}) *VersionField {
+	data, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	field, _ := data["field"].(string)
+	value, ok := data["value"].(float64)
+	if field == "" || !ok || value < 0 || value != float64(uint64(value)) {
+		return nil
+	}
+	return &VersionField{Field: field, Value: uint64(value)}
+}
+
+// versionCheck is a field DecodeAnyX compares to its value in one version
+type versionCheck struct {
+	path   string
+	goType string
+	value  uint64
+	magic  bool
+}
+
+// GenerateVersionDispatch generates DecodeAnyX for the versions of a schema, each
+// generated into the same package by GenerateGoWithOptions with its own options, which
+// must include ExtractFields. The
+// config of each version declares the version its messages are, and optionally the
+// magic they start with, as fields of typeName: { version: { field: "version", value:
+// 2 }, magic: { field: "magic", value: 0x4253 } }. DecodeAnyX reads those fields of a
+// message with each version's ExtractXField, in the order the versions are given, and
+// decodes it with the first version whose fields hold its values. It returns the
+// message as an XMessage, the interface every version's X implements, and the version.
+func GenerateVersionDispatch(versions []SchemaVersion, typeName string) (string, error) {
+	if len(versions) == 0 {
+		return "", fmt.Errorf("no schema versions")
+	}
+	name := capitalizeFirst(typeName)
+	type detected struct {
+		goType string
+		checks []versionCheck
+		value  uint64
+	}
+	var found []detected
+	encodes := true
+	seen := make(map[string]int)
+	for i, version := range versions {
+		if !version.Options.Direction.decodes() {
+			return "", fmt.Errorf("version %d of %s: dispatching needs decoders", i+1, typeName)
+		}
+		if !version.Options.ExtractFields {
+			return "", fmt.Errorf("version %d of %s: dispatching needs ExtractFields", i+1, typeName)
+		}
+		encodes = encodes && version.Options.Direction.encodes()
+		schema, goType, _, err := prepareSchema(version.Schema, typeName, version.Options)
+		if err != nil {
+			return "", fmt.Errorf("version %d of %s: %w", i+1, typeName, err)
+		}
+		if !extractable(schema.Types[goType]) {
+			return "", fmt.Errorf("version %d of %s: %s is not a struct", i+1, typeName, goType)
+		}
+		if schema.Config == nil || schema.Config.Version == nil {
+			return "", fmt.Errorf("version %d of %s: the schema config has no version", i+1, typeName)
+		}
+		d := detected{goType: goType, value: schema.Config.Version.Value}
+		for _, vf := range []*VersionField{schema.Config.Magic, schema.Config.Version} {
+			if vf == nil {
+				continue
+			}
+			fieldType, err := versionFieldType(schema, goType, vf)
+			if err != nil {
+				return "", fmt.Errorf("version %d of %s: %w", i+1, typeName, err)
+			}
+			d.checks = append(d.checks, versionCheck{path: vf.Field, goType: fieldType, value: vf.Value, magic: vf == schema.Config.Magic})
+		}
+		var key string
+		for _, check := range d.checks {
+			key += fmt.Sprintf("%s=%d ", check.path, check.value)
+		}
+		if j, dup := seen[key]; dup {
+			return "", fmt.Errorf("versions %d and %d of %s have the same magic and version", j+1, i+1, typeName)
+		}
+		seen[key] = i
+		found = append(found, d)
+	}
+
+	var buf bytes.Buffer
+	var goTypes []string
+	for _, d := range found {
+		goTypes = append(goTypes, d.goType)
+	}
+	buf.WriteString(fmt.Sprintf("// %sMessage is a %s of any version DecodeAny%s detects: %s\n", name, name, name, strings.Join(goTypes, ", ")))
+	buf.WriteString(fmt.Sprintf("type %sMessage interface {\n", name))
+	if encodes {
+		buf.WriteString("\tEncode() ([]byte, error)\n")
+	}
+	buf.WriteString("\tString() string\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("// DecodeAny%s decodes a %s with the version whose magic and version fields hold\n", name, name))
+	buf.WriteString("// that version's values, trying them in the order they were generated in, and returns\n")
+	buf.WriteString("// the version it was decoded as\n")
+	buf.WriteString(fmt.Sprintf("func DecodeAny%s(bytes []byte) (%sMessage, uint64, error) {\n", name, name))
+	buf.WriteString("\t// is reports whether the field at path holds value, read with extract\n")
+	buf.WriteString("\tis := func(extract func([]byte, string) (interface{}, error), path string, value interface{}) bool {\n")
+	buf.WriteString("\t\tfield, err := extract(bytes, path)\n")
+	buf.WriteString("\t\treturn err == nil && field == value\n")
+	buf.WriteString("\t}\n")
+	for _, d := range found {
+		var conditions []string
+		for _, check := range d.checks {
+			value := fmt.Sprintf("%d", check.value)
+			if check.magic {
+				value = fmt.Sprintf("%#x", check.value)
+			}
+			conditions = append(conditions, fmt.Sprintf("is(Extract%sField, %q, %s(%s))", d.goType, check.path, check.goType, value))
+		}
+		buf.WriteString(fmt.Sprintf("\tif %s {\n", strings.Join(conditions, " && ")))
+		buf.WriteString(fmt.Sprintf("\t\tm, err := Decode%s(bytes)\n", d.goType))
+		buf.WriteString("\t\tif err != nil {\n")
+		buf.WriteString(fmt.Sprintf("\t\t\treturn nil, %d, err\n", d.value))
+		buf.WriteString("\t\t}\n")
+		buf.WriteString(fmt.Sprintf("\t\treturn m, %d, nil\n", d.value))
+		buf.WriteString("\t}\n")
+	}
+	buf.WriteString(fmt.Sprintf("\treturn nil, 0, fmt.Errorf(\"%s: no version's magic and version fields match\")\n", name))
+	buf.WriteString("}\n")
+
+	var out bytes.Buffer
+	out.WriteString(fmt.Sprintf("// Code generated by binschema %s. DO NOT EDIT.\n\n", GeneratorVersion))
+	out.WriteString("package main\n\n")
+	out.WriteString("import \"fmt\"\n\n")
+	out.Write(buf.Bytes())
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("generated dispatch does not parse: %w", err)
+	}
+	return string(formatted), nil
+}
+
+// versionFieldType returns the Go type of the integer field at a version field's path
+// through the nested structs of typeName
+func versionFieldType(schema *Schema, typeName string, vf *VersionField) (string, error) {
+	typeDef := schema.Types[typeName]
+	segments := strings.Split(vf.Field, ".")
+	for i, segment := range segments {
+		field, ok := findSequenceField(typeDef, segment)
+		if !ok || field.Conditional != "" {
+			return "", fmt.Errorf("%s has no unconditional field %q", typeName, vf.Field)
+		}
+		if i < len(segments)-1 {
+			if typeDef = schema.Types[field.Type]; !extractable(typeDef) {
+				return "", fmt.Errorf("%q: %s is not a struct", vf.Field, segment)
+			}
+			continue
+		}
+		goType, err := mapTypeToGo(field)
+		if err != nil || !lengthGoTypes[goType] || field.FlagsRepr != "" {
+			return "", fmt.Errorf("%q is not an integer field", vf.Field)
+		}
+		return goType, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,69 @@
+// ABOUTME: uint128/int128 length, count and offset fields, as archival formats with 128-bit offsets use
+// ABOUTME: Expressions reading them convert with a check, failing cleanly past the platform's int range
+package codegen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Integer types too wide to convert to int64 directly
+var wideIntegers = map[string]bool{"uint128": true, "int128": true}
+
+// markWideRefs sets WideRefs on every field whose length, count, position or size
+// expression reads a uint128 or int128 field of its own struct
+func markWideRefs(schema *Schema) {
+	for _, typeDef := range schema.Types {
+		for _, field := range typeDef.fieldPointers() {
+			for _, f := range []*Field{field, field.Items} {
+				if f == nil {
+					continue
+				}
+				for _, ref := range expressionFields(*f) {
+					if strings.HasPrefix(ref, "../") || !wideIntegers[pathType(schema, typeDef, ref)] {
+						continue
+					}
+					if f.WideRefs == nil {
+						f.WideRefs = make(map[string]bool)
+					}
+					f.WideRefs[ref] = true
+				}
+			}
+		}
+	}
+}
+
+// pathType returns the schema type of the field a dotted path names, through nested
+// structs, or "" if it names none
+func pathType(schema *Schema, typeDef *TypeDef, path string) string {
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		found := false
+		for _, field := range typeDef.allFields() {
+			if field.Name != segment {
+				continue
+			}
+			if i == len(segments)-1 {
+				return field.Type
+			}
+			found = true
+			typeDef = schema.Types[field.Type]
+			break
+		}
+		if !found || typeDef == nil {
+			return ""
+		}
+	}
+	return ""
+}
+
+// wide converts a uint128 or int128 field to an int64 through its Int method, which
+// fails when the value is past what an int holds on the platform decoding it
+func (c *lengthCompiler) wide(goPath, path string) string {
+	wideVar := c.temp("wide")
+	c.buf.WriteString(fmt.Sprintf("%s%s, err := %s.Int()\n", c.indent, wideVar, goPath))
+	c.buf.WriteString(fmt.Sprintf("%sif err != nil {\n", c.indent))
+	c.buf.WriteString(fmt.Sprintf("%s\treturn nil, fmt.Errorf(\"%s: %s %s: %%w\", err)\n", c.indent, c.name, c.what, path))
+	c.buf.WriteString(fmt.Sprintf("%s}\n", c.indent))
+	return fmt.Sprintf("int64(%s)", wideVar)
+}
@@ -0,0 +1,1441 @@
+// ABOUTME: Generates a Wireshark Lua dissector from a schema, a sibling backend of GenerateGo
+// ABOUTME: Maps fields, bitfields, enums and unions to ProtoFields so packets can be inspected in Wireshark
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/serialexp/binschema/expression"
+)
+
+// WiresharkOptions configures the generated dissector
+type WiresharkOptions struct {
+	// Name is the protocol's display filter name, e.g. "sensornet".
+	// Defaults to the root type name in lower case.
+	Name string
+	// Description is the protocol's display name. Defaults to the schema's
+	// meta title, then protocol name, then the root type name.
+	Description string
+	// Transport is "udp" (default) or "tcp"
+	Transport string
+	// Port registers the dissector on a Transport port. With 0 the dissector
+	// is only offered through "Decode As".
+	Port int
+}
+
+// GenerateWireshark generates a Wireshark Lua dissector that decodes packets as typeName.
+//
+// Every decoded value gets a ProtoField, so it can be used in display filters
+// (sensornet.packet.msg_type == 1). Structs, arrays and unions become subtrees,
+// enums are shown with their variant names, and bitfields declared as a "bitfield"
+// of 8, 16, 24 or 32 bits are shown with masks. Each packet (or TCP segment) is
+// decoded as one message; multi-byte values are expected to be byte-aligned.
+func GenerateWireshark(schemaData map[string]interface{}, typeName string, opts WiresharkOptions) (string, error) {
+	if err := schemaError(ValidateSchema(schemaData)); err != nil {
+		return "", err
+	}
+	types := schemaData["types"].(map[string]interface{})
+	g := &luaGen{
+		types:      types,
+		endianness: "big_endian",
+		bitOrder:   "msb_first",
+		abbrs:      make(map[string]string),
+		queued:     make(map[string]bool),
+		generics:   make(map[string]map[string]interface{}),
+	}
+	if config, ok := schemaData["config"].(map[string]interface{}); ok {
+		if e, _ := config["endianness"].(string); e != "" {
+			g.endianness = e
+		}
+		if o, _ := config["bit_order"].(string); o != "" {
+			g.bitOrder = o
+		}
+	}
+	if _, ok := g.typeDef(typeName); !ok {
+		return "", fmt.Errorf("type %s not found in schema", typeName)
+	}
+
+	g.proto = opts.Name
+	if g.proto == "" {
+		g.proto = luaAbbr(typeName)
+	}
+	description := opts.Description
+	if description == "" {
+		description = schemaTitle(schemaData, typeName)
+	}
+	transport := opts.Transport
+	if transport == "" {
+		transport = "udp"
+	}
+	if transport != "udp" && transport != "tcp" {
+		return "", fmt.Errorf("unsupported transport %q (want udp or tcp)", transport)
+	}
+
+	g.require(typeName)
+	for len(g.pending) > 0 {
+		name := g.pending[0]
+		g.pending = g.pending[1:]
+		if err := g.dissectFunction(name); err != nil {
+			return "", fmt.Errorf("type %s: %w", name, err)
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString(fmt.Sprintf("-- Wireshark dissector for %s\n", description))
+	out.WriteString(fmt.Sprintf("-- Load with: wireshark -X lua_script:%s.lua (or copy into the plugins directory)\n", g.proto))
+	out.WriteString("-- Code generated by binschema. DO NOT EDIT.\n\n")
+	out.WriteString(fmt.Sprintf("local proto = Proto(%s, %s)\n\n", luaString(g.proto), luaString(description)))
+	for _, table := range g.valueStrings {
+		out.WriteString(table)
+	}
+	if len(g.valueStrings) > 0 {
+		out.WriteString("\n")
+	}
+	out.WriteString("local f = {}\n")
+	for _, decl := range g.fields {
+		out.WriteString(decl)
+	}
+	out.WriteString("\nlocal fields = {}\n")
+	out.WriteString("for _, field in pairs(f) do\n")
+	out.WriteString("\tfields[#fields + 1] = field\n")
+	out.WriteString("end\n")
+	out.WriteString("proto.fields = fields\n\n")
+	out.WriteString(luaHelpers)
+	out.WriteString("\nlocal dissect = {}\n\n")
+	out.Write(g.body.Bytes())
+
+	out.WriteString("function proto.dissector(buf, pinfo, tree)\n")
+	out.WriteString("\tpinfo.cols.protocol = proto.name\n")
+	out.WriteString("\tdepth = 0\n")
+	out.WriteString("\tlocal root = tree:add(proto, buf())\n")
+	out.WriteString(fmt.Sprintf("\tlocal pos = dissect[%s](buf, 0, root, nil)\n", luaString(typeName)))
+	out.WriteString("\treturn math.ceil(pos / 8)\n")
+	out.WriteString("end\n\n")
+	if opts.Port > 0 {
+		out.WriteString(fmt.Sprintf("DissectorTable.get(\"%s.port\"):add(%d, proto)\n", transport, opts.Port))
+	} else {
+		out.WriteString(fmt.Sprintf("DissectorTable.get(\"%s.port\"):add_for_decode_as(proto)\n", transport))
+	}
+	return out.String(), nil
+}
+
+// luaHelpers is the runtime shared by every generated dissector. Positions are in bits.
+const luaHelpers = `local MAX_DEPTH = 128
+local depth = 0
+
+local function enter()
+	depth = depth + 1
+	if depth > MAX_DEPTH then
+		error("nesting too deep")
+	end
+end
+
+local function leave()
+	depth = depth - 1
+end
+
+local function at(buf, pos, len)
+	return buf(math.floor(pos / 8), len)
+end
+
+local function finish(item, start, pos)
+	item:set_len(math.ceil(pos / 8) - math.floor(start / 8))
+end
+
+local function tree_add(tree, field, r, le)
+	if le then
+		return tree:add_le(field, r)
+	end
+	return tree:add(field, r)
+end
+
+local function truthy(x)
+	return x ~= nil and x ~= false and x ~= 0 and x ~= ""
+end
+
+local function idiv(a, b)
+	local q = a / b
+	if q < 0 then
+		return math.ceil(q)
+	end
+	return math.floor(q)
+end
+
+local function read_uint(buf, pos, len, le)
+	local r = at(buf, pos, len)
+	if len > 4 then
+		return (le and r:le_uint64() or r:uint64()):tonumber()
+	end
+	return le and r:le_uint() or r:uint()
+end
+
+local function read_bits(buf, pos, size, lsb_first)
+	local value = 0
+	for i = 0, size - 1 do
+		local p = pos + i
+		local byte = buf(math.floor(p / 8), 1):uint()
+		if lsb_first then
+			value = value + (math.floor(byte / 2 ^ (p % 8)) % 2) * 2 ^ i
+		else
+			value = value * 2 + math.floor(byte / 2 ^ (7 - p % 8)) % 2
+		end
+	end
+	return value
+end
+
+local function bits_range(buf, pos, size)
+	local first = math.floor(pos / 8)
+	return buf(first, math.ceil((pos + size) / 8) - first)
+end
+
+local function sign_extend(value, size)
+	if value >= 2 ^ (size - 1) then
+		return value - 2 ^ size
+	end
+	return value
+end
+
+local function read_varlength(buf, pos, encoding)
+	local first = at(buf, pos, 1):uint()
+	if encoding == "der" then
+		if first < 0x80 then
+			return first, 1
+		end
+		local n = first - 0x80
+		local value = 0
+		for i = 1, n do
+			value = value * 256 + at(buf, pos + i * 8, 1):uint()
+		end
+		return value, n + 1
+	elseif encoding == "ebml" then
+		local width, marker = 1, 0x80
+		while width <= 8 and first < marker do
+			width, marker = width + 1, marker / 2
+		end
+		local value = first - marker
+		for i = 1, width - 1 do
+			value = value * 256 + at(buf, pos + i * 8, 1):uint()
+		end
+		return value, width
+	end
+	local value, scale, n = 0, 1, 0
+	repeat
+		local byte = at(buf, pos + n * 8, 1):uint()
+		n = n + 1
+		if encoding == "leb128" then
+			value = value + (byte % 0x80) * scale
+			scale = scale * 0x80
+		else
+			value = value * 0x80 + byte % 0x80
+		end
+	until byte < 0x80
+	return value, n
+end
+
+local function find_null(buf, pos, width, limit)
+	local n = 0
+	while (limit == nil or n < limit) and read_uint(buf, pos + n * 8, width, false) ~= 0 do
+		n = n + width
+	end
+	return n
+end
+
+local function parent_of(v)
+	local mt = getmetatable(v)
+	return mt and mt.__index
+end
+
+-- resolve looks up a field path ("header.flags", "items.0", "_root.id", "../len")
+-- in the current struct, falling back to enclosing structs
+local function resolve(v, path)
+	if path:sub(1, 6) == "_root." then
+		while parent_of(v) ~= nil do
+			v = parent_of(v)
+		end
+		path = path:sub(7)
+	end
+	while path:sub(1, 3) == "../" do
+		v = parent_of(v)
+		path = path:sub(4)
+	end
+	local value, first = v, true
+	for part in path:gmatch("[^.]+") do
+		if type(value) ~= "table" then
+			return nil
+		end
+		if rawget(value, part) == nil and rawget(value, "type") ~= nil and type(rawget(value, "value")) == "table" then
+			value = value.value
+		end
+		local index = tonumber(part)
+		if index ~= nil then
+			value = value[index + 1]
+		elseif first then
+			value = value[part]
+		else
+			value = rawget(value, part)
+		end
+		first = false
+	end
+	return value
+end
+
+local function is_terminal(item, variants)
+	if type(item) ~= "table" then
+		return false
+	end
+	for _, variant in ipairs(variants) do
+		if item.type == variant then
+			return true
+		end
+	end
+	return false
+end
+`
+
+type luaGen struct {
+	types      map[string]interface{}
+	generics   map[string]map[string]interface{}
+	proto      string
+	endianness string
+	bitOrder   string
+
+	fields       []string          // ProtoField declarations
+	abbrs        map[string]string // Declared abbreviation -> declaration
+	valueStrings []string          // Enum value_string tables
+	pending      []string          // Types whose dissect functions are still to be generated
+	queued       map[string]bool
+	body         bytes.Buffer
+	next         int
+}
+
+// luaElement is the context an element is decoded in
+type luaElement struct {
+	typeName string // Enclosing schema type, the first part of field abbreviations
+	path     string // Field path within the type ("flags", "items.item")
+	label    string // Display name
+	desc     string
+	target   string // Lua lvalue receiving the decoded value
+	tree     string // Lua tree item to add to
+	indent   string
+}
+
+func (e luaElement) line(g *luaGen, format string, args ...interface{}) {
+	g.body.WriteString(e.indent)
+	g.body.WriteString(fmt.Sprintf(format, args...))
+	g.body.WriteString("\n")
+}
+
+func (e luaElement) nested() luaElement {
+	e.indent += "\t"
+	return e
+}
+
+// typeDef looks up a named type, instantiating generic references like "Optional<uint32>"
+func (g *luaGen) typeDef(name string) (map[string]interface{}, bool) {
+	if def, ok := g.types[name].(map[string]interface{}); ok {
+		return def, true
+	}
+	if def, ok := g.generics[name]; ok {
+		return def, true
+	}
+	def, ok := instantiateGeneric(g.types, name)
+	if ok {
+		g.generics[name] = def
+	}
+	return def, ok
+}
+
+// require queues a type for dissect function generation
+func (g *luaGen) require(name string) {
+	if !g.queued[name] {
+		g.queued[name] = true
+		g.pending = append(g.pending, name)
+	}
+}
+
+func (g *luaGen) id() int {
+	g.next++
+	return g.next
+}
+
+// field declares a ProtoField and returns its Lua reference. Reusing an abbreviation
+// with the same declaration shares the field; a conflicting one gets a numeric suffix.
+func (g *luaGen) field(e luaElement, suffix, ftype string, extra ...string) string {
+	abbr := g.proto + "." + luaAbbr(e.typeName) + "." + e.path + suffix
+	for n := 2; ; n++ {
+		decl := luaProtoField(ftype, abbr, e.label, e.desc, extra...)
+		existing, taken := g.abbrs[abbr]
+		if !taken {
+			g.abbrs[abbr] = decl
+			g.fields = append(g.fields, fmt.Sprintf("f[%s] = %s\n", luaString(abbr), decl))
+		}
+		if !taken || existing == decl {
+			return fmt.Sprintf("f[%s]", luaString(abbr))
+		}
+		abbr = fmt.Sprintf("%s.%s%s_%d", g.proto, luaAbbr(e.typeName), e.path+suffix, n)
+	}
+}
+
+// dissectFunction emits dissect["Name"] = function(buf, pos, tree, parent) returning
+// the new position and the decoded values
+func (g *luaGen) dissectFunction(name string) error {
+	def, _ := g.typeDef(name)
+	if instances, ok := def["instances"].([]interface{}); ok && len(instances) > 0 {
+		return fmt.Errorf("instance fields are not supported")
+	}
+	if protobuf, _ := def["protobuf"].(bool); protobuf {
+		return fmt.Errorf("protobuf messages are not supported")
+	}
+	g.body.WriteString(fmt.Sprintf("dissect[%s] = function(buf, pos, tree, parent)\n", luaString(name)))
+	g.body.WriteString("\tenter()\n")
+	g.body.WriteString("\tlocal v = setmetatable({}, { __index = parent })\n")
+
+	sequence, isStruct := def["sequence"].([]interface{})
+	if !isStruct {
+		// Aliases, enums and unions used directly as the root type
+		desc, _ := def["description"].(string)
+		e := luaElement{typeName: name, path: "value", label: name, desc: desc, target: "value", tree: "tree", indent: "\t"}
+		g.body.WriteString("\tlocal value\n")
+		if err := g.element(def, e, map[string]bool{}); err != nil {
+			return err
+		}
+		g.body.WriteString("\tleave()\n")
+		g.body.WriteString("\treturn pos, value\n")
+		g.body.WriteString("end\n\n")
+		return nil
+	}
+
+	for _, raw := range sequence {
+		field, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fieldName, _ := field["name"].(string)
+		desc, _ := field["description"].(string)
+		e := luaElement{
+			typeName: name, path: luaAbbr(fieldName), label: fieldName, desc: desc,
+			target: fmt.Sprintf("v[%s]", luaString(fieldName)), tree: "tree", indent: "\t",
+		}
+		if condition, ok := field["conditional"].(string); ok {
+			cond, err := g.expr(condition, func(path string) string { return fmt.Sprintf("resolve(v, %s)", luaString(path)) })
+			if err != nil {
+				return fmt.Errorf("%s: %w", fieldName, err)
+			}
+			e.line(g, "if truthy(%s) then", cond)
+			e = e.nested()
+		}
+		if err := g.element(field, e, map[string]bool{}); err != nil {
+			return fmt.Errorf("%s: %w", fieldName, err)
+		}
+		if e.indent != "\t" {
+			g.body.WriteString("\tend\n")
+		}
+	}
+	g.body.WriteString("\tleave()\n")
+	g.body.WriteString("\treturn pos, v\n")
+	g.body.WriteString("end\n\n")
+	return nil
+}
+
+// element emits code that decodes one element at pos, adds it to the tree,
+// stores its value in e.target and advances pos
+func (g *luaGen) element(def map[string]interface{}, e luaElement, aliases map[string]bool) error {
+	elemType, _ := def["type"].(string)
+	le := g.littleEndian(def)
+	if _, ok := def["codec"]; ok {
+		// Only the Go code registered for it knows the field's bytes
+		return fmt.Errorf("codec fields are not supported")
+	}
+
+	switch elemType {
+	case "uint8", "uint16", "uint32", "uint64", "int8", "int16", "int32", "int64":
+		ref := g.field(e, "", elemType, "base.DEC")
+		g.integer(e, elemType, le, ref, "")
+		return nil
+	case "uint128", "int128", "bcd", "packed_bcd":
+		// Wireshark has no 128-bit integer or BCD field type, so these show as their bytes
+		size := 16
+		if elemType != "uint128" && elemType != "int128" {
+			size = bcdBytes(intAttr(def, "digits", 0), elemType == "packed_bcd")
+		}
+		ref := g.field(e, "", "bytes", "base.NONE")
+		e.line(g, "%s:add(%s, at(buf, pos, %d))", e.tree, ref, size)
+		e.line(g, "%s = at(buf, pos, %d):bytes()", e.target, size)
+		e.line(g, "pos = pos + %d", size*8)
+		return nil
+	case "unix32", "unix64", "unix_milli", "ntp":
+		// Shown as the integer stored, whatever its unit and epoch
+		wireType := timestampWires[elemType].goType
+		ref := g.field(e, "", wireType, "base.DEC")
+		g.integer(e, wireType, le, ref, "")
+		return nil
+	case "uuid", "mac", "ipv4", "ipv6":
+		addr := luaAddressFields[elemType]
+		ref := g.field(e, "", addr.ftype)
+		e.line(g, "%s:add(%s, at(buf, pos, %d))", e.tree, ref, addr.size)
+		e.line(g, "%s = at(buf, pos, %d):bytes()", e.target, addr.size)
+		e.line(g, "pos = pos + %d", addr.size*8)
+		return nil
+	case "float32", "float64":
+		ftype := "float"
+		size := 4
+		if elemType == "float64" {
+			ftype, size = "double", 8
+		}
+		ref := g.field(e, "", ftype)
+		e.line(g, "do")
+		in := e.nested()
+		in.line(g, "local r = at(buf, pos, %d)", size)
+		in.line(g, "tree_add(%s, %s, r, %t)", e.tree, ref, le)
+		in.line(g, "%s = %s", e.target, luaGetter("float", le))
+		in.line(g, "pos = pos + %d", size*8)
+		e.line(g, "end")
+		return nil
+	case "bool":
+		ref := g.field(e, "", "bool")
+		e.line(g, "do")
+		in := e.nested()
+		in.line(g, "%s = at(buf, pos, 1):uint() ~= 0", e.target)
+		in.line(g, "%s:add(%s, at(buf, pos, 1), %s)", e.tree, ref, e.target)
+		in.line(g, "pos = pos + 8")
+		e.line(g, "end")
+		return nil
+	case "bit", "int":
+		size := intAttr(def, "size", 1)
+		signed, isSet := def["signed"].(bool)
+		if elemType == "int" && !isSet {
+			signed = true
+		}
+		ref := g.field(e, "", luaBitsType(size, signed), "base.DEC")
+		e.line(g, "do")
+		in := e.nested()
+		value := fmt.Sprintf("read_bits(buf, pos, %d, %t)", size, g.bitOrder == "lsb_first")
+		if signed {
+			value = fmt.Sprintf("sign_extend(%s, %d)", value, size)
+		}
+		in.line(g, "local value = %s", value)
+		in.line(g, "%s:add(%s, bits_range(buf, pos, %d), %s)", e.tree, ref, size, luaExplicitValue("value", size, signed))
+		in.line(g, "%s = value", e.target)
+		in.line(g, "pos = pos + %d", size)
+		e.line(g, "end")
+		return nil
+	case "bitfield":
+		return g.bitfield(def, e)
+	case "varlength":
+		encoding, _ := def["encoding"].(string)
+		if encoding == "" {
+			encoding = "der"
+		}
+		ref := g.field(e, "", "uint64", "base.DEC")
+		e.line(g, "do")
+		in := e.nested()
+		in.line(g, "local value, size = read_varlength(buf, pos, %s)", luaString(encoding))
+		in.line(g, "%s:add(%s, at(buf, pos, size), UInt64.new(value))", e.tree, ref)
+		in.line(g, "%s = value", e.target)
+		in.line(g, "pos = pos + size * 8")
+		e.line(g, "end")
+		return nil
+	case "enum":
+		return g.enum(def, e)
+	case "flags":
+		repr, _ := def["repr"].(string)
+		if integerBytes(repr) == 0 {
+			return fmt.Errorf("flags have unsupported repr %q", repr)
+		}
+		ref := g.field(e, "", repr, "base.HEX")
+		g.integer(e, repr, g.littleEndian(def), ref, "")
+		return nil
+	case "string":
+		return g.stringElement(def, e)
+	case "bytes":
+		return g.bytesElement(def, e)
+	case "array":
+		return g.array(def, e, aliases)
+	case "optional":
+		valueType, _ := def["value_type"].(string)
+		if valueType == "" {
+			return fmt.Errorf("optional has no value_type")
+		}
+		presence := "read_uint(buf, pos, 1, false)"
+		width := 8
+		if pt, _ := def["presence_type"].(string); pt == "bit" {
+			presence = fmt.Sprintf("read_bits(buf, pos, 1, %t)", g.bitOrder == "lsb_first")
+			width = 1
+		}
+		e.line(g, "do")
+		in := e.nested()
+		in.line(g, "local present = %s", presence)
+		in.line(g, "pos = pos + %d", width)
+		in.line(g, "if present ~= 0 then")
+		inner := in.nested()
+		if err := g.element(map[string]interface{}{"type": valueType}, inner, aliases); err != nil {
+			return err
+		}
+		in.line(g, "end")
+		e.line(g, "end")
+		return nil
+	case "padding":
+		align := intAttr(def, "align_to", 1)
+		e.line(g, "pos = math.ceil(pos / 8) * 8")
+		e.line(g, "while (pos / 8) %% %d ~= 0 do", align)
+		e.line(g, "\tpos = pos + 8")
+		e.line(g, "end")
+		return nil
+	case "discriminated_union":
+		return g.union(def, e)
+	case "choice":
+		return g.choice(def, e)
+	case "back_reference":
+		return g.backReference(def, e)
+	case "":
+		return fmt.Errorf("element has no type")
+	}
+
+	typeDef, ok := g.typeDef(elemType)
+	if !ok {
+		return fmt.Errorf("type %s not found in schema", elemType)
+	}
+	if _, isStruct := typeDef["sequence"].([]interface{}); !isStruct {
+		// Inline aliases so enums and unions are shown under the referencing field's name
+		if aliases[elemType] {
+			return fmt.Errorf("type alias %s refers to itself", elemType)
+		}
+		aliases[elemType] = true
+		defer delete(aliases, elemType)
+		if e.desc == "" {
+			e.desc, _ = typeDef["description"].(string)
+		}
+		return g.element(typeDef, e, aliases)
+	}
+
+	g.require(elemType)
+	ref := g.field(e, "", "none")
+	n := g.id()
+	e.line(g, "do")
+	in := e.nested()
+	in.line(g, "local start%d = pos", n)
+	in.line(g, "local sub%d = %s:add(%s, at(buf, pos, 0))", n, e.tree, ref)
+	in.line(g, "pos, %s = dissect[%s](buf, pos, sub%d, v)", e.target, luaString(elemType), n)
+	in.line(g, "finish(sub%d, start%d, pos)", n, n)
+	e.line(g, "end")
+	return nil
+}
+
+func (g *luaGen) littleEndian(def map[string]interface{}) bool {
+	if e, ok := def["endianness"].(string); ok {
+		return e == "little_endian"
+	}
+	return g.endianness == "little_endian"
+}
+
+// integer emits a whole-byte integer read. A non-empty valueString marks enum fields,
+// which get an expert warning for values without a name.
+func (g *luaGen) integer(e luaElement, intType string, le bool, ref, valueString string) {
+	size := integerBytes(intType)
+	signed := strings.HasPrefix(intType, "int")
+	getter := "uint"
+	if signed {
+		getter = "int"
+	}
+	if size == 8 {
+		getter += "64"
+	}
+	value := luaGetter(getter, le && size > 1)
+	if size == 8 {
+		value += ":tonumber()"
+	}
+
+	e.line(g, "do")
+	in := e.nested()
+	in.line(g, "local r = at(buf, pos, %d)", size)
+	in.line(g, "local item = tree_add(%s, %s, r, %t)", e.tree, ref, le && size > 1)
+	in.line(g, "local value = %s", value)
+	if valueString != "" {
+		in.line(g, "if %s[value] == nil then", valueString)
+		in.line(g, "\titem:add_expert_info(PI_MALFORMED, PI_WARN, \"invalid enum value \" .. value)")
+		in.line(g, "end")
+	}
+	in.line(g, "%s = value", e.target)
+	in.line(g, "pos = pos + %d", size*8)
+	e.line(g, "end")
+}
+
+func (g *luaGen) enum(def map[string]interface{}, e luaElement) error {
+	repr, _ := def["repr"].(string)
+	if integerBytes(repr) == 0 {
+		return fmt.Errorf("enum has unsupported repr %q", repr)
+	}
+	variants, _ := def["variants"].(map[string]interface{})
+	names := make([]string, 0, len(variants))
+	for name := range variants {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, _ := variants[names[i]].(float64)
+		b, _ := variants[names[j]].(float64)
+		return a < b || (a == b && names[i] < names[j])
+	})
+
+	table := fmt.Sprintf("vs%d", g.id())
+	var decl strings.Builder
+	decl.WriteString(fmt.Sprintf("local %s = {\n", table))
+	for _, name := range names {
+		value, ok := variants[name].(float64)
+		if !ok {
+			return fmt.Errorf("enum variant %s has non-numeric value", name)
+		}
+		decl.WriteString(fmt.Sprintf("\t[%d] = %s,\n", int64(value), luaString(name)))
+	}
+	decl.WriteString("}\n")
+	g.valueStrings = append(g.valueStrings, decl.String())
+
+	ref := g.field(e, "", repr, "base.DEC", table)
+	g.integer(e, repr, g.littleEndian(def), ref, table)
+	return nil
+}
+
+// bitfield emits a container with one ProtoField per subfield. Containers of whole
+// bytes (up to 32 bits, MSB first) use masks so Wireshark draws the bit layout.
+func (g *luaGen) bitfield(def map[string]interface{}, e luaElement) error {
+	subfields, _ := def["fields"].([]interface{})
+	total := intAttr(def, "size", 0)
+	if total == 0 {
+		for _, raw := range subfields {
+			sub, _ := raw.(map[string]interface{})
+			total += intAttr(sub, "size", 1)
+		}
+	}
+	masked := g.bitOrder != "lsb_first" && total%8 == 0 && total > 0 && total <= 32
+
+	n := g.id()
+	e.line(g, "do")
+	in := e.nested()
+	in.line(g, "local start%d = pos", n)
+	in.line(g, "local values%d = {}", n)
+	if masked {
+		container := g.field(e, "", fmt.Sprintf("uint%d", total), "base.HEX")
+		in.line(g, "local r = at(buf, pos, %d)", total/8)
+		in.line(g, "local sub%d = %s:add(%s, r)", n, e.tree, container)
+	} else {
+		container := g.field(e, "", "none")
+		in.line(g, "local sub%d = %s:add(%s, bits_range(buf, pos, %d))", n, e.tree, container, total)
+	}
+
+	offset := 0
+	for _, raw := range subfields {
+		sub, _ := raw.(map[string]interface{})
+		name, _ := sub["name"].(string)
+		size := intAttr(sub, "size", 1)
+		se := e
+		se.path, se.label = e.path+"."+luaAbbr(name), name
+		se.desc, _ = sub["description"].(string)
+		value := fmt.Sprintf("read_bits(buf, start%d + %d, %d, %t)", n, offset, size, g.bitOrder == "lsb_first")
+		if masked {
+			mask := ((uint64(1) << size) - 1) << (total - offset - size)
+			ref := g.field(se, "", fmt.Sprintf("uint%d", total), "base.DEC", "nil", fmt.Sprintf("0x%X", mask))
+			in.line(g, "sub%d:add(%s, r)", n, ref)
+			in.line(g, "values%d[%s] = %s", n, luaString(name), value)
+		} else {
+			ref := g.field(se, "", luaBitsType(size, false), "base.DEC")
+			in.line(g, "values%d[%s] = %s", n, luaString(name), value)
+			in.line(g, "sub%d:add(%s, bits_range(buf, start%d + %d, %d), %s)", n, ref, n, offset, size,
+				luaExplicitValue(fmt.Sprintf("values%d[%s]", n, luaString(name)), size, false))
+		}
+		offset += size
+	}
+	in.line(g, "%s = values%d", e.target, n)
+	in.line(g, "pos = start%d + %d", n, total)
+	e.line(g, "end")
+	return nil
+}
+
+// readLength emits code that reads a length prefix into local name and advances pos
+func (g *luaGen) readLength(def map[string]interface{}, key, name string, e luaElement) error {
+	lengthType, _ := def[key].(string)
+	if lengthType == "" {
+		lengthType = "uint8"
+	}
+	if lengthType == "varlength" {
+		encoding, _ := def["length_encoding"].(string)
+		if encoding == "" {
+			encoding = "der"
+		}
+		e.line(g, "local %s, %s_size = read_varlength(buf, pos, %s)", name, name, luaString(encoding))
+		e.line(g, "pos = pos + %s_size * 8", name)
+		return nil
+	}
+	size := integerBytes(lengthType)
+	if size == 0 {
+		return fmt.Errorf("unsupported length type %q", lengthType)
+	}
+	e.line(g, "local %s = read_uint(buf, pos, %d, %t)", name, size, g.endianness == "little_endian" && size > 1)
+	e.line(g, "pos = pos + %d", size*8)
+	return nil
+}
+
+func (g *luaGen) stringElement(def map[string]interface{}, e luaElement) error {
+	ref := g.field(e, "", "string", "base.UNICODE")
+	le := g.littleEndian(def)
+	width := 1
+	if def["encoding"] == "utf16" {
+		width = 2
+	}
+	decode := func(rangeExpr string) string {
+		switch def["encoding"] {
+		case "utf16":
+			return fmt.Sprintf("%s:%s()", rangeExpr, map[bool]string{true: "le_ustring", false: "ustring"}[le])
+		case "ascii":
+			return rangeExpr + ":string(ENC_ASCII)"
+		case "latin1":
+			return rangeExpr + ":string(ENC_ISO_8859_1)"
+		}
+		return rangeExpr + ":string(ENC_UTF_8)"
+	}
+
+	kind, _ := def["kind"].(string)
+	if kind == "fixed" && def["length_field"] != nil && def["length"] == nil {
+		kind = "field_referenced"
+	}
+	n := g.id()
+	e.line(g, "do")
+	in := e.nested()
+	switch kind {
+	case "length_prefixed":
+		if err := g.readLength(def, "length_type", fmt.Sprintf("n%d", n), in); err != nil {
+			return err
+		}
+	case "field_referenced":
+		lengthField, _ := def["length_field"].(string)
+		in.line(g, "local n%d = resolve(v, %s)", n, luaString(lengthField))
+	case "fixed":
+		in.line(g, "local n%d = %d", n, intAttr(def, "length", 0))
+	case "null_terminated":
+		in.line(g, "local n%d = find_null(buf, pos, %d)", n, width)
+	default:
+		return fmt.Errorf("unsupported string kind %q", kind)
+	}
+
+	if kind == "fixed" {
+		// Content ends at the first null; the whole field is highlighted
+		in.line(g, "local value = %s", decode(fmt.Sprintf("at(buf, pos, find_null(buf, pos, %d, n%d))", width, n)))
+	} else {
+		in.line(g, "local value = %s", decode(fmt.Sprintf("at(buf, pos, n%d)", n)))
+	}
+	span := fmt.Sprintf("n%d", n)
+	if kind == "null_terminated" {
+		span = fmt.Sprintf("n%d + %d", n, width)
+	}
+	in.line(g, "%s:add(%s, at(buf, pos, %s), value)", e.tree, ref, span)
+	in.line(g, "%s = value", e.target)
+	in.line(g, "pos = pos + (%s) * 8", span)
+	e.line(g, "end")
+	return nil
+}
+
+func (g *luaGen) bytesElement(def map[string]interface{}, e luaElement) error {
+	ref := g.field(e, "", "bytes", "base.NONE")
+	kind, _ := def["kind"].(string)
+	n := g.id()
+	e.line(g, "do")
+	in := e.nested()
+	switch kind {
+	case "fixed":
+		in.line(g, "local n%d = %d", n, intAttr(def, "length", 0))
+	case "length_prefixed":
+		if err := g.readLength(def, "length_type", fmt.Sprintf("n%d", n), in); err != nil {
+			return err
+		}
+	case "field_referenced":
+		lengthField, _ := def["length_field"].(string)
+		in.line(g, "local n%d = resolve(v, %s)", n, luaString(lengthField))
+	case "eof_terminated":
+		in.line(g, "local n%d = buf:len() - math.floor(pos / 8)", n)
+	default:
+		return fmt.Errorf("unsupported bytes kind %q", kind)
+	}
+	in.line(g, "%s:add(%s, at(buf, pos, n%d))", e.tree, ref, n)
+	in.line(g, "%s = at(buf, pos, n%d):bytes()", e.target, n)
+	in.line(g, "pos = pos + n%d * 8", n)
+	e.line(g, "end")
+	return nil
+}
+
+func (g *luaGen) array(def map[string]interface{}, e luaElement, aliases map[string]bool) error {
+	itemDef, ok := def["items"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("array has no items")
+	}
+	kind, _ := def["kind"].(string)
+	ref := g.field(e, "", "none")
+	n := g.id()
+	items := fmt.Sprintf("items%d", n)
+
+	e.line(g, "do")
+	in := e.nested()
+	in.line(g, "local start%d = pos", n)
+	in.line(g, "local %s = {}", items)
+	in.line(g, "local sub%d = %s:add(%s, at(buf, pos, 0))", n, e.tree, ref)
+
+	// item emits one array item inside a loop body
+	item := func(loop luaElement) error {
+		ie := loop
+		ie.path, ie.target, ie.tree = e.path+".item", fmt.Sprintf("%s[#%s + 1]", items, items), fmt.Sprintf("sub%d", n)
+		ie.desc = ""
+		return g.element(itemDef, ie, aliases)
+	}
+	terminal := func() string {
+		variants, _ := def["terminal_variants"].([]interface{})
+		names := make([]string, 0, len(variants))
+		for _, v := range variants {
+			if s, ok := v.(string); ok {
+				names = append(names, luaString(s))
+			}
+		}
+		return "{ " + strings.Join(names, ", ") + " }"
+	}
+	countLoop := func(count string) error {
+		in.line(g, "for _ = 1, %s do", count)
+		if err := item(in.nested()); err != nil {
+			return err
+		}
+		in.line(g, "end")
+		return nil
+	}
+
+	switch kind {
+	case "fixed":
+		if err := countLoop(fmt.Sprint(intAttr(def, "length", 0))); err != nil {
+			return err
+		}
+	case "length_prefixed":
+		if err := g.readLength(def, "length_type", fmt.Sprintf("count%d", n), in); err != nil {
+			return err
+		}
+		if err := countLoop(fmt.Sprintf("count%d", n)); err != nil {
+			return err
+		}
+	case "field_referenced":
+		lengthField, _ := def["length_field"].(string)
+		if err := countLoop(fmt.Sprintf("resolve(v, %s)", luaString(lengthField))); err != nil {
+			return err
+		}
+	case "computed_count":
+		countExpr, _ := def["count_expr"].(string)
+		count, err := g.expr(countExpr, func(path string) string { return fmt.Sprintf("resolve(v, %s)", luaString(path)) })
+		if err != nil {
+			return err
+		}
+		if err := countLoop(count); err != nil {
+			return err
+		}
+	case "length_prefixed_items":
+		if err := g.readLength(def, "length_type", fmt.Sprintf("count%d", n), in); err != nil {
+			return err
+		}
+		in.line(g, "for _ = 1, count%d do", n)
+		body := in.nested()
+		if err := g.readLength(def, "item_length_type", fmt.Sprintf("size%d", n), body); err != nil {
+			return err
+		}
+		body.line(g, "local item_start%d = pos", n)
+		if err := item(body); err != nil {
+			return err
+		}
+		body.line(g, "pos = item_start%d + size%d * 8", n, n)
+		in.line(g, "end")
+	case "byte_length_prefixed":
+		if err := g.readLength(def, "length_type", fmt.Sprintf("size%d", n), in); err != nil {
+			return err
+		}
+		in.line(g, "local limit%d = pos + size%d * 8", n, n)
+		in.line(g, "while pos < limit%d do", n)
+		if err := item(in.nested()); err != nil {
+			return err
+		}
+		in.line(g, "end")
+		in.line(g, "pos = limit%d", n)
+	case "eof_terminated":
+		in.line(g, "while pos < buf:len() * 8 do")
+		if err := item(in.nested()); err != nil {
+			return err
+		}
+		in.line(g, "end")
+	case "null_terminated":
+		in.line(g, "while true do")
+		body := in.nested()
+		body.line(g, "if at(buf, pos, 1):uint() == 0 then")
+		body.line(g, "\tpos = pos + 8")
+		body.line(g, "\tbreak")
+		body.line(g, "end")
+		if err := item(body); err != nil {
+			return err
+		}
+		if _, ok := def["terminal_variants"]; ok {
+			body.line(g, "if is_terminal(%s[#%s], %s) then", items, items, terminal())
+			body.line(g, "\tbreak")
+			body.line(g, "end")
+		}
+		in.line(g, "end")
+	case "signature_terminated":
+		terminatorType, _ := def["terminator_type"].(string)
+		size := integerBytes(terminatorType)
+		if size == 0 {
+			return fmt.Errorf("unsupported terminator type %q", terminatorType)
+		}
+		le := g.endianness == "little_endian"
+		if te, ok := def["terminator_endianness"].(string); ok {
+			le = te == "little_endian"
+		}
+		terminator, _ := def["terminator_value"].(float64)
+		in.line(g, "while read_uint(buf, pos, %d, %t) ~= %d do", size, le && size > 1, int64(terminator))
+		if err := item(in.nested()); err != nil {
+			return err
+		}
+		in.line(g, "end")
+	case "variant_terminated":
+		in.line(g, "repeat")
+		if err := item(in.nested()); err != nil {
+			return err
+		}
+		in.line(g, "until is_terminal(%s[#%s], %s)", items, items, terminal())
+	default:
+		return fmt.Errorf("unsupported array kind %q", kind)
+	}
+
+	in.line(g, "finish(sub%d, start%d, pos)", n, n)
+	in.line(g, "sub%d:append_text(\" (\" .. #%s .. \" items)\")", n, items)
+	in.line(g, "%s = %s", e.target, items)
+	e.line(g, "end")
+	return nil
+}
+
+func (g *luaGen) union(def map[string]interface{}, e luaElement) error {
+	disc, _ := def["discriminator"].(map[string]interface{})
+	ref := g.field(e, "", "none")
+	n := g.id()
+
+	e.line(g, "do")
+	in := e.nested()
+	if peek, ok := disc["peek"].(string); ok {
+		size := integerBytes(peek)
+		if size == 0 {
+			return fmt.Errorf("unsupported discriminator peek type %q", peek)
+		}
+		in.line(g, "local disc%d = read_uint(buf, pos, %d, %t)", n, size, g.littleEndian(disc) && size > 1)
+	} else if field, ok := disc["field"].(string); ok {
+		in.line(g, "local disc%d = resolve(v, %s)", n, luaString(field))
+	} else {
+		return fmt.Errorf("discriminated union has no discriminator")
+	}
+
+	in.line(g, "local variant%d", n)
+	variants, _ := def["variants"].([]interface{})
+	keyword := "if"
+	for _, raw := range variants {
+		variant, _ := raw.(map[string]interface{})
+		variantType, _ := variant["type"].(string)
+		if variantType == "" {
+			return fmt.Errorf("union variant has no type")
+		}
+		if _, ok := g.typeDef(variantType); !ok {
+			return fmt.Errorf("type %s not found in schema", variantType)
+		}
+		g.require(variantType)
+		when, hasWhen := variant["when"].(string)
+		if !hasWhen {
+			if keyword == "if" {
+				in.line(g, "variant%d = %s", n, luaString(variantType))
+			} else {
+				in.line(g, "else")
+				in.line(g, "\tvariant%d = %s", n, luaString(variantType))
+			}
+			break
+		}
+		cond, err := g.expr(when, func(path string) string {
+			if path == "value" {
+				return fmt.Sprintf("disc%d", n)
+			}
+			return fmt.Sprintf("resolve(v, %s)", luaString(path))
+		})
+		if err != nil {
+			return err
+		}
+		in.line(g, "%s truthy(%s) then", keyword, cond)
+		in.line(g, "\tvariant%d = %s", n, luaString(variantType))
+		keyword = "elseif"
+	}
+	if keyword != "if" {
+		in.line(g, "end")
+	}
+
+	in.line(g, "local start%d = pos", n)
+	in.line(g, "local sub%d = %s:add(%s, at(buf, pos, 0))", n, e.tree, ref)
+	in.line(g, "if variant%d == nil then", n)
+	in.line(g, "\tsub%d:add_expert_info(PI_MALFORMED, PI_ERROR, \"no variant matches discriminator \" .. tostring(disc%d))", n, n)
+	in.line(g, "\terror(\"no variant matches discriminator\")")
+	in.line(g, "end")
+	in.line(g, "sub%d:append_text(\": \" .. variant%d)", n, n)
+	in.line(g, "local value%d", n)
+	if budget, ok := def["byte_budget"].(map[string]interface{}); ok {
+		budgetField, _ := budget["field"].(string)
+		in.line(g, "local limit%d = pos + resolve(v, %s) * 8", n, luaString(budgetField))
+		in.line(g, "pos, value%d = dissect[variant%d](buf, pos, sub%d, v)", n, n, n)
+		in.line(g, "pos = limit%d", n)
+	} else {
+		in.line(g, "pos, value%d = dissect[variant%d](buf, pos, sub%d, v)", n, n, n)
+	}
+	in.line(g, "finish(sub%d, start%d, pos)", n, n)
+	in.line(g, "%s = { type = variant%d, value = value%d }", e.target, n, n)
+	e.line(g, "end")
+	return nil
+}
+
+// choice picks the variant whose leading const field matches the next bytes
+func (g *luaGen) choice(def map[string]interface{}, e luaElement) error {
+	ref := g.field(e, "", "none")
+	n := g.id()
+	e.line(g, "do")
+	in := e.nested()
+	in.line(g, "local variant%d", n)
+	choices, _ := def["choices"].([]interface{})
+	keyword := "if"
+	for _, raw := range choices {
+		c, _ := raw.(map[string]interface{})
+		name, _ := c["type"].(string)
+		typeDef, ok := g.typeDef(name)
+		if !ok {
+			return fmt.Errorf("type %s not found in schema", name)
+		}
+		sequence, _ := typeDef["sequence"].([]interface{})
+		if len(sequence) == 0 {
+			continue
+		}
+		first, _ := sequence[0].(map[string]interface{})
+		constVal, hasConst := first["const"].(float64)
+		firstType, _ := first["type"].(string)
+		size := integerBytes(firstType)
+		if !hasConst || size == 0 {
+			return fmt.Errorf("choice variant %s must start with an integer const field", name)
+		}
+		g.require(name)
+		in.line(g, "%s read_uint(buf, pos, %d, %t) == %d then", keyword, size, g.littleEndian(first) && size > 1, int64(constVal))
+		in.line(g, "\tvariant%d = %s", n, luaString(name))
+		keyword = "elseif"
+	}
+	if keyword != "if" {
+		in.line(g, "end")
+	}
+	in.line(g, "if variant%d == nil then", n)
+	in.line(g, "\terror(\"no choice variant matches\")")
+	in.line(g, "end")
+	in.line(g, "local start%d = pos", n)
+	in.line(g, "local sub%d = %s:add(%s, at(buf, pos, 0))", n, e.tree, ref)
+	in.line(g, "sub%d:append_text(\": \" .. variant%d)", n, n)
+	in.line(g, "local value%d", n)
+	in.line(g, "pos, value%d = dissect[variant%d](buf, pos, sub%d, v)", n, n, n)
+	in.line(g, "value%d.type = variant%d", n, n)
+	in.line(g, "finish(sub%d, start%d, pos)", n, n)
+	in.line(g, "%s = value%d", e.target, n)
+	e.line(g, "end")
+	return nil
+}
+
+// backReference shows the pointer and decodes its target in place. Pointer loops
+// are stopped by the nesting depth limit.
+func (g *luaGen) backReference(def map[string]interface{}, e luaElement) error {
+	storage, _ := def["storage"].(string)
+	size := integerBytes(storage)
+	if size == 0 || size > 4 {
+		return fmt.Errorf("unsupported back_reference storage %q", storage)
+	}
+	mask := uint64(1)<<(size*8) - 1
+	switch m := def["offset_mask"].(type) {
+	case string:
+		if _, err := fmt.Sscanf(m, "0x%x", &mask); err != nil {
+			return fmt.Errorf("invalid offset_mask %q", m)
+		}
+	case float64:
+		mask = uint64(m)
+	}
+	target, _ := def["target_type"].(string)
+	if _, ok := g.typeDef(target); !ok {
+		return fmt.Errorf("type %s not found in schema", target)
+	}
+	g.require(target)
+	le := g.littleEndian(def) && size > 1
+	ref := g.field(e, "", storage, "base.HEX")
+
+	n := g.id()
+	e.line(g, "do")
+	in := e.nested()
+	in.line(g, "local r = at(buf, pos, %d)", size)
+	in.line(g, "local offset%d = read_uint(buf, pos, %d, %t) %% %d", n, size, le, mask+1)
+	in.line(g, "local sub%d = tree_add(%s, %s, r, %t)", n, e.tree, ref, le)
+	in.line(g, "sub%d:append_text(\" (offset \" .. offset%d .. \")\")", n, n)
+	in.line(g, "pos = pos + %d", size*8)
+	in.line(g, "local _, value%d = dissect[%s](buf, offset%d * 8, sub%d, v)", n, luaString(target), n, n)
+	in.line(g, "%s = value%d", e.target, n)
+	e.line(g, "end")
+	return nil
+}
+
+// expr translates a schema expression to Lua. Fields are mapped by field; conditions
+// must be wrapped in truthy() since 0 is true in Lua.
+func (g *luaGen) expr(src string, field func(path string) string) (string, error) {
+	node, err := expression.Parse(src)
+	if err != nil {
+		return "", fmt.Errorf("invalid expression %q: %w", src, err)
+	}
+	return luaExpr(node, field)
+}
+
+func luaExpr(node expression.Node, field func(path string) string) (string, error) {
+	switch n := node.(type) {
+	case *expression.Number:
+		return fmt.Sprint(n.Value), nil
+	case *expression.String:
+		return luaString(n.Value), nil
+	case *expression.Bool:
+		return fmt.Sprint(n.Value), nil
+	case *expression.Field:
+		return field(n.Path), nil
+	case *expression.Unary:
+		operand, err := luaExpr(n.Operand, field)
+		if err != nil {
+			return "", err
+		}
+		switch n.Op {
+		case "-":
+			return "(-" + operand + ")", nil
+		case "!":
+			return "(not truthy(" + operand + "))", nil
+		case "~":
+			return "bit.bnot(" + operand + ")", nil
+		}
+		return "", fmt.Errorf("unsupported operator %q", n.Op)
+	case *expression.Binary:
+		left, err := luaExpr(n.Left, field)
+		if err != nil {
+			return "", err
+		}
+		right, err := luaExpr(n.Right, field)
+		if err != nil {
+			return "", err
+		}
+		switch n.Op {
+		case "&&":
+			return fmt.Sprintf("(truthy(%s) and truthy(%s))", left, right), nil
+		case "||":
+			return fmt.Sprintf("(truthy(%s) or truthy(%s))", left, right), nil
+		case "!=":
+			return fmt.Sprintf("(%s ~= %s)", left, right), nil
+		case "==", "<", "<=", ">", ">=", "+", "-", "*":
+			return fmt.Sprintf("(%s %s %s)", left, n.Op, right), nil
+		case "/":
+			return fmt.Sprintf("idiv(%s, %s)", left, right), nil
+		case "%":
+			return fmt.Sprintf("math.fmod(%s, %s)", left, right), nil
+		}
+		if fn, ok := luaBitOps[n.Op]; ok {
+			return fmt.Sprintf("bit.%s(%s, %s)", fn, left, right), nil
+		}
+		return "", fmt.Errorf("unsupported operator %q", n.Op)
+	}
+	return "", fmt.Errorf("unsupported expression %T", node)
+}
+
+var luaBitOps = map[string]string{"&": "band", "|": "bor", "^": "bxor", "<<": "lshift", ">>": "rshift"}
+
+// luaProtoField renders a ProtoField constructor. extra holds the arguments between the
+// name and the description (display base, value_string, mask), trailing nils are dropped.
+func luaProtoField(ftype, abbr, label, desc string, extra ...string) string {
+	slots := 0
+	switch ftype {
+	case "float", "double", "none", "guid", "ether", "ipv4", "ipv6":
+	case "string", "bytes":
+		slots = 1
+	default:
+		slots = 3
+	}
+	args := []string{luaString(abbr), luaString(label)}
+	args = append(args, extra...)
+	if desc != "" {
+		for len(args) < slots+2 {
+			args = append(args, "nil")
+		}
+		args = append(args, luaString(desc))
+	}
+	for len(args) > 2 && args[len(args)-1] == "nil" {
+		args = args[:len(args)-1]
+	}
+	return fmt.Sprintf("ProtoField.%s(%s)", ftype, strings.Join(args, ", "))
+}
+
+// ProtoField type and byte size of each address type
+var luaAddressFields = map[string]struct {
+	ftype string
+	size  int
+}{
+	"uuid": {"guid", 16},
+	"mac":  {"ether", 6},
+	"ipv4": {"ipv4", 4},
+	"ipv6": {"ipv6", 16},
+}
+
+// luaBitsType returns the smallest ProtoField integer type holding size bits
+func luaBitsType(size int, signed bool) string {
+	prefix := "uint"
+	if signed {
+		prefix = "int"
+	}
+	for _, width := range []int{8, 16, 24, 32} {
+		if size <= width {
+			return fmt.Sprintf("%s%d", prefix, width)
+		}
+	}
+	return prefix + "64"
+}
+
+// luaExplicitValue wraps a Lua number for TreeItem:add on a field of luaBitsType(size)
+func luaExplicitValue(value string, size int, signed bool) string {
+	if size <= 32 {
+		return value
+	}
+	if signed {
+		return fmt.Sprintf("Int64.new(%s)", value)
+	}
+	return fmt.Sprintf("UInt64.new(%s)", value)
+}
+
+// luaGetter returns the TvbRange accessor call on r for a value type
+func luaGetter(getter string, le bool) string {
+	if le {
+		return "r:le_" + getter + "()"
+	}
+	return "r:" + getter + "()"
+}
+
+func integerBytes(intType string) int {
+	switch intType {
+	case "uint8", "int8":
+		return 1
+	case "uint16", "int16":
+		return 2
+	case "uint32", "int32":
+		return 4
+	case "uint64", "int64":
+		return 8
+	}
+	return 0
+}
+
+func intAttr(def map[string]interface{}, key string, fallback int) int {
+	if v, ok := def[key].(float64); ok {
+		return int(v)
+	}
+	return fallback
+}
+
+// luaAbbr turns a schema name into a Wireshark field abbreviation part
+func luaAbbr(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return strings.Trim(b.String(), "_")
+}
+
+// luaString quotes s as a Lua string literal
+func luaString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString("\\n")
+		case c < 0x20 || c == 0x7f:
+			b.WriteString(fmt.Sprintf("\\%03d", c))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// schemaTitle picks a display name for the protocol from the schema's metadata
+func schemaTitle(schemaData map[string]interface{}, typeName string) string {
+	if meta, ok := schemaData["meta"].(map[string]interface{}); ok {
+		if title, _ := meta["title"].(string); title != "" {
+			return title
+		}
+	}
+	if protocol, ok := schemaData["protocol"].(map[string]interface{}); ok {
+		if name, _ := protocol["name"].(string); name != "" {
+			return name
+		}
+	}
+	return typeName
+}
+
+// instantiateGeneric resolves a reference like "Optional<uint64>" against a template
+// "Optional<T>" by substituting the type parameters throughout its definition
+func instantiateGeneric(types map[string]interface{}, name string) (map[string]interface{}, bool) {
+	open := strings.Index(name, "<")
+	if open < 0 || !strings.HasSuffix(name, ">") {
+		return nil, false
+	}
+	base := name[:open]
+	args := strings.Split(name[open+1:len(name)-1], ",")
+	for templateName, raw := range types {
+		if !strings.HasPrefix(templateName, base+"<") || !strings.HasSuffix(templateName, ">") {
+			continue
+		}
+		params := strings.Split(templateName[len(base)+1:len(templateName)-1], ",")
+		if len(params) != len(args) {
+			continue
+		}
+		bindings := make(map[string]string, len(params))
+		for i, param := range params {
+			bindings[strings.TrimSpace(param)] = strings.TrimSpace(args[i])
+		}
+		def, ok := substituteGenericParams(raw, bindings).(map[string]interface{})
+		return def, ok
+	}
+	return nil, false
+}
+
+func substituteGenericParams(v interface{}, bindings map[string]string) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(x))
+		for k, val := range x {
+			if t, isString := val.(string); isString && (k == "type" || k == "value_type" || k == "target_type") {
+				if bound, ok := bindings[t]; ok {
+					out[k] = bound
+					continue
+				}
+			}
+			out[k] = substituteGenericParams(val, bindings)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, val := range x {
+			out[i] = substituteGenericParams(val, bindings)
+		}
+		return out
+	}
+	return v
+}
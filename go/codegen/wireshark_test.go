@@ -0,0 +1,121 @@
+// ABOUTME: Tests for the Wireshark Lua dissector generator
+// ABOUTME: Checks ProtoField mapping, expression translation and registration in the generated Lua
+package codegen
+
+import (
+	"testing"
+
+	"github.com/serialexp/binschema/expression"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateWireshark(t *testing.T) {
+	schema := map[string]interface{}{
+		"meta":   map[string]interface{}{"title": "Sensor \"Net\""},
+		"config": map[string]interface{}{"endianness": "little_endian"},
+		"types": map[string]interface{}{
+			"Kind": map[string]interface{}{
+				"type": "enum", "repr": "uint8",
+				"variants": map[string]interface{}{"Ping": float64(1), "Pong": float64(2)},
+			},
+			"Ping": map[string]interface{}{"sequence": []interface{}{
+				map[string]interface{}{"name": "id", "type": "uint16"},
+			}},
+			"Pong": map[string]interface{}{"sequence": []interface{}{
+				map[string]interface{}{"name": "end", "type": "uint32", "endianness": "big_endian"},
+			}},
+			"Packet": map[string]interface{}{"sequence": []interface{}{
+				map[string]interface{}{
+					"name": "flags", "type": "bitfield", "size": float64(8),
+					"fields": []interface{}{
+						map[string]interface{}{"name": "version", "size": float64(3)},
+						map[string]interface{}{"name": "urgent", "size": float64(1), "description": "Deliver first"},
+						map[string]interface{}{"name": "reserved", "size": float64(4)},
+					},
+				},
+				map[string]interface{}{"name": "kind", "type": "Kind"},
+				map[string]interface{}{"name": "extra", "type": "uint16", "conditional": "flags.urgent == 1 && (kind & 0x2) != 0"},
+				map[string]interface{}{
+					"name": "body", "type": "discriminated_union",
+					"discriminator": map[string]interface{}{"field": "kind"},
+					"variants": []interface{}{
+						map[string]interface{}{"when": "value == 1", "type": "Ping"},
+						map[string]interface{}{"type": "Pong"},
+					},
+				},
+				map[string]interface{}{"name": "name", "type": "string", "kind": "length_prefixed", "length_type": "uint8", "encoding": "utf8"},
+				map[string]interface{}{"name": "samples", "type": "array", "kind": "fixed", "length": float64(2), "items": map[string]interface{}{"type": "int16"}},
+			}},
+		},
+	}
+
+	code, err := GenerateWireshark(schema, "Packet", WiresharkOptions{Name: "sensornet", Port: 5683})
+	require.NoError(t, err)
+
+	require.Contains(t, code, `local proto = Proto("sensornet", "Sensor \"Net\"")`)
+	// Bitfields are a container with one masked field per subfield
+	require.Contains(t, code, `f["sensornet.packet.flags"] = ProtoField.uint8("sensornet.packet.flags", "flags", base.HEX)`)
+	require.Contains(t, code, `ProtoField.uint8("sensornet.packet.flags.version", "version", base.DEC, nil, 0xE0)`)
+	require.Contains(t, code, `ProtoField.uint8("sensornet.packet.flags.urgent", "urgent", base.DEC, nil, 0x10, "Deliver first")`)
+	// Enums get a value_string table
+	require.Contains(t, code, "\t[1] = \"Ping\",\n\t[2] = \"Pong\",\n")
+	require.Regexp(t, `ProtoField\.uint8\("sensornet\.packet\.kind", "kind", base\.DEC, vs\d+\)`, code)
+	// Unions, arrays and nested structs become subtrees
+	require.Contains(t, code, `ProtoField.none("sensornet.packet.body", "body")`)
+	require.Contains(t, code, `ProtoField.int16("sensornet.packet.samples.item", "samples", base.DEC)`)
+	require.Contains(t, code, `dissect["Ping"] = function(buf, pos, tree, parent)`)
+	require.Contains(t, code, `dissect["Pong"] = function(buf, pos, tree, parent)`)
+	require.Contains(t, code, `ProtoField.string("sensornet.packet.name", "name", base.UNICODE)`)
+
+	// Expressions are translated to Lua with C-like truthiness
+	require.Contains(t, code, `if truthy((truthy((resolve(v, "flags.urgent") == 1)) and truthy((bit.band(resolve(v, "kind"), 2) ~= 0)))) then`)
+	require.Regexp(t, `if truthy\(\(disc\d+ == 1\)\) then`, code)
+
+	// Byte order follows the schema default, overridable per field
+	require.Contains(t, code, `tree_add(tree, f["sensornet.packet.extra"], r, true)`)
+	require.Contains(t, code, `tree_add(tree, f["sensornet.pong.end"], r, false)`)
+
+	require.Contains(t, code, `DissectorTable.get("udp.port"):add(5683, proto)`)
+}
+
+func TestGenerateWiresharkDefaults(t *testing.T) {
+	schema := map[string]interface{}{
+		"types": map[string]interface{}{
+			"Frame<T>": map[string]interface{}{"sequence": []interface{}{
+				map[string]interface{}{"name": "value", "type": "T"},
+			}},
+			"Message": map[string]interface{}{"sequence": []interface{}{
+				map[string]interface{}{"name": "frame", "type": "Frame<uint32>"},
+			}},
+		},
+	}
+
+	code, err := GenerateWireshark(schema, "Message", WiresharkOptions{Transport: "tcp"})
+	require.NoError(t, err)
+	require.Contains(t, code, `local proto = Proto("message", "Message")`)
+	require.Contains(t, code, `ProtoField.uint32("message.frame_uint32.value", "value", base.DEC)`)
+	require.Contains(t, code, `DissectorTable.get("tcp.port"):add_for_decode_as(proto)`)
+
+	_, err = GenerateWireshark(schema, "Missing", WiresharkOptions{})
+	require.ErrorContains(t, err, "type Missing not found")
+
+	_, err = GenerateWireshark(schema, "Message", WiresharkOptions{Transport: "sctp"})
+	require.ErrorContains(t, err, "unsupported transport")
+}
+
+func TestLuaExpr(t *testing.T) {
+	field := func(path string) string { return path }
+	cases := map[string]string{
+		"a != 1":        "(a ~= 1)",
+		"!a || b":       "(truthy((not truthy(a))) or truthy(b))",
+		"a / 2 + a % 3": "(idiv(a, 2) + math.fmod(a, 3))",
+		"a << 2 | ~b":   "bit.bor(bit.lshift(a, 2), bit.bnot(b))",
+		"a ^ 1 >= -b":   "bit.bxor(a, (1 >= (-b)))",
+		`name == "ok"`:  `(name == "ok")`,
+	}
+	for src, want := range cases {
+		got, err := luaExpr(expression.MustParse(src), field)
+		require.NoError(t, err, src)
+		require.Equal(t, want, got, src)
+	}
+}
@@ -0,0 +1,35 @@
+// ABOUTME: ZeroCopy option: string fields decode as runtime.ByteString views of the input
+// ABOUTME: Decoded values are only valid while the input is unchanged; Clone() copies them out of it
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// markZeroCopyStrings sets ZeroCopy on every UTF-8 and ASCII string field and string
+// array item; other encodings are converted when decoded, so they can't be views
+func markZeroCopyStrings(schema *Schema) {
+	var mark func(field *Field)
+	mark = func(field *Field) {
+		if field.Type == "string" && !field.Lazy && field.Transform == nil && field.Codec == "" && (field.Encoding == "" || field.Encoding == "utf8" || field.Encoding == "ascii") {
+			field.ZeroCopy = true
+		}
+		if field.Items != nil {
+			mark(field.Items)
+		}
+	}
+	for _, typeDef := range schema.Types {
+		for _, field := range typeDef.fieldPointers() {
+			mark(field)
+		}
+	}
+}
+
+// generateReadView emits a read of a string's bytes as a view of the input
+func generateReadView(buf *bytes.Buffer, bytesVar, call, indent string) {
+	buf.WriteString(fmt.Sprintf("%s%s, err := decoder.%s\n", indent, bytesVar, call))
+	buf.WriteString(fmt.Sprintf("%sif err != nil {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s\treturn nil, err\n", indent))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
@@ -0,0 +1,646 @@
+// ABOUTME: Schema-driven encoder/decoder that walks a schema at runtime instead of generating code
+// ABOUTME: Handles schemas loaded at runtime and serves as the fast reference executor for test vectors
+
+package binschema
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aeolun/json5"
+	"github.com/serialexp/binschema/expression"
+	"github.com/serialexp/binschema/runtime"
+)
+
+// Dynamic encodes and decodes values for a schema by walking its type definitions.
+// It is safe for concurrent use.
+//
+// Values use the same shapes as the JSON test vectors: structs are
+// map[string]interface{}, arrays are []interface{}, discriminated unions are
+// {"type": Variant, "value": ...} and choices are the variant's fields plus "type".
+// Numbers may be any Go numeric type on encode; decode returns the natural Go
+// type for each wire type (uint8, int32, float64, ...).
+type Dynamic struct {
+	types      map[string]interface{}
+	endianness runtime.Endianness
+	bitOrder   runtime.BitOrder
+	utf8       runtime.UTF8Policy         // The config's invalid_utf8: what decoding does with invalid UTF-8
+	exprs      map[string]expression.Node // Parsed by CompileSchemaMap, read-only afterwards
+	backrefs   map[string]bool            // Types targeted by back_reference (their positions are recorded on encode)
+
+	mu       sync.Mutex
+	generics map[string]map[string]interface{}
+}
+
+// CompileSchema parses a JSON (or JSON5) schema and prepares it for encoding and decoding
+func CompileSchema(schemaJSON []byte) (*Dynamic, error) {
+	var schema map[string]interface{}
+	if err := json5.Unmarshal(schemaJSON, &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+	return CompileSchemaMap(schema)
+}
+
+// CompileSchemaMap prepares an already-parsed schema for encoding and decoding.
+// Expressions (conditionals, union "when" clauses, count expressions) are parsed
+// up front so syntax errors surface here rather than on first use.
+func CompileSchemaMap(schema map[string]interface{}) (*Dynamic, error) {
+	types, ok := schema["types"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema has no types")
+	}
+
+	d := &Dynamic{
+		types:      types,
+		endianness: runtime.BigEndian,
+		bitOrder:   runtime.MSBFirst,
+		exprs:      make(map[string]expression.Node),
+		backrefs:   make(map[string]bool),
+		generics:   make(map[string]map[string]interface{}),
+	}
+	if config, ok := schema["config"].(map[string]interface{}); ok {
+		if e, _ := config["endianness"].(string); e == "little_endian" {
+			d.endianness = runtime.LittleEndian
+		}
+		if o, _ := config["bit_order"].(string); o == "lsb_first" {
+			d.bitOrder = runtime.LSBFirst
+		}
+		switch config["invalid_utf8"] {
+		case "reject":
+			d.utf8 = runtime.UTF8Reject
+		case "replace":
+			d.utf8 = runtime.UTF8Replace
+		}
+	}
+	for name, raw := range types {
+		def, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("type %s is not an object", name)
+		}
+		if def["type"] == "back_reference" {
+			if target, ok := def["target_type"].(string); ok {
+				d.backrefs[target] = true
+			}
+		}
+		if err := d.parseExpressions(def); err != nil {
+			return nil, fmt.Errorf("type %s: %w", name, err)
+		}
+	}
+	return d, nil
+}
+
+// parseExpressions parses every expression in a definition into the expression cache
+func (d *Dynamic) parseExpressions(v interface{}) error {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for key, val := range x {
+			if src, isString := val.(string); isString && (key == "conditional" || key == "when" || key == "count_expr") {
+				node, err := expression.Parse(src)
+				if err != nil {
+					return fmt.Errorf("invalid expression %q: %w", src, err)
+				}
+				d.exprs[src] = node
+				continue
+			}
+			if err := d.parseExpressions(val); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, val := range x {
+			if err := d.parseExpressions(val); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Encode encodes a value as the named type
+func (d *Dynamic) Encode(typeName string, value interface{}) ([]byte, error) {
+	r := &encodeRun{dyn: d, enc: runtime.NewBitStreamEncoder(d.bitOrder), positions: make(map[string]int)}
+	if err := r.typeRef(typeName, value, nil); err != nil {
+		return nil, err
+	}
+	return r.enc.Finish(), nil
+}
+
+// Decode decodes bytes as the named type
+func (d *Dynamic) Decode(typeName string, data []byte) (interface{}, error) {
+	dec := runtime.NewBitStreamDecoder(data, d.bitOrder)
+	r := &decodeRun{dyn: d, dec: dec, root: dec}
+	return r.typeRef(typeName, nil)
+}
+
+// UnsupportedError reports schema features the dynamic encoder/decoder does not implement
+type UnsupportedError struct {
+	Feature string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("dynamic codec does not support %s", e.Feature)
+}
+
+// scope holds the field values visible to expressions and field references
+type scope struct {
+	values map[string]interface{}
+	fields []interface{} // Definitions of the struct's fields
+	parent *scope
+}
+
+// fieldDef finds the definition of a sibling or ancestor field ("name", "../name")
+func (s *scope) fieldDef(path string) map[string]interface{} {
+	current := s
+	for strings.HasPrefix(path, "../") && current != nil {
+		current = current.parent
+		path = path[3:]
+	}
+	if current == nil || strings.Contains(path, ".") {
+		return nil
+	}
+	for _, raw := range current.fields {
+		if field, ok := raw.(map[string]interface{}); ok && field["name"] == path {
+			return field
+		}
+	}
+	return nil
+}
+
+// resolve looks up a field path. Supports "../" to go up a level, "_root." for the
+// outermost struct, and dotted paths into nested structs. Plain names fall back to
+// enclosing structs, like the context lookup in generated code.
+func (s *scope) resolve(path string) (interface{}, bool) {
+	current := s
+	if strings.HasPrefix(path, "_root.") {
+		for current != nil && current.parent != nil {
+			current = current.parent
+		}
+		path = strings.TrimPrefix(path, "_root.")
+	}
+	explicitParent := false
+	for strings.HasPrefix(path, "../") {
+		if current != nil {
+			current = current.parent
+		}
+		path = path[3:]
+		explicitParent = true
+	}
+
+	parts := strings.Split(path, ".")
+	for ; current != nil; current = current.parent {
+		if v, ok := lookupPath(current.values, parts); ok {
+			return v, true
+		}
+		if explicitParent {
+			break
+		}
+	}
+	return nil, false
+}
+
+func lookupPath(values map[string]interface{}, parts []string) (interface{}, bool) {
+	var v interface{} = values
+	for _, part := range parts {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if inner, isUnion := m["value"].(map[string]interface{}); isUnion && m["type"] != nil {
+			if _, direct := m[part]; !direct {
+				m = inner
+			}
+		}
+		v, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// expr returns a parsed expression. All schema expressions are parsed at compile
+// time; anything else is parsed on each call so the cache is never written concurrently.
+func (d *Dynamic) expr(src string) (expression.Node, error) {
+	if node, ok := d.exprs[src]; ok {
+		return node, nil
+	}
+	node, err := expression.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", src, err)
+	}
+	return node, nil
+}
+
+// condition evaluates a field's "conditional" expression (true if the field has none)
+func (d *Dynamic) condition(field map[string]interface{}, s *scope) (bool, error) {
+	cond, ok := field["conditional"].(string)
+	if !ok {
+		return true, nil
+	}
+	node, err := d.expr(cond)
+	if err != nil {
+		return false, err
+	}
+	present, err := expression.EvalBool(node, s.resolve)
+	// A condition on a field of an absent struct (e.g. "header.flags" without a header) is false
+	var exprErr *expression.Error
+	if errors.As(err, &exprErr) && exprErr.Kind == expression.ErrUndefinedField {
+		return false, nil
+	}
+	return present, err
+}
+
+// variantMatches evaluates a discriminated union "when" clause against a discriminator value.
+// A variant without "when" is the fallback and always matches.
+func (d *Dynamic) variantMatches(variant map[string]interface{}, discriminator interface{}) (bool, error) {
+	when, ok := variant["when"].(string)
+	if !ok {
+		return true, nil
+	}
+	node, err := d.expr(when)
+	if err != nil {
+		return false, err
+	}
+	return expression.EvalBool(node, func(path string) (interface{}, bool) {
+		if path == "value" {
+			return discriminator, true
+		}
+		return nil, false
+	})
+}
+
+func (d *Dynamic) fieldEndianness(def map[string]interface{}) runtime.Endianness {
+	switch def["endianness"] {
+	case "little_endian":
+		return runtime.LittleEndian
+	case "big_endian":
+		return runtime.BigEndian
+	}
+	return d.endianness
+}
+
+// typeDef returns a named type definition
+func (d *Dynamic) typeDef(name string) (map[string]interface{}, error) {
+	def, ok := d.types[name].(map[string]interface{})
+	if !ok {
+		def, ok = d.instantiate(name)
+		if !ok {
+			return nil, fmt.Errorf("type %s not found in schema", name)
+		}
+	}
+	if instances, ok := def["instances"].([]interface{}); ok && len(instances) > 0 {
+		return nil, &UnsupportedError{Feature: "instance fields"}
+	}
+	if protobuf, _ := def["protobuf"].(bool); protobuf {
+		return nil, &UnsupportedError{Feature: "protobuf messages"}
+	}
+	return def, nil
+}
+
+// instantiate resolves a generic type reference such as "Optional<uint64>" against a
+// template like "Optional<T>" by substituting the type parameters throughout its definition
+func (d *Dynamic) instantiate(name string) (map[string]interface{}, bool) {
+	open := strings.Index(name, "<")
+	if open < 0 || !strings.HasSuffix(name, ">") {
+		return nil, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if def, ok := d.generics[name]; ok {
+		return def, true
+	}
+	base := name[:open]
+	args := strings.Split(name[open+1:len(name)-1], ",")
+
+	for templateName, raw := range d.types {
+		if !strings.HasPrefix(templateName, base+"<") || !strings.HasSuffix(templateName, ">") {
+			continue
+		}
+		params := strings.Split(templateName[len(base)+1:len(templateName)-1], ",")
+		if len(params) != len(args) {
+			continue
+		}
+		bindings := make(map[string]string, len(params))
+		for i, param := range params {
+			bindings[strings.TrimSpace(param)] = strings.TrimSpace(args[i])
+		}
+		def, _ := substituteTypeParams(raw, bindings).(map[string]interface{})
+		d.generics[name] = def
+		return def, def != nil
+	}
+	return nil, false
+}
+
+// substituteTypeParams deep-copies a definition, replacing "type" values bound to type parameters
+func substituteTypeParams(v interface{}, bindings map[string]string) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(x))
+		for k, val := range x {
+			if t, isString := val.(string); isString && (k == "type" || k == "value_type" || k == "target_type") {
+				if bound, ok := bindings[t]; ok {
+					out[k] = bound
+					continue
+				}
+			}
+			out[k] = substituteTypeParams(val, bindings)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, val := range x {
+			out[i] = substituteTypeParams(val, bindings)
+		}
+		return out
+	}
+	return v
+}
+
+// sequenceOf returns a struct's fields, or nil for aliases, enums and unions
+func sequenceOf(def map[string]interface{}) []interface{} {
+	seq, _ := def["sequence"].([]interface{})
+	return seq
+}
+
+// itemsOf returns the element definition of an array
+func itemsOf(def map[string]interface{}) (map[string]interface{}, error) {
+	items, ok := def["items"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("array missing items definition")
+	}
+	return items, nil
+}
+
+// valueTypeOf returns the element definition wrapped by an optional
+func valueTypeOf(def map[string]interface{}) (map[string]interface{}, error) {
+	switch vt := def["value_type"].(type) {
+	case string:
+		return map[string]interface{}{"type": vt}, nil
+	case map[string]interface{}:
+		return vt, nil
+	}
+	return nil, fmt.Errorf("optional field missing value_type")
+}
+
+// stringKind normalizes a string definition's kind (fixed with length_field means field_referenced)
+func stringKind(def map[string]interface{}) string {
+	kind, _ := def["kind"].(string)
+	if kind == "fixed" && def["length_field"] != nil && def["length"] == nil {
+		return "field_referenced"
+	}
+	return kind
+}
+
+func intAttrDefault(def map[string]interface{}, key string, fallback int) int {
+	switch v := def[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	}
+	return fallback
+}
+
+// Value conversion helpers accept any Go numeric type (JSON float64, BigInt int64/uint64, decoded uintN)
+
+func toUint64(v interface{}) (uint64, error) {
+	switch x := v.(type) {
+	case uint64:
+		return x, nil
+	case uint8:
+		return uint64(x), nil
+	case uint16:
+		return uint64(x), nil
+	case uint32:
+		return uint64(x), nil
+	case uint:
+		return uint64(x), nil
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	i, err := toInt64(v)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(i), nil
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch x := v.(type) {
+	case int64:
+		return x, nil
+	case int:
+		return int64(x), nil
+	case int8:
+		return int64(x), nil
+	case int16:
+		return int64(x), nil
+	case int32:
+		return int64(x), nil
+	case uint8:
+		return int64(x), nil
+	case uint16:
+		return int64(x), nil
+	case uint32:
+		return int64(x), nil
+	case uint64:
+		return int64(x), nil
+	case uint:
+		return int64(x), nil
+	case float64:
+		if x != math.Trunc(x) {
+			return 0, fmt.Errorf("%v is not an integer", x)
+		}
+		if x >= math.MaxInt64 {
+			return int64(uint64(x)), nil
+		}
+		return int64(x), nil
+	case float32:
+		return toInt64(float64(x))
+	case nil:
+		return 0, fmt.Errorf("missing value")
+	}
+	return 0, fmt.Errorf("expected number, got %T", v)
+}
+
+// Units a unix timestamp can count
+var timestampUnits = map[string]time.Duration{"s": time.Second, "ms": time.Millisecond, "us": time.Microsecond, "ns": time.Nanosecond}
+
+// timestampScale returns the unit and epoch of a unix timestamp definition
+func timestampScale(def map[string]interface{}) (time.Duration, time.Time, error) {
+	name, _ := def["unit"].(string)
+	if name == "" {
+		name = "s"
+		if def["type"] == "unix_milli" {
+			name = "ms"
+		}
+	}
+	unit, ok := timestampUnits[name]
+	if !ok {
+		return 0, time.Time{}, fmt.Errorf("unknown timestamp unit %q", name)
+	}
+	epoch := runtime.UnixEpoch
+	if s, ok := def["epoch"].(string); ok {
+		var err error
+		if epoch, err = time.Parse(time.RFC3339Nano, s); err != nil {
+			return 0, time.Time{}, fmt.Errorf("epoch %q is not an RFC 3339 time", s)
+		}
+	}
+	return unit, epoch, nil
+}
+
+// toBigInt accepts 128-bit values as a big.Int, a decimal string (JSON numbers this large
+// lose precision) or any smaller number
+func toBigInt(v interface{}) (*big.Int, error) {
+	switch x := v.(type) {
+	case *big.Int:
+		return x, nil
+	case runtime.Uint128:
+		return x.Big(), nil
+	case runtime.Int128:
+		return x.Big(), nil
+	case string:
+		n, ok := new(big.Int).SetString(x, 10)
+		if !ok {
+			return nil, fmt.Errorf("%q is not an integer", x)
+		}
+		return n, nil
+	case uint64:
+		return new(big.Int).SetUint64(x), nil
+	case float64:
+		if x != math.Trunc(x) || math.IsInf(x, 0) {
+			return nil, fmt.Errorf("%v is not an integer", x)
+		}
+		n, _ := big.NewFloat(x).Int(nil)
+		return n, nil
+	}
+	i, err := toInt64(v)
+	if err != nil {
+		return nil, err
+	}
+	return big.NewInt(i), nil
+}
+
+func toFloat64(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case float32:
+		return float64(x), nil
+	case nil:
+		// JSON has no Infinity; the test vectors use null for it
+		return math.Inf(1), nil
+	}
+	i, err := toInt64(v)
+	return float64(i), err
+}
+
+// checkRange rejects integers that don't fit in the wire type
+func checkRange(typeName string, v interface{}) error {
+	var lo, hi float64
+	switch typeName {
+	case "uint8":
+		lo, hi = 0, math.MaxUint8
+	case "uint16":
+		lo, hi = 0, math.MaxUint16
+	case "uint32":
+		lo, hi = 0, math.MaxUint32
+	case "int8":
+		lo, hi = math.MinInt8, math.MaxInt8
+	case "int16":
+		lo, hi = math.MinInt16, math.MaxInt16
+	case "int32":
+		lo, hi = math.MinInt32, math.MaxInt32
+	default:
+		return nil
+	}
+	n, err := toInt64(v)
+	if err != nil {
+		return err
+	}
+	if float64(n) < lo || float64(n) > hi {
+		return fmt.Errorf("value %d out of range for %s", n, typeName)
+	}
+	return nil
+}
+
+// constEqual compares a schema const with a decoded value
+func constEqual(constVal, value interface{}) bool {
+	if s, isString := constVal.(string); isString {
+		v, ok := value.(string)
+		return ok && s == v
+	}
+	return NumericEqual(constVal, value)
+}
+
+// NumericEqual compares two numbers of any Go numeric type exactly, so decoded values
+// (uint8, int64, ...) can be checked against inputs such as JSON float64s
+func NumericEqual(a, b interface{}) bool {
+	fa, aIsFloat := floatOf(a)
+	fb, bIsFloat := floatOf(b)
+	if (aIsFloat && math.IsNaN(fa)) || (bIsFloat && math.IsNaN(fb)) {
+		return aIsFloat && bIsFloat && math.IsNaN(fa) && math.IsNaN(fb)
+	}
+	x, ok := bigValue(a)
+	if !ok {
+		return false
+	}
+	y, ok := bigValue(b)
+	if !ok {
+		return false
+	}
+	return x.Cmp(y) == 0
+}
+
+func bigValue(v interface{}) (*big.Float, bool) {
+	f := new(big.Float).SetPrec(128)
+	switch x := v.(type) {
+	case float64:
+		return f.SetFloat64(x), true
+	case float32:
+		return f.SetFloat64(float64(x)), true
+	case uint64:
+		return f.SetUint64(x), true
+	case uint:
+		return f.SetUint64(uint64(x)), true
+	case uint8:
+		return f.SetUint64(uint64(x)), true
+	case uint16:
+		return f.SetUint64(uint64(x)), true
+	case uint32:
+		return f.SetUint64(uint64(x)), true
+	case int64:
+		return f.SetInt64(x), true
+	case int:
+		return f.SetInt64(int64(x)), true
+	case int8:
+		return f.SetInt64(int64(x)), true
+	case int16:
+		return f.SetInt64(int64(x)), true
+	case int32:
+		return f.SetInt64(int64(x)), true
+	case *big.Int:
+		return f.SetInt(x), true
+	}
+	return nil, false
+}
+
+func floatOf(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	}
+	return 0, false
+}
@@ -0,0 +1,750 @@
+// ABOUTME: Decoding half of the dynamic (schema-walking) encoder/decoder
+// ABOUTME: Reads values from a runtime.BitStreamDecoder by walking field definitions
+
+package binschema
+
+import (
+	"fmt"
+	"unicode/utf16"
+
+	"github.com/serialexp/binschema/expression"
+	"github.com/serialexp/binschema/runtime"
+)
+
+type decodeRun struct {
+	dyn  *Dynamic
+	dec  *runtime.BitStreamDecoder // Current decoder (a sub-decoder inside byte-bounded regions)
+	root *runtime.BitStreamDecoder // Whole-message decoder, for back_reference offsets
+}
+
+func (r *decodeRun) typeRef(name string, s *scope) (interface{}, error) {
+	def, err := r.dyn.typeDef(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.dec.EnterNested(); err != nil {
+		return nil, err
+	}
+	defer r.dec.ExitNested()
+
+	if seq := sequenceOf(def); seq != nil {
+		return r.sequence(seq, s)
+	}
+	return r.element(def, s)
+}
+
+func (r *decodeRun) sequence(fields []interface{}, parent *scope) (interface{}, error) {
+	values := make(map[string]interface{}, len(fields))
+	s := &scope{values: values, fields: fields, parent: parent}
+
+	for _, raw := range fields {
+		field, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := field["name"].(string)
+
+		present, err := r.dyn.condition(field, s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if !present {
+			continue
+		}
+
+		value, err := r.element(field, s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if constVal, hasConst := field["const"]; hasConst && !constEqual(constVal, value) {
+			return nil, fmt.Errorf("%s: expected constant %v, got %v", name, constVal, value)
+		}
+		if field["type"] == "padding" {
+			continue
+		}
+		if field["type"] == "optional" && value == nil {
+			continue
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+func (r *decodeRun) element(def map[string]interface{}, s *scope) (interface{}, error) {
+	elemType, _ := def["type"].(string)
+	endianness := r.dyn.fieldEndianness(def)
+	dec := r.dec
+
+	switch elemType {
+	case "uint8", "uint16", "uint32", "uint64", "int8", "int16", "int32", "int64":
+		return readInteger(dec, elemType, endianness)
+	case "uint128":
+		n, err := dec.ReadUint128(endianness)
+		return n.Big(), err
+	case "int128":
+		n, err := dec.ReadInt128(endianness)
+		return n.Big(), err
+	case "uuid":
+		return dec.ReadUUID()
+	case "mac":
+		return dec.ReadMAC()
+	case "ipv4":
+		return dec.ReadIPv4()
+	case "ipv6":
+		return dec.ReadIPv6()
+	case "bcd", "packed_bcd":
+		digits := intAttrDefault(def, "digits", 0)
+		if def["decode_as"] == "string" {
+			return dec.ReadBCD(digits, elemType == "packed_bcd")
+		}
+		return dec.ReadBCDInt(digits, elemType == "packed_bcd")
+	case "ntp":
+		n, err := dec.ReadUint64(endianness)
+		return runtime.NTPTime(n), err
+	case "unix32", "unix64", "unix_milli":
+		unit, epoch, err := timestampScale(def)
+		if err != nil {
+			return nil, err
+		}
+		var n int64
+		if elemType == "unix32" {
+			v, err := dec.ReadUint32(endianness)
+			if err != nil {
+				return nil, err
+			}
+			n = int64(v)
+		} else if n, err = dec.ReadInt64(endianness); err != nil {
+			return nil, err
+		}
+		return runtime.UnixTime(n, unit, epoch), nil
+	case "float32":
+		return dec.ReadFloat32(endianness)
+	case "float64":
+		return dec.ReadFloat64(endianness)
+	case "bool":
+		b, err := dec.ReadUint8()
+		return b != 0, err
+	case "bit":
+		size := intAttrDefault(def, "size", 1)
+		n, err := dec.ReadBits(size)
+		if err != nil {
+			return nil, err
+		}
+		if signed, _ := def["signed"].(bool); signed {
+			return signExtend(n, size), nil
+		}
+		return n, nil
+	case "int":
+		size := intAttrDefault(def, "size", 1)
+		n, err := dec.ReadBits(size)
+		if err != nil {
+			return nil, err
+		}
+		if signed, isSet := def["signed"].(bool); isSet && !signed {
+			return n, nil
+		}
+		return signExtend(n, size), nil
+	case "bitfield":
+		result := make(map[string]interface{})
+		subfields, _ := def["fields"].([]interface{})
+		for _, raw := range subfields {
+			sub, _ := raw.(map[string]interface{})
+			name, _ := sub["name"].(string)
+			n, err := dec.ReadBits(intAttrDefault(sub, "size", 1))
+			if err != nil {
+				return nil, err
+			}
+			result[name] = n
+		}
+		return result, nil
+	case "struct":
+		fields, _ := def["fields"].([]interface{})
+		return r.sequence(fields, s)
+	case "varlength":
+		return readVarlength(dec, def["encoding"])
+	case "string":
+		return r.stringValue(def, s)
+	case "bytes":
+		items, err := r.array(def, map[string]interface{}{"type": "uint8"}, s)
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, len(items))
+		for i, item := range items {
+			data[i] = item.(uint8)
+		}
+		return data, nil
+	case "array":
+		itemDef, err := itemsOf(def)
+		if err != nil {
+			return nil, err
+		}
+		items, err := r.array(def, itemDef, s)
+		if err != nil {
+			return nil, err
+		}
+		return items, nil
+	case "optional":
+		valueDef, err := valueTypeOf(def)
+		if err != nil {
+			return nil, err
+		}
+		presence := map[string]interface{}{"type": "uint8"}
+		if pt, ok := def["presence_type"].(string); ok {
+			presence = map[string]interface{}{"type": pt, "size": float64(1)}
+		}
+		flag, err := r.element(presence, s)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := toUint64(flag); n == 0 {
+			return nil, nil
+		}
+		return r.element(valueDef, s)
+	case "padding":
+		align := intAttrDefault(def, "align_to", 1)
+		for dec.Position()%align != 0 {
+			if _, err := dec.ReadUint8(); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case "enum":
+		repr, _ := def["repr"].(string)
+		value, err := readInteger(dec, repr, endianness)
+		if err != nil {
+			return nil, err
+		}
+		variants, _ := def["variants"].(map[string]interface{})
+		for _, v := range variants {
+			if NumericEqual(v, value) {
+				return value, nil
+			}
+		}
+		errCode := runtime.ErrorInvalidValue
+		dec.LastErrorCode = &errCode
+		return nil, fmt.Errorf("invalid enum value %v", value)
+	case "flags":
+		repr, _ := def["repr"].(string)
+		return readInteger(dec, repr, endianness)
+	case "discriminated_union":
+		return r.union(def, s)
+	case "choice":
+		return r.choice(def, s)
+	case "back_reference":
+		return r.backReference(def, s)
+	case "":
+		return nil, fmt.Errorf("element has no type")
+	}
+	return r.typeRef(elemType, s)
+}
+
+func signExtend(n uint64, size int) int64 {
+	if size < 64 && n&(uint64(1)<<(size-1)) != 0 {
+		return int64(n | ^bitMask(size))
+	}
+	return int64(n)
+}
+
+func readInteger(dec *runtime.BitStreamDecoder, intType string, endianness runtime.Endianness) (interface{}, error) {
+	switch intType {
+	case "uint8":
+		return dec.ReadUint8()
+	case "uint16":
+		return dec.ReadUint16(endianness)
+	case "uint32":
+		return dec.ReadUint32(endianness)
+	case "uint64":
+		return dec.ReadUint64(endianness)
+	case "int8":
+		return dec.ReadInt8()
+	case "int16":
+		return dec.ReadInt16(endianness)
+	case "int32":
+		return dec.ReadInt32(endianness)
+	case "int64":
+		return dec.ReadInt64(endianness)
+	}
+	return nil, fmt.Errorf("unsupported integer type %q", intType)
+}
+
+func readVarlength(dec *runtime.BitStreamDecoder, encoding interface{}) (uint64, error) {
+	switch encoding {
+	case "der", nil:
+		return dec.ReadVarlengthDER()
+	case "leb128":
+		return dec.ReadVarlengthLEB128()
+	case "ebml":
+		return dec.ReadVarlengthEBML()
+	case "vlq":
+		return dec.ReadVarlengthVLQ()
+	}
+	return 0, fmt.Errorf("unknown varlength encoding %v", encoding)
+}
+
+func (r *decodeRun) readLength(def map[string]interface{}, lengthTypeKey string) (int, error) {
+	lengthType, _ := def[lengthTypeKey].(string)
+	if lengthType == "" {
+		lengthType = "uint8"
+	}
+	var n interface{}
+	var err error
+	if lengthType == "varlength" {
+		n, err = readVarlength(r.dec, def["length_encoding"])
+	} else {
+		n, err = readInteger(r.dec, lengthType, r.dyn.endianness)
+	}
+	if err != nil {
+		return 0, err
+	}
+	length, err := toInt64(n)
+	if err != nil {
+		return 0, err
+	}
+	if length < 0 || length > int64(r.dec.Len()-r.dec.Position()) {
+		return 0, fmt.Errorf("length %d exceeds remaining data", length)
+	}
+	return int(length), nil
+}
+
+// referencedLength resolves a length_field reference to a non-negative count
+func referencedLength(def map[string]interface{}, s *scope) (int, error) {
+	lengthField, _ := def["length_field"].(string)
+	v, ok := s.resolve(lengthField)
+	if !ok {
+		return 0, fmt.Errorf("length field %q not found", lengthField)
+	}
+	n, err := toInt64(v)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("negative length %d from %q", n, lengthField)
+	}
+	return int(n), nil
+}
+
+func (r *decodeRun) decodeString(data []byte, def map[string]interface{}, endianness runtime.Endianness) (string, error) {
+	switch def["encoding"] {
+	case "utf16":
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			if endianness == runtime.BigEndian {
+				units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+			} else {
+				units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+			}
+		}
+		return string(utf16.Decode(units)), nil
+	case "ascii", "latin1":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes), nil
+	}
+	// Invalid UTF-8 is kept, rejected or replaced as the config's invalid_utf8 says; the
+	// field is named by the caller's error
+	return r.dec.UTF8String("", data, r.dyn.utf8)
+}
+
+func (r *decodeRun) stringValue(def map[string]interface{}, s *scope) (interface{}, error) {
+	endianness := r.dyn.fieldEndianness(def)
+	utf16Encoded := def["encoding"] == "utf16"
+
+	switch stringKind(def) {
+	case "length_prefixed":
+		n, err := r.readLength(def, "length_type")
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.dec.ReadBytesSlice(n)
+		if err != nil {
+			return nil, err
+		}
+		return r.decodeString(data, def, endianness)
+	case "null_terminated":
+		var data []byte
+		maxLength := intAttrDefault(def, "max_length", 0)
+		for {
+			if maxLength > 0 && len(data) > maxLength {
+				errCode := runtime.ErrorSchemaMismatch
+				r.dec.LastErrorCode = &errCode
+				return nil, fmt.Errorf("no terminator within max_length of %d bytes", maxLength)
+			}
+			b, err := r.dec.ReadUint8()
+			if err != nil {
+				return nil, err
+			}
+			if !utf16Encoded {
+				if b == 0 {
+					break
+				}
+				data = append(data, b)
+				continue
+			}
+			b2, err := r.dec.ReadUint8()
+			if err != nil {
+				return nil, err
+			}
+			if b == 0 && b2 == 0 {
+				break
+			}
+			data = append(data, b, b2)
+		}
+		return r.decodeString(data, def, endianness)
+	case "fixed":
+		data, err := r.dec.ReadBytesSlice(intAttrDefault(def, "length", 0))
+		if err != nil {
+			return nil, err
+		}
+		return r.decodeString(trimPadding(data, def, endianness), def, endianness)
+	case "field_referenced":
+		n, err := referencedLength(def, s)
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.dec.ReadBytesSlice(n)
+		if err != nil {
+			return nil, err
+		}
+		return r.decodeString(data, def, endianness)
+	}
+	return nil, &UnsupportedError{Feature: fmt.Sprintf("string kind %v", def["kind"])}
+}
+
+// stringPadding returns the code unit a fixed-length string is padded with, and whether
+// decoding trims it from the end, as generated code does: NULs by default, spaces with
+// padding: "space", and nothing with padding: "none"
+func stringPadding(def map[string]interface{}) (uint16, bool) {
+	switch def["padding"] {
+	case "space":
+		return ' ', true
+	case "none":
+		return 0, false
+	}
+	return 0, true
+}
+
+// trimPadding removes a fixed-length string's padding from the end of its bytes, whole
+// code units at a time for UTF-16; padding inside the value is kept
+func trimPadding(data []byte, def map[string]interface{}, endianness runtime.Endianness) []byte {
+	pad, trims := stringPadding(def)
+	if !trims {
+		return data
+	}
+	if def["encoding"] != "utf16" {
+		for len(data) > 0 && data[len(data)-1] == byte(pad) {
+			data = data[:len(data)-1]
+		}
+		return data
+	}
+	data = data[:len(data)&^1]
+	for len(data) >= 2 {
+		last := data[len(data)-2:]
+		unit := uint16(last[1])<<8 | uint16(last[0])
+		if endianness == runtime.BigEndian {
+			unit = uint16(last[0])<<8 | uint16(last[1])
+		}
+		if unit != pad {
+			break
+		}
+		data = data[:len(data)-2]
+	}
+	return data
+}
+
+func (r *decodeRun) array(def, itemDef map[string]interface{}, s *scope) ([]interface{}, error) {
+	kind, _ := def["kind"].(string)
+	items := []interface{}{}
+
+	readN := func(n int) ([]interface{}, error) {
+		for i := 0; i < n; i++ {
+			item, err := r.element(itemDef, s)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+
+	switch kind {
+	case "fixed":
+		return readN(intAttrDefault(def, "length", 0))
+	case "length_prefixed":
+		n, err := r.readLength(def, "length_type")
+		if err != nil {
+			return nil, err
+		}
+		return readN(n)
+	case "length_prefixed_items":
+		n, err := r.readLength(def, "length_type")
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			size, err := r.readLength(def, "item_length_type")
+			if err != nil {
+				return nil, err
+			}
+			item, err := r.bounded(size, func() (interface{}, error) { return r.element(itemDef, s) })
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case "byte_length_prefixed":
+		size, err := r.readLength(def, "length_type")
+		if err != nil {
+			return nil, err
+		}
+		_, err = r.bounded(size, func() (interface{}, error) {
+			for r.dec.Position() < r.dec.Len() {
+				item, err := r.element(itemDef, s)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+			return nil, nil
+		})
+		return items, err
+	case "field_referenced":
+		n, err := referencedLength(def, s)
+		if err != nil {
+			return nil, err
+		}
+		return readN(n)
+	case "computed_count":
+		countExpr, _ := def["count_expr"].(string)
+		node, err := r.dyn.expr(countExpr)
+		if err != nil {
+			return nil, err
+		}
+		n, err := expression.EvalInt(node, s.resolve)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("negative count %d from %q", n, countExpr)
+		}
+		return readN(int(n))
+	case "eof_terminated":
+		for r.dec.Position() < r.dec.Len() {
+			item, err := r.element(itemDef, s)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case "null_terminated":
+		terminal, _ := def["terminal_variants"].([]interface{})
+		for {
+			b, err := r.dec.PeekUint8()
+			if err != nil {
+				return nil, err
+			}
+			if b == 0 {
+				r.dec.SkipBytes(1)
+				return items, nil
+			}
+			item, err := r.element(itemDef, s)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			if isTerminalVariant(terminal, item) {
+				return items, nil
+			}
+		}
+	case "signature_terminated":
+		terminatorType, _ := def["terminator_type"].(string)
+		endianness := r.dyn.endianness
+		if e, ok := def["terminator_endianness"].(string); ok {
+			endianness = r.dyn.fieldEndianness(map[string]interface{}{"endianness": e})
+		}
+		for {
+			v, err := r.peekInteger(terminatorType, endianness)
+			if err != nil {
+				return nil, err
+			}
+			if NumericEqual(v, def["terminator_value"]) {
+				return items, nil
+			}
+			item, err := r.element(itemDef, s)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+	case "variant_terminated":
+		terminal, _ := def["terminal_variants"].([]interface{})
+		for {
+			item, err := r.element(itemDef, s)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			if isTerminalVariant(terminal, item) {
+				return items, nil
+			}
+		}
+	}
+	return nil, &UnsupportedError{Feature: fmt.Sprintf("array kind %q", kind)}
+}
+
+func (r *decodeRun) peekInteger(intType string, endianness runtime.Endianness) (interface{}, error) {
+	switch intType {
+	case "uint8":
+		return r.dec.PeekUint8()
+	case "uint16":
+		return r.dec.PeekUint16(endianness)
+	case "uint32":
+		return r.dec.PeekUint32(endianness)
+	case "uint64":
+		return r.dec.PeekUint64(endianness)
+	}
+	return nil, fmt.Errorf("unsupported peek type %q", intType)
+}
+
+// bounded runs a decode step restricted to the next size bytes, then skips past them
+func (r *decodeRun) bounded(size int, step func() (interface{}, error)) (interface{}, error) {
+	data, err := r.dec.ReadBytesSlice(size)
+	if err != nil {
+		return nil, err
+	}
+	outer := r.dec
+	r.dec = runtime.NewBitStreamDecoder(data, r.dyn.bitOrder)
+	defer func() { r.dec = outer }()
+	return step()
+}
+
+func (r *decodeRun) union(def map[string]interface{}, s *scope) (interface{}, error) {
+	disc, _ := def["discriminator"].(map[string]interface{})
+
+	var discriminator interface{}
+	var err error
+	if peek, ok := disc["peek"].(string); ok {
+		discriminator, err = r.peekInteger(peek, r.dyn.fieldEndianness(disc))
+		if err != nil {
+			return nil, err
+		}
+	} else if field, ok := disc["field"].(string); ok {
+		var found bool
+		discriminator, found = s.resolve(field)
+		if !found {
+			return nil, fmt.Errorf("discriminator field %q not found", field)
+		}
+	} else {
+		return nil, fmt.Errorf("discriminated union has no discriminator")
+	}
+
+	variants, _ := def["variants"].([]interface{})
+	for _, raw := range variants {
+		variant, _ := raw.(map[string]interface{})
+		matches, err := r.dyn.variantMatches(variant, discriminator)
+		if err != nil {
+			return nil, err
+		}
+		if !matches {
+			continue
+		}
+		variantType, _ := variant["type"].(string)
+		decodeVariant := func() (interface{}, error) { return r.typeRef(variantType, s) }
+
+		var value interface{}
+		if budget, ok := def["byte_budget"].(map[string]interface{}); ok {
+			n, err := referencedLength(map[string]interface{}{"length_field": budget["field"]}, s)
+			if err != nil {
+				return nil, err
+			}
+			value, err = r.bounded(n, decodeVariant)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			value, err = decodeVariant()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return map[string]interface{}{"type": variantType, "value": value}, nil
+	}
+	errCode := runtime.ErrorSchemaMismatch
+	r.dec.LastErrorCode = &errCode
+	return nil, fmt.Errorf("no variant matches discriminator %v", discriminator)
+}
+
+// choice picks the variant whose leading const field matches the next bytes
+func (r *decodeRun) choice(def map[string]interface{}, s *scope) (interface{}, error) {
+	choices, _ := def["choices"].([]interface{})
+	for _, raw := range choices {
+		c, _ := raw.(map[string]interface{})
+		name, _ := c["type"].(string)
+		typeDef, err := r.dyn.typeDef(name)
+		if err != nil {
+			return nil, err
+		}
+		seq := sequenceOf(typeDef)
+		if len(seq) == 0 {
+			continue
+		}
+		first, _ := seq[0].(map[string]interface{})
+		constVal, hasConst := first["const"]
+		if !hasConst {
+			return nil, fmt.Errorf("choice variant %s must start with a const field", name)
+		}
+		firstType, _ := first["type"].(string)
+		v, err := r.peekInteger(firstType, r.dyn.fieldEndianness(first))
+		if err != nil {
+			return nil, err
+		}
+		if !NumericEqual(v, constVal) {
+			continue
+		}
+		value, err := r.typeRef(name, s)
+		if err != nil {
+			return nil, err
+		}
+		result := value.(map[string]interface{})
+		result["type"] = name
+		return result, nil
+	}
+	return nil, fmt.Errorf("no choice variant matches")
+}
+
+// backReference follows a pointer to earlier data and decodes the target there
+func (r *decodeRun) backReference(def map[string]interface{}, s *scope) (interface{}, error) {
+	storage, _ := def["storage"].(string)
+	raw, err := readInteger(r.dec, storage, r.dyn.fieldEndianness(def))
+	if err != nil {
+		return nil, err
+	}
+	pointer, _ := toUint64(raw)
+	mask, err := backReferenceMask(def)
+	if err != nil {
+		return nil, err
+	}
+	offset := int(pointer & mask)
+	if offset >= r.root.Len() {
+		errCode := runtime.ErrorInvalidValue
+		r.dec.LastErrorCode = &errCode
+		return nil, fmt.Errorf("back_reference offset %d out of bounds", offset)
+	}
+
+	// Decode the target in place on the message decoder, which also keeps the
+	// nesting depth counting so pointer loops terminate
+	target, _ := def["target_type"].(string)
+	outer := r.dec
+	resume := r.root.Position()
+	r.dec = r.root
+	r.dec.Seek(offset)
+	defer func() {
+		r.root.Seek(resume)
+		r.dec = outer
+	}()
+	return r.typeRef(target, s)
+}
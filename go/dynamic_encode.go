@@ -0,0 +1,758 @@
+// ABOUTME: Encoding half of the dynamic (schema-walking) encoder/decoder
+// ABOUTME: Writes values to a runtime.BitStreamEncoder by walking field definitions
+
+package binschema
+
+import (
+	"fmt"
+	"math"
+	"net/netip"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/serialexp/binschema/runtime"
+)
+
+type encodeRun struct {
+	dyn       *Dynamic
+	enc       *runtime.BitStreamEncoder
+	positions map[string]int // back_reference targets already written (nil when measuring)
+	depth     int
+}
+
+// measure encodes a value into a scratch encoder and returns the bytes
+func (r *encodeRun) measure(def map[string]interface{}, value interface{}, s *scope) ([]byte, error) {
+	tmp := &encodeRun{dyn: r.dyn, enc: runtime.NewBitStreamEncoder(r.dyn.bitOrder), depth: r.depth}
+	if err := tmp.element(def, value, s); err != nil {
+		return nil, err
+	}
+	return tmp.enc.Finish(), nil
+}
+
+func (r *encodeRun) typeRef(name string, value interface{}, s *scope) error {
+	def, err := r.dyn.typeDef(name)
+	if err != nil {
+		return err
+	}
+
+	r.depth++
+	defer func() { r.depth-- }()
+	if r.depth > runtime.MaxNestingDepth {
+		return fmt.Errorf("maximum nesting depth of %d exceeded", runtime.MaxNestingDepth)
+	}
+
+	if r.positions != nil && r.dyn.backrefs[name] {
+		key := name + "\x00" + fmt.Sprint(value)
+		if _, seen := r.positions[key]; !seen {
+			r.positions[key] = r.enc.Position()
+		}
+	}
+
+	if seq := sequenceOf(def); seq != nil {
+		return r.sequence(seq, value, s)
+	}
+	return r.element(def, value, s)
+}
+
+func (r *encodeRun) sequence(fields []interface{}, value interface{}, parent *scope) error {
+	input, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected object, got %T", value)
+	}
+
+	// Work on a copy so computed values are visible to later fields without mutating the input
+	values := make(map[string]interface{}, len(input))
+	for k, v := range input {
+		values[k] = v
+	}
+	s := &scope{values: values, fields: fields, parent: parent}
+
+	for _, raw := range fields {
+		field, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := field["name"].(string)
+
+		present, err := r.dyn.condition(field, s)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if !present {
+			continue
+		}
+
+		fieldValue := values[name]
+		if constVal, hasConst := field["const"]; hasConst {
+			fieldValue = constVal
+		} else if computed, isComputed := field["computed"].(map[string]interface{}); isComputed {
+			fieldValue, err = r.computed(computed, s)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		values[name] = fieldValue
+
+		if err := r.element(field, fieldValue, s); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// computed derives the value of a computed field from the fields it describes
+func (r *encodeRun) computed(computed map[string]interface{}, s *scope) (interface{}, error) {
+	kind, _ := computed["type"].(string)
+	target, _ := computed["target"].(string)
+	offset := int64(intAttrDefault(computed, "offset", 0))
+
+	switch kind {
+	case "length_of":
+		if after, ok := computed["from_after_field"].(string); ok {
+			return r.lengthAfterField(after, s)
+		}
+		targetField := s.fieldDef(target)
+		value, ok := s.resolve(target)
+		if !ok {
+			return nil, fmt.Errorf("length_of target %q not found", target)
+		}
+		switch v := value.(type) {
+		case string:
+			if targetField != nil {
+				encoded, err := encodeString(v, targetField, r.dyn.fieldEndianness(targetField))
+				if err != nil {
+					return nil, err
+				}
+				return int64(len(encoded)) + offset, nil
+			}
+			return int64(len(v)) + offset, nil
+		case []interface{}:
+			if targetField != nil && targetField["type"] != "array" && targetField["type"] != "bytes" {
+				break // Alias of an array type: measure encoded size below
+			}
+			return int64(len(v)) + offset, nil
+		case []byte:
+			return int64(len(v)) + offset, nil
+		case map[string]interface{}:
+			// Composite target: its length is its encoded size
+		default:
+			n, err := toInt64(value)
+			if err != nil {
+				return nil, err
+			}
+			return n + offset, nil
+		}
+		if targetField == nil {
+			return nil, &UnsupportedError{Feature: fmt.Sprintf("length_of composite target %q outside the current struct", target)}
+		}
+		encoded, err := r.measure(targetField, value, s)
+		if err != nil {
+			return nil, err
+		}
+		return int64(len(encoded)) + offset, nil
+
+	case "crc32_of":
+		targetField := s.fieldDef(target)
+		value, ok := s.resolve(target)
+		if !ok || targetField == nil {
+			return nil, fmt.Errorf("crc32_of target %q not found", target)
+		}
+		encoded, err := r.measure(targetField, value, s)
+		if err != nil {
+			return nil, err
+		}
+		return uint64(runtime.CRC32(encoded)), nil
+	}
+	return nil, &UnsupportedError{Feature: fmt.Sprintf("computed %s", kind)}
+}
+
+// lengthAfterField measures every field after the named one (content-first length)
+func (r *encodeRun) lengthAfterField(after string, s *scope) (interface{}, error) {
+	fields := s.fields
+	start := -1
+	for i, raw := range fields {
+		if field, ok := raw.(map[string]interface{}); ok && field["name"] == after {
+			start = i + 1
+		}
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("from_after_field %q not found", after)
+	}
+	tmp := &encodeRun{dyn: r.dyn, enc: runtime.NewBitStreamEncoder(r.dyn.bitOrder), depth: r.depth}
+	if err := tmp.sequence(fields[start:], s.values, s.parent); err != nil {
+		return nil, err
+	}
+	return int64(len(tmp.enc.Finish())), nil
+}
+
+// element encodes a value described by a field or element definition
+func (r *encodeRun) element(def map[string]interface{}, value interface{}, s *scope) error {
+	elemType, _ := def["type"].(string)
+	endianness := r.dyn.fieldEndianness(def)
+	enc := r.enc
+
+	switch elemType {
+	case "uint8", "uint16", "uint32", "uint64", "int8", "int16", "int32", "int64":
+		if err := checkRange(elemType, value); err != nil {
+			return err
+		}
+		return writeInteger(enc, elemType, value, endianness)
+	case "uint128", "int128":
+		n, err := toBigInt(value)
+		if err != nil {
+			return err
+		}
+		if elemType == "int128" {
+			i, err := runtime.Int128FromBig(n)
+			if err != nil {
+				return err
+			}
+			enc.WriteInt128(i, endianness)
+		} else {
+			u, err := runtime.Uint128FromBig(n)
+			if err != nil {
+				return err
+			}
+			enc.WriteUint128(u, endianness)
+		}
+	case "uuid":
+		u, ok := value.(runtime.UUID)
+		if s, isString := value.(string); isString {
+			var err error
+			if u, err = runtime.ParseUUID(s); err != nil {
+				return err
+			}
+		} else if !ok {
+			return fmt.Errorf("expected UUID, got %T", value)
+		}
+		enc.WriteUUID(u)
+	case "mac":
+		m, ok := value.(runtime.MAC)
+		if s, isString := value.(string); isString {
+			var err error
+			if m, err = runtime.ParseMAC(s); err != nil {
+				return err
+			}
+		} else if !ok {
+			return fmt.Errorf("expected MAC address, got %T", value)
+		}
+		enc.WriteMAC(m)
+	case "ipv4", "ipv6":
+		addr, ok := value.(netip.Addr)
+		if s, isString := value.(string); isString {
+			var err error
+			if addr, err = netip.ParseAddr(s); err != nil {
+				return err
+			}
+		} else if !ok {
+			return fmt.Errorf("expected IP address, got %T", value)
+		}
+		if elemType == "ipv4" {
+			return enc.WriteIPv4(addr)
+		}
+		enc.WriteIPv6(addr)
+	case "bcd", "packed_bcd":
+		digits := intAttrDefault(def, "digits", 0)
+		if s, isString := value.(string); isString {
+			return enc.WriteBCD(s, digits, elemType == "packed_bcd")
+		}
+		n, err := toInt64(value)
+		if err != nil {
+			return err
+		}
+		return enc.WriteBCDInt(n, digits, elemType == "packed_bcd")
+	case "unix32", "unix64", "unix_milli", "ntp":
+		t, ok := value.(time.Time)
+		if s, isString := value.(string); isString {
+			var err error
+			if t, err = time.Parse(time.RFC3339Nano, s); err != nil {
+				return err
+			}
+		} else if !ok {
+			return fmt.Errorf("expected time, got %T", value)
+		}
+		if elemType == "ntp" {
+			n, ok := runtime.NTPTimestamp(t)
+			if !ok {
+				return fmt.Errorf("%s is outside NTP era 0 (1900 to 2036)", t)
+			}
+			enc.WriteUint64(n, endianness)
+			return nil
+		}
+		unit, epoch, err := timestampScale(def)
+		if err != nil {
+			return err
+		}
+		n := runtime.UnixUnits(t, unit, epoch)
+		if elemType != "unix32" {
+			enc.WriteInt64(n, endianness)
+		} else if n < 0 || n > math.MaxUint32 {
+			return fmt.Errorf("%s is outside the range of unix32", t)
+		} else {
+			enc.WriteUint32(uint32(n), endianness)
+		}
+	case "float32":
+		f, err := toFloat64(value)
+		if err != nil {
+			return err
+		}
+		enc.WriteFloat32(float32(f), endianness)
+	case "float64":
+		f, err := toFloat64(value)
+		if err != nil {
+			return err
+		}
+		enc.WriteFloat64(f, endianness)
+	case "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("expected bool, got %T", value)
+		}
+		if b {
+			enc.WriteUint8(1)
+		} else {
+			enc.WriteUint8(0)
+		}
+	case "bit", "int":
+		size := intAttrDefault(def, "size", 1)
+		n, err := toInt64(value)
+		if err != nil {
+			return err
+		}
+		enc.WriteBits(uint64(n)&bitMask(size), size)
+	case "bitfield":
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected bitfield object, got %T", value)
+		}
+		subfields, _ := def["fields"].([]interface{})
+		for _, raw := range subfields {
+			sub, _ := raw.(map[string]interface{})
+			name, _ := sub["name"].(string)
+			size := intAttrDefault(sub, "size", 1)
+			n, err := toUint64(m[name])
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			enc.WriteBits(n&bitMask(size), size)
+		}
+	case "struct":
+		fields, _ := def["fields"].([]interface{})
+		return r.sequence(fields, value, s)
+	case "varlength":
+		n, err := toUint64(value)
+		if err != nil {
+			return err
+		}
+		return writeVarlength(enc, def["encoding"], n)
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected string, got %T", value)
+		}
+		return r.stringValue(def, str, s)
+	case "bytes":
+		items, err := byteItems(value)
+		if err != nil {
+			return err
+		}
+		return r.array(def, map[string]interface{}{"type": "uint8"}, items, s)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			if value == nil {
+				items = nil
+			} else {
+				return fmt.Errorf("expected array, got %T", value)
+			}
+		}
+		itemDef, err := itemsOf(def)
+		if err != nil {
+			return err
+		}
+		return r.array(def, itemDef, items, s)
+	case "optional":
+		valueDef, err := valueTypeOf(def)
+		if err != nil {
+			return err
+		}
+		presence := map[string]interface{}{"type": "uint8"}
+		if pt, ok := def["presence_type"].(string); ok {
+			presence = map[string]interface{}{"type": pt, "size": float64(1)}
+		}
+		if value == nil {
+			return r.element(presence, uint64(0), s)
+		}
+		if err := r.element(presence, uint64(1), s); err != nil {
+			return err
+		}
+		return r.element(valueDef, value, s)
+	case "padding":
+		align := intAttrDefault(def, "align_to", 1)
+		for enc.Position()%align != 0 {
+			enc.WriteUint8(0)
+		}
+	case "enum":
+		if name, isName := value.(string); isName {
+			variants, _ := def["variants"].(map[string]interface{})
+			v, ok := variants[name]
+			if !ok {
+				return fmt.Errorf("unknown enum variant %q", name)
+			}
+			value = v
+		}
+		repr, _ := def["repr"].(string)
+		return writeInteger(enc, repr, value, endianness)
+	case "flags":
+		if names, isNames := value.(string); isNames {
+			bits, err := flagBits(def, names)
+			if err != nil {
+				return err
+			}
+			value = bits
+		}
+		repr, _ := def["repr"].(string)
+		return writeInteger(enc, repr, value, endianness)
+	case "discriminated_union":
+		return r.union(def, value, s)
+	case "choice":
+		return r.choice(def, value, s)
+	case "back_reference":
+		return r.backReference(def, value, s)
+	case "":
+		return fmt.Errorf("element has no type")
+	default:
+		return r.typeRef(elemType, value, s)
+	}
+	return nil
+}
+
+// flagBits combines flag names written as "syn|ack" into their bits; "0" or "" is no flags
+func flagBits(def map[string]interface{}, names string) (uint64, error) {
+	variants, _ := def["variants"].(map[string]interface{})
+	var bits uint64
+	for _, name := range strings.Split(names, "|") {
+		if name == "" || name == "0" {
+			continue
+		}
+		v, ok := variants[name].(float64)
+		if !ok {
+			return 0, fmt.Errorf("unknown flag %q", name)
+		}
+		bits |= uint64(v)
+	}
+	return bits, nil
+}
+
+func bitMask(size int) uint64 {
+	if size >= 64 {
+		return math.MaxUint64
+	}
+	return (uint64(1) << size) - 1
+}
+
+func writeInteger(enc *runtime.BitStreamEncoder, intType string, value interface{}, endianness runtime.Endianness) error {
+	n, err := toUint64(value)
+	if err != nil {
+		return err
+	}
+	switch intType {
+	case "uint8", "int8":
+		enc.WriteUint8(uint8(n))
+	case "uint16", "int16":
+		enc.WriteUint16(uint16(n), endianness)
+	case "uint32", "int32":
+		enc.WriteUint32(uint32(n), endianness)
+	case "uint64", "int64":
+		enc.WriteUint64(n, endianness)
+	default:
+		return fmt.Errorf("unsupported integer type %q", intType)
+	}
+	return nil
+}
+
+func writeVarlength(enc *runtime.BitStreamEncoder, encoding interface{}, n uint64) error {
+	switch encoding {
+	case "der", nil:
+		enc.WriteVarlengthDER(n)
+	case "leb128":
+		enc.WriteVarlengthLEB128(n)
+	case "ebml":
+		enc.WriteVarlengthEBML(n)
+	case "vlq":
+		enc.WriteVarlengthVLQ(n)
+	default:
+		return fmt.Errorf("unknown varlength encoding %v", encoding)
+	}
+	return nil
+}
+
+// writeLength writes a count or byte-length prefix of the given length_type
+func (r *encodeRun) writeLength(def map[string]interface{}, lengthTypeKey string, n int) error {
+	lengthType, _ := def[lengthTypeKey].(string)
+	if lengthType == "" {
+		lengthType = "uint8"
+	}
+	if lengthType == "varlength" {
+		return writeVarlength(r.enc, def["length_encoding"], uint64(n))
+	}
+	if err := checkRange(lengthType, n); err != nil {
+		return err
+	}
+	return writeInteger(r.enc, lengthType, n, r.dyn.endianness)
+}
+
+func byteItems(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case []byte:
+		items := make([]interface{}, len(v))
+		for i, b := range v {
+			items[i] = b
+		}
+		return items, nil
+	case []interface{}:
+		return v, nil
+	case nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("expected bytes, got %T", value)
+}
+
+// encodeString converts a string to bytes in the definition's encoding
+func encodeString(str string, def map[string]interface{}, endianness runtime.Endianness) ([]byte, error) {
+	switch def["encoding"] {
+	case "utf16":
+		units := utf16.Encode([]rune(str))
+		out := make([]byte, 0, len(units)*2)
+		for _, u := range units {
+			if endianness == runtime.BigEndian {
+				out = append(out, byte(u>>8), byte(u))
+			} else {
+				out = append(out, byte(u), byte(u>>8))
+			}
+		}
+		return out, nil
+	case "ascii", "latin1":
+		out := make([]byte, 0, len(str))
+		for _, c := range str {
+			if c > 0xFF {
+				return nil, fmt.Errorf("character %q cannot be encoded as %v", c, def["encoding"])
+			}
+			out = append(out, byte(c))
+		}
+		return out, nil
+	}
+	return []byte(str), nil
+}
+
+func (r *encodeRun) stringValue(def map[string]interface{}, str string, s *scope) error {
+	endianness := r.dyn.fieldEndianness(def)
+	data, err := encodeString(str, def, endianness)
+	if err != nil {
+		return err
+	}
+
+	switch stringKind(def) {
+	case "length_prefixed":
+		if err := r.writeLength(def, "length_type", len(data)); err != nil {
+			return err
+		}
+		r.enc.WriteBytes(data)
+	case "null_terminated":
+		if maxLength := intAttrDefault(def, "max_length", 0); maxLength > 0 && len(data) > maxLength {
+			return fmt.Errorf("%d bytes exceed max_length %d", len(data), maxLength)
+		}
+		r.enc.WriteBytes(data)
+		r.enc.WriteUint8(0)
+		if def["encoding"] == "utf16" {
+			r.enc.WriteUint8(0)
+		}
+	case "fixed":
+		length := intAttrDefault(def, "length", 0)
+		if len(data) > length {
+			data = data[:length]
+		}
+		r.enc.WriteBytes(data)
+		pad, _ := stringPadding(def)
+		padding, err := encodeString(string(rune(pad)), def, endianness)
+		if err != nil {
+			return err
+		}
+		for i := len(data); i < length; i++ {
+			r.enc.WriteUint8(padding[(i-len(data))%len(padding)])
+		}
+	case "field_referenced":
+		r.enc.WriteBytes(data)
+	default:
+		return &UnsupportedError{Feature: fmt.Sprintf("string kind %v", def["kind"])}
+	}
+	return nil
+}
+
+func (r *encodeRun) array(def, itemDef map[string]interface{}, items []interface{}, s *scope) error {
+	kind, _ := def["kind"].(string)
+
+	switch kind {
+	case "fixed":
+		if length := intAttrDefault(def, "length", 0); len(items) != length {
+			return fmt.Errorf("fixed array expects %d items, got %d", length, len(items))
+		}
+	case "length_prefixed":
+		if err := r.writeLength(def, "length_type", len(items)); err != nil {
+			return err
+		}
+	case "length_prefixed_items":
+		if err := r.writeLength(def, "length_type", len(items)); err != nil {
+			return err
+		}
+		for _, item := range items {
+			data, err := r.measure(itemDef, item, s)
+			if err != nil {
+				return err
+			}
+			if err := r.writeLength(def, "item_length_type", len(data)); err != nil {
+				return err
+			}
+			r.enc.WriteBytes(data)
+		}
+		return nil
+	case "byte_length_prefixed":
+		tmp := &encodeRun{dyn: r.dyn, enc: runtime.NewBitStreamEncoder(r.dyn.bitOrder), depth: r.depth}
+		for _, item := range items {
+			if err := tmp.element(itemDef, item, s); err != nil {
+				return err
+			}
+		}
+		data := tmp.enc.Finish()
+		if err := r.writeLength(def, "length_type", len(data)); err != nil {
+			return err
+		}
+		r.enc.WriteBytes(data)
+		return nil
+	case "field_referenced", "computed_count", "eof_terminated", "signature_terminated", "variant_terminated":
+		// Count is implied by another field, an expression, or the data itself
+	case "null_terminated":
+		for _, item := range items {
+			if err := r.element(itemDef, item, s); err != nil {
+				return err
+			}
+		}
+		// A terminal variant ends the array without a null byte
+		terminal, _ := def["terminal_variants"].([]interface{})
+		if len(items) == 0 || !isTerminalVariant(terminal, items[len(items)-1]) {
+			r.enc.WriteUint8(0)
+		}
+		return nil
+	default:
+		return &UnsupportedError{Feature: fmt.Sprintf("array kind %q", kind)}
+	}
+
+	for _, item := range items {
+		if err := r.element(itemDef, item, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isTerminalVariant reports whether an array item is a union variant listed in terminal_variants
+func isTerminalVariant(terminal []interface{}, item interface{}) bool {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for _, t := range terminal {
+		if t == m["type"] {
+			return true
+		}
+	}
+	return false
+}
+
+// unionParts splits a union value into variant name and payload
+func unionParts(value interface{}) (string, interface{}, error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("expected union object, got %T", value)
+	}
+	variant, ok := m["type"].(string)
+	if !ok {
+		return "", nil, fmt.Errorf("union value missing \"type\"")
+	}
+	return variant, m["value"], nil
+}
+
+func (r *encodeRun) union(def map[string]interface{}, value interface{}, s *scope) error {
+	variant, payload, err := unionParts(value)
+	if err != nil {
+		return err
+	}
+	variants, _ := def["variants"].([]interface{})
+	for _, raw := range variants {
+		v, _ := raw.(map[string]interface{})
+		if v["type"] == variant {
+			return r.typeRef(variant, payload, s)
+		}
+	}
+	return fmt.Errorf("unknown union variant %q", variant)
+}
+
+func (r *encodeRun) choice(def map[string]interface{}, value interface{}, s *scope) error {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected choice object, got %T", value)
+	}
+	variant, _ := m["type"].(string)
+	choices, _ := def["choices"].([]interface{})
+	for _, raw := range choices {
+		c, _ := raw.(map[string]interface{})
+		if c["type"] == variant {
+			return r.typeRef(variant, m, s)
+		}
+	}
+	return fmt.Errorf("unknown choice variant %q", variant)
+}
+
+// backReference writes a pointer to an earlier occurrence of the same target value
+func (r *encodeRun) backReference(def map[string]interface{}, value interface{}, s *scope) error {
+	target, _ := def["target_type"].(string)
+	offset, ok := r.positions[target+"\x00"+fmt.Sprint(value)]
+	if !ok {
+		return fmt.Errorf("back_reference target %s %v was not encoded earlier", target, value)
+	}
+	mask, err := backReferenceMask(def)
+	if err != nil {
+		return err
+	}
+	storage, _ := def["storage"].(string)
+	marker := bitMask(storageBits(storage)) &^ mask
+	return writeInteger(r.enc, storage, marker|uint64(offset)&mask, r.dyn.fieldEndianness(def))
+}
+
+func backReferenceMask(def map[string]interface{}) (uint64, error) {
+	switch m := def["offset_mask"].(type) {
+	case string:
+		var mask uint64
+		if _, err := fmt.Sscanf(m, "0x%x", &mask); err != nil {
+			return 0, fmt.Errorf("invalid offset_mask %q", m)
+		}
+		return mask, nil
+	case float64:
+		return uint64(m), nil
+	}
+	storage, _ := def["storage"].(string)
+	return bitMask(storageBits(storage)), nil
+}
+
+func storageBits(intType string) int {
+	switch intType {
+	case "uint8":
+		return 8
+	case "uint16":
+		return 16
+	case "uint32":
+		return 32
+	}
+	return 64
+}
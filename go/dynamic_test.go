@@ -0,0 +1,518 @@
+// ABOUTME: Tests for the dynamic (schema-walking) encoder/decoder
+// ABOUTME: Uses inline schemas so they run without the generated tests-json directory
+package binschema
+
+import (
+	"errors"
+	"math/big"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/serialexp/binschema/runtime"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamicStructRoundTrip(t *testing.T) {
+	schema := map[string]interface{}{
+		"config": map[string]interface{}{"endianness": "big_endian"},
+		"types": map[string]interface{}{
+			"Message": map[string]interface{}{
+				"sequence": []interface{}{
+					map[string]interface{}{"name": "magic", "type": "uint8", "const": float64(0x42)},
+					map[string]interface{}{"name": "flags", "type": "uint8"},
+					map[string]interface{}{"name": "timestamp", "type": "uint32", "conditional": "flags & 0x01"},
+					map[string]interface{}{"name": "name_len", "type": "uint8", "computed": map[string]interface{}{"type": "length_of", "target": "name"}},
+					map[string]interface{}{"name": "name", "type": "string", "kind": "field_referenced", "length_field": "name_len", "encoding": "utf8"},
+					map[string]interface{}{
+						"name": "values", "type": "array", "kind": "length_prefixed", "length_type": "uint8",
+						"items": map[string]interface{}{"type": "int16", "endianness": "little_endian"},
+					},
+				},
+			},
+		},
+	}
+	dyn, err := CompileSchemaMap(schema)
+	require.NoError(t, err)
+
+	value := map[string]interface{}{
+		"flags":     float64(1),
+		"timestamp": float64(0x01020304),
+		"name":      "hé",
+		"values":    []interface{}{float64(-2), float64(3)},
+	}
+	encoded, err := dyn.Encode("Message", value)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x42, 0x01, 0x01, 0x02, 0x03, 0x04, 0x03, 'h', 0xC3, 0xA9, 0x02, 0xFE, 0xFF, 0x03, 0x00}, encoded)
+
+	decoded, err := dyn.Decode("Message", encoded)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"magic":     uint8(0x42),
+		"flags":     uint8(1),
+		"timestamp": uint32(0x01020304),
+		"name_len":  uint8(3),
+		"name":      "hé",
+		"values":    []interface{}{int16(-2), int16(3)},
+	}, decoded)
+
+	// Conditional field is omitted when its condition is false
+	encoded, err = dyn.Encode("Message", map[string]interface{}{"flags": float64(0), "name": "", "values": []interface{}{}})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x42, 0x00, 0x00, 0x00}, encoded)
+	decoded, err = dyn.Decode("Message", encoded)
+	require.NoError(t, err)
+	require.NotContains(t, decoded, "timestamp")
+
+	// Wrong constant is a decode error
+	_, err = dyn.Decode("Message", []byte{0x41, 0x00, 0x00, 0x00})
+	require.Error(t, err)
+}
+
+func TestDynamic128BitIntegers(t *testing.T) {
+	schema := map[string]interface{}{
+		"config": map[string]interface{}{"endianness": "little_endian"},
+		"types": map[string]interface{}{
+			"Counter": map[string]interface{}{
+				"sequence": []interface{}{
+					map[string]interface{}{"name": "id", "type": "uint128"},
+					map[string]interface{}{"name": "delta", "type": "int128"},
+				},
+			},
+		},
+	}
+	dyn, err := CompileSchemaMap(schema)
+	require.NoError(t, err)
+
+	id, _ := new(big.Int).SetString("0x0102030405060708090a0b0c0d0e0f10", 0)
+	encoded, err := dyn.Encode("Counter", map[string]interface{}{"id": id, "delta": float64(-1)})
+	require.NoError(t, err)
+	require.Equal(t, []byte{
+		0x10, 0x0f, 0x0e, 0x0d, 0x0c, 0x0b, 0x0a, 0x09, 0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	}, encoded)
+
+	decoded, err := dyn.Decode("Counter", encoded)
+	require.NoError(t, err)
+	fields := decoded.(map[string]interface{})
+	require.Equal(t, 0, id.Cmp(fields["id"].(*big.Int)))
+	require.True(t, NumericEqual(fields["delta"], float64(-1)))
+
+	// Decimal strings carry values JSON numbers can't, and out of range values are rejected
+	_, err = dyn.Encode("Counter", map[string]interface{}{"id": "340282366920938463463374607431768211456", "delta": float64(0)})
+	require.EqualError(t, err, "id: 340282366920938463463374607431768211456 doesn't fit in uint128")
+}
+
+func TestDynamicAddressTypes(t *testing.T) {
+	schema := map[string]interface{}{
+		"types": map[string]interface{}{
+			"Lease": map[string]interface{}{
+				"sequence": []interface{}{
+					map[string]interface{}{"name": "client", "type": "uuid"},
+					map[string]interface{}{"name": "hardware", "type": "mac"},
+					map[string]interface{}{"name": "address", "type": "ipv4"},
+				},
+			},
+		},
+	}
+	dyn, err := CompileSchemaMap(schema)
+	require.NoError(t, err)
+
+	encoded, err := dyn.Encode("Lease", map[string]interface{}{
+		"client":   "123e4567-e89b-12d3-a456-426614174000",
+		"hardware": "00-1a-2b-3c-4d-5e",
+		"address":  "10.0.0.1",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []byte{
+		0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00,
+		0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e,
+		10, 0, 0, 1,
+	}, encoded)
+
+	decoded, err := dyn.Decode("Lease", encoded)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"client":   runtime.UUID{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00},
+		"hardware": runtime.MAC{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e},
+		"address":  netip.MustParseAddr("10.0.0.1"),
+	}, decoded)
+
+	_, err = dyn.Encode("Lease", map[string]interface{}{"client": "not-a-uuid", "hardware": "00:00:00:00:00:00", "address": "10.0.0.1"})
+	require.EqualError(t, err, `client: invalid UUID "not-a-uuid"`)
+}
+
+func TestDynamicTimestamps(t *testing.T) {
+	schema := map[string]interface{}{
+		"types": map[string]interface{}{
+			"Reading": map[string]interface{}{
+				"sequence": []interface{}{
+					map[string]interface{}{"name": "taken", "type": "unix32"},
+					map[string]interface{}{"name": "synced", "type": "ntp"},
+					map[string]interface{}{"name": "uptime", "type": "unix64", "unit": "us", "epoch": "2000-01-01T00:00:00Z"},
+				},
+			},
+		},
+	}
+	dyn, err := CompileSchemaMap(schema)
+	require.NoError(t, err)
+
+	encoded, err := dyn.Encode("Reading", map[string]interface{}{
+		"taken":  "2024-01-01T00:00:00Z",
+		"synced": time.Date(2024, time.January, 1, 0, 0, 0, 500000000, time.UTC),
+		"uptime": "2000-01-01T00:00:01Z",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []byte{
+		0x65, 0x92, 0x00, 0x80,
+		0xe9, 0x3c, 0x7f, 0x00, 0x80, 0, 0, 0,
+		0, 0, 0, 0, 0, 0x0f, 0x42, 0x40,
+	}, encoded)
+
+	decoded, err := dyn.Decode("Reading", encoded)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"taken":  time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		"synced": time.Date(2024, time.January, 1, 0, 0, 0, 500000000, time.UTC),
+		"uptime": time.Date(2000, time.January, 1, 0, 0, 1, 0, time.UTC),
+	}, decoded)
+}
+
+func TestDynamicFlags(t *testing.T) {
+	schema := map[string]interface{}{
+		"types": map[string]interface{}{
+			"TcpFlags": map[string]interface{}{
+				"type": "flags", "repr": "uint8",
+				"variants": map[string]interface{}{"fin": float64(1), "syn": float64(2), "ack": float64(16)},
+			},
+			"Segment": map[string]interface{}{
+				"sequence": []interface{}{
+					map[string]interface{}{"name": "flags", "type": "TcpFlags"},
+					map[string]interface{}{"name": "more", "type": "TcpFlags"},
+				},
+			},
+		},
+	}
+	dyn, err := CompileSchemaMap(schema)
+	require.NoError(t, err)
+
+	encoded, err := dyn.Encode("Segment", map[string]interface{}{"flags": "syn|ack", "more": float64(3)})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x12, 0x03}, encoded)
+
+	decoded, err := dyn.Decode("Segment", encoded)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"flags": uint8(0x12), "more": uint8(3)}, decoded)
+
+	_, err = dyn.Encode("Segment", map[string]interface{}{"flags": "syn|urg", "more": float64(0)})
+	require.EqualError(t, err, `flags: unknown flag "urg"`)
+}
+
+func TestDynamicBCD(t *testing.T) {
+	schema := map[string]interface{}{
+		"types": map[string]interface{}{
+			"Charge": map[string]interface{}{
+				"sequence": []interface{}{
+					map[string]interface{}{"name": "msisdn", "type": "packed_bcd", "digits": float64(11), "decode_as": "string"},
+					map[string]interface{}{"name": "code", "type": "bcd", "digits": float64(3)},
+				},
+			},
+		},
+	}
+	dyn, err := CompileSchemaMap(schema)
+	require.NoError(t, err)
+
+	encoded, err := dyn.Encode("Charge", map[string]interface{}{"msisdn": "44791234567", "code": float64(42)})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x04, 0x47, 0x91, 0x23, 0x45, 0x67, 0x00, 0x04, 0x02}, encoded)
+
+	decoded, err := dyn.Decode("Charge", encoded)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"msisdn": "44791234567", "code": int64(42)}, decoded)
+}
+
+func TestDynamicInlineStruct(t *testing.T) {
+	schema := map[string]interface{}{
+		"types": map[string]interface{}{
+			"Packet": map[string]interface{}{
+				"sequence": []interface{}{
+					map[string]interface{}{
+						"name": "header", "type": "struct",
+						"fields": []interface{}{
+							map[string]interface{}{"name": "len", "type": "uint8"},
+							map[string]interface{}{"name": "kind", "type": "uint8"},
+						},
+					},
+					map[string]interface{}{"name": "body", "type": "string", "kind": "field_referenced", "length_field": "header.len", "encoding": "utf8"},
+				},
+			},
+		},
+	}
+	dyn, err := CompileSchemaMap(schema)
+	require.NoError(t, err)
+
+	encoded, err := dyn.Encode("Packet", map[string]interface{}{
+		"header": map[string]interface{}{"len": float64(2), "kind": float64(7)},
+		"body":   "hi",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x02, 0x07, 'h', 'i'}, encoded)
+
+	decoded, err := dyn.Decode("Packet", encoded)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"header": map[string]interface{}{"len": uint8(2), "kind": uint8(7)},
+		"body":   "hi",
+	}, decoded)
+}
+
+func TestDynamicUnionsAndBackReferences(t *testing.T) {
+	schema := map[string]interface{}{
+		"types": map[string]interface{}{
+			"Label": map[string]interface{}{"type": "string", "kind": "length_prefixed", "length_type": "uint8", "encoding": "ascii"},
+			"LabelPointer": map[string]interface{}{
+				"type": "back_reference", "storage": "uint16", "offset_mask": "0x3FFF",
+				"offset_from": "message_start", "target_type": "Label",
+			},
+			"CompressedLabel": map[string]interface{}{
+				"type":          "discriminated_union",
+				"discriminator": map[string]interface{}{"peek": "uint8"},
+				"variants": []interface{}{
+					map[string]interface{}{"type": "Label", "when": "value < 0xC0"},
+					map[string]interface{}{"type": "LabelPointer", "when": "value >= 0xC0"},
+				},
+			},
+			"Domain": map[string]interface{}{
+				"type": "array", "kind": "null_terminated",
+				"items":             map[string]interface{}{"type": "CompressedLabel"},
+				"terminal_variants": []interface{}{"LabelPointer"},
+			},
+			"Pair": map[string]interface{}{
+				"sequence": []interface{}{
+					map[string]interface{}{"name": "first", "type": "Domain"},
+					map[string]interface{}{"name": "second", "type": "Domain"},
+				},
+			},
+		},
+	}
+	dyn, err := CompileSchemaMap(schema)
+	require.NoError(t, err)
+
+	value := map[string]interface{}{
+		"first": []interface{}{
+			map[string]interface{}{"type": "Label", "value": "ab"},
+			map[string]interface{}{"type": "Label", "value": "c"},
+		},
+		"second": []interface{}{
+			map[string]interface{}{"type": "Label", "value": "x"},
+			map[string]interface{}{"type": "LabelPointer", "value": "c"},
+		},
+	}
+	encoded, err := dyn.Encode("Pair", value)
+	require.NoError(t, err)
+	require.Equal(t, []byte{2, 'a', 'b', 1, 'c', 0, 1, 'x', 0xC0, 0x03}, encoded)
+
+	decoded, err := dyn.Decode("Pair", encoded)
+	require.NoError(t, err)
+	require.Equal(t, value, decoded)
+
+	// A pointer to itself must fail instead of recursing forever
+	_, err = dyn.Decode("Domain", []byte{0xC0, 0x00})
+	require.Error(t, err)
+}
+
+func TestDynamicByteBudget(t *testing.T) {
+	schema := map[string]interface{}{
+		"config": map[string]interface{}{"endianness": "little_endian"},
+		"types": map[string]interface{}{
+			"Size": map[string]interface{}{"sequence": []interface{}{
+				map[string]interface{}{"name": "x", "type": "uint16"},
+			}},
+			"Raw": map[string]interface{}{"sequence": []interface{}{
+				map[string]interface{}{"name": "data", "type": "array", "kind": "eof_terminated", "items": map[string]interface{}{"type": "uint8"}},
+			}},
+			"Chunk": map[string]interface{}{"sequence": []interface{}{
+				map[string]interface{}{"name": "id", "type": "string", "kind": "fixed", "length": float64(4), "encoding": "ascii"},
+				map[string]interface{}{"name": "size", "type": "uint32", "computed": map[string]interface{}{"type": "length_of", "target": "content"}},
+				map[string]interface{}{
+					"name": "content", "type": "discriminated_union",
+					"discriminator": map[string]interface{}{"field": "id"},
+					"byte_budget":   map[string]interface{}{"field": "size"},
+					"variants": []interface{}{
+						map[string]interface{}{"type": "Size", "when": "value == 'SIZE'"},
+						map[string]interface{}{"type": "Raw"},
+					},
+				},
+				map[string]interface{}{"name": "trailer", "type": "uint8"},
+			}},
+		},
+	}
+	dyn, err := CompileSchemaMap(schema)
+	require.NoError(t, err)
+
+	value := map[string]interface{}{
+		"id":      "DATA",
+		"content": map[string]interface{}{"type": "Raw", "value": map[string]interface{}{"data": []interface{}{float64(7), float64(8)}}},
+		"trailer": float64(9),
+	}
+	encoded, err := dyn.Encode("Chunk", value)
+	require.NoError(t, err)
+	require.Equal(t, []byte{'D', 'A', 'T', 'A', 2, 0, 0, 0, 7, 8, 9}, encoded)
+
+	decoded, err := dyn.Decode("Chunk", encoded)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"id":      "DATA",
+		"size":    uint32(2),
+		"content": map[string]interface{}{"type": "Raw", "value": map[string]interface{}{"data": []interface{}{uint8(7), uint8(8)}}},
+		"trailer": uint8(9),
+	}, decoded)
+}
+
+func TestDynamicNullTerminatedMaxLength(t *testing.T) {
+	schema := map[string]interface{}{
+		"types": map[string]interface{}{
+			"Name": map[string]interface{}{"sequence": []interface{}{
+				map[string]interface{}{"name": "text", "type": "string", "kind": "null_terminated", "max_length": float64(3)},
+			}},
+		},
+	}
+	dyn, err := CompileSchemaMap(schema)
+	require.NoError(t, err)
+
+	decoded, err := dyn.Decode("Name", []byte{'a', 'b', 'c', 0})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"text": "abc"}, decoded)
+	_, err = dyn.Decode("Name", []byte{'a', 'b', 'c', 'd', 0})
+	require.EqualError(t, err, "text: no terminator within max_length of 3 bytes")
+	_, err = dyn.Encode("Name", map[string]interface{}{"text": "abcd"})
+	require.EqualError(t, err, "text: 4 bytes exceed max_length 3")
+}
+
+func TestDynamicStringPadding(t *testing.T) {
+	schema := map[string]interface{}{
+		"types": map[string]interface{}{
+			"Record": map[string]interface{}{"sequence": []interface{}{
+				map[string]interface{}{"name": "null", "type": "string", "kind": "fixed", "length": float64(5), "encoding": "ascii"},
+				map[string]interface{}{"name": "space", "type": "string", "kind": "fixed", "length": float64(5), "encoding": "ascii", "padding": "space"},
+				map[string]interface{}{"name": "raw", "type": "string", "kind": "fixed", "length": float64(3), "encoding": "ascii", "padding": "none"},
+				map[string]interface{}{"name": "wide", "type": "string", "kind": "fixed", "length": float64(6), "encoding": "utf16"},
+			}},
+		},
+	}
+	dyn, err := CompileSchemaMap(schema)
+	require.NoError(t, err)
+
+	// Only trailing padding is trimmed, as in generated code
+	value := map[string]interface{}{"null": "a\x00b", "space": "a b", "raw": "x", "wide": "\u0100"}
+	encoded, err := dyn.Encode("Record", value)
+	require.NoError(t, err)
+	require.Equal(t, []byte{'a', 0, 'b', 0, 0, 'a', ' ', 'b', ' ', ' ', 'x', 0, 0, 0x01, 0x00, 0, 0, 0, 0}, encoded)
+
+	decoded, err := dyn.Decode("Record", encoded)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"null": "a\x00b", "space": "a b", "raw": "x\x00\x00", "wide": "\u0100"}, decoded)
+}
+
+func TestDynamicInvalidUTF8(t *testing.T) {
+	schemaWith := func(policy string) map[string]interface{} {
+		schema := map[string]interface{}{
+			"types": map[string]interface{}{
+				"Name": map[string]interface{}{"sequence": []interface{}{
+					map[string]interface{}{"name": "text", "type": "string", "kind": "length_prefixed", "length_type": "uint8"},
+				}},
+			},
+		}
+		if policy != "" {
+			schema["config"] = map[string]interface{}{"invalid_utf8": policy}
+		}
+		return schema
+	}
+	invalid := []byte{3, 'a', 0xff, 'c'}
+
+	// Kept by default, as in generated code
+	for _, policy := range []string{"", "keep"} {
+		dyn, err := CompileSchemaMap(schemaWith(policy))
+		require.NoError(t, err)
+		decoded, err := dyn.Decode("Name", invalid)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"text": "a\xffc"}, decoded)
+	}
+
+	dyn, err := CompileSchemaMap(schemaWith("replace"))
+	require.NoError(t, err)
+	decoded, err := dyn.Decode("Name", invalid)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"text": "a\uFFFDc"}, decoded)
+
+	dyn, err = CompileSchemaMap(schemaWith("reject"))
+	require.NoError(t, err)
+	_, err = dyn.Decode("Name", invalid)
+	require.EqualError(t, err, "text: invalid UTF-8 at index 1")
+	var invalidUTF8 *runtime.InvalidUTF8Error
+	require.True(t, errors.As(err, &invalidUTF8), "got %v", err)
+	require.Equal(t, 1, invalidUTF8.Index)
+	require.Equal(t, runtime.ErrorSchemaMismatch, invalidUTF8.Code())
+}
+
+func TestCompileSchema(t *testing.T) {
+	dyn, err := CompileSchema([]byte(`{
+		// JSON5 comments and trailing commas are accepted
+		config: { endianness: "little_endian" },
+		types: {
+			"Frame": { sequence: [
+				{ name: "kind", type: "uint8" },
+				{ name: "length", type: "uint16", conditional: "kind == 2" },
+			] },
+			"Tagged": { sequence: [
+				{ name: "value", type: "uint8" },
+			], instances: [ { name: "extra", type: "uint8", position: 0 } ] },
+		},
+	}`))
+	require.NoError(t, err)
+
+	encoded, err := dyn.Encode("Frame", map[string]interface{}{"kind": 2, "length": uint16(0x0102)})
+	require.NoError(t, err)
+	require.Equal(t, []byte{2, 0x02, 0x01}, encoded)
+
+	_, err = dyn.Encode("Missing", map[string]interface{}{})
+	require.ErrorContains(t, err, "type Missing not found")
+
+	var unsupported *UnsupportedError
+	_, err = dyn.Decode("Tagged", []byte{1})
+	require.True(t, errors.As(err, &unsupported), "got %v", err)
+
+	// Expression syntax errors are reported when compiling, not on first use
+	_, err = CompileSchema([]byte(`{"types": {"T": {"sequence": [{"name": "x", "type": "uint8", "conditional": "a =="}]}}}`))
+	require.ErrorContains(t, err, "invalid expression")
+
+	_, err = CompileSchema([]byte(`{"types": `))
+	require.Error(t, err)
+}
+
+func TestDynamicConcurrentUse(t *testing.T) {
+	dyn, err := CompileSchema([]byte(`{"types": {
+		"Optional<T>": {"sequence": [
+			{"name": "present", "type": "uint8"},
+			{"name": "value", "type": "T", "conditional": "present == 1"}
+		]},
+		"Record": {"sequence": [{"name": "id", "type": "Optional<uint16>"}]}
+	}}`))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value := map[string]interface{}{"id": map[string]interface{}{"present": 1, "value": i}}
+			encoded, err := dyn.Encode("Record", value)
+			require.NoError(t, err)
+			decoded, err := dyn.Decode("Record", encoded)
+			require.NoError(t, err)
+			require.Equal(t, map[string]interface{}{"id": map[string]interface{}{"present": uint8(1), "value": uint16(i)}}, decoded)
+		}(i)
+	}
+	wg.Wait()
+}
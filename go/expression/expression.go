@@ -0,0 +1,569 @@
+// ABOUTME: Parser and evaluator for schema expressions (conditionals, computed counts, lengths)
+// ABOUTME: Mirrors the TypeScript expression engine and extends it with comparison and bitwise operators
+package expression
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrorKind classifies expression failures, matching the cross-language expression spec
+type ErrorKind string
+
+const (
+	ErrParse          ErrorKind = "parse_error"
+	ErrUndefinedField ErrorKind = "undefined_field"
+	ErrDivisionByZero ErrorKind = "division_by_zero"
+	ErrInvalidOperand ErrorKind = "invalid_operand"
+)
+
+// Error is returned by Parse and Eval
+type Error struct {
+	Kind    ErrorKind
+	Details string
+}
+
+func (e *Error) Error() string {
+	if e.Details == "" {
+		return string(e.Kind)
+	}
+	return fmt.Sprintf("%s: %s", e.Kind, e.Details)
+}
+
+// Resolver looks up a field reference such as "flags", "header.length" or "../count".
+// It returns false if the field is not available.
+type Resolver func(path string) (interface{}, bool)
+
+// Node is a parsed expression
+type Node interface {
+	eval(resolve Resolver) (interface{}, error)
+}
+
+// Number is an integer literal
+type Number struct{ Value int64 }
+
+// String is a quoted string literal (used to compare against string fields)
+type String struct{ Value string }
+
+// Bool is a true/false literal
+type Bool struct{ Value bool }
+
+// Field is a reference to a field value
+type Field struct{ Path string }
+
+// Unary is a prefix operator: "-", "!" or "~"
+type Unary struct {
+	Op      string
+	Operand Node
+}
+
+// Binary is an infix operator
+type Binary struct {
+	Op          string
+	Left, Right Node
+}
+
+// Fields returns the field paths referenced by an expression, in order of appearance
+func Fields(node Node) []string {
+	switch n := node.(type) {
+	case *Field:
+		return []string{n.Path}
+	case *Unary:
+		return Fields(n.Operand)
+	case *Binary:
+		return append(Fields(n.Left), Fields(n.Right)...)
+	}
+	return nil
+}
+
+// Eval parses and evaluates an expression in one step
+func Eval(src string, resolve Resolver) (interface{}, error) {
+	node, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return Evaluate(node, resolve)
+}
+
+// Evaluate computes the value of a parsed expression.
+// Results are int64 for arithmetic, bool for comparisons and logic, or string for literals.
+func Evaluate(node Node, resolve Resolver) (interface{}, error) {
+	return node.eval(resolve)
+}
+
+// EvalInt evaluates an expression that must produce an integer (lengths, counts, offsets)
+func EvalInt(node Node, resolve Resolver) (int64, error) {
+	v, err := node.eval(resolve)
+	if err != nil {
+		return 0, err
+	}
+	return toInt(v)
+}
+
+// EvalBool evaluates a condition. Integers are truthy when non-zero, as in the
+// TypeScript generator where conditions like "flags & 0x01" are emitted verbatim.
+func EvalBool(node Node, resolve Resolver) (bool, error) {
+	v, err := node.eval(resolve)
+	if err != nil {
+		return false, err
+	}
+	return Truthy(v), nil
+}
+
+// Truthy reports whether a value counts as true in a condition
+func Truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case bool:
+		return x
+	case string:
+		return x != ""
+	case nil:
+		return false
+	}
+	n, err := toInt(v)
+	return err == nil && n != 0
+}
+
+func (n *Number) eval(Resolver) (interface{}, error) { return n.Value, nil }
+func (n *String) eval(Resolver) (interface{}, error) { return n.Value, nil }
+func (n *Bool) eval(Resolver) (interface{}, error)   { return n.Value, nil }
+
+func (n *Field) eval(resolve Resolver) (interface{}, error) {
+	if resolve == nil {
+		return nil, &Error{Kind: ErrUndefinedField, Details: n.Path}
+	}
+	v, ok := resolve(n.Path)
+	if !ok {
+		return nil, &Error{Kind: ErrUndefinedField, Details: n.Path}
+	}
+	switch x := v.(type) {
+	case bool, string:
+		return x, nil
+	}
+	return toInt(v)
+}
+
+func (n *Unary) eval(resolve Resolver) (interface{}, error) {
+	v, err := n.Operand.eval(resolve)
+	if err != nil {
+		return nil, err
+	}
+	if n.Op == "!" {
+		return !Truthy(v), nil
+	}
+	i, err := toInt(v)
+	if err != nil {
+		return nil, err
+	}
+	if n.Op == "-" {
+		return -i, nil
+	}
+	return ^i, nil
+}
+
+func (n *Binary) eval(resolve Resolver) (interface{}, error) {
+	left, err := n.Left.eval(resolve)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit logic so guards like "count > 0 && items.0 == 1" stay safe
+	switch n.Op {
+	case "&&":
+		if !Truthy(left) {
+			return false, nil
+		}
+		right, err := n.Right.eval(resolve)
+		if err != nil {
+			return nil, err
+		}
+		return Truthy(right), nil
+	case "||":
+		if Truthy(left) {
+			return true, nil
+		}
+		right, err := n.Right.eval(resolve)
+		if err != nil {
+			return nil, err
+		}
+		return Truthy(right), nil
+	}
+
+	right, err := n.Right.eval(resolve)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.Op == "==" || n.Op == "!=" {
+		equal, err := equals(left, right)
+		if err != nil {
+			return nil, err
+		}
+		return equal == (n.Op == "=="), nil
+	}
+
+	l, err := toInt(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := toInt(right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, &Error{Kind: ErrDivisionByZero}
+		}
+		return l / r, nil // Go integer division truncates towards zero, like Math.trunc
+	case "%":
+		if r == 0 {
+			return nil, &Error{Kind: ErrDivisionByZero}
+		}
+		return l % r, nil
+	case "&":
+		return l & r, nil
+	case "|":
+		return l | r, nil
+	case "^":
+		return l ^ r, nil
+	case "<<":
+		return l << uint64(r), nil
+	case ">>":
+		return l >> uint64(r), nil
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	}
+	return nil, &Error{Kind: ErrParse, Details: fmt.Sprintf("unknown operator %q", n.Op)}
+}
+
+func equals(left, right interface{}) (bool, error) {
+	ls, lIsString := left.(string)
+	rs, rIsString := right.(string)
+	if lIsString || rIsString {
+		return lIsString && rIsString && ls == rs, nil
+	}
+	lb, lIsBool := left.(bool)
+	rb, rIsBool := right.(bool)
+	if lIsBool && rIsBool {
+		return lb == rb, nil
+	}
+	if lIsBool || rIsBool {
+		return Truthy(left) == Truthy(right), nil
+	}
+	l, err := toInt(left)
+	if err != nil {
+		return false, err
+	}
+	r, err := toInt(right)
+	if err != nil {
+		return false, err
+	}
+	return l == r, nil
+}
+
+// toInt converts any Go numeric value (including JSON float64) to int64
+func toInt(v interface{}) (int64, error) {
+	switch x := v.(type) {
+	case int64:
+		return x, nil
+	case int:
+		return int64(x), nil
+	case int8:
+		return int64(x), nil
+	case int16:
+		return int64(x), nil
+	case int32:
+		return int64(x), nil
+	case uint:
+		return int64(x), nil
+	case uint8:
+		return int64(x), nil
+	case uint16:
+		return int64(x), nil
+	case uint32:
+		return int64(x), nil
+	case uint64:
+		return int64(x), nil
+	case float32:
+		return floatToInt(float64(x))
+	case float64:
+		return floatToInt(x)
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, &Error{Kind: ErrInvalidOperand, Details: fmt.Sprintf("%v (%T) is not a number", v, v)}
+}
+
+func floatToInt(f float64) (int64, error) {
+	if f != math.Trunc(f) || math.IsInf(f, 0) {
+		return 0, &Error{Kind: ErrInvalidOperand, Details: fmt.Sprintf("%v is not an integer", f)}
+	}
+	return int64(f), nil
+}
+
+// Parse parses an expression.
+//
+// Grammar (lowest to highest precedence, as in C/JavaScript):
+//
+//	or      = and ('||' and)*
+//	and     = bitor ('&&' bitor)*
+//	bitor   = bitxor ('|' bitxor)*
+//	bitxor  = bitand ('^' bitand)*
+//	bitand  = equal ('&' equal)*
+//	equal   = compare (('==' | '!=') compare)*
+//	compare = shift (('<' | '<=' | '>' | '>=') shift)*
+//	shift   = sum (('<<' | '>>') sum)*
+//	sum     = term (('+' | '-') term)*
+//	term    = unary (('*' | '/' | '%') unary)*
+//	unary   = ('-' | '!' | '~') unary | primary
+//	primary = number | string | 'true' | 'false' | field | '(' or ')'
+func Parse(src string) (Node, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, &Error{Kind: ErrParse, Details: "empty expression"}
+	}
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	node, err := p.parseLevel(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.current(); tok.kind != tokenEnd {
+		return nil, &Error{Kind: ErrParse, Details: fmt.Sprintf("unexpected token %q at position %d", tok.text, tok.pos)}
+	}
+	return node, nil
+}
+
+// MustParse is like Parse but panics on error. Intended for expressions known at compile time.
+func MustParse(src string) Node {
+	node, err := Parse(src)
+	if err != nil {
+		panic(err)
+	}
+	return node
+}
+
+type tokenKind int
+
+const (
+	tokenNumber tokenKind = iota
+	tokenString
+	tokenIdent
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenEnd
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// operators sorted so longer operators match before their prefixes.
+// JavaScript's strict equality ("===", "!==") is accepted since schemas are often written with it.
+var operators = []string{"===", "!==", "&&", "||", "==", "!=", "<=", ">=", "<<", ">>", "+", "-", "*", "/", "%", "&", "|", "^", "<", ">", "!", "~"}
+
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	pos := 0
+	for pos < len(src) {
+		c := src[pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+		case isDigit(c):
+			start := pos
+			for pos < len(src) && (isIdentChar(src[pos])) {
+				pos++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[start:pos], pos: start})
+		case c == '\'' || c == '"':
+			start := pos
+			pos++
+			for pos < len(src) && src[pos] != c {
+				pos++
+			}
+			if pos >= len(src) {
+				return nil, &Error{Kind: ErrParse, Details: fmt.Sprintf("unterminated string at position %d", start)}
+			}
+			pos++
+			tokens = append(tokens, token{kind: tokenString, text: src[start+1 : pos-1], pos: start})
+		case isIdentStart(c) || strings.HasPrefix(src[pos:], "../"):
+			// Field paths may start with any number of "../" segments and contain dots
+			start := pos
+			for strings.HasPrefix(src[pos:], "../") {
+				pos += 3
+			}
+			for pos < len(src) && (isIdentChar(src[pos]) || src[pos] == '.') {
+				pos++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[start:pos], pos: start})
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: pos})
+			pos++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: pos})
+			pos++
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[pos:], op) {
+					tokens = append(tokens, token{kind: tokenOperator, text: strictAliases[op], pos: pos})
+					pos += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, &Error{Kind: ErrParse, Details: fmt.Sprintf("invalid character %q at position %d", c, pos)}
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEnd, pos: pos}), nil
+}
+
+// strictAliases maps each operator to the one it is evaluated as
+var strictAliases = func() map[string]string {
+	aliases := map[string]string{"===": "==", "!==": "!="}
+	for _, op := range operators {
+		if _, ok := aliases[op]; !ok {
+			aliases[op] = op
+		}
+	}
+	return aliases
+}()
+
+func isDigit(c byte) bool      { return c >= '0' && c <= '9' }
+func isIdentStart(c byte) bool { return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+func isIdentChar(c byte) bool  { return isIdentStart(c) || isDigit(c) }
+
+// binaryLevels lists infix operators from lowest to highest precedence
+var binaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) current() token { return p.tokens[p.pos] }
+
+func (p *parser) parseLevel(level int) (Node, error) {
+	if level == len(binaryLevels) {
+		return p.parseUnary()
+	}
+	left, err := p.parseLevel(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.current()
+		if tok.kind != tokenOperator || !contains(binaryLevels[level], tok.text) {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseLevel(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: tok.text, Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	tok := p.current()
+	if tok.kind == tokenOperator && (tok.text == "-" || tok.text == "!" || tok.text == "~") {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Op: tok.text, Operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.current()
+	switch tok.kind {
+	case tokenNumber:
+		p.pos++
+		n, err := strconv.ParseInt(tok.text, 0, 64)
+		if err != nil {
+			u, uerr := strconv.ParseUint(tok.text, 0, 64)
+			if uerr != nil {
+				return nil, &Error{Kind: ErrParse, Details: fmt.Sprintf("invalid number %q at position %d", tok.text, tok.pos)}
+			}
+			n = int64(u)
+		}
+		return &Number{Value: n}, nil
+	case tokenString:
+		p.pos++
+		return &String{Value: tok.text}, nil
+	case tokenIdent:
+		p.pos++
+		switch tok.text {
+		case "true":
+			return &Bool{Value: true}, nil
+		case "false":
+			return &Bool{Value: false}, nil
+		}
+		return &Field{Path: tok.text}, nil
+	case tokenLParen:
+		p.pos++
+		inner, err := p.parseLevel(0)
+		if err != nil {
+			return nil, err
+		}
+		if p.current().kind != tokenRParen {
+			return nil, &Error{Kind: ErrParse, Details: fmt.Sprintf("expected ')' at position %d", p.current().pos)}
+		}
+		p.pos++
+		return inner, nil
+	case tokenEnd:
+		return nil, &Error{Kind: ErrParse, Details: "unexpected end of expression"}
+	}
+	return nil, &Error{Kind: ErrParse, Details: fmt.Sprintf("unexpected token %q at position %d", tok.text, tok.pos)}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
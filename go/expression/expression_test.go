@@ -0,0 +1,117 @@
+// ABOUTME: Tests for the schema expression parser and evaluator
+// ABOUTME: Covers the shared arithmetic spec plus comparison, bitwise and logical operators
+package expression
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mapResolver(values map[string]interface{}) Resolver {
+	return func(path string) (interface{}, bool) {
+		v, ok := values[path]
+		return v, ok
+	}
+}
+
+func TestEval(t *testing.T) {
+	ctx := mapResolver(map[string]interface{}{
+		"a":             float64(10),
+		"b":             float64(3),
+		"flags":         uint8(0x05),
+		"header.length": uint32(7),
+		"../count":      int64(2),
+		"kind":          "SIZE",
+		"enabled":       true,
+	})
+
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		{"a + b", int64(13)},
+		{"a - b * 2", int64(4)},
+		{"(a - b) * 2", int64(14)},
+		{"a / b", int64(3)},
+		{"-a / b", int64(-3)},
+		{"a % b", int64(1)},
+		{"header.length + 1", int64(8)},
+		{"../count * 4", int64(8)},
+		{"0x10 + 0b11 + 0o7", int64(26)},
+		{"flags & 0x01", int64(1)},
+		{"flags | 0x02", int64(7)},
+		{"flags ^ 0x01", int64(4)},
+		{"1 << 4 >> 2", int64(4)},
+		{"~0", int64(-1)},
+		{"a == 10", true},
+		{"a != 10", false},
+		{"a > b && b >= 3", true},
+		{"a < b || flags & 0x04", true},
+		{"!enabled", false},
+		{"kind == 'SIZE'", true},
+		{"kind == \"OTHER\"", false},
+		{"enabled == true", true},
+		{"1 + 2 == 3", true},
+		{"a === 10 && b !== 10", true},
+	}
+
+	for _, tc := range tests {
+		got, err := Eval(tc.expr, ctx)
+		require.NoError(t, err, tc.expr)
+		require.Equal(t, tc.want, got, tc.expr)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	ctx := mapResolver(map[string]interface{}{"a": float64(10), "b": float64(0), "s": "x"})
+
+	tests := []struct {
+		expr string
+		kind ErrorKind
+	}{
+		{"", ErrParse},
+		{"a +", ErrParse},
+		{"* b", ErrParse},
+		{"a + + b", ErrParse},
+		{"(a + b", ErrParse},
+		{"a + b)", ErrParse},
+		{"()", ErrParse},
+		{"a $ b", ErrParse},
+		{"'open", ErrParse},
+		{"foo + a", ErrUndefinedField},
+		{"header.length", ErrUndefinedField},
+		{"a / b", ErrDivisionByZero},
+		{"a % b", ErrDivisionByZero},
+		{"s + 1", ErrInvalidOperand},
+	}
+
+	for _, tc := range tests {
+		_, err := Eval(tc.expr, ctx)
+		var exprErr *Error
+		require.True(t, errors.As(err, &exprErr), "%s: expected *Error, got %v", tc.expr, err)
+		require.Equal(t, tc.kind, exprErr.Kind, tc.expr)
+	}
+}
+
+func TestShortCircuit(t *testing.T) {
+	// The right-hand side references a missing field but must not be evaluated
+	ok, err := EvalBool(MustParse("present == 1 && inner.value > 0"), mapResolver(map[string]interface{}{"present": 0}))
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestFields(t *testing.T) {
+	node := MustParse("(header.count - 1) * size + ../base")
+	require.Equal(t, []string{"header.count", "size", "../base"}, Fields(node))
+}
+
+func TestEvalInt(t *testing.T) {
+	n, err := EvalInt(MustParse("count * 2"), mapResolver(map[string]interface{}{"count": uint16(21)}))
+	require.NoError(t, err)
+	require.Equal(t, int64(42), n)
+
+	_, err = EvalInt(MustParse("count"), mapResolver(map[string]interface{}{"count": 1.5}))
+	require.Error(t, err)
+}
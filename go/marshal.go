@@ -0,0 +1,611 @@
+// ABOUTME: Struct-tag driven Marshal/Unmarshal for existing Go types
+// ABOUTME: Produces the same wire format as the equivalent schema fields without codegen or a schema file
+
+package binschema
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/serialexp/binschema/runtime"
+)
+
+// Marshal encodes a struct (or pointer to struct) using its `bin` struct tags.
+//
+// A tag is a comma-separated list: an optional wire type first, then options.
+//
+//	Port    uint16   `bin:"uint16,le"`           // little-endian uint16 (default big-endian)
+//	Count   int      `bin:"uint8"`               // Go type may be wider than the wire type
+//	Name    string   `bin:",lenprefix=uint8"`    // length_prefixed string
+//	Label   string   `bin:",len=8"`              // fixed string, zero-padded
+//	Path    string   `bin:",nullterm"`           // null_terminated string
+//	Items   []Item   `bin:",lenprefix=uint16"`   // length_prefixed array
+//	Trailer []byte   `bin:",eof"`                // everything up to the end of input
+//	Hidden  int      `bin:"-"`                   // skipped
+//
+// Without a wire type, fixed-size Go types map to the schema type of the same
+// name (uint16 → uint16, float32 → float32), nested structs and *struct are
+// encoded in place, and [N]T is a fixed array of N items. Strings and slices
+// need one of lenprefix, len, nullterm (strings only) or eof. Unexported fields
+// are skipped.
+//
+// Plans derived from the tags are cached per type, so reflection over the
+// tags happens once.
+func Marshal(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, fmt.Errorf("marshal: nil %s", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("marshal: expected struct, got %s", rv.Type())
+	}
+
+	plan, err := planFor(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	enc := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	if err := plan.encode(enc, rv); err != nil {
+		return nil, err
+	}
+	return enc.Finish(), nil
+}
+
+// Unmarshal decodes data into the struct pointed to by v, using its `bin` struct tags.
+// See Marshal for the tag format. Bytes after the last field are ignored.
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("unmarshal: expected non-nil pointer to struct, got %T", v)
+	}
+
+	plan, err := planFor(rv.Elem().Type())
+	if err != nil {
+		return err
+	}
+	return plan.decode(runtime.NewBitStreamDecoder(data, runtime.MSBFirst), rv.Elem())
+}
+
+// structPlan is the cached encoding recipe for a struct type
+type structPlan struct {
+	typ    reflect.Type
+	fields []*fieldPlan
+}
+
+// fieldPlan describes how one struct field (or array item) is written
+type fieldPlan struct {
+	name       string // Go field name, for error messages
+	index      int
+	wire       string // Schema type: "uint16", "float32", "string", "array" or "struct"
+	endianness runtime.Endianness
+	lengthType string // lenprefix=
+	fixedLen   int    // len=, -1 when unset
+	nullTerm   bool
+	eof        bool
+	pointer    bool // *struct field
+	elem       *fieldPlan
+	st         *structPlan
+}
+
+var plans sync.Map // reflect.Type -> *structPlan
+
+// planFor returns the cached plan for a struct type, building it on first use
+func planFor(t reflect.Type) (*structPlan, error) {
+	if plan, ok := plans.Load(t); ok {
+		return plan.(*structPlan), nil
+	}
+	building := make(map[reflect.Type]*structPlan)
+	plan, err := buildStructPlan(t, building)
+	if err != nil {
+		return nil, err
+	}
+	for typ, p := range building {
+		plans.LoadOrStore(typ, p)
+	}
+	return plan, nil
+}
+
+// buildStructPlan builds plans for a struct and the structs it contains.
+// Plans under construction are shared through building so recursive types terminate.
+func buildStructPlan(t reflect.Type, building map[reflect.Type]*structPlan) (*structPlan, error) {
+	if plan, ok := plans.Load(t); ok {
+		return plan.(*structPlan), nil
+	}
+	if plan, ok := building[t]; ok {
+		return plan, nil
+	}
+	plan := &structPlan{typ: t}
+	building[t] = plan
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("bin")
+		if !sf.IsExported() || tag == "-" {
+			continue
+		}
+		field, err := buildFieldPlan(sf.Type, tag, building)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t.Name(), sf.Name, err)
+		}
+		field.name = sf.Name
+		field.index = i
+		plan.fields = append(plan.fields, field)
+	}
+	return plan, nil
+}
+
+func buildFieldPlan(t reflect.Type, tag string, building map[reflect.Type]*structPlan) (*fieldPlan, error) {
+	field := &fieldPlan{endianness: runtime.BigEndian, fixedLen: -1}
+
+	parts := strings.Split(tag, ",")
+	field.wire = strings.TrimSpace(parts[0])
+	for _, opt := range parts[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch key {
+		case "be":
+			field.endianness = runtime.BigEndian
+		case "le":
+			field.endianness = runtime.LittleEndian
+		case "lenprefix":
+			if integerSize(value) == 0 || strings.HasPrefix(value, "int") {
+				return nil, fmt.Errorf("lenprefix must be an unsigned integer type, got %q", value)
+			}
+			field.lengthType = value
+		case "len":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid len %q", value)
+			}
+			field.fixedLen = n
+		case "nullterm":
+			field.nullTerm = true
+		case "eof":
+			field.eof = true
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown tag option %q", key)
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint,
+		reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		if field.wire == "" {
+			if t.Kind() == reflect.Int || t.Kind() == reflect.Uint {
+				return nil, fmt.Errorf("%s has no fixed size; add a wire type such as bin:\"int32\"", t)
+			}
+			field.wire = t.Kind().String()
+		}
+		if integerSize(field.wire) == 0 {
+			return nil, fmt.Errorf("wire type %q does not fit Go type %s", field.wire, t)
+		}
+	case reflect.Float32, reflect.Float64:
+		if field.wire == "" {
+			field.wire = t.Kind().String()
+		}
+		if field.wire != "float32" && field.wire != "float64" {
+			return nil, fmt.Errorf("wire type %q does not fit Go type %s", field.wire, t)
+		}
+	case reflect.String:
+		if field.wire != "" && field.wire != "string" {
+			return nil, fmt.Errorf("wire type %q does not fit Go type %s", field.wire, t)
+		}
+		field.wire = "string"
+		if !field.hasLayout() {
+			return nil, fmt.Errorf("string needs one of lenprefix, len, nullterm or eof")
+		}
+	case reflect.Slice, reflect.Array:
+		if field.wire != "" && field.wire != "array" {
+			return nil, fmt.Errorf("wire type %q does not fit Go type %s", field.wire, t)
+		}
+		field.wire = "array"
+		if field.nullTerm {
+			return nil, fmt.Errorf("nullterm is only supported for strings")
+		}
+		if t.Kind() == reflect.Array {
+			if field.lengthType != "" || field.eof || (field.fixedLen >= 0 && field.fixedLen != t.Len()) {
+				return nil, fmt.Errorf("array %s always has %d items", t, t.Len())
+			}
+			field.fixedLen = t.Len()
+		} else if !field.hasLayout() {
+			return nil, fmt.Errorf("slice needs one of lenprefix, len or eof")
+		}
+		// Items inherit the field's byte order
+		elemTag := ""
+		if field.endianness == runtime.LittleEndian {
+			elemTag = ",le"
+		}
+		elem, err := buildFieldPlan(t.Elem(), elemTag, building)
+		if err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+		field.elem = elem
+	case reflect.Struct:
+		st, err := buildStructPlan(t, building)
+		if err != nil {
+			return nil, err
+		}
+		field.wire = "struct"
+		field.st = st
+	case reflect.Ptr:
+		if t.Elem().Kind() != reflect.Struct {
+			return nil, fmt.Errorf("unsupported pointer type %s", t)
+		}
+		st, err := buildStructPlan(t.Elem(), building)
+		if err != nil {
+			return nil, err
+		}
+		field.wire = "struct"
+		field.pointer = true
+		field.st = st
+	default:
+		return nil, fmt.Errorf("unsupported Go type %s", t)
+	}
+	return field, nil
+}
+
+// hasLayout reports whether a string or slice field says where it ends
+func (f *fieldPlan) hasLayout() bool {
+	return f.lengthType != "" || f.fixedLen >= 0 || f.nullTerm || f.eof
+}
+
+// integerSize returns the byte width of an integer schema type, or 0
+func integerSize(wire string) int {
+	switch wire {
+	case "uint8", "int8":
+		return 1
+	case "uint16", "int16":
+		return 2
+	case "uint32", "int32":
+		return 4
+	case "uint64", "int64":
+		return 8
+	}
+	return 0
+}
+
+func (p *structPlan) encode(enc *runtime.BitStreamEncoder, v reflect.Value) error {
+	for _, field := range p.fields {
+		if err := field.encode(enc, v.Field(field.index)); err != nil {
+			return fmt.Errorf("%s.%s: %w", p.typ.Name(), field.name, err)
+		}
+	}
+	return nil
+}
+
+func (p *structPlan) decode(dec *runtime.BitStreamDecoder, v reflect.Value) error {
+	if err := dec.EnterNested(); err != nil {
+		return err
+	}
+	defer dec.ExitNested()
+
+	for _, field := range p.fields {
+		if err := field.decode(dec, v.Field(field.index)); err != nil {
+			return fmt.Errorf("%s.%s: %w", p.typ.Name(), field.name, err)
+		}
+	}
+	return nil
+}
+
+func (f *fieldPlan) encode(enc *runtime.BitStreamEncoder, v reflect.Value) error {
+	switch f.wire {
+	case "float32":
+		enc.WriteFloat32(float32(v.Float()), f.endianness)
+	case "float64":
+		enc.WriteFloat64(v.Float(), f.endianness)
+	case "string":
+		return f.encodeString(enc, v.String())
+	case "array":
+		return f.encodeArray(enc, v)
+	case "struct":
+		if f.pointer {
+			if v.IsNil() {
+				return fmt.Errorf("nested %s is nil", f.st.typ.Name())
+			}
+			v = v.Elem()
+		}
+		return f.st.encode(enc, v)
+	default:
+		n, err := f.integerBits(v)
+		if err != nil {
+			return err
+		}
+		return writeInteger(enc, f.wire, n, f.endianness)
+	}
+	return nil
+}
+
+// integerBits range-checks an integer against the wire type and returns its two's complement bits
+func (f *fieldPlan) integerBits(v reflect.Value) (uint64, error) {
+	bits := uint(integerSize(f.wire) * 8)
+	if v.CanUint() {
+		n := v.Uint()
+		limit := uint64(math.MaxUint64) >> (64 - bits)
+		if strings.HasPrefix(f.wire, "int") {
+			limit >>= 1
+		}
+		if n > limit {
+			return 0, fmt.Errorf("value %d out of range for %s", n, f.wire)
+		}
+		return n, nil
+	}
+	n := v.Int()
+	if strings.HasPrefix(f.wire, "uint") {
+		if n < 0 || (bits < 64 && uint64(n) >= uint64(1)<<bits) {
+			return 0, fmt.Errorf("value %d out of range for %s", n, f.wire)
+		}
+		return uint64(n), nil
+	}
+	if bits < 64 && (n < -(int64(1)<<(bits-1)) || n >= int64(1)<<(bits-1)) {
+		return 0, fmt.Errorf("value %d out of range for %s", n, f.wire)
+	}
+	return uint64(n), nil
+}
+
+func (f *fieldPlan) writeLengthPrefix(enc *runtime.BitStreamEncoder, n int) error {
+	if size := integerSize(f.lengthType); size < 8 && uint64(n) >= uint64(1)<<(size*8) {
+		return fmt.Errorf("length %d does not fit %s prefix", n, f.lengthType)
+	}
+	return writeInteger(enc, f.lengthType, uint64(n), f.endianness)
+}
+
+func (f *fieldPlan) encodeString(enc *runtime.BitStreamEncoder, s string) error {
+	data := []byte(s)
+	switch {
+	case f.lengthType != "":
+		if err := f.writeLengthPrefix(enc, len(data)); err != nil {
+			return err
+		}
+		enc.WriteBytes(data)
+	case f.fixedLen >= 0:
+		if len(data) > f.fixedLen {
+			data = data[:f.fixedLen]
+		}
+		enc.WriteBytes(data)
+		for i := len(data); i < f.fixedLen; i++ {
+			enc.WriteUint8(0)
+		}
+	case f.nullTerm:
+		if strings.IndexByte(s, 0) >= 0 {
+			return fmt.Errorf("null-terminated string contains a null byte")
+		}
+		enc.WriteBytes(data)
+		enc.WriteUint8(0)
+	default:
+		enc.WriteBytes(data)
+	}
+	return nil
+}
+
+func (f *fieldPlan) encodeArray(enc *runtime.BitStreamEncoder, v reflect.Value) error {
+	n := v.Len()
+	if f.lengthType != "" {
+		if err := f.writeLengthPrefix(enc, n); err != nil {
+			return err
+		}
+	} else if f.fixedLen >= 0 && n != f.fixedLen {
+		return fmt.Errorf("fixed array needs %d items, got %d", f.fixedLen, n)
+	}
+
+	// Byte slices are written in one go
+	if f.elem.wire == "uint8" && v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		enc.WriteBytes(v.Bytes())
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		if err := f.elem.encode(enc, v.Index(i)); err != nil {
+			return fmt.Errorf("[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (f *fieldPlan) decode(dec *runtime.BitStreamDecoder, v reflect.Value) error {
+	switch f.wire {
+	case "uint8":
+		n, err := dec.ReadUint8()
+		return setInteger(v, uint64(n), int64(n), err)
+	case "uint16":
+		n, err := dec.ReadUint16(f.endianness)
+		return setInteger(v, uint64(n), int64(n), err)
+	case "uint32":
+		n, err := dec.ReadUint32(f.endianness)
+		return setInteger(v, uint64(n), int64(n), err)
+	case "uint64":
+		n, err := dec.ReadUint64(f.endianness)
+		if err == nil && !v.CanUint() && n > math.MaxInt64 {
+			return fmt.Errorf("value %d overflows %s", n, v.Type())
+		}
+		return setInteger(v, n, int64(n), err)
+	case "int8":
+		n, err := dec.ReadInt8()
+		return setInteger(v, uint64(n), int64(n), err)
+	case "int16":
+		n, err := dec.ReadInt16(f.endianness)
+		return setInteger(v, uint64(n), int64(n), err)
+	case "int32":
+		n, err := dec.ReadInt32(f.endianness)
+		return setInteger(v, uint64(n), int64(n), err)
+	case "int64":
+		n, err := dec.ReadInt64(f.endianness)
+		return setInteger(v, uint64(n), n, err)
+	case "float32":
+		x, err := dec.ReadFloat32(f.endianness)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(float64(x))
+	case "float64":
+		x, err := dec.ReadFloat64(f.endianness)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(x)
+	case "string":
+		s, err := f.decodeString(dec)
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case "array":
+		return f.decodeArray(dec, v)
+	case "struct":
+		if f.pointer {
+			v.Set(reflect.New(f.st.typ))
+			v = v.Elem()
+		}
+		return f.st.decode(dec, v)
+	}
+	return nil
+}
+
+// setInteger stores a decoded integer, rejecting values that overflow the Go field
+func setInteger(v reflect.Value, u uint64, i int64, err error) error {
+	if err != nil {
+		return err
+	}
+	if v.CanUint() {
+		if i < 0 || v.OverflowUint(u) {
+			return fmt.Errorf("value %d overflows %s", i, v.Type())
+		}
+		v.SetUint(u)
+		return nil
+	}
+	if v.OverflowInt(i) {
+		return fmt.Errorf("value %d overflows %s", i, v.Type())
+	}
+	v.SetInt(i)
+	return nil
+}
+
+func (f *fieldPlan) readLengthPrefix(dec *runtime.BitStreamDecoder) (int, error) {
+	var n uint64
+	var err error
+	switch f.lengthType {
+	case "uint8":
+		var x uint8
+		x, err = dec.ReadUint8()
+		n = uint64(x)
+	case "uint16":
+		var x uint16
+		x, err = dec.ReadUint16(f.endianness)
+		n = uint64(x)
+	case "uint32":
+		var x uint32
+		x, err = dec.ReadUint32(f.endianness)
+		n = uint64(x)
+	case "uint64":
+		n, err = dec.ReadUint64(f.endianness)
+	}
+	if err != nil {
+		return 0, err
+	}
+	// Every item takes at least one byte, so a larger count can't be satisfied
+	if remaining := dec.Len() - dec.Position(); n > uint64(remaining) {
+		errCode := runtime.ErrorIncompleteData
+		dec.LastErrorCode = &errCode
+		return 0, fmt.Errorf("length %d exceeds remaining %d bytes", n, remaining)
+	}
+	return int(n), nil
+}
+
+func (f *fieldPlan) decodeString(dec *runtime.BitStreamDecoder) (string, error) {
+	switch {
+	case f.lengthType != "":
+		n, err := f.readLengthPrefix(dec)
+		if err != nil {
+			return "", err
+		}
+		data, err := dec.ReadBytesSlice(n)
+		return string(data), err
+	case f.fixedLen >= 0:
+		data, err := dec.ReadBytesSlice(f.fixedLen)
+		if err != nil {
+			return "", err
+		}
+		if i := strings.IndexByte(string(data), 0); i >= 0 {
+			data = data[:i]
+		}
+		return string(data), nil
+	case f.nullTerm:
+		var data []byte
+		for {
+			b, err := dec.ReadUint8()
+			if err != nil {
+				return "", err
+			}
+			if b == 0 {
+				return string(data), nil
+			}
+			data = append(data, b)
+		}
+	default:
+		data, err := dec.ReadBytesSlice(dec.Len() - dec.Position())
+		return string(data), err
+	}
+}
+
+func (f *fieldPlan) decodeArray(dec *runtime.BitStreamDecoder, v reflect.Value) error {
+	n := f.fixedLen
+	if f.lengthType != "" {
+		var err error
+		if n, err = f.readLengthPrefix(dec); err != nil {
+			return err
+		}
+	}
+
+	if v.Kind() == reflect.Array {
+		for i := 0; i < n; i++ {
+			if err := f.elem.decode(dec, v.Index(i)); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+		return nil
+	}
+
+	byteSlice := f.elem.wire == "uint8" && v.Type().Elem().Kind() == reflect.Uint8
+	if f.eof {
+		if byteSlice {
+			data, err := dec.ReadBytesSlice(dec.Len() - dec.Position())
+			if err != nil {
+				return err
+			}
+			v.SetBytes(append([]byte{}, data...))
+			return nil
+		}
+		items := reflect.MakeSlice(v.Type(), 0, 0)
+		for dec.Position() < dec.Len() {
+			item := reflect.New(v.Type().Elem()).Elem()
+			if err := f.elem.decode(dec, item); err != nil {
+				return fmt.Errorf("[%d]: %w", items.Len(), err)
+			}
+			items = reflect.Append(items, item)
+		}
+		v.Set(items)
+		return nil
+	}
+
+	if byteSlice {
+		data, err := dec.ReadBytesSlice(n)
+		if err != nil {
+			return err
+		}
+		v.SetBytes(append([]byte{}, data...))
+		return nil
+	}
+	items := reflect.MakeSlice(v.Type(), n, n)
+	for i := 0; i < n; i++ {
+		if err := f.elem.decode(dec, items.Index(i)); err != nil {
+			return fmt.Errorf("[%d]: %w", i, err)
+		}
+	}
+	v.Set(items)
+	return nil
+}
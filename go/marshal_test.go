@@ -0,0 +1,186 @@
+// ABOUTME: Tests for struct-tag driven Marshal/Unmarshal
+// ABOUTME: Checks wire compatibility against the equivalent schema run through Dynamic
+package binschema
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type taggedPoint struct {
+	X int16 `bin:"int16,le"`
+	Y int16
+}
+
+type taggedPacket struct {
+	Version uint8
+	Length  int           `bin:"uint16"`
+	Name    string        `bin:",lenprefix=uint8"`
+	Label   string        `bin:",len=4"`
+	Path    string        `bin:",nullterm"`
+	Points  []taggedPoint `bin:",lenprefix=uint8"`
+	Corner  [2]uint16     `bin:",le"`
+	Origin  *taggedPoint
+	Scale   float32
+	cache   int
+	Skipped string `bin:"-"`
+	Trailer []byte `bin:",eof"`
+}
+
+type taggedTree struct {
+	Value    uint8
+	Children []taggedTree `bin:",lenprefix=uint8"`
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	packet := taggedPacket{
+		Version: 1,
+		Length:  0x0203,
+		Name:    "hi",
+		Label:   "ab",
+		Path:    "/x",
+		Points:  []taggedPoint{{X: -1, Y: 2}},
+		Corner:  [2]uint16{1, 0x0100},
+		Origin:  &taggedPoint{X: 5, Y: 6},
+		Scale:   1.5,
+		Trailer: []byte{0xAA, 0xBB},
+	}
+
+	encoded, err := Marshal(&packet)
+	require.NoError(t, err)
+	require.Equal(t, []byte{
+		0x01,
+		0x02, 0x03,
+		0x02, 'h', 'i',
+		'a', 'b', 0, 0,
+		'/', 'x', 0,
+		0x01, 0xFF, 0xFF, 0x00, 0x02,
+		0x01, 0x00, 0x00, 0x01,
+		0x05, 0x00, 0x00, 0x06,
+		0x3F, 0xC0, 0x00, 0x00,
+		0xAA, 0xBB,
+	}, encoded)
+
+	var decoded taggedPacket
+	require.NoError(t, Unmarshal(encoded, &decoded))
+	require.Equal(t, packet, decoded)
+
+	tree := taggedTree{Value: 1, Children: []taggedTree{{Value: 2, Children: []taggedTree{}}, {Value: 3, Children: []taggedTree{}}}}
+	encoded, err = Marshal(tree)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 2, 0, 3, 0}, encoded)
+	var decodedTree taggedTree
+	require.NoError(t, Unmarshal(encoded, &decodedTree))
+	require.Equal(t, tree, decodedTree)
+}
+
+// Struct tags and the equivalent schema must produce the same bytes
+func TestMarshalMatchesSchema(t *testing.T) {
+	dyn, err := CompileSchema([]byte(`{
+		config: { endianness: "big_endian" },
+		types: {
+			"Point": { sequence: [
+				{ name: "x", type: "int16", endianness: "little_endian" },
+				{ name: "y", type: "int16" },
+			] },
+			"Packet": { sequence: [
+				{ name: "version", type: "uint8" },
+				{ name: "length", type: "uint16" },
+				{ name: "name", type: "string", kind: "length_prefixed", length_type: "uint8", encoding: "utf8" },
+				{ name: "label", type: "string", kind: "fixed", length: 4, encoding: "utf8" },
+				{ name: "path", type: "string", kind: "null_terminated", encoding: "utf8" },
+				{ name: "points", type: "array", kind: "length_prefixed", length_type: "uint8", items: { type: "Point" } },
+				{ name: "corner", type: "array", kind: "fixed", length: 2, items: { type: "uint16", endianness: "little_endian" } },
+				{ name: "origin", type: "Point" },
+				{ name: "scale", type: "float32" },
+				{ name: "trailer", type: "array", kind: "eof_terminated", items: { type: "uint8" } },
+			] },
+		},
+	}`))
+	require.NoError(t, err)
+
+	fromSchema, err := dyn.Encode("Packet", map[string]interface{}{
+		"version": 7, "length": 300, "name": "héllo", "label": "abcdef", "path": "p",
+		"points":  []interface{}{map[string]interface{}{"x": 1, "y": -1}},
+		"corner":  []interface{}{3, 4},
+		"origin":  map[string]interface{}{"x": 0, "y": 9},
+		"scale":   -2.25,
+		"trailer": []interface{}{1, 2, 3},
+	})
+	require.NoError(t, err)
+
+	fromTags, err := Marshal(taggedPacket{
+		Version: 7, Length: 300, Name: "héllo", Label: "abcdef", Path: "p",
+		Points:  []taggedPoint{{X: 1, Y: -1}},
+		Corner:  [2]uint16{3, 4},
+		Origin:  &taggedPoint{X: 0, Y: 9},
+		Scale:   -2.25,
+		Trailer: []byte{1, 2, 3},
+	})
+	require.NoError(t, err)
+	require.Equal(t, fromSchema, fromTags)
+}
+
+func TestMarshalErrors(t *testing.T) {
+	_, err := Marshal(struct{ N int }{})
+	require.ErrorContains(t, err, "no fixed size")
+
+	_, err = Marshal(struct{ S string }{})
+	require.ErrorContains(t, err, "needs one of lenprefix")
+
+	_, err = Marshal(struct {
+		N uint8 `bin:"uint8,lenght=2"`
+	}{})
+	require.ErrorContains(t, err, `unknown tag option "lenght"`)
+
+	_, err = Marshal(struct {
+		N uint32 `bin:"float32"`
+	}{})
+	require.ErrorContains(t, err, "does not fit")
+
+	_, err = Marshal(struct {
+		N int32 `bin:"int8"`
+	}{N: 200})
+	require.ErrorContains(t, err, "out of range for int8")
+
+	_, err = Marshal(struct {
+		B []byte `bin:",lenprefix=uint8"`
+	}{B: make([]byte, 256)})
+	require.ErrorContains(t, err, "does not fit uint8 prefix")
+
+	_, err = Marshal(struct{ P *taggedPoint }{})
+	require.ErrorContains(t, err, "nested taggedPoint is nil")
+
+	_, err = Marshal(42)
+	require.Error(t, err)
+
+	var p taggedPoint
+	require.Error(t, Unmarshal([]byte{1}, &p))
+	require.Error(t, Unmarshal([]byte{1, 2, 3, 4}, p))
+
+	var narrow struct {
+		N int8 `bin:"uint8"`
+	}
+	require.ErrorContains(t, Unmarshal([]byte{200}, &narrow), "overflows int8")
+
+	// A length prefix larger than the input fails before allocating
+	var bytesField struct {
+		B []uint32 `bin:",lenprefix=uint32"`
+	}
+	require.Error(t, Unmarshal([]byte{0xFF, 0xFF, 0xFF, 0xFF}, &bytesField))
+}
+
+func TestMarshalPlanCache(t *testing.T) {
+	_, err := Marshal(taggedTree{})
+	require.NoError(t, err)
+	cached, ok := plans.Load(reflect.TypeOf(taggedTree{}))
+	require.True(t, ok)
+
+	plan, err := planFor(reflect.TypeOf(taggedTree{}))
+	require.NoError(t, err)
+	require.Same(t, cached, plan)
+	// The recursive slice refers back to the same plan
+	require.Same(t, plan, plan.fields[1].elem.st)
+}
@@ -0,0 +1,188 @@
+// ABOUTME: Registry of schema versions for message buses: versioned envelopes around generated types
+// ABOUTME: Encoding prefixes a schema id and version; decoding dispatches on them to the registered type
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/serialexp/binschema/runtime"
+)
+
+// Message is a value a registry can encode: every generated type implements it
+type Message interface {
+	Encode() ([]byte, error)
+}
+
+// Header identifies the schema and version an enveloped message was encoded with.
+// On the wire it is the id and then the version, each an unsigned LEB128 varint,
+// followed by the message itself.
+type Header struct {
+	ID      uint64
+	Version uint64
+}
+
+// Envelope is a decoded message with where it came from
+type Envelope struct {
+	Header
+	Name    string // Name the schema was registered under
+	Message Message
+}
+
+// UnknownSchemaError reports an envelope whose schema id or version isn't registered
+type UnknownSchemaError struct {
+	Header
+}
+
+func (e *UnknownSchemaError) Error() string {
+	return fmt.Sprintf("no schema registered with id %d version %d", e.ID, e.Version)
+}
+
+// ErrUnregisteredType is returned when encoding a value whose type isn't registered
+var ErrUnregisteredType = errors.New("message type is not registered")
+
+type entry struct {
+	name   string
+	header Header
+	decode func([]byte) (Message, error)
+}
+
+// Registry maps schema ids and versions to generated types. Producers and consumers
+// each register the versions they know; a consumer decodes any message whose version
+// it has registered, whichever version its producer encodes. It is safe for concurrent
+// use.
+type Registry struct {
+	mu       sync.RWMutex
+	versions map[Header]*entry
+	types    map[reflect.Type]*entry
+	names    map[string]uint64 // Name -> id; one id per name
+	ids      map[uint64]string // Id -> name
+}
+
+// New returns an empty registry
+func New() *Registry {
+	return &Registry{
+		versions: make(map[Header]*entry),
+		types:    make(map[reflect.Type]*entry),
+		names:    make(map[string]uint64),
+		ids:      make(map[uint64]string),
+	}
+}
+
+// Register adds version of the schema named name, with the given id, decoded with decode
+// (a generated DecodeX function) into *T:
+//
+//	registry.Register(r, "sensor.reading", 7, 2, v2.DecodeReading)
+//
+// Every version of a name shares its id. A version can only be registered once, and a
+// Go type under one version only, so encoding knows which header to write.
+func Register[T any, PT interface {
+	*T
+	Message
+}](r *Registry, name string, id, version uint64, decode func([]byte) (PT, error)) error {
+	typ := reflect.TypeOf(PT(nil))
+	header := Header{ID: id, Version: version}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if other, ok := r.names[name]; ok && other != id {
+		return fmt.Errorf("schema %q is registered with id %d, not %d", name, other, id)
+	}
+	if other, ok := r.ids[id]; ok && other != name {
+		return fmt.Errorf("id %d is registered for schema %q, not %q", id, other, name)
+	}
+	if _, ok := r.versions[header]; ok {
+		return fmt.Errorf("schema %q version %d is already registered", name, version)
+	}
+	if other, ok := r.types[typ]; ok {
+		return fmt.Errorf("type %s is already registered as schema %q version %d", typ, other.name, other.header.Version)
+	}
+
+	e := &entry{
+		name:   name,
+		header: header,
+		decode: func(data []byte) (Message, error) {
+			return decode(data)
+		},
+	}
+	r.versions[header] = e
+	r.types[typ] = e
+	r.names[name] = id
+	r.ids[id] = name
+	return nil
+}
+
+// Encode encodes msg behind the header of the schema version its type is registered as
+func (r *Registry) Encode(msg Message) ([]byte, error) {
+	r.mu.RLock()
+	e, ok := r.types[reflect.TypeOf(msg)]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrUnregisteredType, msg)
+	}
+	payload, err := msg.Encode()
+	if err != nil {
+		return nil, fmt.Errorf("%s v%d: %w", e.name, e.header.Version, err)
+	}
+	return append(AppendHeader(nil, e.header), payload...), nil
+}
+
+// Decode reads an envelope's header and decodes the message with the type registered
+// for its schema version. Unregistered versions return an *UnknownSchemaError.
+func (r *Registry) Decode(data []byte) (*Envelope, error) {
+	header, n, err := ParseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	e, ok := r.versions[header]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, &UnknownSchemaError{Header: header}
+	}
+	msg, err := e.decode(data[n:])
+	if err != nil {
+		return nil, fmt.Errorf("%s v%d: %w", e.name, header.Version, err)
+	}
+	return &Envelope{Header: header, Name: e.name, Message: msg}, nil
+}
+
+// Versions returns the registered versions of a schema, in increasing order
+func (r *Registry) Versions(name string) []uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var versions []uint64
+	for header, e := range r.versions {
+		if e.name == name {
+			versions = append(versions, header.Version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+// AppendHeader appends the encoding of an envelope header to dst
+func AppendHeader(dst []byte, header Header) []byte {
+	encoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	encoder.WriteVarlengthLEB128(header.ID)
+	encoder.WriteVarlengthLEB128(header.Version)
+	return append(dst, encoder.Finish()...)
+}
+
+// ParseHeader reads an envelope header from the start of data, returning it and its
+// length in bytes
+func ParseHeader(data []byte) (Header, int, error) {
+	decoder := runtime.NewBitStreamDecoder(data, runtime.MSBFirst)
+	id, err := decoder.ReadVarlengthLEB128()
+	if err != nil {
+		return Header{}, 0, fmt.Errorf("envelope schema id: %w", err)
+	}
+	version, err := decoder.ReadVarlengthLEB128()
+	if err != nil {
+		return Header{}, 0, fmt.Errorf("envelope version: %w", err)
+	}
+	return Header{ID: id, Version: version}, decoder.Position(), nil
+}
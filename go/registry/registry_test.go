@@ -0,0 +1,81 @@
+// ABOUTME: Tests for the schema registry and its versioned envelopes
+// ABOUTME: Uses small hand-written types standing in for generated ones
+package registry
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type readingV1 struct{ Value uint8 }
+
+func (m *readingV1) Encode() ([]byte, error) { return []byte{m.Value}, nil }
+
+func decodeReadingV1(data []byte) (*readingV1, error) {
+	if len(data) != 1 {
+		return nil, fmt.Errorf("want 1 byte, got %d", len(data))
+	}
+	return &readingV1{Value: data[0]}, nil
+}
+
+type readingV2 struct{ Value uint16 }
+
+func (m *readingV2) Encode() ([]byte, error) { return binary.BigEndian.AppendUint16(nil, m.Value), nil }
+
+func decodeReadingV2(data []byte) (*readingV2, error) {
+	if len(data) != 2 {
+		return nil, fmt.Errorf("want 2 bytes, got %d", len(data))
+	}
+	return &readingV2{Value: binary.BigEndian.Uint16(data)}, nil
+}
+
+func TestRegistry(t *testing.T) {
+	r := New()
+	require.NoError(t, Register(r, "reading", 300, 1, decodeReadingV1))
+	require.NoError(t, Register(r, "reading", 300, 2, decodeReadingV2))
+	require.Equal(t, []uint64{1, 2}, r.Versions("reading"))
+
+	encoded, err := r.Encode(&readingV2{Value: 0x1234})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xac, 0x02, 0x02, 0x12, 0x34}, encoded)
+
+	envelope, err := r.Decode(encoded)
+	require.NoError(t, err)
+	require.Equal(t, &Envelope{Header: Header{ID: 300, Version: 2}, Name: "reading", Message: &readingV2{Value: 0x1234}}, envelope)
+
+	encoded, err = r.Encode(&readingV1{Value: 7})
+	require.NoError(t, err)
+	envelope, err = r.Decode(encoded)
+	require.NoError(t, err)
+	require.Equal(t, &readingV1{Value: 7}, envelope.Message)
+
+	// A consumer that only knows version 1 rejects version 2 with the header it saw
+	consumer := New()
+	require.NoError(t, Register(consumer, "reading", 300, 1, decodeReadingV1))
+	_, err = consumer.Decode([]byte{0xac, 0x02, 0x02, 0x12, 0x34})
+	var unknown *UnknownSchemaError
+	require.True(t, errors.As(err, &unknown), "got %v", err)
+	require.Equal(t, Header{ID: 300, Version: 2}, unknown.Header)
+
+	_, err = r.Decode([]byte{0xac, 0x02, 0x01, 0x12, 0x34})
+	require.EqualError(t, err, "reading v1: want 1 byte, got 2")
+	_, err = r.Decode([]byte{0xac})
+	require.ErrorContains(t, err, "envelope schema id")
+}
+
+func TestRegisterConflicts(t *testing.T) {
+	r := New()
+	require.NoError(t, Register(r, "reading", 1, 1, decodeReadingV1))
+
+	require.EqualError(t, Register(r, "reading", 2, 2, decodeReadingV2), `schema "reading" is registered with id 1, not 2`)
+	require.EqualError(t, Register(r, "other", 1, 2, decodeReadingV2), `id 1 is registered for schema "reading", not "other"`)
+	require.EqualError(t, Register(r, "reading", 1, 1, decodeReadingV2), `schema "reading" version 1 is already registered`)
+	require.EqualError(t, Register(r, "reading", 1, 3, decodeReadingV1), `type *registry.readingV1 is already registered as schema "reading" version 1`)
+
+	_, err := r.Encode(&readingV2{})
+	require.ErrorIs(t, err, ErrUnregisteredType)
+}
@@ -0,0 +1,170 @@
+package runtime
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// UUID is a 16-byte identifier in RFC 9562 byte order. It prints and marshals to JSON
+// in the usual 8-4-4-4-12 hex form.
+type UUID [16]byte
+
+// ParseUUID reads the 8-4-4-4-12 hex form, with or without braces or a urn:uuid: prefix
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	trimmed := strings.TrimPrefix(strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}"), "urn:uuid:")
+	if len(trimmed) != 36 || trimmed[8] != '-' || trimmed[13] != '-' || trimmed[18] != '-' || trimmed[23] != '-' {
+		return u, fmt.Errorf("invalid UUID %q", s)
+	}
+	digits := trimmed[:8] + trimmed[9:13] + trimmed[14:18] + trimmed[19:23] + trimmed[24:]
+	if _, err := hex.Decode(u[:], []byte(digits)); err != nil {
+		return u, fmt.Errorf("invalid UUID %q", s)
+	}
+	return u, nil
+}
+
+// String formats the UUID as 8-4-4-4-12 lowercase hex
+func (u UUID) String() string {
+	h := hex.EncodeToString(u[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// MarshalText writes the UUID's string form
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText reads a UUID in any form ParseUUID accepts
+func (u *UUID) UnmarshalText(text []byte) error {
+	parsed, err := ParseUUID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// MAC is a 6-byte Ethernet hardware address. It prints and marshals to JSON as
+// colon-separated hex.
+type MAC [6]byte
+
+// ParseMAC reads six hex bytes separated by colons or hyphens
+func ParseMAC(s string) (MAC, error) {
+	var m MAC
+	if len(s) != 17 {
+		return m, fmt.Errorf("invalid MAC address %q", s)
+	}
+	for i := range m {
+		if i > 0 && s[i*3-1] != ':' && s[i*3-1] != '-' {
+			return m, fmt.Errorf("invalid MAC address %q", s)
+		}
+		if _, err := hex.Decode(m[i:i+1], []byte(s[i*3:i*3+2])); err != nil {
+			return m, fmt.Errorf("invalid MAC address %q", s)
+		}
+	}
+	return m, nil
+}
+
+// String formats the address as colon-separated lowercase hex
+func (m MAC) String() string {
+	var b strings.Builder
+	for i, v := range m {
+		if i > 0 {
+			b.WriteByte(':')
+		}
+		fmt.Fprintf(&b, "%02x", v)
+	}
+	return b.String()
+}
+
+// MarshalText writes the address's string form
+func (m MAC) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText reads an address in any form ParseMAC accepts
+func (m *MAC) UnmarshalText(text []byte) error {
+	parsed, err := ParseMAC(string(text))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// readFull fills b from the stream, aligned or not
+func (d *BitStreamDecoder) readFull(b []byte) error {
+	for i := range b {
+		v, err := d.ReadUint8()
+		if err != nil {
+			return err
+		}
+		b[i] = v
+	}
+	return nil
+}
+
+// ReadUUID reads a 16-byte UUID
+func (d *BitStreamDecoder) ReadUUID() (UUID, error) {
+	var u UUID
+	err := d.readFull(u[:])
+	return u, err
+}
+
+// WriteUUID writes a 16-byte UUID
+func (e *BitStreamEncoder) WriteUUID(value UUID) {
+	e.WriteBytes(value[:])
+}
+
+// ReadMAC reads a 6-byte hardware address
+func (d *BitStreamDecoder) ReadMAC() (MAC, error) {
+	var m MAC
+	err := d.readFull(m[:])
+	return m, err
+}
+
+// WriteMAC writes a 6-byte hardware address
+func (e *BitStreamEncoder) WriteMAC(value MAC) {
+	e.WriteBytes(value[:])
+}
+
+// ReadIPv4 reads a 4-byte IPv4 address in network byte order
+func (d *BitStreamDecoder) ReadIPv4() (netip.Addr, error) {
+	var b [4]byte
+	err := d.readFull(b[:])
+	return netip.AddrFrom4(b), err
+}
+
+// WriteIPv4 writes a 4-byte IPv4 address in network byte order. An IPv4-mapped IPv6
+// address is written as its IPv4 address, and the zero Addr as 0.0.0.0.
+func (e *BitStreamEncoder) WriteIPv4(addr netip.Addr) error {
+	var b [4]byte
+	if addr.IsValid() {
+		addr = addr.Unmap()
+		if !addr.Is4() {
+			return fmt.Errorf("%s is not an IPv4 address", addr)
+		}
+		b = addr.As4()
+	}
+	e.WriteBytes(b[:])
+	return nil
+}
+
+// ReadIPv6 reads a 16-byte IPv6 address in network byte order
+func (d *BitStreamDecoder) ReadIPv6() (netip.Addr, error) {
+	var b [16]byte
+	err := d.readFull(b[:])
+	return netip.AddrFrom16(b), err
+}
+
+// WriteIPv6 writes a 16-byte IPv6 address in network byte order. An IPv4 address is
+// written IPv4-mapped, and the zero Addr as ::.
+func (e *BitStreamEncoder) WriteIPv6(addr netip.Addr) {
+	var b [16]byte
+	if addr.IsValid() {
+		b = addr.As16()
+	}
+	e.WriteBytes(b[:])
+}
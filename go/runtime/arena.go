@@ -0,0 +1,116 @@
+package runtime
+
+import "sync"
+
+// DecodeArena hands out the structs and slices a decode allocates from reusable
+// slabs, one per type, so decoding message after message reuses the same memory
+// instead of making garbage. Reset releases everything it handed out in one call:
+// values decoded with the arena must not be used after that. An arena is not safe
+// for concurrent use; a nil *DecodeArena allocates from the heap.
+type DecodeArena struct {
+	slabs map[interface{}]arenaSlab // Keyed by a nil *T, whose dynamic type tells slabs apart without reflect
+}
+
+type arenaSlab interface {
+	reset()
+}
+
+// slab hands out the elements of its chunks in order. Chunks are kept across
+// resets; each new one is twice the size of the last.
+type slab[T any] struct {
+	chunks [][]T
+	chunk  int // Chunk being handed out
+	used   int // Elements of it handed out
+}
+
+// Elements in a type's first chunk
+const arenaFirstChunk = 16
+
+// NewDecodeArena returns an empty arena
+func NewDecodeArena() *DecodeArena {
+	return &DecodeArena{}
+}
+
+// Reset zeroes everything the arena handed out and makes it available again
+func (a *DecodeArena) Reset() {
+	for _, s := range a.slabs {
+		s.reset()
+	}
+}
+
+var arenaPool = sync.Pool{
+	New: func() interface{} {
+		return NewDecodeArena()
+	},
+}
+
+// AcquireDecodeArena gets an arena from the pool
+func AcquireDecodeArena() *DecodeArena {
+	return arenaPool.Get().(*DecodeArena)
+}
+
+// ReleaseDecodeArena resets an arena and returns it to the pool
+func ReleaseDecodeArena(a *DecodeArena) {
+	if a == nil {
+		return
+	}
+	a.Reset()
+	arenaPool.Put(a)
+}
+
+// ArenaNew returns a zero T from the arena, or from the heap if a is nil
+func ArenaNew[T any](a *DecodeArena) *T {
+	if a == nil {
+		return new(T)
+	}
+	return &slabFor[T](a).take(1)[0]
+}
+
+// ArenaSlice returns a slice of n zero Ts from the arena, or from the heap if a is
+// nil. Its capacity is n, so appending to it never writes over another slice.
+func ArenaSlice[T any](a *DecodeArena, n int) []T {
+	if a == nil {
+		return make([]T, n)
+	}
+	return slabFor[T](a).take(n)
+}
+
+func slabFor[T any](a *DecodeArena) *slab[T] {
+	var key interface{} = (*T)(nil)
+	if s, ok := a.slabs[key]; ok {
+		return s.(*slab[T])
+	}
+	if a.slabs == nil {
+		a.slabs = make(map[interface{}]arenaSlab)
+	}
+	s := &slab[T]{}
+	a.slabs[key] = s
+	return s
+}
+
+func (s *slab[T]) take(n int) []T {
+	for ; s.chunk < len(s.chunks); s.chunk, s.used = s.chunk+1, 0 {
+		if c := s.chunks[s.chunk]; s.used+n <= len(c) {
+			s.used += n
+			return c[s.used-n : s.used : s.used]
+		}
+	}
+	size := arenaFirstChunk
+	if len(s.chunks) > 0 {
+		size = 2 * len(s.chunks[len(s.chunks)-1])
+	}
+	size = max(size, n)
+	s.chunks = append(s.chunks, make([]T, size))
+	s.chunk, s.used = len(s.chunks)-1, n
+	return s.chunks[s.chunk][:n:n]
+}
+
+func (s *slab[T]) reset() {
+	for i := 0; i < s.chunk && i < len(s.chunks); i++ {
+		clear(s.chunks[i])
+	}
+	if s.chunk < len(s.chunks) {
+		clear(s.chunks[s.chunk][:s.used])
+	}
+	s.chunk, s.used = 0, 0
+}
@@ -0,0 +1,92 @@
+package runtime
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// BCDSize returns the bytes taken by a BCD number of the given digits: one per digit,
+// or one per two digits when packed, with a leading zero nibble if the count is odd
+func BCDSize(digits int, packed bool) int {
+	if packed {
+		return (digits + 1) / 2
+	}
+	return digits
+}
+
+// ReadBCD reads a binary-coded decimal number as its digits, leading zeros included.
+// Unpacked BCD has a digit in each byte; packed BCD two, the first in the high nibble.
+func (d *BitStreamDecoder) ReadBCD(digits int, packed bool) (string, error) {
+	out := make([]byte, 0, digits+1)
+	for i := 0; i < BCDSize(digits, packed); i++ {
+		b, err := d.ReadUint8()
+		if err != nil {
+			return "", err
+		}
+		if !packed {
+			if b > 9 {
+				return "", fmt.Errorf("byte %#x is not a decimal digit", b)
+			}
+			out = append(out, '0'+b)
+			continue
+		}
+		if b>>4 > 9 || b&0x0f > 9 {
+			return "", fmt.Errorf("byte %#x is not two decimal digits", b)
+		}
+		out = append(out, '0'+b>>4, '0'+b&0x0f)
+	}
+	if len(out) > digits {
+		// Odd digit count: the leading nibble is padding
+		if out[0] != '0' {
+			return "", fmt.Errorf("padding nibble of %d-digit packed BCD is %c, not 0", digits, out[0])
+		}
+		out = out[1:]
+	}
+	return string(out), nil
+}
+
+// ReadBCDInt reads a binary-coded decimal number of at most 18 digits as an int64
+func (d *BitStreamDecoder) ReadBCDInt(digits int, packed bool) (int64, error) {
+	s, err := d.ReadBCD(digits, packed)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// WriteBCD writes a string of exactly digits decimal digits as binary-coded decimal
+func (e *BitStreamEncoder) WriteBCD(value string, digits int, packed bool) error {
+	if len(value) != digits {
+		return fmt.Errorf("%q is not %d decimal digits", value, digits)
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return fmt.Errorf("%q is not %d decimal digits", value, digits)
+		}
+	}
+	if !packed {
+		for i := 0; i < len(value); i++ {
+			e.WriteUint8(value[i] - '0')
+		}
+		return nil
+	}
+	if digits%2 == 1 {
+		value = "0" + value
+	}
+	for i := 0; i < len(value); i += 2 {
+		e.WriteUint8((value[i]-'0')<<4 | (value[i+1] - '0'))
+	}
+	return nil
+}
+
+// WriteBCDInt writes a non-negative int64 as binary-coded decimal, zero-padded to digits
+func (e *BitStreamEncoder) WriteBCDInt(value int64, digits int, packed bool) error {
+	s := strconv.FormatInt(value, 10)
+	if value < 0 || len(s) > digits {
+		return fmt.Errorf("%d doesn't fit in %d decimal digits", value, digits)
+	}
+	for len(s) < digits {
+		s = "0" + s
+	}
+	return e.WriteBCD(s, digits, packed)
+}
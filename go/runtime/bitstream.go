@@ -6,6 +6,7 @@
 package runtime
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -20,6 +21,7 @@ type Endianness int
 const (
 	BigEndian Endianness = iota
 	LittleEndian
+	DynamicEndian // The byte order set on the encoder or decoder with SetEndianness
 )
 
 // BitOrder specifies bit packing order within bytes
@@ -32,11 +34,14 @@ const (
 
 // BitStreamEncoder writes bits to a byte stream
 type BitStreamEncoder struct {
-	bytes           []byte
-	currentByte     byte
-	bitOffset       int // Bits used in currentByte (0-7)
-	totalBitsWritten int
-	bitOrder        BitOrder
+	bytes       []byte
+	currentByte byte
+	bitOffset   int // Bits used in currentByte (0-7)
+	bitOrder    BitOrder
+	endianness  Endianness // What DynamicEndian means, chosen while encoding
+	limit       int        // Length the output may reach, when limited
+	limited     bool
+	err         error // ErrOutputTooLarge once a write passed limit
 }
 
 // NewBitStreamEncoder creates a new encoder with the specified bit order
@@ -55,11 +60,82 @@ func (e *BitStreamEncoder) Position() int {
 	return len(e.bytes)
 }
 
+// BitPosition returns the number of bits written so far, counting the bits of a partly
+// written byte
+func (e *BitStreamEncoder) BitPosition() int {
+	return len(e.bytes)*8 + e.bitOffset
+}
+
+// Len returns the length Finish would return now: the bytes written, a partly written
+// byte included
+func (e *BitStreamEncoder) Len() int {
+	return e.Position()
+}
+
+// BytesWritten returns the number of whole bytes written, leaving out a partly written
+// byte. Bytes up to it are final apart from patched placeholders.
+func (e *BitStreamEncoder) BytesWritten() int {
+	return len(e.bytes)
+}
+
+// SetLimit makes writes that would take the output past maxBytes fail: they are
+// dropped, and CheckLimit and Result return ErrOutputTooLarge from then on. A
+// negative maxBytes removes the limit.
+func (e *BitStreamEncoder) SetLimit(maxBytes int) {
+	e.limit = maxBytes
+	e.limited = maxBytes >= 0
+}
+
+// fits reports whether n more bytes stay within the encoder's limit, recording
+// ErrOutputTooLarge when they don't
+func (e *BitStreamEncoder) fits(n int) bool {
+	if !e.limited {
+		return true
+	}
+	if e.err != nil {
+		return false
+	}
+	if len(e.bytes)+n > e.limit {
+		e.err = fmt.Errorf("%w: more than %d bytes", ErrOutputTooLarge, e.limit)
+		return false
+	}
+	return true
+}
+
+// CheckLimit is called by generated code for each array item it encodes. It returns
+// ErrOutputTooLarge once a write has passed the encoder's limit, so an encode too
+// large to send stops early instead of running to the end.
+func (e *BitStreamEncoder) CheckLimit() error {
+	return e.err
+}
+
+// Result returns the encoded bytes like Finish, or the error that stopped the encoder
+func (e *BitStreamEncoder) Result() ([]byte, error) {
+	bytes := e.Finish()
+	if e.err != nil {
+		return nil, e.err
+	}
+	return bytes, nil
+}
+
+// SetEndianness sets the byte order of fields written with DynamicEndian, for formats
+// whose byte order is chosen by a field (TIFF "II"/"MM") or negotiated at runtime
+func (e *BitStreamEncoder) SetEndianness(endianness Endianness) {
+	e.endianness = endianness
+}
+
+// Endianness returns the byte order of fields written with DynamicEndian
+func (e *BitStreamEncoder) Endianness() Endianness {
+	return e.endianness
+}
+
 // Finish returns the encoded bytes, flushing any partial byte
 func (e *BitStreamEncoder) Finish() []byte {
 	// Flush partial byte if any
 	if e.bitOffset > 0 {
-		e.bytes = append(e.bytes, e.currentByte)
+		if e.fits(1) {
+			e.bytes = append(e.bytes, e.currentByte)
+		}
 		e.currentByte = 0
 		e.bitOffset = 0
 	}
@@ -72,9 +148,25 @@ type BitStreamDecoder struct {
 	byteOffset    int
 	bitOffset     int // Bits read from current byte (0-7)
 	bitOrder      BitOrder
-	LastErrorCode *string // Cross-language error handling
+	endianness    Endianness      // What DynamicEndian means, chosen while decoding
+	depth         int             // Current nesting depth of recursive type decodes
+	LastErrorCode *string         // Cross-language error handling
+	Trace         TraceSink       // Receives traced reads; generated decoders take their traced copy when set
+	Arena         *DecodeArena    // Structs and slices of the decoded value come from here when set
+	Context       context.Context // Checked for cancellation while decoding arrays when set
+	UTF8          UTF8Policy      // Overrides the schema's policy for invalid UTF-8 in strings when set
+	traceStack    []TraceEvent
+	traceOrder    int
+	checks        int // CheckCanceled calls since Context was last checked
 }
 
+// Array items decoded between checks of a decoder's Context
+const CancelCheckInterval = 1024
+
+// MaxNestingDepth bounds how deeply recursive types (trees, nested containers)
+// may nest during decoding, so hostile input can't exhaust the stack.
+const MaxNestingDepth = 128
+
 // NewBitStreamDecoder creates a new decoder with the specified bit order
 func NewBitStreamDecoder(bytes []byte, bitOrder BitOrder) *BitStreamDecoder {
 	return &BitStreamDecoder{
@@ -89,7 +181,16 @@ func (d *BitStreamDecoder) Reset(bytes []byte, bitOrder BitOrder) {
 	d.byteOffset = 0
 	d.bitOffset = 0
 	d.bitOrder = bitOrder
+	d.endianness = BigEndian
+	d.depth = 0
 	d.LastErrorCode = nil
+	d.Trace = nil
+	d.Arena = nil
+	d.Context = nil
+	d.UTF8 = UTF8Default
+	d.checks = 0
+	d.traceStack = d.traceStack[:0]
+	d.traceOrder = 0
 }
 
 // Decoder pools for different bit orders
@@ -124,6 +225,8 @@ func ReleaseDecoder(d *BitStreamDecoder) {
 		return
 	}
 	d.bytes = nil // Allow GC of the byte slice
+	d.Arena = nil
+	d.Context = nil
 	if d.bitOrder == MSBFirst {
 		decoderPoolMSB.Put(d)
 	} else {
@@ -131,11 +234,73 @@ func ReleaseDecoder(d *BitStreamDecoder) {
 	}
 }
 
+// EnterNested records entry into a recursive type's decode function.
+// Returns a CIRCULAR_REFERENCE error once MaxNestingDepth is exceeded.
+// Every successful call must be paired with ExitNested.
+func (d *BitStreamDecoder) EnterNested() error {
+	if d.depth >= MaxNestingDepth {
+		errCode := ErrorCircularReference
+		d.LastErrorCode = &errCode
+		return fmt.Errorf("maximum nesting depth of %d exceeded", MaxNestingDepth)
+	}
+	d.depth++
+	return nil
+}
+
+// ExitNested records exit from a recursive type's decode function
+func (d *BitStreamDecoder) ExitNested() {
+	if d.depth > 0 {
+		d.depth--
+	}
+}
+
+// SetBitOrder changes how bits are packed in the bytes read from here on, for a
+// nested type whose bit order differs from its parent's
+func (d *BitStreamDecoder) SetBitOrder(bitOrder BitOrder) {
+	d.bitOrder = bitOrder
+}
+
+// BitOrder returns the bit order bits are read in
+func (d *BitStreamDecoder) BitOrder() BitOrder {
+	return d.bitOrder
+}
+
+// SetEndianness sets the byte order of fields read with DynamicEndian
+func (d *BitStreamDecoder) SetEndianness(endianness Endianness) {
+	d.endianness = endianness
+}
+
+// Endianness returns the byte order of fields read with DynamicEndian
+func (d *BitStreamDecoder) Endianness() Endianness {
+	return d.endianness
+}
+
+// CheckCanceled is called by generated code for each array item it decodes. Every
+// CancelCheckInterval calls it returns the error of the decoder's Context, if it has one
+// and it is done, so a decode of hostile or giant input can be bounded in time.
+func (d *BitStreamDecoder) CheckCanceled() error {
+	if d.Context == nil {
+		return nil
+	}
+	d.checks++
+	if d.checks < CancelCheckInterval {
+		return nil
+	}
+	d.checks = 0
+	return d.Context.Err()
+}
+
 // Position returns the current byte offset
 func (d *BitStreamDecoder) Position() int {
 	return d.byteOffset
 }
 
+// BitPosition returns the number of bits read so far, counting the bits of a partly
+// read byte
+func (d *BitStreamDecoder) BitPosition() int {
+	return d.byteOffset*8 + d.bitOffset
+}
+
 // SkipBytes skips the specified number of bytes
 func (d *BitStreamDecoder) SkipBytes(n int) {
 	d.byteOffset += n
@@ -152,6 +317,14 @@ func (d *BitStreamDecoder) Len() int {
 	return len(d.bytes)
 }
 
+// Incomplete marks err as the input ending early, as reads past its end are, so callers
+// with more input to come (StatefulDecoder) can wait for it
+func (d *BitStreamDecoder) Incomplete(err error) error {
+	errCode := ErrorIncompleteData
+	d.LastErrorCode = &errCode
+	return err
+}
+
 // Bytes returns the underlying byte slice (for calculating EOF-relative positions)
 func (d *BitStreamDecoder) Bytes() []byte {
 	return d.bytes
@@ -167,7 +340,7 @@ func (d *BitStreamDecoder) ReadBytesSlice(n int) ([]byte, error) {
 	if d.byteOffset+n > len(d.bytes) {
 		errCode := "INCOMPLETE_DATA"
 		d.LastErrorCode = &errCode
-		return nil, errors.New("unexpected end of stream")
+		return nil, endOfStream()
 	}
 	slice := d.bytes[d.byteOffset : d.byteOffset+n]
 	d.byteOffset += n
@@ -175,6 +348,16 @@ func (d *BitStreamDecoder) ReadBytesSlice(n int) ([]byte, error) {
 	return slice, nil
 }
 
+// PeekAligned returns the next n bytes without consuming them, if the decoder is
+// byte-aligned and has that many left, so generated code can read a run of
+// fixed-width fields after one bounds check and then skip past them
+func (d *BitStreamDecoder) PeekAligned(n int) ([]byte, bool) {
+	if d.bitOffset != 0 || d.byteOffset+n > len(d.bytes) {
+		return nil, false
+	}
+	return d.bytes[d.byteOffset : d.byteOffset+n : d.byteOffset+n], true
+}
+
 // ReadUint8 reads an 8-bit unsigned integer
 func (d *BitStreamDecoder) ReadUint8() (uint8, error) {
 	if d.bitOffset == 0 {
@@ -182,7 +365,7 @@ func (d *BitStreamDecoder) ReadUint8() (uint8, error) {
 		if d.byteOffset >= len(d.bytes) {
 			errCode := "INCOMPLETE_DATA"
 			d.LastErrorCode = &errCode
-			return 0, errors.New("unexpected end of stream")
+			return 0, endOfStream()
 		}
 		d.LastErrorCode = nil
 		val := d.bytes[d.byteOffset]
@@ -208,7 +391,7 @@ func (d *BitStreamDecoder) ReadBit() (uint8, error) {
 	if d.byteOffset >= len(d.bytes) {
 		errCode := "INCOMPLETE_DATA"
 		d.LastErrorCode = &errCode
-		return 0, errors.New("unexpected end of stream")
+		return 0, endOfStream()
 	}
 
 	currentByte := d.bytes[d.byteOffset]
@@ -241,7 +424,7 @@ func (d *BitStreamDecoder) ReadBits(numBits int) (uint64, error) {
 		if d.byteOffset >= len(d.bytes) {
 			errCode := "INCOMPLETE_DATA"
 			d.LastErrorCode = &errCode
-			return 0, errors.New("unexpected end of stream")
+			return 0, endOfStream()
 		}
 		bitsAvailable := 8 - d.bitOffset
 		if numBits <= bitsAvailable {
@@ -261,7 +444,7 @@ func (d *BitStreamDecoder) ReadBits(numBits int) (uint64, error) {
 		if d.byteOffset+1 >= len(d.bytes) {
 			errCode := "INCOMPLETE_DATA"
 			d.LastErrorCode = &errCode
-			return 0, errors.New("unexpected end of stream")
+			return 0, endOfStream()
 		}
 		// Bits from current byte (high bits of result)
 		bitsFromFirst := bitsAvailable
@@ -282,6 +465,31 @@ func (d *BitStreamDecoder) ReadBits(numBits int) (uint64, error) {
 		return highPart | lowPart, nil
 	}
 
+	// Fast path: up to 56 bits the data holds, gathered into one word, so a group of
+	// bit fields costs one read
+	if numBits > 0 && numBits <= 56 {
+		end := d.bitOffset + numBits
+		n := (end + 7) / 8
+		if d.byteOffset+n <= len(d.bytes) {
+			var word, result uint64
+			if d.bitOrder == MSBFirst {
+				for _, b := range d.bytes[d.byteOffset : d.byteOffset+n] {
+					word = word<<8 | uint64(b)
+				}
+				result = word >> (n*8 - end)
+			} else {
+				for i, b := range d.bytes[d.byteOffset : d.byteOffset+n] {
+					word |= uint64(b) << (8 * i)
+				}
+				result = word >> d.bitOffset
+			}
+			d.byteOffset += end / 8
+			d.bitOffset = end % 8
+			d.LastErrorCode = nil
+			return result & (1<<numBits - 1), nil
+		}
+	}
+
 	var result uint64
 	if d.bitOrder == LSBFirst {
 		// LSB first: first bit read is bit 0 of result
@@ -310,7 +518,9 @@ func (d *BitStreamDecoder) ReadBits(numBits int) (uint64, error) {
 func (e *BitStreamEncoder) WriteUint8(value uint8) {
 	if e.bitOffset == 0 {
 		// Byte-aligned: write directly
-		e.bytes = append(e.bytes, value)
+		if e.fits(1) {
+			e.bytes = append(e.bytes, value)
+		}
 	} else {
 		// Not byte-aligned: write bit by bit (LSB first for byte values)
 		for i := 0; i < 8; i++ {
@@ -320,11 +530,20 @@ func (e *BitStreamEncoder) WriteUint8(value uint8) {
 	}
 }
 
-// WriteBytes writes a slice of bytes to the encoder
+// WriteBytes writes a slice of bytes to the encoder, as WriteAlignedBytes does
 func (e *BitStreamEncoder) WriteBytes(data []byte) {
+	e.WriteAlignedBytes(data)
+}
+
+// WriteAlignedBytes writes a slice of bytes, appended whole when the encoder is
+// byte-aligned and byte by byte, as WriteUint8 writes them, when it isn't. Generated
+// encoders write strings, byte arrays and nested output with it.
+func (e *BitStreamEncoder) WriteAlignedBytes(data []byte) {
 	if e.bitOffset == 0 {
 		// Byte-aligned: append directly
-		e.bytes = append(e.bytes, data...)
+		if e.fits(len(data)) {
+			e.bytes = append(e.bytes, data...)
+		}
 	} else {
 		// Not byte-aligned: write byte by byte
 		for _, b := range data {
@@ -344,10 +563,11 @@ func (e *BitStreamEncoder) WriteBit(bit uint8) {
 	}
 
 	e.bitOffset++
-	e.totalBitsWritten++
 
 	if e.bitOffset == 8 {
-		e.bytes = append(e.bytes, e.currentByte)
+		if e.fits(1) {
+			e.bytes = append(e.bytes, e.currentByte)
+		}
 		e.currentByte = 0
 		e.bitOffset = 0
 	}
@@ -366,9 +586,10 @@ func (e *BitStreamEncoder) WriteBits(value uint64, numBits int) {
 			mask := uint8((1 << numBits) - 1)
 			e.currentByte |= (uint8(value) & mask) << shift
 			e.bitOffset += numBits
-			e.totalBitsWritten += numBits
 			if e.bitOffset == 8 {
-				e.bytes = append(e.bytes, e.currentByte)
+				if e.fits(1) {
+					e.bytes = append(e.bytes, e.currentByte)
+				}
 				e.currentByte = 0
 				e.bitOffset = 0
 			}
@@ -391,11 +612,14 @@ func (e *BitStreamEncoder) WriteBits(value uint64, numBits int) {
 
 // ReadUint16 reads a 16-bit unsigned integer
 func (d *BitStreamDecoder) ReadUint16(endianness Endianness) (uint16, error) {
+	if endianness == DynamicEndian {
+		endianness = d.endianness
+	}
 	if d.bitOffset == 0 {
 		if d.byteOffset+2 > len(d.bytes) {
 			errCode := "INCOMPLETE_DATA"
 			d.LastErrorCode = &errCode
-			return 0, errors.New("unexpected end of stream")
+			return 0, endOfStream()
 		}
 		var v uint16
 		if endianness == BigEndian {
@@ -436,11 +660,14 @@ func (d *BitStreamDecoder) ReadUint16(endianness Endianness) (uint16, error) {
 
 // ReadUint32 reads a 32-bit unsigned integer
 func (d *BitStreamDecoder) ReadUint32(endianness Endianness) (uint32, error) {
+	if endianness == DynamicEndian {
+		endianness = d.endianness
+	}
 	if d.bitOffset == 0 {
 		if d.byteOffset+4 > len(d.bytes) {
 			errCode := "INCOMPLETE_DATA"
 			d.LastErrorCode = &errCode
-			return 0, errors.New("unexpected end of stream")
+			return 0, endOfStream()
 		}
 		var v uint32
 		if endianness == BigEndian {
@@ -497,11 +724,14 @@ func (d *BitStreamDecoder) ReadUint32(endianness Endianness) (uint32, error) {
 
 // ReadUint64 reads a 64-bit unsigned integer
 func (d *BitStreamDecoder) ReadUint64(endianness Endianness) (uint64, error) {
+	if endianness == DynamicEndian {
+		endianness = d.endianness
+	}
 	if d.bitOffset == 0 {
 		if d.byteOffset+8 > len(d.bytes) {
 			errCode := "INCOMPLETE_DATA"
 			d.LastErrorCode = &errCode
-			return 0, errors.New("unexpected end of stream")
+			return 0, endOfStream()
 		}
 		var v uint64
 		if endianness == BigEndian {
@@ -650,6 +880,9 @@ func (d *BitStreamDecoder) ReadInt64(endianness Endianness) (int64, error) {
 
 // WriteUint16 writes a 16-bit unsigned integer
 func (e *BitStreamEncoder) WriteUint16(value uint16, endianness Endianness) {
+	if endianness == DynamicEndian {
+		endianness = e.endianness
+	}
 	if e.bitOffset == 0 {
 		var buf [2]byte
 		if endianness == BigEndian {
@@ -657,7 +890,9 @@ func (e *BitStreamEncoder) WriteUint16(value uint16, endianness Endianness) {
 		} else {
 			binary.LittleEndian.PutUint16(buf[:], value)
 		}
-		e.bytes = append(e.bytes, buf[:]...)
+		if e.fits(len(buf)) {
+			e.bytes = append(e.bytes, buf[:]...)
+		}
 		return
 	}
 	if endianness == BigEndian {
@@ -671,6 +906,9 @@ func (e *BitStreamEncoder) WriteUint16(value uint16, endianness Endianness) {
 
 // WriteUint32 writes a 32-bit unsigned integer
 func (e *BitStreamEncoder) WriteUint32(value uint32, endianness Endianness) {
+	if endianness == DynamicEndian {
+		endianness = e.endianness
+	}
 	if e.bitOffset == 0 {
 		var buf [4]byte
 		if endianness == BigEndian {
@@ -678,7 +916,9 @@ func (e *BitStreamEncoder) WriteUint32(value uint32, endianness Endianness) {
 		} else {
 			binary.LittleEndian.PutUint32(buf[:], value)
 		}
-		e.bytes = append(e.bytes, buf[:]...)
+		if e.fits(len(buf)) {
+			e.bytes = append(e.bytes, buf[:]...)
+		}
 		return
 	}
 	if endianness == BigEndian {
@@ -696,6 +936,9 @@ func (e *BitStreamEncoder) WriteUint32(value uint32, endianness Endianness) {
 
 // WriteUint64 writes a 64-bit unsigned integer
 func (e *BitStreamEncoder) WriteUint64(value uint64, endianness Endianness) {
+	if endianness == DynamicEndian {
+		endianness = e.endianness
+	}
 	if e.bitOffset == 0 {
 		var buf [8]byte
 		if endianness == BigEndian {
@@ -703,7 +946,9 @@ func (e *BitStreamEncoder) WriteUint64(value uint64, endianness Endianness) {
 		} else {
 			binary.LittleEndian.PutUint64(buf[:], value)
 		}
-		e.bytes = append(e.bytes, buf[:]...)
+		if e.fits(len(buf)) {
+			e.bytes = append(e.bytes, buf[:]...)
+		}
 		return
 	}
 	if endianness == BigEndian {
@@ -1038,6 +1283,78 @@ func (d *BitStreamDecoder) ReadVarlengthVLQ() (uint64, error) {
 	return result, nil
 }
 
+// ReservePlaceholder writes size zero bytes for a value that is only known later,
+// such as the offset of a section encoded after it, and returns their slot for
+// PatchUint8/16/32/64. Placeholders must start on a byte boundary.
+func (e *BitStreamEncoder) ReservePlaceholder(size int) (int, error) {
+	if e.bitOffset != 0 {
+		return 0, fmt.Errorf("placeholder %d bits into a byte: placeholders must start on a byte boundary", e.bitOffset)
+	}
+	if !e.fits(size) {
+		return 0, e.err
+	}
+	slot := len(e.bytes)
+	e.bytes = append(e.bytes, make([]byte, size)...)
+	return slot, nil
+}
+
+// Span returns the bytes written between two bit positions (BitPosition), for the
+// lengths and checksums of byte ranges. Both must be on byte boundaries, and the bytes
+// up to the end written out; placeholders in the range read as zero until patched.
+func (e *BitStreamEncoder) Span(startBit, endBit int) ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	if startBit%8 != 0 || endBit%8 != 0 {
+		return nil, fmt.Errorf("byte range from bit %d to bit %d: ranges must start and end on byte boundaries", startBit, endBit)
+	}
+	if startBit > endBit || endBit/8 > len(e.bytes) {
+		return nil, fmt.Errorf("byte range from byte %d to byte %d is outside the %d bytes written", startBit/8, endBit/8, len(e.bytes))
+	}
+	return e.bytes[startBit/8 : endBit/8], nil
+}
+
+// PatchUint8 overwrites an 8-bit placeholder slot
+func (e *BitStreamEncoder) PatchUint8(slot int, value uint8) {
+	e.bytes[slot] = value
+}
+
+// PatchUint16 overwrites a 16-bit placeholder slot
+func (e *BitStreamEncoder) PatchUint16(slot int, value uint16, endianness Endianness) {
+	if endianness == DynamicEndian {
+		endianness = e.endianness
+	}
+	if endianness == BigEndian {
+		binary.BigEndian.PutUint16(e.bytes[slot:], value)
+	} else {
+		binary.LittleEndian.PutUint16(e.bytes[slot:], value)
+	}
+}
+
+// PatchUint32 overwrites a 32-bit placeholder slot
+func (e *BitStreamEncoder) PatchUint32(slot int, value uint32, endianness Endianness) {
+	if endianness == DynamicEndian {
+		endianness = e.endianness
+	}
+	if endianness == BigEndian {
+		binary.BigEndian.PutUint32(e.bytes[slot:], value)
+	} else {
+		binary.LittleEndian.PutUint32(e.bytes[slot:], value)
+	}
+}
+
+// PatchUint64 overwrites a 64-bit placeholder slot
+func (e *BitStreamEncoder) PatchUint64(slot int, value uint64, endianness Endianness) {
+	if endianness == DynamicEndian {
+		endianness = e.endianness
+	}
+	if endianness == BigEndian {
+		binary.BigEndian.PutUint64(e.bytes[slot:], value)
+	} else {
+		binary.LittleEndian.PutUint64(e.bytes[slot:], value)
+	}
+}
+
 // CRC32 computes the CRC32 checksum (IEEE polynomial) of the given data.
 // This matches the CRC32 implementation used by ZIP and other formats.
 func CRC32(data []byte) uint32 {
@@ -0,0 +1,65 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitStreamEncoderPositions(t *testing.T) {
+	encoder := NewBitStreamEncoder(MSBFirst)
+	require.Equal(t, 0, encoder.Position())
+	require.Equal(t, 0, encoder.BitPosition())
+	require.Equal(t, 0, encoder.Len())
+	require.Equal(t, 0, encoder.BytesWritten())
+
+	// A partly written byte counts in Position and Len, not in BytesWritten
+	encoder.WriteBits(0b101, 3)
+	require.Equal(t, 1, encoder.Position())
+	require.Equal(t, 3, encoder.BitPosition())
+	require.Equal(t, 1, encoder.Len())
+	require.Equal(t, 0, encoder.BytesWritten())
+
+	encoder.WriteBits(0b11111, 5)
+	require.Equal(t, 1, encoder.Position())
+	require.Equal(t, 8, encoder.BitPosition())
+	require.Equal(t, 1, encoder.BytesWritten())
+
+	encoder.WriteUint16(0x1234, BigEndian)
+	encoder.WriteBit(1)
+	require.Equal(t, 4, encoder.Position())
+	require.Equal(t, 25, encoder.BitPosition())
+	require.Equal(t, 4, encoder.Len())
+	require.Equal(t, 3, encoder.BytesWritten())
+
+	// Len is the length Finish returns
+	require.Len(t, encoder.Finish(), 4)
+}
+
+func TestBitStreamDecoderPositions(t *testing.T) {
+	decoder := NewBitStreamDecoder([]byte{0xAB, 0x12, 0x34, 0xFF}, MSBFirst)
+	require.Equal(t, 0, decoder.Position())
+	require.Equal(t, 0, decoder.BitPosition())
+
+	// Position counts whole bytes read, BitPosition the bits of a partly read byte too
+	_, err := decoder.ReadBits(3)
+	require.NoError(t, err)
+	require.Equal(t, 0, decoder.Position())
+	require.Equal(t, 3, decoder.BitPosition())
+
+	_, err = decoder.ReadBits(5)
+	require.NoError(t, err)
+	require.Equal(t, 1, decoder.Position())
+	require.Equal(t, 8, decoder.BitPosition())
+
+	value, err := decoder.ReadUint16(BigEndian)
+	require.NoError(t, err)
+	require.Equal(t, uint16(0x1234), value)
+	require.Equal(t, 3, decoder.Position())
+	require.Equal(t, 24, decoder.BitPosition())
+
+	_, err = decoder.ReadBits(1)
+	require.NoError(t, err)
+	require.Equal(t, 3, decoder.Position())
+	require.Equal(t, 25, decoder.BitPosition())
+}
@@ -0,0 +1,124 @@
+package runtime
+
+import "fmt"
+
+// ByteString is a string field decoded without copying: it is a view of the decoder's
+// input, so it is only valid while that buffer is unchanged. Clone it (or the
+// generated Clone of the struct holding it) to keep it longer.
+type ByteString []byte
+
+// String returns the bytes as a string, copying them
+func (s ByteString) String() string {
+	return string(s)
+}
+
+// Clone returns a copy of s that doesn't share the input buffer
+func (s ByteString) Clone() ByteString {
+	if s == nil {
+		return nil
+	}
+	return append(ByteString{}, s...)
+}
+
+// MarshalText marshals s as a string, as a string field would be
+func (s ByteString) MarshalText() ([]byte, error) {
+	return s, nil
+}
+
+// UnmarshalText copies text into s
+func (s *ByteString) UnmarshalText(text []byte) error {
+	*s = append(ByteString{}, text...)
+	return nil
+}
+
+// ReadBytesView reads n bytes as a view of the input when the decoder is
+// byte-aligned, and copies them when it isn't
+func (d *BitStreamDecoder) ReadBytesView(n int) ([]byte, error) {
+	if d.bitOffset == 0 {
+		return d.ReadBytesSlice(n)
+	}
+	b := make([]byte, n)
+	if err := d.readFull(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ReadNullTerminatedView reads bytes up to a 0 byte, consuming the 0 but leaving it
+// out, as a view of the input when the decoder is byte-aligned
+func (d *BitStreamDecoder) ReadNullTerminatedView() ([]byte, error) {
+	if d.bitOffset != 0 {
+		b := []byte{}
+		for {
+			v, err := d.ReadUint8()
+			if err != nil {
+				return nil, err
+			}
+			if v == 0 {
+				return b, nil
+			}
+			b = append(b, v)
+		}
+	}
+	for end := d.byteOffset; end < len(d.bytes); end++ {
+		if d.bytes[end] == 0 {
+			view := d.bytes[d.byteOffset:end]
+			d.byteOffset = end + 1
+			d.LastErrorCode = nil
+			return view, nil
+		}
+	}
+	errCode := "INCOMPLETE_DATA"
+	d.LastErrorCode = &errCode
+	d.byteOffset = len(d.bytes)
+	return nil, endOfStream()
+}
+
+// ReadNullTerminatedLimit is ReadNullTerminatedView for a string of at most max bytes,
+// a field's max_length: once max bytes have been read without a 0 after them, it fails
+// (SCHEMA_MISMATCH) instead of reading on, so input missing its terminator can't make
+// decoding scan the rest of it
+func (d *BitStreamDecoder) ReadNullTerminatedLimit(field string, max int) ([]byte, error) {
+	if d.bitOffset != 0 {
+		b := []byte{}
+		for {
+			v, err := d.ReadUint8()
+			if err != nil {
+				return nil, err
+			}
+			if v == 0 {
+				return b, nil
+			}
+			if len(b) == max {
+				return nil, d.unterminated(field, max)
+			}
+			b = append(b, v)
+		}
+	}
+	limit := len(d.bytes)
+	if max < limit-d.byteOffset {
+		limit = d.byteOffset + max + 1
+	}
+	for end := d.byteOffset; end < limit; end++ {
+		if d.bytes[end] == 0 {
+			view := d.bytes[d.byteOffset:end]
+			d.byteOffset = end + 1
+			d.LastErrorCode = nil
+			return view, nil
+		}
+	}
+	if limit-d.byteOffset > max {
+		return nil, d.unterminated(field, max)
+	}
+	errCode := ErrorIncompleteData
+	d.LastErrorCode = &errCode
+	d.byteOffset = len(d.bytes)
+	return nil, endOfStream()
+}
+
+// unterminated is the error of a null-terminated string without a 0 within max bytes
+func (d *BitStreamDecoder) unterminated(field string, max int) error {
+	errCode := ErrorSchemaMismatch
+	d.LastErrorCode = &errCode
+	return fmt.Errorf("%s: no terminator within max_length of %d bytes", field, max)
+}
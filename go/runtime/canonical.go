@@ -0,0 +1,26 @@
+package runtime
+
+import "math"
+
+// The quiet NaNs canonical encoders write for every NaN
+const (
+	canonicalNaN32 = 0x7FC00000
+	canonicalNaN64 = 0x7FF8000000000000
+)
+
+// CanonicalFloat32 returns v, or the quiet NaN if v is any NaN, so NaNs with
+// different payloads encode alike
+func CanonicalFloat32(v float32) float32 {
+	if v != v {
+		return math.Float32frombits(canonicalNaN32)
+	}
+	return v
+}
+
+// CanonicalFloat64 returns v, or the quiet NaN if v is any NaN
+func CanonicalFloat64(v float64) float64 {
+	if v != v {
+		return math.Float64frombits(canonicalNaN64)
+	}
+	return v
+}
@@ -0,0 +1,69 @@
+package runtime
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// ErrHostByteOrder is returned by casts on big-endian hosts, where the memory of a
+// little-endian record doesn't hold its values
+var ErrHostByteOrder = errors.New("records can only be cast on little-endian hosts")
+
+// littleEndianHost reports whether the host stores numbers little-endian, as the
+// records casts view do
+var littleEndianHost = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+// Cast returns the T at the start of data without decoding it. T must be a struct
+// whose Go layout is its little-endian encoding, as the generated CastX checks at
+// compile time. The result shares data's memory: it is only valid while data is, and
+// writing to one writes to the other. data must be aligned for T.
+func Cast[T any](data []byte) (*T, error) {
+	var zero T
+	if size := int(unsafe.Sizeof(zero)); len(data) < size {
+		return nil, fmt.Errorf("cast needs %d bytes, got %d", size, len(data))
+	}
+	if err := checkCast(data, unsafe.Alignof(zero)); err != nil {
+		return nil, err
+	}
+	return (*T)(unsafe.Pointer(unsafe.SliceData(data))), nil
+}
+
+// CastSlice returns the records data holds as a []T sharing its memory, like Cast.
+// data must be a whole number of records.
+func CastSlice[T any](data []byte) ([]T, error) {
+	var zero T
+	size := int(unsafe.Sizeof(zero))
+	if len(data)%size != 0 {
+		return nil, fmt.Errorf("%d bytes is not a whole number of %d-byte records", len(data), size)
+	}
+	if err := checkCast(data, unsafe.Alignof(zero)); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return unsafe.Slice((*T)(unsafe.Pointer(unsafe.SliceData(data))), len(data)/size), nil
+}
+
+// SliceBytes returns the encoding of records, the reverse of CastSlice: the bytes
+// share the records' memory rather than being encoded from them
+func SliceBytes[T any](records []T) ([]byte, error) {
+	if !littleEndianHost {
+		return nil, ErrHostByteOrder
+	}
+	var zero T
+	return unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(records))), len(records)*int(unsafe.Sizeof(zero))), nil
+}
+
+// checkCast checks that data can be viewed as records aligned to align bytes
+func checkCast(data []byte, align uintptr) error {
+	if !littleEndianHost {
+		return ErrHostByteOrder
+	}
+	if len(data) > 0 && uintptr(unsafe.Pointer(unsafe.SliceData(data)))%align != 0 {
+		return fmt.Errorf("cast needs data aligned to %d bytes", align)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package runtime
+
+import (
+	"encoding"
+	"fmt"
+)
+
+// CodecName is the name Codec registers under: gRPC sends it as the content subtype,
+// in "application/grpc+binschema"
+const CodecName = "binschema"
+
+// Codec implements gRPC's encoding.Codec (and any interface of the same shape) for
+// generated types, so BinSchema messages can be gRPC requests and responses:
+//
+//	encoding.RegisterCodec(runtime.Codec{})
+//
+// Marshal takes any generated value. Unmarshal needs a pointer to a type generated with
+// GenerateOptions.BinaryCodecs, which implements encoding.BinaryUnmarshaler.
+type Codec struct{}
+
+// Marshal encodes v, a generated message
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(EncodableMessage)
+	if !ok {
+		return nil, fmt.Errorf("binschema codec: %T is not a generated message", v)
+	}
+	return msg.Encode()
+}
+
+// Unmarshal decodes data into v, a pointer to a generated message
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return fmt.Errorf("binschema codec: %T does not implement encoding.BinaryUnmarshaler", v)
+	}
+	return msg.UnmarshalBinary(data)
+}
+
+// Name returns CodecName
+func (Codec) Name() string {
+	return CodecName
+}
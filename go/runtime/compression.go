@@ -0,0 +1,152 @@
+package runtime
+
+// CompressionMatch selects which earlier names a back-reference pointer may stand for
+type CompressionMatch int
+
+const (
+	// MatchLabels points at any label written before, whatever followed it there.
+	// It is what GetCompressionOffset has always allowed.
+	MatchLabels CompressionMatch = iota
+	// MatchSuffixes points only at labels that ended a name written before and are
+	// the whole rest of the name being written, as RFC 1035 compression does
+	MatchSuffixes
+	// MatchWholeNames points only at a whole name written before, never at the end of one
+	MatchWholeNames
+)
+
+// DNSMaxPointerOffset is the largest offset the 14 bits of a DNS compression pointer hold
+const DNSMaxPointerOffset = 0x3FFF
+
+// CompressionPolicy controls when encoders write back-reference pointers instead of
+// values written before. The zero value compresses as encoders always have: any
+// label recorded before, wherever it was written.
+type CompressionPolicy struct {
+	// Disabled writes every value in full and records nothing
+	Disabled bool
+	// MaxOffset is the largest offset a pointer can hold, DNSMaxPointerOffset for
+	// DNS: values written further in aren't recorded, so nothing points at them,
+	// where the pointer's mask would otherwise wrap the offset. 0 means no limit.
+	MaxOffset int
+	// Match selects which earlier names FindName may point at
+	Match CompressionMatch
+}
+
+// Compression sets the policy a context's compression dictionary follows. Some
+// resolvers reject pointers into the middle of names, or any pointer at all.
+func Compression(policy CompressionPolicy) EncodingOption {
+	return func(ctx *EncodingContext) {
+		ctx.compression = policy
+	}
+}
+
+// CompressionPolicy returns the policy set with Compression (the zero policy without a context)
+func (ctx *EncodingContext) CompressionPolicy() CompressionPolicy {
+	if ctx == nil {
+		return CompressionPolicy{}
+	}
+	return ctx.compression
+}
+
+// reachable reports whether a pointer may be written to, or recorded for, offset
+func (p CompressionPolicy) reachable(offset int) bool {
+	return !p.Disabled && offset >= 0 && (p.MaxOffset == 0 || offset <= p.MaxOffset)
+}
+
+// NameKey returns the compression dictionary key of a name, or of the labels ending
+// one. Generated code supplies the key function matching its format.
+type NameKey func(labels []string) string
+
+// WireNameKey keys a name on its labels as written: each label's length byte, then
+// its bytes. Unlike labels joined with dots, it can't confuse "a.b" with "a", "b".
+func WireNameKey(labels []string) string {
+	key := make([]byte, 0, 64)
+	for _, label := range labels {
+		key = append(key, byte(len(label)))
+		key = append(key, label...)
+	}
+	return string(key)
+}
+
+// DNSNameKey keys a name as WireNameKey does, with ASCII letters folded to lower case:
+// DNS names compare without regard to case (RFC 4343), so "Example.COM" can point at
+// "example.com" as DNS servers' own compression does
+func DNSNameKey(labels []string) string {
+	key := []byte(WireNameKey(labels))
+	for i, b := range key {
+		if 'A' <= b && b <= 'Z' {
+			key[i] = b + 'a' - 'A'
+		}
+	}
+	return string(key)
+}
+
+// FindName looks up the labels of a name about to be written, keyed with key
+// (WireNameKey if nil). It returns the index n of the first label a pointer can
+// replace, so labels[:n] are written and then a pointer to offset, or false if the
+// whole name must be written. The policy's Match selects the candidates: any label,
+// the suffixes labels[i:], or only the whole name; the earliest label that matches
+// wins, so a pointer replaces as much of the name as it can.
+func (ctx *EncodingContext) FindName(labels []string, key NameKey) (n, offset int, ok bool) {
+	if ctx == nil || ctx.compression.Disabled {
+		return 0, 0, false
+	}
+	if key == nil {
+		key = WireNameKey
+	}
+	ctx.lock()
+	defer ctx.unlock()
+
+	for i := range labels {
+		if i > 0 && ctx.compression.Match == MatchWholeNames {
+			break
+		}
+		offset, found := ctx.CompressionDict[nameKey(ctx.compression.Match, labels, i, key)]
+		if found && ctx.compression.reachable(offset) {
+			return i, offset, true
+		}
+	}
+	return 0, 0, false
+}
+
+// RecordName records a name just written, keyed with key (WireNameKey if nil), so
+// later names can point at it: offsets[i] is the offset labels[i] was written at.
+// When the name ended in a pointer, offsets covers only the labels written before it;
+// each is recorded with all the labels after it, pointed at or not, so "mail" written
+// before a pointer to "example.com" is found as "mail.example.com". What is recorded
+// follows the policy's Match: each label, each suffix, or the whole name. A key keeps
+// the first offset recorded for it, as DNS servers point at a name's first occurrence.
+func (ctx *EncodingContext) RecordName(labels []string, offsets []int, key NameKey) {
+	if ctx == nil || ctx.compression.Disabled {
+		return
+	}
+	if key == nil {
+		key = WireNameKey
+	}
+	ctx.lock()
+	defer ctx.unlock()
+
+	if ctx.CompressionDict == nil {
+		ctx.CompressionDict = make(map[string]int)
+	}
+	for i := range offsets {
+		if i >= len(labels) || i > 0 && ctx.compression.Match == MatchWholeNames {
+			break
+		}
+		if !ctx.compression.reachable(offsets[i]) {
+			continue
+		}
+		k := nameKey(ctx.compression.Match, labels, i, key)
+		if _, seen := ctx.CompressionDict[k]; !seen {
+			ctx.CompressionDict[k] = offsets[i]
+		}
+	}
+}
+
+// nameKey is the dictionary key of the candidate starting at labels[i]: the label
+// alone, or the labels from it to the end
+func nameKey(match CompressionMatch, labels []string, i int, key NameKey) string {
+	if match == MatchLabels {
+		return key(labels[i : i+1])
+	}
+	return key(labels[i:])
+}
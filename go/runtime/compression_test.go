@@ -0,0 +1,161 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordWWW records www.example.com as written at offset 12, each label after the
+// last one's length byte and bytes
+func recordWWW(ctx *EncodingContext) {
+	ctx.RecordName([]string{"www", "example", "com"}, []int{12, 16, 24}, nil)
+}
+
+func TestFindNameMatchLabels(t *testing.T) {
+	ctx := NewEncodingContext()
+	require.Equal(t, CompressionPolicy{}, ctx.CompressionPolicy())
+	recordWWW(ctx)
+
+	// Any label written before can be pointed at, whatever followed it there
+	n, offset, ok := ctx.FindName([]string{"mail", "example", "com"}, nil)
+	require.True(t, ok)
+	require.Equal(t, 1, n)
+	require.Equal(t, 16, offset)
+
+	n, offset, ok = ctx.FindName([]string{"example", "org"}, nil)
+	require.True(t, ok)
+	require.Equal(t, 0, n)
+	require.Equal(t, 16, offset)
+
+	_, _, ok = ctx.FindName([]string{"mail", "org"}, nil)
+	require.False(t, ok)
+}
+
+func TestFindNameMatchSuffixes(t *testing.T) {
+	ctx := NewEncodingContext(Compression(CompressionPolicy{Match: MatchSuffixes}))
+	recordWWW(ctx)
+
+	n, offset, ok := ctx.FindName([]string{"mail", "example", "com"}, nil)
+	require.True(t, ok)
+	require.Equal(t, 1, n)
+	require.Equal(t, 16, offset)
+
+	n, offset, ok = ctx.FindName([]string{"com"}, nil)
+	require.True(t, ok)
+	require.Equal(t, 0, n)
+	require.Equal(t, 24, offset)
+
+	// "example" was followed by "com", not "org"
+	_, _, ok = ctx.FindName([]string{"example", "org"}, nil)
+	require.False(t, ok)
+
+	// A name ending in a pointer is recorded with the labels pointed at, and a key
+	// keeps its first offset
+	ctx.RecordName([]string{"mail", "example", "com"}, []int{40}, nil)
+	ctx.RecordName([]string{"www", "example", "com"}, []int{60, 64, 72}, nil)
+	n, offset, ok = ctx.FindName([]string{"mail", "example", "com"}, nil)
+	require.True(t, ok)
+	require.Equal(t, 0, n)
+	require.Equal(t, 40, offset)
+	_, offset, _ = ctx.FindName([]string{"www", "example", "com"}, nil)
+	require.Equal(t, 12, offset)
+}
+
+func TestFindNameMatchWholeNames(t *testing.T) {
+	ctx := NewEncodingContext(Compression(CompressionPolicy{Match: MatchWholeNames}))
+	recordWWW(ctx)
+
+	n, offset, ok := ctx.FindName([]string{"www", "example", "com"}, nil)
+	require.True(t, ok)
+	require.Equal(t, 0, n)
+	require.Equal(t, 12, offset)
+
+	// Neither the end of a name nor a name ending in one is pointed at
+	_, _, ok = ctx.FindName([]string{"example", "com"}, nil)
+	require.False(t, ok)
+	_, _, ok = ctx.FindName([]string{"mail", "example", "com"}, nil)
+	require.False(t, ok)
+	require.Len(t, ctx.CompressionDict, 1)
+}
+
+func TestCompressionDisabled(t *testing.T) {
+	ctx := NewEncodingContext(Compression(CompressionPolicy{Disabled: true}))
+	recordWWW(ctx)
+	ctx.SetCompressionOffset("value", 4)
+	require.Empty(t, ctx.CompressionDict)
+
+	// Nothing is pointed at, even what the dictionary started with
+	ctx = NewEncodingContext(
+		CompressionDictionary(map[string]int{WireNameKey([]string{"com"}): 24, "value": 4}),
+		Compression(CompressionPolicy{Disabled: true}),
+	)
+	_, _, ok := ctx.FindName([]string{"com"}, nil)
+	require.False(t, ok)
+	_, ok = ctx.GetCompressionOffset("value")
+	require.False(t, ok)
+
+	// Without a context there is nothing to compress against
+	var none *EncodingContext
+	require.Equal(t, CompressionPolicy{}, none.CompressionPolicy())
+	_, _, ok = none.FindName([]string{"com"}, nil)
+	require.False(t, ok)
+}
+
+func TestCompressionMaxOffset(t *testing.T) {
+	policy := CompressionPolicy{MaxOffset: DNSMaxPointerOffset, Match: MatchSuffixes}
+	ctx := NewEncodingContext(Compression(policy))
+
+	// Labels past the offsets a pointer holds aren't recorded; those before are
+	ctx.RecordName([]string{"a", "example", "com"}, []int{0x3FF0, 0x3FF2, 0x4000}, nil)
+	_, _, ok := ctx.FindName([]string{"com"}, nil)
+	require.False(t, ok)
+	n, offset, ok := ctx.FindName([]string{"b", "example", "com"}, nil)
+	require.True(t, ok)
+	require.Equal(t, 1, n)
+	require.Equal(t, 0x3FF2, offset)
+
+	ctx.SetCompressionOffset("far", DNSMaxPointerOffset+1)
+	_, ok = ctx.GetCompressionOffset("far")
+	require.False(t, ok)
+	ctx.SetCompressionOffset("near", DNSMaxPointerOffset)
+	offset, ok = ctx.GetCompressionOffset("near")
+	require.True(t, ok)
+	require.Equal(t, DNSMaxPointerOffset, offset)
+
+	// Offsets a dictionary started with are checked when looked up
+	ctx = NewEncodingContext(
+		CompressionDictionary(map[string]int{WireNameKey([]string{"com"}): 0x5000}),
+		Compression(policy),
+	)
+	_, _, ok = ctx.FindName([]string{"com"}, nil)
+	require.False(t, ok)
+}
+
+func TestNameKeys(t *testing.T) {
+	// Wire keys carry each label's length, so names joined differently don't collide
+	require.NotEqual(t, WireNameKey([]string{"a.b"}), WireNameKey([]string{"a", "b"}))
+	require.NotEqual(t, WireNameKey([]string{"ab", "c"}), WireNameKey([]string{"a", "bc"}))
+	require.NotEqual(t, WireNameKey([]string{"a", ""}), WireNameKey([]string{"a"}))
+	require.Equal(t, "\x03www\x07example", WireNameKey([]string{"www", "example"}))
+
+	// Wire keys are case-sensitive, DNS keys fold ASCII letters only
+	require.NotEqual(t, WireNameKey([]string{"Example", "COM"}), WireNameKey([]string{"example", "com"}))
+	require.Equal(t, DNSNameKey([]string{"Example", "COM"}), DNSNameKey([]string{"example", "com"}))
+	require.Equal(t, WireNameKey([]string{"example", "com"}), DNSNameKey([]string{"eXaMpLe", "Com"}))
+	require.NotEqual(t, DNSNameKey([]string{"É"}), DNSNameKey([]string{"é"}))
+	require.NotEqual(t, DNSNameKey([]string{"A", "b"}), DNSNameKey([]string{"ab"}))
+
+	// A name differing only in case is found with DNSNameKey and not with WireNameKey
+	ctx := NewEncodingContext(Compression(CompressionPolicy{Match: MatchSuffixes}))
+	ctx.RecordName([]string{"www", "example", "com"}, []int{12, 16, 24}, DNSNameKey)
+	n, offset, ok := ctx.FindName([]string{"MAIL", "Example", "COM"}, DNSNameKey)
+	require.True(t, ok)
+	require.Equal(t, 1, n)
+	require.Equal(t, 16, offset)
+
+	ctx = NewEncodingContext(Compression(CompressionPolicy{Match: MatchSuffixes}))
+	recordWWW(ctx)
+	_, _, ok = ctx.FindName([]string{"MAIL", "Example", "COM"}, nil)
+	require.False(t, ok)
+}
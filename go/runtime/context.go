@@ -1,18 +1,40 @@
 package runtime
 
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
 // EncodingContext holds state needed during encoding for computed fields.
 // It enables multi-pass encoding, parent references, and position tracking.
+//
+// Contexts derived from one another (ExtendWithParent, At, ...) share Positions,
+// TypeIndices and CompressionDict. Generated Encode() starts from a fresh context on
+// every call, so encoding different values from several goroutines is safe; a context
+// passed to EncodeWithContext from several goroutines at once must be made with the
+// Synchronized option, or each goroutine given its own with Fork.
 type EncodingContext struct {
 	// Parents holds parent objects for ../field references.
 	// Each entry is a map of field name -> value.
 	// The last element is the immediate parent, first is the root.
 	Parents []map[string]interface{}
 
+	// ParentStructs holds the enclosing structs themselves (pointers to generated
+	// types), innermost last, for ../field references read through typed accessors.
+	// It is a chain of its own: the Go generator extends it instead of Parents.
+	ParentStructs []interface{}
+
 	// ArrayIterations tracks active array loops for corresponding<Type> correlations.
 	ArrayIterations map[string]*ArrayIteration
 
 	// Positions tracks byte positions for position_of computed fields.
 	// Key format: "arrayName_typeName", Value: slice of positions for each occurrence.
+	// Generated code tracks them by PositionSite instead (TrackSitePosition); the
+	// string-keyed accessors remain for code generated before.
 	Positions map[string][]int
 
 	// TypeIndices tracks type occurrence counters for corresponding<Type> correlations.
@@ -20,6 +42,11 @@ type EncodingContext struct {
 	// Shared across all contexts to persist counters across iterations.
 	TypeIndices map[string]map[string]int
 
+	// sites tracks positions and occurrences by PositionSite, the integer-keyed
+	// counterpart of Positions and TypeIndices that generated code uses.
+	// Shared like them.
+	sites *siteTable
+
 	// ByteOffset tracks absolute byte offset across encoder boundaries.
 	// Used for back-reference support and position calculations.
 	ByteOffset int
@@ -27,8 +54,76 @@ type EncodingContext struct {
 	// CompressionDict maps serialized values to their byte offsets for back_reference compression.
 	// When a value is first encoded, its offset is recorded. Subsequent references can use
 	// compression pointers (like DNS name compression) instead of re-encoding the value.
+	// Names recorded with RecordName are keyed on every suffix, serialized by a NameKey.
 	// Shared across all contexts to enable cross-encoder compression.
 	CompressionDict map[string]int
+
+	// Endianness is the byte order nested encoders start with for DynamicEndian fields,
+	// the one their parent's encoder had chosen.
+	Endianness Endianness
+
+	// canonical keeps the first offset recorded for each compression dictionary
+	// value, set by the Canonical option
+	canonical bool
+
+	// compression is the policy set by the Compression option
+	compression CompressionPolicy
+
+	// outputLimit is the length the whole encoding may reach, set by the OutputLimit
+	// option (0: no limit)
+	outputLimit int
+
+	// mu guards the shared maps when the context was made Synchronized. Derived
+	// contexts copy the pointer, so they all lock the same mutex.
+	mu *sync.Mutex
+}
+
+// EncodingOption configures a context made by NewEncodingContext
+type EncodingOption func(*EncodingContext)
+
+// Synchronized makes the context's accessors lock a mutex around Positions,
+// TypeIndices, the PositionSite table and CompressionDict, so contexts derived
+// from it can be used by concurrent encodes. Reading or writing the maps directly
+// bypasses the lock.
+func Synchronized() EncodingOption {
+	return func(ctx *EncodingContext) {
+		ctx.mu = &sync.Mutex{}
+	}
+}
+
+// StartEndianness sets the byte order nested encoders start with for DynamicEndian fields
+func StartEndianness(endianness Endianness) EncodingOption {
+	return func(ctx *EncodingContext) {
+		ctx.Endianness = endianness
+	}
+}
+
+// CompressionDictionary starts the context with dict as its compression dictionary,
+// so values recorded by earlier encodes can be referenced
+func CompressionDictionary(dict map[string]int) EncodingOption {
+	return func(ctx *EncodingContext) {
+		ctx.CompressionDict = dict
+	}
+}
+
+// Canonical makes the compression dictionary keep the first offset recorded for a
+// value rather than the latest, so a pointer to a value always leads back to its
+// first occurrence. For byte-identical output, give each encode a fresh context (or a
+// Fork of one set up ahead of time), not one Synchronized context shared by
+// concurrent encodes: their offsets are recorded in whatever order they run.
+func Canonical() EncodingOption {
+	return func(ctx *EncodingContext) {
+		ctx.canonical = true
+	}
+}
+
+// OutputLimit makes encodes with the context fail with ErrOutputTooLarge as soon as
+// their output passes maxBytes, instead of finishing a message too large to send. A
+// maxBytes of 0 or less sets no limit.
+func OutputLimit(maxBytes int) EncodingOption {
+	return func(ctx *EncodingContext) {
+		ctx.outputLimit = max(maxBytes, 0)
+	}
 }
 
 // ArrayIteration tracks state of an array being encoded.
@@ -40,16 +135,69 @@ type ArrayIteration struct {
 	TypeIndices map[string]int // Type occurrence counters for choice/discriminated union arrays
 }
 
-// NewEncodingContext creates an empty encoding context.
-func NewEncodingContext() *EncodingContext {
-	return &EncodingContext{
+// NewEncodingContext creates an empty encoding context configured by opts.
+func NewEncodingContext(opts ...EncodingOption) *EncodingContext {
+	ctx := &EncodingContext{
 		Parents:         make([]map[string]interface{}, 0),
 		ArrayIterations: make(map[string]*ArrayIteration),
 		Positions:       make(map[string][]int),
 		TypeIndices:     make(map[string]map[string]int),
+		sites:           &siteTable{},
 		ByteOffset:      0,
 		CompressionDict: make(map[string]int),
 	}
+	for _, opt := range opts {
+		opt(ctx)
+	}
+	return ctx
+}
+
+// Fork returns a copy of ctx with its own copies of Positions, TypeIndices, the
+// positions and occurrences tracked by PositionSite, and CompressionDict, so one
+// context set up ahead of time can start encodes running in parallel without them
+// seeing each other's state.
+func (ctx *EncodingContext) Fork() *EncodingContext {
+	if ctx == nil {
+		return NewEncodingContext()
+	}
+	ctx.lock()
+	defer ctx.unlock()
+
+	forked := *ctx
+	forked.Positions = make(map[string][]int, len(ctx.Positions))
+	for k, v := range ctx.Positions {
+		forked.Positions[k] = append([]int(nil), v...)
+	}
+	forked.TypeIndices = make(map[string]map[string]int, len(ctx.TypeIndices))
+	for k, v := range ctx.TypeIndices {
+		indices := make(map[string]int, len(v))
+		for name, n := range v {
+			indices[name] = n
+		}
+		forked.TypeIndices[k] = indices
+	}
+	forked.sites = ctx.sites.clone()
+	forked.CompressionDict = make(map[string]int, len(ctx.CompressionDict))
+	for k, v := range ctx.CompressionDict {
+		forked.CompressionDict[k] = v
+	}
+	if ctx.mu != nil {
+		forked.mu = &sync.Mutex{}
+	}
+	return &forked
+}
+
+// lock and unlock guard the shared maps of a Synchronized context
+func (ctx *EncodingContext) lock() {
+	if ctx.mu != nil {
+		ctx.mu.Lock()
+	}
+}
+
+func (ctx *EncodingContext) unlock() {
+	if ctx.mu != nil {
+		ctx.mu.Unlock()
+	}
 }
 
 // ExtendWithParent creates a new context with an additional parent added.
@@ -66,14 +214,44 @@ func (ctx *EncodingContext) ExtendWithParent(parent map[string]interface{}) *Enc
 
 	return &EncodingContext{
 		Parents:         newParents,
+		ParentStructs:   ctx.ParentStructs,
 		ArrayIterations: ctx.ArrayIterations, // Shared reference
 		Positions:       ctx.Positions,       // Shared reference
 		TypeIndices:     ctx.TypeIndices,     // Shared reference
+		sites:           ctx.sites,           // Shared reference
 		ByteOffset:      ctx.ByteOffset,
 		CompressionDict: ctx.CompressionDict, // Shared reference
+		Endianness:      ctx.Endianness,
+		canonical:       ctx.canonical,
+		compression:     ctx.compression,
+		outputLimit:     ctx.outputLimit,
+		mu:              ctx.mu,
 	}
 }
 
+// ExtendWithParentStruct creates a new context with parent, a pointer to the struct
+// being encoded, as the innermost enclosing struct. Nothing is copied out of it, so
+// nested types read its fields through ParentStruct without a map or boxing.
+func (ctx *EncodingContext) ExtendWithParentStruct(parent interface{}) *EncodingContext {
+	if ctx == nil {
+		ctx = NewEncodingContext()
+	}
+	extended := *ctx
+	extended.ParentStructs = make([]interface{}, len(ctx.ParentStructs)+1)
+	copy(extended.ParentStructs, ctx.ParentStructs)
+	extended.ParentStructs[len(ctx.ParentStructs)] = parent
+	return &extended
+}
+
+// ParentStruct returns the enclosing struct levelsUp levels up (1 is the immediate
+// parent), or nil when there is none
+func (ctx *EncodingContext) ParentStruct(levelsUp int) interface{} {
+	if ctx == nil {
+		return nil
+	}
+	return parentStruct(ctx.ParentStructs, levelsUp)
+}
+
 // ExtendWithArrayIteration creates a new context with an array iteration added.
 // TypeIndices and CompressionDict are shared at the EncodingContext level to persist state across iterations.
 func (ctx *EncodingContext) ExtendWithArrayIteration(fieldName string, items interface{}, index int) *EncodingContext {
@@ -95,11 +273,18 @@ func (ctx *EncodingContext) ExtendWithArrayIteration(fieldName string, items int
 
 	return &EncodingContext{
 		Parents:         ctx.Parents,
+		ParentStructs:   ctx.ParentStructs,
 		ArrayIterations: newIterations,
-		Positions:       ctx.Positions,       // Shared reference
-		TypeIndices:     ctx.TypeIndices,     // Shared reference (persists across iterations)
+		Positions:       ctx.Positions,   // Shared reference
+		TypeIndices:     ctx.TypeIndices, // Shared reference (persists across iterations)
+		sites:           ctx.sites,       // Shared reference (persists across iterations)
 		ByteOffset:      ctx.ByteOffset,
 		CompressionDict: ctx.CompressionDict, // Shared reference (persists across iterations)
+		Endianness:      ctx.Endianness,
+		canonical:       ctx.canonical,
+		compression:     ctx.compression,
+		outputLimit:     ctx.outputLimit,
+		mu:              ctx.mu,
 	}
 }
 
@@ -139,7 +324,13 @@ func (ctx *EncodingContext) FindParentField(fieldName string) (interface{}, bool
 // GetPosition retrieves tracked positions for an array/type combination.
 // Key format is "arrayName_typeName".
 func (ctx *EncodingContext) GetPosition(key string, index int) (int, bool) {
-	if ctx == nil || ctx.Positions == nil {
+	if ctx == nil {
+		return 0, false
+	}
+	ctx.lock()
+	defer ctx.unlock()
+
+	if ctx.Positions == nil {
 		return 0, false
 	}
 
@@ -153,7 +344,13 @@ func (ctx *EncodingContext) GetPosition(key string, index int) (int, bool) {
 
 // GetFirstPosition retrieves the first tracked position for an array/type combination.
 func (ctx *EncodingContext) GetFirstPosition(key string) (int, bool) {
-	if ctx == nil || ctx.Positions == nil {
+	if ctx == nil {
+		return 0, false
+	}
+	ctx.lock()
+	defer ctx.unlock()
+
+	if ctx.Positions == nil {
 		return 0, false
 	}
 
@@ -167,7 +364,13 @@ func (ctx *EncodingContext) GetFirstPosition(key string) (int, bool) {
 
 // GetLastPosition retrieves the last tracked position for an array/type combination.
 func (ctx *EncodingContext) GetLastPosition(key string) (int, bool) {
-	if ctx == nil || ctx.Positions == nil {
+	if ctx == nil {
+		return 0, false
+	}
+	ctx.lock()
+	defer ctx.unlock()
+
+	if ctx.Positions == nil {
 		return 0, false
 	}
 
@@ -184,6 +387,8 @@ func (ctx *EncodingContext) TrackPosition(key string, position int) {
 	if ctx == nil {
 		return
 	}
+	ctx.lock()
+	defer ctx.unlock()
 
 	if ctx.Positions == nil {
 		ctx.Positions = make(map[string][]int)
@@ -220,7 +425,13 @@ func (ctx *EncodingContext) GetAnyArrayIteration() (*ArrayIteration, bool) {
 // GetTypeIndex retrieves the type occurrence index for a discriminated union type.
 // Uses the context-level TypeIndices which is shared across all iterations.
 func (ctx *EncodingContext) GetTypeIndex(arrayFieldName, typeName string) int {
-	if ctx == nil || ctx.TypeIndices == nil {
+	if ctx == nil {
+		return 0
+	}
+	ctx.lock()
+	defer ctx.unlock()
+
+	if ctx.TypeIndices == nil {
 		return 0
 	}
 
@@ -238,6 +449,8 @@ func (ctx *EncodingContext) IncrementTypeIndex(arrayFieldName, typeName string)
 	if ctx == nil {
 		return 0
 	}
+	ctx.lock()
+	defer ctx.unlock()
 
 	if ctx.TypeIndices == nil {
 		ctx.TypeIndices = make(map[string]map[string]int)
@@ -259,31 +472,277 @@ func (ctx *EncodingContext) WithByteOffset(offset int) *EncodingContext {
 
 	return &EncodingContext{
 		Parents:         ctx.Parents,
+		ParentStructs:   ctx.ParentStructs,
 		ArrayIterations: ctx.ArrayIterations,
 		Positions:       ctx.Positions,
 		TypeIndices:     ctx.TypeIndices,
+		sites:           ctx.sites,
 		ByteOffset:      offset,
 		CompressionDict: ctx.CompressionDict,
+		Endianness:      ctx.Endianness,
+		canonical:       ctx.canonical,
+		compression:     ctx.compression,
+		outputLimit:     ctx.outputLimit,
+		mu:              ctx.mu,
+	}
+}
+
+// Offset returns the absolute byte offset the current encoder's output starts at.
+// Without a context that is the start of the message.
+func (ctx *EncodingContext) Offset() int {
+	if ctx == nil {
+		return 0
+	}
+	return ctx.ByteOffset
+}
+
+// OutputRemaining returns the bytes an encoder started with ctx may write before the
+// encoding passes its OutputLimit, or -1 without a limit. The offset the encoder's
+// output lands at is taken off when the context knows it (At).
+func (ctx *EncodingContext) OutputRemaining() int {
+	if ctx == nil || ctx.outputLimit == 0 {
+		return -1
+	}
+	return max(ctx.outputLimit-ctx.ByteOffset, 0)
+}
+
+// At returns the context for a nested encoder whose output is written at position
+// in the current encoder's output, so position_of fields inside it stay absolute.
+func (ctx *EncodingContext) At(position int) *EncodingContext {
+	return ctx.WithByteOffset(ctx.Offset() + position)
+}
+
+// WithEndianness returns a context whose nested encoders write DynamicEndian fields in
+// the given byte order
+func (ctx *EncodingContext) WithEndianness(endianness Endianness) *EncodingContext {
+	if ctx == nil {
+		ctx = NewEncodingContext()
 	}
+	copied := *ctx
+	copied.Endianness = endianness
+	return &copied
+}
+
+// ByteOrder returns the byte order for DynamicEndian fields (big endian without a context)
+func (ctx *EncodingContext) ByteOrder() Endianness {
+	if ctx == nil {
+		return BigEndian
+	}
+	return ctx.Endianness
 }
 
 // GetCompressionOffset retrieves the byte offset for a serialized value from the compression dictionary.
-// Returns the offset and true if found, 0 and false otherwise.
+// Returns the offset and true if found and the CompressionPolicy allows a pointer to it, 0 and false otherwise.
 func (ctx *EncodingContext) GetCompressionOffset(valueKey string) (int, bool) {
-	if ctx == nil || ctx.CompressionDict == nil {
+	if ctx == nil {
+		return 0, false
+	}
+	ctx.lock()
+	defer ctx.unlock()
+
+	if ctx.CompressionDict == nil {
 		return 0, false
 	}
 	offset, ok := ctx.CompressionDict[valueKey]
-	return offset, ok
+	if !ok || !ctx.compression.reachable(offset) {
+		return 0, false
+	}
+	return offset, true
 }
 
-// SetCompressionOffset records a value's byte offset in the compression dictionary.
+// SetCompressionOffset records a value's byte offset in the compression dictionary,
+// replacing an earlier one unless the context is Canonical. Nothing is recorded when
+// the CompressionPolicy disables compression or a pointer can't hold the offset.
 func (ctx *EncodingContext) SetCompressionOffset(valueKey string, offset int) {
-	if ctx == nil {
+	if ctx == nil || !ctx.compression.reachable(offset) {
 		return
 	}
+	ctx.lock()
+	defer ctx.unlock()
 	if ctx.CompressionDict == nil {
 		ctx.CompressionDict = make(map[string]int)
 	}
+	if _, seen := ctx.CompressionDict[valueKey]; seen && ctx.canonical {
+		return
+	}
 	ctx.CompressionDict[valueKey] = offset
 }
+
+// GetParentInt resolves a parent field path like "header.version" (levelsUp as in
+// GetParentField) to an integer, for comparing in conditionals.
+func (ctx *EncodingContext) GetParentInt(levelsUp int, path string) (int64, error) {
+	if ctx == nil {
+		return parentInt(nil, levelsUp, path)
+	}
+	return parentInt(ctx.Parents, levelsUp, path)
+}
+
+// DecodingContext holds the fields of the structs enclosing the one being decoded,
+// for ../field references in nested types. It has the same parent chain as
+// EncodingContext. A nil *DecodingContext is an empty context.
+type DecodingContext struct {
+	// Parents holds the fields decoded so far of each enclosing struct.
+	// The last element is the immediate parent, first is the root.
+	Parents []map[string]interface{}
+
+	// ParentStructs holds the enclosing structs being decoded (pointers to generated
+	// types), innermost last; fields after the one being decoded are still zero
+	ParentStructs []interface{}
+}
+
+// NewDecodingContext creates an empty decoding context.
+func NewDecodingContext() *DecodingContext {
+	return &DecodingContext{Parents: make([]map[string]interface{}, 0)}
+}
+
+// ExtendWithParent creates a new context with an additional parent added.
+// The new parent becomes the most recent (innermost) parent.
+func (ctx *DecodingContext) ExtendWithParent(parent map[string]interface{}) *DecodingContext {
+	var parents []map[string]interface{}
+	if ctx != nil {
+		parents = ctx.Parents
+	}
+	newParents := make([]map[string]interface{}, len(parents)+1)
+	copy(newParents, parents)
+	newParents[len(parents)] = parent
+	var structs []interface{}
+	if ctx != nil {
+		structs = ctx.ParentStructs
+	}
+	return &DecodingContext{Parents: newParents, ParentStructs: structs}
+}
+
+// ExtendWithParentStruct creates a new context with parent, a pointer to the struct
+// being decoded, as the innermost enclosing struct
+func (ctx *DecodingContext) ExtendWithParentStruct(parent interface{}) *DecodingContext {
+	var parents []map[string]interface{}
+	var structs []interface{}
+	if ctx != nil {
+		parents, structs = ctx.Parents, ctx.ParentStructs
+	}
+	newStructs := make([]interface{}, len(structs)+1)
+	copy(newStructs, structs)
+	newStructs[len(structs)] = parent
+	return &DecodingContext{Parents: parents, ParentStructs: newStructs}
+}
+
+// ParentStruct returns the enclosing struct levelsUp levels up (1 is the immediate
+// parent), or nil when there is none
+func (ctx *DecodingContext) ParentStruct(levelsUp int) interface{} {
+	if ctx == nil {
+		return nil
+	}
+	return parentStruct(ctx.ParentStructs, levelsUp)
+}
+
+func parentStruct(structs []interface{}, levelsUp int) interface{} {
+	idx := len(structs) - levelsUp
+	if idx < 0 || idx >= len(structs) {
+		return nil
+	}
+	return structs[idx]
+}
+
+// GetParentField retrieves a field value from N levels up in the parent chain.
+// levelsUp=1 means the immediate parent, levelsUp=2 means the grandparent, etc.
+func (ctx *DecodingContext) GetParentField(levelsUp int, fieldName string) (interface{}, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	idx := len(ctx.Parents) - levelsUp
+	if idx < 0 || idx >= len(ctx.Parents) {
+		return nil, false
+	}
+	val, ok := ctx.Parents[idx][fieldName]
+	return val, ok
+}
+
+// FindParentField searches through all parents to find a field by name.
+// Searches from outermost (root) to innermost (immediate parent).
+func (ctx *DecodingContext) FindParentField(fieldName string) (interface{}, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	for _, parent := range ctx.Parents {
+		if val, ok := parent[fieldName]; ok {
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+// GetParentInt resolves a parent field path like "header.version" (levelsUp as in
+// GetParentField) to an integer, for comparing in conditionals.
+func (ctx *DecodingContext) GetParentInt(levelsUp int, path string) (int64, error) {
+	if ctx == nil {
+		return parentInt(nil, levelsUp, path)
+	}
+	return parentInt(ctx.Parents, levelsUp, path)
+}
+
+// parentInt looks up the first segment of a dotted path in a parent and follows
+// the rest through nested generated structs (schema names map to Go field names
+// by capitalizing the first letter, or by the binschema tag of a field with a
+// go_name), maps and pointers.
+func parentInt(parents []map[string]interface{}, levelsUp int, path string) (int64, error) {
+	ref := strings.Repeat("../", levelsUp) + path
+	idx := len(parents) - levelsUp
+	if idx < 0 || idx >= len(parents) {
+		return 0, fmt.Errorf("%s: no parent %d levels up", ref, levelsUp)
+	}
+	parts := strings.Split(path, ".")
+	value, ok := parents[idx][parts[0]]
+	if !ok {
+		return 0, fmt.Errorf("%s: parent has no field %q", ref, parts[0])
+	}
+
+	v := reflect.ValueOf(value)
+	for _, part := range parts[1:] {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			v = structField(v, part)
+		case reflect.Map:
+			v = v.MapIndex(reflect.ValueOf(part))
+		default:
+			v = reflect.Value{}
+		}
+		if !v.IsValid() {
+			return 0, fmt.Errorf("%s: no field %q", ref, part)
+		}
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("%s: not an integer", ref)
+}
+
+// structField returns the field of a generated struct a schema name names: the field
+// tagged with it, or the name capitalized as the generator capitalizes it
+func structField(v reflect.Value, name string) reflect.Value {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("binschema") == name {
+			return v.Field(i)
+		}
+	}
+	r, size := utf8.DecodeRuneInString(name)
+	if upper := unicode.ToUpper(r); unicode.IsUpper(upper) {
+		return v.FieldByName(string(upper) + name[size:])
+	}
+	return v.FieldByName("X" + name)
+}
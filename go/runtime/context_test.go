@@ -0,0 +1,110 @@
+package runtime
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSynchronizedContextSharedAcrossGoroutines(t *testing.T) {
+	ctx := NewEncodingContext(Synchronized())
+	const workers, iterations = 8, 500
+
+	// Each goroutine records through contexts derived from the shared one, as
+	// concurrent EncodeWithContext calls do
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				derived := ctx.ExtendWithParent(map[string]interface{}{}).ExtendWithArrayIteration("items", nil, i)
+				derived.TrackPosition("items_Item", i)
+				derived.IncrementTypeIndex("items", "Item")
+				derived.TrackSitePosition(0, i)
+				derived.IncrementSiteTypeIndex(0)
+				derived.SetCompressionOffset(fmt.Sprintf("w%d-%d", w, i), i)
+				derived.GetLastPosition("items_Item")
+				derived.GetCompressionOffset("w0-0")
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	require.Len(t, ctx.Positions["items_Item"], workers*iterations)
+	require.Equal(t, workers*iterations, ctx.GetTypeIndex("items", "Item"))
+	require.Equal(t, workers*iterations, ctx.SiteTypeIndex(0))
+	_, ok := ctx.SitePosition(0, workers*iterations-1)
+	require.True(t, ok)
+	require.Len(t, ctx.CompressionDict, workers*iterations)
+}
+
+func TestForkIsolatesSharedState(t *testing.T) {
+	ctx := NewEncodingContext(Synchronized(), Canonical(), Compression(CompressionPolicy{MaxOffset: 100}))
+	ctx.TrackPosition("items_Item", 4)
+	ctx.IncrementTypeIndex("items", "Item")
+	ctx.SetCompressionOffset("example", 12)
+
+	forked := ctx.Fork()
+	require.NotSame(t, ctx.mu, forked.mu)
+	require.Equal(t, ctx.CompressionPolicy(), forked.CompressionPolicy())
+
+	// The fork starts with what the context held...
+	position, ok := forked.GetLastPosition("items_Item")
+	require.True(t, ok)
+	require.Equal(t, 4, position)
+	require.Equal(t, 1, forked.GetTypeIndex("items", "Item"))
+	offset, ok := forked.GetCompressionOffset("example")
+	require.True(t, ok)
+	require.Equal(t, 12, offset)
+
+	// ...and neither sees what the other records afterwards
+	forked.TrackPosition("items_Item", 9)
+	forked.IncrementTypeIndex("items", "Item")
+	forked.SetCompressionOffset("other", 20)
+	ctx.SetCompressionOffset("mine", 30)
+
+	require.Equal(t, []int{4}, ctx.Positions["items_Item"])
+	require.Equal(t, 1, ctx.GetTypeIndex("items", "Item"))
+	_, ok = ctx.GetCompressionOffset("other")
+	require.False(t, ok)
+	require.Equal(t, []int{4, 9}, forked.Positions["items_Item"])
+	require.Equal(t, 2, forked.GetTypeIndex("items", "Item"))
+	_, ok = forked.GetCompressionOffset("mine")
+	require.False(t, ok)
+
+	// Canonical carries over: the first offset recorded stays
+	forked.SetCompressionOffset("example", 50)
+	offset, _ = forked.GetCompressionOffset("example")
+	require.Equal(t, 12, offset)
+}
+
+func TestForksEncodeInParallel(t *testing.T) {
+	base := NewEncodingContext(CompressionDictionary(map[string]int{"shared": 2}))
+
+	// Forks of an unsynchronized context need no lock of their own
+	var wg sync.WaitGroup
+	forks := make([]*EncodingContext, 8)
+	for i := range forks {
+		forks[i] = base.Fork()
+		wg.Add(1)
+		go func(ctx *EncodingContext, i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				ctx.SetCompressionOffset(fmt.Sprintf("value%d", j), i*1000+j)
+				ctx.IncrementTypeIndex("items", "Item")
+			}
+		}(forks[i], i)
+	}
+	wg.Wait()
+
+	for i, ctx := range forks {
+		require.Len(t, ctx.CompressionDict, 101)
+		offset, _ := ctx.GetCompressionOffset("value7")
+		require.Equal(t, i*1000+7, offset)
+		require.Equal(t, 100, ctx.GetTypeIndex("items", "Item"))
+	}
+	require.Equal(t, map[string]int{"shared": 2}, base.CompressionDict)
+}
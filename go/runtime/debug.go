@@ -0,0 +1,209 @@
+package runtime
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Formatter renders generated values for their String() and GoString() methods.
+// Generated code drives it field by field; scalars are formatted with fmt.
+type Formatter struct {
+	GoSyntax bool // Render Go syntax (GoString) instead of the readable form (String)
+
+	buf   strings.Builder
+	first []bool // Per open struct or list: no element written yet
+}
+
+// BeginStruct starts a struct value
+func (f *Formatter) BeginStruct(name string) {
+	f.buf.WriteString(name)
+	f.buf.WriteByte('{')
+	f.first = append(f.first, true)
+}
+
+// Field starts a struct field, labelled with its schema name or, in Go syntax, its Go name
+func (f *Formatter) Field(name, goName string) {
+	f.separate()
+	if f.GoSyntax {
+		name = goName
+	}
+	f.buf.WriteString(name)
+	f.buf.WriteString(": ")
+}
+
+// EndStruct closes the innermost struct value
+func (f *Formatter) EndStruct() {
+	f.close()
+	f.buf.WriteByte('}')
+}
+
+// BeginList starts a slice whose items are written with Item
+func (f *Formatter) BeginList(goType string) {
+	if f.GoSyntax {
+		f.buf.WriteString(goType)
+		f.buf.WriteByte('{')
+	} else {
+		f.buf.WriteByte('[')
+	}
+	f.first = append(f.first, true)
+}
+
+// Item starts the next slice item
+func (f *Formatter) Item() {
+	f.separate()
+}
+
+// EndList closes the innermost slice
+func (f *Formatter) EndList() {
+	f.close()
+	if f.GoSyntax {
+		f.buf.WriteByte('}')
+	} else {
+		f.buf.WriteByte(']')
+	}
+}
+
+// Pointer marks the next struct value as referenced through a pointer
+func (f *Formatter) Pointer() {
+	if f.GoSyntax {
+		f.buf.WriteByte('&')
+	}
+}
+
+// Nil writes a nil pointer
+func (f *Formatter) Nil() {
+	f.buf.WriteString("nil")
+}
+
+// Value writes a scalar, or a slice of scalars
+func (f *Formatter) Value(v interface{}) {
+	f.buf.WriteString(formatValue(v, f.GoSyntax))
+}
+
+// String returns everything written so far
+func (f *Formatter) String() string {
+	return f.buf.String()
+}
+
+func (f *Formatter) separate() {
+	n := len(f.first) - 1
+	if n < 0 {
+		return
+	}
+	if !f.first[n] {
+		f.buf.WriteString(", ")
+	}
+	f.first[n] = false
+}
+
+func (f *Formatter) close() {
+	if len(f.first) > 0 {
+		f.first = f.first[:len(f.first)-1]
+	}
+}
+
+func formatValue(v interface{}, goSyntax bool) string {
+	if goSyntax {
+		return fmt.Sprintf("%#v", v)
+	}
+	switch v.(type) {
+	case string, []string:
+		return fmt.Sprintf("%q", v)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// Trace is a TraceSink that collects events so they can be rendered with Dump.
+// Generated DumpAnnotated functions decode with one attached.
+type Trace struct {
+	Events []TraceEvent // In the order reads completed
+}
+
+// Record appends an event
+func (t *Trace) Record(event TraceEvent) {
+	t.Events = append(t.Events, event)
+}
+
+// dumpHexWidth is how many bytes a field line shows before eliding the rest
+const dumpHexWidth = 8
+
+// Dump renders data as an annotated field listing followed by a hex dump.
+// Each field line shows its offset, its bytes and its decoded value, indented by nesting.
+func (t *Trace) Dump(typeName string, data []byte) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s)\n", typeName, byteCount(len(data)))
+
+	// Show reads in the order they started, so parents precede their fields
+	events := append([]TraceEvent(nil), t.Events...)
+	sort.Slice(events, func(i, j int) bool { return events[i].Order < events[j].Order })
+
+	end := 0
+	for _, event := range events {
+		last := event.ByteEnd
+		if event.BitEnd > 0 {
+			last++
+		}
+		if last > end {
+			end = last
+		}
+
+		hex := ""
+		if !event.Container {
+			hex = hexBytes(data, event.ByteStart, last)
+		}
+		// Containers show their size; leaves, and strings behind a length prefix, their value
+		label := strings.Repeat("  ", event.Depth) + event.Name
+		if event.Container {
+			label += " (" + byteCount(event.ByteEnd-event.ByteStart) + ")"
+		}
+		_, isString := event.Value.(string)
+		switch {
+		case !event.Complete:
+			label += " (incomplete)"
+		case isString || !event.Container:
+			label += ": " + formatValue(event.Value, false)
+		}
+		offset := fmt.Sprintf("%04x", event.ByteStart)
+		if event.BitStart > 0 {
+			offset += fmt.Sprintf(".%d", event.BitStart)
+		}
+		fmt.Fprintf(&b, "%-6s  %-*s  %s\n", offset, dumpHexWidth*3+2, hex, label)
+	}
+	if end < len(data) {
+		fmt.Fprintf(&b, "%-6s  %-*s  (%s not decoded)\n", fmt.Sprintf("%04x", end), dumpHexWidth*3+2, hexBytes(data, end, len(data)), byteCount(len(data)-end))
+	}
+
+	b.WriteByte('\n')
+	for offset := 0; offset < len(data); offset += 16 {
+		row := data[offset:min(offset+16, len(data))]
+		ascii := make([]byte, len(row))
+		for i, c := range row {
+			ascii[i] = '.'
+			if c >= 0x20 && c < 0x7f {
+				ascii[i] = c
+			}
+		}
+		fmt.Fprintf(&b, "%04x  %-47s  |%s|\n", offset, fmt.Sprintf("% x", row), ascii)
+	}
+	return b.String()
+}
+
+// hexBytes formats data[start:end], eliding bytes past dumpHexWidth
+func hexBytes(data []byte, start, end int) string {
+	end = min(end, len(data))
+	if start >= end {
+		return ""
+	}
+	if end-start > dumpHexWidth {
+		return fmt.Sprintf("% x ..", data[start:start+dumpHexWidth])
+	}
+	return fmt.Sprintf("% x", data[start:end])
+}
+
+func byteCount(n int) string {
+	if n == 1 {
+		return "1 byte"
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,264 @@
+package runtime
+
+import (
+	"encoding/binary"
+	"math"
+	"math/big"
+	"time"
+)
+
+// EmitFormat is a self-describing encoding an Emitter writes
+type EmitFormat int
+
+const (
+	// CBOR is RFC 8949 Concise Binary Object Representation
+	CBOR EmitFormat = iota
+	// MessagePack is the msgpack.org encoding
+	MessagePack
+)
+
+// Emitter re-serializes generated values in a self-describing format, for generated
+// MarshalCBOR and MarshalMessagePack methods. Generated code drives it field by field:
+// structs are maps keyed by schema field names, arrays are arrays, byte arrays are byte
+// strings, and timestamps use each format's time representation.
+type Emitter struct {
+	Format EmitFormat
+
+	buf []byte
+}
+
+// Bytes returns everything written so far
+func (e *Emitter) Bytes() []byte {
+	return e.buf
+}
+
+// BeginMap starts a map of n entries, each a Key followed by its value
+func (e *Emitter) BeginMap(n int) {
+	if e.Format == CBOR {
+		e.cborHead(5, uint64(n))
+		return
+	}
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xde)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdf)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+// Key writes a map key
+func (e *Emitter) Key(name string) {
+	e.String(name)
+}
+
+// BeginArray starts an array of n values
+func (e *Emitter) BeginArray(n int) {
+	if e.Format == CBOR {
+		e.cborHead(4, uint64(n))
+		return
+	}
+	switch {
+	case n < 16:
+		e.buf = append(e.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xdc)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdd)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+}
+
+// Nil writes a null, for absent values
+func (e *Emitter) Nil() {
+	if e.Format == CBOR {
+		e.buf = append(e.buf, 0xf6)
+	} else {
+		e.buf = append(e.buf, 0xc0)
+	}
+}
+
+// Uint writes an unsigned integer in its shortest form
+func (e *Emitter) Uint(v uint64) {
+	if e.Format == CBOR {
+		e.cborHead(0, v)
+		return
+	}
+	switch {
+	case v <= 0x7f:
+		e.buf = append(e.buf, byte(v))
+	case v <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v))
+	case v <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+	default:
+		e.buf = append(e.buf, 0xcf)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, v)
+	}
+}
+
+// Int writes a signed integer in its shortest form
+func (e *Emitter) Int(v int64) {
+	if v >= 0 {
+		e.Uint(uint64(v))
+		return
+	}
+	if e.Format == CBOR {
+		e.cborHead(1, uint64(^v))
+		return
+	}
+	switch {
+	case v >= -32:
+		e.buf = append(e.buf, byte(v))
+	case v >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v))
+	case v >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v))
+	}
+}
+
+// BigInt writes a 128-bit integer: as an integer if it fits in 64 bits, otherwise as a
+// CBOR bignum, or in MessagePack, which has none, as a decimal string
+func (e *Emitter) BigInt(v *big.Int) {
+	switch {
+	case v.IsUint64():
+		e.Uint(v.Uint64())
+	case v.IsInt64():
+		e.Int(v.Int64())
+	case e.Format == CBOR && v.Sign() > 0:
+		e.cborHead(6, 2)
+		e.Binary(v.Bytes())
+	case e.Format == CBOR:
+		// Tag 3 holds -1 - v
+		e.cborHead(6, 3)
+		e.Binary(new(big.Int).Sub(new(big.Int).Neg(v), big.NewInt(1)).Bytes())
+	default:
+		e.String(v.String())
+	}
+}
+
+// Float32 writes a single-precision float
+func (e *Emitter) Float32(v float32) {
+	if e.Format == CBOR {
+		e.buf = append(e.buf, 0xfa)
+	} else {
+		e.buf = append(e.buf, 0xca)
+	}
+	e.buf = binary.BigEndian.AppendUint32(e.buf, math.Float32bits(v))
+}
+
+// Float64 writes a double-precision float
+func (e *Emitter) Float64(v float64) {
+	if e.Format == CBOR {
+		e.buf = append(e.buf, 0xfb)
+	} else {
+		e.buf = append(e.buf, 0xcb)
+	}
+	e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v))
+}
+
+// String writes a text string
+func (e *Emitter) String(s string) {
+	if e.Format == CBOR {
+		e.cborHead(3, uint64(len(s)))
+		e.buf = append(e.buf, s...)
+		return
+	}
+	n := len(s)
+	switch {
+	case n < 32:
+		e.buf = append(e.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xda)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xdb)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+// Binary writes a byte string
+func (e *Emitter) Binary(b []byte) {
+	if e.Format == CBOR {
+		e.cborHead(2, uint64(len(b)))
+		e.buf = append(e.buf, b...)
+		return
+	}
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, 0xc5)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, 0xc6)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+// Time writes a timestamp: in CBOR as tag 1 epoch seconds, an integer unless there is a
+// fractional part; in MessagePack as the timestamp extension type (-1)
+func (e *Emitter) Time(t time.Time) {
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+	if e.Format == CBOR {
+		e.cborHead(6, 1)
+		if nsec == 0 {
+			e.Int(sec)
+		} else {
+			e.Float64(float64(sec) + float64(nsec)/1e9)
+		}
+		return
+	}
+	switch {
+	case sec>>34 == 0 && nsec == 0 && sec <= math.MaxUint32:
+		e.buf = append(e.buf, 0xd6, 0xff)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(sec))
+	case sec>>34 == 0:
+		e.buf = append(e.buf, 0xd7, 0xff)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(nsec)<<34|uint64(sec))
+	default:
+		e.buf = append(e.buf, 0xc7, 12, 0xff)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(nsec))
+		e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(sec))
+	}
+}
+
+// cborHead writes the initial bytes of a CBOR item: its major type and an argument
+func (e *Emitter) cborHead(major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		e.buf = append(e.buf, major|byte(n))
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, major|25)
+		e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(n))
+	case n <= math.MaxUint32:
+		e.buf = append(e.buf, major|26)
+		e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(n))
+	default:
+		e.buf = append(e.buf, major|27)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, n)
+	}
+}
@@ -0,0 +1,169 @@
+package runtime
+
+import (
+	"math"
+	"reflect"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// ValuesEqual reports whether a and b hold the same values, as the Equal methods of
+// generated types define it: floats are compared bit for bit, so NaN equals itself
+// and 0 doesn't equal -0, a nil slice or map equals an empty one, and times compare as
+// time.Time.Equal does. It walks values with reflection, for generators that emit
+// Equal without a comparison of their own for each field.
+func ValuesEqual(a, b interface{}) bool {
+	return valuesEqual(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+func valuesEqual(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() == b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return math.Float64bits(a.Float()) == math.Float64bits(b.Float())
+	case reflect.Complex64, reflect.Complex128:
+		ca, cb := a.Complex(), b.Complex()
+		return math.Float64bits(real(ca)) == math.Float64bits(real(cb)) &&
+			math.Float64bits(imag(ca)) == math.Float64bits(imag(cb))
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Pointer:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return a.Pointer() == b.Pointer() || valuesEqual(a.Elem(), b.Elem())
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return valuesEqual(a.Elem(), b.Elem())
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !valuesEqual(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+		iter := a.MapRange()
+		for iter.Next() {
+			other := b.MapIndex(iter.Key())
+			if !other.IsValid() || !valuesEqual(iter.Value(), other) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		if a.Type() == timeType && a.CanInterface() {
+			return a.Interface().(time.Time).Equal(b.Interface().(time.Time))
+		}
+		for i := 0; i < a.NumField(); i++ {
+			if !valuesEqual(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	// Functions, channels and unsafe pointers are only equal to themselves
+	return a.Pointer() == b.Pointer()
+}
+
+// Value feeds any value, so values ValuesEqual reports equal hash alike: pointers and
+// interfaces by what they point at, slices and arrays by their length and elements,
+// maps whatever order their entries come in
+func (h *Hasher) Value(v interface{}) {
+	h.value(reflect.ValueOf(v))
+}
+
+func (h *Hasher) value(v reflect.Value) {
+	if !v.IsValid() {
+		h.Uint(0)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			h.Uint(1)
+		} else {
+			h.Uint(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		h.Int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		h.Uint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		h.Float64(v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		h.Float64(real(v.Complex()))
+		h.Float64(imag(v.Complex()))
+	case reflect.String:
+		h.String(v.String())
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			h.Uint(0)
+			return
+		}
+		h.Uint(1)
+		if v.Kind() == reflect.Interface {
+			h.String(v.Elem().Type().String())
+		}
+		h.value(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 && v.Kind() == reflect.Slice {
+			h.Bytes(v.Bytes())
+			return
+		}
+		h.Uint(uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			h.value(v.Index(i))
+		}
+	case reflect.Map:
+		// Entries are combined by adding their hashes, which doesn't depend on order
+		var sum uint64
+		iter := v.MapRange()
+		for iter.Next() {
+			entry := NewHasher()
+			entry.value(iter.Key())
+			entry.value(iter.Value())
+			sum += entry.Sum64()
+		}
+		h.Uint(uint64(v.Len()))
+		h.Uint(sum)
+	case reflect.Struct:
+		if v.Type() == timeType && v.CanInterface() {
+			h.Time(v.Interface().(time.Time))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			h.value(v.Field(i))
+		}
+	default:
+		h.Uint(uint64(v.Pointer()))
+	}
+}
+
+// HashValue returns the hash of v that Hasher.Value feeds, for the Hash methods of
+// generated types that pair with ValuesEqual
+func HashValue(v interface{}) uint64 {
+	h := NewHasher()
+	h.Value(v)
+	return h.Sum64()
+}
@@ -0,0 +1,95 @@
+package runtime
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// shape stands in for the structs, pointers and union interfaces of generated types
+type shape interface{ isShape() }
+
+type circle struct{ Radius float64 }
+
+func (circle) isShape() {}
+
+type square struct{ Side float64 }
+
+func (square) isShape() {}
+
+type message struct {
+	ID      uint32
+	Name    string
+	Ratio   float32
+	Payload []byte
+	Items   []circle
+	Header  *circle
+	Shape   shape
+	Tags    map[string]int
+	Fixed   [2]int16
+	Sent    time.Time
+}
+
+func TestValuesEqual(t *testing.T) {
+	sent := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	base := func() *message {
+		return &message{
+			ID:      7,
+			Name:    "probe",
+			Ratio:   float32(math.NaN()),
+			Payload: []byte{1, 2},
+			Items:   []circle{{Radius: 1}},
+			Header:  &circle{Radius: 2},
+			Shape:   square{Side: 3},
+			Tags:    map[string]int{"a": 1, "b": 2},
+			Fixed:   [2]int16{-1, 1},
+			Sent:    sent,
+		}
+	}
+	require.True(t, ValuesEqual(base(), base()), "NaN equals itself")
+	require.Equal(t, HashValue(base()), HashValue(base()))
+
+	// Nil and empty slices and maps are equal, and hash alike
+	a, b := base(), base()
+	a.Payload, b.Payload = nil, []byte{}
+	a.Items, b.Items = nil, []circle{}
+	a.Tags, b.Tags = nil, map[string]int{}
+	require.True(t, ValuesEqual(a, b))
+	require.Equal(t, HashValue(a), HashValue(b))
+
+	// Times compare as instants
+	b = base()
+	b.Sent = sent.In(time.FixedZone("UTC+2", 2*60*60))
+	require.True(t, ValuesEqual(base(), b))
+	require.Equal(t, HashValue(base()), HashValue(b))
+
+	for name, change := range map[string]func(m *message){
+		"integer":        func(m *message) { m.ID = 8 },
+		"negative zero":  func(m *message) { m.Ratio = float32(math.Copysign(0, -1)) },
+		"byte":           func(m *message) { m.Payload[1] = 3 },
+		"length":         func(m *message) { m.Items = append(m.Items, circle{}) },
+		"pointer target": func(m *message) { m.Header.Radius = 5 },
+		"nil pointer":    func(m *message) { m.Header = nil },
+		"union variant":  func(m *message) { m.Shape = circle{Radius: 3} },
+		"nil union":      func(m *message) { m.Shape = nil },
+		"map value":      func(m *message) { m.Tags["b"] = 3 },
+		"map key":        func(m *message) { m.Tags = map[string]int{"a": 1, "c": 2} },
+		"array element":  func(m *message) { m.Fixed[0] = 0 },
+		"time":           func(m *message) { m.Sent = sent.Add(time.Nanosecond) },
+	} {
+		changed := base()
+		change(changed)
+		require.False(t, ValuesEqual(base(), changed), name)
+		require.False(t, ValuesEqual(changed, base()), name)
+		require.NotEqual(t, HashValue(base()), HashValue(changed), name)
+	}
+
+	var none *message
+	require.True(t, ValuesEqual(none, (*message)(nil)))
+	require.False(t, ValuesEqual(none, base()))
+	require.False(t, ValuesEqual(circle{}, square{}))
+	require.True(t, ValuesEqual(0.0, math.Copysign(0, 1)))
+	require.False(t, ValuesEqual(0.0, math.Copysign(0, -1)))
+}
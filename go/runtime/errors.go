@@ -1,5 +1,7 @@
 package runtime
 
+import "errors"
+
 // Error codes for cross-language compatibility.
 // These match the TypeScript implementation exactly.
 const (
@@ -15,3 +17,11 @@ const (
 	// ErrorCircularReference indicates infinite loop in pointer structures
 	ErrorCircularReference = "CIRCULAR_REFERENCE"
 )
+
+// ErrorOutputTooLarge indicates an encoding passed the limit it was given. The
+// TypeScript runtime has no encode limit, so it has no such code.
+const ErrorOutputTooLarge = "OUTPUT_TOO_LARGE"
+
+// ErrOutputTooLarge is returned by encodes stopped at their OutputLimit (code
+// OUTPUT_TOO_LARGE)
+var ErrOutputTooLarge = errors.New("output too large")
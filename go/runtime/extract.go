@@ -0,0 +1,28 @@
+package runtime
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrUnknownField is returned by generated ExtractXField for a path naming no field of
+// the type, or going on past a field that isn't a struct
+var ErrUnknownField = errors.New("unknown field")
+
+// ErrFieldAbsent is returned by generated ExtractXField for a conditional field the
+// data leaves out
+var ErrFieldAbsent = errors.New("field absent")
+
+// SplitFieldPath splits a dotted field path ("header.id") into its field names
+func SplitFieldPath(path string) []string {
+	return strings.Split(path, ".")
+}
+
+// Skip steps over n bytes without reading them, failing as a read would if fewer are left
+func (d *BitStreamDecoder) Skip(n int) error {
+	if d.byteOffset+n > len(d.bytes) {
+		return d.Incomplete(endOfStream())
+	}
+	d.byteOffset += n
+	return nil
+}
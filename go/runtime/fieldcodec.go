@@ -0,0 +1,74 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnknownCodec is returned for a field codec nothing is registered under
+var ErrUnknownCodec = errors.New("unknown codec")
+
+// EncodeFunc writes a field's value for a codec registered with RegisterCodec. It
+// writes at the encoder's bit position, as the generated code around it does.
+type EncodeFunc[T any] func(encoder *BitStreamEncoder, value T) error
+
+// DecodeFunc reads a field's value for a codec registered with RegisterCodec, leaving
+// the decoder after the field's last bit
+type DecodeFunc[T any] func(decoder *BitStreamDecoder) (T, error)
+
+// fieldCodec is a registered codec for values of type T
+type fieldCodec[T any] struct {
+	encode EncodeFunc[T]
+	decode DecodeFunc[T]
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]interface{}{}
+)
+
+// RegisterCodec makes encode and decode the codec of fields marked "codec": name,
+// replacing any registered before: hand-written reads and writes for fields the schema
+// can't describe, like proprietary float formats, in an otherwise generated type. T is
+// the Go type the field's schema type generates, float32 for "type": "float32".
+func RegisterCodec[T any](name string, encode EncodeFunc[T], decode DecodeFunc[T]) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[name] = fieldCodec[T]{encode: encode, decode: decode}
+}
+
+// lookupCodec returns the codec registered under name for values of type T
+func lookupCodec[T any](name string) (fieldCodec[T], error) {
+	codecsMu.RLock()
+	registered, ok := codecs[name]
+	codecsMu.RUnlock()
+	if !ok {
+		return fieldCodec[T]{}, fmt.Errorf("%w %q: register it with runtime.RegisterCodec", ErrUnknownCodec, name)
+	}
+	codec, ok := registered.(fieldCodec[T])
+	if !ok {
+		var zero T
+		return fieldCodec[T]{}, fmt.Errorf("codec %q is registered for another type than %T", name, zero)
+	}
+	return codec, nil
+}
+
+// EncodeCustom writes value with the codec registered under name
+func EncodeCustom[T any](name string, encoder *BitStreamEncoder, value T) error {
+	codec, err := lookupCodec[T](name)
+	if err != nil {
+		return err
+	}
+	return codec.encode(encoder, value)
+}
+
+// DecodeCustom reads a value with the codec registered under name
+func DecodeCustom[T any](name string, decoder *BitStreamDecoder) (T, error) {
+	codec, err := lookupCodec[T](name)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return codec.decode(decoder)
+}
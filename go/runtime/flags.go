@@ -0,0 +1,35 @@
+package runtime
+
+import "strconv"
+
+// FlagName names one flag of a generated flag set type
+type FlagName struct {
+	Value uint64
+	Name  string
+}
+
+// FormatFlags lists the flags set in v by name, as "syn|ack", in the order of names.
+// Bits no flag covers follow in hex, and no flags at all is "0".
+func FormatFlags(v uint64, names []FlagName) string {
+	if v == 0 {
+		return "0"
+	}
+	out := ""
+	rest := v
+	for _, flag := range names {
+		if flag.Value != 0 && v&flag.Value == flag.Value {
+			if out != "" {
+				out += "|"
+			}
+			out += flag.Name
+			rest &^= flag.Value
+		}
+	}
+	if rest != 0 {
+		if out != "" {
+			out += "|"
+		}
+		out += "0x" + strconv.FormatUint(rest, 16)
+	}
+	return out
+}
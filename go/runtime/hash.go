@@ -0,0 +1,78 @@
+package runtime
+
+import (
+	"math"
+	"time"
+)
+
+// FNV-1a 64-bit parameters
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// Hasher accumulates the 64-bit FNV-1a hash behind generated Hash methods. Values are
+// fed in a fixed byte order, so a hash depends only on the values: it is the same on
+// every platform and in every process.
+type Hasher struct {
+	sum uint64
+}
+
+// NewHasher returns a Hasher with nothing fed to it
+func NewHasher() *Hasher {
+	return &Hasher{sum: fnvOffset64}
+}
+
+// Sum64 returns the hash of everything fed so far
+func (h *Hasher) Sum64() uint64 {
+	return h.sum
+}
+
+// Uint feeds an unsigned integer, as 8 bytes whatever its type
+func (h *Hasher) Uint(v uint64) {
+	for i := 0; i < 8; i++ {
+		h.sum ^= v & 0xFF
+		h.sum *= fnvPrime64
+		v >>= 8
+	}
+}
+
+// Int feeds a signed integer
+func (h *Hasher) Int(v int64) {
+	h.Uint(uint64(v))
+}
+
+// Float32 feeds a float's bits, so NaNs with the same bits hash alike and 0 and -0 don't
+func (h *Hasher) Float32(v float32) {
+	h.Uint(uint64(math.Float32bits(v)))
+}
+
+// Float64 feeds a float's bits, like Float32
+func (h *Hasher) Float64(v float64) {
+	h.Uint(math.Float64bits(v))
+}
+
+// Bytes feeds a length and then the bytes, so consecutive byte strings can't run into
+// each other. A nil slice hashes as an empty one.
+func (h *Hasher) Bytes(b []byte) {
+	h.Uint(uint64(len(b)))
+	for _, c := range b {
+		h.sum ^= uint64(c)
+		h.sum *= fnvPrime64
+	}
+}
+
+// String feeds a string as Bytes would
+func (h *Hasher) String(s string) {
+	h.Uint(uint64(len(s)))
+	for i := 0; i < len(s); i++ {
+		h.sum ^= uint64(s[i])
+		h.sum *= fnvPrime64
+	}
+}
+
+// Time feeds the instant t stands for, ignoring its location as time.Time.Equal does
+func (h *Hasher) Time(t time.Time) {
+	h.Int(t.Unix())
+	h.Uint(uint64(t.Nanosecond()))
+}
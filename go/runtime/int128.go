@@ -0,0 +1,198 @@
+package runtime
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Uint128 is an unsigned 128-bit integer, as used for UUIDs, IPv6 addresses and
+// cryptographic counters. Hi holds the most significant 64 bits.
+type Uint128 struct {
+	Hi, Lo uint64
+}
+
+// Int128 is a two's complement signed 128-bit integer. Hi holds the most significant
+// 64 bits, including the sign.
+type Int128 struct {
+	Hi, Lo uint64
+}
+
+var (
+	maxUint128 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+	maxInt128  = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 127), big.NewInt(1))
+	minInt128  = new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 127))
+)
+
+// Uint128FromBytes reads a big-endian 16-byte value, such as a UUID or an IPv6 address
+func Uint128FromBytes(b [16]byte) Uint128 {
+	return Uint128{Hi: binary.BigEndian.Uint64(b[:8]), Lo: binary.BigEndian.Uint64(b[8:])}
+}
+
+// Bytes returns the value as 16 big-endian bytes
+func (u Uint128) Bytes() [16]byte {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], u.Hi)
+	binary.BigEndian.PutUint64(b[8:], u.Lo)
+	return b
+}
+
+// Big returns the value as a big.Int
+func (u Uint128) Big() *big.Int {
+	n := new(big.Int).SetUint64(u.Hi)
+	n.Lsh(n, 64)
+	return n.Or(n, new(big.Int).SetUint64(u.Lo))
+}
+
+// Uint128FromBig converts a big.Int, rejecting values outside 0 to 2^128-1
+func Uint128FromBig(n *big.Int) (Uint128, error) {
+	if n.Sign() < 0 || n.Cmp(maxUint128) > 0 {
+		return Uint128{}, fmt.Errorf("%s doesn't fit in uint128", n)
+	}
+	return Uint128{Hi: new(big.Int).Rsh(n, 64).Uint64(), Lo: n.Uint64()}, nil
+}
+
+// String formats the value in decimal
+func (u Uint128) String() string {
+	return u.Big().String()
+}
+
+// Int returns the value as an int, for a length, count or offset held in 128 bits, or
+// an error when it is larger than an int holds on this platform
+func (u Uint128) Int() (int, error) {
+	if u.Hi != 0 || u.Lo > math.MaxInt {
+		return 0, fmt.Errorf("%s is larger than an int holds", u)
+	}
+	return int(u.Lo), nil
+}
+
+// MarshalJSON writes the value as a JSON number with all its digits
+func (u Uint128) MarshalJSON() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number or a decimal string
+func (u *Uint128) UnmarshalJSON(data []byte) error {
+	n, err := unmarshalBigInt(data)
+	if err != nil {
+		return err
+	}
+	*u, err = Uint128FromBig(n)
+	return err
+}
+
+// Big returns the value as a big.Int
+func (i Int128) Big() *big.Int {
+	n := Uint128(i).Big()
+	if int64(i.Hi) < 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), 128))
+	}
+	return n
+}
+
+// Int128FromBig converts a big.Int, rejecting values outside -2^127 to 2^127-1
+func Int128FromBig(n *big.Int) (Int128, error) {
+	if n.Cmp(minInt128) < 0 || n.Cmp(maxInt128) > 0 {
+		return Int128{}, fmt.Errorf("%s doesn't fit in int128", n)
+	}
+	if n.Sign() < 0 {
+		n = new(big.Int).Add(n, new(big.Int).Lsh(big.NewInt(1), 128))
+	}
+	return Int128{Hi: new(big.Int).Rsh(n, 64).Uint64(), Lo: n.Uint64()}, nil
+}
+
+// Int returns the value as an int, or an error when it is outside what an int holds
+// on this platform
+func (i Int128) Int() (int, error) {
+	switch {
+	case i.Hi == 0 && i.Lo <= math.MaxInt:
+		return int(i.Lo), nil
+	case i.Hi == math.MaxUint64 && int64(i.Lo) < 0 && int64(i.Lo) >= math.MinInt:
+		return int(int64(i.Lo)), nil
+	}
+	return 0, fmt.Errorf("%s is outside what an int holds", i)
+}
+
+// String formats the value in decimal
+func (i Int128) String() string {
+	return i.Big().String()
+}
+
+// MarshalJSON writes the value as a JSON number with all its digits
+func (i Int128) MarshalJSON() ([]byte, error) {
+	return []byte(i.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number or a decimal string
+func (i *Int128) UnmarshalJSON(data []byte) error {
+	n, err := unmarshalBigInt(data)
+	if err != nil {
+		return err
+	}
+	*i, err = Int128FromBig(n)
+	return err
+}
+
+// unmarshalBigInt reads an integer given as a JSON number or, for readers whose JSON
+// numbers lose precision, as a decimal string
+func unmarshalBigInt(data []byte) (*big.Int, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var num json.Number
+		if err := json.Unmarshal(data, &num); err != nil {
+			return nil, err
+		}
+		s = num.String()
+	}
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an integer", data)
+	}
+	return n, nil
+}
+
+// ReadUint128 reads a 128-bit unsigned integer, as two 64-bit halves in byte order
+func (d *BitStreamDecoder) ReadUint128(endianness Endianness) (Uint128, error) {
+	if endianness == DynamicEndian {
+		endianness = d.endianness
+	}
+	first, err := d.ReadUint64(endianness)
+	if err != nil {
+		return Uint128{}, err
+	}
+	second, err := d.ReadUint64(endianness)
+	if err != nil {
+		return Uint128{}, err
+	}
+	if endianness == BigEndian {
+		return Uint128{Hi: first, Lo: second}, nil
+	}
+	return Uint128{Hi: second, Lo: first}, nil
+}
+
+// ReadInt128 reads a 128-bit two's complement integer
+func (d *BitStreamDecoder) ReadInt128(endianness Endianness) (Int128, error) {
+	v, err := d.ReadUint128(endianness)
+	return Int128(v), err
+}
+
+// WriteUint128 writes a 128-bit unsigned integer, as two 64-bit halves in byte order
+func (e *BitStreamEncoder) WriteUint128(value Uint128, endianness Endianness) {
+	if endianness == DynamicEndian {
+		endianness = e.endianness
+	}
+	if endianness == BigEndian {
+		e.WriteUint64(value.Hi, endianness)
+		e.WriteUint64(value.Lo, endianness)
+	} else {
+		e.WriteUint64(value.Lo, endianness)
+		e.WriteUint64(value.Hi, endianness)
+	}
+}
+
+// WriteInt128 writes a 128-bit two's complement integer
+func (e *BitStreamEncoder) WriteInt128(value Int128, endianness Endianness) {
+	e.WriteUint128(Uint128(value), endianness)
+}
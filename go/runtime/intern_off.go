@@ -0,0 +1,11 @@
+//go:build !tinygo && !intern_errors
+
+package runtime
+
+import "errors"
+
+// endOfStream returns the error of a read past the end of the data, a new one each
+// time. TinyGo builds, and builds with -tags intern_errors, share one instead.
+func endOfStream() error {
+	return errors.New("unexpected end of stream")
+}
@@ -0,0 +1,16 @@
+//go:build tinygo || intern_errors
+
+package runtime
+
+import "errors"
+
+// errEndOfStream is the one error every read past the end of the data returns, so
+// a failed read, which streaming decoders retry until the data is complete, doesn't
+// allocate on small heaps
+var errEndOfStream = errors.New("unexpected end of stream")
+
+// endOfStream returns the error of a read past the end of the data. Build without
+// -tags tinygo or intern_errors to get a new one each time.
+func endOfStream() error {
+	return errEndOfStream
+}
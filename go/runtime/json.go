@@ -0,0 +1,29 @@
+package runtime
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// JSONBytes is a byte array that marshals to JSON as an array of numbers, the shape
+// test vectors and the dynamic API use, instead of encoding/json's base64 string.
+// Unmarshaling accepts either form.
+type JSONBytes []byte
+
+// MarshalJSON writes the bytes as an array of numbers
+func (b JSONBytes) MarshalJSON() ([]byte, error) {
+	out := make([]byte, 0, 2+len(b)*4)
+	out = append(out, '[')
+	for i, v := range b {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		out = strconv.AppendUint(out, uint64(v), 10)
+	}
+	return append(out, ']'), nil
+}
+
+// UnmarshalJSON reads an array of numbers or a base64 string
+func (b *JSONBytes) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*[]byte)(b))
+}
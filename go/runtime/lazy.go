@@ -0,0 +1,86 @@
+package runtime
+
+import "encoding/json"
+
+// Lazy holds a field marked lazy in the schema: decoding records the bytes it spans and
+// Get decodes them the first time it is called, so payloads most callers never touch
+// are never materialized. The bytes are a view of the input, which must stay unchanged
+// until the field is decoded. The zero Lazy holds the zero value of T.
+//
+// Get caches its result in the Lazy, so a Lazy is not safe for concurrent use until
+// it has been decoded.
+type Lazy[T any] struct {
+	raw     []byte
+	decode  func([]byte) (T, error)
+	value   T
+	err     error
+	pending bool
+}
+
+// NewLazy returns a Lazy that decodes raw with decode on first access
+func NewLazy[T any](raw []byte, decode func([]byte) (T, error)) Lazy[T] {
+	return Lazy[T]{raw: raw, decode: decode, pending: true}
+}
+
+// LazyOf returns a Lazy holding an already decoded value, for building messages to encode
+func LazyOf[T any](value T) Lazy[T] {
+	return Lazy[T]{value: value}
+}
+
+// Get returns the field's value, decoding it on the first call. A decode error is
+// returned by this and every later call.
+func (l *Lazy[T]) Get() (T, error) {
+	if l.pending {
+		l.value, l.err = l.decode(l.raw)
+		l.pending = false
+	}
+	return l.value, l.err
+}
+
+// Set replaces the field's value, dropping the bytes it was decoded from
+func (l *Lazy[T]) Set(value T) {
+	*l = LazyOf(value)
+}
+
+// Raw returns the encoded bytes the field was decoded from, or nil if it was built with
+// LazyOf or Set
+func (l *Lazy[T]) Raw() []byte {
+	return l.raw
+}
+
+// Decoded reports whether Get has nothing left to decode
+func (l *Lazy[T]) Decoded() bool {
+	return !l.pending
+}
+
+// Clone returns a Lazy that no longer views the input: its bytes are copied, to be
+// decoded again from the copy. A value built with LazyOf or Set is kept as it is.
+func (l *Lazy[T]) Clone() Lazy[T] {
+	if l.raw == nil {
+		return *l
+	}
+	return NewLazy(append([]byte(nil), l.raw...), l.decode)
+}
+
+// MarshalJSON writes the field's value, decoding it without caching the result if it
+// hasn't been decoded. Byte arrays are written as arrays of numbers, like JSONBytes.
+func (l Lazy[T]) MarshalJSON() ([]byte, error) {
+	value, err := l.Get()
+	if err != nil {
+		return nil, err
+	}
+	if b, ok := any(value).([]byte); ok {
+		return JSONBytes(b).MarshalJSON()
+	}
+	return json.Marshal(value)
+}
+
+// UnmarshalJSON sets the field's value from JSON
+func (l *Lazy[T]) UnmarshalJSON(data []byte) error {
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	l.Set(value)
+	return nil
+}
@@ -0,0 +1,105 @@
+package runtime
+
+import "fmt"
+
+// MappedDecoder decodes records of a large file mapped into memory rather than read:
+// the OS loads pages as records touch them, decoding starts at any offset, and ZeroCopy
+// strings and Lazy fields view the mapping instead of copying it. Every decoder it
+// hands out reads the whole file, so instances find their positions in it and decoders
+// seek to them. The mapping is read-only, and values viewing it are only valid until
+// Close. A MappedDecoder is safe for concurrent use; the decoders it hands out aren't.
+type MappedDecoder struct {
+	data     []byte
+	bitOrder BitOrder
+	unmap    func() error
+}
+
+// NewMappedDecoder returns a MappedDecoder over data already in memory, which Close
+// leaves alone
+func NewMappedDecoder(data []byte, bitOrder BitOrder) *MappedDecoder {
+	return &MappedDecoder{data: data, bitOrder: bitOrder}
+}
+
+// OpenMapped maps the file at path into memory. Where the OS can't map files, and in
+// TinyGo builds, it reads the file instead.
+func OpenMapped(path string, bitOrder BitOrder) (*MappedDecoder, error) {
+	data, unmap, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MappedDecoder{data: data, bitOrder: bitOrder, unmap: unmap}, nil
+}
+
+// Close unmaps the file. Decoders and values viewing it must not be used after.
+func (m *MappedDecoder) Close() error {
+	unmap := m.unmap
+	m.data, m.unmap = nil, nil
+	if unmap == nil {
+		return nil
+	}
+	return unmap()
+}
+
+// Bytes returns the mapped data
+func (m *MappedDecoder) Bytes() []byte {
+	return m.data
+}
+
+// Len returns the size of the mapped data in bytes
+func (m *MappedDecoder) Len() int64 {
+	return int64(len(m.data))
+}
+
+// At returns a decoder over the whole data, at offset
+func (m *MappedDecoder) At(offset int64) (*BitStreamDecoder, error) {
+	if offset < 0 || offset > int64(len(m.data)) {
+		return nil, fmt.Errorf("offset %d is outside the %d bytes mapped", offset, len(m.data))
+	}
+	decoder := NewBitStreamDecoder(m.data, m.bitOrder)
+	decoder.Seek(int(offset))
+	return decoder, nil
+}
+
+// DecodeAt decodes the record at offset with decode, a DecodeXWithDecoder generated
+// with ExportDecoders
+func DecodeAt[T any](m *MappedDecoder, offset int64, decode func(*BitStreamDecoder) (T, error)) (T, error) {
+	decoder, err := m.At(offset)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return decode(decoder)
+}
+
+// MappedRecords indexes records of a MappedDecoder by their offsets, as a container's
+// table of contents gives them, and decodes each only when it is asked for
+type MappedRecords[T any] struct {
+	mapped  *MappedDecoder
+	offsets []int64
+	decode  func(*BitStreamDecoder) (T, error)
+}
+
+// NewMappedRecords returns the records of m at offsets, decoded with decode (a
+// DecodeXWithDecoder)
+func NewMappedRecords[T any](m *MappedDecoder, offsets []int64, decode func(*BitStreamDecoder) (T, error)) *MappedRecords[T] {
+	return &MappedRecords[T]{mapped: m, offsets: offsets, decode: decode}
+}
+
+// Len returns the number of records
+func (r *MappedRecords[T]) Len() int {
+	return len(r.offsets)
+}
+
+// Offset returns the offset of record i
+func (r *MappedRecords[T]) Offset(i int) int64 {
+	return r.offsets[i]
+}
+
+// At decodes record i. Each call decodes it again: keep what's used more than once.
+func (r *MappedRecords[T]) At(i int) (T, error) {
+	value, err := DecodeAt(r.mapped, r.offsets[i], r.decode)
+	if err != nil {
+		return value, fmt.Errorf("record %d at offset %d: %w", i, r.offsets[i], err)
+	}
+	return value, nil
+}
@@ -0,0 +1,127 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// record is read as a big-endian uint32 id, then a name after its uint8 length,
+// viewing the mapping as ZeroCopy strings do
+type record struct {
+	ID   uint32
+	Name []byte
+}
+
+func decodeRecord(decoder *BitStreamDecoder) (record, error) {
+	id, err := decoder.ReadUint32(BigEndian)
+	if err != nil {
+		return record{}, err
+	}
+	length, err := decoder.ReadUint8()
+	if err != nil {
+		return record{}, err
+	}
+	name, err := decoder.ReadBytesSlice(int(length))
+	if err != nil {
+		return record{}, err
+	}
+	return record{ID: id, Name: name}, nil
+}
+
+// recordFile writes "alpha" at offset 0 and "beta" at offset 10, then a record cut
+// off in its name at offset 19
+func recordFile(t *testing.T) string {
+	data := []byte{
+		0, 0, 0, 1, 5, 'a', 'l', 'p', 'h', 'a',
+		0, 0, 0, 2, 4, 'b', 'e', 't', 'a',
+		0, 0, 0, 3, 9, 'c', 'u', 't',
+	}
+	path := filepath.Join(t.TempDir(), "records.bin")
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	return path
+}
+
+func TestMappedDecoderDecodesAtOffsets(t *testing.T) {
+	mapped, err := OpenMapped(recordFile(t), MSBFirst)
+	require.NoError(t, err)
+	defer mapped.Close()
+	require.Equal(t, int64(27), mapped.Len())
+
+	beta, err := DecodeAt(mapped, 10, decodeRecord)
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), beta.ID)
+	require.Equal(t, "beta", string(beta.Name))
+	// The name views the mapping rather than a copy of it
+	require.Same(t, &mapped.Bytes()[15], &beta.Name[0])
+
+	// Decoders read the whole data from their offset on
+	decoder, err := mapped.At(5)
+	require.NoError(t, err)
+	require.Equal(t, 5, decoder.Position())
+	name, err := decoder.ReadBytesSlice(5)
+	require.NoError(t, err)
+	require.Equal(t, "alpha", string(name))
+	_, err = mapped.At(mapped.Len())
+	require.NoError(t, err)
+
+	for _, offset := range []int64{-1, 28} {
+		_, err = mapped.At(offset)
+		require.ErrorContains(t, err, "outside the 27 bytes mapped")
+		_, err = DecodeAt(mapped, offset, decodeRecord)
+		require.Error(t, err)
+	}
+}
+
+func TestMappedRecords(t *testing.T) {
+	mapped, err := OpenMapped(recordFile(t), MSBFirst)
+	require.NoError(t, err)
+	defer mapped.Close()
+
+	records := NewMappedRecords(mapped, []int64{10, 0, 19}, decodeRecord)
+	require.Equal(t, 3, records.Len())
+	require.Equal(t, int64(0), records.Offset(1))
+
+	first, err := records.At(1)
+	require.NoError(t, err)
+	require.Equal(t, record{ID: 1, Name: []byte("alpha")}, first)
+	second, err := records.At(0)
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), second.ID)
+
+	// A record running past the end of the file fails with its index and offset
+	_, err = records.At(2)
+	require.ErrorContains(t, err, "record 2 at offset 19")
+}
+
+func TestMappedDecoderClose(t *testing.T) {
+	mapped, err := OpenMapped(recordFile(t), MSBFirst)
+	require.NoError(t, err)
+	require.NoError(t, mapped.Close())
+	require.Zero(t, mapped.Len())
+	require.NoError(t, mapped.Close())
+
+	// Empty files open with nothing to decode
+	empty := filepath.Join(t.TempDir(), "empty.bin")
+	require.NoError(t, os.WriteFile(empty, nil, 0o644))
+	mapped, err = OpenMapped(empty, MSBFirst)
+	require.NoError(t, err)
+	require.Zero(t, mapped.Len())
+	_, err = DecodeAt(mapped, 0, decodeRecord)
+	require.Error(t, err)
+	require.NoError(t, mapped.Close())
+
+	_, err = OpenMapped(filepath.Join(t.TempDir(), "missing.bin"), MSBFirst)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	// Data already in memory is left alone
+	data := []byte{0, 0, 0, 7, 0}
+	mapped = NewMappedDecoder(data, MSBFirst)
+	value, err := DecodeAt(mapped, 0, decodeRecord)
+	require.NoError(t, err)
+	require.Equal(t, uint32(7), value.ID)
+	require.NoError(t, mapped.Close())
+	require.Equal(t, []byte{0, 0, 0, 7, 0}, data)
+}
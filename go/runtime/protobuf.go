@@ -0,0 +1,145 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Protobuf wire types: how the value after a field's tag is encoded
+const (
+	WireVarint  = 0 // LEB128 integer: int32, int64, uint32, uint64, sint32, sint64, bool, enum
+	WireFixed64 = 1 // 8 bytes little-endian: fixed64, sfixed64, double
+	WireBytes   = 2 // Varint length, then that many bytes: string, bytes, messages, packed repeated
+	WireFixed32 = 5 // 4 bytes little-endian: fixed32, sfixed32, float
+)
+
+// Highest field number protobuf allows
+const MaxProtobufFieldNumber = 1<<29 - 1
+
+// WriteProtobufTag writes the key starting a protobuf field
+func (e *BitStreamEncoder) WriteProtobufTag(fieldNumber int, wireType int) {
+	e.WriteVarlengthLEB128(uint64(fieldNumber)<<3 | uint64(wireType))
+}
+
+// WriteProtobufBytes writes a length-delimited value: its length as a varint, then the bytes
+func (e *BitStreamEncoder) WriteProtobufBytes(data []byte) {
+	e.WriteVarlengthLEB128(uint64(len(data)))
+	e.WriteBytes(data)
+}
+
+// ReadProtobufTag reads the key starting a protobuf field
+func (d *BitStreamDecoder) ReadProtobufTag() (fieldNumber int, wireType int, err error) {
+	key, err := d.ReadVarlengthLEB128()
+	if err != nil {
+		return 0, 0, err
+	}
+	if key>>3 == 0 || key>>3 > MaxProtobufFieldNumber {
+		return 0, 0, d.protobufError(fmt.Sprintf("invalid protobuf field number %d", key>>3))
+	}
+	return int(key >> 3), int(key & 7), nil
+}
+
+// ReadProtobufLength reads the length of a length-delimited value and checks the input holds it
+func (d *BitStreamDecoder) ReadProtobufLength() (int, error) {
+	n, err := d.ReadVarlengthLEB128()
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(d.bytes)-d.byteOffset) {
+		errCode := ErrorIncompleteData
+		d.LastErrorCode = &errCode
+		return 0, endOfStream()
+	}
+	return int(n), nil
+}
+
+// ReadProtobufBytes reads a length-delimited value, as a slice of the input
+func (d *BitStreamDecoder) ReadProtobufBytes() ([]byte, error) {
+	n, err := d.ReadProtobufLength()
+	if err != nil {
+		return nil, err
+	}
+	return d.ReadBytesSlice(n)
+}
+
+// CheckWireType returns an error if a field arrived with another wire type than its
+// schema gives it
+func (d *BitStreamDecoder) CheckWireType(fieldNumber, wireType, want int) error {
+	if wireType == want {
+		return nil
+	}
+	return d.protobufError(fmt.Sprintf("protobuf field %d has wire type %d, expected %d", fieldNumber, wireType, want))
+}
+
+// SkipProtobufField skips the value of a field the schema doesn't know, so messages
+// from newer schema versions still decode
+func (d *BitStreamDecoder) SkipProtobufField(wireType int) error {
+	switch wireType {
+	case WireVarint:
+		_, err := d.ReadVarlengthLEB128()
+		return err
+	case WireFixed64:
+		_, err := d.ReadBytesSlice(8)
+		return err
+	case WireBytes:
+		_, err := d.ReadProtobufBytes()
+		return err
+	case WireFixed32:
+		_, err := d.ReadBytesSlice(4)
+		return err
+	}
+	// Groups (3 and 4) are deprecated and not supported
+	return d.protobufError(fmt.Sprintf("unsupported protobuf wire type %d", wireType))
+}
+
+// LimitBytes makes the input end n bytes from the current position, for a value that
+// extends to the end of a length-delimited region. It returns the length to pass to
+// RestoreLimit once the value is read.
+func (d *BitStreamDecoder) LimitBytes(n int) (int, error) {
+	if n < 0 || d.byteOffset+n > len(d.bytes) {
+		errCode := ErrorIncompleteData
+		d.LastErrorCode = &errCode
+		return 0, endOfStream()
+	}
+	saved := len(d.bytes)
+	d.bytes = d.bytes[:d.byteOffset+n]
+	return saved, nil
+}
+
+// RestoreLimit ends a region started by LimitBytes, skipping what of it wasn't read
+func (d *BitStreamDecoder) RestoreLimit(saved int) {
+	d.byteOffset = len(d.bytes)
+	d.bitOffset = 0
+	d.bytes = d.bytes[:saved]
+}
+
+// AtEnd reports whether all of the input has been read
+func (d *BitStreamDecoder) AtEnd() bool {
+	return d.byteOffset >= len(d.bytes)
+}
+
+func (d *BitStreamDecoder) protobufError(msg string) error {
+	errCode := ErrorSchemaMismatch
+	d.LastErrorCode = &errCode
+	return errors.New(msg)
+}
+
+// ZigZag32 maps a signed value to an unsigned one small for small magnitudes, as sint32 is encoded
+func ZigZag32(v int32) uint64 {
+	return uint64(uint32(v<<1) ^ uint32(v>>31))
+}
+
+// ZigZag64 maps a signed value to an unsigned one small for small magnitudes, as sint64 is encoded
+func ZigZag64(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// UnZigZag32 undoes ZigZag32
+func UnZigZag32(v uint64) int32 {
+	return int32(uint32(v)>>1) ^ -int32(uint32(v)&1)
+}
+
+// UnZigZag64 undoes ZigZag64
+func UnZigZag64(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
@@ -0,0 +1,24 @@
+package runtime
+
+// Reuse returns s resized to n elements, reusing its backing array when it has room,
+// so a decoder filling the same slice again doesn't allocate. Reused elements keep
+// their old values until overwritten. Otherwise the slice comes from arena (the heap
+// if nil), so a nil s gives a new, non-nil slice.
+func Reuse[T any](arena *DecodeArena, s []T, n int) []T {
+	if s != nil && cap(s) >= n {
+		return s[:n]
+	}
+	return ArenaSlice[T](arena, n)
+}
+
+// Extend returns s one element longer, reusing the element past its end when s has
+// room so that element's own slices can be refilled too. Otherwise s is copied to a
+// slice twice the size from arena (the heap if nil).
+func Extend[T any](arena *DecodeArena, s []T) []T {
+	if len(s) < cap(s) {
+		return s[:len(s)+1]
+	}
+	grown := ArenaSlice[T](arena, max(2*cap(s), 4))
+	copy(grown, s)
+	return grown[:len(s)+1]
+}
@@ -0,0 +1,112 @@
+package runtime
+
+// SchemaInfo describes the schema a package was generated from.
+// Generated code exposes it through a package-level Schema() function so tools
+// (debug UIs, admin endpoints, diffing) can introspect types without the schema file.
+type SchemaInfo struct {
+	Title       string
+	Description string
+	Version     string
+	Endianness  string     // "big_endian" or "little_endian"
+	BitOrder    string     // "msb_first" or "lsb_first"
+	Types       []TypeInfo // Sorted by name
+}
+
+// TypeInfo describes one schema type and the Go struct generated for it
+type TypeInfo struct {
+	Name        string // Schema name
+	GoName      string
+	Description string
+	Width       int // Encoded size in bits if every field has a fixed size, else 0
+	Fields      []FieldInfo
+}
+
+// FieldInfo describes one field of a type, or the items of an array
+type FieldInfo struct {
+	Name        string // Schema name ("" for array items)
+	GoName      string
+	Type        string // Schema type: "uint16", "string", "array", or a type name
+	Kind        string // For strings and arrays: "fixed", "length_prefixed", ...
+	Width       int    // Encoded size in bits if fixed, else 0
+	Endianness  string // Effective byte order for multi-byte values
+	LengthType  string // Length prefix type for length_prefixed kinds
+	Length      int    // Element count (arrays) or byte length (strings) for fixed kinds
+	Conditional string
+	Optional    bool
+	Description string
+	Metadata    map[string]interface{}
+	Items       *FieldInfo // Array item description
+}
+
+// Type looks up a type by schema name
+func (s *SchemaInfo) Type(name string) (*TypeInfo, bool) {
+	for i := range s.Types {
+		if s.Types[i].Name == name {
+			return &s.Types[i], true
+		}
+	}
+	return nil, false
+}
+
+// Field looks up a field by schema name
+func (t *TypeInfo) Field(name string) (*FieldInfo, bool) {
+	for i := range t.Fields {
+		if t.Fields[i].Name == name {
+			return &t.Fields[i], true
+		}
+	}
+	return nil, false
+}
+
+// Clone returns a deep copy of s, metadata included, so callers can change it
+// without affecting other users of the same SchemaInfo
+func (s *SchemaInfo) Clone() *SchemaInfo {
+	clone := *s
+	clone.Types = make([]TypeInfo, len(s.Types))
+	for i, t := range s.Types {
+		clone.Types[i] = t
+		clone.Types[i].Fields = cloneFields(t.Fields)
+	}
+	return &clone
+}
+
+func cloneFields(fields []FieldInfo) []FieldInfo {
+	if fields == nil {
+		return nil
+	}
+	clones := make([]FieldInfo, len(fields))
+	for i := range fields {
+		clones[i] = fields[i].clone()
+	}
+	return clones
+}
+
+func (f FieldInfo) clone() FieldInfo {
+	if f.Metadata != nil {
+		f.Metadata = cloneMetadata(f.Metadata).(map[string]interface{})
+	}
+	if f.Items != nil {
+		items := f.Items.clone()
+		f.Items = &items
+	}
+	return f
+}
+
+// cloneMetadata copies a metadata value, recursing into its objects and arrays
+func cloneMetadata(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		clone := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			clone[key] = cloneMetadata(item)
+		}
+		return clone
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneMetadata(item)
+		}
+		return clone
+	}
+	return value
+}
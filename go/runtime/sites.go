@@ -0,0 +1,136 @@
+package runtime
+
+// PositionSite numbers an array/type pair whose item positions and occurrences an
+// encode tracks for first<Type>, last<Type> and corresponding<Type> selectors.
+// Generators assign the numbers (0, 1, ...) when generating, so the encode path
+// indexes slices instead of building "arrayName_typeName" keys for Positions and
+// TypeIndices and hashing them.
+type PositionSite int
+
+// siteTable holds what is tracked per PositionSite. Contexts derived from one
+// another share one table, like they share Positions.
+type siteTable struct {
+	positions [][]int
+	counts    []int
+}
+
+// grow makes room for site in both slices
+func (t *siteTable) grow(site PositionSite) {
+	for len(t.positions) <= int(site) {
+		t.positions = append(t.positions, nil)
+		t.counts = append(t.counts, 0)
+	}
+}
+
+// clone copies the table for Fork
+func (t *siteTable) clone() *siteTable {
+	if t == nil {
+		return &siteTable{}
+	}
+	cloned := &siteTable{
+		positions: make([][]int, len(t.positions)),
+		counts:    append([]int(nil), t.counts...),
+	}
+	for i, positions := range t.positions {
+		cloned.positions[i] = append([]int(nil), positions...)
+	}
+	return cloned
+}
+
+// sitePositions returns the positions tracked for site. The caller holds the lock.
+func (ctx *EncodingContext) sitePositions(site PositionSite) []int {
+	if ctx.sites == nil || site < 0 || int(site) >= len(ctx.sites.positions) {
+		return nil
+	}
+	return ctx.sites.positions[site]
+}
+
+// TrackSitePosition records the byte position of the next occurrence at site
+func (ctx *EncodingContext) TrackSitePosition(site PositionSite, position int) {
+	if ctx == nil || site < 0 {
+		return
+	}
+	ctx.lock()
+	defer ctx.unlock()
+
+	if ctx.sites == nil {
+		ctx.sites = &siteTable{}
+	}
+	ctx.sites.grow(site)
+	ctx.sites.positions[site] = append(ctx.sites.positions[site], position)
+}
+
+// SitePosition returns the position of occurrence index at site
+func (ctx *EncodingContext) SitePosition(site PositionSite, index int) (int, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	ctx.lock()
+	defer ctx.unlock()
+
+	positions := ctx.sitePositions(site)
+	if index < 0 || index >= len(positions) {
+		return 0, false
+	}
+	return positions[index], true
+}
+
+// FirstSitePosition returns the position of the first occurrence at site
+func (ctx *EncodingContext) FirstSitePosition(site PositionSite) (int, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	ctx.lock()
+	defer ctx.unlock()
+
+	positions := ctx.sitePositions(site)
+	if len(positions) == 0 {
+		return 0, false
+	}
+	return positions[0], true
+}
+
+// LastSitePosition returns the position of the last occurrence at site
+func (ctx *EncodingContext) LastSitePosition(site PositionSite) (int, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	ctx.lock()
+	defer ctx.unlock()
+
+	positions := ctx.sitePositions(site)
+	if len(positions) == 0 {
+		return 0, false
+	}
+	return positions[len(positions)-1], true
+}
+
+// SiteTypeIndex returns how many occurrences have been counted at site
+func (ctx *EncodingContext) SiteTypeIndex(site PositionSite) int {
+	if ctx == nil {
+		return 0
+	}
+	ctx.lock()
+	defer ctx.unlock()
+
+	if ctx.sites == nil || site < 0 || int(site) >= len(ctx.sites.counts) {
+		return 0
+	}
+	return ctx.sites.counts[site]
+}
+
+// IncrementSiteTypeIndex counts one more occurrence at site and returns the new count
+func (ctx *EncodingContext) IncrementSiteTypeIndex(site PositionSite) int {
+	if ctx == nil || site < 0 {
+		return 0
+	}
+	ctx.lock()
+	defer ctx.unlock()
+
+	if ctx.sites == nil {
+		ctx.sites = &siteTable{}
+	}
+	ctx.sites.grow(site)
+	ctx.sites.counts[site]++
+	return ctx.sites.counts[site]
+}
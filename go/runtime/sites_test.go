@@ -0,0 +1,103 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSitePositions(t *testing.T) {
+	ctx := NewEncodingContext()
+	const questions, answers PositionSite = 0, 3
+
+	_, ok := ctx.FirstSitePosition(answers)
+	require.False(t, ok)
+
+	ctx.TrackSitePosition(questions, 12)
+	ctx.TrackSitePosition(answers, 30)
+	ctx.TrackSitePosition(answers, 45)
+
+	// Contexts derived from one another record into the same table
+	ctx.ExtendWithParent(map[string]interface{}{}).At(45).TrackSitePosition(answers, 60)
+
+	position, ok := ctx.SitePosition(answers, 1)
+	require.True(t, ok)
+	require.Equal(t, 45, position)
+	_, ok = ctx.SitePosition(answers, 3)
+	require.False(t, ok)
+	_, ok = ctx.SitePosition(answers, -1)
+	require.False(t, ok)
+
+	first, ok := ctx.FirstSitePosition(answers)
+	require.True(t, ok)
+	require.Equal(t, 30, first)
+	last, ok := ctx.LastSitePosition(answers)
+	require.True(t, ok)
+	require.Equal(t, 60, last)
+	first, ok = ctx.FirstSitePosition(questions)
+	require.True(t, ok)
+	require.Equal(t, 12, first)
+
+	// Sites in between were never tracked
+	_, ok = ctx.LastSitePosition(1)
+	require.False(t, ok)
+	_, ok = ctx.FirstSitePosition(7)
+	require.False(t, ok)
+}
+
+func TestSiteTypeIndices(t *testing.T) {
+	ctx := NewEncodingContext()
+	require.Equal(t, 0, ctx.SiteTypeIndex(2))
+	require.Equal(t, 1, ctx.IncrementSiteTypeIndex(2))
+	require.Equal(t, 2, ctx.ExtendWithArrayIteration("items", nil, 0).IncrementSiteTypeIndex(2))
+	require.Equal(t, 2, ctx.SiteTypeIndex(2))
+	require.Equal(t, 0, ctx.SiteTypeIndex(0))
+	require.Equal(t, 0, ctx.SiteTypeIndex(-1))
+	require.Equal(t, 0, ctx.IncrementSiteTypeIndex(-1))
+
+	// A nil context tracks nothing
+	var none *EncodingContext
+	none.TrackSitePosition(0, 5)
+	_, ok := none.SitePosition(0, 0)
+	require.False(t, ok)
+	require.Equal(t, 0, none.IncrementSiteTypeIndex(0))
+}
+
+func TestForkIsolatesSites(t *testing.T) {
+	ctx := NewEncodingContext()
+	ctx.TrackSitePosition(0, 10)
+	ctx.IncrementSiteTypeIndex(0)
+
+	// A fork starts with what was tracked so far...
+	forked := ctx.Fork()
+	last, ok := forked.LastSitePosition(0)
+	require.True(t, ok)
+	require.Equal(t, 10, last)
+	require.Equal(t, 1, forked.SiteTypeIndex(0))
+
+	// ...and from then on neither sees what the other tracks
+	forked.TrackSitePosition(0, 20)
+	forked.IncrementSiteTypeIndex(0)
+	forked.TrackSitePosition(4, 40)
+	ctx.TrackSitePosition(0, 30)
+
+	last, _ = ctx.LastSitePosition(0)
+	require.Equal(t, 30, last)
+	require.Equal(t, 1, ctx.SiteTypeIndex(0))
+	_, ok = ctx.FirstSitePosition(4)
+	require.False(t, ok)
+
+	last, _ = forked.LastSitePosition(0)
+	require.Equal(t, 20, last)
+	_, ok = forked.SitePosition(0, 2)
+	require.False(t, ok)
+	require.Equal(t, 2, forked.SiteTypeIndex(0))
+
+	// Forks of a context without a table get their own
+	var none *EncodingContext
+	fresh := none.Fork()
+	fresh.TrackSitePosition(1, 8)
+	first, ok := fresh.FirstSitePosition(1)
+	require.True(t, ok)
+	require.Equal(t, 8, first)
+}
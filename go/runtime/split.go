@@ -0,0 +1,61 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FitItems encodes the message holding the longest run of items, from the first,
+// that fits a byte budget, and returns it with the items left for the next message.
+// encode encodes the message holding the items it is given and fails with
+// ErrOutputTooLarge when it passes the budget, as EncodeWithLimit does. Messages must
+// not shrink as items are added, so the run is found by a binary search: about
+// log2(len(items)) encodes, each stopped once it passes the budget. If even one item
+// doesn't fit, FitItems fails with ErrOutputTooLarge.
+func FitItems[T any](items []T, encode func(items []T) ([]byte, error)) ([]byte, []T, error) {
+	data, err := encode(items)
+	if err == nil {
+		return data, nil, nil
+	}
+	if !errors.Is(err, ErrOutputTooLarge) || len(items) == 0 {
+		return nil, nil, err
+	}
+
+	// items[:n] fits; runs longer than hi are known not to
+	var fitted []byte
+	n, lo, hi := 0, 1, len(items)-1
+	for lo <= hi {
+		mid := lo + (hi-lo)/2
+		data, err := encode(items[:mid])
+		switch {
+		case err == nil:
+			fitted, n = data, mid
+			lo = mid + 1
+		case errors.Is(err, ErrOutputTooLarge):
+			hi = mid - 1
+		default:
+			return nil, nil, err
+		}
+	}
+	if n == 0 {
+		return nil, nil, fmt.Errorf("%w: the first item doesn't fit in a message on its own", ErrOutputTooLarge)
+	}
+	return fitted, items[n:], nil
+}
+
+// SplitItems encodes items into as many messages as it takes, each holding as many of
+// the items left as FitItems fits in it. No items make one message without any.
+func SplitItems[T any](items []T, encode func(items []T) ([]byte, error)) ([][]byte, error) {
+	var messages [][]byte
+	for {
+		data, rest, err := FitItems(items, encode)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", len(messages)+1, err)
+		}
+		messages = append(messages, data)
+		if len(rest) == 0 {
+			return messages, nil
+		}
+		items = rest
+	}
+}
@@ -0,0 +1,32 @@
+package runtime
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// ScanBytes returns a copy of the bytes a database/sql driver passes to a generated
+// Scan method: a BLOB as []byte, or a text column as string. Drivers may reuse their
+// []byte after Scan returns, and zero-copy decoding would keep views of it, hence the
+// copy. NULL is an error, as for the other non-nullable types database/sql scans into.
+func ScanBytes(src interface{}, typeName string) ([]byte, error) {
+	switch src := src.(type) {
+	case []byte:
+		return append([]byte(nil), src...), nil
+	case string:
+		return []byte(src), nil
+	case nil:
+		return nil, fmt.Errorf("cannot scan NULL into %s", typeName)
+	}
+	return nil, fmt.Errorf("cannot scan %T into %s", src, typeName)
+}
+
+// AppendBase64 appends the standard base64 encoding of data to dst, for generated MarshalText
+func AppendBase64(dst, data []byte) []byte {
+	return base64.StdEncoding.AppendEncode(dst, data)
+}
+
+// DecodeBase64 decodes the standard base64 text of a generated UnmarshalText
+func DecodeBase64(text []byte) ([]byte, error) {
+	return base64.StdEncoding.AppendDecode(nil, text)
+}
@@ -0,0 +1,71 @@
+package runtime
+
+import "errors"
+
+// ErrNeedMoreData is returned by StatefulDecoder.Next when the buffered bytes end
+// inside a message: Feed more and call Next again
+var ErrNeedMoreData = errors.New("need more data")
+
+// StatefulDecoder decodes successive messages from bytes that arrive in chunks, as
+// they are read from a socket. Bytes fed to it are buffered until a whole message has
+// arrived; a decode that ran out of data (INCOMPLETE_DATA) keeps everything buffered
+// and is only retried once more bytes are fed. Values decoded from the buffer stay
+// valid after later feeds, so zero-copy types need no Clone.
+//
+// Types that read until the end of their input, such as eof_terminated arrays, can't
+// tell a partial message from a whole one and shouldn't be decoded this way.
+type StatefulDecoder[T any] struct {
+	decode   func(*BitStreamDecoder) (T, error)
+	bitOrder BitOrder
+	buf      []byte // Unconsumed bytes are buf[start:]
+	start    int
+	stalled  bool // The last decode ran out of data and nothing was fed since
+}
+
+// NewStatefulDecoder returns a decoder calling decode for each message; generated
+// DecodeXStream functions wrap it for their types
+func NewStatefulDecoder[T any](bitOrder BitOrder, decode func(*BitStreamDecoder) (T, error)) *StatefulDecoder[T] {
+	return &StatefulDecoder[T]{decode: decode, bitOrder: bitOrder}
+}
+
+// Feed appends the next chunk of input. The decoder copies p, so it may be reused.
+func (d *StatefulDecoder[T]) Feed(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	if d.start > 0 && len(p) > cap(d.buf)-len(d.buf) {
+		// Leave consumed bytes behind in a new buffer rather than moving what's left
+		// over them: decoded values may still point into them
+		rest := make([]byte, len(d.buf)-d.start, max(2*(len(d.buf)-d.start+len(p)), 64))
+		copy(rest, d.buf[d.start:])
+		d.buf, d.start = rest, 0
+	}
+	d.buf = append(d.buf, p...)
+	d.stalled = false
+}
+
+// Next decodes the next message from the buffered bytes. It returns ErrNeedMoreData,
+// keeping them, if they end inside the message; other errors mean the input is
+// invalid, and the decoder should be discarded with it.
+func (d *StatefulDecoder[T]) Next() (T, error) {
+	var zero T
+	if d.stalled || d.start == len(d.buf) {
+		return zero, ErrNeedMoreData
+	}
+	decoder := NewBitStreamDecoder(d.buf[d.start:len(d.buf):len(d.buf)], d.bitOrder)
+	value, err := d.decode(decoder)
+	if err != nil {
+		if decoder.LastErrorCode != nil && *decoder.LastErrorCode == ErrorIncompleteData {
+			d.stalled = true
+			return zero, ErrNeedMoreData
+		}
+		return zero, err
+	}
+	d.start += decoder.Position()
+	return value, nil
+}
+
+// Buffered returns the number of bytes fed but not yet decoded
+func (d *StatefulDecoder[T]) Buffered() int {
+	return len(d.buf) - d.start
+}
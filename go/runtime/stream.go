@@ -0,0 +1,201 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// LengthPrefix is how a MessageStream writes the length before each message
+type LengthPrefix int
+
+const (
+	PrefixUint8  LengthPrefix = iota // 1 byte, messages up to 255 bytes
+	PrefixUint16                     // 2 bytes in the stream's byte order
+	PrefixUint32                     // 4 bytes in the stream's byte order
+	PrefixUint64                     // 8 bytes in the stream's byte order
+	PrefixVarint                     // Unsigned LEB128, as protobuf's length-delimited streams
+)
+
+// maxLength returns the longest message a prefix can describe
+func (p LengthPrefix) maxLength() uint64 {
+	switch p {
+	case PrefixUint8:
+		return math.MaxUint8
+	case PrefixUint16:
+		return math.MaxUint16
+	case PrefixUint32:
+		return math.MaxUint32
+	}
+	return math.MaxUint64
+}
+
+// EncodableMessage is a value a MessageStream can write: every generated type implements it
+type EncodableMessage interface {
+	Encode() ([]byte, error)
+}
+
+// ErrFrameTooLarge is returned when a message is longer than a stream's prefix can
+// describe, or an incoming one than its MaxFrameSize
+var ErrFrameTooLarge = errors.New("message frame too large")
+
+// Read buffer size when a stream first reads, and the least it asks its reader for
+const streamReadSize = 4096
+
+// MessageStream frames generated messages over a byte stream such as a TCP connection:
+// each message is written after its length, and read back whole however the bytes
+// arrive. A stream is not safe for concurrent use, though one goroutine may read while
+// another writes.
+type MessageStream struct {
+	// MaxFrameSize bounds the length of incoming messages, so a corrupt or hostile
+	// prefix can't make the stream buffer gigabytes; 0 means no limit
+	MaxFrameSize int
+
+	rw         io.ReadWriter
+	prefix     LengthPrefix
+	endianness Endianness
+	buf        []byte // Bytes read but not returned yet are buf[start:]
+	start      int
+}
+
+// NewMessageStream returns a stream framing messages over rw with the given length
+// prefix, in the given byte order (ignored for PrefixUint8 and PrefixVarint)
+func NewMessageStream(rw io.ReadWriter, prefix LengthPrefix, endianness Endianness) *MessageStream {
+	return &MessageStream{rw: rw, prefix: prefix, endianness: endianness}
+}
+
+// WriteMessage encodes msg and writes it as one frame
+func (s *MessageStream) WriteMessage(msg EncodableMessage) error {
+	payload, err := msg.Encode()
+	if err != nil {
+		return err
+	}
+	return s.WriteFrame(payload)
+}
+
+// WriteFrame writes payload after its length, in one Write call
+func (s *MessageStream) WriteFrame(payload []byte) error {
+	if uint64(len(payload)) > s.prefix.maxLength() {
+		return fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, len(payload))
+	}
+	encoder := NewBitStreamEncoder(MSBFirst)
+	switch s.prefix {
+	case PrefixUint8:
+		encoder.WriteUint8(uint8(len(payload)))
+	case PrefixUint16:
+		encoder.WriteUint16(uint16(len(payload)), s.endianness)
+	case PrefixUint32:
+		encoder.WriteUint32(uint32(len(payload)), s.endianness)
+	case PrefixUint64:
+		encoder.WriteUint64(uint64(len(payload)), s.endianness)
+	case PrefixVarint:
+		encoder.WriteVarlengthLEB128(uint64(len(payload)))
+	default:
+		return fmt.Errorf("unknown length prefix %d", s.prefix)
+	}
+	encoder.WriteBytes(payload)
+	_, err := s.rw.Write(encoder.Finish())
+	return err
+}
+
+// ReadFrame returns the next message's bytes, reading until all of it has arrived. The
+// slice is only valid until the next read from the stream. At the end of the stream
+// ReadFrame returns io.EOF, or io.ErrUnexpectedEOF if it ended inside a frame.
+func (s *MessageStream) ReadFrame() ([]byte, error) {
+	for {
+		frame, n, err := s.parseFrame()
+		if err != nil {
+			return nil, err
+		}
+		if frame != nil {
+			s.start += n
+			return frame, nil
+		}
+		if err := s.fill(n); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ReadMessage reads the next frame and decodes it with decode (a generated DecodeX
+// function). Zero-copy types must be cloned to outlive the next read.
+func ReadMessage[T any](s *MessageStream, decode func([]byte) (T, error)) (T, error) {
+	frame, err := s.ReadFrame()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return decode(frame)
+}
+
+// parseFrame returns the buffered frame and its length with the prefix, or a nil frame
+// and the number of bytes needed for it, at least as far as can be told yet
+func (s *MessageStream) parseFrame() ([]byte, int, error) {
+	data := s.buf[s.start:]
+	decoder := NewBitStreamDecoder(data, MSBFirst)
+	var length uint64
+	var err error
+	switch s.prefix {
+	case PrefixUint8:
+		var n uint8
+		n, err = decoder.ReadUint8()
+		length = uint64(n)
+	case PrefixUint16:
+		var n uint16
+		n, err = decoder.ReadUint16(s.endianness)
+		length = uint64(n)
+	case PrefixUint32:
+		var n uint32
+		n, err = decoder.ReadUint32(s.endianness)
+		length = uint64(n)
+	case PrefixUint64:
+		length, err = decoder.ReadUint64(s.endianness)
+	case PrefixVarint:
+		length, err = decoder.ReadVarlengthLEB128()
+	default:
+		return nil, 0, fmt.Errorf("unknown length prefix %d", s.prefix)
+	}
+	if err != nil {
+		if decoder.LastErrorCode != nil && *decoder.LastErrorCode == ErrorIncompleteData {
+			return nil, len(data) + 1, nil
+		}
+		return nil, 0, err
+	}
+	if s.MaxFrameSize > 0 && length > uint64(s.MaxFrameSize) || length > math.MaxInt32 {
+		return nil, 0, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, length)
+	}
+	size := decoder.Position() + int(length)
+	if len(data) < size {
+		return nil, size, nil
+	}
+	return data[decoder.Position():size:size], size, nil
+}
+
+// fill reads more of the stream into the buffer, making room for at least need
+// unreturned bytes
+func (s *MessageStream) fill(need int) error {
+	buffered := len(s.buf) - s.start
+	if s.start > 0 && (buffered == 0 || s.start+need > cap(s.buf)) {
+		// Move what's left of the buffer to its start
+		copy(s.buf, s.buf[s.start:])
+		s.buf = s.buf[:buffered]
+		s.start = 0
+	}
+	if need > cap(s.buf)-s.start || len(s.buf) == cap(s.buf) {
+		grown := make([]byte, buffered, max(need, 2*cap(s.buf), streamReadSize))
+		copy(grown, s.buf[s.start:])
+		s.buf, s.start = grown, 0
+	}
+
+	n, err := s.rw.Read(s.buf[len(s.buf):cap(s.buf)])
+	s.buf = s.buf[:len(s.buf)+n]
+	if n > 0 {
+		// Errors come back from the next read, once the data is handled
+		return nil
+	}
+	if err == io.EOF && len(s.buf) > s.start {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
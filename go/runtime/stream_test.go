@@ -0,0 +1,127 @@
+package runtime
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readWriter joins a reader and a writer into a stream's io.ReadWriter
+type readWriter struct {
+	io.Reader
+	io.Writer
+}
+
+// greeting is an EncodableMessage encoding as its text
+type greeting string
+
+func (g greeting) Encode() ([]byte, error) {
+	return []byte(g), nil
+}
+
+func TestMessageStreamPrefixes(t *testing.T) {
+	payload := []byte("hello")
+	cases := []struct {
+		name       string
+		prefix     LengthPrefix
+		endianness Endianness
+		header     []byte
+	}{
+		{"uint8", PrefixUint8, BigEndian, []byte{5}},
+		{"uint16 big endian", PrefixUint16, BigEndian, []byte{0, 5}},
+		{"uint16 little endian", PrefixUint16, LittleEndian, []byte{5, 0}},
+		{"uint32 big endian", PrefixUint32, BigEndian, []byte{0, 0, 0, 5}},
+		{"uint32 little endian", PrefixUint32, LittleEndian, []byte{5, 0, 0, 0}},
+		{"uint64 big endian", PrefixUint64, BigEndian, []byte{0, 0, 0, 0, 0, 0, 0, 5}},
+		{"varint", PrefixVarint, LittleEndian, []byte{5}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var wire bytes.Buffer
+			stream := NewMessageStream(&wire, tc.prefix, tc.endianness)
+			require.NoError(t, stream.WriteFrame(payload))
+			require.NoError(t, stream.WriteFrame(nil))
+			// The empty frame's prefix is all zeros
+			require.Equal(t, bytes.Join([][]byte{tc.header, payload, make([]byte, len(tc.header))}, nil), wire.Bytes())
+
+			frame, err := stream.ReadFrame()
+			require.NoError(t, err)
+			require.Equal(t, payload, frame)
+			frame, err = stream.ReadFrame()
+			require.NoError(t, err)
+			require.Empty(t, frame)
+			_, err = stream.ReadFrame()
+			require.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+func TestMessageStreamPartialReads(t *testing.T) {
+	// Frames longer than the first read, with a varint prefix split across reads too
+	long := bytes.Repeat([]byte{0xAB}, streamReadSize+300)
+	var wire bytes.Buffer
+	writer := NewMessageStream(&wire, PrefixVarint, LittleEndian)
+	require.NoError(t, writer.WriteMessage(greeting("first")))
+	require.NoError(t, writer.WriteFrame(long))
+	require.NoError(t, writer.WriteMessage(greeting("last")))
+
+	for name, reader := range map[string]io.Reader{
+		"one byte per read": iotest.OneByteReader(bytes.NewReader(wire.Bytes())),
+		"half per read":     iotest.HalfReader(bytes.NewReader(wire.Bytes())),
+		"error with data":   iotest.DataErrReader(bytes.NewReader(wire.Bytes())),
+	} {
+		t.Run(name, func(t *testing.T) {
+			stream := NewMessageStream(readWriter{Reader: reader}, PrefixVarint, LittleEndian)
+			decode := func(b []byte) (string, error) { return string(b), nil }
+
+			message, err := ReadMessage(stream, decode)
+			require.NoError(t, err)
+			require.Equal(t, "first", message)
+			frame, err := stream.ReadFrame()
+			require.NoError(t, err)
+			require.Equal(t, long, frame)
+			message, err = ReadMessage(stream, decode)
+			require.NoError(t, err)
+			require.Equal(t, "last", message)
+			_, err = stream.ReadFrame()
+			require.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+func TestMessageStreamTruncated(t *testing.T) {
+	// Ending inside the prefix or the payload is unexpected, unlike ending between frames
+	for name, wire := range map[string][]byte{
+		"in prefix":  {0},
+		"in payload": {0, 5, 'h', 'e'},
+	} {
+		t.Run(name, func(t *testing.T) {
+			stream := NewMessageStream(readWriter{Reader: bytes.NewReader(wire)}, PrefixUint16, BigEndian)
+			_, err := stream.ReadFrame()
+			require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		})
+	}
+
+	failure := errors.New("connection reset")
+	stream := NewMessageStream(readWriter{Reader: iotest.ErrReader(failure)}, PrefixUint8, BigEndian)
+	_, err := stream.ReadFrame()
+	require.ErrorIs(t, err, failure)
+}
+
+func TestMessageStreamFrameTooLarge(t *testing.T) {
+	var wire bytes.Buffer
+	stream := NewMessageStream(&wire, PrefixUint8, BigEndian)
+	require.ErrorIs(t, stream.WriteFrame(make([]byte, 256)), ErrFrameTooLarge)
+	require.Zero(t, wire.Len())
+	require.NoError(t, stream.WriteFrame(make([]byte, 255)))
+
+	// An incoming length over MaxFrameSize fails before its payload is buffered
+	stream = NewMessageStream(readWriter{Reader: bytes.NewReader([]byte{0xFF, 0xFF, 0xFF, 0x7F})}, PrefixUint32, LittleEndian)
+	stream.MaxFrameSize = 1 << 20
+	_, err := stream.ReadFrame()
+	require.ErrorIs(t, err, ErrFrameTooLarge)
+}
@@ -0,0 +1,143 @@
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// InvalidValueError is returned by encodes of a value its field's encoding can't
+// represent (code INVALID_VALUE)
+type InvalidValueError struct {
+	Field  string // The field, as the schema names it
+	Index  int    // Byte offset of the offending character in the value
+	Reason string
+}
+
+func (e *InvalidValueError) Error() string {
+	return fmt.Sprintf("%s: %s at index %d", e.Field, e.Reason, e.Index)
+}
+
+// Code returns the error code, INVALID_VALUE
+func (e *InvalidValueError) Code() string {
+	return ErrorInvalidValue
+}
+
+// ASCIIBytes returns the bytes an ascii string field writes for s. A character that
+// isn't ASCII fails with an InvalidValueError at its byte offset, or with replace, is
+// written as '?': one per character, however many bytes its UTF-8 takes.
+func ASCIIBytes[S ~string | ~[]byte](field string, s S, replace bool) ([]byte, error) {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); {
+		if s[i] < utf8.RuneSelf {
+			out = append(out, s[i])
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(string(s[i:]))
+		if !replace {
+			reason := fmt.Sprintf("character %q is not ASCII", r)
+			if r == utf8.RuneError && size == 1 {
+				reason = fmt.Sprintf("byte %#02x is not ASCII", s[i])
+			}
+			return nil, &InvalidValueError{Field: field, Index: i, Reason: reason}
+		}
+		out = append(out, '?')
+		i += size
+	}
+	return out, nil
+}
+
+// UTF8Policy selects what decoding a utf8 string does with bytes that aren't valid UTF-8
+type UTF8Policy int
+
+const (
+	// UTF8Default is the schema's policy, the invalid_utf8 of its config, or UTF8Keep
+	// if it has none
+	UTF8Default UTF8Policy = iota
+	// UTF8Keep keeps the bytes as they are, in a string that isn't valid UTF-8
+	UTF8Keep
+	// UTF8Reject fails the decode (code SCHEMA_MISMATCH)
+	UTF8Reject
+	// UTF8Replace writes U+FFFD for each run of invalid bytes, as strings.ToValidUTF8 does
+	UTF8Replace
+)
+
+// DecodeUTF8 returns the string a utf8 field decodes from b under policy. Rejected
+// strings fail with the byte offset of their first invalid byte.
+func DecodeUTF8(field string, b []byte, policy UTF8Policy) (string, error) {
+	if policy <= UTF8Keep || utf8.Valid(b) {
+		return string(b), nil
+	}
+	if policy == UTF8Replace {
+		return string(bytes.ToValidUTF8(b, []byte(string(utf8.RuneError)))), nil
+	}
+	return "", invalidUTF8(field, b)
+}
+
+// InvalidUTF8Error is returned by decodes of a utf8 string that isn't valid UTF-8 under
+// UTF8Reject (code SCHEMA_MISMATCH)
+type InvalidUTF8Error struct {
+	Field string // The field, as the schema names it; empty when the caller names it
+	Index int    // Byte offset of the first invalid byte in the string
+}
+
+func (e *InvalidUTF8Error) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("invalid UTF-8 at index %d", e.Index)
+	}
+	return fmt.Sprintf("%s: invalid UTF-8 at index %d", e.Field, e.Index)
+}
+
+// Code returns the error code, SCHEMA_MISMATCH
+func (e *InvalidUTF8Error) Code() string {
+	return ErrorSchemaMismatch
+}
+
+// invalidUTF8 is the error of a string rejected for its first invalid byte
+func invalidUTF8(field string, b []byte) error {
+	i := 0
+	for i < len(b) {
+		r, size := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError && size == 1 {
+			break
+		}
+		i += size
+	}
+	return &InvalidUTF8Error{Field: field, Index: i}
+}
+
+// UTF8Policy is the policy the decoder decodes strings with: its own if set, else the
+// schema's
+func (d *BitStreamDecoder) UTF8Policy(schema UTF8Policy) UTF8Policy {
+	if d.UTF8 != UTF8Default {
+		return d.UTF8
+	}
+	return schema
+}
+
+// UTF8String returns the string a utf8 field decodes from b, under the decoder's UTF8
+// policy if set, else the schema's
+func (d *BitStreamDecoder) UTF8String(field string, b []byte, schema UTF8Policy) (string, error) {
+	s, err := DecodeUTF8(field, b, d.UTF8Policy(schema))
+	if err != nil {
+		errCode := ErrorSchemaMismatch
+		d.LastErrorCode = &errCode
+	}
+	return s, err
+}
+
+// UTF8View is UTF8String for a ZeroCopy string: b itself if it is kept, a copy of it if
+// invalid bytes are replaced
+func (d *BitStreamDecoder) UTF8View(field string, b []byte, schema UTF8Policy) (ByteString, error) {
+	policy := d.UTF8Policy(schema)
+	if policy <= UTF8Keep || utf8.Valid(b) {
+		return ByteString(b), nil
+	}
+	if policy == UTF8Replace {
+		return ByteString(bytes.ToValidUTF8(b, []byte(string(utf8.RuneError)))), nil
+	}
+	errCode := ErrorSchemaMismatch
+	d.LastErrorCode = &errCode
+	return nil, invalidUTF8(field, b)
+}
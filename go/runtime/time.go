@@ -0,0 +1,58 @@
+package runtime
+
+import "time"
+
+// UnixEpoch is the default epoch of unix32, unix64 and unix_milli timestamps
+var UnixEpoch = time.Unix(0, 0).UTC()
+
+// ntpEpochOffset is the number of seconds from the NTP epoch (1900) to the Unix epoch
+const ntpEpochOffset = 2208988800
+
+// UnixTime converts a count of units since epoch to a UTC time
+func UnixTime(n int64, unit time.Duration, epoch time.Time) time.Time {
+	perSecond := int64(time.Second / unit)
+	seconds, rest := n/perSecond, n%perSecond
+	return time.Unix(epoch.Unix()+seconds, int64(epoch.Nanosecond())+rest*int64(unit)).UTC()
+}
+
+// UnixUnits converts a time to whole units since epoch, rounding down. The zero
+// time.Time gives 0, so an unset timestamp encodes as the epoch.
+func UnixUnits(t time.Time, unit time.Duration, epoch time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	perSecond := int64(time.Second / unit)
+	seconds := t.Unix() - epoch.Unix()
+	nanos := int64(t.Nanosecond()) - int64(epoch.Nanosecond())
+	if nanos < 0 {
+		seconds--
+		nanos += int64(time.Second)
+	}
+	return seconds*perSecond + nanos/int64(unit)
+}
+
+// NTPTime converts a 64-bit NTP timestamp (seconds since 1900 and a 32-bit fraction)
+// to a UTC time. Era 0 only: NTP timestamps wrap in 2036. 0 means the time is unknown
+// and gives the zero time.Time.
+func NTPTime(v uint64) time.Time {
+	if v == 0 {
+		return time.Time{}
+	}
+	seconds := int64(v>>32) - ntpEpochOffset
+	nanos := (int64(v&0xffffffff)*int64(time.Second) + 1<<31) >> 32 // Nearest, so converting back gives the same fraction
+	return time.Unix(seconds, nanos).UTC()
+}
+
+// NTPTimestamp converts a time to a 64-bit NTP timestamp, with ok false if it falls
+// outside era 0 (1900 to 2036). The zero time.Time gives 0.
+func NTPTimestamp(t time.Time) (v uint64, ok bool) {
+	if t.IsZero() {
+		return 0, true
+	}
+	seconds := t.Unix() + ntpEpochOffset
+	if seconds < 0 || seconds > 0xffffffff {
+		return 0, false
+	}
+	fraction := (uint64(t.Nanosecond()) << 32) / uint64(time.Second)
+	return uint64(seconds)<<32 | fraction, true
+}
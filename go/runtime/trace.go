@@ -0,0 +1,96 @@
+package runtime
+
+import "fmt"
+
+// TraceSink receives one event per traced read while decoding.
+// Set BitStreamDecoder.Trace to collect them: generated decoders then take a copy
+// that traces each read, in any build.
+type TraceSink interface {
+	Record(event TraceEvent)
+}
+
+// TraceEvent describes where one field, array item or length prefix was read.
+// Events are recorded when the read completes, so children arrive before their
+// parent; Order gives the position in which reads started.
+type TraceEvent struct {
+	Path      string      // Field path from the root type, e.g. "readings[0].value"
+	Name      string      // Last path element: a field name or "[i]"
+	Depth     int         // Nesting level below the root type
+	Order     int         // Index of this read in the order reads started
+	ByteStart int         // Byte offset of the first bit read
+	BitStart  int         // Bit offset (0-7) within ByteStart
+	ByteEnd   int         // Byte offset after the last bit read
+	BitEnd    int         // Bits (0-7) read from ByteEnd
+	Value     interface{} // Decoded value as stored in the result
+	Container bool        // Has child events
+	Complete  bool        // False if decoding failed inside this read
+}
+
+// TraceEnter starts a traced read of a named field at the current position
+func (d *BitStreamDecoder) TraceEnter(name string) {
+	if d.Trace == nil {
+		return
+	}
+	path := name
+	if n := len(d.traceStack); n > 0 {
+		path = d.traceStack[n-1].Path + "." + name
+	}
+	d.traceStart(path, name)
+}
+
+// TraceEnterItem starts a traced read of an item of the innermost open array
+func (d *BitStreamDecoder) TraceEnterItem(index int) {
+	if d.Trace == nil {
+		return
+	}
+	name := fmt.Sprintf("[%d]", index)
+	path := name
+	if n := len(d.traceStack); n > 0 {
+		path = d.traceStack[n-1].Path + name
+	}
+	d.traceStart(path, name)
+}
+
+func (d *BitStreamDecoder) traceStart(path, name string) {
+	if n := len(d.traceStack); n > 0 {
+		d.traceStack[n-1].Container = true
+	}
+	d.traceStack = append(d.traceStack, TraceEvent{
+		Path:      path,
+		Name:      name,
+		Depth:     len(d.traceStack),
+		Order:     d.traceOrder,
+		ByteStart: d.byteOffset,
+		BitStart:  d.bitOffset,
+	})
+	d.traceOrder++
+}
+
+// TraceLeave completes the innermost traced read and records it with its value
+func (d *BitStreamDecoder) TraceLeave(value interface{}) {
+	n := len(d.traceStack)
+	if d.Trace == nil || n == 0 {
+		return
+	}
+	event := d.traceStack[n-1]
+	d.traceStack = d.traceStack[:n-1]
+	event.ByteEnd = d.byteOffset
+	event.BitEnd = d.bitOffset
+	event.Value = value
+	event.Complete = true
+	d.Trace.Record(event)
+}
+
+// TraceAbort records every read still open as incomplete, innermost first.
+// Call it after a traced decode fails.
+func (d *BitStreamDecoder) TraceAbort() {
+	for n := len(d.traceStack); n > 0; n-- {
+		event := d.traceStack[n-1]
+		event.ByteEnd = d.byteOffset
+		event.BitEnd = d.bitOffset
+		if d.Trace != nil {
+			d.Trace.Record(event)
+		}
+	}
+	d.traceStack = d.traceStack[:0]
+}
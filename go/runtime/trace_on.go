@@ -0,0 +1,8 @@
+//go:build trace
+
+package runtime
+
+// TraceEnabled reports whether the regular generated decoders emit trace calls too.
+// Build with -tags trace to turn them on; otherwise the calls compile away there,
+// and only decoders with a Trace attached, which take the traced copy, make them.
+const TraceEnabled = true
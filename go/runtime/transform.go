@@ -0,0 +1,220 @@
+package runtime
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// MaxReversedSize bounds the bytes a decompressing transform produces from one field,
+// so a small hostile input can't expand into gigabytes while decoding
+const MaxReversedSize = 64 << 20
+
+// ErrUnknownTransform is returned for a transform nothing is registered under
+var ErrUnknownTransform = errors.New("unknown transform")
+
+// TransformParams is what a field's schema says about its transform, besides its name
+type TransformParams struct {
+	// Field is the name of the field transformed, for errors and key lookups
+	Field string
+	// Key is the key given in the schema, for xor
+	Key []byte
+	// KeyID names the key a keyed transform like aes-gcm asks its hook for
+	KeyID string
+}
+
+// Transform turns a field's encoded bytes into the bytes on the wire and back:
+// compression or encryption of a section of a container format. Generated encoders
+// call Apply on a field's bytes after encoding them, and decoders call Reverse on
+// them before decoding. The data Reverse is given can be a view of the decoder's
+// input, so it must return new bytes rather than data itself.
+type Transform interface {
+	Apply(data []byte, params TransformParams) ([]byte, error)
+	Reverse(data []byte, params TransformParams) ([]byte, error)
+}
+
+var (
+	transformsMu sync.RWMutex
+	transforms   = map[string]Transform{
+		"gzip": gzipTransform{},
+		"zlib": zlibTransform{},
+		"xor":  xorTransform{},
+	}
+)
+
+// RegisterTransform makes t the transform fields name with "transform": name,
+// replacing any registered before. gzip, zlib and xor are built in; zstd and aes-gcm
+// need registering, zstd with a Transform wrapping the caller's zstd library and
+// aes-gcm with AESGCM and the caller's key hook:
+//
+//	runtime.RegisterTransform("aes-gcm", runtime.AESGCM(keys.Lookup))
+func RegisterTransform(name string, t Transform) {
+	transformsMu.Lock()
+	defer transformsMu.Unlock()
+	transforms[name] = t
+}
+
+// lookupTransform returns the transform registered under name
+func lookupTransform(name string) (Transform, error) {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	t, ok := transforms[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q: register it with runtime.RegisterTransform", ErrUnknownTransform, name)
+	}
+	return t, nil
+}
+
+// ApplyTransform applies the transform registered under name to a field's bytes
+func ApplyTransform(name string, params TransformParams, data []byte) ([]byte, error) {
+	t, err := lookupTransform(name)
+	if err != nil {
+		return nil, err
+	}
+	return t.Apply(data, params)
+}
+
+// ReverseTransform undoes the transform registered under name on a field's bytes
+func ReverseTransform(name string, params TransformParams, data []byte) ([]byte, error) {
+	t, err := lookupTransform(name)
+	if err != nil {
+		return nil, err
+	}
+	return t.Reverse(data, params)
+}
+
+// readLimited reads r to the end, failing past MaxReversedSize
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxReversedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxReversedSize {
+		return nil, fmt.Errorf("decompresses to more than %d bytes", MaxReversedSize)
+	}
+	return data, nil
+}
+
+// gzipTransform compresses with gzip (RFC 1952)
+type gzipTransform struct{}
+
+func (gzipTransform) Apply(data []byte, params TransformParams) ([]byte, error) {
+	var out bytes.Buffer
+	w := gzip.NewWriter(&out)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (gzipTransform) Reverse(data []byte, params TransformParams) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readLimited(r)
+}
+
+// zlibTransform compresses with zlib (RFC 1950)
+type zlibTransform struct{}
+
+func (zlibTransform) Apply(data []byte, params TransformParams) ([]byte, error) {
+	var out bytes.Buffer
+	w := zlib.NewWriter(&out)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (zlibTransform) Reverse(data []byte, params TransformParams) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readLimited(r)
+}
+
+// xorTransform XORs the bytes with the schema's key, repeated: the obfuscation some
+// game and firmware formats use. It is its own reverse.
+type xorTransform struct{}
+
+func (xorTransform) Apply(data []byte, params TransformParams) ([]byte, error) {
+	if len(params.Key) == 0 {
+		return nil, fmt.Errorf("xor needs a key")
+	}
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[i] = b ^ params.Key[i%len(params.Key)]
+	}
+	return out, nil
+}
+
+func (t xorTransform) Reverse(data []byte, params TransformParams) ([]byte, error) {
+	return t.Apply(data, params)
+}
+
+// aesGCMTransform encrypts with AES-GCM under keys from a hook
+type aesGCMTransform struct {
+	key func(keyID string) ([]byte, error)
+}
+
+// AESGCM returns a Transform encrypting with AES-GCM under the 16, 24 or 32 byte key
+// key returns for the field's key_id. The wire bytes are a random nonce followed by
+// the ciphertext and its tag; Reverse fails on any bytes not sealed under the key.
+// Register it to use it: RegisterTransform("aes-gcm", AESGCM(lookup)).
+func AESGCM(key func(keyID string) ([]byte, error)) Transform {
+	return aesGCMTransform{key: key}
+}
+
+// aead returns the cipher for the field's key
+func (t aesGCMTransform) aead(params TransformParams) (cipher.AEAD, error) {
+	key, err := t.key(params.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", params.KeyID, err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (t aesGCMTransform) Apply(data []byte, params TransformParams) ([]byte, error) {
+	aead, err := t.aead(params)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func (t aesGCMTransform) Reverse(data []byte, params TransformParams) ([]byte, error) {
+	aead, err := t.aead(params)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("%d bytes are too short to hold a nonce", len(data))
+	}
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, nil)
+}
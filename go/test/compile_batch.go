@@ -1,5 +1,5 @@
 // ABOUTME: Batched compilation for Go test suites
-// ABOUTME: Compiles all test suites at once for fast execution
+// ABOUTME: Each suite is its own package in one shared module, built and run in parallel
 
 package test
 
@@ -10,11 +10,15 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
+	goruntime "runtime"
 	"sort"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/aeolun/json5"
+	"github.com/serialexp/binschema/codegen"
 )
 
 // TestResult represents the result of a single test case
@@ -26,7 +30,10 @@ type TestResult struct {
 	DecodedValue interface{} `json:"decoded_value,omitempty"`
 }
 
-// CompileAndTestBatch compiles all test suites together and runs them
+// CompileAndTestBatch generates each suite into its own main package of one temporary
+// module, then builds and runs the packages in parallel. Suites share the module and the
+// build cache, so the runtime is compiled once, but a suite whose generated code doesn't
+// compile fails on its own rather than taking the whole batch down with it.
 func CompileAndTestBatch(suites []*TestSuite) (map[string][]TestResult, error) {
 	// Create temporary directory
 	tmpDir, err := os.MkdirTemp("", "binschema-batch-*")
@@ -44,15 +51,8 @@ func CompileAndTestBatch(suites []*TestSuite) (map[string][]TestResult, error) {
 		defer os.RemoveAll(tmpDir)
 	}
 
-	// Track results for suites that fail code generation
-	results := make(map[string][]TestResult)
-
-	// Track which suites successfully generated code
-	var successfulSuites []*TestSuite
-	var typeNamePrefixes []string
-
-	// Generate code for all suites - write each to its own file in main package
-	for i, suite := range suites {
+	var runnable []*TestSuite
+	for _, suite := range suites {
 		// Skip suites with no test cases - these are schema validation error tests
 		// that intentionally test invalid schemas (e.g., duplicate discriminator values)
 		if len(suite.TestCases) == 0 {
@@ -66,291 +66,184 @@ func CompileAndTestBatch(suites []*TestSuite) (map[string][]TestResult, error) {
 			fmt.Fprintf(os.Stderr, "Skipping suite %q: test_type %q is a string type alias (no standalone encode/decode)\n", suite.Name, suite.TestType)
 			continue
 		}
-
-		code, err := generateGoSource(suite.Schema, suite.TestType)
-		if err != nil {
-			// Mark all test cases in this suite as failed due to code generation error
-			var failedResults []TestResult
-			for _, tc := range suite.TestCases {
-				failedResults = append(failedResults, TestResult{
-					Description: tc.Description,
-					Pass:        false,
-					Error:       fmt.Sprintf("code generation failed: %v", err),
-				})
-			}
-			results[suite.Name] = failedResults
-			continue
-		}
-
-		// Prefix type names to avoid conflicts
-		prefix := strings.ReplaceAll(suite.Name, "-", "_")
-
-		// Save original code for debugging if DEBUG_GENERATED is set
-		// Use .go.orig extension so it doesn't get compiled with go run .
-		if debugDir != "" {
-			origFilename := fmt.Sprintf("orig_%d.go.orig", i)
-			origFile := filepath.Join(tmpDir, origFilename)
-			os.WriteFile(origFile, []byte(code), 0644)
-		}
-
-		prefixedCode := prefixTypeNames(code, suite.TestType, prefix)
-
-		// Write to separate file (Go allows multiple files in same package/directory)
-		filename := fmt.Sprintf("gen_%d.go", i)
-		codeFile := filepath.Join(tmpDir, filename)
-		if err := os.WriteFile(codeFile, []byte(prefixedCode), 0644); err != nil {
-			return nil, fmt.Errorf("failed to write generated code: %w", err)
-		}
-
-		// Track successful generation
-		successfulSuites = append(successfulSuites, suite)
-		typeNamePrefixes = append(typeNamePrefixes, prefix)
+		runnable = append(runnable, suite)
 	}
 
-	// If no suites generated successfully, return the failure results
-	if len(successfulSuites) == 0 {
+	// Generate every suite first: the module is only set up if there is code to build
+	results := make(map[string][]TestResult)
+	var mu sync.Mutex
+	record := func(suite *TestSuite, suiteResults []TestResult) {
+		mu.Lock()
+		results[suite.Name] = suiteResults
+		mu.Unlock()
+	}
+	var generated []*TestSuite
+	inParallel(runnable, func(suite *TestSuite) {
+		if failed := writeSuitePackage(tmpDir, suite); failed != nil {
+			record(suite, failed)
+			return
+		}
+		mu.Lock()
+		generated = append(generated, suite)
+		mu.Unlock()
+	})
+	if len(generated) == 0 {
 		return results, nil
 	}
 
-	// Generate unified test harness (only for successfully generated suites)
-	testHarness := generateBatchedTestHarness(successfulSuites, typeNamePrefixes)
-	harnessFile := filepath.Join(tmpDir, "main.go")
-	if err := os.WriteFile(harnessFile, []byte(testHarness), 0644); err != nil {
-		return nil, fmt.Errorf("failed to write test harness: %w", err)
+	if err := initBatchModule(tmpDir); err != nil {
+		return nil, err
 	}
+	inParallel(generated, func(suite *TestSuite) {
+		record(suite, runSuitePackage(tmpDir, suite))
+	})
+
+	return results, nil
+}
+
+// inParallel calls run for every suite, on as many goroutines at once as there are CPUs
+func inParallel(suites []*TestSuite, run func(*TestSuite)) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, goruntime.NumCPU())
+	for _, suite := range suites {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			run(suite)
+		}()
+	}
+	wg.Wait()
+}
 
-	// Initialize go.mod
+// failSuite fails every case of a suite with the same error
+func failSuite(suite *TestSuite, format string, args ...interface{}) []TestResult {
+	var failed []TestResult
+	for _, tc := range suite.TestCases {
+		failed = append(failed, TestResult{
+			Description: tc.Description,
+			Pass:        false,
+			Error:       fmt.Sprintf(format, args...),
+		})
+	}
+	return failed
+}
+
+// suitePackage names the directory under the module holding a suite's package
+func suitePackage(suite *TestSuite) string {
+	return strings.ReplaceAll(suite.Name, "-", "_")
+}
+
+// initBatchModule makes dir a module depending on the binschema runtime in this
+// checkout, for the suite packages to be written into
+func initBatchModule(dir string) error {
 	cmd := exec.Command("go", "mod", "init", "testmodule")
-	cmd.Dir = tmpDir
+	cmd.Dir = dir
 	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("failed to init go module: %w", err)
+		return fmt.Errorf("failed to init go module: %w", err)
 	}
 
 	// Add dependency on binschema runtime
 	runtimePath, err := filepath.Abs(".")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get abs path: %w", err)
+		return fmt.Errorf("failed to get abs path: %w", err)
 	}
 	runtimePath = filepath.Dir(runtimePath) // go up to binschema root
 
-	goModPath := filepath.Join(tmpDir, "go.mod")
+	goModPath := filepath.Join(dir, "go.mod")
 	goModContent, err := os.ReadFile(goModPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read go.mod: %w", err)
+		return fmt.Errorf("failed to read go.mod: %w", err)
 	}
 
 	goModContent = append(goModContent, []byte(fmt.Sprintf("\nreplace github.com/serialexp/binschema => %s\n", runtimePath))...)
 	if err := os.WriteFile(goModPath, goModContent, 0644); err != nil {
-		return nil, fmt.Errorf("failed to update go.mod: %w", err)
+		return fmt.Errorf("failed to update go.mod: %w", err)
 	}
 
-	// Run go get to fetch dependencies
-	cmd = exec.Command("go", "get", "github.com/serialexp/binschema/runtime", "github.com/aeolun/json5")
-	cmd.Dir = tmpDir
+	// Run go get to fetch dependencies. Explicit versions, the replaced module's and
+	// the json5 this checkout uses, need no version lookup, so a module cache holding
+	// them is enough.
+	cmd = exec.Command("go", "list", "-m", "-f", "{{.Version}}", "github.com/aeolun/json5")
+	cmd.Dir = runtimePath
+	json5Version, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to find json5 version: %w", err)
+	}
+	cmd = exec.Command("go", "get", "github.com/serialexp/binschema@v0.0.0", "github.com/aeolun/json5@"+strings.TrimSpace(string(json5Version)))
+	cmd.Dir = dir
 	if output, err := cmd.CombinedOutput(); err != nil {
-		return nil, fmt.Errorf("failed to get dependencies: %w\nOutput: %s", err, output)
+		return fmt.Errorf("failed to get dependencies: %w\nOutput: %s", err, output)
 	}
+	return nil
+}
 
-	// Run the test harness (only one compilation!)
-	cmd = exec.Command("go", "run", ".")
-	cmd.Dir = tmpDir
-	output, err := cmd.CombinedOutput()
+// writeSuitePackage generates a suite's code and harness into suites/<name> under
+// moduleDir. It returns the suite's failed results if generation fails, nil otherwise.
+func writeSuitePackage(moduleDir string, suite *TestSuite) []TestResult {
+	code, err := generateGoSource(suite.Schema, suite.TestType)
 	if err != nil {
-		return nil, fmt.Errorf("failed to run test harness: %w\nOutput: %s", err, output)
+		return failSuite(suite, "code generation failed: %v", err)
 	}
 
-	// Parse results - array of arrays, one per suite
-	var allResults [][]TestResult
-	if err := json5.Unmarshal(output, &allResults); err != nil {
-		return nil, fmt.Errorf("failed to parse test results: %w\nOutput: %s", err, output)
+	// The suite's own package keeps its types apart from every other suite's
+	pkgDir := filepath.Join(moduleDir, "suites", suitePackage(suite))
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		return failSuite(suite, "failed to create suite package: %v", err)
 	}
 
-	// Map results from test harness back to suite names
-	for i, suite := range successfulSuites {
-		if i < len(allResults) {
-			results[suite.Name] = allResults[i]
-		}
+	files := map[string]string{
+		"generated.go": code,
+		"main.go":      generateSuiteHarness(suite),
 	}
-
-	return results, nil
-}
-
-// prefixTypeNames adds a prefix to ALL type names and functions to avoid conflicts
-func prefixTypeNames(code string, typeName string, prefix string) string {
-	// Use regexp to find and prefix ALL type definitions and decode functions
-	// This is more robust than string replacement as schemas may define multiple types
-
-	// Prefix all struct type definitions: "type Foo struct" -> "type prefix_Foo struct"
-	// Include underscores to handle bitfield struct names like DnsMessage_Flags
-	typeDefRegex := regexp.MustCompile(`\btype\s+([A-Z][a-zA-Z0-9_]*)\s+struct`)
-	code = typeDefRegex.ReplaceAllStringFunc(code, func(match string) string {
-		parts := typeDefRegex.FindStringSubmatch(match)
-		if len(parts) >= 2 {
-			typeName := parts[1]
-			return fmt.Sprintf("type %s_%s struct", prefix, typeName)
-		}
-		return match
-	})
-
-	// Prefix all interface type definitions: "type Foo interface" -> "type prefix_Foo interface"
-	interfaceDefRegex := regexp.MustCompile(`\btype\s+([A-Z][a-zA-Z0-9_]*)\s+interface`)
-	code = interfaceDefRegex.ReplaceAllStringFunc(code, func(match string) string {
-		parts := interfaceDefRegex.FindStringSubmatch(match)
-		if len(parts) >= 2 {
-			typeName := parts[1]
-			return fmt.Sprintf("type %s_%s interface", prefix, typeName)
-		}
-		return match
-	})
-
-	// Prefix enum type definitions: "type Foo uint8/uint16/uint32" -> "type prefix_Foo uint8"
-	enumTypeDefRegex := regexp.MustCompile(`\btype\s+([A-Z][a-zA-Z0-9_]*)\s+(uint8|uint16|uint32)\b`)
-	code = enumTypeDefRegex.ReplaceAllString(code, fmt.Sprintf("type %s_$1 $2", prefix))
-
-	// Prefix type alias definitions: "type Foo = string" -> "type prefix_Foo = string"
-	typeAliasRegex := regexp.MustCompile(`\btype\s+([A-Z][a-zA-Z0-9_]*)\s*=\s*(\w+)`)
-	code = typeAliasRegex.ReplaceAllString(code, fmt.Sprintf("type %s_$1 = $2", prefix))
-
-	// Prefix value receiver methods: "func (x Foo)" -> "func (x prefix_Foo)" (no pointer)
-	valueReceiverRegex := regexp.MustCompile(`\bfunc\s+\(([a-z]+)\s+([A-Z][a-zA-Z0-9_]*)\)`)
-	code = valueReceiverRegex.ReplaceAllString(code, fmt.Sprintf("func ($1 %s_$2)", prefix))
-
-	// Prefix const declarations with type annotations: "\tFooBar Foo = 0" -> "\tprefix_FooBar prefix_Foo = 0"
-	constDeclRegex := regexp.MustCompile(`(\t)([A-Z][a-zA-Z0-9_]*)\s+([A-Z][a-zA-Z0-9_]*)\s*=`)
-	// IMPORTANT: Use ${N} syntax because $N followed by alphanumeric (e.g., $1enum...) is parsed as one group name
-	code = constDeclRegex.ReplaceAllString(code, fmt.Sprintf("${1}%s_${2} %s_${3} =", prefix, prefix))
-
-	// Prefix type conversions: ":= Foo(" or "= Foo(" -> ":= prefix_Foo(" or "= prefix_Foo("
-	// Process line-by-line to avoid matching Decode function calls (which look like type conversions syntactically)
-	{
-		typeConvRegex := regexp.MustCompile(`(=\s*)([A-Z][a-zA-Z0-9_]*)\(`)
-		typeConvLines := strings.Split(code, "\n")
-		for i, line := range typeConvLines {
-			if m := typeConvRegex.FindStringSubmatch(line); m != nil {
-				typeName := m[2]
-				// Skip decode function calls — these are handled by separate decode regexes
-				if strings.HasPrefix(typeName, "Decode") || strings.HasPrefix(typeName, "decode") {
-					continue
-				}
-				// IMPORTANT: Use ${N} syntax because $N followed by alphanumeric is parsed as one group name
-				typeConvLines[i] = typeConvRegex.ReplaceAllString(line, fmt.Sprintf("${1}%s_${2}(", prefix))
-			}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(pkgDir, name), []byte(content), 0644); err != nil {
+			return failSuite(suite, "failed to write %s: %v", name, err)
 		}
-		code = strings.Join(typeConvLines, "\n")
 	}
+	return nil
+}
 
-	// Prefix bare uppercase identifiers in switch case statements: "case FooBar, FooBaz:" -> "case prefix_FooBar, prefix_FooBaz:"
-	// Process line by line to handle comma-separated identifiers
-	{
-		enumLines := strings.Split(code, "\n")
-		caseLineRegex := regexp.MustCompile(`^(\s*case\s+)(.+):$`)
-		upperIdRegex := regexp.MustCompile(`\b([A-Z][a-zA-Z0-9_]*)\b`)
-		for i, line := range enumLines {
-			if m := caseLineRegex.FindStringSubmatch(line); m != nil {
-				// Replace uppercase identifiers in the case expression (but not Go keywords)
-				caseExpr := upperIdRegex.ReplaceAllString(m[2], fmt.Sprintf("%s_$1", prefix))
-				enumLines[i] = m[1] + caseExpr + ":"
-			}
-		}
-		code = strings.Join(enumLines, "\n")
+// runSuitePackage builds a suite's package and runs it. A failure to build or run
+// fails every case of the suite, with the step and its output as the error.
+func runSuitePackage(moduleDir string, suite *TestSuite) []TestResult {
+	prefix := suitePackage(suite)
+	binary := filepath.Join(moduleDir, "bin", prefix)
+	cmd := exec.Command("go", "build", "-o", binary, "./suites/"+prefix)
+	cmd.Dir = moduleDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return failSuite(suite, "compile failed: %v\nOutput: %s", err, output)
 	}
 
-	// Prefix all Decode function DEFINITIONS: "func DecodeFoo" -> "func Decodeprefix_Foo"
-	// and "func decodeFooWithDecoder" -> "func decodeprefixFooWithDecoder"
-	// Note: Decode functions get prefix AFTER Decode, decode helpers get prefix BEFORE decode
-	decodeFuncDefRegex := regexp.MustCompile(`(\bfunc\s+)Decode([A-Z][a-zA-Z0-9_]*)`)
-	code = decodeFuncDefRegex.ReplaceAllString(code, fmt.Sprintf("${1}Decode%s_${2}", prefix))
-
-	decodeHelperRegex := regexp.MustCompile(`(\bfunc\s+)decode([A-Z][a-zA-Z0-9_]*)`)
-	code = decodeHelperRegex.ReplaceAllString(code, fmt.Sprintf("${1}%s_decode${2}", prefix))
-
-	// Prefix type references in function CALLS: "DecodeFoo(" -> "Decodeprefix_Foo("
-	// and "decodeFooWithDecoder(" -> "prefix_decodeFooWithDecoder("
-	decodeCallRegex := regexp.MustCompile(`\bDecode([A-Z][a-zA-Z0-9_]*)\(`)
-	code = decodeCallRegex.ReplaceAllString(code, fmt.Sprintf("Decode%s_${1}(", prefix))
-
-	decodeHelperCallRegex := regexp.MustCompile(`\bdecode([A-Z][a-zA-Z0-9_]*)\(`)
-	code = decodeHelperCallRegex.ReplaceAllString(code, fmt.Sprintf("%s_decode${1}(", prefix))
-
-	// Prefix type references carefully to avoid breaking maps and other Go syntax
-	// Only prefix: "&Foo{" -> "&prefix_Foo{", "*Foo," -> "*prefix_Foo,", " Foo{" -> " prefix_Foo{"
-	// but NOT map[string]Foo or other complex expressions
-
-	// Handle array types: []Type -> []prefix_Type
-	code = regexp.MustCompile(`\[\]([A-Z][a-zA-Z0-9_]*)`).ReplaceAllString(code, fmt.Sprintf("[]%s_$1", prefix))
-
-	// Handle &Type{} struct literals
-	code = regexp.MustCompile(`&([A-Z][a-zA-Z0-9_]*)\{`).ReplaceAllString(code, fmt.Sprintf("&%s_$1{", prefix))
-
-	// Handle *Type in function parameters/returns (with comma or closing paren after)
-	code = regexp.MustCompile(`\*([A-Z][a-zA-Z0-9_]*)([,\)])`).ReplaceAllString(code, fmt.Sprintf("*%s_$1$2", prefix))
-
-	// Handle *Type at end of line (struct field declarations like "Name *String")
-	code = regexp.MustCompile(`\*([A-Z][a-zA-Z0-9_]*)(\s*)$`).ReplaceAllStringFunc(code, func(match string) string {
-		// Process each line separately to match end of line
-		return regexp.MustCompile(`\*([A-Z][a-zA-Z0-9_]*)(\s*)$`).ReplaceAllString(match, fmt.Sprintf("*%s_$1$2", prefix))
-	})
-	// Actually need to do per-line replacement for end-of-line patterns
-	lines := strings.Split(code, "\n")
-	for i, line := range lines {
-		if regexp.MustCompile(`\s+\*([A-Z][a-zA-Z0-9_]*)$`).MatchString(line) {
-			lines[i] = regexp.MustCompile(`(\s+)\*([A-Z][a-zA-Z0-9_]*)$`).ReplaceAllString(line, fmt.Sprintf("$1*%s_$2", prefix))
-		}
+	cmd = exec.Command(binary)
+	cmd.Dir = moduleDir
+	output, err := cmd.Output()
+	if err != nil {
+		return failSuite(suite, "test harness failed: %v\nOutput: %s", err, output)
 	}
-	code = strings.Join(lines, "\n")
-
-	// Handle type assertions in case statements: "case *Type:" -> "case *prefix_Type:"
-	code = regexp.MustCompile(`case\s+\*([A-Z][a-zA-Z0-9_]*):`).ReplaceAllString(code, fmt.Sprintf("case *%s_$1:", prefix))
-
-	// Handle type assertions: elem.(*Type) -> elem.(*prefix_Type) and elem.(Type) -> elem.(prefix_Type)
-	code = regexp.MustCompile(`\.\(\*([A-Z][a-zA-Z0-9_]*)\)`).ReplaceAllString(code, fmt.Sprintf(".(*%s_$1)", prefix))
-	code = regexp.MustCompile(`\.\(([A-Z][a-zA-Z0-9_]*)\)`).ReplaceAllString(code, fmt.Sprintf(".(%s_$1)", prefix))
-
-	// Handle bare Type in function return types: ) (Type, error) -> ) (prefix_Type, error)
-	// Only match when preceded by ) to avoid matching function arguments
-	code = regexp.MustCompile(`\)\s*\(([A-Z][a-zA-Z0-9_]*),`).ReplaceAllString(code, fmt.Sprintf(") (%s_$1,", prefix))
-
-	// Handle Type{} struct literals (with space before)
-	code = regexp.MustCompile(`\s([A-Z][a-zA-Z0-9_]*)\{`).ReplaceAllString(code, fmt.Sprintf(" %s_$1{", prefix))
-
-	// Prefix method receivers: "func (m *Foo)" -> "func (m *prefix_Foo)"
-	methodRegex := regexp.MustCompile(`\bfunc\s+\(([a-z])\s+\*([A-Z][a-zA-Z0-9_]*)\)`)
-	code = methodRegex.ReplaceAllStringFunc(code, func(match string) string {
-		parts := methodRegex.FindStringSubmatch(match)
-		if len(parts) >= 3 {
-			receiver := parts[1]
-			typeName := parts[2]
-			return fmt.Sprintf("func (%s *%s_%s)", receiver, prefix, typeName)
-		}
-		return match
-	})
 
-	// Handle field types in struct definitions: "FieldName TypeName" at end of line
-	// Match patterns like "Value Discriminated_union" or "Cname CompressedDomain"
-	// Be careful to match full lines to avoid false matches
-	fieldTypeRegex := regexp.MustCompile(`(\s+\w+\s+)([A-Z][a-zA-Z0-9_]*)(\s*)$`)
-	lines = strings.Split(code, "\n")
-	for i, line := range lines {
-		// Only process lines that look like field declarations (indent + word + Type)
-		if fieldTypeRegex.MatchString(line) {
-			lines[i] = fieldTypeRegex.ReplaceAllString(line, fmt.Sprintf("${1}%s_${2}${3}", prefix))
-		}
+	var results []TestResult
+	if err := json5.Unmarshal(output, &results); err != nil {
+		return failSuite(suite, "failed to parse test results: %v\nOutput: %s", err, output)
 	}
-	code = strings.Join(lines, "\n")
-
-	return code
+	return results
 }
 
-func generateBatchedTestHarness(suites []*TestSuite, typePrefixes []string) string {
+// generateSuiteHarness generates the main.go run in a suite's package next to the
+// generated code. It refers to the generated types by their own names, so the code is
+// compiled exactly as the generator wrote it, and prints the suite's results as JSON5.
+func generateSuiteHarness(suite *TestSuite) string {
 	harness := `package main
 
 import (
 	"bytes"
 	"fmt"
 	"math"
-	"reflect"
+	"strings"
 
 	"github.com/aeolun/json5"
+	"github.com/serialexp/binschema/runtime"
 )
 
 type TestResult struct {
@@ -361,6 +254,28 @@ type TestResult struct {
 	DecodedValue interface{} ` + "`json:\"decoded_value,omitempty\"`" + `
 }
 
+// bitsMismatch describes how encoded differs from the expected bits, or returns ""
+// when it holds them followed only by zero padding to the end of the last byte
+func bitsMismatch(encoded []byte, expected []int, bitOrder runtime.BitOrder) string {
+	var got []int
+	for i := 0; i < len(encoded)*8; i++ {
+		shift := 7 - i%8
+		if bitOrder == runtime.LSBFirst {
+			shift = i % 8
+		}
+		got = append(got, int(encoded[i/8]>>shift)&1)
+	}
+	if len(encoded) != (len(expected)+7)/8 {
+		return fmt.Sprintf("encoded bits mismatch: got %v, want %v", got, expected)
+	}
+	for i, bit := range got {
+		if (i < len(expected) && bit != expected[i]) || (i >= len(expected) && bit != 0) {
+			return fmt.Sprintf("encoded bits mismatch: got %v, want %v", got, expected)
+		}
+	}
+	return ""
+}
+
 // Pointer helper functions for optional fields
 func ptrUint8(v uint8) *uint8 { return &v }
 func ptrUint16(v uint16) *uint16 { return &v }
@@ -374,137 +289,140 @@ func ptrFloat32(v float32) *float32 { return &v }
 func ptrFloat64(v float64) *float64 { return &v }
 func ptrString(v string) *string { return &v }
 func ptrBool(v bool) *bool { return &v }
+func ptrTo[T any](v T) *T { return &v }
 
 func main() {
 	_ = math.Pi
 	_ = bytes.Equal // Ensure bytes import is used even for instance-field-only tests
-	allResults := [][]TestResult{}
+	_ = runtime.ErrNeedMoreData
+	_ = strings.Contains // Used only by expected-error checks
+	results := []TestResult{}
 
 `
 
-	// Generate test code for each suite
-	for i, suite := range suites {
-		typePrefix := typePrefixes[i]
-		prefixedType := typePrefix + "_" + suite.TestType
-
-		// Check if the test type has instance fields
-		hasInstanceFields := false
-		if types, ok := suite.Schema["types"].(map[string]interface{}); ok {
-			if typeDef, ok := types[suite.TestType].(map[string]interface{}); ok {
-				if instances, ok := typeDef["instances"].([]interface{}); ok && len(instances) > 0 {
-					hasInstanceFields = true
-				}
+	// Check if the test type has instance fields
+	hasInstanceFields := false
+	if types, ok := suite.Schema["types"].(map[string]interface{}); ok {
+		if typeDef, ok := types[suite.TestType].(map[string]interface{}); ok {
+			if instances, ok := typeDef["instances"].([]interface{}); ok && len(instances) > 0 {
+				hasInstanceFields = true
 			}
 		}
+	}
 
-		harness += fmt.Sprintf("\t// Test suite: %s\n", suite.Name)
-		harness += "\t{\n"
-		harness += "\t\tresults := []TestResult{}\n\n"
+	harness += fmt.Sprintf("\t// Test suite: %s\n", suite.Name)
+	harness += "\t{\n"
 
-		for j, tc := range suite.TestCases {
-			// Skip encode-specific or decode-specific error tests (still not supported)
-			if tc.ShouldErrorOnEncode || tc.ShouldErrorOnDecode {
-				continue
-			}
+	for j, tc := range suite.TestCases {
+		// Skip encode-specific or decode-specific error tests (still not supported)
+		if tc.ShouldErrorOnEncode || tc.ShouldErrorOnDecode {
+			continue
+		}
 
-			harness += fmt.Sprintf("\t\t// Test case %d: %s\n", j, tc.Description)
-			harness += "\t\tfunc() {\n"
-			harness += fmt.Sprintf("\t\t\tresult := TestResult{Description: %q}\n", tc.Description)
-			harness += "\t\t\tdefer func() { results = append(results, result) }()\n\n"
-
-			// Handle should_error tests (general error expected)
-			if tc.ShouldError {
-				harness += fmt.Sprintf("\t\t\texpectedBytes := []byte{%s}\n", formatByteSlice(tc.Bytes))
-				harness += fmt.Sprintf("\t\t\t_, decErr := Decode%s(expectedBytes)\n", prefixedType)
-				harness += "\t\t\tif decErr == nil {\n"
-				harness += "\t\t\t\tresult.Error = \"expected decode error but got none\"\n"
-				harness += "\t\t\t\tresult.Pass = false\n"
-				harness += "\t\t\t\treturn\n"
-				harness += "\t\t\t}\n"
-				harness += "\t\t\tresult.Pass = true\n"
-				harness += "\t\t}()\n\n"
-				continue
-			}
+		harness += fmt.Sprintf("\t\t// Test case %d: %s\n", j, tc.Description)
+		harness += "\t\tfunc() {\n"
+		harness += fmt.Sprintf("\t\t\tresult := TestResult{Description: %q}\n", tc.Description)
+		harness += "\t\t\tdefer func() { results = append(results, result) }()\n\n"
 
-			// Generate expected decoded value if different from input
-			hasDecodedValue := tc.DecodedValue != nil
-			if hasDecodedValue {
-				harness += generateValueConstructionWithSchema(prefixedType, tc.DecodedValue, "expectedDecoded", suite, typePrefix)
-			}
+		// Handle expected decode errors: should_error, or an expected error code
+		if tc.ExpectsDecodeError() {
+			harness += generateDecodeErrorCheck(suite, tc)
+			harness += "\t\t}()\n\n"
+			continue
+		}
 
-			// Generate value construction with schema information
-			// For types with instance fields AND hasDecodedValue, we only need expectedDecoded
-			// Otherwise we need testValue for either encoding or comparison
-			needsTestValue := !hasInstanceFields || !hasDecodedValue
-			if needsTestValue {
-				harness += generateValueConstructionWithSchema(prefixedType, tc.Value, "testValue", suite, typePrefix)
-			}
+		// Handle generated property cases: no expected bytes, just Encode→Decode→Encode stability
+		if tc.RoundTripOnly {
+			harness += generateValueConstructionWithSchema(suite.TestType, tc.Value, "testValue", suite)
+			harness += generateRoundTripCheck(suite.TestType)
+			continue
+		}
 
-			// Define expectedBytes for types with instance fields (used for decode-only testing)
-			harness += fmt.Sprintf("\t\t\texpectedBytes := []byte{%s}\n", formatByteSlice(tc.Bytes))
+		// Generate expected decoded value if different from input
+		hasDecodedValue := tc.DecodedValue != nil
+		if hasDecodedValue {
+			harness += generateValueConstructionWithSchema(suite.TestType, tc.DecodedValue, "expectedDecoded", suite)
+		}
 
-			if hasInstanceFields {
-				// For types with instance fields, only test decoding
-				// Instance fields are decode-only (data is at different positions in the file)
-				harness += "\t\t\t// Instance fields are decode-only - skip encoding comparison\n"
-				harness += "\t\t\tresult.EncodedBytes = expectedBytes\n\n"
+		// Generate value construction with schema information
+		// For types with instance fields AND hasDecodedValue, we only need expectedDecoded
+		// Otherwise we need testValue for either encoding or comparison
+		needsTestValue := !hasInstanceFields || !hasDecodedValue
+		if needsTestValue {
+			harness += generateValueConstructionWithSchema(suite.TestType, tc.Value, "testValue", suite)
+		}
 
-				// Decode from expected bytes (which contains all data including instance positions)
-				harness += fmt.Sprintf("\t\t\tdecoded, decErr := Decode%s(expectedBytes)\n", prefixedType)
-				harness += "\t\t\tif decErr != nil {\n"
-				harness += "\t\t\t\tresult.Error = fmt.Sprintf(\"decode error: %v\", decErr)\n"
-				harness += "\t\t\t\treturn\n"
-				harness += "\t\t\t}\n"
-				harness += "\t\t\tresult.DecodedValue = decoded\n\n"
-			} else {
-				// Normal round-trip testing for types without instance fields
-				// Encode
-				harness += "\t\t\tencoded, encErr := testValue.Encode()\n"
-				harness += "\t\t\tif encErr != nil {\n"
-				harness += "\t\t\t\tresult.Error = fmt.Sprintf(\"encode error: %v\", encErr)\n"
-				harness += "\t\t\t\treturn\n"
-				harness += "\t\t\t}\n"
-				harness += "\t\t\tresult.EncodedBytes = encoded\n\n"
+		// Define expectedBytes for types with instance fields (used for decode-only testing)
+		harness += fmt.Sprintf("\t\t\texpectedBytes := []byte{%s}\n", formatByteSlice(tc.Bytes))
+
+		if hasInstanceFields {
+			// For types with instance fields, only test decoding
+			// Instance fields are decode-only (data is at different positions in the file)
+			harness += "\t\t\t// Instance fields are decode-only - skip encoding comparison\n"
+			harness += "\t\t\tresult.EncodedBytes = expectedBytes\n\n"
 
-				// Compare bytes
+			// Decode from expected bytes (which contains all data including instance positions)
+			harness += fmt.Sprintf("\t\t\tdecoded, decErr := Decode%s(expectedBytes)\n", suite.TestType)
+			harness += "\t\t\tif decErr != nil {\n"
+			harness += "\t\t\t\tresult.Error = fmt.Sprintf(\"decode error: %v\", decErr)\n"
+			harness += "\t\t\t\treturn\n"
+			harness += "\t\t\t}\n"
+			harness += "\t\t\tresult.DecodedValue = decoded\n\n"
+		} else {
+			// Normal round-trip testing for types without instance fields
+			// Encode
+			harness += "\t\t\tencoded, encErr := testValue.Encode()\n"
+			harness += "\t\t\tif encErr != nil {\n"
+			harness += "\t\t\t\tresult.Error = fmt.Sprintf(\"encode error: %v\", encErr)\n"
+			harness += "\t\t\t\treturn\n"
+			harness += "\t\t\t}\n"
+			harness += "\t\t\tresult.EncodedBytes = encoded\n\n"
+
+			// Compare bits, when the case gives them, or bytes
+			if tc.BitLevel {
+				harness += generateBitsCheck(suite, tc)
+			} else {
 				harness += "\t\t\tif !bytes.Equal(encoded, expectedBytes) {\n"
 				harness += "\t\t\t\tresult.Error = fmt.Sprintf(\"encoded bytes mismatch: got %v, want %v\", encoded, expectedBytes)\n"
 				harness += "\t\t\t\tresult.Pass = false\n"
 				harness += "\t\t\t\treturn\n"
 				harness += "\t\t\t}\n\n"
-
-				// Decode
-				harness += fmt.Sprintf("\t\t\tdecoded, decErr := Decode%s(encoded)\n", prefixedType)
-				harness += "\t\t\tif decErr != nil {\n"
-				harness += "\t\t\t\tresult.Error = fmt.Sprintf(\"decode error: %v\", decErr)\n"
-				harness += "\t\t\t\treturn\n"
-				harness += "\t\t\t}\n"
-				harness += "\t\t\tresult.DecodedValue = decoded\n\n"
 			}
 
-			// Compare values - use expectedDecoded if available, otherwise testValue
-			if hasDecodedValue {
-				harness += "\t\t\tif !reflect.DeepEqual(decoded, &expectedDecoded) {\n"
-				harness += "\t\t\t\tresult.Error = fmt.Sprintf(\"decoded value mismatch: got %+v, want %+v\", decoded, expectedDecoded)\n"
-			} else {
-				harness += "\t\t\tif !reflect.DeepEqual(decoded, &testValue) {\n"
-				harness += "\t\t\t\tresult.Error = fmt.Sprintf(\"decoded value mismatch: got %+v, want %+v\", decoded, testValue)\n"
-			}
-			harness += "\t\t\t\tresult.Pass = false\n"
+			// Decode
+			harness += fmt.Sprintf("\t\t\tdecoded, decErr := Decode%s(encoded)\n", suite.TestType)
+			harness += "\t\t\tif decErr != nil {\n"
+			harness += "\t\t\t\tresult.Error = fmt.Sprintf(\"decode error: %v\", decErr)\n"
 			harness += "\t\t\t\treturn\n"
-			harness += "\t\t\t}\n\n"
+			harness += "\t\t\t}\n"
+			harness += "\t\t\tresult.DecodedValue = decoded\n\n"
+		}
 
-			harness += "\t\t\tresult.Pass = true\n"
-			harness += "\t\t}()\n\n"
+		// Compare values - use expectedDecoded if available, otherwise testValue
+		if hasDecodedValue {
+			harness += "\t\t\tif !decoded.Equal(&expectedDecoded) {\n"
+			harness += "\t\t\t\tresult.Error = fmt.Sprintf(\"decoded value mismatch: got %+v, want %+v\", decoded, expectedDecoded)\n"
+		} else {
+			harness += "\t\t\tif !decoded.Equal(&testValue) {\n"
+			harness += "\t\t\t\tresult.Error = fmt.Sprintf(\"decoded value mismatch: got %+v, want %+v\", decoded, testValue)\n"
+		}
+		harness += "\t\t\t\tresult.Pass = false\n"
+		harness += "\t\t\t\treturn\n"
+		harness += "\t\t\t}\n\n"
+
+		if len(tc.ChunkSizes) > 0 {
+			harness += generateStreamingCheck(suite, tc.ChunkSizes)
 		}
 
-		harness += "\t\tallResults = append(allResults, results)\n"
-		harness += "\t}\n\n"
+		harness += "\t\t\tresult.Pass = true\n"
+		harness += "\t\t}()\n\n"
 	}
 
+	harness += "\t}\n\n"
+
 	harness += `
 	// Output results as JSON5
-	data, err := json5.Marshal(allResults)
+	data, err := json5.Marshal(results)
 	if err != nil {
 		panic(err)
 	}
@@ -515,7 +433,136 @@ func main() {
 	return harness
 }
 
-func generateValueConstructionWithSchema(typeName string, value interface{}, varName string, suite *TestSuite, typePrefix string) string {
+// generateBitsCheck emits a check that encoded holds exactly the case's bits: the same
+// bits, then only zero padding to the end of the last byte. Encode's bytes can't say
+// how many bits were written, so the bit count is checked by decoding expectedBytes
+// with a decoder of the harness's own and reading its BitPosition.
+func generateBitsCheck(suite *TestSuite, tc TestCase) string {
+	bitOrder := suiteBitOrder(suite)
+	code := fmt.Sprintf("\t\t\texpectedBits := []int{%s}\n", formatIntSlice(tc.Bits))
+	code += fmt.Sprintf("\t\t\tif mismatch := bitsMismatch(encoded, expectedBits, %s); mismatch != \"\" {\n", bitOrder)
+	code += "\t\t\t\tresult.Error = mismatch\n"
+	code += "\t\t\t\treturn\n"
+	code += "\t\t\t}\n"
+	code += fmt.Sprintf("\t\t\tbitDecoder := runtime.NewBitStreamDecoder(expectedBytes, %s)\n", bitOrder)
+	code += fmt.Sprintf("\t\t\tif _, err := decode%sWithDecoder(bitDecoder); err != nil || bitDecoder.BitPosition() != len(expectedBits) {\n", suite.TestType)
+	code += "\t\t\t\tresult.Error = fmt.Sprintf(\"decoding read %d bits, want %d (%v)\", bitDecoder.BitPosition(), len(expectedBits), err)\n"
+	code += "\t\t\t\treturn\n"
+	code += "\t\t\t}\n\n"
+	return code
+}
+
+// suiteBitOrder returns the runtime bit order constant for a suite's schema
+func suiteBitOrder(suite *TestSuite) string {
+	if config, ok := suite.Schema["config"].(map[string]interface{}); ok && config["bit_order"] == "lsb_first" {
+		return "runtime.LSBFirst"
+	}
+	return "runtime.MSBFirst"
+}
+
+// generateDecodeErrorCheck emits a check that expectedBytes fail to decode with the
+// error the case expects: an error containing its error_message, and with its error
+// code as the decoder's LastErrorCode. The code is read from a second decode with a
+// decoder of the harness's own, since DecodeX doesn't expose it.
+func generateDecodeErrorCheck(suite *TestSuite, tc TestCase) string {
+	code := fmt.Sprintf("\t\t\texpectedBytes := []byte{%s}\n", formatByteSlice(tc.Bytes))
+	code += fmt.Sprintf("\t\t\t_, decErr := Decode%s(expectedBytes)\n", suite.TestType)
+	code += "\t\t\tif decErr == nil {\n"
+	code += "\t\t\t\tresult.Error = \"expected decode error but got none\"\n"
+	code += "\t\t\t\treturn\n"
+	code += "\t\t\t}\n"
+	if tc.ErrorMessage != "" {
+		code += fmt.Sprintf("\t\t\tif !strings.Contains(decErr.Error(), %q) {\n", tc.ErrorMessage)
+		code += fmt.Sprintf("\t\t\t\tresult.Error = fmt.Sprintf(\"decode error %%q doesn't contain %%q\", decErr.Error(), %q)\n", tc.ErrorMessage)
+		code += "\t\t\t\treturn\n"
+		code += "\t\t\t}\n"
+	}
+	if tc.Error != nil {
+		code += fmt.Sprintf("\t\t\tdecoder := runtime.NewBitStreamDecoder(expectedBytes, %s)\n", suiteBitOrder(suite))
+		code += fmt.Sprintf("\t\t\t_, _ = decode%sWithDecoder(decoder)\n", suite.TestType)
+		code += "\t\t\tif decoder.LastErrorCode == nil {\n"
+		code += fmt.Sprintf("\t\t\t\tresult.Error = fmt.Sprintf(\"decode error code none, want %%s (%%v)\", %q, decErr)\n", *tc.Error)
+		code += "\t\t\t\treturn\n"
+		code += "\t\t\t}\n"
+		code += fmt.Sprintf("\t\t\tif *decoder.LastErrorCode != %q {\n", *tc.Error)
+		code += fmt.Sprintf("\t\t\t\tresult.Error = fmt.Sprintf(\"decode error code %%s, want %%s (%%v)\", *decoder.LastErrorCode, %q, decErr)\n", *tc.Error)
+		code += "\t\t\t\treturn\n"
+		code += "\t\t\t}\n"
+	}
+	code += "\t\t\tresult.Pass = true\n"
+	return code
+}
+
+// generateStreamingCheck emits a check that expectedBytes, fed to a runtime
+// StatefulDecoder in chunks whose sizes cycle through chunkSizes, decode to the same
+// single value as decoding them whole. The stateful decoder wraps the generated
+// decodeXWithDecoder itself, which every generator emits, rather than a
+// DecodeXStream only the Go generator does. Expects decoded to be declared.
+func generateStreamingCheck(suite *TestSuite, chunkSizes []int) string {
+	sizes := make([]string, len(chunkSizes))
+	for i, size := range chunkSizes {
+		sizes[i] = fmt.Sprint(size)
+	}
+	code := fmt.Sprintf("\t\t\tstream := runtime.NewStatefulDecoder(%s, decode%sWithDecoder)\n", suiteBitOrder(suite), suite.TestType)
+	code += fmt.Sprintf("\t\t\tchunkSizes := []int{%s}\n", strings.Join(sizes, ", "))
+	code += fmt.Sprintf("\t\t\tvar streamed []*%s\n", suite.TestType)
+	code += "\t\t\tfor offset, i := 0, 0; offset < len(expectedBytes); i++ {\n"
+	code += "\t\t\t\tend := min(offset+chunkSizes[i%len(chunkSizes)], len(expectedBytes))\n"
+	code += "\t\t\t\tstream.Feed(expectedBytes[offset:end])\n"
+	code += "\t\t\t\toffset = end\n"
+	code += "\t\t\t\tfor {\n"
+	code += "\t\t\t\t\titem, err := stream.Next()\n"
+	code += "\t\t\t\t\tif err == runtime.ErrNeedMoreData {\n"
+	code += "\t\t\t\t\t\tbreak\n"
+	code += "\t\t\t\t\t}\n"
+	code += "\t\t\t\t\tif err != nil {\n"
+	code += "\t\t\t\t\t\tresult.Error = fmt.Sprintf(\"streaming decode error with chunks %v: %v\", chunkSizes, err)\n"
+	code += "\t\t\t\t\t\treturn\n"
+	code += "\t\t\t\t\t}\n"
+	code += "\t\t\t\t\tstreamed = append(streamed, item)\n"
+	code += "\t\t\t\t}\n"
+	code += "\t\t\t}\n"
+	code += "\t\t\tif len(streamed) != 1 || !streamed[0].Equal(decoded) {\n"
+	code += "\t\t\t\tresult.Error = fmt.Sprintf(\"streaming decode with chunks %v: got %+v, want %+v\", chunkSizes, streamed, decoded)\n"
+	code += "\t\t\t\treturn\n"
+	code += "\t\t\t}\n\n"
+	return code
+}
+
+// generateRoundTripCheck emits the body of a property test case: encode the value,
+// decode the result, re-encode the decoded value and require identical bytes.
+// Expects testValue to be declared and closes the test case func.
+func generateRoundTripCheck(typeName string) string {
+	code := "\t\t\tencoded, encErr := testValue.Encode()\n"
+	code += "\t\t\tif encErr != nil {\n"
+	code += "\t\t\t\tresult.Error = fmt.Sprintf(\"encode error: %v\", encErr)\n"
+	code += "\t\t\t\treturn\n"
+	code += "\t\t\t}\n"
+	code += "\t\t\tresult.EncodedBytes = encoded\n\n"
+
+	code += fmt.Sprintf("\t\t\tdecoded, decErr := Decode%s(encoded)\n", typeName)
+	code += "\t\t\tif decErr != nil {\n"
+	code += "\t\t\t\tresult.Error = fmt.Sprintf(\"decode error: %v\", decErr)\n"
+	code += "\t\t\t\treturn\n"
+	code += "\t\t\t}\n"
+	code += "\t\t\tresult.DecodedValue = decoded\n\n"
+
+	code += "\t\t\treencoded, reencErr := decoded.Encode()\n"
+	code += "\t\t\tif reencErr != nil {\n"
+	code += "\t\t\t\tresult.Error = fmt.Sprintf(\"re-encode error: %v\", reencErr)\n"
+	code += "\t\t\t\treturn\n"
+	code += "\t\t\t}\n"
+	code += "\t\t\tif !bytes.Equal(encoded, reencoded) {\n"
+	code += "\t\t\t\tresult.Error = fmt.Sprintf(\"round-trip bytes mismatch: first %v, second %v\", encoded, reencoded)\n"
+	code += "\t\t\t\treturn\n"
+	code += "\t\t\t}\n\n"
+
+	code += "\t\t\tresult.Pass = true\n"
+	code += "\t\t}()\n\n"
+	return code
+}
+
+func generateValueConstructionWithSchema(typeName string, value interface{}, varName string, suite *TestSuite) string {
 	// Get the type definition from the schema
 	types, ok := suite.Schema["types"].(map[string]interface{})
 	if !ok {
@@ -553,8 +600,7 @@ func generateValueConstructionWithSchema(typeName string, value interface{}, var
 	if typeDefType, _ := typeDef["type"].(string); typeDefType == "array" {
 		if arrVal, ok := value.([]interface{}); ok {
 			// Get the array item type for proper formatting
-			typePrefix := strings.TrimSuffix(typeName, "_"+suite.TestType)
-			arrayValue := formatArrayTypeAliasValue(arrVal, typeDef, types, typePrefix, suite.TestType)
+			arrayValue := formatArrayTypeAliasValue(arrVal, typeDef, types, suite.TestType)
 			return fmt.Sprintf("\t\t\t%s := %s{Value: %s}\n", varName, typeName, arrayValue)
 		}
 	}
@@ -588,9 +634,6 @@ func generateValueConstructionWithSchema(typeName string, value interface{}, var
 		}
 	}
 
-	// Use the test type as the base name (typePrefix + "_" + baseTypeName = typeName)
-	baseTypeName := suite.TestType
-
 	result := fmt.Sprintf("\t\t\t%s := %s{\n", varName, typeName)
 	for key, val := range valueMap {
 		fieldDef := fieldDefs[key]
@@ -598,16 +641,16 @@ func generateValueConstructionWithSchema(typeName string, value interface{}, var
 
 		// Handle sequence fields
 		if fieldDef != nil {
-			fieldName := capitalizeFirst(key)
-			formattedVal := formatValueWithSchema(val, fieldDef, types, typePrefix, baseTypeName, key)
+			fieldName := toGoFieldName(key)
+			formattedVal := formatValueWithSchema(val, fieldDef, types, suite.TestType, key)
 			result += fmt.Sprintf("\t\t\t\t%s: %s,\n", fieldName, formattedVal)
 			continue
 		}
 
 		// Handle instance fields (position-based)
 		if instanceDef != nil {
-			fieldName := capitalizeFirst(key)
-			formattedVal := formatInstanceFieldValue(val, instanceDef, types, typePrefix)
+			fieldName := toGoFieldName(key)
+			formattedVal := formatInstanceFieldValue(val, instanceDef, types)
 			result += fmt.Sprintf("\t\t\t\t%s: %s,\n", fieldName, formattedVal)
 			continue
 		}
@@ -621,7 +664,7 @@ func generateValueConstructionWithSchema(typeName string, value interface{}, var
 
 // formatInstanceFieldValue formats an instance field value
 // Instance fields are position-based (decoded at specific offsets)
-func formatInstanceFieldValue(val interface{}, instanceDef map[string]interface{}, types map[string]interface{}, typePrefix string) string {
+func formatInstanceFieldValue(val interface{}, instanceDef map[string]interface{}, types map[string]interface{}) string {
 	if val == nil {
 		return "nil"
 	}
@@ -632,21 +675,17 @@ func formatInstanceFieldValue(val interface{}, instanceDef map[string]interface{
 	// Handle inline discriminated union type (type is an object with discriminator and variants)
 	if instanceTypeObj, ok := instanceType.(map[string]interface{}); ok {
 		if _, hasDiscriminator := instanceTypeObj["discriminator"]; hasDiscriminator {
-			// Inline discriminated union - value is map with "type" and "value"
+			// Inline discriminated union - value is map with "type" and "value", and the
+			// decoder stores it as such a map around the variant
 			valMap, ok := val.(map[string]interface{})
 			if !ok {
 				return "nil"
 			}
-			variantType, _ := valMap["type"].(string)
-			variantValue := valMap["value"]
-			goTypeName := typePrefix + "_" + capitalizeFirst(variantType)
-
-			// Format the variant value as a struct
-			if variantValMap, ok := variantValue.(map[string]interface{}); ok {
-				typeDef, _ := types[variantType].(map[string]interface{})
-				return fmt.Sprintf("map[string]interface{}{\"type\": %q, \"value\": &%s}", variantType, formatStructValue(variantValMap, typeDef, types, typePrefix, variantType))
+			variantType, ok := unionVariant(valMap, instanceTypeObj)
+			if !ok {
+				return "nil"
 			}
-			return fmt.Sprintf("map[string]interface{}{\"type\": %q, \"value\": &%s{}}", variantType, goTypeName)
+			return fmt.Sprintf("map[string]interface{}{\"type\": %q, \"value\": %s}", variantType, formatVariantValue(valMap["value"], variantType, types))
 		}
 	}
 
@@ -665,7 +704,7 @@ func formatInstanceFieldValue(val interface{}, instanceDef map[string]interface{
 		// Go generator wraps these in a struct with a Value field
 		if typeDefType != "" && typeDefType != "discriminated_union" && typeDefType != "array" {
 			// Check if it's a primitive type alias
-			goTypeName := typePrefix + "_" + capitalizeFirst(instanceTypeName)
+			goTypeName := toGoTypeName(instanceTypeName)
 			formattedVal := formatValueWithType(val, typeDefType)
 			return fmt.Sprintf("&%s{Value: %s}", goTypeName, formattedVal)
 		}
@@ -677,12 +716,12 @@ func formatInstanceFieldValue(val interface{}, instanceDef map[string]interface{
 			if !ok {
 				return "nil"
 			}
-			return formatDiscriminatedUnionValue(valMap, typeDef, types, typePrefix)
+			return formatDiscriminatedUnionValue(valMap, typeDef, types)
 		}
 
 		// Regular struct type - value is a map
 		if valMap, ok := val.(map[string]interface{}); ok {
-			return "&" + formatStructValue(valMap, typeDef, types, typePrefix, instanceTypeName)
+			return "&" + formatStructValue(valMap, typeDef, types, instanceTypeName)
 		}
 	}
 
@@ -690,9 +729,9 @@ func formatInstanceFieldValue(val interface{}, instanceDef map[string]interface{
 }
 
 // formatValueWithSchema formats a value using full field definition and schema context
-// parentTypeName is the base type name (without prefix) of the parent struct
+// parentTypeName is the type name of the parent struct
 // fieldName is the name of the field being formatted (for nested type names)
-func formatValueWithSchema(val interface{}, fieldDef map[string]interface{}, types map[string]interface{}, typePrefix string, parentTypeName string, fieldName string) string {
+func formatValueWithSchema(val interface{}, fieldDef map[string]interface{}, types map[string]interface{}, parentTypeName string, fieldName string) string {
 	if fieldDef == nil {
 		return formatValue(val)
 	}
@@ -718,28 +757,28 @@ func formatValueWithSchema(val interface{}, fieldDef map[string]interface{}, typ
 	// Handle inline array fields
 	if fieldType == "array" {
 		if valSlice, ok := val.([]interface{}); ok {
-			return formatArrayWithSchema(valSlice, fieldDef, types, typePrefix, parentTypeName, fieldName)
+			return formatArrayWithSchema(valSlice, fieldDef, types, parentTypeName, fieldName)
 		}
 	}
 
 	// Handle inline bitfield fields
 	if fieldType == "bitfield" {
 		if valMap, ok := val.(map[string]interface{}); ok {
-			return formatBitfieldValue(valMap, typePrefix, parentTypeName, fieldName)
+			return formatBitfieldValue(valMap, parentTypeName, fieldName)
 		}
 	}
 
 	// Handle inline discriminated_union fields
 	if fieldType == "discriminated_union" {
 		if valMap, ok := val.(map[string]interface{}); ok {
-			return formatDiscriminatedUnionValue(valMap, fieldDef, types, typePrefix)
+			return formatDiscriminatedUnionValue(valMap, fieldDef, types)
 		}
 	}
 
 	// Handle inline choice fields
 	if fieldType == "choice" {
 		if valMap, ok := val.(map[string]interface{}); ok {
-			return formatInlineChoiceValue(valMap, fieldDef, types, typePrefix)
+			return formatInlineChoiceValue(valMap, fieldDef, types)
 		}
 	}
 
@@ -787,7 +826,7 @@ func formatValueWithSchema(val interface{}, fieldDef map[string]interface{}, typ
 			// For type references (structs), format and take address
 			if typeDef, hasTypeDef := types[valueType].(map[string]interface{}); hasTypeDef {
 				typeDefType, _ := typeDef["type"].(string)
-				goTypeName := typePrefix + "_" + capitalizeFirst(valueType)
+				goTypeName := toGoTypeName(valueType)
 
 				// Type reference to string type
 				if typeDefType == "string" {
@@ -799,9 +838,16 @@ func formatValueWithSchema(val interface{}, fieldDef map[string]interface{}, typ
 					}
 				}
 
+				// Type reference to a union: a pointer to the interface holding the variant
+				if typeDefType == "discriminated_union" {
+					if valMap, ok := val.(map[string]interface{}); ok {
+						return fmt.Sprintf("ptrTo[%s](%s)", goTypeName, formatDiscriminatedUnionValue(valMap, typeDef, types))
+					}
+				}
+
 				// Type reference to struct type - value is a map
 				if valMap, ok := val.(map[string]interface{}); ok {
-					return "&" + formatStructValue(valMap, typeDef, types, typePrefix, valueType)
+					return "&" + formatStructValue(valMap, typeDef, types, valueType)
 				}
 			}
 			return formattedVal
@@ -814,7 +860,7 @@ func formatValueWithSchema(val interface{}, fieldDef map[string]interface{}, typ
 
 		// Handle type reference to enum type - value is just a number
 		if typeDefType == "enum" {
-			goTypeName := typePrefix + "_" + capitalizeFirst(fieldType)
+			goTypeName := toGoTypeName(fieldType)
 			if numVal, ok := val.(float64); ok {
 				return fmt.Sprintf("%s(%d)", goTypeName, int(numVal))
 			}
@@ -824,7 +870,7 @@ func formatValueWithSchema(val interface{}, fieldDef map[string]interface{}, typ
 		if typeDefType == "string" {
 			if strVal, ok := val.(string); ok {
 				if isStringUsedAsVariant(fieldType, types) {
-					goTypeName := typePrefix + "_" + capitalizeFirst(fieldType)
+					goTypeName := toGoTypeName(fieldType)
 					return fmt.Sprintf("%s{Value: %q}", goTypeName, strVal)
 				}
 				return fmt.Sprintf("%q", strVal)
@@ -835,8 +881,8 @@ func formatValueWithSchema(val interface{}, fieldDef map[string]interface{}, typ
 		// Go generator wraps array type aliases in a struct with a Value field
 		if typeDefType == "array" {
 			if valSlice, ok := val.([]interface{}); ok {
-				goTypeName := typePrefix + "_" + capitalizeFirst(fieldType)
-				arrayVal := formatArrayTypeAliasValue(valSlice, typeDef, types, typePrefix, fieldType)
+				goTypeName := toGoTypeName(fieldType)
+				arrayVal := formatArrayTypeAliasValue(valSlice, typeDef, types, fieldType)
 				return fmt.Sprintf("%s{Value: %s}", goTypeName, arrayVal)
 			}
 		}
@@ -844,23 +890,23 @@ func formatValueWithSchema(val interface{}, fieldDef map[string]interface{}, typ
 		// Handle type reference to discriminated_union type
 		if typeDefType == "discriminated_union" {
 			if valMap, ok := val.(map[string]interface{}); ok {
-				return formatDiscriminatedUnionValue(valMap, typeDef, types, typePrefix)
+				return formatDiscriminatedUnionValue(valMap, typeDef, types)
 			}
 		}
 
 		// Handle type alias to another user-defined type (e.g., Realm -> KerberosString)
 		// Go generator wraps these in a struct with a Value field
 		if aliasedTypeDef, hasAliasedType := types[typeDefType].(map[string]interface{}); hasAliasedType {
-			goTypeName := typePrefix + "_" + fieldType
+			goTypeName := fieldType
 			if valMap, ok := val.(map[string]interface{}); ok {
-				innerValue := formatStructValue(valMap, aliasedTypeDef, types, typePrefix, typeDefType)
+				innerValue := formatStructValue(valMap, aliasedTypeDef, types, typeDefType)
 				return fmt.Sprintf("%s{Value: %s}", goTypeName, innerValue)
 			}
 		}
 
 		// Handle type reference to struct type
 		if valMap, ok := val.(map[string]interface{}); ok {
-			return formatStructValue(valMap, typeDef, types, typePrefix, fieldType)
+			return formatStructValue(valMap, typeDef, types, fieldType)
 		}
 	}
 
@@ -963,7 +1009,7 @@ func isStringUsedAsVariant(typeName string, types map[string]interface{}) bool {
 // formatArrayWithSchema formats an array using schema info (handles choice types)
 // schemaTypeName is the name of the containing type in the schema (e.g., "EncryptedData")
 // fieldName is the name of the array field (e.g., "fields")
-func formatArrayWithSchema(arr []interface{}, fieldDef map[string]interface{}, types map[string]interface{}, typePrefix string, schemaTypeName string, fieldName string) string {
+func formatArrayWithSchema(arr []interface{}, fieldDef map[string]interface{}, types map[string]interface{}, schemaTypeName string, fieldName string) string {
 	items, ok := fieldDef["items"].(map[string]interface{})
 	if !ok {
 		return formatValue(arr)
@@ -973,12 +1019,12 @@ func formatArrayWithSchema(arr []interface{}, fieldDef map[string]interface{}, t
 
 	// Handle inline choice type arrays
 	if itemType == "choice" {
-		return formatChoiceArray(arr, items, types, typePrefix, schemaTypeName, fieldName)
+		return formatChoiceArray(arr, items, types, schemaTypeName, fieldName)
 	}
 
 	// Handle inline discriminated_union type arrays
 	if itemType == "discriminated_union" {
-		return formatDiscriminatedUnionArray(arr, items, types, typePrefix)
+		return formatDiscriminatedUnionArray(arr, items, types)
 	}
 
 	// Handle nested arrays (2D arrays, etc.)
@@ -987,7 +1033,7 @@ func formatArrayWithSchema(arr []interface{}, fieldDef map[string]interface{}, t
 		innerItemType, _ := innerItems["type"].(string)
 		goInnerType := mapPrimitiveType(innerItemType)
 		if goInnerType == "" {
-			goInnerType = typePrefix + "_" + capitalizeFirst(innerItemType)
+			goInnerType = toGoTypeName(innerItemType)
 		}
 
 		if len(arr) == 0 {
@@ -1014,14 +1060,14 @@ func formatArrayWithSchema(arr []interface{}, fieldDef map[string]interface{}, t
 		// Handle reference to discriminated_union type (e.g., CompressedLabel)
 		if typeDefType == "discriminated_union" {
 			// Use the typed version with the type name
-			return formatDiscriminatedUnionArrayTyped(arr, typeDef, types, typePrefix, itemType)
+			return formatDiscriminatedUnionArrayTyped(arr, typeDef, types, itemType)
 		}
 
 		// Handle reference to string type alias (e.g., Label)
 		if typeDefType == "string" {
 			if isStringUsedAsVariant(itemType, types) {
 				// String type used as discriminated union variant — struct wrapper
-				goTypeName := typePrefix + "_" + capitalizeFirst(itemType)
+				goTypeName := toGoTypeName(itemType)
 				if len(arr) == 0 {
 					return fmt.Sprintf("[]%s{}", goTypeName)
 				}
@@ -1049,14 +1095,14 @@ func formatArrayWithSchema(arr []interface{}, fieldDef map[string]interface{}, t
 		}
 
 		// Handle reference to struct type
-		goTypeName := typePrefix + "_" + capitalizeFirst(itemType)
+		goTypeName := toGoTypeName(itemType)
 		if len(arr) == 0 {
 			return fmt.Sprintf("[]%s{}", goTypeName)
 		}
 		result := fmt.Sprintf("[]%s{\n", goTypeName)
 		for _, elem := range arr {
 			if elemMap, ok := elem.(map[string]interface{}); ok {
-				result += "\t\t\t\t\t" + formatStructValue(elemMap, typeDef, types, typePrefix, itemType) + ",\n"
+				result += "\t\t\t\t\t" + formatStructValue(elemMap, typeDef, types, itemType) + ",\n"
 			}
 		}
 		result += "\t\t\t\t}"
@@ -1097,7 +1143,7 @@ func formatArrayWithSchema(arr []interface{}, fieldDef map[string]interface{}, t
 }
 
 // formatArrayTypeAliasValue formats an array for a type alias (e.g., CompressedDomain with items of CompressedLabel)
-func formatArrayTypeAliasValue(arr []interface{}, typeDef map[string]interface{}, types map[string]interface{}, typePrefix string, arrayTypeName string) string {
+func formatArrayTypeAliasValue(arr []interface{}, typeDef map[string]interface{}, types map[string]interface{}, arrayTypeName string) string {
 	items, ok := typeDef["items"].(map[string]interface{})
 	if !ok {
 		return formatValue(arr)
@@ -1109,24 +1155,24 @@ func formatArrayTypeAliasValue(arr []interface{}, typeDef map[string]interface{}
 	if itemTypeDef, hasTypeDef := types[itemType].(map[string]interface{}); hasTypeDef {
 		typeDefType, _ := itemTypeDef["type"].(string)
 		if typeDefType == "discriminated_union" {
-			return formatDiscriminatedUnionArrayTyped(arr, itemTypeDef, types, typePrefix, itemType)
+			return formatDiscriminatedUnionArrayTyped(arr, itemTypeDef, types, itemType)
 		}
 	}
 
 	// Handle inline discriminated_union
 	if itemType == "discriminated_union" {
 		// For inline discriminated_union, we still need to figure out a good type name
-		return formatDiscriminatedUnionArray(arr, items, types, typePrefix)
+		return formatDiscriminatedUnionArray(arr, items, types)
 	}
 
 	// For other types, use formatArrayWithSchema
 	// Note: For type aliases, the "containing type" is the type alias itself
-	return formatArrayWithSchema(arr, typeDef, types, typePrefix, arrayTypeName, "value")
+	return formatArrayWithSchema(arr, typeDef, types, arrayTypeName, "value")
 }
 
 // formatDiscriminatedUnionArrayTyped formats an array of discriminated union values with a proper Go type
-func formatDiscriminatedUnionArrayTyped(arr []interface{}, unionDef map[string]interface{}, types map[string]interface{}, typePrefix string, unionTypeName string) string {
-	goTypeName := typePrefix + "_" + capitalizeFirst(unionTypeName)
+func formatDiscriminatedUnionArrayTyped(arr []interface{}, unionDef map[string]interface{}, types map[string]interface{}, unionTypeName string) string {
+	goTypeName := toGoTypeName(unionTypeName)
 
 	if len(arr) == 0 {
 		return fmt.Sprintf("[]%s{}", goTypeName)
@@ -1135,7 +1181,7 @@ func formatDiscriminatedUnionArrayTyped(arr []interface{}, unionDef map[string]i
 	result := fmt.Sprintf("[]%s{\n", goTypeName)
 	for _, elem := range arr {
 		if elemMap, ok := elem.(map[string]interface{}); ok {
-			result += "\t\t\t\t\t" + formatDiscriminatedUnionValue(elemMap, unionDef, types, typePrefix) + ",\n"
+			result += "\t\t\t\t\t" + formatDiscriminatedUnionValue(elemMap, unionDef, types) + ",\n"
 		} else {
 			result += fmt.Sprintf("\t\t\t\t\t%v,\n", formatValue(elem))
 		}
@@ -1145,7 +1191,7 @@ func formatDiscriminatedUnionArrayTyped(arr []interface{}, unionDef map[string]i
 }
 
 // formatDiscriminatedUnionArray formats an array of discriminated union values (legacy, uses interface{})
-func formatDiscriminatedUnionArray(arr []interface{}, unionDef map[string]interface{}, types map[string]interface{}, typePrefix string) string {
+func formatDiscriminatedUnionArray(arr []interface{}, unionDef map[string]interface{}, types map[string]interface{}) string {
 	// For inline discriminated unions without a type name, use interface{}
 	if len(arr) == 0 {
 		return "nil"
@@ -1154,7 +1200,7 @@ func formatDiscriminatedUnionArray(arr []interface{}, unionDef map[string]interf
 	result := "[]interface{}{\n"
 	for _, elem := range arr {
 		if elemMap, ok := elem.(map[string]interface{}); ok {
-			result += "\t\t\t\t\t" + formatDiscriminatedUnionValue(elemMap, unionDef, types, typePrefix) + ",\n"
+			result += "\t\t\t\t\t" + formatDiscriminatedUnionValue(elemMap, unionDef, types) + ",\n"
 		} else {
 			result += fmt.Sprintf("\t\t\t\t\t%v,\n", formatValue(elem))
 		}
@@ -1166,13 +1212,13 @@ func formatDiscriminatedUnionArray(arr []interface{}, unionDef map[string]interf
 // formatBitfieldValue formats a bitfield struct value
 // parentTypeName is the name of the struct containing the bitfield field
 // fieldName is the name of the bitfield field (used to derive the struct type name)
-func formatBitfieldValue(val map[string]interface{}, typePrefix string, parentTypeName string, fieldName string) string {
+func formatBitfieldValue(val map[string]interface{}, parentTypeName string, fieldName string) string {
 	// The Go generator names bitfield structs as ParentType_FieldName
-	goTypeName := typePrefix + "_" + parentTypeName + "_" + capitalizeFirst(fieldName)
+	goTypeName := parentTypeName + "_" + toGoFieldName(fieldName)
 	result := goTypeName + "{"
 	var fields []string
 	for key, v := range val {
-		goFieldName := capitalizeFirst(key)
+		goFieldName := toGoFieldName(key)
 		fields = append(fields, fmt.Sprintf("%s: %s", goFieldName, formatValueWithType(v, "uint64")))
 	}
 	// Sort for deterministic output
@@ -1182,12 +1228,10 @@ func formatBitfieldValue(val map[string]interface{}, typePrefix string, parentTy
 	return result
 }
 
-// formatDiscriminatedUnionValue formats a discriminated union value
-// The value has format: {type: "VariantName", value: {...}}
 // formatInlineChoiceValue formats a choice value used as a direct sequence field.
 // Choice values are flat maps: {type: "VariantName", field1: val1, field2: val2, ...}
 // Unlike discriminated_union which wraps in {type, value}, choice fields are flat.
-func formatInlineChoiceValue(val map[string]interface{}, choiceDef map[string]interface{}, types map[string]interface{}, typePrefix string) string {
+func formatInlineChoiceValue(val map[string]interface{}, choiceDef map[string]interface{}, types map[string]interface{}) string {
 	variantType, _ := val["type"].(string)
 	if variantType == "" {
 		return formatValue(val)
@@ -1203,59 +1247,93 @@ func formatInlineChoiceValue(val map[string]interface{}, choiceDef map[string]in
 	// delegate so we get bytes/optional/type-reference handling for free
 	// instead of reinventing the per-field-type dispatch and emitting
 	// `[]int{…}` for bytes or bare `OptionalUint64{…}` for nested types.
-	return "&" + formatStructValue(val, variantTypeDef, types, typePrefix, variantType)
+	return "&" + formatStructValue(val, variantTypeDef, types, variantType)
 }
 
-func formatDiscriminatedUnionValue(val map[string]interface{}, unionDef map[string]interface{}, types map[string]interface{}, typePrefix string) string {
-	variantType, _ := val["type"].(string)
-	variantValue := val["value"]
+// formatDiscriminatedUnionValue formats a discriminated union value, which has the
+// format {type: "VariantName", value: {...}}, as a literal of the interface the union
+// field holds: a pointer to the variant's struct. The variant is picked from the
+// union's own variants, so a value naming a type the union can't hold yields nil and
+// fails the comparison instead of the build.
+func formatDiscriminatedUnionValue(val map[string]interface{}, unionDef map[string]interface{}, types map[string]interface{}) string {
+	variantType, ok := unionVariant(val, unionDef)
+	if !ok {
+		return fmt.Sprintf("nil /* not a variant of the union: %v */", val["type"])
+	}
+	return formatVariantValue(val["value"], variantType, types)
+}
 
+// unionVariant returns the variant type a union value names in its "type", checked
+// against the variants of unionDef when it lists them
+func unionVariant(val map[string]interface{}, unionDef map[string]interface{}) (string, bool) {
+	variantType, _ := val["type"].(string)
 	if variantType == "" {
-		return formatValue(val)
+		return "", false
+	}
+	variants, _ := unionDef["variants"].([]interface{})
+	if len(variants) == 0 {
+		return variantType, true
 	}
+	for _, variantRaw := range variants {
+		if variant, ok := variantRaw.(map[string]interface{}); ok && variant["type"] == variantType {
+			return variantType, true
+		}
+	}
+	return "", false
+}
 
-	// Look up the variant type definition
+// formatVariantValue formats the value of one union variant as a pointer to the
+// variant's struct. Variants that aren't structs are wrapped in a struct with a Value
+// field by the Go generator, so their value is formatted as that field.
+func formatVariantValue(variantValue interface{}, variantType string, types map[string]interface{}) string {
+	goTypeName := toGoTypeName(variantType)
 	variantTypeDef, _ := types[variantType].(map[string]interface{})
-	goTypeName := typePrefix + "_" + capitalizeFirst(variantType)
+	if variantTypeDef == nil {
+		return fmt.Sprintf("&%s{}", goTypeName)
+	}
 
-	// If variant value is a map (struct), format it as struct literal
-	if valMap, ok := variantValue.(map[string]interface{}); ok && variantTypeDef != nil {
-		return "&" + formatStructValue(valMap, variantTypeDef, types, typePrefix, variantType)
+	// Struct variant: the value is a map of its fields
+	if _, isStruct := variantTypeDef["sequence"]; isStruct {
+		if valMap, ok := variantValue.(map[string]interface{}); ok {
+			return "&" + formatStructValue(valMap, variantTypeDef, types, variantType)
+		}
+		return fmt.Sprintf("&%s{}", goTypeName)
 	}
 
-	// If variant value is a simple type (like string for Label)
-	if variantTypeDef != nil {
-		typeDefType, _ := variantTypeDef["type"].(string)
+	typeDefType, _ := variantTypeDef["type"].(string)
 
-		if typeDefType == "string" {
-			if strVal, ok := variantValue.(string); ok {
-				return fmt.Sprintf("&%s{Value: %q}", goTypeName, strVal)
-			}
+	// String variant (like Label): kept as a struct, since it needs the marker method
+	if typeDefType == "string" {
+		if strVal, ok := variantValue.(string); ok {
+			return fmt.Sprintf("&%s{Value: %q}", goTypeName, strVal)
 		}
+	}
 
-		// Handle back_reference types - wrap value in target type struct
-		if typeDefType == "back_reference" {
-			targetType, _ := variantTypeDef["target_type"].(string)
-			if targetType != "" {
-				goTargetTypeName := typePrefix + "_" + capitalizeFirst(targetType)
-				// Look up target type definition
-				targetTypeDef, _ := types[targetType].(map[string]interface{})
-				if targetTypeDef != nil {
-					targetTypeType, _ := targetTypeDef["type"].(string)
-					// If target type is a string, wrap the value appropriately
-					if targetTypeType == "string" {
-						if strVal, ok := variantValue.(string); ok {
-							return fmt.Sprintf("&%s{Value: %s{Value: %q}}", goTypeName, goTargetTypeName, strVal)
-						}
-					}
-					// If target value is a map (struct), format it as nested struct
-					if valMap, ok := variantValue.(map[string]interface{}); ok {
-						innerValue := formatStructValue(valMap, targetTypeDef, types, typePrefix, targetType)
-						return fmt.Sprintf("&%s{Value: %s}", goTypeName, innerValue)
-					}
+	// Handle back_reference types - wrap value in target type struct
+	if typeDefType == "back_reference" {
+		targetType, _ := variantTypeDef["target_type"].(string)
+		targetTypeDef, _ := types[targetType].(map[string]interface{})
+		if targetTypeDef != nil {
+			goTargetTypeName := toGoTypeName(targetType)
+			targetTypeType, _ := targetTypeDef["type"].(string)
+			// If target type is a string, wrap the value appropriately
+			if targetTypeType == "string" {
+				if strVal, ok := variantValue.(string); ok {
+					return fmt.Sprintf("&%s{Value: %s{Value: %q}}", goTypeName, goTargetTypeName, strVal)
 				}
 			}
+			// If target value is a map (struct), format it as nested struct
+			if valMap, ok := variantValue.(map[string]interface{}); ok {
+				innerValue := formatStructValue(valMap, targetTypeDef, types, targetType)
+				return fmt.Sprintf("&%s{Value: %s}", goTypeName, innerValue)
+			}
 		}
+		return fmt.Sprintf("&%s{}", goTypeName)
+	}
+
+	// Any other alias (uint16, an array, ...) is the struct's Value field
+	if typeDefType != "" && variantValue != nil {
+		return fmt.Sprintf("&%s{Value: %s}", goTypeName, formatValueWithSchema(variantValue, variantTypeDef, types, variantType, "value"))
 	}
 
 	// Fallback
@@ -1265,10 +1343,10 @@ func formatDiscriminatedUnionValue(val map[string]interface{}, unionDef map[stri
 // formatChoiceArray formats an array of choice type items
 // schemaTypeName is the containing type name (e.g., "EncryptedData")
 // fieldName is the array field name (e.g., "fields")
-func formatChoiceArray(arr []interface{}, items map[string]interface{}, types map[string]interface{}, typePrefix string, schemaTypeName string, fieldName string) string {
-	// Build the unique interface name: ${typePrefix}_${schemaTypeName}_${FieldName}_Choice
-	goFieldName := capitalizeFirst(fieldName)
-	choiceInterfaceName := fmt.Sprintf("%s_%s_%s_Choice", typePrefix, schemaTypeName, goFieldName)
+func formatChoiceArray(arr []interface{}, items map[string]interface{}, types map[string]interface{}, schemaTypeName string, fieldName string) string {
+	// Build the unique interface name: ${schemaTypeName}_${FieldName}_Choice
+	goFieldName := toGoFieldName(fieldName)
+	choiceInterfaceName := fmt.Sprintf("%s_%s_Choice", schemaTypeName, goFieldName)
 
 	if len(arr) == 0 {
 		return fmt.Sprintf("[]%s{}", choiceInterfaceName)
@@ -1282,7 +1360,7 @@ func formatChoiceArray(arr []interface{}, items map[string]interface{}, types ma
 			if variantType != "" {
 				if typeDef, ok := types[variantType].(map[string]interface{}); ok {
 					// Use the variant type name directly (preserve underscores)
-					goTypeName := typePrefix + "_" + variantType
+					goTypeName := variantType
 					// Format as pointer to satisfy interface
 					result += "\t\t\t\t\t&" + goTypeName + "{\n"
 					if sequence, ok := typeDef["sequence"].([]interface{}); ok {
@@ -1290,13 +1368,13 @@ func formatChoiceArray(arr []interface{}, items map[string]interface{}, types ma
 							if field, ok := fieldRaw.(map[string]interface{}); ok {
 								fieldName, _ := field["name"].(string)
 								if fieldVal, hasVal := elemMap[fieldName]; hasVal {
-									goFieldName := capitalizeFirst(fieldName)
+									goFieldName := toGoFieldName(fieldName)
 									fieldType, _ := field["type"].(string)
 
 									// Handle array fields with schema context
 									if fieldType == "array" {
 										if arrVal, ok := fieldVal.([]interface{}); ok {
-											result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatArrayWithSchema(arrVal, field, types, typePrefix, variantType, fieldName))
+											result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatArrayWithSchema(arrVal, field, types, variantType, fieldName))
 											continue
 										}
 									}
@@ -1304,7 +1382,7 @@ func formatChoiceArray(arr []interface{}, items map[string]interface{}, types ma
 									// Handle inline bitfield fields
 									if fieldType == "bitfield" {
 										if bitfieldVal, ok := fieldVal.(map[string]interface{}); ok {
-											result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatBitfieldValue(bitfieldVal, typePrefix, capitalizeFirst(variantType), fieldName))
+											result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatBitfieldValue(bitfieldVal, toGoTypeName(variantType), fieldName))
 											continue
 										}
 									}
@@ -1312,7 +1390,7 @@ func formatChoiceArray(arr []interface{}, items map[string]interface{}, types ma
 									// Handle inline discriminated_union fields
 									if fieldType == "discriminated_union" {
 										if unionVal, ok := fieldVal.(map[string]interface{}); ok {
-											result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatDiscriminatedUnionValue(unionVal, field, types, typePrefix))
+											result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatDiscriminatedUnionValue(unionVal, field, types))
 											continue
 										}
 									}
@@ -1325,7 +1403,7 @@ func formatChoiceArray(arr []interface{}, items map[string]interface{}, types ma
 										if refTypeType == "string" {
 											if strVal, ok := fieldVal.(string); ok {
 												if isStringUsedAsVariant(fieldType, types) {
-													refGoTypeName := typePrefix + "_" + capitalizeFirst(fieldType)
+													refGoTypeName := toGoTypeName(fieldType)
 													result += fmt.Sprintf("\t\t\t\t\t\t%s: %s{Value: %q},\n", goFieldName, refGoTypeName, strVal)
 												} else {
 													result += fmt.Sprintf("\t\t\t\t\t\t%s: %q,\n", goFieldName, strVal)
@@ -1337,8 +1415,8 @@ func formatChoiceArray(arr []interface{}, items map[string]interface{}, types ma
 										// Type reference to array type (e.g., CompressedDomain)
 										if refTypeType == "array" {
 											if arrVal, ok := fieldVal.([]interface{}); ok {
-												refGoTypeName := typePrefix + "_" + capitalizeFirst(fieldType)
-												arrayVal := formatArrayTypeAliasValue(arrVal, referencedTypeDef, types, typePrefix, fieldType)
+												refGoTypeName := toGoTypeName(fieldType)
+												arrayVal := formatArrayTypeAliasValue(arrVal, referencedTypeDef, types, fieldType)
 												result += fmt.Sprintf("\t\t\t\t\t\t%s: %s{Value: %s},\n", goFieldName, refGoTypeName, arrayVal)
 												continue
 											}
@@ -1347,7 +1425,7 @@ func formatChoiceArray(arr []interface{}, items map[string]interface{}, types ma
 										// Type reference to discriminated_union type
 										if refTypeType == "discriminated_union" {
 											if unionVal, ok := fieldVal.(map[string]interface{}); ok {
-												result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatDiscriminatedUnionValue(unionVal, referencedTypeDef, types, typePrefix))
+												result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatDiscriminatedUnionValue(unionVal, referencedTypeDef, types))
 												continue
 											}
 										}
@@ -1355,9 +1433,9 @@ func formatChoiceArray(arr []interface{}, items map[string]interface{}, types ma
 										// Type alias to another user-defined type (e.g., Realm -> KerberosString)
 										// Go generator wraps these in a struct with a Value field
 										if aliasedTypeDef, hasAliasedType := types[refTypeType].(map[string]interface{}); hasAliasedType {
-											refGoTypeName := typePrefix + "_" + fieldType
+											refGoTypeName := fieldType
 											if nestedMap, ok := fieldVal.(map[string]interface{}); ok {
-												innerValue := formatStructValue(nestedMap, aliasedTypeDef, types, typePrefix, refTypeType)
+												innerValue := formatStructValue(nestedMap, aliasedTypeDef, types, refTypeType)
 												result += fmt.Sprintf("\t\t\t\t\t\t%s: %s{Value: %s},\n", goFieldName, refGoTypeName, innerValue)
 												continue
 											}
@@ -1365,7 +1443,7 @@ func formatChoiceArray(arr []interface{}, items map[string]interface{}, types ma
 
 										// Type reference to struct type (has sequence)
 										if nestedMap, ok := fieldVal.(map[string]interface{}); ok {
-											result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatStructValue(nestedMap, referencedTypeDef, types, typePrefix, fieldType))
+											result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatStructValue(nestedMap, referencedTypeDef, types, fieldType))
 											continue
 										}
 									}
@@ -1388,9 +1466,9 @@ func formatChoiceArray(arr []interface{}, items map[string]interface{}, types ma
 }
 
 // formatStructValue formats a struct value with schema context
-func formatStructValue(val map[string]interface{}, typeDef map[string]interface{}, types map[string]interface{}, typePrefix string, typeName string) string {
+func formatStructValue(val map[string]interface{}, typeDef map[string]interface{}, types map[string]interface{}, typeName string) string {
 	// Use the type name directly (preserve underscores) since generated code preserves them
-	goTypeName := typePrefix + "_" + typeName
+	goTypeName := typeName
 	result := goTypeName + "{\n"
 
 	if sequence, ok := typeDef["sequence"].([]interface{}); ok {
@@ -1398,7 +1476,7 @@ func formatStructValue(val map[string]interface{}, typeDef map[string]interface{
 			if field, ok := fieldRaw.(map[string]interface{}); ok {
 				fieldName, _ := field["name"].(string)
 				if fieldVal, hasVal := val[fieldName]; hasVal {
-					goFieldName := capitalizeFirst(fieldName)
+					goFieldName := toGoFieldName(fieldName)
 					fieldType, _ := field["type"].(string)
 
 					// Check for bytes field — generated Go type is `[]byte`,
@@ -1425,7 +1503,7 @@ func formatStructValue(val map[string]interface{}, typeDef map[string]interface{
 					// Check for inline array field
 					if fieldType == "array" {
 						if arrVal, ok := fieldVal.([]interface{}); ok {
-							result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatArrayWithSchema(arrVal, field, types, typePrefix, typeName, fieldName))
+							result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatArrayWithSchema(arrVal, field, types, typeName, fieldName))
 							continue
 						}
 					}
@@ -1433,7 +1511,7 @@ func formatStructValue(val map[string]interface{}, typeDef map[string]interface{
 					// Check for inline bitfield field
 					if fieldType == "bitfield" {
 						if bitfieldVal, ok := fieldVal.(map[string]interface{}); ok {
-							result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatBitfieldValue(bitfieldVal, typePrefix, capitalizeFirst(typeName), fieldName))
+							result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatBitfieldValue(bitfieldVal, toGoTypeName(typeName), fieldName))
 							continue
 						}
 					}
@@ -1441,14 +1519,14 @@ func formatStructValue(val map[string]interface{}, typeDef map[string]interface{
 					// Check for inline discriminated_union field
 					if fieldType == "discriminated_union" {
 						if unionVal, ok := fieldVal.(map[string]interface{}); ok {
-							result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatDiscriminatedUnionValue(unionVal, field, types, typePrefix))
+							result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatDiscriminatedUnionValue(unionVal, field, types))
 							continue
 						}
 					}
 					// Check for inline choice field
 					if fieldType == "choice" {
 						if choiceVal, ok := fieldVal.(map[string]interface{}); ok {
-							result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatInlineChoiceValue(choiceVal, field, types, typePrefix))
+							result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatInlineChoiceValue(choiceVal, field, types))
 							continue
 						}
 					}
@@ -1456,7 +1534,7 @@ func formatStructValue(val map[string]interface{}, typeDef map[string]interface{
 
 					// Check for optional field - wrap value in pointer helper
 					if fieldType == "optional" {
-						formattedVal := formatValueWithSchema(fieldVal, field, types, typePrefix, typeName, fieldName)
+						formattedVal := formatValueWithSchema(fieldVal, field, types, typeName, fieldName)
 						result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formattedVal)
 						continue
 					}
@@ -1469,7 +1547,7 @@ func formatStructValue(val map[string]interface{}, typeDef map[string]interface{
 						if refTypeType == "string" {
 							if strVal, ok := fieldVal.(string); ok {
 								if isStringUsedAsVariant(fieldType, types) {
-									goTypeName := typePrefix + "_" + capitalizeFirst(fieldType)
+									goTypeName := toGoTypeName(fieldType)
 									result += fmt.Sprintf("\t\t\t\t\t\t%s: %s{Value: %q},\n", goFieldName, goTypeName, strVal)
 								} else {
 									result += fmt.Sprintf("\t\t\t\t\t\t%s: %q,\n", goFieldName, strVal)
@@ -1482,8 +1560,8 @@ func formatStructValue(val map[string]interface{}, typeDef map[string]interface{
 						// Go generator wraps array type aliases in a struct with a Value field
 						if refTypeType == "array" {
 							if arrVal, ok := fieldVal.([]interface{}); ok {
-								goTypeName := typePrefix + "_" + capitalizeFirst(fieldType)
-								arrayVal := formatArrayTypeAliasValue(arrVal, referencedTypeDef, types, typePrefix, fieldType)
+								goTypeName := toGoTypeName(fieldType)
+								arrayVal := formatArrayTypeAliasValue(arrVal, referencedTypeDef, types, fieldType)
 								result += fmt.Sprintf("\t\t\t\t\t\t%s: %s{Value: %s},\n", goFieldName, goTypeName, arrayVal)
 								continue
 							}
@@ -1492,7 +1570,7 @@ func formatStructValue(val map[string]interface{}, typeDef map[string]interface{
 						// Type reference to discriminated_union type
 						if refTypeType == "discriminated_union" {
 							if unionVal, ok := fieldVal.(map[string]interface{}); ok {
-								result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatDiscriminatedUnionValue(unionVal, referencedTypeDef, types, typePrefix))
+								result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatDiscriminatedUnionValue(unionVal, referencedTypeDef, types))
 								continue
 							}
 						}
@@ -1500,9 +1578,9 @@ func formatStructValue(val map[string]interface{}, typeDef map[string]interface{
 						// Type alias to another user-defined type (e.g., Realm -> KerberosString)
 						// Go generator wraps these in a struct with a Value field
 						if aliasedTypeDef, hasAliasedType := types[refTypeType].(map[string]interface{}); hasAliasedType {
-							goTypeName := typePrefix + "_" + fieldType
+							goTypeName := fieldType
 							if nestedMap, ok := fieldVal.(map[string]interface{}); ok {
-								innerValue := formatStructValue(nestedMap, aliasedTypeDef, types, typePrefix, refTypeType)
+								innerValue := formatStructValue(nestedMap, aliasedTypeDef, types, refTypeType)
 								result += fmt.Sprintf("\t\t\t\t\t\t%s: %s{Value: %s},\n", goFieldName, goTypeName, innerValue)
 								continue
 							}
@@ -1510,7 +1588,7 @@ func formatStructValue(val map[string]interface{}, typeDef map[string]interface{
 
 						// Type reference to struct type (has sequence)
 						if nestedMap, ok := fieldVal.(map[string]interface{}); ok {
-							result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatStructValue(nestedMap, referencedTypeDef, types, typePrefix, fieldType))
+							result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formatStructValue(nestedMap, referencedTypeDef, types, fieldType))
 							continue
 						}
 					}
@@ -1527,8 +1605,8 @@ func formatStructValue(val map[string]interface{}, typeDef map[string]interface{
 			if instance, ok := instanceRaw.(map[string]interface{}); ok {
 				instanceName, _ := instance["name"].(string)
 				if instanceVal, hasVal := val[instanceName]; hasVal {
-					goFieldName := capitalizeFirst(instanceName)
-					formattedVal := formatInstanceFieldValue(instanceVal, instance, types, typePrefix)
+					goFieldName := toGoFieldName(instanceName)
+					formattedVal := formatInstanceFieldValue(instanceVal, instance, types)
 					result += fmt.Sprintf("\t\t\t\t\t\t%s: %s,\n", goFieldName, formattedVal)
 				}
 			}
@@ -1547,7 +1625,7 @@ func generateValueConstructionSimple(typeName string, value interface{}, varName
 
 	result := fmt.Sprintf("\t\t\t%s := %s{\n", varName, typeName)
 	for key, val := range valueMap {
-		fieldName := capitalizeFirst(key)
+		fieldName := toGoFieldName(key)
 		result += fmt.Sprintf("\t\t\t\t%s: %s,\n", fieldName, formatValue(val))
 	}
 	result += "\t\t\t}\n"
@@ -1702,7 +1780,7 @@ func formatValueWithType(val interface{}, fieldType string) string {
 		// Handle maps/objects - format as struct literal if fieldType is provided
 		if fieldType != "" && !isPrimitiveType(fieldType) && fieldType != "array" && fieldType != "string" {
 			// It's a struct type - generate proper struct literal
-			goTypeName := toGoFieldName(fieldType)
+			goTypeName := toGoTypeName(fieldType)
 			var fields []string
 			for key, val := range v {
 				goFieldName := toGoFieldName(key)
@@ -1724,6 +1802,15 @@ func formatValue(val interface{}) string {
 	return formatValueWithType(val, "")
 }
 
+// formatIntSlice formats ints as the elements of a Go slice literal
+func formatIntSlice(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
 func formatByteSlice(bytes []byte) string {
 	if len(bytes) == 0 {
 		return ""
@@ -1738,33 +1825,20 @@ func formatByteSlice(bytes []byte) string {
 	return result
 }
 
-// toGoFieldName converts snake_case or camelCase to PascalCase (Go exported field name)
+// toGoFieldName converts a schema field name to its Go field name, as both Go
+// generators name fields: codegen.FieldName
 func toGoFieldName(s string) string {
+	return codegen.FieldName(s)
+}
+
+// toGoTypeName converts a schema type name to its Go type name, as the TypeScript
+// generator writes it: the name with its first letter upper-cased
+func toGoTypeName(s string) string {
 	if s == "" {
 		return ""
 	}
-	// Split on underscores and hyphens
-	parts := strings.FieldsFunc(s, func(r rune) bool {
-		return r == '_' || r == '-'
-	})
-	result := ""
-	for _, part := range parts {
-		if part == "" {
-			continue
-		}
-		// Capitalize first letter of each part
-		if part[0] >= 'a' && part[0] <= 'z' {
-			result += string(part[0]-32) + part[1:]
-		} else {
-			result += part
-		}
-	}
-	return result
-}
-
-// capitalizeFirst is an alias for toGoFieldName
-func capitalizeFirst(s string) string {
-	return toGoFieldName(s)
+	first, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(first)) + s[size:]
 }
 
 // isPrimitiveType checks if a type is a BinSchema primitive type
@@ -0,0 +1,147 @@
+// ABOUTME: Tests for the compiled harness's value construction from schema metadata
+// ABOUTME: Checks the Go literals emitted for union-typed expected values, and runs chunked vectors
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatDiscriminatedUnionValue(t *testing.T) {
+	types := map[string]interface{}{
+		"Ping": map[string]interface{}{
+			"sequence": []interface{}{
+				map[string]interface{}{"name": "tag", "type": "uint8"},
+				map[string]interface{}{"name": "seq", "type": "uint16"},
+			},
+		},
+		"Code": map[string]interface{}{"type": "uint16"},
+		"Message": map[string]interface{}{
+			"type":          "discriminated_union",
+			"discriminator": map[string]interface{}{"peek": "uint8"},
+			"variants": []interface{}{
+				map[string]interface{}{"when": "value == 0x01", "type": "Ping"},
+				map[string]interface{}{"when": "value == 0x02", "type": "Code"},
+			},
+		},
+	}
+	message := types["Message"].(map[string]interface{})
+
+	ping := map[string]interface{}{"type": "Ping", "value": map[string]interface{}{"tag": 1.0, "seq": 7.0}}
+	require.Equal(t, "&Ping{\n\t\t\t\t\t\tTag: 1,\n\t\t\t\t\t\tSeq: 7,\n\t\t\t\t\t}", formatDiscriminatedUnionValue(ping, message, types))
+
+	// A variant that isn't a struct is the Value of the struct wrapping it
+	code := map[string]interface{}{"type": "Code", "value": 404.0}
+	require.Equal(t, "&Code{Value: 404}", formatDiscriminatedUnionValue(code, message, types))
+
+	// A type the union can't hold gives nil, so the case fails instead of the build
+	other := map[string]interface{}{"type": "Pong", "value": map[string]interface{}{}}
+	require.Equal(t, "nil /* not a variant of the union: Pong */", formatDiscriminatedUnionValue(other, message, types))
+
+	// An optional union field is a pointer to the interface
+	optional := map[string]interface{}{"name": "last", "type": "optional", "value_type": "Message"}
+	require.Equal(t, "ptrTo[Message](&Code{Value: 404})", formatValueWithSchema(code, optional, types, "Log", "last"))
+}
+
+// Harness field names follow the TypeScript generator, whose code the harness compiles
+func TestToGoFieldName(t *testing.T) {
+	require.Equal(t, "DataOffset", toGoFieldName("data_offset"))
+	require.Equal(t, "UserID", toGoFieldName("user_id"))
+	require.Equal(t, "URLPath", toGoFieldName("urlPath"))
+	require.Equal(t, "Pattern5555", toGoFieldName("pattern_5555"))
+	require.Equal(t, "Reserved", toGoFieldName("_reserved"))
+	require.Equal(t, "ContentType", toGoFieldName("content-type"))
+	require.Equal(t, "Größe", toGoFieldName("größe"))
+
+	// Type names only get their first letter upper-cased
+	require.Equal(t, "DataChunk", toGoTypeName("DataChunk"))
+	require.Equal(t, "User_id", toGoTypeName("user_id"))
+	require.Equal(t, "Ñame", toGoTypeName("ñame"))
+}
+
+// pointSource is Point as the TypeScript Go generator writes it: the API the harness
+// calls, without needing that generator to run
+const pointSource = `package main
+
+import "github.com/serialexp/binschema/runtime"
+
+type Point struct {
+	X uint16
+	Y uint16
+}
+
+func (m *Point) Encode() ([]byte, error) {
+	encoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	encoder.WriteUint16(m.X, runtime.BigEndian)
+	encoder.WriteUint16(m.Y, runtime.BigEndian)
+	return encoder.Finish(), nil
+}
+
+func (m *Point) Equal(other *Point) bool {
+	return runtime.ValuesEqual(m, other)
+}
+
+func (m *Point) Hash() uint64 {
+	return runtime.HashValue(m)
+}
+
+func DecodePoint(bytes []byte) (*Point, error) {
+	return decodePointWithDecoder(runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst))
+}
+
+func decodePointWithDecoder(decoder *runtime.BitStreamDecoder) (*Point, error) {
+	x, err := decoder.ReadUint16(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decoder.ReadUint16(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	return &Point{X: x, Y: y}, nil
+}
+`
+
+// Cases with chunkSizes are also decoded from their bytes fed in those chunks, by a
+// StatefulDecoder around the generated decodeXWithDecoder
+func TestStreamingCheckChunkedVectors(t *testing.T) {
+	point := map[string]interface{}{"x": 1.0, "y": 515.0}
+	suite := &TestSuite{
+		Name:     "chunked_point",
+		TestType: "Point",
+		Schema: map[string]interface{}{
+			"config": map[string]interface{}{"endianness": "big_endian"},
+			"types": map[string]interface{}{
+				"Point": map[string]interface{}{
+					"sequence": []interface{}{
+						map[string]interface{}{"name": "x", "type": "uint16"},
+						map[string]interface{}{"name": "y", "type": "uint16"},
+					},
+				},
+			},
+		},
+		TestCases: []TestCase{
+			{Description: "one byte at a time", Value: point, Bytes: []byte{0, 1, 2, 3}, ChunkSizes: []int{1}},
+			{Description: "split inside a field", Value: point, Bytes: []byte{0, 1, 2, 3}, ChunkSizes: []int{3, 1}},
+			{Description: "whole", Value: point, Bytes: []byte{0, 1, 2, 3}, ChunkSizes: []int{4}},
+		},
+	}
+
+	moduleDir := t.TempDir()
+	pkgDir := filepath.Join(moduleDir, "suites", suitePackage(suite))
+	require.NoError(t, os.MkdirAll(pkgDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "generated.go"), []byte(pointSource), 0644))
+	harness := generateSuiteHarness(suite)
+	require.Contains(t, harness, "runtime.NewStatefulDecoder(runtime.MSBFirst, decodePointWithDecoder)")
+	require.NoError(t, os.WriteFile(filepath.Join(pkgDir, "main.go"), []byte(harness), 0644))
+	require.NoError(t, initBatchModule(moduleDir))
+
+	results := runSuitePackage(moduleDir, suite)
+	require.Len(t, results, 3)
+	for _, result := range results {
+		require.True(t, result.Pass, "%s: %s", result.Description, result.Error)
+	}
+}
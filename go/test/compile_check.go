@@ -0,0 +1,96 @@
+// ABOUTME: In-memory compile check of generated Go code: parse, gofmt and type-check
+// ABOUTME: Uses go/parser, go/format and go/types, so no module is written and nothing is built
+
+package test
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// GeneratedCodeChecker checks generated Go sources as package main. Its importer is
+// shared, so the runtime package is type-checked from source once for all of them.
+type GeneratedCodeChecker struct {
+	fset     *token.FileSet
+	importer types.Importer
+}
+
+// NewGeneratedCodeChecker returns a checker importing packages from source, which
+// must run inside the binschema module to find its runtime package
+func NewGeneratedCodeChecker() *GeneratedCodeChecker {
+	fset := token.NewFileSet()
+	return &GeneratedCodeChecker{fset: fset, importer: importer.ForCompiler(fset, "source", nil)}
+}
+
+// Check parses code, checks gofmt leaves it unchanged and type-checks it. It returns
+// the first problem found, prefixed by the step ("syntax", "gofmt" or "type check")
+// and its position in name, with the offending line of code, or nil if the code would
+// compile as it is formatted.
+func (c *GeneratedCodeChecker) Check(name, code string) error {
+	file, err := parser.ParseFile(c.fset, name, code, parser.AllErrors)
+	if err != nil {
+		var list scanner.ErrorList
+		if errors.As(err, &list) && len(list) > 0 {
+			return fmt.Errorf("syntax: %s\n%s", list[0], sourceLine(code, list[0].Pos.Line))
+		}
+		return fmt.Errorf("syntax: %w", err)
+	}
+	formatted, err := format.Source([]byte(code))
+	if err != nil {
+		return fmt.Errorf("gofmt: %w", err)
+	}
+	if line := firstDifferentLine(code, string(formatted)); line > 0 {
+		return fmt.Errorf("gofmt: %s:%d: not formatted\n%s", name, line, sourceLine(code, line))
+	}
+
+	var first error
+	conf := types.Config{
+		Importer: c.importer,
+		Error: func(err error) {
+			if first == nil {
+				first = err
+			}
+		},
+	}
+	conf.Check("main", c.fset, []*ast.File{file}, nil)
+	if first != nil {
+		var typeErr types.Error
+		if errors.As(first, &typeErr) {
+			return fmt.Errorf("type check: %s\n%s", typeErr, sourceLine(code, c.fset.Position(typeErr.Pos).Line))
+		}
+		return fmt.Errorf("type check: %w", first)
+	}
+	return nil
+}
+
+// firstDifferentLine returns the first line (from 1) where code and its formatted form
+// differ, or 0 if they are the same
+func firstDifferentLine(code, formatted string) int {
+	if code == formatted {
+		return 0
+	}
+	lines, want := strings.Split(code, "\n"), strings.Split(formatted, "\n")
+	for i := range lines {
+		if i >= len(want) || lines[i] != want[i] {
+			return i + 1
+		}
+	}
+	return len(lines)
+}
+
+// sourceLine returns line n (from 1) of code, trimmed, for showing with an error
+func sourceLine(code string, n int) string {
+	lines := strings.Split(code, "\n")
+	if n < 1 || n > len(lines) {
+		return ""
+	}
+	return "\t" + strings.TrimSpace(lines[n-1])
+}
@@ -0,0 +1,94 @@
+// ABOUTME: Compile-checks the Go code generated for every tests-json schema, in memory
+// ABOUTME: Syntax and gofmt errors always fail; known type errors are listed with the generator gap behind them
+
+package test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/serialexp/binschema/codegen"
+	"github.com/stretchr/testify/require"
+)
+
+// knownTypeErrors lists the suites whose generated code doesn't type-check yet, with
+// the generator gap behind each. A suite that starts type-checking must be removed.
+var knownTypeErrors = map[string]string{
+	"conditional_bigint_bitmask":      "a bitmask conditional (flags & 0x01) isn't compared with 0",
+	"conditional_equality":            "a bitmask conditional (flags & 0x01) isn't compared with 0",
+	"conditional_field":               "a bitmask conditional (flags & 0x01) isn't compared with 0",
+	"conditional_nested_parent":       "a bitmask conditional (flags & 0x01) isn't compared with 0",
+	"multiple_conditionals":           "a bitmask conditional (flags & 0x01) isn't compared with 0",
+	"nested_field_conditional":        "a bitmask conditional (flags & 0x01) isn't compared with 0",
+	"position_at_eof_boundary":        "decoding an empty struct declares result without using it",
+	"zero_size_position_field":        "decoding an empty struct declares result without using it",
+	"utf16_extended_chars":            "UTF-16 strings are encoded from a _bytes variable that is never declared",
+	"utf16_field_endianness_override": "UTF-16 strings are encoded from a _bytes variable that is never declared",
+	"utf16_fixed_big_endian":          "UTF-16 strings are encoded from a _bytes variable that is never declared",
+	"utf16_fixed_little_endian":       "UTF-16 strings are encoded from a _bytes variable that is never declared",
+	"utf16_length_prefixed":           "UTF-16 strings are encoded from a _bytes variable that is never declared",
+	"utf16_null_terminated":           "UTF-16 strings are encoded from a _bytes variable that is never declared",
+}
+
+// The options turning on the optional entry points, so their code is checked too
+var checkedOptions = codegen.GenerateOptions{
+	SelfDescribing:  true,
+	DecodeInto:      true,
+	DecodeWithArena: true,
+	DecodeStream:    true,
+	DecodeContext:   true,
+	ExtractFields:   true,
+}
+
+// TestGeneratedCodeCompiles runs GenerateGoWithOptions on the schema of every suite,
+// with checkedOptions, and checks the code parses, is gofmt-clean and type-checks,
+// failing with the position of the first problem. Schemas it refuses are counted, not
+// checked.
+func TestGeneratedCodeCompiles(t *testing.T) {
+	testsDir := filepath.Join("..", "..", "packages", "binschema", ".generated", "tests-json")
+	suites, err := LoadAllTestSuites(testsDir)
+	require.NoError(t, err, "Failed to load test suites")
+
+	checker := NewGeneratedCodeChecker()
+	checked, refused := 0, 0
+	for _, suite := range suites {
+		if suite.SchemaValidationError || suite.TestType == "" {
+			continue
+		}
+		code, err := codegen.GenerateGoWithOptions(suite.Schema, suite.TestType, checkedOptions)
+		if err != nil {
+			refused++
+			continue
+		}
+		checked++
+
+		err = checker.Check(suite.Name+".go", code)
+		reason, known := knownTypeErrors[suite.Name]
+		switch {
+		case err != nil && known && strings.HasPrefix(err.Error(), "type check:"):
+			t.Logf("%s: known type error (%s): %v", suite.Name, reason, err)
+		case err != nil:
+			t.Errorf("%s: %v", suite.Name, err)
+		case known:
+			t.Errorf("%s: now type-checks, remove it from knownTypeErrors", suite.Name)
+		}
+	}
+	t.Logf("Checked the code generated for %d schemas; GenerateGoWithOptions refused %d", checked, refused)
+}
+
+func TestGeneratedCodeCheckerPositions(t *testing.T) {
+	checker := NewGeneratedCodeChecker()
+	require.NoError(t, checker.Check("ok.go", "package main\n\nfunc main() {}\n"))
+
+	err := checker.Check("syntax.go", "package main\n\nfunc main() {\n\tresult. = 1\n}\n")
+	require.ErrorContains(t, err, "syntax: syntax.go:4:10:")
+	require.ErrorContains(t, err, "\tresult. = 1")
+
+	err = checker.Check("gofmt.go", "package main\n\nfunc main() {\n  println(1)\n}\n")
+	require.ErrorContains(t, err, "gofmt: gofmt.go:4: not formatted")
+	require.ErrorContains(t, err, "\tprintln(1)")
+
+	err = checker.Check("types.go", "package main\n\nfunc main() {\n\tvar n uint8 = \"x\"\n\t_ = n\n}\n")
+	require.ErrorContains(t, err, "type check: types.go:4:16:")
+}
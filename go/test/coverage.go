@@ -0,0 +1,254 @@
+// ABOUTME: Coverage report of the schema features exercised by the tests-json suites
+// ABOUTME: Lists the suites using each feature, and the generator's features no suite has vectors for
+
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/serialexp/binschema/codegen"
+)
+
+// FeatureCoverage maps schema features to the suites whose schemas use them. Features
+// are named "<category>: <value>" ("type: uint16", "array kind: fixed") or by the
+// attribute alone ("conditional"). Only suites with test cases count: a schema with no
+// vectors exercises nothing.
+type FeatureCoverage struct {
+	Suites   int                 // Suites walked
+	Features map[string][]string // Feature -> sorted names of the suites using it
+}
+
+// generatorFeatures returns every feature the Go generator has code for: the
+// builtin types and array and string kinds from codegen's schema vocabulary, plus
+// the attributes and configurations handled apart from them.
+func generatorFeatures() []string {
+	var features []string
+	for _, name := range codegen.BuiltinTypes() {
+		features = append(features, "type: "+name)
+	}
+	for _, kind := range codegen.ArrayKinds() {
+		features = append(features, "array kind: "+kind)
+	}
+	for _, kind := range codegen.StringKinds() {
+		features = append(features, "string kind: "+kind)
+	}
+	features = append(features,
+		"type reference",
+		"union discriminator: peek", "union discriminator: field",
+		"endianness: big_endian", "endianness: little_endian", "endianness: dynamic",
+		"bit order: msb_first", "bit order: lsb_first",
+		"computed: length_of", "computed: position_of", "computed: count_of",
+		"conditional", "endianness override", "const", "lazy", "instances", "selects_endianness",
+	)
+	sort.Strings(features)
+	return features
+}
+
+// BuildFeatureCoverage walks the schemas of suites and records the features each uses
+func BuildFeatureCoverage(suites []*TestSuite) *FeatureCoverage {
+	coverage := &FeatureCoverage{Features: make(map[string][]string)}
+	for _, suite := range suites {
+		if len(suite.TestCases) == 0 {
+			continue
+		}
+		coverage.Suites++
+		walker := &featureWalker{used: make(map[string]bool)}
+		walker.schema(suite.Schema)
+		for feature := range walker.used {
+			coverage.Features[feature] = append(coverage.Features[feature], suite.Name)
+		}
+	}
+	for _, names := range coverage.Features {
+		sort.Strings(names)
+	}
+	return coverage
+}
+
+// Uncovered returns the generator features no suite uses, sorted
+func (c *FeatureCoverage) Uncovered() []string {
+	var uncovered []string
+	for _, feature := range generatorFeatures() {
+		if len(c.Features[feature]) == 0 {
+			uncovered = append(uncovered, feature)
+		}
+	}
+	return uncovered
+}
+
+// PrintReport prints each feature with the number of suites using it, then the
+// generator features without vectors
+func (c *FeatureCoverage) PrintReport() {
+	features := make([]string, 0, len(c.Features))
+	for feature := range c.Features {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+
+	fmt.Printf("\n========== FEATURE COVERAGE ==========\n")
+	fmt.Printf("Suites with test vectors: %d\n\n", c.Suites)
+	for _, feature := range features {
+		fmt.Printf("  %-40s %d suites\n", feature, len(c.Features[feature]))
+	}
+	uncovered := c.Uncovered()
+	fmt.Printf("\nGenerator features without vectors: %d\n", len(uncovered))
+	for _, feature := range uncovered {
+		fmt.Printf("  - %s\n", feature)
+	}
+	fmt.Printf("======================================\n\n")
+}
+
+// PrintJSON prints the coverage as JSON (for scripting), uncovered features included
+func (c *FeatureCoverage) PrintJSON() {
+	data, err := json.MarshalIndent(struct {
+		*FeatureCoverage
+		Uncovered []string
+	}{c, c.Uncovered()}, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to marshal JSON: %v\n", err)
+		return
+	}
+	fmt.Println(string(data))
+}
+
+// featureWalker collects the features used by one raw schema
+type featureWalker struct {
+	types  map[string]interface{}
+	params map[string]bool // Type parameters of the generic type being walked ("T" in "Optional<T>")
+	used   map[string]bool
+}
+
+func (w *featureWalker) schema(schema map[string]interface{}) {
+	endianness, bitOrder := "big_endian", "msb_first"
+	if config, ok := schema["config"].(map[string]interface{}); ok {
+		if value, ok := config["endianness"].(string); ok {
+			endianness = value
+		}
+		if value, ok := config["bit_order"].(string); ok {
+			bitOrder = value
+		}
+	}
+	w.used["endianness: "+endianness] = true
+	w.used["bit order: "+bitOrder] = true
+
+	w.types, _ = schema["types"].(map[string]interface{})
+	for name, typeRaw := range w.types {
+		typeDef, ok := typeRaw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		w.params = make(map[string]bool)
+		if _, args, generic := genericType(name); generic {
+			for _, param := range args {
+				w.params[param] = true
+			}
+		}
+		if order, ok := typeDef["bit_order"].(string); ok {
+			w.used["bit order: "+order] = true
+		}
+		// Aliases, unions, enums and flag sets are described like a field
+		if _, ok := typeDef["type"]; ok {
+			w.field(typeDef)
+			continue
+		}
+		w.fields(typeDef["sequence"])
+		if instances, ok := typeDef["instances"].([]interface{}); ok && len(instances) > 0 {
+			w.used["instances"] = true
+			w.fields(instances)
+		}
+	}
+}
+
+func (w *featureWalker) fields(list interface{}) {
+	fields, _ := list.([]interface{})
+	for _, fieldRaw := range fields {
+		if field, ok := fieldRaw.(map[string]interface{}); ok {
+			w.field(field)
+		}
+	}
+}
+
+func (w *featureWalker) field(field map[string]interface{}) {
+	switch fieldType := field["type"].(type) {
+	case map[string]interface{}:
+		// Instances may give an inline union as their type
+		w.field(fieldType)
+	case string:
+		if base, args, generic := genericType(fieldType); generic {
+			// An instance of a generic type ("Optional<uint64>"): its arguments are types too
+			w.used["type reference"] = true
+			for name := range w.types {
+				if strings.HasPrefix(name, base+"<") {
+					w.used["generic type"] = true
+				}
+			}
+			for _, arg := range args {
+				w.field(map[string]interface{}{"type": arg})
+			}
+		} else if _, isType := w.types[fieldType]; isType {
+			w.used["type reference"] = true
+		} else if !w.params[fieldType] {
+			w.used["type: "+fieldType] = true
+		}
+		if kind, ok := field["kind"].(string); ok && (fieldType == "array" || fieldType == "string") {
+			w.used[fieldType+" kind: "+kind] = true
+		}
+		// Bitfield subfields are bit ranges, not fields with types of their own
+		if fieldType != "bitfield" {
+			w.fields(field["fields"])
+		}
+	}
+
+	if discriminator, ok := field["discriminator"].(map[string]interface{}); ok {
+		if discriminator["peek"] != nil {
+			w.used["union discriminator: peek"] = true
+		}
+		if discriminator["field"] != nil {
+			w.used["union discriminator: field"] = true
+		}
+	}
+	if computed, ok := field["computed"].(map[string]interface{}); ok {
+		w.used[fmt.Sprintf("computed: %v", computed["type"])] = true
+	}
+	if order, ok := field["bit_order"].(string); ok {
+		w.used["bit order: "+order] = true
+	}
+	for attribute, feature := range map[string]string{
+		"conditional":        "conditional",
+		"endianness":         "endianness override",
+		"const":              "const",
+		"lazy":               "lazy",
+		"selects_endianness": "selects_endianness",
+	} {
+		if value, ok := field[attribute]; ok && value != false {
+			w.used[feature] = true
+		}
+	}
+
+	for _, nested := range []string{"items", "value_type"} {
+		switch item := field[nested].(type) {
+		case map[string]interface{}:
+			w.field(item)
+		case string:
+			w.field(map[string]interface{}{"type": item})
+		}
+	}
+	w.fields(field["choices"])
+}
+
+// genericType splits a generic type name ("Optional<uint64>", "Pair<A, B>") into its
+// base name and type arguments
+func genericType(name string) (string, []string, bool) {
+	open := strings.Index(name, "<")
+	if open <= 0 || !strings.HasSuffix(name, ">") {
+		return "", nil, false
+	}
+	var args []string
+	for _, arg := range strings.Split(name[open+1:len(name)-1], ",") {
+		args = append(args, strings.TrimSpace(arg))
+	}
+	return name[:open], args, true
+}
@@ -0,0 +1,77 @@
+// ABOUTME: Tests for the feature coverage report, and the COVERAGE_REPORT mode printing it for tests-json
+// ABOUTME: The walk is checked on an inline schema so it doesn't depend on which suites exist
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestFeatureCoverageReport prints which schema features the tests-json suites cover,
+// e.g. COVERAGE_REPORT=1 go test ./test -run TestFeatureCoverageReport (or =json)
+func TestFeatureCoverageReport(t *testing.T) {
+	report := os.Getenv("COVERAGE_REPORT")
+	if report == "" {
+		t.Skip("COVERAGE_REPORT not set")
+	}
+	testsDir := filepath.Join("..", "..", "packages", "binschema", ".generated", "tests-json")
+	suites, err := LoadAllTestSuites(testsDir)
+	require.NoError(t, err, "Failed to load test suites")
+
+	coverage := BuildFeatureCoverage(suites)
+	if report == "json" {
+		coverage.PrintJSON()
+	} else {
+		coverage.PrintReport()
+	}
+}
+
+func TestBuildFeatureCoverage(t *testing.T) {
+	schema := map[string]interface{}{
+		"config": map[string]interface{}{"endianness": "little_endian"},
+		"types": map[string]interface{}{
+			"Packet": map[string]interface{}{
+				"sequence": []interface{}{
+					map[string]interface{}{"name": "len", "type": "uint16", "endianness": "big_endian",
+						"computed": map[string]interface{}{"type": "length_of", "target": "body"}},
+					map[string]interface{}{"name": "body", "type": "array", "kind": "field_referenced", "length_field": "len",
+						"items": map[string]interface{}{"type": "Item"}},
+					map[string]interface{}{"name": "note", "type": "optional", "value_type": "uint8"},
+					map[string]interface{}{"name": "flags", "type": "bitfield", "size": 8,
+						"fields": []interface{}{map[string]interface{}{"name": "a", "offset": 0, "size": 1}}},
+				},
+			},
+			"Item": map[string]interface{}{
+				"type":          "discriminated_union",
+				"discriminator": map[string]interface{}{"peek": "uint8"},
+				"variants":      []interface{}{map[string]interface{}{"when": "value == 1", "type": "Packet"}},
+			},
+		},
+	}
+	suites := []*TestSuite{
+		{Name: "packet", Schema: schema, TestCases: []TestCase{{Description: "empty"}}},
+		// No vectors, so nothing it uses counts
+		{Name: "schema_error", Schema: map[string]interface{}{"types": map[string]interface{}{
+			"Name": map[string]interface{}{"type": "string", "kind": "null_terminated"},
+		}}},
+	}
+
+	coverage := BuildFeatureCoverage(suites)
+	require.Equal(t, 1, coverage.Suites)
+	for _, feature := range []string{
+		"endianness: little_endian", "bit order: msb_first", "endianness override", "computed: length_of",
+		"type: uint16", "type: array", "array kind: field_referenced", "type reference",
+		"type: discriminated_union", "union discriminator: peek", "type: optional", "type: uint8", "type: bitfield",
+	} {
+		require.Equal(t, []string{"packet"}, coverage.Features[feature], feature)
+	}
+	require.Len(t, coverage.Features, 13)
+
+	uncovered := coverage.Uncovered()
+	require.Contains(t, uncovered, "string kind: null_terminated")
+	require.Contains(t, uncovered, "union discriminator: field")
+	require.NotContains(t, uncovered, "array kind: field_referenced")
+}
@@ -0,0 +1,338 @@
+// ABOUTME: Golden updater filling in tests-json vectors (bytes, bits) from the Go implementation
+// ABOUTME: Values are encoded with binschema.Dynamic and written back in place, keeping the file's key order
+
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/serialexp/binschema"
+)
+
+// UpdateVectorsFromEnv returns the paths named by UPDATE_VECTORS, a comma-separated
+// list of .test.json files or directories holding them, or nil when it isn't set.
+func UpdateVectorsFromEnv() []string {
+	raw := os.Getenv("UPDATE_VECTORS")
+	if raw == "" {
+		return nil
+	}
+	var paths []string
+	for _, path := range strings.Split(raw, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// UpdateVectors fills in the expected bytes of every test case in a .test.json file
+// from what binschema.Dynamic encodes its value to, so vectors for a feature written
+// on the Go side first needn't be computed by hand. Cases expected to fail are left
+// alone, as are cases with bytes whose value holds a null, which stands for either
+// infinity. A case given as bits gets bits, keeping its bit count when that still
+// covers the encoded bytes. Each value must decode back from its bytes (to
+// decoded_value, if given), or nothing is written. It returns the descriptions of the
+// cases changed; the file is rewritten only if there are any. The file must be plain
+// JSON, and its schema within what binschema.Dynamic supports.
+func UpdateVectors(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read test file %s: %w", path, err)
+	}
+	raw, err := decodeOrderedJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse test file %s (only plain JSON can be updated): %w", path, err)
+	}
+	root, ok := raw.(*orderedObject)
+	if !ok {
+		return nil, fmt.Errorf("%s: not a test suite object", path)
+	}
+	rawCases, _ := root.values["test_cases"].([]interface{})
+	if len(rawCases) == 0 {
+		rawCases, _ = root.values["tests"].([]interface{})
+	}
+
+	suite, err := LoadTestSuite(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(rawCases) != len(suite.TestCases) {
+		return nil, fmt.Errorf("%s: found %d raw test cases for %d loaded ones", path, len(rawCases), len(suite.TestCases))
+	}
+	dyn, err := binschema.CompileSchemaMap(suite.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("%s: schema load failed: %w", path, err)
+	}
+	bitOrder := "msb_first"
+	if config, ok := suite.Schema["config"].(map[string]interface{}); ok {
+		if order, ok := config["bit_order"].(string); ok {
+			bitOrder = order
+		}
+	}
+
+	var changed []string
+	for i, tc := range suite.TestCases {
+		if tc.ShouldError || tc.ShouldErrorOnEncode || tc.ShouldErrorOnDecode || tc.Error != nil {
+			continue
+		}
+		// null stands for an infinity JSON can't hold, without saying which one
+		if tc.Bytes != nil && containsNull(tc.Value) {
+			continue
+		}
+		encoded, err := dyn.Encode(suite.TestType, tc.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q: encode error: %w", path, tc.Description, err)
+		}
+		decoded, err := dyn.Decode(suite.TestType, encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q: decode error: %w", path, tc.Description, err)
+		}
+		expected := tc.Value
+		if tc.DecodedValue != nil {
+			expected = tc.DecodedValue
+		}
+		if !valuesEqual(expected, decoded) {
+			return nil, fmt.Errorf("%s: %q: decoded value mismatch: got %+v, want %+v", path, tc.Description, decoded, expected)
+		}
+		if tc.Bytes != nil && bytes.Equal(encoded, tc.Bytes) {
+			continue
+		}
+
+		rawCase, ok := rawCases[i].(*orderedObject)
+		if !ok {
+			return nil, fmt.Errorf("%s: test case %d is not an object", path, i)
+		}
+		if tc.BitLevel {
+			count := len(tc.Bits)
+			if (count+7)/8 != len(encoded) {
+				count = len(encoded) * 8
+			}
+			rawCase.set("bits", jsonNumbers(bytesToBits(encoded, bitOrder, count)))
+		} else {
+			rawCase.set("bytes", jsonNumbers(encoded))
+		}
+		changed = append(changed, tc.Description)
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	out, err := encodeOrderedJSON(root)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasSuffix(data, []byte("\n")) {
+		out = append(out, '\n')
+	}
+	if err := os.WriteFile(path, out, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write test file %s: %w", path, err)
+	}
+	return changed, nil
+}
+
+// UpdateAllVectors runs UpdateVectors on a file, or on every .test.json file under a
+// directory, and returns the changed case descriptions by file.
+func UpdateAllVectors(root string) (map[string][]string, error) {
+	updated := make(map[string][]string)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || (path != root && !strings.HasSuffix(path, ".test.json")) {
+			return nil
+		}
+		changed, err := UpdateVectors(path)
+		if err != nil {
+			return err
+		}
+		if len(changed) > 0 {
+			updated[path] = changed
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// containsNull reports whether a test value holds a null anywhere
+func containsNull(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		for _, item := range v {
+			if containsNull(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if containsNull(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bytesToBits is the inverse of bitsToBytes, giving count bits
+func bytesToBits(data []byte, bitOrder string, count int) []int {
+	bits := make([]int, count)
+	for i := range bits {
+		bitIdx := 7 - (i % 8)
+		if bitOrder == "lsb_first" {
+			bitIdx = i % 8
+		}
+		bits[i] = int(data[i/8]>>bitIdx) & 1
+	}
+	return bits
+}
+
+// jsonNumbers converts bytes or bits to the JSON array written for them
+func jsonNumbers[T byte | int](values []T) []interface{} {
+	list := make([]interface{}, len(values))
+	for i, v := range values {
+		list[i] = json.Number(fmt.Sprint(v))
+	}
+	return list
+}
+
+// orderedObject is a JSON object that keeps its keys in the order they were read,
+// so rewriting a test file changes only the values updated
+type orderedObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// set replaces a key's value, appending the key if it is new
+func (o *orderedObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// decodeOrderedJSON parses JSON into orderedObjects, []interface{}, json.Number,
+// strings, bools and nil
+func decodeOrderedJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	value, err := decodeOrderedValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the top-level value")
+	}
+	return value, nil
+}
+
+func decodeOrderedValue(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		obj := &orderedObject{values: make(map[string]interface{})}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrderedValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.set(key.(string), value)
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			value, err := decodeOrderedValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := dec.Token()
+		return list, err
+	}
+	return token, nil
+}
+
+// encodeOrderedJSON writes a value from decodeOrderedJSON indented by two spaces, as
+// JSON.stringify(value, null, 2) does when the TypeScript side generates the files
+func encodeOrderedJSON(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeOrderedValue(&buf, value, ""); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeOrderedValue(buf *bytes.Buffer, value interface{}, indent string) error {
+	switch v := value.(type) {
+	case *orderedObject:
+		if len(v.keys) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{")
+		for i, key := range v.keys {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n" + indent + "  ")
+			if err := writeJSONLeaf(buf, key); err != nil {
+				return err
+			}
+			buf.WriteString(": ")
+			if err := writeOrderedValue(buf, v.values[key], indent+"  "); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + indent + "}")
+		return nil
+	case []interface{}:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteString("[")
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n" + indent + "  ")
+			if err := writeOrderedValue(buf, item, indent+"  "); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("\n" + indent + "]")
+		return nil
+	}
+	return writeJSONLeaf(buf, value)
+}
+
+// writeJSONLeaf writes a string, number, bool or null without escaping HTML characters
+func writeJSONLeaf(buf *bytes.Buffer, value interface{}) error {
+	var leaf bytes.Buffer
+	enc := json.NewEncoder(&leaf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimRight(leaf.Bytes(), "\n"))
+	return nil
+}
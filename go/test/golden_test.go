@@ -0,0 +1,137 @@
+// ABOUTME: Tests for the golden updater, and the UPDATE_VECTORS mode that runs it on real files
+// ABOUTME: Inline suites are written to a temporary directory, so the tests-json files are never touched
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestUpdateVectorsMode rewrites the files named by UPDATE_VECTORS, e.g.
+// UPDATE_VECTORS=../../packages/binschema/tests-json/my_feature.test.json go test ./test -run TestUpdateVectorsMode
+func TestUpdateVectorsMode(t *testing.T) {
+	paths := UpdateVectorsFromEnv()
+	if len(paths) == 0 {
+		t.Skip("UPDATE_VECTORS not set")
+	}
+	for _, path := range paths {
+		updated, err := UpdateAllVectors(path)
+		require.NoError(t, err)
+		for file, cases := range updated {
+			for _, description := range cases {
+				t.Logf("%s: updated %q", file, description)
+			}
+		}
+	}
+}
+
+func TestUpdateVectors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sample.test.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+  "name": "sample",
+  "schema": {
+    "config": {
+      "endianness": "big_endian"
+    },
+    "types": {
+      "Sample": {
+        "sequence": [
+          {
+            "name": "flag",
+            "type": "bit",
+            "size": 3
+          },
+          {
+            "name": "spare",
+            "type": "bit",
+            "size": 5
+          },
+          {
+            "name": "big",
+            "type": "uint64"
+          }
+        ]
+      }
+    }
+  },
+  "test_type": "Sample",
+  "test_cases": [
+    {
+      "description": "new <case>",
+      "value": {
+        "flag": 5,
+        "spare": 0,
+        "big": "18446744073709551615n"
+      }
+    },
+    {
+      "description": "bits",
+      "value": {
+        "flag": 1,
+        "spare": 0,
+        "big": 1
+      },
+      "bits": [
+        0
+      ]
+    },
+    {
+      "description": "up to date",
+      "value": {
+        "flag": 0,
+        "spare": 0,
+        "big": 2
+      },
+      "bytes": [
+        0,
+        0,
+        0,
+        0,
+        0,
+        0,
+        0,
+        0,
+        2
+      ]
+    },
+    {
+      "description": "truncated",
+      "bytes": [],
+      "should_error": true
+    }
+  ]
+}
+`), 0o644))
+
+	changed, err := UpdateVectors(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"new <case>", "bits"}, changed)
+
+	suite, err := LoadTestSuite(path)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xA0, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, suite.TestCases[0].Bytes)
+	require.Len(t, suite.TestCases[1].Bits, 72)
+	require.Equal(t, []byte{0x20, 0, 0, 0, 0, 0, 0, 0, 1}, suite.TestCases[1].Bytes)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"description": "new <case>",
+      "value": {
+        "flag": 5,
+        "spare": 0,
+        "big": "18446744073709551615n"
+      },
+      "bytes": [
+        160,`)
+
+	// A second run has nothing left to change
+	changed, err = UpdateVectors(path)
+	require.NoError(t, err)
+	require.Empty(t, changed)
+	again, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, data, again)
+}
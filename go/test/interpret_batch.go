@@ -0,0 +1,264 @@
+// ABOUTME: In-process test executor that validates test vectors with binschema.Dynamic
+// ABOUTME: Alternative to CompileAndTestBatch that needs no code generation, temp modules or go run
+
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/serialexp/binschema"
+)
+
+// ExecutorFromEnv returns the test executor selected by GO_TEST_EXECUTOR:
+// "interpret" runs test vectors through binschema.Dynamic in-process,
+// anything else (the default) compiles generated code with CompileAndTestBatch.
+func ExecutorFromEnv() (string, func([]*TestSuite) (map[string][]TestResult, error)) {
+	if os.Getenv("GO_TEST_EXECUTOR") == "interpret" {
+		return "interpret", InterpretBatch
+	}
+	return "compile", CompileAndTestBatch
+}
+
+// InterpretBatch runs all test suites through binschema.Dynamic.
+// Results have the same shape as CompileAndTestBatch so both executors share reporting.
+func InterpretBatch(suites []*TestSuite) (map[string][]TestResult, error) {
+	results := make(map[string][]TestResult)
+
+	for _, suite := range suites {
+		if len(suite.TestCases) == 0 {
+			continue
+		}
+
+		dyn, err := binschema.CompileSchemaMap(suite.Schema)
+		if err != nil {
+			var failed []TestResult
+			for _, tc := range suite.TestCases {
+				failed = append(failed, TestResult{
+					Description: tc.Description,
+					Error:       fmt.Sprintf("schema load failed: %v", err),
+				})
+			}
+			results[suite.Name] = failed
+			continue
+		}
+
+		bitOrder := "msb_first"
+		if config, ok := suite.Schema["config"].(map[string]interface{}); ok {
+			if order, ok := config["bit_order"].(string); ok {
+				bitOrder = order
+			}
+		}
+
+		var suiteResults []TestResult
+		for _, tc := range suite.TestCases {
+			// Encode-specific or decode-specific error tests are skipped, as in the compiled harness
+			if tc.ShouldErrorOnEncode || tc.ShouldErrorOnDecode {
+				continue
+			}
+			suiteResults = append(suiteResults, interpretCase(dyn, suite.TestType, bitOrder, tc))
+		}
+		results[suite.Name] = suiteResults
+	}
+
+	return results, nil
+}
+
+// interpretCase runs one test case. Panics while encoding or decoding are reported as failures.
+// Bits are compared bit by bit, though only the compiled harness can check how many
+// were written: Dynamic keeps its decoder to itself.
+func interpretCase(dyn *binschema.Dynamic, typeName, bitOrder string, tc TestCase) (result TestResult) {
+	result.Description = tc.Description
+	defer func() {
+		if r := recover(); r != nil {
+			result.Pass = false
+			result.Error = fmt.Sprintf("interpreter panic: %v", r)
+		}
+	}()
+
+	if tc.ExpectsDecodeError() {
+		// Dynamic keeps its decoder to itself, so only the message can be checked
+		_, err := dyn.Decode(typeName, tc.Bytes)
+		if mismatch := decodeErrorMismatch(tc, err, nil); mismatch != "" {
+			result.Error = mismatch
+			return result
+		}
+		result.Pass = true
+		return result
+	}
+
+	encoded, err := dyn.Encode(typeName, tc.Value)
+	if err != nil {
+		result.Error = fmt.Sprintf("encode error: %v", err)
+		return result
+	}
+	result.EncodedBytes = encoded
+
+	if tc.RoundTripOnly {
+		decoded, err := dyn.Decode(typeName, encoded)
+		if err != nil {
+			result.Error = fmt.Sprintf("decode error: %v", err)
+			return result
+		}
+		result.DecodedValue = decoded
+		reencoded, err := dyn.Encode(typeName, decoded)
+		if err != nil {
+			result.Error = fmt.Sprintf("re-encode error: %v", err)
+			return result
+		}
+		if !bytes.Equal(encoded, reencoded) {
+			result.Error = fmt.Sprintf("round-trip mismatch: first encode %v, re-encode %v", encoded, reencoded)
+			return result
+		}
+		result.Pass = true
+		return result
+	}
+
+	if tc.BitLevel {
+		if mismatch := bitsMismatch(encoded, tc.Bits, bitOrder); mismatch != "" {
+			result.Error = mismatch
+			return result
+		}
+	} else if !bytes.Equal(encoded, tc.Bytes) {
+		result.Error = fmt.Sprintf("encoded bytes mismatch: got %v, want %v", encoded, tc.Bytes)
+		return result
+	}
+
+	decoded, err := dyn.Decode(typeName, tc.Bytes)
+	if err != nil {
+		result.Error = fmt.Sprintf("decode error: %v", err)
+		return result
+	}
+	result.DecodedValue = decoded
+
+	expected := tc.Value
+	if tc.DecodedValue != nil {
+		expected = tc.DecodedValue
+	}
+	if !valuesEqual(expected, decoded) {
+		result.Error = fmt.Sprintf("decoded value mismatch: got %+v, want %+v", decoded, expected)
+		return result
+	}
+
+	result.Pass = true
+	return result
+}
+
+// bitsMismatch describes how encoded differs from the expected bits, or returns ""
+// when it holds them followed only by zero padding to the end of the last byte. The
+// compiled harness has a copy of it.
+func bitsMismatch(encoded []byte, expected []int, bitOrder string) string {
+	got := bytesToBits(encoded, bitOrder, len(encoded)*8)
+	if len(encoded) != (len(expected)+7)/8 {
+		return fmt.Sprintf("encoded bits mismatch: got %v, want %v", got, expected)
+	}
+	for i, bit := range got {
+		if (i < len(expected) && bit != expected[i]) || (i >= len(expected) && bit != 0) {
+			return fmt.Sprintf("encoded bits mismatch: got %v, want %v", got, expected)
+		}
+	}
+	return ""
+}
+
+// decodeErrorMismatch describes how a decode error differs from the one a case
+// expects, or returns "" if it matches. The error code is compared only when code is
+// non-nil, i.e. the executor could read the decoder's LastErrorCode.
+func decodeErrorMismatch(tc TestCase, err error, code *string) string {
+	if err == nil {
+		return "expected decode error but got none"
+	}
+	if tc.ErrorMessage != "" && !strings.Contains(err.Error(), tc.ErrorMessage) {
+		return fmt.Sprintf("decode error %q doesn't contain %q", err.Error(), tc.ErrorMessage)
+	}
+	if tc.Error != nil && code != nil && *code != *tc.Error {
+		return fmt.Sprintf("decode error code %s, want %s (%v)", *code, *tc.Error, err)
+	}
+	return ""
+}
+
+// valuesEqual compares an expected test-vector value with a decoded one.
+//
+// Numbers compare by value regardless of Go type (JSON float64 vs uint16, BigInt
+// int64 vs uint64). A nil expectation matches +Inf floats, which JSON can't represent.
+// Keys present only in the decoded value are ignored: test vectors may omit
+// computed and const fields that decoding fills in.
+func valuesEqual(expected, actual interface{}) bool {
+	if expected == nil {
+		if actual == nil {
+			return true
+		}
+		f, isFloat := floatValue(actual)
+		return isFloat && math.IsInf(f, 1)
+	}
+
+	switch exp := expected.(type) {
+	case string:
+		act, ok := actual.(string)
+		return ok && exp == act
+	case bool:
+		act, ok := actual.(bool)
+		return ok && exp == act
+	case map[string]interface{}:
+		act, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range exp {
+			if !valuesEqual(v, act[k]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		act, ok := listValue(actual)
+		if !ok || len(act) != len(exp) {
+			return false
+		}
+		for i := range exp {
+			if !valuesEqual(exp[i], act[i]) {
+				return false
+			}
+		}
+		return true
+	case []byte:
+		list := make([]interface{}, len(exp))
+		for i, b := range exp {
+			list[i] = b
+		}
+		return valuesEqual(list, actual)
+	}
+
+	// Narrowed floats are compared at the decoded precision
+	if act, ok := actual.(float32); ok {
+		f, isNumber := floatValue(expected)
+		return isNumber && (float32(f) == act || (math.IsNaN(f) && math.IsNaN(float64(act))))
+	}
+	return binschema.NumericEqual(expected, actual)
+}
+
+func listValue(v interface{}) ([]interface{}, bool) {
+	switch x := v.(type) {
+	case []interface{}:
+		return x, true
+	case []byte:
+		list := make([]interface{}, len(x))
+		for i, b := range x {
+			list[i] = b
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+func floatValue(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	}
+	return 0, false
+}
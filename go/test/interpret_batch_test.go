@@ -0,0 +1,84 @@
+// ABOUTME: Tests for the in-process test executor
+// ABOUTME: Uses inline suites so they run without the generated tests-json directory
+package test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/serialexp/binschema/runtime"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValuesEqual(t *testing.T) {
+	require.True(t, valuesEqual(float64(300), uint16(300)))
+	require.True(t, valuesEqual(int64(-1), int8(-1)))
+	require.True(t, valuesEqual(uint64(1<<63), uint64(1<<63)))
+	require.False(t, valuesEqual(float64(1), uint8(2)))
+	require.True(t, valuesEqual(float64(3.14), float32(3.14)), "float32 compares at decoded precision")
+	require.True(t, valuesEqual(nil, math.Inf(1)), "null stands for +Inf")
+	require.True(t, valuesEqual([]interface{}{float64(1), float64(2)}, []byte{1, 2}))
+	require.True(t, valuesEqual(map[string]interface{}{"a": float64(1)}, map[string]interface{}{"a": uint8(1), "len": uint8(4)}))
+	require.False(t, valuesEqual(map[string]interface{}{"a": float64(1), "b": "x"}, map[string]interface{}{"a": uint8(1)}))
+}
+
+func TestInterpretBatch(t *testing.T) {
+	incomplete := runtime.ErrorIncompleteData
+	suite := &TestSuite{
+		Name:     "interpret_sample",
+		TestType: "Point",
+		Schema: map[string]interface{}{
+			"types": map[string]interface{}{
+				"Point": map[string]interface{}{"sequence": []interface{}{
+					map[string]interface{}{"name": "x", "type": "uint8"},
+					map[string]interface{}{"name": "y", "type": "uint8"},
+				}},
+			},
+		},
+		TestCases: []TestCase{
+			{Description: "match", Value: map[string]interface{}{"x": float64(1), "y": float64(2)}, Bytes: []byte{1, 2}},
+			{Description: "wrong bytes", Value: map[string]interface{}{"x": float64(1), "y": float64(2)}, Bytes: []byte{2, 1}},
+			{Description: "truncated", Bytes: []byte{1}, ShouldError: true},
+			{Description: "property", Value: map[string]interface{}{"x": float64(9), "y": float64(0)}, RoundTripOnly: true},
+			{Description: "truncated, message", Bytes: []byte{1}, ShouldError: true, ErrorMessage: "end of stream"},
+			{Description: "truncated, wrong message", Bytes: []byte{1}, ShouldError: true, ErrorMessage: "invalid"},
+			{Description: "complete", Bytes: []byte{1, 2}, Error: &incomplete},
+		},
+	}
+
+	results, err := InterpretBatch([]*TestSuite{suite})
+	require.NoError(t, err)
+	got := results[suite.Name]
+	require.Len(t, got, 7)
+	require.True(t, got[0].Pass, got[0].Error)
+	require.False(t, got[1].Pass)
+	require.Contains(t, got[1].Error, "encoded bytes mismatch")
+	require.True(t, got[2].Pass, got[2].Error)
+	require.True(t, got[3].Pass, got[3].Error)
+	require.True(t, got[4].Pass, got[4].Error)
+	require.False(t, got[5].Pass)
+	require.Contains(t, got[5].Error, `doesn't contain "invalid"`)
+	require.Equal(t, "expected decode error but got none", got[6].Error)
+}
+
+func TestBitsMismatch(t *testing.T) {
+	require.Empty(t, bitsMismatch([]byte{0xA0}, []int{1, 0, 1}, "msb_first"))
+	require.Empty(t, bitsMismatch([]byte{0x05}, []int{1, 0, 1}, "lsb_first"))
+	require.Contains(t, bitsMismatch([]byte{0xA1}, []int{1, 0, 1}, "msb_first"), "encoded bits mismatch", "nonzero padding")
+	require.Contains(t, bitsMismatch([]byte{0xA0, 0}, []int{1, 0, 1}, "msb_first"), "encoded bits mismatch", "extra byte")
+	require.Contains(t, bitsMismatch([]byte{0x80}, []int{1, 0, 1}, "msb_first"), "encoded bits mismatch")
+}
+
+func TestDecodeErrorMismatch(t *testing.T) {
+	incomplete := runtime.ErrorIncompleteData
+	invalid := runtime.ErrorInvalidValue
+	tc := TestCase{Error: &incomplete, ErrorMessage: "end of stream"}
+	require.True(t, tc.ExpectsDecodeError())
+	err := errors.New("unexpected end of stream")
+	require.Empty(t, decodeErrorMismatch(tc, err, &incomplete))
+	require.Empty(t, decodeErrorMismatch(tc, err, nil), "no code to compare")
+	require.Equal(t, "decode error code INVALID_VALUE, want INCOMPLETE_DATA (unexpected end of stream)", decodeErrorMismatch(tc, err, &invalid))
+	require.Equal(t, `decode error "bad tag" doesn't contain "end of stream"`, decodeErrorMismatch(tc, errors.New("bad tag"), &incomplete))
+	require.Equal(t, "expected decode error but got none", decodeErrorMismatch(tc, nil, nil))
+}
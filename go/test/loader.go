@@ -18,8 +18,8 @@ type TestSuite struct {
 	Description           string                 `json:"description"`
 	Schema                map[string]interface{} `json:"schema"`
 	TestType              string                 `json:"test_type"`
-	TestCases             []TestCase             `json:"test_cases"` // Primary field name
-	Tests                 []TestCase             `json:"tests"`      // Alternative field name (both are accepted)
+	TestCases             []TestCase             `json:"test_cases"`                        // Primary field name
+	Tests                 []TestCase             `json:"tests"`                             // Alternative field name (both are accepted)
 	SchemaValidationError bool                   `json:"schema_validation_error,omitempty"` // True if this tests schema validation failure
 	ErrorMessage          string                 `json:"error_message,omitempty"`           // Expected error message for validation error tests
 }
@@ -40,10 +40,18 @@ type TestCase struct {
 	Bytes               []byte      `json:"bytes"`
 	Bits                []int       `json:"bits,omitempty"`
 	ChunkSizes          []int       `json:"chunkSizes,omitempty"`
-	Error               *string     `json:"error,omitempty"`
-	ShouldError         bool        `json:"should_error,omitempty"`         // General error expected (decode or encode)
+	Error               *string     `json:"error,omitempty"`         // Expected error code of a failing decode (runtime.ErrorIncompleteData, ...); implies should_error
+	ErrorMessage        string      `json:"error_message,omitempty"` // Text the expected error contains, as the TypeScript runner checks it
+	ShouldError         bool        `json:"should_error,omitempty"`  // General error expected (decode or encode)
 	ShouldErrorOnEncode bool        `json:"should_error_on_encode,omitempty"`
 	ShouldErrorOnDecode bool        `json:"should_error_on_decode,omitempty"`
+	RoundTripOnly       bool        `json:"-"` // Generated property case: no expected bytes, checks Encode→Decode→Encode stability
+	BitLevel            bool        `json:"-"` // Expected output given as bits alone: compared bit by bit, bit count included
+}
+
+// ExpectsDecodeError reports whether the case's bytes should fail to decode
+func (tc *TestCase) ExpectsDecodeError() bool {
+	return tc.ShouldError || tc.Error != nil
 }
 
 // LoadTestSuite loads a single test suite from a JSON file
@@ -159,6 +167,7 @@ func convertBitsToBytes(cases []TestCase, bitOrder string) []TestCase {
 		// If test case has bits but no bytes, convert bits to bytes
 		if len(cases[i].Bits) > 0 && len(cases[i].Bytes) == 0 {
 			cases[i].Bytes = bitsToBytes(cases[i].Bits, bitOrder)
+			cases[i].BitLevel = true
 		}
 	}
 	return cases
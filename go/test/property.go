@@ -0,0 +1,349 @@
+// ABOUTME: Random value generation for round-trip property testing
+// ABOUTME: Produces schema-valid values so Encode→Decode→Encode stability can be checked without fixed vectors
+
+package test
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// DefaultPropertyCases is the number of random values generated per suite when
+// PROPERTY_TESTS is set to a non-numeric value (e.g. PROPERTY_TESTS=1 or =true).
+const DefaultPropertyCases = 20
+
+// maxPropertyDepth bounds recursion through type references so self-referential
+// schemas still produce finite values.
+const maxPropertyDepth = 6
+
+// maxPropertyItems bounds variable-length arrays and strings to keep generated
+// harness code small.
+const maxPropertyItems = 4
+
+// errPropertyUnsupported is returned when a schema uses a feature whose valid
+// values can't be derived from the schema alone (e.g. peek-based unions whose
+// discriminator lives inside the variant). Such suites are skipped.
+type errPropertyUnsupported struct {
+	feature string
+}
+
+func (e *errPropertyUnsupported) Error() string {
+	return fmt.Sprintf("property tests unsupported: %s", e.feature)
+}
+
+// PropertyConfig controls round-trip property test generation.
+type PropertyConfig struct {
+	Cases int   // Number of random values per suite (0 disables property tests)
+	Seed  int64 // Seed for the random source, printed in case descriptions for reproduction
+}
+
+// PropertyConfigFromEnv reads PROPERTY_TESTS and PROPERTY_SEED.
+// PROPERTY_TESTS may be a case count ("50") or any other non-empty value for the default count.
+// PROPERTY_SEED defaults to 1 so runs are reproducible unless explicitly varied.
+func PropertyConfigFromEnv() PropertyConfig {
+	cfg := PropertyConfig{Seed: 1}
+
+	raw := os.Getenv("PROPERTY_TESTS")
+	if raw == "" || raw == "0" || raw == "false" {
+		return cfg
+	}
+	cfg.Cases = DefaultPropertyCases
+	if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+		cfg.Cases = n
+	}
+
+	if seed := os.Getenv("PROPERTY_SEED"); seed != "" {
+		if n, err := strconv.ParseInt(seed, 10, 64); err == nil {
+			cfg.Seed = n
+		}
+	}
+	return cfg
+}
+
+// GeneratePropertyCases produces round-trip-only test cases with random values for the suite's test type.
+// Returns an error if the schema uses features the generator can't produce valid values for.
+func GeneratePropertyCases(suite *TestSuite, cfg PropertyConfig) ([]TestCase, error) {
+	types, ok := suite.Schema["types"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema has no types")
+	}
+
+	gen := &propertyGenerator{
+		rng:   rand.New(rand.NewSource(cfg.Seed)),
+		types: types,
+	}
+
+	var cases []TestCase
+	for i := 0; i < cfg.Cases; i++ {
+		value, err := gen.typeValue(suite.TestType, 0)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, TestCase{
+			Description:   fmt.Sprintf("property #%d (seed %d)", i, cfg.Seed),
+			Value:         value,
+			RoundTripOnly: true,
+		})
+	}
+	return cases, nil
+}
+
+type propertyGenerator struct {
+	rng   *rand.Rand
+	types map[string]interface{}
+}
+
+// typeValue generates a value for a named type definition
+func (g *propertyGenerator) typeValue(typeName string, depth int) (interface{}, error) {
+	if depth > maxPropertyDepth {
+		return nil, &errPropertyUnsupported{feature: fmt.Sprintf("recursion deeper than %d through %s", maxPropertyDepth, typeName)}
+	}
+
+	typeDef, ok := g.types[typeName].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("type %s not found in schema", typeName)
+	}
+
+	if instances, ok := typeDef["instances"].([]interface{}); ok && len(instances) > 0 {
+		return nil, &errPropertyUnsupported{feature: "instance fields"}
+	}
+
+	sequence, hasSequence := typeDef["sequence"].([]interface{})
+	if !hasSequence {
+		// Type alias or enum - the definition itself describes the element
+		if typeDefType, _ := typeDef["type"].(string); typeDefType == "enum" {
+			return g.enumValue(typeDef)
+		}
+		return g.elementValue(typeDef, depth+1)
+	}
+
+	result := make(map[string]interface{})
+	for _, fieldRaw := range sequence {
+		field, ok := fieldRaw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := field["name"].(string)
+
+		// Computed fields are filled in by the encoder; leave them out of the input value
+		if _, isComputed := field["computed"]; isComputed {
+			continue
+		}
+		if _, isConditional := field["conditional"]; isConditional {
+			return nil, &errPropertyUnsupported{feature: "conditional fields"}
+		}
+		if constVal, hasConst := field["const"]; hasConst {
+			result[name] = constVal
+			continue
+		}
+		if fieldType, _ := field["type"].(string); fieldType == "padding" {
+			continue
+		}
+		if _, refsField := field["length_field"]; refsField {
+			return nil, &errPropertyUnsupported{feature: "field-referenced lengths"}
+		}
+
+		value, err := g.elementValue(field, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+// elementValue generates a value for a field or element definition
+func (g *propertyGenerator) elementValue(def map[string]interface{}, depth int) (interface{}, error) {
+	elemType, _ := def["type"].(string)
+
+	switch elemType {
+	case "uint8", "uint16", "uint32", "int8", "int16", "int32":
+		return g.integerValue(elemType), nil
+	case "uint64":
+		return g.rng.Uint64(), nil
+	case "int64":
+		return int64(g.rng.Uint64()), nil
+	case "float32":
+		// Round through float32 so the value survives the narrowing conversion exactly
+		return float64(float32(g.rng.NormFloat64() * 1000)), nil
+	case "float64":
+		return g.rng.NormFloat64() * 1e6, nil
+	case "bool":
+		return g.rng.Intn(2) == 1, nil
+	case "bit", "int":
+		size := intAttr(def, "size", 1)
+		bits := g.bitsValue(size)
+		if signed, _ := def["signed"].(bool); signed || elemType == "int" {
+			// Sign-extend the top bit of the random pattern
+			return jsonNumber(int64(bits<<(64-size)) >> (64 - size)), nil
+		}
+		return jsonNumber(bits), nil
+	case "bitfield":
+		return g.bitfieldValue(def), nil
+	case "string":
+		return g.stringValue(def)
+	case "bytes":
+		n, err := g.collectionLength(def)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			items[i] = float64(g.rng.Intn(256))
+		}
+		return items, nil
+	case "array":
+		return g.arrayValue(def, depth)
+	case "optional":
+		if g.rng.Intn(2) == 0 {
+			return nil, nil
+		}
+		switch vt := def["value_type"].(type) {
+		case string:
+			return g.elementValue(map[string]interface{}{"type": vt}, depth)
+		case map[string]interface{}:
+			return g.elementValue(vt, depth)
+		}
+		return nil, fmt.Errorf("optional field missing value_type")
+	case "discriminated_union", "choice", "back_reference":
+		return nil, &errPropertyUnsupported{feature: elemType}
+	}
+
+	// Type reference
+	if _, ok := g.types[elemType]; ok {
+		return g.typeValue(elemType, depth)
+	}
+	return nil, &errPropertyUnsupported{feature: fmt.Sprintf("type %q", elemType)}
+}
+
+// integerValue returns a random value within the range of a fixed-width integer type.
+// Values are float64 to match what the JSON test loader produces.
+func (g *propertyGenerator) integerValue(intType string) interface{} {
+	switch intType {
+	case "uint8":
+		return float64(g.rng.Intn(math.MaxUint8 + 1))
+	case "uint16":
+		return float64(g.rng.Intn(math.MaxUint16 + 1))
+	case "uint32":
+		return float64(g.rng.Uint32())
+	case "int8":
+		return float64(int8(g.rng.Intn(math.MaxUint8 + 1)))
+	case "int16":
+		return float64(int16(g.rng.Intn(math.MaxUint16 + 1)))
+	default: // int32
+		return float64(int32(g.rng.Uint32()))
+	}
+}
+
+// enumValue picks one of an enum's numeric values. They are sorted first, since map
+// order would make the pick differ between runs of the same seed.
+func (g *propertyGenerator) enumValue(typeDef map[string]interface{}) (interface{}, error) {
+	variants, _ := typeDef["variants"].(map[string]interface{})
+	values := make([]float64, 0, len(variants))
+	for _, v := range variants {
+		if f, ok := v.(float64); ok {
+			values = append(values, f)
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("enum has no numeric variants")
+	}
+	sort.Float64s(values)
+	return values[g.rng.Intn(len(values))], nil
+}
+
+func (g *propertyGenerator) bitfieldValue(def map[string]interface{}) interface{} {
+	result := make(map[string]interface{})
+	fields, _ := def["fields"].([]interface{})
+	for _, fieldRaw := range fields {
+		field, ok := fieldRaw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := field["name"].(string)
+		result[name] = jsonNumber(g.bitsValue(intAttr(field, "size", 1)))
+	}
+	return result
+}
+
+// bitsValue returns a random size-bit unsigned value (size may be up to 64)
+func (g *propertyGenerator) bitsValue(size int) uint64 {
+	if size >= 64 {
+		return g.rng.Uint64()
+	}
+	return g.rng.Uint64() & (uint64(1)<<size - 1)
+}
+
+func (g *propertyGenerator) stringValue(def map[string]interface{}) (interface{}, error) {
+	n, err := g.collectionLength(def)
+	if err != nil {
+		return nil, err
+	}
+	// Printable ASCII only: valid for both ascii and utf8 encodings, never contains a
+	// null byte (null_terminated), and fixed strings are trimmed of nulls on decode.
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = byte(' ' + g.rng.Intn('~'-' '+1))
+	}
+	return string(buf), nil
+}
+
+func (g *propertyGenerator) arrayValue(def map[string]interface{}, depth int) (interface{}, error) {
+	items, ok := def["items"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("array missing items definition")
+	}
+	// A null terminator can't be distinguished from a zero-valued item
+	if kind, _ := def["kind"].(string); kind == "null_terminated" {
+		return nil, &errPropertyUnsupported{feature: "null_terminated arrays"}
+	}
+	n, err := g.collectionLength(def)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, n)
+	for i := range result {
+		item, err := g.elementValue(items, depth)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = item
+	}
+	return result, nil
+}
+
+// collectionLength picks an element count valid for the array/string/bytes kind
+func (g *propertyGenerator) collectionLength(def map[string]interface{}) (int, error) {
+	kind, _ := def["kind"].(string)
+	switch kind {
+	case "fixed":
+		return intAttr(def, "length", 0), nil
+	case "length_prefixed", "length_prefixed_items", "byte_length_prefixed", "null_terminated", "eof_terminated", "":
+		limit := maxPropertyItems
+		if lengthType, _ := def["length_type"].(string); lengthType == "uint8" && limit > math.MaxUint8 {
+			limit = math.MaxUint8
+		}
+		return g.rng.Intn(limit + 1), nil
+	}
+	return 0, &errPropertyUnsupported{feature: fmt.Sprintf("%s collections", kind)}
+}
+
+// jsonNumber returns float64 for values JSON can represent exactly, and the
+// int64/uint64 the BigInt loader produces for wider ones
+func jsonNumber[T int64 | uint64](n T) interface{} {
+	if n <= 1<<53 && (n >= 0 || -n <= 1<<53) {
+		return float64(n)
+	}
+	return n
+}
+
+func intAttr(def map[string]interface{}, key string, fallback int) int {
+	if f, ok := def[key].(float64); ok {
+		return int(f)
+	}
+	return fallback
+}
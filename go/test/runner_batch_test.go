@@ -39,10 +39,30 @@ func TestBinSchema(t *testing.T) {
 		t.Logf("Filtered to %d test suites matching '%s'", len(suites), filter)
 	}
 
-	// Compile and run all tests in one batch
-	resultMap, err := CompileAndTestBatch(suites)
+	// Support round-trip property tests (e.g., PROPERTY_TESTS=1 or PROPERTY_TESTS=100, PROPERTY_SEED=42)
+	// Random schema-valid values are appended to each suite as Encode→Decode→Encode checks
+	propertyConfig := PropertyConfigFromEnv()
+	if propertyConfig.Cases > 0 {
+		for _, suite := range suites {
+			if suite.SchemaValidationError || len(suite.TestCases) == 0 || isStringTypeAliasSuite(suite) {
+				continue
+			}
+			cases, err := GeneratePropertyCases(suite, propertyConfig)
+			if err != nil {
+				t.Logf("Skipping property tests for %s: %v", suite.Name, err)
+				continue
+			}
+			suite.TestCases = append(suite.TestCases, cases...)
+		}
+		t.Logf("Property tests enabled: %d cases per suite (seed %d)", propertyConfig.Cases, propertyConfig.Seed)
+	}
+
+	// Run all tests in one batch: compiled generated code by default, or the schema
+	// interpreter in-process with GO_TEST_EXECUTOR=interpret (no codegen or go run)
+	executorName, executor := ExecutorFromEnv()
+	resultMap, err := executor(suites)
 	if err != nil {
-		t.Fatalf("Failed to compile/run batched tests: %v", err)
+		t.Fatalf("Failed to run batched tests (%s executor): %v", executorName, err)
 	}
 
 	// Report results per suite
@@ -0,0 +1,52 @@
+// ABOUTME: Runs the round-trip tests GenerateGoTests generates for tests-json schemas
+// ABOUTME: Each suite's package gets a generated_test.go next to its generated code
+
+package test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/serialexp/binschema/codegen"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGeneratedTestStubs writes the code and test stubs generated for the smoke suites
+// to a module, as a user adopting them would, and runs go test over it. Compiling and
+// testing every suite takes minutes, so GENERATED_TESTS=1 is needed to run them all.
+func TestGeneratedTestStubs(t *testing.T) {
+	testsDir := filepath.Join("..", "..", "packages", "binschema", ".generated", "tests-json")
+	suites, err := LoadAllTestSuites(testsDir)
+	require.NoError(t, err, "Failed to load test suites")
+	smoke := os.Getenv("GENERATED_TESTS") == ""
+	if smoke {
+		suites = selectSmokeSuites(t, suites)
+	}
+	var buildable []*TestSuite
+	for _, suite := range suites {
+		if _, known := knownTypeErrors[suite.Name]; !known {
+			buildable = append(buildable, suite)
+		}
+	}
+
+	moduleDir := t.TempDir()
+	written, err := WriteTinyGoModule(moduleDir, buildable)
+	require.NoError(t, err)
+	if smoke {
+		require.Len(t, written, len(smokeSuites), "every smoke suite should be written")
+	}
+	for _, suite := range written {
+		stubs, err := codegen.GenerateGoTests(suite.Schema, suite.TestType, codegen.GenerateOptions{})
+		require.NoError(t, err, suite.Name)
+		path := filepath.Join(moduleDir, "suites", suitePackage(suite), "generated_test.go")
+		require.NoError(t, os.WriteFile(path, []byte(stubs), 0644))
+	}
+
+	cmd := exec.Command("go", "test", "./suites/...")
+	cmd.Dir = moduleDir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+}
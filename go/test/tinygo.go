@@ -0,0 +1,81 @@
+// ABOUTME: TinyGo builds of the Go code generated for test suites, for embedded users of the generator
+// ABOUTME: Each suite is a package of one module whose main keeps the root type's encoder and decoder
+
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/serialexp/binschema/codegen"
+)
+
+// tinyGoMain is the main of a suite's package: it decodes and re-encodes the root
+// type, so neither is dropped as dead code before the compiler has seen it
+func tinyGoMain(typeName string) string {
+	return fmt.Sprintf(`package main
+
+func main() {
+	value, err := Decode%s(nil)
+	if err == nil {
+		value.Encode()
+	}
+}
+`, typeName)
+}
+
+// initRuntimeModule makes dir a module depending on nothing but the binschema runtime
+// in this checkout. Unlike initBatchModule it needs no network: the go.sum of this
+// module covers the runtime's dependencies, so builds must run with -mod=mod.
+func initRuntimeModule(dir string) error {
+	root, err := filepath.Abs("..")
+	if err != nil {
+		return fmt.Errorf("failed to get abs path: %w", err)
+	}
+	goMod := "module tinygomodule\n\ngo 1.21\n\nrequire github.com/serialexp/binschema v0.0.0\n\nreplace github.com/serialexp/binschema => " + root + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0644); err != nil {
+		return fmt.Errorf("failed to write go.mod: %w", err)
+	}
+	goSum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		return fmt.Errorf("failed to read go.sum: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.sum"), goSum, 0644); err != nil {
+		return fmt.Errorf("failed to write go.sum: %w", err)
+	}
+	return nil
+}
+
+// WriteTinyGoModule makes dir a module of one package per suite, under suites/, holding
+// the code GenerateGo generates for it and tinyGoMain. It returns the suites written:
+// those GenerateGo refuses, or whose root type has no DecodeX of its own, are left out.
+// Builds in the module must run with -mod=mod.
+func WriteTinyGoModule(dir string, suites []*TestSuite) ([]*TestSuite, error) {
+	if err := initRuntimeModule(dir); err != nil {
+		return nil, err
+	}
+	var written []*TestSuite
+	for _, suite := range suites {
+		if suite.SchemaValidationError || suite.TestType == "" {
+			continue
+		}
+		code, err := codegen.GenerateGo(suite.Schema, suite.TestType)
+		if err != nil || !strings.Contains(code, fmt.Sprintf("func Decode%s(bytes []byte)", suite.TestType)) {
+			continue
+		}
+		pkgDir := filepath.Join(dir, "suites", suitePackage(suite))
+		if err := os.MkdirAll(pkgDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create suite package: %w", err)
+		}
+		files := map[string]string{"generated.go": code, "main.go": tinyGoMain(suite.TestType)}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(pkgDir, name), []byte(content), 0644); err != nil {
+				return nil, fmt.Errorf("failed to write %s: %w", name, err)
+			}
+		}
+		written = append(written, suite)
+	}
+	return written, nil
+}
@@ -0,0 +1,115 @@
+// ABOUTME: Builds the Go code generated for tests-json schemas in the TinyGo build mode
+// ABOUTME: Go builds it with -tags tinygo everywhere; TinyGo itself builds it when tinygo is on the PATH
+
+package test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// smokeSuites are the suites built by default, covering the broad areas of the
+// generator; building every suite takes minutes
+var smokeSuites = []string{
+	"bitfield_lsb_first",
+	"c_string",
+	"computed_crc32_byte_array",
+	"conditional_comparison",
+	"dns_compression_pointer",
+	"fixed_array_of_structs",
+	"float32_little_endian",
+	"instance_union",
+	"length_prefixed_items_structs",
+	"string_length_prefixed_uint16",
+}
+
+// selectSmokeSuites returns the suites named in smokeSuites, failing if one is missing
+func selectSmokeSuites(t *testing.T, suites []*TestSuite) []*TestSuite {
+	byName := make(map[string]*TestSuite, len(suites))
+	for _, suite := range suites {
+		byName[suite.Name] = suite
+	}
+	selected := make([]*TestSuite, 0, len(smokeSuites))
+	for _, name := range smokeSuites {
+		suite, ok := byName[name]
+		require.True(t, ok, "smoke suite %s not found", name)
+		selected = append(selected, suite)
+	}
+	return selected
+}
+
+// TestTinyGoBuild builds the code generated for the smoke suites in the runtime's
+// TinyGo build mode: with go build -tags tinygo, which selects the runtime files
+// TinyGo does, then with TinyGo if it's installed. TINYGO_BUILD=1 builds every suite,
+// TEST_FILTER those it matches. TINYGO_TARGET picks a TinyGo target ("wasm",
+// "pico"), the host by default.
+func TestTinyGoBuild(t *testing.T) {
+	_, lookErr := exec.LookPath("tinygo")
+	testsDir := filepath.Join("..", "..", "packages", "binschema", ".generated", "tests-json")
+	suites, err := LoadAllTestSuites(testsDir)
+	require.NoError(t, err, "Failed to load test suites")
+	smoke := false
+	if filter := os.Getenv("TEST_FILTER"); filter != "" {
+		filterRegex, err := regexp.Compile(filter)
+		require.NoError(t, err, "Invalid TEST_FILTER regex %q", filter)
+		var filtered []*TestSuite
+		for _, suite := range suites {
+			if filterRegex.MatchString(suite.Name) {
+				filtered = append(filtered, suite)
+			}
+		}
+		suites = filtered
+	} else if os.Getenv("TINYGO_BUILD") == "" {
+		suites, smoke = selectSmokeSuites(t, suites), true
+	}
+	var buildable []*TestSuite
+	for _, suite := range suites {
+		if _, known := knownTypeErrors[suite.Name]; !known {
+			buildable = append(buildable, suite)
+		}
+	}
+
+	moduleDir := t.TempDir()
+	buildable, err = WriteTinyGoModule(moduleDir, buildable)
+	require.NoError(t, err)
+	if smoke {
+		require.Len(t, buildable, len(smokeSuites), "every smoke suite should be written")
+	}
+	build := func(args ...string) (string, error) {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = moduleDir
+		cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+		output, err := cmd.CombinedOutput()
+		return string(output), err
+	}
+
+	t.Run("go", func(t *testing.T) {
+		// Several main packages build without linking, in one go
+		output, err := build("go", "build", "-tags", "tinygo", "./suites/...")
+		require.NoError(t, err, output)
+	})
+
+	t.Run("tinygo", func(t *testing.T) {
+		if lookErr != nil {
+			t.Skip("tinygo is not installed")
+		}
+		var mu sync.Mutex
+		inParallel(buildable, func(suite *TestSuite) {
+			args := []string{"tinygo", "build", "-o", filepath.Join(moduleDir, "bin", suitePackage(suite))}
+			if target := os.Getenv("TINYGO_TARGET"); target != "" {
+				args = append(args, "-target", target)
+			}
+			if output, err := build(append(args, "./suites/"+suitePackage(suite))...); err != nil {
+				mu.Lock()
+				t.Errorf("%s: %v\n%s", suite.Name, err, output)
+				mu.Unlock()
+			}
+		})
+	})
+}
@@ -124,7 +124,10 @@ describe("Go Code Generator", () => {
         FieldNamesTest: {
           sequence: [
             { name: "simple_field", type: "uint8" },
-            { name: "another_long_field_name", type: "uint16" }
+            { name: "another_long_field_name", type: "uint16" },
+            { name: "user_id", type: "uint32" },
+            { name: "urlPath", type: "uint8" },
+            { name: "pattern_5555", type: "uint8" }
           ]
         }
       }
@@ -136,6 +139,10 @@ describe("Go Code Generator", () => {
     expect(result.code).toContain("AnotherLongFieldName uint16");
     expect(result.code).toContain("m.SimpleField");
     expect(result.code).toContain("m.AnotherLongFieldName");
+    // Named as codegen.FieldName names them in the Go module
+    expect(result.code).toContain("UserID uint32");
+    expect(result.code).toContain("URLPath uint8");
+    expect(result.code).toContain("Pattern5555 uint8");
   });
 
   test("generates package and imports correctly", () => {
@@ -222,4 +229,28 @@ describe("Go Code Generator", () => {
     expect(result.code).toContain("func DecodeTypeA(");
     expect(result.code).toContain("func DecodeTypeB(");
   });
+
+  test("generates Equal and Hash for structs and enums", () => {
+    const schema: BinarySchema = {
+      config: {},
+      types: {
+        Reading: {
+          sequence: [{ name: "level", type: "float32" }]
+        },
+        Direction: {
+          type: "enum",
+          repr: "uint8",
+          variants: { North: 0, South: 1 }
+        }
+      }
+    };
+
+    const result = generateGo(schema, "Reading");
+
+    expect(result.code).toContain("func (m *Reading) Equal(other *Reading) bool {");
+    expect(result.code).toContain("return runtime.ValuesEqual(m, other)");
+    expect(result.code).toContain("func (m *Reading) Hash() uint64 {");
+    expect(result.code).toContain("return runtime.HashValue(m)");
+    expect(result.code).toContain("func (m *Direction) Equal(other *Direction) bool {");
+  });
 });
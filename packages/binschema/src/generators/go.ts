@@ -215,6 +215,46 @@ function parseCorrespondingTarget(target: string): { levelsUp: number; arrayPath
   };
 }
 
+/**
+ * The runtime.PositionSite constants of the file being generated: one per array/type
+ * pair whose item positions or occurrences the encoders track, keyed "array_Type".
+ * Numbered while generating so the encode path indexes the context's site table
+ * instead of building string keys. Reset by generateGo.
+ */
+let positionSites = new Map<string, string>();
+
+/**
+ * Returns the name of the PositionSite constant for an array/type pair, assigning one
+ * on first use
+ */
+function positionSite(arrayPath: string, typeName: string): string {
+  const key = `${arrayPath}_${typeName}`;
+  let name = positionSites.get(key);
+  if (!name) {
+    name = `positionSite_${arrayPath.replace(/\W/g, "_")}_${typeName}`;
+    positionSites.set(key, name);
+  }
+  return name;
+}
+
+/**
+ * Generates the const block numbering the sites assigned by positionSite
+ */
+function generatePositionSites(): string[] {
+  if (positionSites.size === 0) return [];
+  const lines: string[] = [];
+  lines.push(`// Array/type pairs whose item positions and occurrences the encoders track`);
+  lines.push(`const (`);
+  let first = true;
+  for (const name of positionSites.values()) {
+    lines.push(first ? `\t${name} runtime.PositionSite = iota` : `\t${name}`);
+    first = false;
+  }
+  lines.push(`)`);
+  lines.push(``);
+  return lines;
+}
+
 /**
  * Detect which arrays in the schema need position tracking for first/last selectors
  * Returns a map of array field names to the set of types that need tracking
@@ -723,13 +763,13 @@ function generateComputedFieldEncoding(
     if (firstLastInfo) {
       // first/last selector - look up position directly from context
       const { arrayPath, filterType, selector } = firstLastInfo;
-      const positionKey = `${arrayPath}_${filterType}`;
+      const site = positionSite(arrayPath, filterType);
       lines.push(`${indent}// position_of with ${selector}<${filterType}> selector`);
 
       if (selector === "first") {
-        lines.push(`${indent}${computedVarName}Pos, ${computedVarName}Ok := ctx.GetFirstPosition("${positionKey}")`);
+        lines.push(`${indent}${computedVarName}Pos, ${computedVarName}Ok := ctx.FirstSitePosition(${site})`);
       } else {
-        lines.push(`${indent}${computedVarName}Pos, ${computedVarName}Ok := ctx.GetLastPosition("${positionKey}")`);
+        lines.push(`${indent}${computedVarName}Pos, ${computedVarName}Ok := ctx.LastSitePosition(${site})`);
       }
 
       // Use sentinel value when position not found (empty array case)
@@ -749,7 +789,7 @@ function generateComputedFieldEncoding(
       // corresponding selector - look up position using type occurrence index
       // For same-array correlation: Nth instance of CurrentType -> Nth instance of TargetType
       const { arrayPath, filterType } = correspondingInfo;
-      const positionKey = `${arrayPath}_${filterType}`;
+      const site = positionSite(arrayPath, filterType);
       lines.push(`${indent}// position_of with corresponding<${filterType}> selector`);
 
       // Use type occurrence index for same-array correlation
@@ -762,12 +802,12 @@ function generateComputedFieldEncoding(
         lines.push(`${indent}\treturn nil, fmt.Errorf("array iteration context not found for ${arrayPath}")`);
         lines.push(`${indent}}`);
         lines.push(`${indent}// Get type occurrence index for current type (${containingTypeName})`);
-        lines.push(`${indent}${computedVarName}TypeIdx := ctx.GetTypeIndex("${arrayPath}", "${containingTypeName}")`);
+        lines.push(`${indent}${computedVarName}TypeIdx := ctx.SiteTypeIndex(${positionSite(arrayPath, containingTypeName)})`);
         lines.push(`${indent}if ${computedVarName}TypeIdx == 0 {`);
         lines.push(`${indent}\treturn nil, fmt.Errorf("type occurrence index not found for ${containingTypeName} in ${arrayPath}")`);
         lines.push(`${indent}}`);
         lines.push(`${indent}${computedVarName}CorrelationIdx := ${computedVarName}TypeIdx - 1 // Counter was incremented before encoding`);
-        lines.push(`${indent}${computedVarName}Pos, ${computedVarName}PosOk := ctx.GetPosition("${positionKey}", ${computedVarName}CorrelationIdx)`);
+        lines.push(`${indent}${computedVarName}Pos, ${computedVarName}PosOk := ctx.SitePosition(${site}, ${computedVarName}CorrelationIdx)`);
         lines.push(`${indent}if !${computedVarName}PosOk {`);
         lines.push(`${indent}\treturn nil, fmt.Errorf("position not found for corresponding<${filterType}> at index %d in ${arrayPath}", ${computedVarName}CorrelationIdx)`);
         lines.push(`${indent}}`);
@@ -777,7 +817,7 @@ function generateComputedFieldEncoding(
         lines.push(`${indent}if !${computedVarName}IterOk {`);
         lines.push(`${indent}\treturn nil, fmt.Errorf("array iteration context not found for ${arrayPath}")`);
         lines.push(`${indent}}`);
-        lines.push(`${indent}${computedVarName}Pos, ${computedVarName}PosOk := ctx.GetPosition("${positionKey}", ${computedVarName}ArrayIter.Index)`);
+        lines.push(`${indent}${computedVarName}Pos, ${computedVarName}PosOk := ctx.SitePosition(${site}, ${computedVarName}ArrayIter.Index)`);
         lines.push(`${indent}if !${computedVarName}PosOk {`);
         lines.push(`${indent}\treturn nil, fmt.Errorf("position not found for corresponding<${filterType}> at index %d in ${arrayPath}", ${computedVarName}ArrayIter.Index)`);
         lines.push(`${indent}}`);
@@ -995,7 +1035,7 @@ function generateComputedFieldEncoding(
     lines.push(`${indent}if ${computedVarName}IterOk {`);
     lines.push(`${indent}\t// Same-array correlation: use type occurrence index`);
     if (containingTypeName) {
-      lines.push(`${indent}\t${computedVarName}TypeIdx := ctx.GetTypeIndex("${arrayPath}", "${containingTypeName}")`);
+      lines.push(`${indent}\t${computedVarName}TypeIdx := ctx.SiteTypeIndex(${positionSite(arrayPath, containingTypeName)})`);
       lines.push(`${indent}\tif ${computedVarName}TypeIdx == 0 {`);
       lines.push(`${indent}\t\treturn nil, fmt.Errorf("type occurrence index not found for ${containingTypeName} in ${arrayPath}")`);
       lines.push(`${indent}\t}`);
@@ -1192,14 +1232,14 @@ function generateComputedFieldEncoding(
       if (firstLastInfo) {
         // first/last selector - look up position from context
         const { arrayPath, filterType, selector } = firstLastInfo;
-        const positionKey = `${arrayPath}_${filterType}`;
+        const site = positionSite(arrayPath, filterType);
         lines.push(`${indent}// position_of with ${selector} selector - looking up from context`);
         lines.push(`${indent}_ = ${computedVarName}Raw // Using context for position lookup`);
 
         if (selector === "first") {
-          lines.push(`${indent}${computedVarName}Pos, ${computedVarName}Ok := ctx.GetFirstPosition("${positionKey}")`);
+          lines.push(`${indent}${computedVarName}Pos, ${computedVarName}Ok := ctx.FirstSitePosition(${site})`);
         } else {
-          lines.push(`${indent}${computedVarName}Pos, ${computedVarName}Ok := ctx.GetLastPosition("${positionKey}")`);
+          lines.push(`${indent}${computedVarName}Pos, ${computedVarName}Ok := ctx.LastSitePosition(${site})`);
         }
         lines.push(`${indent}if !${computedVarName}Ok {`);
         lines.push(`${indent}\treturn nil, fmt.Errorf("position not found for ${selector}<${filterType}> in ${arrayPath}")`);
@@ -1208,7 +1248,7 @@ function generateComputedFieldEncoding(
       } else if (correspondingInfo) {
         // corresponding selector - look up position using type occurrence index
         const { arrayPath, filterType } = correspondingInfo;
-        const positionKey = `${arrayPath}_${filterType}`;
+        const site = positionSite(arrayPath, filterType);
         lines.push(`${indent}// position_of with corresponding selector - looking up from context`);
         lines.push(`${indent}_ = ${computedVarName}Raw // Using context for position lookup`);
 
@@ -1220,12 +1260,12 @@ function generateComputedFieldEncoding(
           lines.push(`${indent}\treturn nil, fmt.Errorf("array iteration context not found for ${arrayPath}")`);
           lines.push(`${indent}}`);
           lines.push(`${indent}// Get type occurrence index for current type (${containingTypeName})`);
-          lines.push(`${indent}${computedVarName}TypeIdx := ctx.GetTypeIndex("${arrayPath}", "${containingTypeName}")`);
+          lines.push(`${indent}${computedVarName}TypeIdx := ctx.SiteTypeIndex(${positionSite(arrayPath, containingTypeName)})`);
           lines.push(`${indent}if ${computedVarName}TypeIdx == 0 {`);
           lines.push(`${indent}\treturn nil, fmt.Errorf("type occurrence index not found for ${containingTypeName} in ${arrayPath}")`);
           lines.push(`${indent}}`);
           lines.push(`${indent}${computedVarName}CorrelationIdx := ${computedVarName}TypeIdx - 1 // Counter was incremented before encoding`);
-          lines.push(`${indent}${computedVarName}Pos, ${computedVarName}PosOk := ctx.GetPosition("${positionKey}", ${computedVarName}CorrelationIdx)`);
+          lines.push(`${indent}${computedVarName}Pos, ${computedVarName}PosOk := ctx.SitePosition(${site}, ${computedVarName}CorrelationIdx)`);
           lines.push(`${indent}if !${computedVarName}PosOk {`);
           lines.push(`${indent}\treturn nil, fmt.Errorf("position not found for corresponding<${filterType}> at index %d in ${arrayPath}", ${computedVarName}CorrelationIdx)`);
           lines.push(`${indent}}`);
@@ -1235,7 +1275,7 @@ function generateComputedFieldEncoding(
           lines.push(`${indent}if !${computedVarName}IterOk {`);
           lines.push(`${indent}\treturn nil, fmt.Errorf("array iteration context not found for ${arrayPath}")`);
           lines.push(`${indent}}`);
-          lines.push(`${indent}${computedVarName}Pos, ${computedVarName}PosOk := ctx.GetPosition("${positionKey}", ${computedVarName}ArrayIter.Index)`);
+          lines.push(`${indent}${computedVarName}Pos, ${computedVarName}PosOk := ctx.SitePosition(${site}, ${computedVarName}ArrayIter.Index)`);
           lines.push(`${indent}if !${computedVarName}PosOk {`);
           lines.push(`${indent}\treturn nil, fmt.Errorf("position not found for corresponding<${filterType}> at index %d in ${arrayPath}", ${computedVarName}ArrayIter.Index)`);
           lines.push(`${indent}}`);
@@ -1547,6 +1587,7 @@ export function generateGo(
   }
 
   const lines: string[] = [];
+  positionSites = new Map();
 
   // Determine default endianness and bit order
   const defaultEndianness = schema.config?.endianness || "big_endian";
@@ -1587,38 +1628,46 @@ export function generateGo(
     lines.push(...generateChoiceInterface(choiceName));
   }
 
-  // Generate all types in the schema (Go doesn't require forward declarations)
+  // Generate all types in the schema (Go doesn't require forward declarations).
+  // They go in typeLines so the position sites they use can be declared before them.
+  const typeLines: string[] = [];
   for (const [name, typeDef] of Object.entries(schema.types)) {
     // Check if this is a composite type (has sequence) or type alias
     if ("sequence" in typeDef) {
       // Composite type with fields
       const typeDefAny = typeDef as any;
       const instances = typeDefAny.instances || [];
-      lines.push(...generateStruct(name, typeDef.sequence, instances, schema));
-      lines.push(...generateEncodeMethod(name, typeDef.sequence, defaultEndianness, defaultBitOrder, schema));
-      lines.push(...generateCalculateSizeMethod(name, typeDef.sequence, schema));
-      lines.push(...generateDecodeFunction(name, typeDef.sequence, defaultEndianness, schema, defaultBitOrder, instances));
+      // A type's own bit_order overrides the config's
+      const bitOrder = typeDefAny.bit_order || defaultBitOrder;
+      typeLines.push(...generateStruct(name, typeDef.sequence, instances, schema));
+      typeLines.push(...generateEncodeMethod(name, typeDef.sequence, defaultEndianness, bitOrder, schema));
+      typeLines.push(...generateCalculateSizeMethod(name, typeDef.sequence, schema));
+      typeLines.push(...generateEqualMethods(name));
+      typeLines.push(...generateDecodeFunction(name, typeDef.sequence, defaultEndianness, schema, bitOrder, instances));
     } else if (isEnumType(typeDef)) {
       // Enum type - generate Go typed constants
-      lines.push(...generateGoEnumType(name, typeDef as any, defaultEndianness, defaultBitOrder));
+      typeLines.push(...generateGoEnumType(name, typeDef as any, defaultEndianness, defaultBitOrder));
     } else if ("type" in typeDef) {
       // Type alias or discriminated union
       if ((typeDef as any).type === "discriminated_union") {
         // Discriminated union type
-        lines.push(...generateDiscriminatedUnion(name, typeDef as any, defaultEndianness, schema, defaultBitOrder));
+        typeLines.push(...generateDiscriminatedUnion(name, typeDef as any, defaultEndianness, schema, defaultBitOrder));
       } else {
         // Regular type alias - generate Go type alias
-        lines.push(...generateTypeAlias(name, typeDef as any, defaultEndianness, schema, defaultBitOrder));
+        typeLines.push(...generateTypeAlias(name, typeDef as any, defaultEndianness, schema, defaultBitOrder));
       }
     } else if ("variants" in typeDef) {
       // Discriminated union (old format without "type" field)
-      lines.push(...generateDiscriminatedUnion(name, typeDef as any, defaultEndianness, schema, defaultBitOrder));
+      typeLines.push(...generateDiscriminatedUnion(name, typeDef as any, defaultEndianness, schema, defaultBitOrder));
     } else {
       // Unknown type definition
       throw new Error(`Unknown type definition for ${name}: ${JSON.stringify(typeDef)}`);
     }
   }
 
+  lines.push(...generatePositionSites());
+  lines.push(...typeLines);
+
   // Choice decode logic is now inlined at each call site - no shared function needed
 
   return {
@@ -1693,6 +1742,8 @@ function generateGoEnumType(name: string, typeDef: any, defaultEndianness: strin
   lines.push(`}`);
   lines.push(``);
 
+  lines.push(...generateEqualMethods(goName));
+
   // Decode function (standalone, from bytes)
   lines.push(`func Decode${goName}(data []byte) (*${goName}, error) {`);
   lines.push(`\tdecoder := runtime.NewBitStreamDecoder(data, runtime.${runtimeBitOrder})`);
@@ -1728,6 +1779,26 @@ function generateGoEnumType(name: string, typeDef: any, defaultEndianness: strin
   return lines;
 }
 
+/**
+ * Generates Equal and Hash, comparing and hashing values as runtime.ValuesEqual and
+ * runtime.HashValue do: floats bit for bit, and nil slices equal to empty ones
+ */
+function generateEqualMethods(name: string): string[] {
+  const lines: string[] = [];
+  lines.push(`// Equal reports whether m and other hold the same values. Floats are compared bit for`);
+  lines.push(`// bit, so NaN equals itself, and a nil slice equals an empty one.`);
+  lines.push(`func (m *${name}) Equal(other *${name}) bool {`);
+  lines.push(`\treturn runtime.ValuesEqual(m, other)`);
+  lines.push(`}`);
+  lines.push(``);
+  lines.push(`// Hash returns a hash of m that is the same for values Equal reports equal`);
+  lines.push(`func (m *${name}) Hash() uint64 {`);
+  lines.push(`\treturn runtime.HashValue(m)`);
+  lines.push(`}`);
+  lines.push(``);
+  return lines;
+}
+
 /**
  * Generates a type alias
  */
@@ -1754,6 +1825,7 @@ function generateTypeAlias(name: string, typeDef: any, defaultEndianness: string
   lines.push(...generateStruct(name, [field], undefined, schema));
   lines.push(...generateEncodeMethod(name, [field], defaultEndianness, defaultBitOrder, schema));
   lines.push(...generateCalculateSizeMethod(name, [field], schema));
+  lines.push(...generateEqualMethods(name));
   lines.push(...generateDecodeFunction(name, [field], defaultEndianness, schema, defaultBitOrder));
 
   return lines;
@@ -2293,14 +2365,14 @@ function generateEncodeMethod(name: string, fields: Field[], defaultEndianness:
         for (const typeName of typesToTrack) {
           const goTypeName = toGoTypeName(typeName);
           lines.push(`\t\tif _, ok := ${field.name}_prepass_item.(*${goTypeName}); ok {`);
-          lines.push(`\t\t\tchildCtx.TrackPosition("${field.name}_${typeName}", ${field.name}_offset)`);
+          lines.push(`\t\t\tchildCtx.TrackSitePosition(${positionSite(field.name, typeName)}, ${field.name}_offset)`);
           lines.push(`\t\t}`);
         }
       } else {
         // For single-type arrays, all items are the same type
         const itemTypeName = items.type;
         if (typesToTrack.has(itemTypeName)) {
-          lines.push(`\t\tchildCtx.TrackPosition("${field.name}_${itemTypeName}", ${field.name}_offset)`);
+          lines.push(`\t\tchildCtx.TrackSitePosition(${positionSite(field.name, itemTypeName)}, ${field.name}_offset)`);
         }
       }
 
@@ -3343,7 +3415,7 @@ function generateEncodeArrayWithCorresponding(
     const isStringType = choiceTypeDef && (choiceTypeDef as any).type === "string";
 
     lines.push(`${indent}\tcase *${goTypeName}:`);
-    lines.push(`${indent}\t\t${arrName}_iterCtx.IncrementTypeIndex("${arrName}", "${choice.type}")`);
+    lines.push(`${indent}\t\t${arrName}_iterCtx.IncrementSiteTypeIndex(${positionSite(arrName, choice.type)})`);
 
     // For string types (not back_references), record the value in compression dictionary
     // before encoding so that back_reference types can reference them later
@@ -5505,22 +5577,63 @@ function mapFieldToGoType(field: Field, parentTypeName?: string, schema?: Binary
   }
 }
 
+// Words toGoFieldName writes in capitals, as codegen.FieldName in the Go module does
+const GO_FIELD_ACRONYMS = new Set(["id", "url"]);
+
 /**
- * Converts a field name to Go exported field name (PascalCase)
+ * Converts a field name to Go exported field name (PascalCase), as codegen.FieldName
+ * does so both generators and the Go test harness agree: the words between
+ * underscores, hyphens and camelCase humps, each with its first letter upper-cased
+ * (pattern_5555 → Pattern5555), and id and url in capitals (user_id → UserID).
+ * A name that would stay unexported (__1st) gets an X before it.
  */
 function toGoFieldName(name: string): string {
-  // Convert snake_case or camelCase to PascalCase
-  // Handle numbers properly: pattern_5555 → Pattern5555
-  return name
-    .split(/[_-]/)
-    .map(part => {
-      if (!part) return "";
-      // If part is all digits, keep as-is
-      if (/^\d+$/.test(part)) return part;
-      // Otherwise capitalize first letter
-      return part.charAt(0).toUpperCase() + part.slice(1);
-    })
-    .join("");
+  let result = "";
+  for (const part of name.split(/[_-]/)) {
+    if (!part) continue;
+    for (const word of camelWords(part)) {
+      if (GO_FIELD_ACRONYMS.has(word.toLowerCase())) {
+        result += word.toUpperCase();
+        continue;
+      }
+      const first = String.fromCodePoint(word.codePointAt(0)!);
+      result += upperRune(first) + word.slice(first.length);
+    }
+  }
+  if (!result) return result;
+  const first = String.fromCodePoint(result.codePointAt(0)!);
+  return /^\p{Lu}$/u.test(upperRune(first)) ? upperRune(first) + result.slice(first.length) : "X" + result;
+}
+
+/**
+ * Upper-cases one character, keeping it when its upper case is several (ß)
+ */
+function upperRune(c: string): string {
+  const upper = c.toUpperCase();
+  return Array.from(upper).length === 1 ? upper : c;
+}
+
+/**
+ * Splits a camelCase name into its words, keeping runs of capitals together:
+ * urlPath → url, Path and HTTPServer → HTTP, Server
+ */
+function camelWords(s: string): string[] {
+  const runes = Array.from(s);
+  const isUpper = (c: string) => /^\p{Lu}$/u.test(c);
+  const isLower = (c: string) => /^\p{Ll}$/u.test(c);
+  const isDigit = (c: string) => /^\p{Nd}$/u.test(c);
+  const words: string[] = [];
+  let start = 0;
+  for (let i = 1; i < runes.length; i++) {
+    if (!isUpper(runes[i])) continue;
+    const prev = runes[i - 1];
+    if (isLower(prev) || isDigit(prev) || (isUpper(prev) && i + 1 < runes.length && isLower(runes[i + 1]))) {
+      words.push(runes.slice(start, i).join(""));
+      start = i;
+    }
+  }
+  words.push(runes.slice(start).join(""));
+  return words;
 }
 
 /**
@@ -142,10 +142,12 @@ function generateTypeCode(
     return generateTypeAliasCode(typeName, typeDef, schema, globalEndianness, globalBitOrder);
   }
 
-  // Composite type - generate interfaces (Input/Output), encoder, and decoder
+  // Composite type - generate interfaces (Input/Output), encoder, and decoder,
+  // packing bits in the type's own bit_order when it overrides the config's
+  const typeBitOrder = typeDefAny.bit_order || globalBitOrder;
   const interfaceCode = generateInterfaces(typeName, typeDef, schema);
-  const encoderCode = generateEncoder(typeName, typeDef, schema, globalEndianness, globalBitOrder, addEncoderLogs);
-  const decoderCode = generateDecoder(typeName, typeDef, schema, globalEndianness, globalBitOrder, addTraceLogs);
+  const encoderCode = generateEncoder(typeName, typeDef, schema, globalEndianness, typeBitOrder, addEncoderLogs);
+  const decoderCode = generateDecoder(typeName, typeDef, schema, globalEndianness, typeBitOrder, addTraceLogs);
 
   const sections = [interfaceCode];
   const enumCode = generateDiscriminatedUnionEnumsForFields(typeName, fields);
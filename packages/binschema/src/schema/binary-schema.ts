@@ -2050,6 +2050,9 @@ const CompositeTypeSchema = z.object({
   instances: z.array(PositionFieldSchema).optional().meta({
     description: "Position-based fields (lazy-evaluated when accessed). Requires seekable input."
   }),
+  bit_order: BitOrderSchema.optional().meta({
+    description: "Bit packing of this type, overriding config.bit_order"
+  }),
   description: z.string().optional(),
 });
 
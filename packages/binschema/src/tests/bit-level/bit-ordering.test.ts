@@ -111,3 +111,98 @@ export const bitOrderComparisonTestSuite = defineTestSuite({
     },
   ]
 });
+
+/**
+ * Test suite for a type overriding the config's bit order
+ *
+ * The config packs LSB-first, but the type's own bit_order packs it MSB-first,
+ * giving the bytes of bit_order_comparison
+ */
+export const typeBitOrderMsbOverrideTestSuite = defineTestSuite({
+  name: "bit_order_type_override_msb_first",
+  description: "Type bit_order msb_first overriding config lsb_first",
+
+  schema: {
+    config: {
+      bit_order: "lsb_first",
+    },
+    types: {
+      "Register": {
+        bit_order: "msb_first",
+        sequence: [
+          { name: "a", type: "bit", size: 2 },
+          { name: "b", type: "bit", size: 3 },
+          { name: "c", type: "bit", size: 3 },
+        ]
+      }
+    }
+  },
+
+  test_type: "Register",
+
+  test_cases: [
+    {
+      description: "a=0b11, b=0b010, c=0b101 (MSB-first packing)",
+      value: { a: 0b11, b: 0b010, c: 0b101 },
+      bits: [
+        1,1,     // a = 0b11
+        0,1,0,   // b = 0b010
+        1,0,1,   // c = 0b101
+      ],
+      bytes: [0xD5], // 11010101 (packed left-to-right)
+    },
+  ]
+});
+
+/**
+ * Test suite for a type overriding the default bit order
+ *
+ * The config leaves bits MSB-first, but the type's own bit_order packs it
+ * LSB-first: each value is written from its least significant bit, filling the
+ * byte from the right
+ */
+export const typeBitOrderLsbOverrideTestSuite = defineTestSuite({
+  name: "bit_order_type_override_lsb_first",
+  description: "Type bit_order lsb_first overriding the default msb_first",
+
+  schema: {
+    config: {
+      bit_order: "msb_first",
+    },
+    types: {
+      "Register": {
+        bit_order: "lsb_first",
+        sequence: [
+          { name: "a", type: "bit", size: 2 },
+          { name: "b", type: "bit", size: 3 },
+          { name: "c", type: "bit", size: 3 },
+        ]
+      }
+    }
+  },
+
+  test_type: "Register",
+
+  test_cases: [
+    {
+      description: "a=0b11, b=0b010, c=0b101 (LSB-first packing)",
+      value: { a: 0b11, b: 0b010, c: 0b101 },
+      bits: [
+        1,1,     // a = 0b11, bit 0 first
+        0,1,0,   // b = 0b010, bit 0 first
+        1,0,1,   // c = 0b101, bit 0 first
+      ],
+      bytes: [0xAB], // 10101011 (packed right-to-left)
+    },
+    {
+      description: "a=0b01, b=0b100, c=0b000 (LSB-first packing)",
+      value: { a: 0b01, b: 0b100, c: 0b000 },
+      bits: [
+        1,0,     // a = 0b01, bit 0 first
+        0,0,1,   // b = 0b100, bit 0 first
+        0,0,0,   // c = 0b000
+      ],
+      bytes: [0x11], // 00010001 (packed right-to-left)
+    },
+  ]
+});
@@ -37,12 +37,14 @@ export const simpleStructTestSuite = defineTestSuite({
       value: { x: 10, y: 20 },
       decoded_value: { x: 10, y: 20 },
       bytes: [0x00, 0x0A, 0x00, 0x14],
+      chunkSizes: [3, 1], // second chunk splits y
     },
     {
       description: "Point (0x1234, 0x5678)",
       value: { x: 0x1234, y: 0x5678 },
       decoded_value: { x: 0x1234, y: 0x5678 },
       bytes: [0x12, 0x34, 0x56, 0x78],
+      chunkSizes: [1, 1, 1, 1], // one byte at a time
     },
   ]
 });
@@ -83,6 +85,7 @@ export const mixedFieldsStructTestSuite = defineTestSuite({
       description: "Version 2, flags 0xFF, length 1024",
       value: { version: 2, flags: 0xFF, length: 1024 },
       bytes: [0x02, 0xFF, 0x00, 0x00, 0x04, 0x00],
+      chunkSizes: [1, 2, 3], // chunks end inside length
     },
   ]
 });
@@ -60,6 +60,7 @@ export const stringTestSuite = defineTestSuite({
         0x00, 0x00, 0x00, 0x05, // length = 5
         0x48, 0x65, 0x6C, 0x6C, 0x6F, // 'Hello'
       ],
+      chunkSizes: [2, 5, 2], // chunks end inside the length and the data
     },
     {
       description: "UTF-8 emoji '👋' (U+1F44B = 0xF0 0x9F 0x91 0x8B)",
@@ -0,0 +1,4354 @@
+// Code generated by website-examples from dns.schema.json. DO NOT EDIT.
+
+// Code generated by binschema 0.6.0. DO NOT EDIT.
+
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/serialexp/binschema/runtime"
+)
+
+// AAAA_Record: AAAA record RDATA - IPv6 address (TYPE=28, CLASS=IN)
+type AAAA_Record struct {
+	// High 64 bits of IPv6 address
+	Address_high uint64
+	// Low 64 bits of IPv6 address
+	Address_low uint64
+}
+
+func (m *AAAA_Record) Encode() ([]byte, error) {
+	return m.EncodeWithContext(nil)
+}
+
+// EncodeWithLimit encodes like Encode, but fails with runtime.ErrOutputTooLarge as
+// soon as the output passes maxBytes, so a message too large to send isn't encoded
+// to the end first
+func (m *AAAA_Record) EncodeWithLimit(maxBytes int) ([]byte, error) {
+	return m.EncodeWithContext(runtime.NewEncodingContext(runtime.OutputLimit(maxBytes)))
+}
+
+func (m *AAAA_Record) EncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error) {
+	encoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	encoder.SetLimit(ctx.OutputRemaining())
+
+	encoder.WriteUint64(m.Address_high, runtime.BigEndian)
+	encoder.WriteUint64(m.Address_low, runtime.BigEndian)
+
+	return encoder.Result()
+}
+
+func DecodeAAAA_Record(bytes []byte) (*AAAA_Record, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodeAAAA_RecordWithDecoder(decoder, nil)
+}
+
+func decodeAAAA_RecordWithDecoder(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext) (*AAAA_Record, error) {
+	return decodeAAAA_RecordInto(decoder, ctx, runtime.ArenaNew[AAAA_Record](decoder.Arena))
+}
+
+func decodeAAAA_RecordInto(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *AAAA_Record) (*AAAA_Record, error) {
+	if decoder.Trace != nil {
+		return decodeAAAA_RecordIntoTraced(decoder, ctx, result)
+	}
+	const traceEnabled = runtime.TraceEnabled
+
+	*result = AAAA_Record{}
+
+	if span, ok := decoder.PeekAligned(16); ok && !traceEnabled {
+		decodeAAAA_RecordFrom(span, result)
+		decoder.SkipBytes(16)
+		return result, nil
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("address_high")
+	}
+	address_high, err := decoder.ReadUint64(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	result.Address_high = address_high
+	if traceEnabled {
+		decoder.TraceLeave(result.Address_high)
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("address_low")
+	}
+	address_low, err := decoder.ReadUint64(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	result.Address_low = address_low
+	if traceEnabled {
+		decoder.TraceLeave(result.Address_low)
+	}
+
+	return result, nil
+}
+
+// decodeAAAA_RecordIntoTraced is decodeAAAA_RecordInto recording each read in decoder.Trace
+func decodeAAAA_RecordIntoTraced(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *AAAA_Record) (*AAAA_Record, error) {
+	const traceEnabled = true
+
+	*result = AAAA_Record{}
+
+	if span, ok := decoder.PeekAligned(16); ok && !traceEnabled {
+		decodeAAAA_RecordFrom(span, result)
+		decoder.SkipBytes(16)
+		return result, nil
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("address_high")
+	}
+	address_high, err := decoder.ReadUint64(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	result.Address_high = address_high
+	if traceEnabled {
+		decoder.TraceLeave(result.Address_high)
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("address_low")
+	}
+	address_low, err := decoder.ReadUint64(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	result.Address_low = address_low
+	if traceEnabled {
+		decoder.TraceLeave(result.Address_low)
+	}
+
+	return result, nil
+}
+
+// decodeAAAA_RecordFrom decodes a AAAA_Record from the first 16 bytes of span, which the caller has checked are there
+func decodeAAAA_RecordFrom(span []byte, result *AAAA_Record) {
+	_ = span[15]
+	result.Address_high = binary.BigEndian.Uint64(span[0:])
+	result.Address_low = binary.BigEndian.Uint64(span[8:])
+}
+
+// String returns a readable one-line rendering of AAAA_Record using schema field names
+func (m *AAAA_Record) String() string {
+	if m == nil {
+		return "nil"
+	}
+	f := &runtime.Formatter{}
+	m.format(f)
+	return f.String()
+}
+
+// GoString returns AAAA_Record as a Go composite literal, for %#v
+func (m *AAAA_Record) GoString() string {
+	if m == nil {
+		return "(*AAAA_Record)(nil)"
+	}
+	f := &runtime.Formatter{GoSyntax: true}
+	f.Pointer()
+	m.format(f)
+	return f.String()
+}
+
+func (m *AAAA_Record) format(f *runtime.Formatter) {
+	f.BeginStruct("AAAA_Record")
+	f.Field("address_high", "Address_high")
+	f.Value(m.Address_high)
+	f.Field("address_low", "Address_low")
+	f.Value(m.Address_low)
+	f.EndStruct()
+}
+
+// Equal reports whether m and other hold the same values. Floats are compared bit for
+// bit, so NaN equals itself, and a nil slice equals an empty one. A lazy field that
+// fails to decode equals nothing.
+func (m *AAAA_Record) Equal(other *AAAA_Record) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if m.Address_high != other.Address_high {
+		return false
+	}
+	if m.Address_low != other.Address_low {
+		return false
+	}
+	return true
+}
+
+// Hash returns a hash of m that is the same for values Equal reports equal, on every
+// platform and in every process
+func (m *AAAA_Record) Hash() uint64 {
+	h := runtime.NewHasher()
+	m.hash(h)
+	return h.Sum64()
+}
+
+func (m *AAAA_Record) hash(h *runtime.Hasher) {
+	if m == nil {
+		h.Uint(0)
+		return
+	}
+	h.Uint(1)
+	h.Uint(uint64(m.Address_high))
+	h.Uint(uint64(m.Address_low))
+}
+
+// MarshalJSON encodes AAAA_Record with schema field names; byte arrays become arrays of numbers
+func (m AAAA_Record) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Address_high uint64 `json:"address_high"`
+		Address_low  uint64 `json:"address_low"`
+	}{
+		Address_high: m.Address_high,
+		Address_low:  m.Address_low,
+	})
+}
+
+// UnmarshalJSON decodes AAAA_Record from the JSON MarshalJSON produces
+func (m *AAAA_Record) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Address_high uint64 `json:"address_high"`
+		Address_low  uint64 `json:"address_low"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m.Address_high = v.Address_high
+	m.Address_low = v.Address_low
+	return nil
+}
+
+// Clone returns a deep copy of AAAA_Record that shares no memory with m or the input it was decoded from
+func (m *AAAA_Record) Clone() *AAAA_Record {
+	if m == nil {
+		return nil
+	}
+	out := *m
+	return &out
+}
+
+// A_Record: A record RDATA - IPv4 address (TYPE=1, CLASS=IN)
+type A_Record struct {
+	// A 32-bit Internet address (IPv4). Hosts with multiple Internet addresses will
+	// have multiple A records
+	Address uint32
+}
+
+func (m *A_Record) Encode() ([]byte, error) {
+	return m.EncodeWithContext(nil)
+}
+
+// EncodeWithLimit encodes like Encode, but fails with runtime.ErrOutputTooLarge as
+// soon as the output passes maxBytes, so a message too large to send isn't encoded
+// to the end first
+func (m *A_Record) EncodeWithLimit(maxBytes int) ([]byte, error) {
+	return m.EncodeWithContext(runtime.NewEncodingContext(runtime.OutputLimit(maxBytes)))
+}
+
+func (m *A_Record) EncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error) {
+	encoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	encoder.SetLimit(ctx.OutputRemaining())
+
+	encoder.WriteUint32(m.Address, runtime.BigEndian)
+
+	return encoder.Result()
+}
+
+func DecodeA_Record(bytes []byte) (*A_Record, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodeA_RecordWithDecoder(decoder, nil)
+}
+
+func decodeA_RecordWithDecoder(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext) (*A_Record, error) {
+	return decodeA_RecordInto(decoder, ctx, runtime.ArenaNew[A_Record](decoder.Arena))
+}
+
+func decodeA_RecordInto(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *A_Record) (*A_Record, error) {
+	if decoder.Trace != nil {
+		return decodeA_RecordIntoTraced(decoder, ctx, result)
+	}
+	const traceEnabled = runtime.TraceEnabled
+
+	*result = A_Record{}
+
+	if span, ok := decoder.PeekAligned(4); ok && !traceEnabled {
+		decodeA_RecordFrom(span, result)
+		decoder.SkipBytes(4)
+		return result, nil
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("address")
+	}
+	address, err := decoder.ReadUint32(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	result.Address = address
+	if traceEnabled {
+		decoder.TraceLeave(result.Address)
+	}
+
+	return result, nil
+}
+
+// decodeA_RecordIntoTraced is decodeA_RecordInto recording each read in decoder.Trace
+func decodeA_RecordIntoTraced(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *A_Record) (*A_Record, error) {
+	const traceEnabled = true
+
+	*result = A_Record{}
+
+	if span, ok := decoder.PeekAligned(4); ok && !traceEnabled {
+		decodeA_RecordFrom(span, result)
+		decoder.SkipBytes(4)
+		return result, nil
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("address")
+	}
+	address, err := decoder.ReadUint32(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	result.Address = address
+	if traceEnabled {
+		decoder.TraceLeave(result.Address)
+	}
+
+	return result, nil
+}
+
+// decodeA_RecordFrom decodes a A_Record from the first 4 bytes of span, which the caller has checked are there
+func decodeA_RecordFrom(span []byte, result *A_Record) {
+	_ = span[3]
+	result.Address = binary.BigEndian.Uint32(span[0:])
+}
+
+// String returns a readable one-line rendering of A_Record using schema field names
+func (m *A_Record) String() string {
+	if m == nil {
+		return "nil"
+	}
+	f := &runtime.Formatter{}
+	m.format(f)
+	return f.String()
+}
+
+// GoString returns A_Record as a Go composite literal, for %#v
+func (m *A_Record) GoString() string {
+	if m == nil {
+		return "(*A_Record)(nil)"
+	}
+	f := &runtime.Formatter{GoSyntax: true}
+	f.Pointer()
+	m.format(f)
+	return f.String()
+}
+
+func (m *A_Record) format(f *runtime.Formatter) {
+	f.BeginStruct("A_Record")
+	f.Field("address", "Address")
+	f.Value(m.Address)
+	f.EndStruct()
+}
+
+// Equal reports whether m and other hold the same values. Floats are compared bit for
+// bit, so NaN equals itself, and a nil slice equals an empty one. A lazy field that
+// fails to decode equals nothing.
+func (m *A_Record) Equal(other *A_Record) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if m.Address != other.Address {
+		return false
+	}
+	return true
+}
+
+// Hash returns a hash of m that is the same for values Equal reports equal, on every
+// platform and in every process
+func (m *A_Record) Hash() uint64 {
+	h := runtime.NewHasher()
+	m.hash(h)
+	return h.Sum64()
+}
+
+func (m *A_Record) hash(h *runtime.Hasher) {
+	if m == nil {
+		h.Uint(0)
+		return
+	}
+	h.Uint(1)
+	h.Uint(uint64(m.Address))
+}
+
+// MarshalJSON encodes A_Record with schema field names; byte arrays become arrays of numbers
+func (m A_Record) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Address uint32 `json:"address"`
+	}{
+		Address: m.Address,
+	})
+}
+
+// UnmarshalJSON decodes A_Record from the JSON MarshalJSON produces
+func (m *A_Record) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Address uint32 `json:"address"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m.Address = v.Address
+	return nil
+}
+
+// Clone returns a deep copy of A_Record that shares no memory with m or the input it was decoded from
+func (m *A_Record) Clone() *A_Record {
+	if m == nil {
+		return nil
+	}
+	out := *m
+	return &out
+}
+
+// Label: Single DNS label - length byte (0-63) followed by that many ASCII
+// characters
+type Label struct {
+	Value string
+}
+
+func (m *Label) Encode() ([]byte, error) {
+	return m.EncodeWithContext(nil)
+}
+
+// EncodeWithLimit encodes like Encode, but fails with runtime.ErrOutputTooLarge as
+// soon as the output passes maxBytes, so a message too large to send isn't encoded
+// to the end first
+func (m *Label) EncodeWithLimit(maxBytes int) ([]byte, error) {
+	return m.EncodeWithContext(runtime.NewEncodingContext(runtime.OutputLimit(maxBytes)))
+}
+
+func (m *Label) EncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error) {
+	encoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	encoder.SetLimit(ctx.OutputRemaining())
+
+	Value_bytes, err := runtime.ASCIIBytes("value", m.Value, false)
+	if err != nil {
+		return nil, err
+	}
+	encoder.WriteUint8(uint8(len(Value_bytes)))
+	encoder.WriteAlignedBytes(Value_bytes)
+
+	return encoder.Result()
+}
+
+func DecodeLabel(bytes []byte) (*Label, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodeLabelWithDecoder(decoder, nil)
+}
+
+func decodeLabelWithDecoder(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext) (*Label, error) {
+	return decodeLabelInto(decoder, ctx, runtime.ArenaNew[Label](decoder.Arena))
+}
+
+func decodeLabelInto(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *Label) (*Label, error) {
+	if decoder.Trace != nil {
+		return decodeLabelIntoTraced(decoder, ctx, result)
+	}
+	const traceEnabled = runtime.TraceEnabled
+
+	*result = Label{}
+
+	if traceEnabled {
+		decoder.TraceEnter("value")
+	}
+	if traceEnabled {
+		decoder.TraceEnter("length")
+	}
+	length, err := decoder.ReadUint8()
+	if err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(length)
+	}
+	value_bytes := make([]byte, length)
+	for i := range value_bytes {
+		b, err := decoder.ReadUint8()
+		if err != nil {
+			return nil, err
+		}
+		value_bytes[i] = b
+	}
+	result.Value = string(value_bytes)
+	if traceEnabled {
+		decoder.TraceLeave(result.Value)
+	}
+
+	return result, nil
+}
+
+// decodeLabelIntoTraced is decodeLabelInto recording each read in decoder.Trace
+func decodeLabelIntoTraced(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *Label) (*Label, error) {
+	const traceEnabled = true
+
+	*result = Label{}
+
+	if traceEnabled {
+		decoder.TraceEnter("value")
+	}
+	if traceEnabled {
+		decoder.TraceEnter("length")
+	}
+	length, err := decoder.ReadUint8()
+	if err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(length)
+	}
+	value_bytes := make([]byte, length)
+	for i := range value_bytes {
+		b, err := decoder.ReadUint8()
+		if err != nil {
+			return nil, err
+		}
+		value_bytes[i] = b
+	}
+	result.Value = string(value_bytes)
+	if traceEnabled {
+		decoder.TraceLeave(result.Value)
+	}
+
+	return result, nil
+}
+
+// String returns a readable one-line rendering of Label using schema field names
+func (m *Label) String() string {
+	if m == nil {
+		return "nil"
+	}
+	f := &runtime.Formatter{}
+	m.format(f)
+	return f.String()
+}
+
+// GoString returns Label as a Go composite literal, for %#v
+func (m *Label) GoString() string {
+	if m == nil {
+		return "(*Label)(nil)"
+	}
+	f := &runtime.Formatter{GoSyntax: true}
+	f.Pointer()
+	m.format(f)
+	return f.String()
+}
+
+func (m *Label) format(f *runtime.Formatter) {
+	f.BeginStruct("Label")
+	f.Field("value", "Value")
+	f.Value(m.Value)
+	f.EndStruct()
+}
+
+// Equal reports whether m and other hold the same values. Floats are compared bit for
+// bit, so NaN equals itself, and a nil slice equals an empty one. A lazy field that
+// fails to decode equals nothing.
+func (m *Label) Equal(other *Label) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if m.Value != other.Value {
+		return false
+	}
+	return true
+}
+
+// Hash returns a hash of m that is the same for values Equal reports equal, on every
+// platform and in every process
+func (m *Label) Hash() uint64 {
+	h := runtime.NewHasher()
+	m.hash(h)
+	return h.Sum64()
+}
+
+func (m *Label) hash(h *runtime.Hasher) {
+	if m == nil {
+		h.Uint(0)
+		return
+	}
+	h.Uint(1)
+	h.String(m.Value)
+}
+
+// MarshalJSON encodes Label with schema field names; byte arrays become arrays of numbers
+func (m Label) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Value string `json:"value"`
+	}{
+		Value: m.Value,
+	})
+}
+
+// UnmarshalJSON decodes Label from the JSON MarshalJSON produces
+func (m *Label) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m.Value = v.Value
+	return nil
+}
+
+// Clone returns a deep copy of Label that shares no memory with m or the input it was decoded from
+func (m *Label) Clone() *Label {
+	if m == nil {
+		return nil
+	}
+	out := *m
+	return &out
+}
+
+// DomainName: Complete domain name - sequence of labels terminated by zero-length
+// label
+type DomainName struct {
+	Value []Label
+}
+
+func (m *DomainName) Encode() ([]byte, error) {
+	return m.EncodeWithContext(nil)
+}
+
+// EncodeWithLimit encodes like Encode, but fails with runtime.ErrOutputTooLarge as
+// soon as the output passes maxBytes, so a message too large to send isn't encoded
+// to the end first
+func (m *DomainName) EncodeWithLimit(maxBytes int) ([]byte, error) {
+	return m.EncodeWithContext(runtime.NewEncodingContext(runtime.OutputLimit(maxBytes)))
+}
+
+func (m *DomainName) EncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error) {
+	encoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	encoder.SetLimit(ctx.OutputRemaining())
+
+	for _, Value_item := range m.Value {
+		if err := encoder.CheckLimit(); err != nil {
+			return nil, err
+		}
+		Value_item_bytes, err := Value_item.EncodeWithContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		encoder.WriteAlignedBytes(Value_item_bytes)
+	}
+	encoder.WriteUint8(0)
+
+	return encoder.Result()
+}
+
+func DecodeDomainName(bytes []byte) (*DomainName, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodeDomainNameWithDecoder(decoder, nil)
+}
+
+func decodeDomainNameWithDecoder(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext) (*DomainName, error) {
+	return decodeDomainNameInto(decoder, ctx, runtime.ArenaNew[DomainName](decoder.Arena))
+}
+
+func decodeDomainNameInto(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *DomainName) (*DomainName, error) {
+	if decoder.Trace != nil {
+		return decodeDomainNameIntoTraced(decoder, ctx, result)
+	}
+	const traceEnabled = runtime.TraceEnabled
+
+	*result = DomainName{Value: result.Value[:0]}
+
+	if traceEnabled {
+		decoder.TraceEnter("value")
+	}
+	result.Value = runtime.Reuse(decoder.Arena, result.Value, 0)
+	for {
+		terminator, err := decoder.PeekUint8()
+		if err != nil {
+			return nil, err
+		}
+		if terminator == 0 {
+			decoder.SkipBytes(1)
+			break
+		}
+		if err := decoder.CheckCanceled(); err != nil {
+			return nil, err
+		}
+		if traceEnabled {
+			decoder.TraceEnterItem(len(result.Value))
+		}
+		result.Value = runtime.Extend(decoder.Arena, result.Value)
+		if _, err := decodeLabelInto(decoder, ctx, &result.Value[len(result.Value)-1]); err != nil {
+			return nil, err
+		}
+		if traceEnabled {
+			decoder.TraceLeave(result.Value[len(result.Value)-1])
+		}
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Value)
+	}
+
+	return result, nil
+}
+
+// decodeDomainNameIntoTraced is decodeDomainNameInto recording each read in decoder.Trace
+func decodeDomainNameIntoTraced(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *DomainName) (*DomainName, error) {
+	const traceEnabled = true
+
+	*result = DomainName{Value: result.Value[:0]}
+
+	if traceEnabled {
+		decoder.TraceEnter("value")
+	}
+	result.Value = runtime.Reuse(decoder.Arena, result.Value, 0)
+	for {
+		terminator, err := decoder.PeekUint8()
+		if err != nil {
+			return nil, err
+		}
+		if terminator == 0 {
+			decoder.SkipBytes(1)
+			break
+		}
+		if err := decoder.CheckCanceled(); err != nil {
+			return nil, err
+		}
+		if traceEnabled {
+			decoder.TraceEnterItem(len(result.Value))
+		}
+		result.Value = runtime.Extend(decoder.Arena, result.Value)
+		if _, err := decodeLabelInto(decoder, ctx, &result.Value[len(result.Value)-1]); err != nil {
+			return nil, err
+		}
+		if traceEnabled {
+			decoder.TraceLeave(result.Value[len(result.Value)-1])
+		}
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Value)
+	}
+
+	return result, nil
+}
+
+// DecodeDomainNameValueEach decodes bytes like DecodeDomainName, but passes each item of value to fn as
+// it is decoded instead of collecting them: Value is left empty. The first error fn
+// returns stops decoding and is returned as is
+func DecodeDomainNameValueEach(bytes []byte, fn func(item *Label) error) (*DomainName, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodeDomainNameValueEach(decoder, nil, fn)
+}
+
+func decodeDomainNameValueEach(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, fn func(item *Label) error) (*DomainName, error) {
+	const traceEnabled = runtime.TraceEnabled
+	result := &DomainName{}
+
+	for {
+		terminator, err := decoder.PeekUint8()
+		if err != nil {
+			return nil, err
+		}
+		if terminator == 0 {
+			decoder.SkipBytes(1)
+			break
+		}
+		if err := decoder.CheckCanceled(); err != nil {
+			return nil, err
+		}
+		value_item, err := decodeLabelWithDecoder(decoder, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := fn(value_item); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// String returns a readable one-line rendering of DomainName using schema field names
+func (m *DomainName) String() string {
+	if m == nil {
+		return "nil"
+	}
+	f := &runtime.Formatter{}
+	m.format(f)
+	return f.String()
+}
+
+// GoString returns DomainName as a Go composite literal, for %#v
+func (m *DomainName) GoString() string {
+	if m == nil {
+		return "(*DomainName)(nil)"
+	}
+	f := &runtime.Formatter{GoSyntax: true}
+	f.Pointer()
+	m.format(f)
+	return f.String()
+}
+
+func (m *DomainName) format(f *runtime.Formatter) {
+	f.BeginStruct("DomainName")
+	f.Field("value", "Value")
+	f.BeginList("[]Label")
+	for i0 := range m.Value {
+		f.Item()
+		m.Value[i0].format(f)
+	}
+	f.EndList()
+	f.EndStruct()
+}
+
+// Equal reports whether m and other hold the same values. Floats are compared bit for
+// bit, so NaN equals itself, and a nil slice equals an empty one. A lazy field that
+// fails to decode equals nothing.
+func (m *DomainName) Equal(other *DomainName) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if len(m.Value) != len(other.Value) {
+		return false
+	}
+	for i0 := range m.Value {
+		if !m.Value[i0].Equal(&other.Value[i0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns a hash of m that is the same for values Equal reports equal, on every
+// platform and in every process
+func (m *DomainName) Hash() uint64 {
+	h := runtime.NewHasher()
+	m.hash(h)
+	return h.Sum64()
+}
+
+func (m *DomainName) hash(h *runtime.Hasher) {
+	if m == nil {
+		h.Uint(0)
+		return
+	}
+	h.Uint(1)
+	h.Uint(uint64(len(m.Value)))
+	for i0 := range m.Value {
+		m.Value[i0].hash(h)
+	}
+}
+
+// MarshalJSON encodes DomainName with schema field names; byte arrays become arrays of numbers
+func (m DomainName) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Value []Label `json:"value"`
+	}{
+		Value: m.Value,
+	})
+}
+
+// UnmarshalJSON decodes DomainName from the JSON MarshalJSON produces
+func (m *DomainName) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Value []Label `json:"value"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m.Value = v.Value
+	return nil
+}
+
+// Clone returns a deep copy of DomainName that shares no memory with m or the input it was decoded from
+func (m *DomainName) Clone() *DomainName {
+	if m == nil {
+		return nil
+	}
+	out := *m
+	if m.Value != nil {
+		out.Value = make([]Label, len(m.Value))
+		for i0 := range m.Value {
+			out.Value[i0] = *m.Value[i0].Clone()
+		}
+	}
+	return &out
+}
+
+// CNAME_Record: CNAME record RDATA - canonical name for an alias (TYPE=5)
+type CNAME_Record struct {
+	// A domain name which specifies the canonical or primary name for the owner. The
+	// owner name is an alias
+	Cname DomainName
+}
+
+func (m *CNAME_Record) Encode() ([]byte, error) {
+	return m.EncodeWithContext(nil)
+}
+
+// EncodeWithLimit encodes like Encode, but fails with runtime.ErrOutputTooLarge as
+// soon as the output passes maxBytes, so a message too large to send isn't encoded
+// to the end first
+func (m *CNAME_Record) EncodeWithLimit(maxBytes int) ([]byte, error) {
+	return m.EncodeWithContext(runtime.NewEncodingContext(runtime.OutputLimit(maxBytes)))
+}
+
+func (m *CNAME_Record) EncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error) {
+	encoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	encoder.SetLimit(ctx.OutputRemaining())
+
+	Cname_bytes, err := m.Cname.EncodeWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	encoder.WriteAlignedBytes(Cname_bytes)
+
+	return encoder.Result()
+}
+
+func DecodeCNAME_Record(bytes []byte) (*CNAME_Record, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodeCNAME_RecordWithDecoder(decoder, nil)
+}
+
+func decodeCNAME_RecordWithDecoder(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext) (*CNAME_Record, error) {
+	return decodeCNAME_RecordInto(decoder, ctx, runtime.ArenaNew[CNAME_Record](decoder.Arena))
+}
+
+func decodeCNAME_RecordInto(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *CNAME_Record) (*CNAME_Record, error) {
+	if decoder.Trace != nil {
+		return decodeCNAME_RecordIntoTraced(decoder, ctx, result)
+	}
+	const traceEnabled = runtime.TraceEnabled
+
+	*result = CNAME_Record{Cname: result.Cname}
+
+	if traceEnabled {
+		decoder.TraceEnter("cname")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Cname); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Cname)
+	}
+
+	return result, nil
+}
+
+// decodeCNAME_RecordIntoTraced is decodeCNAME_RecordInto recording each read in decoder.Trace
+func decodeCNAME_RecordIntoTraced(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *CNAME_Record) (*CNAME_Record, error) {
+	const traceEnabled = true
+
+	*result = CNAME_Record{Cname: result.Cname}
+
+	if traceEnabled {
+		decoder.TraceEnter("cname")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Cname); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Cname)
+	}
+
+	return result, nil
+}
+
+// String returns a readable one-line rendering of CNAME_Record using schema field names
+func (m *CNAME_Record) String() string {
+	if m == nil {
+		return "nil"
+	}
+	f := &runtime.Formatter{}
+	m.format(f)
+	return f.String()
+}
+
+// GoString returns CNAME_Record as a Go composite literal, for %#v
+func (m *CNAME_Record) GoString() string {
+	if m == nil {
+		return "(*CNAME_Record)(nil)"
+	}
+	f := &runtime.Formatter{GoSyntax: true}
+	f.Pointer()
+	m.format(f)
+	return f.String()
+}
+
+func (m *CNAME_Record) format(f *runtime.Formatter) {
+	f.BeginStruct("CNAME_Record")
+	f.Field("cname", "Cname")
+	m.Cname.format(f)
+	f.EndStruct()
+}
+
+// Equal reports whether m and other hold the same values. Floats are compared bit for
+// bit, so NaN equals itself, and a nil slice equals an empty one. A lazy field that
+// fails to decode equals nothing.
+func (m *CNAME_Record) Equal(other *CNAME_Record) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if !m.Cname.Equal(&other.Cname) {
+		return false
+	}
+	return true
+}
+
+// Hash returns a hash of m that is the same for values Equal reports equal, on every
+// platform and in every process
+func (m *CNAME_Record) Hash() uint64 {
+	h := runtime.NewHasher()
+	m.hash(h)
+	return h.Sum64()
+}
+
+func (m *CNAME_Record) hash(h *runtime.Hasher) {
+	if m == nil {
+		h.Uint(0)
+		return
+	}
+	h.Uint(1)
+	m.Cname.hash(h)
+}
+
+// MarshalJSON encodes CNAME_Record with schema field names; byte arrays become arrays of numbers
+func (m CNAME_Record) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Cname DomainName `json:"cname"`
+	}{
+		Cname: m.Cname,
+	})
+}
+
+// UnmarshalJSON decodes CNAME_Record from the JSON MarshalJSON produces
+func (m *CNAME_Record) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Cname DomainName `json:"cname"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m.Cname = v.Cname
+	return nil
+}
+
+// Clone returns a deep copy of CNAME_Record that shares no memory with m or the input it was decoded from
+func (m *CNAME_Record) Clone() *CNAME_Record {
+	if m == nil {
+		return nil
+	}
+	out := *m
+	out.Cname = *m.Cname.Clone()
+	return &out
+}
+
+// DNSHeader: DNS message header - fixed 12 bytes at start of all DNS messages
+type DNSHeader struct {
+	// A 16-bit identifier assigned by the program that generates any kind of query.
+	// This identifier is copied into the corresponding reply and can be used by the
+	// requester to match up replies to outstanding queries.
+	Id uint16
+	// Query/Response flag - 1 bit that specifies whether this message is a query (0)
+	// or a response (1)
+	Qr uint8
+	// Operation code - 4 bits specifying the kind of query. Set by originator, copied
+	// into response. 0=standard query (QUERY), 1=inverse query (IQUERY), 2=server
+	// status request (STATUS), 3-15=reserved for future use
+	Opcode uint8
+	// Authoritative Answer - 1 bit valid in responses. Specifies that the responding
+	// name server is an authority for the domain name in the question section. Note:
+	// AA corresponds to the name matching the query name, or the first owner name in
+	// the answer section
+	Aa uint8
+	// TrunCation - 1 bit specifying that this message was truncated due to length
+	// greater than that permitted on the transmission channel (512 bytes for UDP)
+	Tc uint8
+	// Recursion Desired - 1 bit that may be set in a query and is copied into the
+	// response. If set, it directs the name server to pursue the query recursively.
+	// Recursive query support is optional
+	Rd uint8
+	// Recursion Available - 1 bit set or cleared in a response. Denotes whether
+	// recursive query support is available in the name server
+	Ra uint8
+	// Reserved for future use - 3 bits. Must be zero in all queries and responses
+	Z uint8
+	// Response code - 4 bits set as part of responses. 0=No error, 1=Format error
+	// (unable to interpret query), 2=Server failure, 3=Name Error (domain does not
+	// exist, only from authoritative server), 4=Not Implemented (query type not
+	// supported), 5=Refused (policy reasons), 6-15=reserved
+	Rcode uint8
+	// An unsigned 16-bit integer specifying the number of entries in the question
+	// section
+	Qdcount uint16
+	// An unsigned 16-bit integer specifying the number of resource records in the
+	// answer section
+	Ancount uint16
+	// An unsigned 16-bit integer specifying the number of name server resource records
+	// in the authority records section
+	Nscount uint16
+	// An unsigned 16-bit integer specifying the number of resource records in the
+	// additional records section
+	Arcount uint16
+}
+
+func (m *DNSHeader) Encode() ([]byte, error) {
+	return m.EncodeWithContext(nil)
+}
+
+// EncodeWithLimit encodes like Encode, but fails with runtime.ErrOutputTooLarge as
+// soon as the output passes maxBytes, so a message too large to send isn't encoded
+// to the end first
+func (m *DNSHeader) EncodeWithLimit(maxBytes int) ([]byte, error) {
+	return m.EncodeWithContext(runtime.NewEncodingContext(runtime.OutputLimit(maxBytes)))
+}
+
+func (m *DNSHeader) EncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error) {
+	encoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	encoder.SetLimit(ctx.OutputRemaining())
+
+	encoder.WriteUint16(m.Id, runtime.BigEndian)
+	encoder.WriteBits(uint64(m.Qr), 1)
+	encoder.WriteBits(uint64(m.Opcode), 4)
+	encoder.WriteBits(uint64(m.Aa), 1)
+	encoder.WriteBits(uint64(m.Tc), 1)
+	encoder.WriteBits(uint64(m.Rd), 1)
+	encoder.WriteBits(uint64(m.Ra), 1)
+	encoder.WriteBits(uint64(m.Z), 3)
+	encoder.WriteBits(uint64(m.Rcode), 4)
+	encoder.WriteUint16(m.Qdcount, runtime.BigEndian)
+	encoder.WriteUint16(m.Ancount, runtime.BigEndian)
+	encoder.WriteUint16(m.Nscount, runtime.BigEndian)
+	encoder.WriteUint16(m.Arcount, runtime.BigEndian)
+
+	return encoder.Result()
+}
+
+func DecodeDNSHeader(bytes []byte) (*DNSHeader, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodeDNSHeaderWithDecoder(decoder, nil)
+}
+
+func decodeDNSHeaderWithDecoder(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext) (*DNSHeader, error) {
+	return decodeDNSHeaderInto(decoder, ctx, runtime.ArenaNew[DNSHeader](decoder.Arena))
+}
+
+func decodeDNSHeaderInto(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *DNSHeader) (*DNSHeader, error) {
+	if decoder.Trace != nil {
+		return decodeDNSHeaderIntoTraced(decoder, ctx, result)
+	}
+	const traceEnabled = runtime.TraceEnabled
+
+	*result = DNSHeader{}
+
+	if traceEnabled {
+		decoder.TraceEnter("id")
+	}
+	id, err := decoder.ReadUint16(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	result.Id = id
+	if traceEnabled {
+		decoder.TraceLeave(result.Id)
+	}
+
+	if !traceEnabled {
+		// qr, opcode, aa, tc, rd, ra, z, rcode: 16 bits
+		bits, err := decoder.ReadBits(16)
+		if err != nil {
+			return nil, err
+		}
+		result.Qr = uint8(bits >> 15 & 0x1)
+		result.Opcode = uint8(bits >> 11 & 0xf)
+		result.Aa = uint8(bits >> 10 & 0x1)
+		result.Tc = uint8(bits >> 9 & 0x1)
+		result.Rd = uint8(bits >> 8 & 0x1)
+		result.Ra = uint8(bits >> 7 & 0x1)
+		result.Z = uint8(bits >> 4 & 0x7)
+		result.Rcode = uint8(bits & 0xf)
+	} else {
+		if traceEnabled {
+			decoder.TraceEnter("qr")
+		}
+		qr_bits, err := decoder.ReadBits(1)
+		if err != nil {
+			return nil, err
+		}
+		qr := uint8(qr_bits)
+		result.Qr = qr
+		if traceEnabled {
+			decoder.TraceLeave(result.Qr)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("opcode")
+		}
+		opcode_bits, err := decoder.ReadBits(4)
+		if err != nil {
+			return nil, err
+		}
+		opcode := uint8(opcode_bits)
+		result.Opcode = opcode
+		if traceEnabled {
+			decoder.TraceLeave(result.Opcode)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("aa")
+		}
+		aa_bits, err := decoder.ReadBits(1)
+		if err != nil {
+			return nil, err
+		}
+		aa := uint8(aa_bits)
+		result.Aa = aa
+		if traceEnabled {
+			decoder.TraceLeave(result.Aa)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("tc")
+		}
+		tc_bits, err := decoder.ReadBits(1)
+		if err != nil {
+			return nil, err
+		}
+		tc := uint8(tc_bits)
+		result.Tc = tc
+		if traceEnabled {
+			decoder.TraceLeave(result.Tc)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("rd")
+		}
+		rd_bits, err := decoder.ReadBits(1)
+		if err != nil {
+			return nil, err
+		}
+		rd := uint8(rd_bits)
+		result.Rd = rd
+		if traceEnabled {
+			decoder.TraceLeave(result.Rd)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("ra")
+		}
+		ra_bits, err := decoder.ReadBits(1)
+		if err != nil {
+			return nil, err
+		}
+		ra := uint8(ra_bits)
+		result.Ra = ra
+		if traceEnabled {
+			decoder.TraceLeave(result.Ra)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("z")
+		}
+		z_bits, err := decoder.ReadBits(3)
+		if err != nil {
+			return nil, err
+		}
+		z := uint8(z_bits)
+		result.Z = z
+		if traceEnabled {
+			decoder.TraceLeave(result.Z)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("rcode")
+		}
+		rcode_bits, err := decoder.ReadBits(4)
+		if err != nil {
+			return nil, err
+		}
+		rcode := uint8(rcode_bits)
+		result.Rcode = rcode
+		if traceEnabled {
+			decoder.TraceLeave(result.Rcode)
+		}
+	}
+
+	// qdcount, ancount, nscount, arcount: 8 bytes
+	if span, ok := decoder.PeekAligned(8); ok && !traceEnabled {
+		_ = span[7]
+		result.Qdcount = binary.BigEndian.Uint16(span[0:])
+		result.Ancount = binary.BigEndian.Uint16(span[2:])
+		result.Nscount = binary.BigEndian.Uint16(span[4:])
+		result.Arcount = binary.BigEndian.Uint16(span[6:])
+		decoder.SkipBytes(8)
+	} else {
+		if traceEnabled {
+			decoder.TraceEnter("qdcount")
+		}
+		qdcount, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Qdcount = qdcount
+		if traceEnabled {
+			decoder.TraceLeave(result.Qdcount)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("ancount")
+		}
+		ancount, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Ancount = ancount
+		if traceEnabled {
+			decoder.TraceLeave(result.Ancount)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("nscount")
+		}
+		nscount, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Nscount = nscount
+		if traceEnabled {
+			decoder.TraceLeave(result.Nscount)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("arcount")
+		}
+		arcount, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Arcount = arcount
+		if traceEnabled {
+			decoder.TraceLeave(result.Arcount)
+		}
+	}
+
+	return result, nil
+}
+
+// decodeDNSHeaderIntoTraced is decodeDNSHeaderInto recording each read in decoder.Trace
+func decodeDNSHeaderIntoTraced(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *DNSHeader) (*DNSHeader, error) {
+	const traceEnabled = true
+
+	*result = DNSHeader{}
+
+	if traceEnabled {
+		decoder.TraceEnter("id")
+	}
+	id, err := decoder.ReadUint16(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	result.Id = id
+	if traceEnabled {
+		decoder.TraceLeave(result.Id)
+	}
+
+	if !traceEnabled {
+		// qr, opcode, aa, tc, rd, ra, z, rcode: 16 bits
+		bits, err := decoder.ReadBits(16)
+		if err != nil {
+			return nil, err
+		}
+		result.Qr = uint8(bits >> 15 & 0x1)
+		result.Opcode = uint8(bits >> 11 & 0xf)
+		result.Aa = uint8(bits >> 10 & 0x1)
+		result.Tc = uint8(bits >> 9 & 0x1)
+		result.Rd = uint8(bits >> 8 & 0x1)
+		result.Ra = uint8(bits >> 7 & 0x1)
+		result.Z = uint8(bits >> 4 & 0x7)
+		result.Rcode = uint8(bits & 0xf)
+	} else {
+		if traceEnabled {
+			decoder.TraceEnter("qr")
+		}
+		qr_bits, err := decoder.ReadBits(1)
+		if err != nil {
+			return nil, err
+		}
+		qr := uint8(qr_bits)
+		result.Qr = qr
+		if traceEnabled {
+			decoder.TraceLeave(result.Qr)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("opcode")
+		}
+		opcode_bits, err := decoder.ReadBits(4)
+		if err != nil {
+			return nil, err
+		}
+		opcode := uint8(opcode_bits)
+		result.Opcode = opcode
+		if traceEnabled {
+			decoder.TraceLeave(result.Opcode)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("aa")
+		}
+		aa_bits, err := decoder.ReadBits(1)
+		if err != nil {
+			return nil, err
+		}
+		aa := uint8(aa_bits)
+		result.Aa = aa
+		if traceEnabled {
+			decoder.TraceLeave(result.Aa)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("tc")
+		}
+		tc_bits, err := decoder.ReadBits(1)
+		if err != nil {
+			return nil, err
+		}
+		tc := uint8(tc_bits)
+		result.Tc = tc
+		if traceEnabled {
+			decoder.TraceLeave(result.Tc)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("rd")
+		}
+		rd_bits, err := decoder.ReadBits(1)
+		if err != nil {
+			return nil, err
+		}
+		rd := uint8(rd_bits)
+		result.Rd = rd
+		if traceEnabled {
+			decoder.TraceLeave(result.Rd)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("ra")
+		}
+		ra_bits, err := decoder.ReadBits(1)
+		if err != nil {
+			return nil, err
+		}
+		ra := uint8(ra_bits)
+		result.Ra = ra
+		if traceEnabled {
+			decoder.TraceLeave(result.Ra)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("z")
+		}
+		z_bits, err := decoder.ReadBits(3)
+		if err != nil {
+			return nil, err
+		}
+		z := uint8(z_bits)
+		result.Z = z
+		if traceEnabled {
+			decoder.TraceLeave(result.Z)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("rcode")
+		}
+		rcode_bits, err := decoder.ReadBits(4)
+		if err != nil {
+			return nil, err
+		}
+		rcode := uint8(rcode_bits)
+		result.Rcode = rcode
+		if traceEnabled {
+			decoder.TraceLeave(result.Rcode)
+		}
+	}
+
+	// qdcount, ancount, nscount, arcount: 8 bytes
+	if span, ok := decoder.PeekAligned(8); ok && !traceEnabled {
+		_ = span[7]
+		result.Qdcount = binary.BigEndian.Uint16(span[0:])
+		result.Ancount = binary.BigEndian.Uint16(span[2:])
+		result.Nscount = binary.BigEndian.Uint16(span[4:])
+		result.Arcount = binary.BigEndian.Uint16(span[6:])
+		decoder.SkipBytes(8)
+	} else {
+		if traceEnabled {
+			decoder.TraceEnter("qdcount")
+		}
+		qdcount, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Qdcount = qdcount
+		if traceEnabled {
+			decoder.TraceLeave(result.Qdcount)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("ancount")
+		}
+		ancount, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Ancount = ancount
+		if traceEnabled {
+			decoder.TraceLeave(result.Ancount)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("nscount")
+		}
+		nscount, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Nscount = nscount
+		if traceEnabled {
+			decoder.TraceLeave(result.Nscount)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("arcount")
+		}
+		arcount, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Arcount = arcount
+		if traceEnabled {
+			decoder.TraceLeave(result.Arcount)
+		}
+	}
+
+	return result, nil
+}
+
+// String returns a readable one-line rendering of DNSHeader using schema field names
+func (m *DNSHeader) String() string {
+	if m == nil {
+		return "nil"
+	}
+	f := &runtime.Formatter{}
+	m.format(f)
+	return f.String()
+}
+
+// GoString returns DNSHeader as a Go composite literal, for %#v
+func (m *DNSHeader) GoString() string {
+	if m == nil {
+		return "(*DNSHeader)(nil)"
+	}
+	f := &runtime.Formatter{GoSyntax: true}
+	f.Pointer()
+	m.format(f)
+	return f.String()
+}
+
+func (m *DNSHeader) format(f *runtime.Formatter) {
+	f.BeginStruct("DNSHeader")
+	f.Field("id", "Id")
+	f.Value(m.Id)
+	f.Field("qr", "Qr")
+	f.Value(m.Qr)
+	f.Field("opcode", "Opcode")
+	f.Value(m.Opcode)
+	f.Field("aa", "Aa")
+	f.Value(m.Aa)
+	f.Field("tc", "Tc")
+	f.Value(m.Tc)
+	f.Field("rd", "Rd")
+	f.Value(m.Rd)
+	f.Field("ra", "Ra")
+	f.Value(m.Ra)
+	f.Field("z", "Z")
+	f.Value(m.Z)
+	f.Field("rcode", "Rcode")
+	f.Value(m.Rcode)
+	f.Field("qdcount", "Qdcount")
+	f.Value(m.Qdcount)
+	f.Field("ancount", "Ancount")
+	f.Value(m.Ancount)
+	f.Field("nscount", "Nscount")
+	f.Value(m.Nscount)
+	f.Field("arcount", "Arcount")
+	f.Value(m.Arcount)
+	f.EndStruct()
+}
+
+// Equal reports whether m and other hold the same values. Floats are compared bit for
+// bit, so NaN equals itself, and a nil slice equals an empty one. A lazy field that
+// fails to decode equals nothing.
+func (m *DNSHeader) Equal(other *DNSHeader) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if m.Id != other.Id {
+		return false
+	}
+	if m.Qr != other.Qr {
+		return false
+	}
+	if m.Opcode != other.Opcode {
+		return false
+	}
+	if m.Aa != other.Aa {
+		return false
+	}
+	if m.Tc != other.Tc {
+		return false
+	}
+	if m.Rd != other.Rd {
+		return false
+	}
+	if m.Ra != other.Ra {
+		return false
+	}
+	if m.Z != other.Z {
+		return false
+	}
+	if m.Rcode != other.Rcode {
+		return false
+	}
+	if m.Qdcount != other.Qdcount {
+		return false
+	}
+	if m.Ancount != other.Ancount {
+		return false
+	}
+	if m.Nscount != other.Nscount {
+		return false
+	}
+	if m.Arcount != other.Arcount {
+		return false
+	}
+	return true
+}
+
+// Hash returns a hash of m that is the same for values Equal reports equal, on every
+// platform and in every process
+func (m *DNSHeader) Hash() uint64 {
+	h := runtime.NewHasher()
+	m.hash(h)
+	return h.Sum64()
+}
+
+func (m *DNSHeader) hash(h *runtime.Hasher) {
+	if m == nil {
+		h.Uint(0)
+		return
+	}
+	h.Uint(1)
+	h.Uint(uint64(m.Id))
+	h.Uint(uint64(m.Qr))
+	h.Uint(uint64(m.Opcode))
+	h.Uint(uint64(m.Aa))
+	h.Uint(uint64(m.Tc))
+	h.Uint(uint64(m.Rd))
+	h.Uint(uint64(m.Ra))
+	h.Uint(uint64(m.Z))
+	h.Uint(uint64(m.Rcode))
+	h.Uint(uint64(m.Qdcount))
+	h.Uint(uint64(m.Ancount))
+	h.Uint(uint64(m.Nscount))
+	h.Uint(uint64(m.Arcount))
+}
+
+// MarshalJSON encodes DNSHeader with schema field names; byte arrays become arrays of numbers
+func (m DNSHeader) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Id      uint16 `json:"id"`
+		Qr      uint8  `json:"qr"`
+		Opcode  uint8  `json:"opcode"`
+		Aa      uint8  `json:"aa"`
+		Tc      uint8  `json:"tc"`
+		Rd      uint8  `json:"rd"`
+		Ra      uint8  `json:"ra"`
+		Z       uint8  `json:"z"`
+		Rcode   uint8  `json:"rcode"`
+		Qdcount uint16 `json:"qdcount"`
+		Ancount uint16 `json:"ancount"`
+		Nscount uint16 `json:"nscount"`
+		Arcount uint16 `json:"arcount"`
+	}{
+		Id:      m.Id,
+		Qr:      m.Qr,
+		Opcode:  m.Opcode,
+		Aa:      m.Aa,
+		Tc:      m.Tc,
+		Rd:      m.Rd,
+		Ra:      m.Ra,
+		Z:       m.Z,
+		Rcode:   m.Rcode,
+		Qdcount: m.Qdcount,
+		Ancount: m.Ancount,
+		Nscount: m.Nscount,
+		Arcount: m.Arcount,
+	})
+}
+
+// UnmarshalJSON decodes DNSHeader from the JSON MarshalJSON produces
+func (m *DNSHeader) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Id      uint16 `json:"id"`
+		Qr      uint8  `json:"qr"`
+		Opcode  uint8  `json:"opcode"`
+		Aa      uint8  `json:"aa"`
+		Tc      uint8  `json:"tc"`
+		Rd      uint8  `json:"rd"`
+		Ra      uint8  `json:"ra"`
+		Z       uint8  `json:"z"`
+		Rcode   uint8  `json:"rcode"`
+		Qdcount uint16 `json:"qdcount"`
+		Ancount uint16 `json:"ancount"`
+		Nscount uint16 `json:"nscount"`
+		Arcount uint16 `json:"arcount"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m.Id = v.Id
+	m.Qr = v.Qr
+	m.Opcode = v.Opcode
+	m.Aa = v.Aa
+	m.Tc = v.Tc
+	m.Rd = v.Rd
+	m.Ra = v.Ra
+	m.Z = v.Z
+	m.Rcode = v.Rcode
+	m.Qdcount = v.Qdcount
+	m.Ancount = v.Ancount
+	m.Nscount = v.Nscount
+	m.Arcount = v.Arcount
+	return nil
+}
+
+// Clone returns a deep copy of DNSHeader that shares no memory with m or the input it was decoded from
+func (m *DNSHeader) Clone() *DNSHeader {
+	if m == nil {
+		return nil
+	}
+	out := *m
+	return &out
+}
+
+// MX_Record: MX record RDATA - mail exchange (TYPE=15)
+type MX_Record struct {
+	// A 16-bit preference value used when multiple mail exchanges exist
+	Preference uint16
+	// A domain name which specifies a mail exchange
+	Exchange DomainName
+}
+
+func (m *MX_Record) Encode() ([]byte, error) {
+	return m.EncodeWithContext(nil)
+}
+
+// EncodeWithLimit encodes like Encode, but fails with runtime.ErrOutputTooLarge as
+// soon as the output passes maxBytes, so a message too large to send isn't encoded
+// to the end first
+func (m *MX_Record) EncodeWithLimit(maxBytes int) ([]byte, error) {
+	return m.EncodeWithContext(runtime.NewEncodingContext(runtime.OutputLimit(maxBytes)))
+}
+
+func (m *MX_Record) EncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error) {
+	encoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	encoder.SetLimit(ctx.OutputRemaining())
+
+	encoder.WriteUint16(m.Preference, runtime.BigEndian)
+	Exchange_bytes, err := m.Exchange.EncodeWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	encoder.WriteAlignedBytes(Exchange_bytes)
+
+	return encoder.Result()
+}
+
+func DecodeMX_Record(bytes []byte) (*MX_Record, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodeMX_RecordWithDecoder(decoder, nil)
+}
+
+func decodeMX_RecordWithDecoder(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext) (*MX_Record, error) {
+	return decodeMX_RecordInto(decoder, ctx, runtime.ArenaNew[MX_Record](decoder.Arena))
+}
+
+func decodeMX_RecordInto(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *MX_Record) (*MX_Record, error) {
+	if decoder.Trace != nil {
+		return decodeMX_RecordIntoTraced(decoder, ctx, result)
+	}
+	const traceEnabled = runtime.TraceEnabled
+
+	*result = MX_Record{Exchange: result.Exchange}
+
+	if traceEnabled {
+		decoder.TraceEnter("preference")
+	}
+	preference, err := decoder.ReadUint16(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	result.Preference = preference
+	if traceEnabled {
+		decoder.TraceLeave(result.Preference)
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("exchange")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Exchange); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Exchange)
+	}
+
+	return result, nil
+}
+
+// decodeMX_RecordIntoTraced is decodeMX_RecordInto recording each read in decoder.Trace
+func decodeMX_RecordIntoTraced(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *MX_Record) (*MX_Record, error) {
+	const traceEnabled = true
+
+	*result = MX_Record{Exchange: result.Exchange}
+
+	if traceEnabled {
+		decoder.TraceEnter("preference")
+	}
+	preference, err := decoder.ReadUint16(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	result.Preference = preference
+	if traceEnabled {
+		decoder.TraceLeave(result.Preference)
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("exchange")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Exchange); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Exchange)
+	}
+
+	return result, nil
+}
+
+// String returns a readable one-line rendering of MX_Record using schema field names
+func (m *MX_Record) String() string {
+	if m == nil {
+		return "nil"
+	}
+	f := &runtime.Formatter{}
+	m.format(f)
+	return f.String()
+}
+
+// GoString returns MX_Record as a Go composite literal, for %#v
+func (m *MX_Record) GoString() string {
+	if m == nil {
+		return "(*MX_Record)(nil)"
+	}
+	f := &runtime.Formatter{GoSyntax: true}
+	f.Pointer()
+	m.format(f)
+	return f.String()
+}
+
+func (m *MX_Record) format(f *runtime.Formatter) {
+	f.BeginStruct("MX_Record")
+	f.Field("preference", "Preference")
+	f.Value(m.Preference)
+	f.Field("exchange", "Exchange")
+	m.Exchange.format(f)
+	f.EndStruct()
+}
+
+// Equal reports whether m and other hold the same values. Floats are compared bit for
+// bit, so NaN equals itself, and a nil slice equals an empty one. A lazy field that
+// fails to decode equals nothing.
+func (m *MX_Record) Equal(other *MX_Record) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if m.Preference != other.Preference {
+		return false
+	}
+	if !m.Exchange.Equal(&other.Exchange) {
+		return false
+	}
+	return true
+}
+
+// Hash returns a hash of m that is the same for values Equal reports equal, on every
+// platform and in every process
+func (m *MX_Record) Hash() uint64 {
+	h := runtime.NewHasher()
+	m.hash(h)
+	return h.Sum64()
+}
+
+func (m *MX_Record) hash(h *runtime.Hasher) {
+	if m == nil {
+		h.Uint(0)
+		return
+	}
+	h.Uint(1)
+	h.Uint(uint64(m.Preference))
+	m.Exchange.hash(h)
+}
+
+// MarshalJSON encodes MX_Record with schema field names; byte arrays become arrays of numbers
+func (m MX_Record) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Preference uint16     `json:"preference"`
+		Exchange   DomainName `json:"exchange"`
+	}{
+		Preference: m.Preference,
+		Exchange:   m.Exchange,
+	})
+}
+
+// UnmarshalJSON decodes MX_Record from the JSON MarshalJSON produces
+func (m *MX_Record) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Preference uint16     `json:"preference"`
+		Exchange   DomainName `json:"exchange"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m.Preference = v.Preference
+	m.Exchange = v.Exchange
+	return nil
+}
+
+// Clone returns a deep copy of MX_Record that shares no memory with m or the input it was decoded from
+func (m *MX_Record) Clone() *MX_Record {
+	if m == nil {
+		return nil
+	}
+	out := *m
+	out.Exchange = *m.Exchange.Clone()
+	return &out
+}
+
+// NS_Record: NS record RDATA - authoritative name server (TYPE=2)
+type NS_Record struct {
+	// A domain name which specifies a host which should be authoritative for the
+	// specified class and domain
+	Nsdname DomainName
+}
+
+func (m *NS_Record) Encode() ([]byte, error) {
+	return m.EncodeWithContext(nil)
+}
+
+// EncodeWithLimit encodes like Encode, but fails with runtime.ErrOutputTooLarge as
+// soon as the output passes maxBytes, so a message too large to send isn't encoded
+// to the end first
+func (m *NS_Record) EncodeWithLimit(maxBytes int) ([]byte, error) {
+	return m.EncodeWithContext(runtime.NewEncodingContext(runtime.OutputLimit(maxBytes)))
+}
+
+func (m *NS_Record) EncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error) {
+	encoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	encoder.SetLimit(ctx.OutputRemaining())
+
+	Nsdname_bytes, err := m.Nsdname.EncodeWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	encoder.WriteAlignedBytes(Nsdname_bytes)
+
+	return encoder.Result()
+}
+
+func DecodeNS_Record(bytes []byte) (*NS_Record, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodeNS_RecordWithDecoder(decoder, nil)
+}
+
+func decodeNS_RecordWithDecoder(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext) (*NS_Record, error) {
+	return decodeNS_RecordInto(decoder, ctx, runtime.ArenaNew[NS_Record](decoder.Arena))
+}
+
+func decodeNS_RecordInto(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *NS_Record) (*NS_Record, error) {
+	if decoder.Trace != nil {
+		return decodeNS_RecordIntoTraced(decoder, ctx, result)
+	}
+	const traceEnabled = runtime.TraceEnabled
+
+	*result = NS_Record{Nsdname: result.Nsdname}
+
+	if traceEnabled {
+		decoder.TraceEnter("nsdname")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Nsdname); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Nsdname)
+	}
+
+	return result, nil
+}
+
+// decodeNS_RecordIntoTraced is decodeNS_RecordInto recording each read in decoder.Trace
+func decodeNS_RecordIntoTraced(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *NS_Record) (*NS_Record, error) {
+	const traceEnabled = true
+
+	*result = NS_Record{Nsdname: result.Nsdname}
+
+	if traceEnabled {
+		decoder.TraceEnter("nsdname")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Nsdname); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Nsdname)
+	}
+
+	return result, nil
+}
+
+// String returns a readable one-line rendering of NS_Record using schema field names
+func (m *NS_Record) String() string {
+	if m == nil {
+		return "nil"
+	}
+	f := &runtime.Formatter{}
+	m.format(f)
+	return f.String()
+}
+
+// GoString returns NS_Record as a Go composite literal, for %#v
+func (m *NS_Record) GoString() string {
+	if m == nil {
+		return "(*NS_Record)(nil)"
+	}
+	f := &runtime.Formatter{GoSyntax: true}
+	f.Pointer()
+	m.format(f)
+	return f.String()
+}
+
+func (m *NS_Record) format(f *runtime.Formatter) {
+	f.BeginStruct("NS_Record")
+	f.Field("nsdname", "Nsdname")
+	m.Nsdname.format(f)
+	f.EndStruct()
+}
+
+// Equal reports whether m and other hold the same values. Floats are compared bit for
+// bit, so NaN equals itself, and a nil slice equals an empty one. A lazy field that
+// fails to decode equals nothing.
+func (m *NS_Record) Equal(other *NS_Record) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if !m.Nsdname.Equal(&other.Nsdname) {
+		return false
+	}
+	return true
+}
+
+// Hash returns a hash of m that is the same for values Equal reports equal, on every
+// platform and in every process
+func (m *NS_Record) Hash() uint64 {
+	h := runtime.NewHasher()
+	m.hash(h)
+	return h.Sum64()
+}
+
+func (m *NS_Record) hash(h *runtime.Hasher) {
+	if m == nil {
+		h.Uint(0)
+		return
+	}
+	h.Uint(1)
+	m.Nsdname.hash(h)
+}
+
+// MarshalJSON encodes NS_Record with schema field names; byte arrays become arrays of numbers
+func (m NS_Record) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Nsdname DomainName `json:"nsdname"`
+	}{
+		Nsdname: m.Nsdname,
+	})
+}
+
+// UnmarshalJSON decodes NS_Record from the JSON MarshalJSON produces
+func (m *NS_Record) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Nsdname DomainName `json:"nsdname"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m.Nsdname = v.Nsdname
+	return nil
+}
+
+// Clone returns a deep copy of NS_Record that shares no memory with m or the input it was decoded from
+func (m *NS_Record) Clone() *NS_Record {
+	if m == nil {
+		return nil
+	}
+	out := *m
+	out.Nsdname = *m.Nsdname.Clone()
+	return &out
+}
+
+// PTR_Record: PTR record RDATA - pointer to domain name (TYPE=12)
+type PTR_Record struct {
+	// A domain-name pointer which points to some other location in the domain name
+	// space
+	Ptrdname DomainName
+}
+
+func (m *PTR_Record) Encode() ([]byte, error) {
+	return m.EncodeWithContext(nil)
+}
+
+// EncodeWithLimit encodes like Encode, but fails with runtime.ErrOutputTooLarge as
+// soon as the output passes maxBytes, so a message too large to send isn't encoded
+// to the end first
+func (m *PTR_Record) EncodeWithLimit(maxBytes int) ([]byte, error) {
+	return m.EncodeWithContext(runtime.NewEncodingContext(runtime.OutputLimit(maxBytes)))
+}
+
+func (m *PTR_Record) EncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error) {
+	encoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	encoder.SetLimit(ctx.OutputRemaining())
+
+	Ptrdname_bytes, err := m.Ptrdname.EncodeWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	encoder.WriteAlignedBytes(Ptrdname_bytes)
+
+	return encoder.Result()
+}
+
+func DecodePTR_Record(bytes []byte) (*PTR_Record, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodePTR_RecordWithDecoder(decoder, nil)
+}
+
+func decodePTR_RecordWithDecoder(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext) (*PTR_Record, error) {
+	return decodePTR_RecordInto(decoder, ctx, runtime.ArenaNew[PTR_Record](decoder.Arena))
+}
+
+func decodePTR_RecordInto(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *PTR_Record) (*PTR_Record, error) {
+	if decoder.Trace != nil {
+		return decodePTR_RecordIntoTraced(decoder, ctx, result)
+	}
+	const traceEnabled = runtime.TraceEnabled
+
+	*result = PTR_Record{Ptrdname: result.Ptrdname}
+
+	if traceEnabled {
+		decoder.TraceEnter("ptrdname")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Ptrdname); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Ptrdname)
+	}
+
+	return result, nil
+}
+
+// decodePTR_RecordIntoTraced is decodePTR_RecordInto recording each read in decoder.Trace
+func decodePTR_RecordIntoTraced(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *PTR_Record) (*PTR_Record, error) {
+	const traceEnabled = true
+
+	*result = PTR_Record{Ptrdname: result.Ptrdname}
+
+	if traceEnabled {
+		decoder.TraceEnter("ptrdname")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Ptrdname); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Ptrdname)
+	}
+
+	return result, nil
+}
+
+// String returns a readable one-line rendering of PTR_Record using schema field names
+func (m *PTR_Record) String() string {
+	if m == nil {
+		return "nil"
+	}
+	f := &runtime.Formatter{}
+	m.format(f)
+	return f.String()
+}
+
+// GoString returns PTR_Record as a Go composite literal, for %#v
+func (m *PTR_Record) GoString() string {
+	if m == nil {
+		return "(*PTR_Record)(nil)"
+	}
+	f := &runtime.Formatter{GoSyntax: true}
+	f.Pointer()
+	m.format(f)
+	return f.String()
+}
+
+func (m *PTR_Record) format(f *runtime.Formatter) {
+	f.BeginStruct("PTR_Record")
+	f.Field("ptrdname", "Ptrdname")
+	m.Ptrdname.format(f)
+	f.EndStruct()
+}
+
+// Equal reports whether m and other hold the same values. Floats are compared bit for
+// bit, so NaN equals itself, and a nil slice equals an empty one. A lazy field that
+// fails to decode equals nothing.
+func (m *PTR_Record) Equal(other *PTR_Record) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if !m.Ptrdname.Equal(&other.Ptrdname) {
+		return false
+	}
+	return true
+}
+
+// Hash returns a hash of m that is the same for values Equal reports equal, on every
+// platform and in every process
+func (m *PTR_Record) Hash() uint64 {
+	h := runtime.NewHasher()
+	m.hash(h)
+	return h.Sum64()
+}
+
+func (m *PTR_Record) hash(h *runtime.Hasher) {
+	if m == nil {
+		h.Uint(0)
+		return
+	}
+	h.Uint(1)
+	m.Ptrdname.hash(h)
+}
+
+// MarshalJSON encodes PTR_Record with schema field names; byte arrays become arrays of numbers
+func (m PTR_Record) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Ptrdname DomainName `json:"ptrdname"`
+	}{
+		Ptrdname: m.Ptrdname,
+	})
+}
+
+// UnmarshalJSON decodes PTR_Record from the JSON MarshalJSON produces
+func (m *PTR_Record) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Ptrdname DomainName `json:"ptrdname"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m.Ptrdname = v.Ptrdname
+	return nil
+}
+
+// Clone returns a deep copy of PTR_Record that shares no memory with m or the input it was decoded from
+func (m *PTR_Record) Clone() *PTR_Record {
+	if m == nil {
+		return nil
+	}
+	out := *m
+	out.Ptrdname = *m.Ptrdname.Clone()
+	return &out
+}
+
+// Pointer: DNS compression pointer - 2 bytes where top 2 bits are 11, followed by
+// 14-bit offset from start of DNS message
+type Pointer struct {
+	Value uint16
+}
+
+func (m *Pointer) Encode() ([]byte, error) {
+	return m.EncodeWithContext(nil)
+}
+
+// EncodeWithLimit encodes like Encode, but fails with runtime.ErrOutputTooLarge as
+// soon as the output passes maxBytes, so a message too large to send isn't encoded
+// to the end first
+func (m *Pointer) EncodeWithLimit(maxBytes int) ([]byte, error) {
+	return m.EncodeWithContext(runtime.NewEncodingContext(runtime.OutputLimit(maxBytes)))
+}
+
+func (m *Pointer) EncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error) {
+	encoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	encoder.SetLimit(ctx.OutputRemaining())
+
+	encoder.WriteUint16(m.Value, runtime.BigEndian)
+
+	return encoder.Result()
+}
+
+func DecodePointer(bytes []byte) (*Pointer, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodePointerWithDecoder(decoder, nil)
+}
+
+func decodePointerWithDecoder(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext) (*Pointer, error) {
+	return decodePointerInto(decoder, ctx, runtime.ArenaNew[Pointer](decoder.Arena))
+}
+
+func decodePointerInto(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *Pointer) (*Pointer, error) {
+	if decoder.Trace != nil {
+		return decodePointerIntoTraced(decoder, ctx, result)
+	}
+	const traceEnabled = runtime.TraceEnabled
+
+	*result = Pointer{}
+
+	if span, ok := decoder.PeekAligned(2); ok && !traceEnabled {
+		decodePointerFrom(span, result)
+		decoder.SkipBytes(2)
+		return result, nil
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("value")
+	}
+	value, err := decoder.ReadUint16(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	result.Value = value
+	if traceEnabled {
+		decoder.TraceLeave(result.Value)
+	}
+
+	return result, nil
+}
+
+// decodePointerIntoTraced is decodePointerInto recording each read in decoder.Trace
+func decodePointerIntoTraced(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *Pointer) (*Pointer, error) {
+	const traceEnabled = true
+
+	*result = Pointer{}
+
+	if span, ok := decoder.PeekAligned(2); ok && !traceEnabled {
+		decodePointerFrom(span, result)
+		decoder.SkipBytes(2)
+		return result, nil
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("value")
+	}
+	value, err := decoder.ReadUint16(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	result.Value = value
+	if traceEnabled {
+		decoder.TraceLeave(result.Value)
+	}
+
+	return result, nil
+}
+
+// decodePointerFrom decodes a Pointer from the first 2 bytes of span, which the caller has checked are there
+func decodePointerFrom(span []byte, result *Pointer) {
+	_ = span[1]
+	result.Value = binary.BigEndian.Uint16(span[0:])
+}
+
+// String returns a readable one-line rendering of Pointer using schema field names
+func (m *Pointer) String() string {
+	if m == nil {
+		return "nil"
+	}
+	f := &runtime.Formatter{}
+	m.format(f)
+	return f.String()
+}
+
+// GoString returns Pointer as a Go composite literal, for %#v
+func (m *Pointer) GoString() string {
+	if m == nil {
+		return "(*Pointer)(nil)"
+	}
+	f := &runtime.Formatter{GoSyntax: true}
+	f.Pointer()
+	m.format(f)
+	return f.String()
+}
+
+func (m *Pointer) format(f *runtime.Formatter) {
+	f.BeginStruct("Pointer")
+	f.Field("value", "Value")
+	f.Value(m.Value)
+	f.EndStruct()
+}
+
+// Equal reports whether m and other hold the same values. Floats are compared bit for
+// bit, so NaN equals itself, and a nil slice equals an empty one. A lazy field that
+// fails to decode equals nothing.
+func (m *Pointer) Equal(other *Pointer) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if m.Value != other.Value {
+		return false
+	}
+	return true
+}
+
+// Hash returns a hash of m that is the same for values Equal reports equal, on every
+// platform and in every process
+func (m *Pointer) Hash() uint64 {
+	h := runtime.NewHasher()
+	m.hash(h)
+	return h.Sum64()
+}
+
+func (m *Pointer) hash(h *runtime.Hasher) {
+	if m == nil {
+		h.Uint(0)
+		return
+	}
+	h.Uint(1)
+	h.Uint(uint64(m.Value))
+}
+
+// MarshalJSON encodes Pointer with schema field names; byte arrays become arrays of numbers
+func (m Pointer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Value uint16 `json:"value"`
+	}{
+		Value: m.Value,
+	})
+}
+
+// UnmarshalJSON decodes Pointer from the JSON MarshalJSON produces
+func (m *Pointer) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Value uint16 `json:"value"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m.Value = v.Value
+	return nil
+}
+
+// Clone returns a deep copy of Pointer that shares no memory with m or the input it was decoded from
+func (m *Pointer) Clone() *Pointer {
+	if m == nil {
+		return nil
+	}
+	out := *m
+	return &out
+}
+
+// Question: Question section entry - what is being asked
+type Question struct {
+	// A domain name represented as a sequence of labels. Each label consists of a
+	// length octet followed by that number of octets. The domain name terminates with
+	// the zero length octet for the null label of the root. May be an odd number of
+	// octets; no padding is used
+	Qname DomainName
+	// A two-octet code which specifies the type of the query. Values include all TYPE
+	// codes plus some more general codes which can match more than one type: 1=A,
+	// 2=NS, 5=CNAME, 6=SOA, 12=PTR, 15=MX, 16=TXT, 28=AAAA, 252=AXFR (zone transfer),
+	// 255=* (all records)
+	Qtype uint16
+	// A two-octet code that specifies the class of the query. 1=IN (Internet), 2=CS
+	// (CSNET, obsolete), 3=CH (CHAOS), 4=HS (Hesiod), 255=* (any class)
+	Qclass uint16
+}
+
+func (m *Question) Encode() ([]byte, error) {
+	return m.EncodeWithContext(nil)
+}
+
+// EncodeWithLimit encodes like Encode, but fails with runtime.ErrOutputTooLarge as
+// soon as the output passes maxBytes, so a message too large to send isn't encoded
+// to the end first
+func (m *Question) EncodeWithLimit(maxBytes int) ([]byte, error) {
+	return m.EncodeWithContext(runtime.NewEncodingContext(runtime.OutputLimit(maxBytes)))
+}
+
+func (m *Question) EncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error) {
+	encoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	encoder.SetLimit(ctx.OutputRemaining())
+
+	Qname_bytes, err := m.Qname.EncodeWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	encoder.WriteAlignedBytes(Qname_bytes)
+	encoder.WriteUint16(m.Qtype, runtime.BigEndian)
+	encoder.WriteUint16(m.Qclass, runtime.BigEndian)
+
+	return encoder.Result()
+}
+
+func DecodeQuestion(bytes []byte) (*Question, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodeQuestionWithDecoder(decoder, nil)
+}
+
+func decodeQuestionWithDecoder(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext) (*Question, error) {
+	return decodeQuestionInto(decoder, ctx, runtime.ArenaNew[Question](decoder.Arena))
+}
+
+func decodeQuestionInto(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *Question) (*Question, error) {
+	if decoder.Trace != nil {
+		return decodeQuestionIntoTraced(decoder, ctx, result)
+	}
+	const traceEnabled = runtime.TraceEnabled
+
+	*result = Question{Qname: result.Qname}
+
+	if traceEnabled {
+		decoder.TraceEnter("qname")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Qname); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Qname)
+	}
+
+	// qtype, qclass: 4 bytes
+	if span, ok := decoder.PeekAligned(4); ok && !traceEnabled {
+		_ = span[3]
+		result.Qtype = binary.BigEndian.Uint16(span[0:])
+		result.Qclass = binary.BigEndian.Uint16(span[2:])
+		decoder.SkipBytes(4)
+	} else {
+		if traceEnabled {
+			decoder.TraceEnter("qtype")
+		}
+		qtype, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Qtype = qtype
+		if traceEnabled {
+			decoder.TraceLeave(result.Qtype)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("qclass")
+		}
+		qclass, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Qclass = qclass
+		if traceEnabled {
+			decoder.TraceLeave(result.Qclass)
+		}
+	}
+
+	return result, nil
+}
+
+// decodeQuestionIntoTraced is decodeQuestionInto recording each read in decoder.Trace
+func decodeQuestionIntoTraced(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *Question) (*Question, error) {
+	const traceEnabled = true
+
+	*result = Question{Qname: result.Qname}
+
+	if traceEnabled {
+		decoder.TraceEnter("qname")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Qname); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Qname)
+	}
+
+	// qtype, qclass: 4 bytes
+	if span, ok := decoder.PeekAligned(4); ok && !traceEnabled {
+		_ = span[3]
+		result.Qtype = binary.BigEndian.Uint16(span[0:])
+		result.Qclass = binary.BigEndian.Uint16(span[2:])
+		decoder.SkipBytes(4)
+	} else {
+		if traceEnabled {
+			decoder.TraceEnter("qtype")
+		}
+		qtype, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Qtype = qtype
+		if traceEnabled {
+			decoder.TraceLeave(result.Qtype)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("qclass")
+		}
+		qclass, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Qclass = qclass
+		if traceEnabled {
+			decoder.TraceLeave(result.Qclass)
+		}
+	}
+
+	return result, nil
+}
+
+// String returns a readable one-line rendering of Question using schema field names
+func (m *Question) String() string {
+	if m == nil {
+		return "nil"
+	}
+	f := &runtime.Formatter{}
+	m.format(f)
+	return f.String()
+}
+
+// GoString returns Question as a Go composite literal, for %#v
+func (m *Question) GoString() string {
+	if m == nil {
+		return "(*Question)(nil)"
+	}
+	f := &runtime.Formatter{GoSyntax: true}
+	f.Pointer()
+	m.format(f)
+	return f.String()
+}
+
+func (m *Question) format(f *runtime.Formatter) {
+	f.BeginStruct("Question")
+	f.Field("qname", "Qname")
+	m.Qname.format(f)
+	f.Field("qtype", "Qtype")
+	f.Value(m.Qtype)
+	f.Field("qclass", "Qclass")
+	f.Value(m.Qclass)
+	f.EndStruct()
+}
+
+// Equal reports whether m and other hold the same values. Floats are compared bit for
+// bit, so NaN equals itself, and a nil slice equals an empty one. A lazy field that
+// fails to decode equals nothing.
+func (m *Question) Equal(other *Question) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if !m.Qname.Equal(&other.Qname) {
+		return false
+	}
+	if m.Qtype != other.Qtype {
+		return false
+	}
+	if m.Qclass != other.Qclass {
+		return false
+	}
+	return true
+}
+
+// Hash returns a hash of m that is the same for values Equal reports equal, on every
+// platform and in every process
+func (m *Question) Hash() uint64 {
+	h := runtime.NewHasher()
+	m.hash(h)
+	return h.Sum64()
+}
+
+func (m *Question) hash(h *runtime.Hasher) {
+	if m == nil {
+		h.Uint(0)
+		return
+	}
+	h.Uint(1)
+	m.Qname.hash(h)
+	h.Uint(uint64(m.Qtype))
+	h.Uint(uint64(m.Qclass))
+}
+
+// MarshalJSON encodes Question with schema field names; byte arrays become arrays of numbers
+func (m Question) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Qname  DomainName `json:"qname"`
+		Qtype  uint16     `json:"qtype"`
+		Qclass uint16     `json:"qclass"`
+	}{
+		Qname:  m.Qname,
+		Qtype:  m.Qtype,
+		Qclass: m.Qclass,
+	})
+}
+
+// UnmarshalJSON decodes Question from the JSON MarshalJSON produces
+func (m *Question) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Qname  DomainName `json:"qname"`
+		Qtype  uint16     `json:"qtype"`
+		Qclass uint16     `json:"qclass"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m.Qname = v.Qname
+	m.Qtype = v.Qtype
+	m.Qclass = v.Qclass
+	return nil
+}
+
+// Clone returns a deep copy of Question that shares no memory with m or the input it was decoded from
+func (m *Question) Clone() *Question {
+	if m == nil {
+		return nil
+	}
+	out := *m
+	out.Qname = *m.Qname.Clone()
+	return &out
+}
+
+// ResourceRecord: Resource record - answer/authority/additional section entry
+type ResourceRecord struct {
+	// An owner name - the domain name to which this resource record pertains. Can be a
+	// domain name or a compression pointer (2 bytes starting with bits 11)
+	Name DomainName
+	// Two octets containing one of the RR TYPE codes. Specifies the meaning of the
+	// data in the RDATA field. Common types: 1=A (host address), 2=NS (authoritative
+	// name server), 5=CNAME (canonical name), 6=SOA (start of authority), 12=PTR
+	// (domain name pointer), 15=MX (mail exchange), 16=TXT (text strings), 28=AAAA
+	// (IPv6 address)
+	Rtype uint16
+	// Two octets which specify the class of the data in the RDATA field. 1=IN
+	// (Internet), 3=CH (CHAOS), 4=HS (Hesiod)
+	Rclass uint16
+	// A 32-bit unsigned integer that specifies the time interval (in seconds) that the
+	// resource record may be cached before it should be discarded. Zero values mean
+	// the RR can only be used for the transaction in progress and should not be cached
+	Ttl uint32
+	// An unsigned 16-bit integer that specifies the length in octets of the RDATA
+	// field
+	Rdlength uint16
+	Rdata    []uint8
+}
+
+func (m *ResourceRecord) Encode() ([]byte, error) {
+	return m.EncodeWithContext(nil)
+}
+
+// EncodeWithLimit encodes like Encode, but fails with runtime.ErrOutputTooLarge as
+// soon as the output passes maxBytes, so a message too large to send isn't encoded
+// to the end first
+func (m *ResourceRecord) EncodeWithLimit(maxBytes int) ([]byte, error) {
+	return m.EncodeWithContext(runtime.NewEncodingContext(runtime.OutputLimit(maxBytes)))
+}
+
+func (m *ResourceRecord) EncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error) {
+	encoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	encoder.SetLimit(ctx.OutputRemaining())
+
+	Name_bytes, err := m.Name.EncodeWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	encoder.WriteAlignedBytes(Name_bytes)
+	encoder.WriteUint16(m.Rtype, runtime.BigEndian)
+	encoder.WriteUint16(m.Rclass, runtime.BigEndian)
+	encoder.WriteUint32(m.Ttl, runtime.BigEndian)
+	encoder.WriteUint16(m.Rdlength, runtime.BigEndian)
+	encoder.WriteAlignedBytes(m.Rdata)
+
+	return encoder.Result()
+}
+
+func DecodeResourceRecord(bytes []byte) (*ResourceRecord, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodeResourceRecordWithDecoder(decoder, nil)
+}
+
+func decodeResourceRecordWithDecoder(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext) (*ResourceRecord, error) {
+	return decodeResourceRecordInto(decoder, ctx, runtime.ArenaNew[ResourceRecord](decoder.Arena))
+}
+
+func decodeResourceRecordInto(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *ResourceRecord) (*ResourceRecord, error) {
+	if decoder.Trace != nil {
+		return decodeResourceRecordIntoTraced(decoder, ctx, result)
+	}
+	const traceEnabled = runtime.TraceEnabled
+
+	*result = ResourceRecord{Name: result.Name, Rdata: result.Rdata[:0]}
+
+	if traceEnabled {
+		decoder.TraceEnter("name")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Name); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Name)
+	}
+
+	// rtype, rclass, ttl, rdlength: 10 bytes
+	if span, ok := decoder.PeekAligned(10); ok && !traceEnabled {
+		_ = span[9]
+		result.Rtype = binary.BigEndian.Uint16(span[0:])
+		result.Rclass = binary.BigEndian.Uint16(span[2:])
+		result.Ttl = binary.BigEndian.Uint32(span[4:])
+		result.Rdlength = binary.BigEndian.Uint16(span[8:])
+		decoder.SkipBytes(10)
+	} else {
+		if traceEnabled {
+			decoder.TraceEnter("rtype")
+		}
+		rtype, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Rtype = rtype
+		if traceEnabled {
+			decoder.TraceLeave(result.Rtype)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("rclass")
+		}
+		rclass, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Rclass = rclass
+		if traceEnabled {
+			decoder.TraceLeave(result.Rclass)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("ttl")
+		}
+		ttl, err := decoder.ReadUint32(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Ttl = ttl
+		if traceEnabled {
+			decoder.TraceLeave(result.Ttl)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("rdlength")
+		}
+		rdlength, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Rdlength = rdlength
+		if traceEnabled {
+			decoder.TraceLeave(result.Rdlength)
+		}
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("rdata")
+	}
+	rdata_computed_length := int64(result.Rdlength)
+	if rdata_computed_length < 0 {
+		return nil, fmt.Errorf("rdata: negative length %d from %q", rdata_computed_length, "rdlength")
+	}
+	if rdata_computed_length > int64(decoder.Len()-decoder.Position())*8 {
+		return nil, decoder.Incomplete(fmt.Errorf("rdata: length %d exceeds remaining data", rdata_computed_length))
+	}
+	result.Rdata = runtime.Reuse(decoder.Arena, result.Rdata, int(rdata_computed_length))
+	for i := range result.Rdata {
+		if err := decoder.CheckCanceled(); err != nil {
+			return nil, err
+		}
+		if traceEnabled {
+			decoder.TraceEnterItem(i)
+		}
+		rdata_item, err := decoder.ReadUint8()
+		if err != nil {
+			return nil, err
+		}
+		if traceEnabled {
+			decoder.TraceLeave(rdata_item)
+		}
+		result.Rdata[i] = rdata_item
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Rdata)
+	}
+
+	return result, nil
+}
+
+// decodeResourceRecordIntoTraced is decodeResourceRecordInto recording each read in decoder.Trace
+func decodeResourceRecordIntoTraced(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *ResourceRecord) (*ResourceRecord, error) {
+	const traceEnabled = true
+
+	*result = ResourceRecord{Name: result.Name, Rdata: result.Rdata[:0]}
+
+	if traceEnabled {
+		decoder.TraceEnter("name")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Name); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Name)
+	}
+
+	// rtype, rclass, ttl, rdlength: 10 bytes
+	if span, ok := decoder.PeekAligned(10); ok && !traceEnabled {
+		_ = span[9]
+		result.Rtype = binary.BigEndian.Uint16(span[0:])
+		result.Rclass = binary.BigEndian.Uint16(span[2:])
+		result.Ttl = binary.BigEndian.Uint32(span[4:])
+		result.Rdlength = binary.BigEndian.Uint16(span[8:])
+		decoder.SkipBytes(10)
+	} else {
+		if traceEnabled {
+			decoder.TraceEnter("rtype")
+		}
+		rtype, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Rtype = rtype
+		if traceEnabled {
+			decoder.TraceLeave(result.Rtype)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("rclass")
+		}
+		rclass, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Rclass = rclass
+		if traceEnabled {
+			decoder.TraceLeave(result.Rclass)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("ttl")
+		}
+		ttl, err := decoder.ReadUint32(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Ttl = ttl
+		if traceEnabled {
+			decoder.TraceLeave(result.Ttl)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("rdlength")
+		}
+		rdlength, err := decoder.ReadUint16(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Rdlength = rdlength
+		if traceEnabled {
+			decoder.TraceLeave(result.Rdlength)
+		}
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("rdata")
+	}
+	rdata_computed_length := int64(result.Rdlength)
+	if rdata_computed_length < 0 {
+		return nil, fmt.Errorf("rdata: negative length %d from %q", rdata_computed_length, "rdlength")
+	}
+	if rdata_computed_length > int64(decoder.Len()-decoder.Position())*8 {
+		return nil, decoder.Incomplete(fmt.Errorf("rdata: length %d exceeds remaining data", rdata_computed_length))
+	}
+	result.Rdata = runtime.Reuse(decoder.Arena, result.Rdata, int(rdata_computed_length))
+	for i := range result.Rdata {
+		if err := decoder.CheckCanceled(); err != nil {
+			return nil, err
+		}
+		if traceEnabled {
+			decoder.TraceEnterItem(i)
+		}
+		rdata_item, err := decoder.ReadUint8()
+		if err != nil {
+			return nil, err
+		}
+		if traceEnabled {
+			decoder.TraceLeave(rdata_item)
+		}
+		result.Rdata[i] = rdata_item
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Rdata)
+	}
+
+	return result, nil
+}
+
+// DecodeResourceRecordRdataEach decodes bytes like DecodeResourceRecord, but passes each item of rdata to fn as
+// it is decoded instead of collecting them: Rdata is left empty. The first error fn
+// returns stops decoding and is returned as is
+func DecodeResourceRecordRdataEach(bytes []byte, fn func(item uint8) error) (*ResourceRecord, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodeResourceRecordRdataEach(decoder, nil, fn)
+}
+
+func decodeResourceRecordRdataEach(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, fn func(item uint8) error) (*ResourceRecord, error) {
+	const traceEnabled = runtime.TraceEnabled
+	result := &ResourceRecord{}
+
+	if traceEnabled {
+		decoder.TraceEnter("name")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Name); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Name)
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("rtype")
+	}
+	rtype, err := decoder.ReadUint16(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	result.Rtype = rtype
+	if traceEnabled {
+		decoder.TraceLeave(result.Rtype)
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("rclass")
+	}
+	rclass, err := decoder.ReadUint16(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	result.Rclass = rclass
+	if traceEnabled {
+		decoder.TraceLeave(result.Rclass)
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("ttl")
+	}
+	ttl, err := decoder.ReadUint32(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	result.Ttl = ttl
+	if traceEnabled {
+		decoder.TraceLeave(result.Ttl)
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("rdlength")
+	}
+	rdlength, err := decoder.ReadUint16(runtime.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+	result.Rdlength = rdlength
+	if traceEnabled {
+		decoder.TraceLeave(result.Rdlength)
+	}
+
+	rdata_computed_length := int64(result.Rdlength)
+	if rdata_computed_length < 0 {
+		return nil, fmt.Errorf("rdata: negative length %d from %q", rdata_computed_length, "rdlength")
+	}
+	if rdata_computed_length > int64(decoder.Len()-decoder.Position())*8 {
+		return nil, decoder.Incomplete(fmt.Errorf("rdata: length %d exceeds remaining data", rdata_computed_length))
+	}
+	for i := int64(0); i < rdata_computed_length; i++ {
+		if err := decoder.CheckCanceled(); err != nil {
+			return nil, err
+		}
+		rdata_item, err := decoder.ReadUint8()
+		if err != nil {
+			return nil, err
+		}
+		if err := fn(rdata_item); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// String returns a readable one-line rendering of ResourceRecord using schema field names
+func (m *ResourceRecord) String() string {
+	if m == nil {
+		return "nil"
+	}
+	f := &runtime.Formatter{}
+	m.format(f)
+	return f.String()
+}
+
+// GoString returns ResourceRecord as a Go composite literal, for %#v
+func (m *ResourceRecord) GoString() string {
+	if m == nil {
+		return "(*ResourceRecord)(nil)"
+	}
+	f := &runtime.Formatter{GoSyntax: true}
+	f.Pointer()
+	m.format(f)
+	return f.String()
+}
+
+func (m *ResourceRecord) format(f *runtime.Formatter) {
+	f.BeginStruct("ResourceRecord")
+	f.Field("name", "Name")
+	m.Name.format(f)
+	f.Field("rtype", "Rtype")
+	f.Value(m.Rtype)
+	f.Field("rclass", "Rclass")
+	f.Value(m.Rclass)
+	f.Field("ttl", "Ttl")
+	f.Value(m.Ttl)
+	f.Field("rdlength", "Rdlength")
+	f.Value(m.Rdlength)
+	f.Field("rdata", "Rdata")
+	f.Value(m.Rdata)
+	f.EndStruct()
+}
+
+// Equal reports whether m and other hold the same values. Floats are compared bit for
+// bit, so NaN equals itself, and a nil slice equals an empty one. A lazy field that
+// fails to decode equals nothing.
+func (m *ResourceRecord) Equal(other *ResourceRecord) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if !m.Name.Equal(&other.Name) {
+		return false
+	}
+	if m.Rtype != other.Rtype {
+		return false
+	}
+	if m.Rclass != other.Rclass {
+		return false
+	}
+	if m.Ttl != other.Ttl {
+		return false
+	}
+	if m.Rdlength != other.Rdlength {
+		return false
+	}
+	if string(m.Rdata) != string(other.Rdata) {
+		return false
+	}
+	return true
+}
+
+// Hash returns a hash of m that is the same for values Equal reports equal, on every
+// platform and in every process
+func (m *ResourceRecord) Hash() uint64 {
+	h := runtime.NewHasher()
+	m.hash(h)
+	return h.Sum64()
+}
+
+func (m *ResourceRecord) hash(h *runtime.Hasher) {
+	if m == nil {
+		h.Uint(0)
+		return
+	}
+	h.Uint(1)
+	m.Name.hash(h)
+	h.Uint(uint64(m.Rtype))
+	h.Uint(uint64(m.Rclass))
+	h.Uint(uint64(m.Ttl))
+	h.Uint(uint64(m.Rdlength))
+	h.Bytes(m.Rdata)
+}
+
+// MarshalJSON encodes ResourceRecord with schema field names; byte arrays become arrays of numbers
+func (m ResourceRecord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Name     DomainName        `json:"name"`
+		Rtype    uint16            `json:"rtype"`
+		Rclass   uint16            `json:"rclass"`
+		Ttl      uint32            `json:"ttl"`
+		Rdlength uint16            `json:"rdlength"`
+		Rdata    runtime.JSONBytes `json:"rdata"`
+	}{
+		Name:     m.Name,
+		Rtype:    m.Rtype,
+		Rclass:   m.Rclass,
+		Ttl:      m.Ttl,
+		Rdlength: m.Rdlength,
+		Rdata:    runtime.JSONBytes(m.Rdata),
+	})
+}
+
+// UnmarshalJSON decodes ResourceRecord from the JSON MarshalJSON produces
+func (m *ResourceRecord) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Name     DomainName        `json:"name"`
+		Rtype    uint16            `json:"rtype"`
+		Rclass   uint16            `json:"rclass"`
+		Ttl      uint32            `json:"ttl"`
+		Rdlength uint16            `json:"rdlength"`
+		Rdata    runtime.JSONBytes `json:"rdata"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m.Name = v.Name
+	m.Rtype = v.Rtype
+	m.Rclass = v.Rclass
+	m.Ttl = v.Ttl
+	m.Rdlength = v.Rdlength
+	m.Rdata = []uint8(v.Rdata)
+	return nil
+}
+
+// Clone returns a deep copy of ResourceRecord that shares no memory with m or the input it was decoded from
+func (m *ResourceRecord) Clone() *ResourceRecord {
+	if m == nil {
+		return nil
+	}
+	out := *m
+	out.Name = *m.Name.Clone()
+	if m.Rdata != nil {
+		out.Rdata = make([]uint8, len(m.Rdata))
+		copy(out.Rdata, m.Rdata)
+	}
+	return &out
+}
+
+// SOA_Record: SOA record RDATA - start of authority (TYPE=6)
+type SOA_Record struct {
+	// The name of the primary master name server for the zone
+	Mname DomainName
+	// The mailbox of the person responsible for this zone
+	Rname DomainName
+	// The unsigned 32 bit version number of the original copy of the zone. Zone
+	// transfers preserve this value
+	Serial uint32
+	// The time interval before the zone should be refreshed
+	Refresh uint32
+	// The time interval that should elapse before a failed refresh should be retried
+	Retry uint32
+	// The upper limit on the time interval that can elapse before the zone is no
+	// longer authoritative
+	Expire uint32
+	// The minimum TTL field that should be exported with any RR from this zone
+	Minimum uint32
+}
+
+func (m *SOA_Record) Encode() ([]byte, error) {
+	return m.EncodeWithContext(nil)
+}
+
+// EncodeWithLimit encodes like Encode, but fails with runtime.ErrOutputTooLarge as
+// soon as the output passes maxBytes, so a message too large to send isn't encoded
+// to the end first
+func (m *SOA_Record) EncodeWithLimit(maxBytes int) ([]byte, error) {
+	return m.EncodeWithContext(runtime.NewEncodingContext(runtime.OutputLimit(maxBytes)))
+}
+
+func (m *SOA_Record) EncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error) {
+	encoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	encoder.SetLimit(ctx.OutputRemaining())
+
+	Mname_bytes, err := m.Mname.EncodeWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	encoder.WriteAlignedBytes(Mname_bytes)
+	Rname_bytes, err := m.Rname.EncodeWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	encoder.WriteAlignedBytes(Rname_bytes)
+	encoder.WriteUint32(m.Serial, runtime.BigEndian)
+	encoder.WriteUint32(m.Refresh, runtime.BigEndian)
+	encoder.WriteUint32(m.Retry, runtime.BigEndian)
+	encoder.WriteUint32(m.Expire, runtime.BigEndian)
+	encoder.WriteUint32(m.Minimum, runtime.BigEndian)
+
+	return encoder.Result()
+}
+
+func DecodeSOA_Record(bytes []byte) (*SOA_Record, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodeSOA_RecordWithDecoder(decoder, nil)
+}
+
+func decodeSOA_RecordWithDecoder(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext) (*SOA_Record, error) {
+	return decodeSOA_RecordInto(decoder, ctx, runtime.ArenaNew[SOA_Record](decoder.Arena))
+}
+
+func decodeSOA_RecordInto(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *SOA_Record) (*SOA_Record, error) {
+	if decoder.Trace != nil {
+		return decodeSOA_RecordIntoTraced(decoder, ctx, result)
+	}
+	const traceEnabled = runtime.TraceEnabled
+
+	*result = SOA_Record{Mname: result.Mname, Rname: result.Rname}
+
+	if traceEnabled {
+		decoder.TraceEnter("mname")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Mname); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Mname)
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("rname")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Rname); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Rname)
+	}
+
+	// serial, refresh, retry, expire, minimum: 20 bytes
+	if span, ok := decoder.PeekAligned(20); ok && !traceEnabled {
+		_ = span[19]
+		result.Serial = binary.BigEndian.Uint32(span[0:])
+		result.Refresh = binary.BigEndian.Uint32(span[4:])
+		result.Retry = binary.BigEndian.Uint32(span[8:])
+		result.Expire = binary.BigEndian.Uint32(span[12:])
+		result.Minimum = binary.BigEndian.Uint32(span[16:])
+		decoder.SkipBytes(20)
+	} else {
+		if traceEnabled {
+			decoder.TraceEnter("serial")
+		}
+		serial, err := decoder.ReadUint32(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Serial = serial
+		if traceEnabled {
+			decoder.TraceLeave(result.Serial)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("refresh")
+		}
+		refresh, err := decoder.ReadUint32(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Refresh = refresh
+		if traceEnabled {
+			decoder.TraceLeave(result.Refresh)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("retry")
+		}
+		retry, err := decoder.ReadUint32(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Retry = retry
+		if traceEnabled {
+			decoder.TraceLeave(result.Retry)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("expire")
+		}
+		expire, err := decoder.ReadUint32(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Expire = expire
+		if traceEnabled {
+			decoder.TraceLeave(result.Expire)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("minimum")
+		}
+		minimum, err := decoder.ReadUint32(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Minimum = minimum
+		if traceEnabled {
+			decoder.TraceLeave(result.Minimum)
+		}
+	}
+
+	return result, nil
+}
+
+// decodeSOA_RecordIntoTraced is decodeSOA_RecordInto recording each read in decoder.Trace
+func decodeSOA_RecordIntoTraced(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *SOA_Record) (*SOA_Record, error) {
+	const traceEnabled = true
+
+	*result = SOA_Record{Mname: result.Mname, Rname: result.Rname}
+
+	if traceEnabled {
+		decoder.TraceEnter("mname")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Mname); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Mname)
+	}
+
+	if traceEnabled {
+		decoder.TraceEnter("rname")
+	}
+	if _, err := decodeDomainNameInto(decoder, ctx, &result.Rname); err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Rname)
+	}
+
+	// serial, refresh, retry, expire, minimum: 20 bytes
+	if span, ok := decoder.PeekAligned(20); ok && !traceEnabled {
+		_ = span[19]
+		result.Serial = binary.BigEndian.Uint32(span[0:])
+		result.Refresh = binary.BigEndian.Uint32(span[4:])
+		result.Retry = binary.BigEndian.Uint32(span[8:])
+		result.Expire = binary.BigEndian.Uint32(span[12:])
+		result.Minimum = binary.BigEndian.Uint32(span[16:])
+		decoder.SkipBytes(20)
+	} else {
+		if traceEnabled {
+			decoder.TraceEnter("serial")
+		}
+		serial, err := decoder.ReadUint32(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Serial = serial
+		if traceEnabled {
+			decoder.TraceLeave(result.Serial)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("refresh")
+		}
+		refresh, err := decoder.ReadUint32(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Refresh = refresh
+		if traceEnabled {
+			decoder.TraceLeave(result.Refresh)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("retry")
+		}
+		retry, err := decoder.ReadUint32(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Retry = retry
+		if traceEnabled {
+			decoder.TraceLeave(result.Retry)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("expire")
+		}
+		expire, err := decoder.ReadUint32(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Expire = expire
+		if traceEnabled {
+			decoder.TraceLeave(result.Expire)
+		}
+		if traceEnabled {
+			decoder.TraceEnter("minimum")
+		}
+		minimum, err := decoder.ReadUint32(runtime.BigEndian)
+		if err != nil {
+			return nil, err
+		}
+		result.Minimum = minimum
+		if traceEnabled {
+			decoder.TraceLeave(result.Minimum)
+		}
+	}
+
+	return result, nil
+}
+
+// String returns a readable one-line rendering of SOA_Record using schema field names
+func (m *SOA_Record) String() string {
+	if m == nil {
+		return "nil"
+	}
+	f := &runtime.Formatter{}
+	m.format(f)
+	return f.String()
+}
+
+// GoString returns SOA_Record as a Go composite literal, for %#v
+func (m *SOA_Record) GoString() string {
+	if m == nil {
+		return "(*SOA_Record)(nil)"
+	}
+	f := &runtime.Formatter{GoSyntax: true}
+	f.Pointer()
+	m.format(f)
+	return f.String()
+}
+
+func (m *SOA_Record) format(f *runtime.Formatter) {
+	f.BeginStruct("SOA_Record")
+	f.Field("mname", "Mname")
+	m.Mname.format(f)
+	f.Field("rname", "Rname")
+	m.Rname.format(f)
+	f.Field("serial", "Serial")
+	f.Value(m.Serial)
+	f.Field("refresh", "Refresh")
+	f.Value(m.Refresh)
+	f.Field("retry", "Retry")
+	f.Value(m.Retry)
+	f.Field("expire", "Expire")
+	f.Value(m.Expire)
+	f.Field("minimum", "Minimum")
+	f.Value(m.Minimum)
+	f.EndStruct()
+}
+
+// Equal reports whether m and other hold the same values. Floats are compared bit for
+// bit, so NaN equals itself, and a nil slice equals an empty one. A lazy field that
+// fails to decode equals nothing.
+func (m *SOA_Record) Equal(other *SOA_Record) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if !m.Mname.Equal(&other.Mname) {
+		return false
+	}
+	if !m.Rname.Equal(&other.Rname) {
+		return false
+	}
+	if m.Serial != other.Serial {
+		return false
+	}
+	if m.Refresh != other.Refresh {
+		return false
+	}
+	if m.Retry != other.Retry {
+		return false
+	}
+	if m.Expire != other.Expire {
+		return false
+	}
+	if m.Minimum != other.Minimum {
+		return false
+	}
+	return true
+}
+
+// Hash returns a hash of m that is the same for values Equal reports equal, on every
+// platform and in every process
+func (m *SOA_Record) Hash() uint64 {
+	h := runtime.NewHasher()
+	m.hash(h)
+	return h.Sum64()
+}
+
+func (m *SOA_Record) hash(h *runtime.Hasher) {
+	if m == nil {
+		h.Uint(0)
+		return
+	}
+	h.Uint(1)
+	m.Mname.hash(h)
+	m.Rname.hash(h)
+	h.Uint(uint64(m.Serial))
+	h.Uint(uint64(m.Refresh))
+	h.Uint(uint64(m.Retry))
+	h.Uint(uint64(m.Expire))
+	h.Uint(uint64(m.Minimum))
+}
+
+// MarshalJSON encodes SOA_Record with schema field names; byte arrays become arrays of numbers
+func (m SOA_Record) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Mname   DomainName `json:"mname"`
+		Rname   DomainName `json:"rname"`
+		Serial  uint32     `json:"serial"`
+		Refresh uint32     `json:"refresh"`
+		Retry   uint32     `json:"retry"`
+		Expire  uint32     `json:"expire"`
+		Minimum uint32     `json:"minimum"`
+	}{
+		Mname:   m.Mname,
+		Rname:   m.Rname,
+		Serial:  m.Serial,
+		Refresh: m.Refresh,
+		Retry:   m.Retry,
+		Expire:  m.Expire,
+		Minimum: m.Minimum,
+	})
+}
+
+// UnmarshalJSON decodes SOA_Record from the JSON MarshalJSON produces
+func (m *SOA_Record) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Mname   DomainName `json:"mname"`
+		Rname   DomainName `json:"rname"`
+		Serial  uint32     `json:"serial"`
+		Refresh uint32     `json:"refresh"`
+		Retry   uint32     `json:"retry"`
+		Expire  uint32     `json:"expire"`
+		Minimum uint32     `json:"minimum"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m.Mname = v.Mname
+	m.Rname = v.Rname
+	m.Serial = v.Serial
+	m.Refresh = v.Refresh
+	m.Retry = v.Retry
+	m.Expire = v.Expire
+	m.Minimum = v.Minimum
+	return nil
+}
+
+// Clone returns a deep copy of SOA_Record that shares no memory with m or the input it was decoded from
+func (m *SOA_Record) Clone() *SOA_Record {
+	if m == nil {
+		return nil
+	}
+	out := *m
+	out.Mname = *m.Mname.Clone()
+	out.Rname = *m.Rname.Clone()
+	return &out
+}
+
+// TXT_Record: TXT record RDATA - text strings (TYPE=16)
+type TXT_Record struct {
+	Value []uint8
+}
+
+func (m *TXT_Record) Encode() ([]byte, error) {
+	return m.EncodeWithContext(nil)
+}
+
+// EncodeWithLimit encodes like Encode, but fails with runtime.ErrOutputTooLarge as
+// soon as the output passes maxBytes, so a message too large to send isn't encoded
+// to the end first
+func (m *TXT_Record) EncodeWithLimit(maxBytes int) ([]byte, error) {
+	return m.EncodeWithContext(runtime.NewEncodingContext(runtime.OutputLimit(maxBytes)))
+}
+
+func (m *TXT_Record) EncodeWithContext(ctx *runtime.EncodingContext) ([]byte, error) {
+	encoder := runtime.NewBitStreamEncoder(runtime.MSBFirst)
+	encoder.SetLimit(ctx.OutputRemaining())
+
+	encoder.WriteUint8(uint8(len(m.Value)))
+	encoder.WriteAlignedBytes(m.Value)
+
+	return encoder.Result()
+}
+
+func DecodeTXT_Record(bytes []byte) (*TXT_Record, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodeTXT_RecordWithDecoder(decoder, nil)
+}
+
+func decodeTXT_RecordWithDecoder(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext) (*TXT_Record, error) {
+	return decodeTXT_RecordInto(decoder, ctx, runtime.ArenaNew[TXT_Record](decoder.Arena))
+}
+
+func decodeTXT_RecordInto(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *TXT_Record) (*TXT_Record, error) {
+	if decoder.Trace != nil {
+		return decodeTXT_RecordIntoTraced(decoder, ctx, result)
+	}
+	const traceEnabled = runtime.TraceEnabled
+
+	*result = TXT_Record{Value: result.Value[:0]}
+
+	if traceEnabled {
+		decoder.TraceEnter("value")
+	}
+	if traceEnabled {
+		decoder.TraceEnter("length")
+	}
+	length, err := decoder.ReadUint8()
+	if err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(length)
+	}
+	result.Value = runtime.Reuse(decoder.Arena, result.Value, int(length))
+	for i := range result.Value {
+		if err := decoder.CheckCanceled(); err != nil {
+			return nil, err
+		}
+		if traceEnabled {
+			decoder.TraceEnterItem(i)
+		}
+		value_item, err := decoder.ReadUint8()
+		if err != nil {
+			return nil, err
+		}
+		if traceEnabled {
+			decoder.TraceLeave(value_item)
+		}
+		result.Value[i] = value_item
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Value)
+	}
+
+	return result, nil
+}
+
+// decodeTXT_RecordIntoTraced is decodeTXT_RecordInto recording each read in decoder.Trace
+func decodeTXT_RecordIntoTraced(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, result *TXT_Record) (*TXT_Record, error) {
+	const traceEnabled = true
+
+	*result = TXT_Record{Value: result.Value[:0]}
+
+	if traceEnabled {
+		decoder.TraceEnter("value")
+	}
+	if traceEnabled {
+		decoder.TraceEnter("length")
+	}
+	length, err := decoder.ReadUint8()
+	if err != nil {
+		return nil, err
+	}
+	if traceEnabled {
+		decoder.TraceLeave(length)
+	}
+	result.Value = runtime.Reuse(decoder.Arena, result.Value, int(length))
+	for i := range result.Value {
+		if err := decoder.CheckCanceled(); err != nil {
+			return nil, err
+		}
+		if traceEnabled {
+			decoder.TraceEnterItem(i)
+		}
+		value_item, err := decoder.ReadUint8()
+		if err != nil {
+			return nil, err
+		}
+		if traceEnabled {
+			decoder.TraceLeave(value_item)
+		}
+		result.Value[i] = value_item
+	}
+	if traceEnabled {
+		decoder.TraceLeave(result.Value)
+	}
+
+	return result, nil
+}
+
+// DecodeTXT_RecordValueEach decodes bytes like DecodeTXT_Record, but passes each item of value to fn as
+// it is decoded instead of collecting them: Value is left empty. The first error fn
+// returns stops decoding and is returned as is
+func DecodeTXT_RecordValueEach(bytes []byte, fn func(item uint8) error) (*TXT_Record, error) {
+	decoder := runtime.NewBitStreamDecoder(bytes, runtime.MSBFirst)
+	return decodeTXT_RecordValueEach(decoder, nil, fn)
+}
+
+func decodeTXT_RecordValueEach(decoder *runtime.BitStreamDecoder, ctx *runtime.DecodingContext, fn func(item uint8) error) (*TXT_Record, error) {
+	const traceEnabled = runtime.TraceEnabled
+	result := &TXT_Record{}
+
+	value_length, err := decoder.ReadUint8()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(value_length); i++ {
+		if err := decoder.CheckCanceled(); err != nil {
+			return nil, err
+		}
+		value_item, err := decoder.ReadUint8()
+		if err != nil {
+			return nil, err
+		}
+		if err := fn(value_item); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// String returns a readable one-line rendering of TXT_Record using schema field names
+func (m *TXT_Record) String() string {
+	if m == nil {
+		return "nil"
+	}
+	f := &runtime.Formatter{}
+	m.format(f)
+	return f.String()
+}
+
+// GoString returns TXT_Record as a Go composite literal, for %#v
+func (m *TXT_Record) GoString() string {
+	if m == nil {
+		return "(*TXT_Record)(nil)"
+	}
+	f := &runtime.Formatter{GoSyntax: true}
+	f.Pointer()
+	m.format(f)
+	return f.String()
+}
+
+func (m *TXT_Record) format(f *runtime.Formatter) {
+	f.BeginStruct("TXT_Record")
+	f.Field("value", "Value")
+	f.Value(m.Value)
+	f.EndStruct()
+}
+
+// Equal reports whether m and other hold the same values. Floats are compared bit for
+// bit, so NaN equals itself, and a nil slice equals an empty one. A lazy field that
+// fails to decode equals nothing.
+func (m *TXT_Record) Equal(other *TXT_Record) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if string(m.Value) != string(other.Value) {
+		return false
+	}
+	return true
+}
+
+// Hash returns a hash of m that is the same for values Equal reports equal, on every
+// platform and in every process
+func (m *TXT_Record) Hash() uint64 {
+	h := runtime.NewHasher()
+	m.hash(h)
+	return h.Sum64()
+}
+
+func (m *TXT_Record) hash(h *runtime.Hasher) {
+	if m == nil {
+		h.Uint(0)
+		return
+	}
+	h.Uint(1)
+	h.Bytes(m.Value)
+}
+
+// MarshalJSON encodes TXT_Record with schema field names; byte arrays become arrays of numbers
+func (m TXT_Record) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		Value runtime.JSONBytes `json:"value"`
+	}{
+		Value: runtime.JSONBytes(m.Value),
+	})
+}
+
+// UnmarshalJSON decodes TXT_Record from the JSON MarshalJSON produces
+func (m *TXT_Record) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Value runtime.JSONBytes `json:"value"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	m.Value = []uint8(v.Value)
+	return nil
+}
+
+// Clone returns a deep copy of TXT_Record that shares no memory with m or the input it was decoded from
+func (m *TXT_Record) Clone() *TXT_Record {
+	if m == nil {
+		return nil
+	}
+	out := *m
+	if m.Value != nil {
+		out.Value = make([]uint8, len(m.Value))
+		copy(out.Value, m.Value)
+	}
+	return &out
+}
+
+// DumpAnnotated decodes data as DNSHeader and returns a hex dump annotated with each
+// field's offset, bytes and decoded value. If decoding fails the dump shows the
+// fields read up to the failure and the decode error is returned with it.
+func DumpAnnotated(data []byte) (string, error) {
+	trace := &runtime.Trace{}
+	decoder := runtime.NewBitStreamDecoder(data, runtime.MSBFirst)
+	decoder.Trace = trace
+	if _, err := decodeDNSHeaderWithDecoder(decoder, nil); err != nil {
+		decoder.TraceAbort()
+		return trace.Dump("DNSHeader", data), err
+	}
+	return trace.Dump("DNSHeader", data), nil
+}
+
+// Schema describes the schema this package was generated from. Each call returns
+// a copy, which the caller may change
+func Schema() *runtime.SchemaInfo {
+	return schemaInfo.Clone()
+}
+
+var schemaInfo = runtime.SchemaInfo{
+	Endianness: "big_endian",
+	BitOrder:   "msb_first",
+	Types: []runtime.TypeInfo{
+		{
+			Name:        "AAAA_Record",
+			GoName:      "AAAA_Record",
+			Description: "AAAA record RDATA - IPv6 address (TYPE=28, CLASS=IN)",
+			Width:       128,
+			Fields: []runtime.FieldInfo{
+				{
+					Name:        "address_high",
+					GoName:      "Address_high",
+					Type:        "uint64",
+					Width:       64,
+					Endianness:  "big_endian",
+					Description: "High 64 bits of IPv6 address",
+				},
+				{
+					Name:        "address_low",
+					GoName:      "Address_low",
+					Type:        "uint64",
+					Width:       64,
+					Endianness:  "big_endian",
+					Description: "Low 64 bits of IPv6 address",
+				},
+			},
+		},
+		{
+			Name:        "A_Record",
+			GoName:      "A_Record",
+			Description: "A record RDATA - IPv4 address (TYPE=1, CLASS=IN)",
+			Width:       32,
+			Fields: []runtime.FieldInfo{
+				{
+					Name:        "address",
+					GoName:      "Address",
+					Type:        "uint32",
+					Width:       32,
+					Endianness:  "big_endian",
+					Description: "A 32-bit Internet address (IPv4). Hosts with multiple Internet addresses will have multiple A records",
+				},
+			},
+		},
+		{
+			Name:        "CNAME_Record",
+			GoName:      "CNAME_Record",
+			Description: "CNAME record RDATA - canonical name for an alias (TYPE=5)",
+			Fields: []runtime.FieldInfo{
+				{
+					Name:        "cname",
+					GoName:      "Cname",
+					Type:        "DomainName",
+					Endianness:  "big_endian",
+					Description: "A domain name which specifies the canonical or primary name for the owner. The owner name is an alias",
+				},
+			},
+		},
+		{
+			Name:        "DNSHeader",
+			GoName:      "DNSHeader",
+			Description: "DNS message header - fixed 12 bytes at start of all DNS messages",
+			Width:       96,
+			Fields: []runtime.FieldInfo{
+				{
+					Name:        "id",
+					GoName:      "Id",
+					Type:        "uint16",
+					Width:       16,
+					Endianness:  "big_endian",
+					Description: "A 16-bit identifier assigned by the program that generates any kind of query. This identifier is copied into the corresponding reply and can be used by the requester to match up replies to outstanding queries.",
+				},
+				{
+					Name:        "qr",
+					GoName:      "Qr",
+					Type:        "bit",
+					Width:       1,
+					Endianness:  "big_endian",
+					Description: "Query/Response flag - 1 bit that specifies whether this message is a query (0) or a response (1)",
+				},
+				{
+					Name:        "opcode",
+					GoName:      "Opcode",
+					Type:        "bit",
+					Width:       4,
+					Endianness:  "big_endian",
+					Description: "Operation code - 4 bits specifying the kind of query. Set by originator, copied into response. 0=standard query (QUERY), 1=inverse query (IQUERY), 2=server status request (STATUS), 3-15=reserved for future use",
+				},
+				{
+					Name:        "aa",
+					GoName:      "Aa",
+					Type:        "bit",
+					Width:       1,
+					Endianness:  "big_endian",
+					Description: "Authoritative Answer - 1 bit valid in responses. Specifies that the responding name server is an authority for the domain name in the question section. Note: AA corresponds to the name matching the query name, or the first owner name in the answer section",
+				},
+				{
+					Name:        "tc",
+					GoName:      "Tc",
+					Type:        "bit",
+					Width:       1,
+					Endianness:  "big_endian",
+					Description: "TrunCation - 1 bit specifying that this message was truncated due to length greater than that permitted on the transmission channel (512 bytes for UDP)",
+				},
+				{
+					Name:        "rd",
+					GoName:      "Rd",
+					Type:        "bit",
+					Width:       1,
+					Endianness:  "big_endian",
+					Description: "Recursion Desired - 1 bit that may be set in a query and is copied into the response. If set, it directs the name server to pursue the query recursively. Recursive query support is optional",
+				},
+				{
+					Name:        "ra",
+					GoName:      "Ra",
+					Type:        "bit",
+					Width:       1,
+					Endianness:  "big_endian",
+					Description: "Recursion Available - 1 bit set or cleared in a response. Denotes whether recursive query support is available in the name server",
+				},
+				{
+					Name:        "z",
+					GoName:      "Z",
+					Type:        "bit",
+					Width:       3,
+					Endianness:  "big_endian",
+					Description: "Reserved for future use - 3 bits. Must be zero in all queries and responses",
+				},
+				{
+					Name:        "rcode",
+					GoName:      "Rcode",
+					Type:        "bit",
+					Width:       4,
+					Endianness:  "big_endian",
+					Description: "Response code - 4 bits set as part of responses. 0=No error, 1=Format error (unable to interpret query), 2=Server failure, 3=Name Error (domain does not exist, only from authoritative server), 4=Not Implemented (query type not supported), 5=Refused (policy reasons), 6-15=reserved",
+				},
+				{
+					Name:        "qdcount",
+					GoName:      "Qdcount",
+					Type:        "uint16",
+					Width:       16,
+					Endianness:  "big_endian",
+					Description: "An unsigned 16-bit integer specifying the number of entries in the question section",
+				},
+				{
+					Name:        "ancount",
+					GoName:      "Ancount",
+					Type:        "uint16",
+					Width:       16,
+					Endianness:  "big_endian",
+					Description: "An unsigned 16-bit integer specifying the number of resource records in the answer section",
+				},
+				{
+					Name:        "nscount",
+					GoName:      "Nscount",
+					Type:        "uint16",
+					Width:       16,
+					Endianness:  "big_endian",
+					Description: "An unsigned 16-bit integer specifying the number of name server resource records in the authority records section",
+				},
+				{
+					Name:        "arcount",
+					GoName:      "Arcount",
+					Type:        "uint16",
+					Width:       16,
+					Endianness:  "big_endian",
+					Description: "An unsigned 16-bit integer specifying the number of resource records in the additional records section",
+				},
+			},
+		},
+		{
+			Name:        "DomainName",
+			GoName:      "DomainName",
+			Description: "Complete domain name - sequence of labels terminated by zero-length label",
+			Fields: []runtime.FieldInfo{
+				{
+					Name:       "value",
+					GoName:     "Value",
+					Type:       "array",
+					Kind:       "null_terminated",
+					Endianness: "big_endian",
+					Items: &runtime.FieldInfo{
+						Type:       "Label",
+						Endianness: "big_endian",
+					},
+				},
+			},
+		},
+		{
+			Name:        "Label",
+			GoName:      "Label",
+			Description: "Single DNS label - length byte (0-63) followed by that many ASCII characters",
+			Fields: []runtime.FieldInfo{
+				{
+					Name:       "value",
+					GoName:     "Value",
+					Type:       "string",
+					Kind:       "length_prefixed",
+					Endianness: "big_endian",
+					LengthType: "uint8",
+				},
+			},
+		},
+		{
+			Name:        "MX_Record",
+			GoName:      "MX_Record",
+			Description: "MX record RDATA - mail exchange (TYPE=15)",
+			Fields: []runtime.FieldInfo{
+				{
+					Name:        "preference",
+					GoName:      "Preference",
+					Type:        "uint16",
+					Width:       16,
+					Endianness:  "big_endian",
+					Description: "A 16-bit preference value used when multiple mail exchanges exist",
+				},
+				{
+					Name:        "exchange",
+					GoName:      "Exchange",
+					Type:        "DomainName",
+					Endianness:  "big_endian",
+					Description: "A domain name which specifies a mail exchange",
+				},
+			},
+		},
+		{
+			Name:        "NS_Record",
+			GoName:      "NS_Record",
+			Description: "NS record RDATA - authoritative name server (TYPE=2)",
+			Fields: []runtime.FieldInfo{
+				{
+					Name:        "nsdname",
+					GoName:      "Nsdname",
+					Type:        "DomainName",
+					Endianness:  "big_endian",
+					Description: "A domain name which specifies a host which should be authoritative for the specified class and domain",
+				},
+			},
+		},
+		{
+			Name:        "PTR_Record",
+			GoName:      "PTR_Record",
+			Description: "PTR record RDATA - pointer to domain name (TYPE=12)",
+			Fields: []runtime.FieldInfo{
+				{
+					Name:        "ptrdname",
+					GoName:      "Ptrdname",
+					Type:        "DomainName",
+					Endianness:  "big_endian",
+					Description: "A domain-name pointer which points to some other location in the domain name space",
+				},
+			},
+		},
+		{
+			Name:        "Pointer",
+			GoName:      "Pointer",
+			Description: "DNS compression pointer - 2 bytes where top 2 bits are 11, followed by 14-bit offset from start of DNS message",
+			Width:       16,
+			Fields: []runtime.FieldInfo{
+				{
+					Name:       "value",
+					GoName:     "Value",
+					Type:       "uint16",
+					Width:      16,
+					Endianness: "big_endian",
+				},
+			},
+		},
+		{
+			Name:        "Question",
+			GoName:      "Question",
+			Description: "Question section entry - what is being asked",
+			Fields: []runtime.FieldInfo{
+				{
+					Name:        "qname",
+					GoName:      "Qname",
+					Type:        "DomainName",
+					Endianness:  "big_endian",
+					Description: "A domain name represented as a sequence of labels. Each label consists of a length octet followed by that number of octets. The domain name terminates with the zero length octet for the null label of the root. May be an odd number of octets; no padding is used",
+				},
+				{
+					Name:        "qtype",
+					GoName:      "Qtype",
+					Type:        "uint16",
+					Width:       16,
+					Endianness:  "big_endian",
+					Description: "A two-octet code which specifies the type of the query. Values include all TYPE codes plus some more general codes which can match more than one type: 1=A, 2=NS, 5=CNAME, 6=SOA, 12=PTR, 15=MX, 16=TXT, 28=AAAA, 252=AXFR (zone transfer), 255=* (all records)",
+				},
+				{
+					Name:        "qclass",
+					GoName:      "Qclass",
+					Type:        "uint16",
+					Width:       16,
+					Endianness:  "big_endian",
+					Description: "A two-octet code that specifies the class of the query. 1=IN (Internet), 2=CS (CSNET, obsolete), 3=CH (CHAOS), 4=HS (Hesiod), 255=* (any class)",
+				},
+			},
+		},
+		{
+			Name:        "ResourceRecord",
+			GoName:      "ResourceRecord",
+			Description: "Resource record - answer/authority/additional section entry",
+			Fields: []runtime.FieldInfo{
+				{
+					Name:        "name",
+					GoName:      "Name",
+					Type:        "DomainName",
+					Endianness:  "big_endian",
+					Description: "An owner name - the domain name to which this resource record pertains. Can be a domain name or a compression pointer (2 bytes starting with bits 11)",
+				},
+				{
+					Name:        "rtype",
+					GoName:      "Rtype",
+					Type:        "uint16",
+					Width:       16,
+					Endianness:  "big_endian",
+					Description: "Two octets containing one of the RR TYPE codes. Specifies the meaning of the data in the RDATA field. Common types: 1=A (host address), 2=NS (authoritative name server), 5=CNAME (canonical name), 6=SOA (start of authority), 12=PTR (domain name pointer), 15=MX (mail exchange), 16=TXT (text strings), 28=AAAA (IPv6 address)",
+				},
+				{
+					Name:        "rclass",
+					GoName:      "Rclass",
+					Type:        "uint16",
+					Width:       16,
+					Endianness:  "big_endian",
+					Description: "Two octets which specify the class of the data in the RDATA field. 1=IN (Internet), 3=CH (CHAOS), 4=HS (Hesiod)",
+				},
+				{
+					Name:        "ttl",
+					GoName:      "Ttl",
+					Type:        "uint32",
+					Width:       32,
+					Endianness:  "big_endian",
+					Description: "A 32-bit unsigned integer that specifies the time interval (in seconds) that the resource record may be cached before it should be discarded. Zero values mean the RR can only be used for the transaction in progress and should not be cached",
+				},
+				{
+					Name:        "rdlength",
+					GoName:      "Rdlength",
+					Type:        "uint16",
+					Width:       16,
+					Endianness:  "big_endian",
+					Description: "An unsigned 16-bit integer that specifies the length in octets of the RDATA field",
+				},
+				{
+					Name:       "rdata",
+					GoName:     "Rdata",
+					Type:       "array",
+					Kind:       "field_referenced",
+					Endianness: "big_endian",
+					Items: &runtime.FieldInfo{
+						Type:       "uint8",
+						Width:      8,
+						Endianness: "big_endian",
+					},
+				},
+			},
+		},
+		{
+			Name:        "SOA_Record",
+			GoName:      "SOA_Record",
+			Description: "SOA record RDATA - start of authority (TYPE=6)",
+			Fields: []runtime.FieldInfo{
+				{
+					Name:        "mname",
+					GoName:      "Mname",
+					Type:        "DomainName",
+					Endianness:  "big_endian",
+					Description: "The name of the primary master name server for the zone",
+				},
+				{
+					Name:        "rname",
+					GoName:      "Rname",
+					Type:        "DomainName",
+					Endianness:  "big_endian",
+					Description: "The mailbox of the person responsible for this zone",
+				},
+				{
+					Name:        "serial",
+					GoName:      "Serial",
+					Type:        "uint32",
+					Width:       32,
+					Endianness:  "big_endian",
+					Description: "The unsigned 32 bit version number of the original copy of the zone. Zone transfers preserve this value",
+				},
+				{
+					Name:        "refresh",
+					GoName:      "Refresh",
+					Type:        "uint32",
+					Width:       32,
+					Endianness:  "big_endian",
+					Description: "The time interval before the zone should be refreshed",
+				},
+				{
+					Name:        "retry",
+					GoName:      "Retry",
+					Type:        "uint32",
+					Width:       32,
+					Endianness:  "big_endian",
+					Description: "The time interval that should elapse before a failed refresh should be retried",
+				},
+				{
+					Name:        "expire",
+					GoName:      "Expire",
+					Type:        "uint32",
+					Width:       32,
+					Endianness:  "big_endian",
+					Description: "The upper limit on the time interval that can elapse before the zone is no longer authoritative",
+				},
+				{
+					Name:        "minimum",
+					GoName:      "Minimum",
+					Type:        "uint32",
+					Width:       32,
+					Endianness:  "big_endian",
+					Description: "The minimum TTL field that should be exported with any RR from this zone",
+				},
+			},
+		},
+		{
+			Name:        "TXT_Record",
+			GoName:      "TXT_Record",
+			Description: "TXT record RDATA - text strings (TYPE=16)",
+			Fields: []runtime.FieldInfo{
+				{
+					Name:       "value",
+					GoName:     "Value",
+					Type:       "array",
+					Kind:       "length_prefixed",
+					Endianness: "big_endian",
+					LengthType: "uint8",
+					Items: &runtime.FieldInfo{
+						Type:       "uint8",
+						Width:      8,
+						Endianness: "big_endian",
+					},
+				},
+			},
+		},
+	},
+}